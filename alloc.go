@@ -0,0 +1,181 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"sort"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// AllocSite はヒープ確保の候補となる箇所
+type AllocSite struct {
+	Func   string         `json:"func"`
+	Kind   string         `json:"kind"` // new, complit, varargs, escape, make, closure, concat
+	Type   string         `json:"type"`
+	Pos    token.Position `json:"pos"`
+	InLoop bool           `json:"in_loop"`
+}
+
+func runAllocs(args []string) error {
+	fs := flag.NewFlagSet("allocs", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output as JSON")
+	top := fs.Int("top", 0, "summarize the `N` functions with the most allocation sites")
+	fs.Parse(args)
+	prog, err := loadProgram(".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	sites := allocSites(prog)
+	relativizePositions(sites)
+	if *top > 0 {
+		hot := allocHotSpots(sites, *top)
+		if *asJSON {
+			return writeJSON(os.Stdout, hot)
+		}
+		return writeAllocHotSpots(os.Stdout, hot)
+	}
+	if *asJSON {
+		return writeJSON(os.Stdout, sites)
+	}
+	for _, s := range sites {
+		loop := ""
+		if s.InLoop {
+			loop = " in loop"
+		}
+		fmt.Printf("%s: %s: %s %s%s\n", s.Pos, s.Func, s.Kind, s.Type, loop)
+	}
+	return nil
+}
+
+// allocSites は解析対象の関数からヒープ確保の候補を SSA で探す
+func allocSites(prog *Program) []AllocSite {
+	var sites []AllocSite
+	for _, fn := range prog.targetFunctions() {
+		loops := loopBlocks(fn)
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				var kind string
+				var t types.Type
+				pos := instr.Pos()
+				switch instr := instr.(type) {
+				case *ssa.Alloc:
+					if !instr.Heap {
+						continue
+					}
+					t = instr.Type().(*types.Pointer).Elem()
+					switch instr.Comment {
+					case "new", "varargs":
+						kind = instr.Comment
+					case "complit", "slicelit":
+						kind = "complit"
+					case "makeslice":
+						kind = "make"
+					default:
+						kind = "escape" // 変数がエスケープしている
+					}
+				case *ssa.MakeSlice, *ssa.MakeMap, *ssa.MakeChan:
+					kind, t = "make", instr.(ssa.Value).Type()
+				case *ssa.MakeClosure:
+					if len(instr.Bindings) == 0 {
+						continue
+					}
+					kind, t = "closure", instr.Type()
+					pos = instr.Fn.Pos()
+				case *ssa.BinOp:
+					if instr.Op != token.ADD || !loops[b] {
+						continue
+					}
+					if basic, ok := instr.Type().Underlying().(*types.Basic); !ok || basic.Info()&types.IsString == 0 {
+						continue
+					}
+					kind, t = "concat", instr.Type()
+				default:
+					continue
+				}
+				sites = append(sites, AllocSite{
+					Func:   fn.RelString(nil),
+					Kind:   kind,
+					Type:   types.TypeString(t, types.RelativeTo(fn.Pkg.Pkg)),
+					Pos:    prog.Fset.Position(pos),
+					InLoop: loops[b],
+				})
+			}
+		}
+	}
+	return sites
+}
+
+// loopBlocks は fn の中でループの一部になっている (自分自身に戻ってこられる) ブロックを返す
+func loopBlocks(fn *ssa.Function) map[*ssa.BasicBlock]bool {
+	loops := make(map[*ssa.BasicBlock]bool)
+	for _, b := range fn.Blocks {
+		seen := make(map[*ssa.BasicBlock]bool)
+		stack := append([]*ssa.BasicBlock{}, b.Succs...)
+		for len(stack) > 0 {
+			s := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if s == b {
+				loops[b] = true
+				break
+			}
+			if seen[s] {
+				continue
+			}
+			seen[s] = true
+			stack = append(stack, s.Succs...)
+		}
+	}
+	return loops
+}
+
+// AllocHotSpot は関数ごとのヒープ確保候補の数
+type AllocHotSpot struct {
+	Func   string `json:"func"`
+	Sites  int    `json:"sites"`
+	InLoop int    `json:"in_loop"`
+}
+
+// allocHotSpots はループ内の確保数、全体の確保数の多い順に上位 n 件の関数を返す
+func allocHotSpots(sites []AllocSite, n int) []AllocHotSpot {
+	byFunc := make(map[string]*AllocHotSpot)
+	var hot []AllocHotSpot
+	for _, s := range sites {
+		h, ok := byFunc[s.Func]
+		if !ok {
+			h = &AllocHotSpot{Func: s.Func}
+			byFunc[s.Func] = h
+		}
+		h.Sites++
+		if s.InLoop {
+			h.InLoop++
+		}
+	}
+	for _, h := range byFunc {
+		hot = append(hot, *h)
+	}
+	sort.Slice(hot, func(i, j int) bool {
+		if hot[i].InLoop != hot[j].InLoop {
+			return hot[i].InLoop > hot[j].InLoop
+		}
+		if hot[i].Sites != hot[j].Sites {
+			return hot[i].Sites > hot[j].Sites
+		}
+		return hot[i].Func < hot[j].Func
+	})
+	if len(hot) > n {
+		hot = hot[:n]
+	}
+	return hot
+}
+
+func writeAllocHotSpots(w io.Writer, hot []AllocHotSpot) error {
+	for _, h := range hot {
+		fmt.Fprintf(w, "%4d sites %4d in loops  %s\n", h.Sites, h.InLoop, h.Func)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestAllocSites(t *testing.T) {
+	src := `package main
+
+type Calculator struct {
+	nested *Calculator
+}
+
+func NewCalculator() *Calculator {
+	return &Calculator{nested: new(Calculator)}
+}
+
+func join(names []string) string {
+	s := ""
+	for _, name := range names {
+		s += name + ","
+	}
+	return s
+}
+
+func counter() func() int {
+	n := 0
+	return func() int {
+		n++
+		return n
+	}
+}
+
+func index(names []string) map[string]int {
+	m := make(map[string]int)
+	for i, name := range names {
+		m[name] = i
+	}
+	return m
+}
+
+func main() {
+	_ = NewCalculator()
+	_ = join([]string{"a", "b"})
+	_ = counter()
+	_ = index(nil)
+}
+`
+	prog := loadTestProgram(t, map[string]string{"main.go": src})
+	sites := allocSites(prog)
+	var got []string
+	for _, s := range sites {
+		got = append(got, fmt.Sprintf("%d: %s %s %s %v", s.Pos.Line, s.Func, s.Kind, s.Type, s.InLoop))
+	}
+	assertLines(t, got, []string{
+		"8: example.com/m.NewCalculator complit Calculator false",
+		"8: example.com/m.NewCalculator new Calculator false",
+		"14: example.com/m.join concat string true",
+		"14: example.com/m.join concat string true",
+		"20: example.com/m.counter escape int false",
+		"21: example.com/m.counter closure func() int false",
+		"28: example.com/m.index make map[string]int false",
+		"37: example.com/m.main complit [2]string false",
+	})
+
+	var hot []string
+	for _, h := range allocHotSpots(sites, 2) {
+		hot = append(hot, fmt.Sprintf("%s %d %d", h.Func, h.Sites, h.InLoop))
+	}
+	assertLines(t, hot, []string{
+		"example.com/m.join 2 2",
+		"example.com/m.NewCalculator 2 0",
+	})
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/buildssa"
+	"golang.org/x/tools/go/packages"
+)
+
+// Analyzers は指摘の解析を analysis.Analyzer にしたもの。パッケージごとに実行されるので、
+// 複数のパッケージにまたがる解析 (deadcode, deprecated など) は含めない
+var Analyzers = []*analysis.Analyzer{
+	newAnalyzer("assertcheck", "report impossible and unchecked type assertions", checkTypeAssertions),
+	newFixAnalyzer("concatloop", "report strings built by concatenation in loops and suggest strings.Builder", checkConcatLoops, fixConcatLoopAt),
+	newAnalyzer("ctxcheck", "report context.Context misuse", checkContext),
+	newAnalyzer("deadbranch", "report branches guarded by constant conditions and the code they make unreachable", checkDeadBranches),
+	newAnalyzer("doccheck", "report missing or malformed doc comments on exported identifiers", checkDocs),
+	newFixAnalyzer("importcheck", "report ungrouped, misnamed and dot imports and suggest regrouping them", checkDefaultImports, fixImportsAt),
+	newFixAnalyzer("makecap", "report make calls missing a size hint that the following loop determines", checkMakeCaps, fixMakeCapAt),
+	newAnalyzer("nilness", "report pointer dereferences that may be nil on some path", checkNilness),
+}
+
+// newAnalyzer は check を pass のパッケージだけからなる Program に適用する Analyzer を作る
+func newAnalyzer(name, doc string, check func(*Program) []Diagnostic) *analysis.Analyzer {
+	return &analysis.Analyzer{
+		Name:     name,
+		Doc:      doc,
+		Requires: []*analysis.Analyzer{buildssa.Analyzer},
+		Run: func(pass *analysis.Pass) (interface{}, error) {
+			prog := passProgram(pass)
+			diags := check(prog)
+			sortDiagnostics(diags)
+			for _, d := range diags {
+				pass.Report(analysis.Diagnostic{
+					Pos:      passPos(pass, d.Pos),
+					Category: d.Category,
+					Message:  d.Message,
+				})
+			}
+			return nil, nil
+		},
+	}
+}
+
+// newFixAnalyzer は newAnalyzer と同じだが、指摘ごとに fix で直したファイルの内容との差分を SuggestedFixes にする。
+// fix は構文木を書き換えるので、pass の構文木を共有するほかの Analyzer に影響しないよう、
+// 指摘ごとにファイルを読み直して型検査した Program (reloadPass) を渡す
+func newFixAnalyzer(name, doc string, check func(*Program) []Diagnostic, fix func(*Program, Diagnostic) (map[string][]byte, error)) *analysis.Analyzer {
+	a := newAnalyzer(name, doc, check)
+	a.Run = func(pass *analysis.Pass) (interface{}, error) {
+		diags := check(passProgram(pass))
+		sortDiagnostics(diags)
+		for _, d := range diags {
+			prog, err := reloadPass(pass)
+			if err != nil {
+				return nil, err
+			}
+			files, err := fix(prog, d)
+			if err != nil {
+				return nil, err
+			}
+			diag := analysis.Diagnostic{Pos: passPos(pass, d.Pos), Category: d.Category, Message: d.Message}
+			if edits := passEdits(pass, files); len(edits) > 0 {
+				diag.SuggestedFixes = []analysis.SuggestedFix{{Message: "Fix " + name, TextEdits: edits}}
+			}
+			pass.Report(diag)
+		}
+		return nil, nil
+	}
+	return a
+}
+
+// passProgram は pass のパッケージを解析対象とする Program を作る。SSA は buildssa の結果を使う
+func passProgram(pass *analysis.Pass) *Program {
+	pkg := &packages.Package{
+		ID:         pass.Pkg.Path(),
+		Name:       pass.Pkg.Name(),
+		PkgPath:    pass.Pkg.Path(),
+		Fset:       pass.Fset,
+		Syntax:     pass.Files,
+		Types:      pass.Pkg,
+		TypesInfo:  pass.TypesInfo,
+		TypesSizes: pass.TypesSizes,
+	}
+	for _, f := range pass.Files {
+		pkg.CompiledGoFiles = append(pkg.CompiledGoFiles, pass.Fset.File(f.Pos()).Name())
+	}
+	pkg.GoFiles = pkg.CompiledGoFiles
+	prog := &Program{Fset: pass.Fset, Packages: []*packages.Package{pkg}}
+	res := pass.ResultOf[buildssa.Analyzer].(*buildssa.SSA)
+	prog.ssaOnce.Do(func() {
+		prog.ssa = res.Pkg.Prog
+		prog.ssaPkgs = append(prog.ssaPkgs, res.Pkg)
+	})
+	return prog
+}
+
+// reloadPass は pass のファイルを読み直し、pass の依存先のパッケージを使って型検査した Program を作る。SSA は作らない
+func reloadPass(pass *analysis.Pass) (*Program, error) {
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, f := range pass.Files {
+		name := pass.Fset.File(f.Pos()).Name()
+		src, err := passReadFile(pass, name)
+		if err != nil {
+			return nil, err
+		}
+		file, err := parser.ParseFile(fset, name, src, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	imports := make(map[string]*types.Package)
+	for _, imp := range pass.Pkg.Imports() {
+		imports[imp.Path()] = imp
+	}
+	info := &types.Info{
+		Types:        make(map[ast.Expr]types.TypeAndValue),
+		Instances:    make(map[*ast.Ident]types.Instance),
+		Defs:         make(map[*ast.Ident]types.Object),
+		Uses:         make(map[*ast.Ident]types.Object),
+		Implicits:    make(map[ast.Node]types.Object),
+		Selections:   make(map[*ast.SelectorExpr]*types.Selection),
+		Scopes:       make(map[ast.Node]*types.Scope),
+		FileVersions: make(map[*ast.File]string),
+	}
+	conf := types.Config{
+		Importer: importerFunc(func(path string) (*types.Package, error) {
+			if imp, ok := imports[path]; ok {
+				return imp, nil
+			}
+			return nil, fmt.Errorf("package %s is not imported by %s", path, pass.Pkg.Path())
+		}),
+		GoVersion: pass.Pkg.GoVersion(),
+		Sizes:     pass.TypesSizes,
+	}
+	tpkg, err := conf.Check(pass.Pkg.Path(), fset, files, info)
+	if err != nil {
+		return nil, err
+	}
+	pkg := &packages.Package{
+		ID:         tpkg.Path(),
+		Name:       tpkg.Name(),
+		PkgPath:    tpkg.Path(),
+		Fset:       fset,
+		Syntax:     files,
+		Types:      tpkg,
+		TypesInfo:  info,
+		TypesSizes: pass.TypesSizes,
+	}
+	for _, f := range files {
+		pkg.CompiledGoFiles = append(pkg.CompiledGoFiles, fset.File(f.Pos()).Name())
+	}
+	pkg.GoFiles = pkg.CompiledGoFiles
+	return &Program{Fset: fset, Packages: []*packages.Package{pkg}}, nil
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
+
+// passReadFile は pass のファイルの内容を返す。ReadFile を設定しないドライバーではファイルを直接読む
+func passReadFile(pass *analysis.Pass, name string) ([]byte, error) {
+	if pass.ReadFile != nil {
+		return pass.ReadFile(name)
+	}
+	return os.ReadFile(name)
+}
+
+// passEdits は files (ファイル名から直した内容) を、pass のファイルの変わった行ごとの TextEdit にする
+func passEdits(pass *analysis.Pass, files map[string][]byte) []analysis.TextEdit {
+	var edits []analysis.TextEdit
+	for _, f := range pass.Files {
+		tf := pass.Fset.File(f.Pos())
+		src, ok := files[tf.Name()]
+		if !ok {
+			continue
+		}
+		orig, err := passReadFile(pass, tf.Name())
+		if err != nil {
+			continue
+		}
+		for _, e := range lineEdits(orig, src) {
+			edits = append(edits, analysis.TextEdit{Pos: tf.Pos(e.start), End: tf.Pos(e.end), NewText: e.text})
+		}
+	}
+	return edits
+}
+
+// byteEdit は元の内容の [start, end) を text に置き換える編集
+type byteEdit struct {
+	start, end int
+	text       []byte
+}
+
+// lineEdits は old を new にする編集を、行の最長共通部分列から変わった行の範囲ごとに返す
+func lineEdits(old, new []byte) []byteEdit {
+	a, b := bytes.SplitAfter(old, []byte("\n")), bytes.SplitAfter(new, []byte("\n"))
+	// 共通の先頭と末尾を除いた部分だけを比べる
+	pre, offset := 0, 0
+	for pre < len(a) && pre < len(b) && bytes.Equal(a[pre], b[pre]) {
+		offset += len(a[pre])
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && bytes.Equal(a[len(a)-1-suf], b[len(b)-1-suf]) {
+		suf++
+	}
+	a, b = a[pre:len(a)-suf], b[pre:len(b)-suf]
+	// lcs[i][j] は a[i:] と b[j:] の最長共通部分列の長さ
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if bytes.Equal(a[i], b[j]) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var edits []byteEdit
+	var cur *byteEdit
+	flush := func() {
+		if cur != nil {
+			edits = append(edits, *cur)
+			cur = nil
+		}
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && bytes.Equal(a[i], b[j]):
+			flush()
+			offset += len(a[i])
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			if cur == nil {
+				cur = &byteEdit{start: offset, end: offset}
+			}
+			cur.text = append(cur.text, b[j]...)
+			j++
+		default:
+			if cur == nil {
+				cur = &byteEdit{start: offset, end: offset}
+			}
+			offset += len(a[i])
+			cur.end = offset
+			i++
+		}
+	}
+	flush()
+	return edits
+}
+
+// passPos は Diagnostic の位置を pass のファイルの token.Pos に戻す。見つからなければ NoPos を返す
+func passPos(pass *analysis.Pass, pos token.Position) token.Pos {
+	for _, f := range pass.Files {
+		tf := pass.Fset.File(f.Pos())
+		if tf == nil || tf.Name() != pos.Filename || pos.Line < 1 || pos.Line > tf.LineCount() {
+			continue
+		}
+		return tf.LineStart(pos.Line) + token.Pos(pos.Column-1)
+	}
+	return token.NoPos
+}
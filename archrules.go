@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// ArchConfig はパッケージのグループと、グループ間の依存の向きの規則
+//
+//	{
+//	  "groups": [
+//	    {"name": "handlers", "packages": ["example.com/m/handlers/..."]},
+//	    {"name": "services", "packages": ["example.com/m/services/..."]}
+//	  ],
+//	  "rules": [
+//	    {"from": "handlers", "allow": ["services"]},
+//	    {"from": "services", "deny": ["handlers"]}
+//	  ]
+//	}
+type ArchConfig struct {
+	Groups []ArchGroup `json:"groups"`
+	Rules  []ArchRule  `json:"rules"`
+}
+
+// ArchGroup はパッケージパターン (import path、または末尾が /... のもの) の集まり
+type ArchGroup struct {
+	Name     string   `json:"name"`
+	Packages []string `json:"packages"`
+}
+
+// ArchRule は From グループから他のグループへの import と呼び出しの規則。
+// Allow が空でなければそれ以外のグループへの依存を、Deny を指定した場合はそのグループへの依存を禁止する
+type ArchRule struct {
+	From  string   `json:"from"`
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+}
+
+func runArchRules(args []string) error {
+	fs := flag.NewFlagSet("archrules", flag.ExitOnError)
+	configPath := fs.String("config", "arch.json", "path to the architecture rules `file`")
+	return runDiagnostics(fs, args, func(prog *Program) ([]Diagnostic, error) {
+		conf, err := loadArchConfig(*configPath)
+		if err != nil {
+			return nil, err
+		}
+		return checkArchRules(prog, conf), nil
+	})
+}
+
+func loadArchConfig(path string) (*ArchConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var conf ArchConfig
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	groups := make(map[string]bool)
+	for _, g := range conf.Groups {
+		groups[g.Name] = true
+	}
+	for _, r := range conf.Rules {
+		for _, name := range append(append([]string{r.From}, r.Allow...), r.Deny...) {
+			if !groups[name] {
+				return nil, fmt.Errorf("%s: rule refers to unknown group %q", path, name)
+			}
+		}
+	}
+	return &conf, nil
+}
+
+// group は path が属する最初のグループの名前を返す
+func (c *ArchConfig) group(path string) string {
+	for _, g := range c.Groups {
+		for _, pattern := range g.Packages {
+			if matchPackage(pattern, path) {
+				return g.Name
+			}
+		}
+	}
+	return ""
+}
+
+// allowed は from グループから to グループへの依存が許されているかどうかを返す
+func (c *ArchConfig) allowed(from, to string) bool {
+	if from == "" || to == "" || from == to {
+		return true
+	}
+	for _, r := range c.Rules {
+		if r.From != from {
+			continue
+		}
+		for _, name := range r.Deny {
+			if name == to {
+				return false
+			}
+		}
+		if len(r.Allow) == 0 {
+			continue
+		}
+		ok := false
+		for _, name := range r.Allow {
+			if name == to {
+				ok = true
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// checkArchRules は import と呼び出しのうち、規則に反するものを指摘する
+func checkArchRules(prog *Program, conf *ArchConfig) []Diagnostic {
+	var diags []Diagnostic
+	for _, pkg := range prog.Packages {
+		from := conf.group(pkg.PkgPath)
+		for _, file := range pkg.Syntax {
+			for _, spec := range file.Imports {
+				path, err := strconv.Unquote(spec.Path.Value)
+				if err != nil {
+					continue
+				}
+				if to := conf.group(path); !conf.allowed(from, to) {
+					diags = append(diags, newDiagnostic(prog.Fset, spec.Pos(), "archrules",
+						"%s (%s) must not import %s (%s)", pkg.PkgPath, from, path, to))
+				}
+			}
+		}
+	}
+
+	cg := prog.CallGraph()
+	for _, fn := range prog.targetFunctions() {
+		node := cg.Nodes[fn]
+		if node == nil || fn.Synthetic != "" {
+			continue // パッケージの初期化による呼び出しは import の検査で扱う
+		}
+		from := conf.group(fn.Pkg.Pkg.Path())
+		for _, e := range node.Out {
+			// インターフェース経由の呼び出しは依存関係の逆転なので対象にしない
+			callee := e.Callee.Func
+			if callee.Pkg == nil || e.Site == nil || e.Site.Common().StaticCallee() == nil {
+				continue
+			}
+			if to := conf.group(callee.Pkg.Pkg.Path()); !conf.allowed(from, to) {
+				diags = append(diags, newDiagnostic(prog.Fset, e.Site.Pos(), "archrules",
+					"%s (%s) must not call %s (%s)", fn.RelString(nil), from, callee.RelString(nil), to))
+			}
+		}
+	}
+	sortDiagnostics(diags)
+	return diags
+}
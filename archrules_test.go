@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckArchRules(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"handlers/handlers.go": `package handlers
+
+import (
+	"example.com/m/services"
+	"example.com/m/views"
+)
+
+func Handle() string { return views.Render(services.Find()) }
+`,
+		"services/services.go": `package services
+
+import "example.com/m/views"
+
+type Notifier interface{ Notify() }
+
+func Find() string { return "model" }
+
+func Preview() string { return views.Render(Find()) }
+`,
+		"views/views.go": `package views
+
+func Render(s string) string { return "<" + s + ">" }
+`,
+		"arch.json": `{
+  "groups": [
+    {"name": "handlers", "packages": ["example.com/m/handlers/..."]},
+    {"name": "services", "packages": ["example.com/m/services/..."]},
+    {"name": "views", "packages": ["example.com/m/views"]}
+  ],
+  "rules": [
+    {"from": "handlers", "allow": ["services"]},
+    {"from": "services", "deny": ["handlers", "views"]}
+  ]
+}
+`,
+	})
+	prog, err := loadProgram(dir, "./...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf, err := loadArchConfig(filepath.Join(dir, "arch.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertLines(t, diagnosticMessages(checkArchRules(prog, conf)), []string{
+		"handlers.go:5: example.com/m/handlers (handlers) must not import example.com/m/views (views)",
+		"handlers.go:8: example.com/m/handlers.Handle (handlers) must not call example.com/m/views.Render (views)",
+		"services.go:3: example.com/m/services (services) must not import example.com/m/views (views)",
+		"services.go:9: example.com/m/services.Preview (services) must not call example.com/m/views.Render (views)",
+	})
+
+	bad := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(bad, []byte(`{"groups": [], "rules": [{"from": "x"}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadArchConfig(bad); err == nil {
+		t.Error("expected error for unknown group")
+	}
+}
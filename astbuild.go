@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// astPlaceholder は書式の中で ast.Node の引数を置く識別子の接頭辞
+const astPlaceholder = "__ast_arg"
+
+// expandTemplate は format の動詞を args で置き換えたソースと、識別子で仮置きした ast.Node の引数を返す。
+// ast.Expr と ast.Stmt の引数は動詞によらず仮置きの識別子にし、それ以外は fmt の書式で文字列にする
+func expandTemplate(format string, args []interface{}) (string, map[string]ast.Node, error) {
+	var src strings.Builder
+	nodes := make(map[string]ast.Node)
+	argi := 0
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if c != '%' {
+			src.WriteByte(c)
+			continue
+		}
+		// %[flags][width][.prec]verb
+		j := i + 1
+		for j < len(format) && strings.IndexByte("+-# 0123456789.", format[j]) >= 0 {
+			j++
+		}
+		if j == len(format) {
+			return "", nil, fmt.Errorf("template %q: incomplete verb at the end", format)
+		}
+		verb := format[i : j+1]
+		i = j
+		if verb == "%%" {
+			src.WriteByte('%')
+			continue
+		}
+		if argi >= len(args) {
+			return "", nil, fmt.Errorf("template %q: missing argument for %s", format, verb)
+		}
+		switch arg := args[argi].(type) {
+		case ast.Expr, ast.Stmt:
+			name := fmt.Sprintf("%s%d", astPlaceholder, argi)
+			nodes[name] = arg.(ast.Node)
+			src.WriteString(name)
+		default:
+			fmt.Fprintf(&src, verb, arg)
+		}
+		argi++
+	}
+	if argi < len(args) {
+		return "", nil, fmt.Errorf("template %q: %d extra arguments", format, len(args)-argi)
+	}
+	return src.String(), nodes, nil
+}
+
+// fillTemplate は仮置きの識別子を引数のノードに置き換え、テンプレートから作られたノードの位置を
+// token.NoPos にする。引数のノードの位置はそのまま残す
+func fillTemplate(n ast.Node, nodes map[string]ast.Node) (ast.Node, error) {
+	var err error
+	placeholder := func(n ast.Node) ast.Node {
+		if id, ok := n.(*ast.Ident); ok {
+			return nodes[id.Name]
+		}
+		return nil
+	}
+	n = astutil.Apply(n, func(c *astutil.Cursor) bool {
+		n := c.Node()
+		if stmt, ok := n.(*ast.ExprStmt); ok {
+			if arg, ok := placeholder(stmt.X).(ast.Stmt); ok {
+				c.Replace(arg)
+				return false
+			}
+		}
+		if arg := placeholder(n); arg != nil {
+			if _, ok := arg.(ast.Expr); !ok {
+				err = fmt.Errorf("statement argument %T used as an expression", arg)
+				return false
+			}
+			c.Replace(arg)
+			return false
+		}
+		clearPositions(n)
+		return true
+	}, nil)
+	return n, err
+}
+
+// clearPositions は n のフィールドのうち token.Pos 型のものを token.NoPos にする (子のノードは変えない)
+func clearPositions(n ast.Node) {
+	v := reflect.ValueOf(n)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return
+	}
+	v = v.Elem()
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	posType := reflect.TypeOf(token.NoPos)
+	for i := 0; i < v.NumField(); i++ {
+		if f := v.Field(i); f.Type() == posType && f.CanSet() {
+			f.SetInt(int64(token.NoPos))
+		}
+	}
+}
+
+// fillPositions は n とその子のノードの token.NoPos の位置を pos にする。位置のない挿入したノードの中に
+// go/printer が後ろのコメントを出力しないようにするためのもの。GenDecl の括弧や可変長引数の ... のように、
+// 位置があるかないかで意味の変わるものはそのままにする
+func fillPositions(n ast.Node, pos token.Pos) {
+	posType := reflect.TypeOf(token.NoPos)
+	ast.Inspect(n, func(n ast.Node) bool {
+		v := reflect.ValueOf(n)
+		if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+			return true
+		}
+		v = v.Elem()
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Field(i)
+			if f.Type() != posType || !f.CanSet() || token.Pos(f.Int()) != token.NoPos || meaningfulPos(n, v.Type().Field(i).Name) {
+				continue
+			}
+			f.SetInt(int64(pos))
+		}
+		return true
+	})
+}
+
+// meaningfulPos は n の name という位置のフィールドが、位置があるかないかで出力が変わるものかどうかを返す
+func meaningfulPos(n ast.Node, name string) bool {
+	switch n.(type) {
+	case *ast.GenDecl:
+		return name == "Lparen" || name == "Rparen"
+	case *ast.CallExpr:
+		return name == "Ellipsis"
+	case *ast.TypeSpec:
+		return name == "Assign"
+	case *ast.ChanType:
+		return name == "Arrow"
+	}
+	return false
+}
+
+// ParseExpr は format の動詞を args で置き換えた式を構文解析する。ast.Expr の引数はそのまま式の中に埋め込まれ、
+// それ以外の引数は fmt の書式で展開される。例えば ParseExpr("fmt.Printf(%q, %s)", "%d", arg) は
+// fmt.Printf("%d", arg) になる。テンプレートから作られたノードの位置は token.NoPos になる
+func ParseExpr(format string, args ...interface{}) (ast.Expr, error) {
+	src, nodes, err := expandTemplate(format, args)
+	if err != nil {
+		return nil, err
+	}
+	expr, err := parser.ParseExpr(src)
+	if err != nil {
+		return nil, fmt.Errorf("template %q: %w", format, parseError(err))
+	}
+	n, err := fillTemplate(expr, nodes)
+	if err != nil {
+		return nil, fmt.Errorf("template %q: %v", format, err)
+	}
+	return n.(ast.Expr), nil
+}
+
+// MustParseExpr は ParseExpr と同じだが、エラーなら panic する
+func MustParseExpr(format string, args ...interface{}) ast.Expr {
+	expr, err := ParseExpr(format, args...)
+	if err != nil {
+		panic(err)
+	}
+	return expr
+}
+
+// ParseStmts は ParseExpr と同じように文の並びを構文解析する。ast.Stmt の引数は文として置ける位置に埋め込める
+func ParseStmts(format string, args ...interface{}) ([]ast.Stmt, error) {
+	src, nodes, err := expandTemplate(format, args)
+	if err != nil {
+		return nil, err
+	}
+	file, err := parser.ParseFile(token.NewFileSet(), "", "package p; func _() {\n"+src+"\n}", 0)
+	if err != nil {
+		return nil, fmt.Errorf("template %q: %w", format, parseError(err))
+	}
+	body := file.Decls[0].(*ast.FuncDecl).Body
+	n, err := fillTemplate(body, nodes)
+	if err != nil {
+		return nil, fmt.Errorf("template %q: %v", format, err)
+	}
+	return n.(*ast.BlockStmt).List, nil
+}
+
+// MustParseStmt は 1 つの文を ParseStmts で構文解析する。エラーか文が 1 つでなければ panic する
+func MustParseStmt(format string, args ...interface{}) ast.Stmt {
+	stmts, err := ParseStmts(format, args...)
+	if err != nil {
+		panic(err)
+	}
+	if len(stmts) != 1 {
+		panic(fmt.Sprintf("template %q: got %d statements, want 1", format, len(stmts)))
+	}
+	return stmts[0]
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestParseExpr(t *testing.T) {
+	fset := token.NewFileSet()
+	arg, err := parser.ParseExprFrom(fset, "x.go", "a[i] + 1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expr := MustParseExpr("fmt.Printf(%q, %s, %d%%2)", "%d\n", arg, 7)
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, expr); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), `fmt.Printf("%d\n", a[i]+1, 7%2)`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	call := expr.(*ast.CallExpr)
+	if call.Lparen != token.NoPos || call.Fun.Pos() != token.NoPos || call.Args[0].Pos() != token.NoPos {
+		t.Errorf("template nodes have positions: %v %v %v", call.Lparen, call.Fun.Pos(), call.Args[0].Pos())
+	}
+	if call.Args[1] != arg || arg.Pos() == token.NoPos {
+		t.Errorf("argument node was not embedded as is")
+	}
+
+	for _, tc := range []struct {
+		format string
+		args   []interface{}
+	}{
+		{"f(%s, %s)", []interface{}{arg}},
+		{"f(%s)", []interface{}{arg, arg}},
+		{"f(%s", []interface{}{arg}},
+		{"f(%s)", []interface{}{&ast.ReturnStmt{}}},
+	} {
+		if _, err := ParseExpr(tc.format, tc.args...); err == nil {
+			t.Errorf("ParseExpr(%q) succeeded, want an error", tc.format)
+		}
+	}
+}
+
+func TestParseStmts(t *testing.T) {
+	body := MustParseStmt("return %s", ast.NewIdent("err"))
+	stmts, err := ParseStmts("if err := %s; err != nil {\n%s\n}\nx++", MustParseExpr("f()"), body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stmts) != 2 {
+		t.Fatalf("got %d statements, want 2", len(stmts))
+	}
+	var buf bytes.Buffer
+	for _, stmt := range stmts {
+		if err := format.Node(&buf, token.NewFileSet(), stmt); err != nil {
+			t.Fatal(err)
+		}
+		buf.WriteByte('\n')
+	}
+	want := "if err := f(); err != nil {\n\treturn err\n}\nx++\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if stmts[0].(*ast.IfStmt).Body.List[0] != body {
+		t.Errorf("statement argument was not embedded as is")
+	}
+}
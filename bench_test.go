@@ -0,0 +1,130 @@
+package main
+
+import (
+	"flag"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// 実際のプロジェクトに対するベンチマーク。既定ではこのリポジトリ自身を読み込む。
+// 別のプロジェクトは go test -run '^$' -bench Corpus -corpus /path/to/project で指定する
+var (
+	corpusDir     = flag.String("corpus", ".", "`dir` of the module loaded by the Corpus benchmarks")
+	corpusPattern = flag.String("corpus-pattern", "./...", "package `pattern` loaded by the Corpus benchmarks")
+)
+
+var (
+	corpusOnce sync.Once
+	corpusProg *Program
+	corpusErr  error
+)
+
+// loadCorpus はコーパスを 1 度だけ読み込む
+func loadCorpus(b *testing.B) *Program {
+	b.Helper()
+	corpusOnce.Do(func() {
+		corpusProg, corpusErr = loadProgramWith(loadOptions{Tolerant: true}, *corpusDir, *corpusPattern)
+	})
+	if corpusErr != nil {
+		b.Fatal(corpusErr)
+	}
+	return corpusProg
+}
+
+// freshProgram は prog と同じパッケージで SSA やコールグラフを持たない Program を返す
+func freshProgram(prog *Program) *Program {
+	return &Program{Fset: prog.Fset, Packages: prog.Packages, Errors: prog.Errors, approximate: prog.approximate}
+}
+
+func BenchmarkCorpusLoad(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := loadProgramWith(loadOptions{Tolerant: true}, *corpusDir, *corpusPattern); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCorpusIndex(b *testing.B) {
+	prog := loadCorpus(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		CallSites(prog.Packages)
+	}
+}
+
+func BenchmarkCorpusSSA(b *testing.B) {
+	prog := loadCorpus(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		freshProgram(prog).SSA()
+	}
+}
+
+func BenchmarkCorpusCallGraph(b *testing.B) {
+	prog := loadCorpus(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		p := freshProgram(prog)
+		p.SSA()
+		b.StartTimer()
+		p.CallGraph()
+	}
+}
+
+// BenchmarkCorpusQuery は SSA とコールグラフを構築済みのセッションで各解析の指摘を求める時間を測る
+func BenchmarkCorpusQuery(b *testing.B) {
+	prog := freshProgram(loadCorpus(b))
+	prog.CallGraph()
+	for _, name := range NewAnalysis(prog).Checks() {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := NewAnalysis(prog).Diagnostics(name); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// retainedHeap は build が返した値が GC の後も保持しているヒープのバイト数を返す
+func retainedHeap(build func() any) uint64 {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	v := build()
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(v)
+	if after.HeapAlloc < before.HeapAlloc {
+		return 0
+	}
+	return after.HeapAlloc - before.HeapAlloc
+}
+
+// BenchmarkCorpusRetained は読み込んだ Program をそのまま保持する場合と、Index だけを残す場合の
+// 保持するヒープの大きさを retained-B/op として比べる
+func BenchmarkCorpusRetained(b *testing.B) {
+	load := func(b *testing.B) *Program {
+		prog, err := loadProgramWith(loadOptions{Tolerant: true}, *corpusDir, *corpusPattern)
+		if err != nil {
+			b.Fatal(err)
+		}
+		return prog
+	}
+	b.Run("program", func(b *testing.B) {
+		var total uint64
+		for i := 0; i < b.N; i++ {
+			total += retainedHeap(func() any { return load(b) })
+		}
+		b.ReportMetric(float64(total)/float64(b.N), "retained-B/op")
+	})
+	b.Run("index", func(b *testing.B) {
+		var total uint64
+		for i := 0; i < b.N; i++ {
+			total += retainedHeap(func() any { return NewIndex(load(b)) })
+		}
+		b.ReportMetric(float64(total)/float64(b.N), "retained-B/op")
+	})
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/types"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+// SizeEntry はバイナリのテキストセグメントのうち、1 つのパッケージか関数が占める大きさ
+type SizeEntry struct {
+	Name      string  `json:"name"`              // パッケージのパスか、リンカーの形の関数の名前 (pkg.(*T).M)。main パッケージは main
+	Package   string  `json:"package,omitempty"` // 関数のパッケージ
+	Size      int64   `json:"size"`
+	Share     float64 `json:"share"`     // テキストセグメント全体に対する割合 (%)
+	Reachable *bool   `json:"reachable"` // main から CHA のコールグラフで到達できるか。対応する関数がなければ null
+}
+
+func runBinSize(args []string) error {
+	fs := flag.NewFlagSet("binsize", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output as JSON")
+	bin := fs.String("bin", "", "built `binary` to attribute (default: build the main package into a temporary file)")
+	by := fs.String("by", "package", "attribute sizes by package or function")
+	top := fs.Int("top", 0, "only list the `n` largest entries (0 for all)")
+	fs.Parse(args)
+	if *by != "package" && *by != "function" {
+		return fmt.Errorf("unknown -by %q (want package or function)", *by)
+	}
+	prog, err := loadProgram(".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	var mainPkgs []string
+	for _, pkg := range prog.Packages {
+		if pkg.Name == "main" {
+			mainPkgs = append(mainPkgs, pkg.PkgPath)
+		}
+	}
+	if len(mainPkgs) != 1 {
+		return fmt.Errorf("binsize needs exactly one main package, got %d", len(mainPkgs))
+	}
+	if *bin == "" {
+		dir, err := os.MkdirTemp("", "learn_ast-binsize")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+		*bin = filepath.Join(dir, "main")
+		if _, err := goCommand(".", nil, "build", "-o", *bin, mainPkgs[0]); err != nil {
+			return err
+		}
+	}
+	out, err := goCommand(".", nil, "tool", "nm", "-size", *bin)
+	if err != nil {
+		return err
+	}
+	syms, err := parseNM(bytes.NewReader(out))
+	if err != nil {
+		return err
+	}
+	entries := attributeSizes(prog, syms, *by == "function")
+	if *top > 0 && len(entries) > *top {
+		entries = entries[:*top]
+	}
+	if *asJSON {
+		return writeJSON(os.Stdout, entries)
+	}
+	return writeSizes(os.Stdout, entries)
+}
+
+// nmSymbol は go tool nm -size の 1 行
+type nmSymbol struct {
+	Name string
+	Size int64
+	Type string
+}
+
+// parseNM は go tool nm -size の出力 (アドレス、大きさ、種類、名前) のうちテキストセグメントのシンボルを返す
+func parseNM(r io.Reader) ([]nmSymbol, error) {
+	var syms []nmSymbol
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		// 未定義のシンボルにはアドレスがない
+		if len(fields) < 4 {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("go tool nm: invalid size in %q", sc.Text())
+		}
+		if typ := fields[2]; typ == "T" || typ == "t" {
+			syms = append(syms, nmSymbol{Name: strings.Join(fields[3:], " "), Size: size, Type: typ})
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(syms) == 0 {
+		return nil, errors.New("go tool nm: no text symbols (stripped binary?)")
+	}
+	return syms, nil
+}
+
+// splitSymbol はリンカーのシンボルの名前をパッケージのパスと、関数の宣言の名前に分ける。
+// 無名関数 (F.func1)、go や defer のラッパー、メソッド値 (-fm) と型引数は宣言した関数にまとめる。
+// パッケージに属さないシンボル (type:.eq.T など) はパッケージを空にする
+func splitSymbol(sym string) (pkg, fn string) {
+	// 型引数の中にもパッケージのパスがあるので、最初の [ より前の最後の / から探す
+	head := sym
+	if i := strings.Index(head, "["); i >= 0 {
+		head = head[:i]
+	}
+	slash := strings.LastIndex(head, "/") + 1
+	dot := strings.Index(sym[slash:], ".")
+	if dot < 0 || strings.Contains(sym[:slash+dot], ":") {
+		return "", sym
+	}
+	// リンカーはパスの最後の要素の . を %2e にする
+	pkg = strings.ReplaceAll(sym[:slash+dot], "%2e", ".")
+	fn = stripTypeArgs(sym[slash+dot+1:])
+	fn = strings.TrimSuffix(fn, "-fm")
+	for {
+		i := strings.LastIndex(fn, ".")
+		if i < 0 || !isClosureSuffix(fn[i+1:]) {
+			break
+		}
+		fn = fn[:i]
+	}
+	return pkg, fn
+}
+
+// stripTypeArgs は s の [...] を取り除く
+func stripTypeArgs(s string) string {
+	var b strings.Builder
+	depth := 0
+	for _, r := range s {
+		switch {
+		case r == '[':
+			depth++
+		case r == ']':
+			depth--
+		case depth == 0:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// isClosureSuffix は s が無名関数やコンパイラーが作るラッパーの名前の接尾辞 (func1, gowrap1, deferwrap1) かどうかを返す
+func isClosureSuffix(s string) bool {
+	for _, prefix := range []string{"func", "gowrap", "deferwrap"} {
+		if rest, ok := strings.CutPrefix(s, prefix); ok && rest != "" && strings.Trim(rest, "0123456789") == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// linkerName は関数の宣言の名前をリンカーの形 (pkg.F, pkg.T.M, pkg.(*T).M) で返す
+func linkerName(obj *types.Func) string {
+	name := obj.Name()
+	if recv := obj.Type().(*types.Signature).Recv(); recv != nil {
+		t := recv.Type()
+		ptr := false
+		if p, ok := t.(*types.Pointer); ok {
+			t, ptr = p.Elem(), true
+		}
+		named, ok := t.(*types.Named)
+		if !ok {
+			return ""
+		}
+		if ptr {
+			name = "(*" + named.Obj().Name() + ")." + name
+		} else {
+			name = named.Obj().Name() + "." + name
+		}
+	}
+	return linkerPackage(obj.Pkg()) + "." + name
+}
+
+// linkerPackage はリンカーのシンボルでのパッケージの名前を返す。main パッケージはパスによらず main になる
+func linkerPackage(pkg *types.Package) string {
+	if pkg.Name() == "main" {
+		return "main"
+	}
+	return pkg.Path()
+}
+
+// attributeSizes はテキストセグメントのシンボルの大きさをパッケージ (byFunc なら関数) ごとに合計し、大きい順に返す。
+// 読み込んだパッケージ (依存先を含む) の関数なら、main から到達できるかを CHA のコールグラフで求めて添える。
+// 到達できないのにバイナリに含まれる関数は、ランタイムがコンパイラーの生成したコードから呼ばれるか、
+// リフレクションやアセンブリ経由でしか使われないもの
+func attributeSizes(prog *Program, syms []nmSymbol, byFunc bool) []SizeEntry {
+	var roots []*ssa.Function
+	for _, pkg := range prog.Packages {
+		if spkg := prog.SSA().Package(pkg.Types); spkg != nil && pkg.Name == "main" {
+			roots = append(roots, spkg.Func("main"), spkg.Func("init"))
+		}
+	}
+	reachable := reachableFrom(prog, roots)
+	// 関数の宣言ごとに、その宣言 (か中の無名関数) のどれかに到達できるか
+	declReachable := make(map[string]bool)
+	pkgReachable := make(map[string]bool)
+	for fn := range ssautil.AllFunctions(prog.SSA()) {
+		decl := fn
+		for decl.Parent() != nil {
+			decl = decl.Parent()
+		}
+		if decl.Origin() != nil {
+			decl = decl.Origin()
+		}
+		obj, ok := decl.Object().(*types.Func)
+		if !ok || obj.Pkg() == nil {
+			continue
+		}
+		if name := linkerName(obj); name != "" {
+			declReachable[name] = declReachable[name] || reachable[fn]
+		}
+		pkg := linkerPackage(obj.Pkg())
+		pkgReachable[pkg] = pkgReachable[pkg] || reachable[fn]
+	}
+
+	var total int64
+	sizes := make(map[string]*SizeEntry)
+	for _, sym := range syms {
+		total += sym.Size
+		pkg, fn := splitSymbol(sym.Name)
+		key := pkg
+		if pkg == "" {
+			key = "(linker)"
+		}
+		if byFunc && pkg != "" {
+			key = pkg + "." + fn
+		}
+		e, ok := sizes[key]
+		if !ok {
+			e = &SizeEntry{Name: key}
+			if byFunc && pkg != "" {
+				e.Package = pkg
+				if r, ok := declReachable[key]; ok {
+					e.Reachable = &r
+				}
+			}
+			sizes[key] = e
+		}
+		e.Size += sym.Size
+	}
+	var entries []SizeEntry
+	for _, e := range sizes {
+		if total > 0 {
+			e.Share = float64(e.Size) * 100 / float64(total)
+		}
+		entries = append(entries, *e)
+	}
+	if !byFunc {
+		// パッケージの到達可能性は、そのパッケージの関数のどれかに到達できるか
+		for i := range entries {
+			if r, ok := pkgReachable[entries[i].Name]; ok {
+				entries[i].Reachable = &r
+			}
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Size != entries[j].Size {
+			return entries[i].Size > entries[j].Size
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	return entries
+}
+
+func writeSizes(w io.Writer, entries []SizeEntry) error {
+	fmt.Fprintf(w, "%10s %6s %-9s %s\n", "SIZE", "SHARE", "REACHABLE", "NAME")
+	for _, e := range entries {
+		reachable := "-"
+		if e.Reachable != nil {
+			reachable = strconv.FormatBool(*e.Reachable)
+		}
+		fmt.Fprintf(w, "%10d %5.1f%% %-9s %s\n", e.Size, e.Share, reachable, e.Name)
+	}
+	return nil
+}
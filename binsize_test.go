@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSplitSymbol(t *testing.T) {
+	tests := []struct {
+		sym, pkg, fn string
+	}{
+		{"main.main", "main", "main"},
+		{"example.com/m/lib.(*T).M", "example.com/m/lib", "(*T).M"},
+		{"example.com/m/lib.T.M-fm", "example.com/m/lib", "T.M"},
+		{"example.com/m/lib.F.func1.2", "example.com/m/lib", "F.func1.2"},
+		{"example.com/m/lib.F.func1.func2", "example.com/m/lib", "F"},
+		{"example.com/m/lib.F.gowrap1", "example.com/m/lib", "F"},
+		{"example.com/m/lib.Map[go.shape.int,example.com/m/x.T]", "example.com/m/lib", "Map"},
+		{"gopkg.in/yaml%2ev3.Unmarshal", "gopkg.in/yaml.v3", "Unmarshal"},
+		{"type:.eq.[2]interface {}", "", "type:.eq.[2]interface {}"},
+		{"go:buildid", "", "go:buildid"},
+	}
+	for _, tt := range tests {
+		pkg, fn := splitSymbol(tt.sym)
+		if pkg != tt.pkg || fn != tt.fn {
+			t.Errorf("splitSymbol(%q) = %q, %q, want %q, %q", tt.sym, pkg, fn, tt.pkg, tt.fn)
+		}
+	}
+}
+
+func TestAttributeSizes(t *testing.T) {
+	prog := loadTestProgram(t, map[string]string{
+		"main.go": `package main
+
+import "example.com/m/lib"
+
+func main() { lib.Used() }
+`,
+		"lib/lib.go": `package lib
+
+func Used() { go func() {}() }
+
+func Unused() {}
+`,
+	})
+	syms, err := parseNM(strings.NewReader(`  401000        600 T main.main
+  401100        300 T example.com/m/lib.Used
+  401200        100 T example.com/m/lib.Used.func1
+  401300        200 t example.com/m/lib.Unused
+  401400        800 T runtime.mallocgc
+  501000        100 D runtime.data
+           0          U _cgo_init
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	writeSizes(&buf, attributeSizes(prog, syms, false))
+	assertLines(t, strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n"), []string{
+		"      SIZE  SHARE REACHABLE NAME",
+		"       800  40.0% -         runtime",
+		"       600  30.0% true      example.com/m/lib",
+		"       600  30.0% true      main",
+	})
+
+	buf.Reset()
+	writeSizes(&buf, attributeSizes(prog, syms, true))
+	assertLines(t, strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n"), []string{
+		"      SIZE  SHARE REACHABLE NAME",
+		"       800  40.0% -         runtime.mallocgc",
+		"       600  30.0% true      main.main",
+		"       400  20.0% true      example.com/m/lib.Used",
+		"       200  10.0% false     example.com/m/lib.Unused",
+	})
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// BoxingSite は具体的な型の値をインターフェースに変換している (ヒープに確保しうる) 箇所
+type BoxingSite struct {
+	Func   string         `json:"func"`
+	Type   string         `json:"type"`          // 変換する値の型
+	Iface  string         `json:"iface"`         // 変換先のインターフェース
+	Use    string         `json:"use,omitempty"` // 変換した値を受け取る関数 (可変長引数を含む)
+	Pos    token.Position `json:"pos"`
+	InLoop bool           `json:"in_loop"`
+	FanIn  int            `json:"fan_in"` // 関数を直接かインターフェース経由で呼んでいる関数の数 (CHA)
+}
+
+func runBoxing(args []string) error {
+	fs := flag.NewFlagSet("boxing", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output as JSON")
+	minFanIn := fs.Int("min-fanin", 2, "also report conversions outside loops in functions called from at least `n` functions (0 reports every conversion)")
+	fs.Parse(args)
+	prog, err := loadProgram(".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	var sites []BoxingSite
+	for _, s := range boxingSites(prog) {
+		if s.InLoop || s.FanIn >= *minFanIn {
+			sites = append(sites, s)
+		}
+	}
+	relativizePositions(sites)
+	if *asJSON {
+		return writeJSON(os.Stdout, sites)
+	}
+	return writeBoxingSites(os.Stdout, sites)
+}
+
+// boxingSites は解析対象の関数の MakeInterface のうち、割り当てを伴いうるものを、ループの中のもの、
+// 呼び出し元の多い関数のものの順に返す。定数、ポインターの形の値 (ポインター、マップ、チャネル、関数)、
+// 大きさが 0 か 1 バイトの値はランタイムが割り当てずに済ませるので除く
+func boxingSites(prog *Program) []BoxingSite {
+	cg := prog.CallGraph()
+	var sites []BoxingSite
+	for _, fn := range prog.targetFunctions() {
+		fanIn := 0
+		if node := cg.Nodes[fn]; node != nil {
+			callers := make(map[*ssa.Function]bool)
+			for _, e := range node.In {
+				// CHA は関数値の呼び出しを同じシグネチャの全関数につなぐので数えない
+				if c := e.Site.Common(); c.IsInvoke() || c.StaticCallee() != nil {
+					callers[e.Caller.Func] = true
+				}
+			}
+			fanIn = len(callers)
+		}
+		var loops map[*ssa.BasicBlock]bool
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				mi, ok := instr.(*ssa.MakeInterface)
+				if !ok || !boxAllocates(mi) {
+					continue
+				}
+				if loops == nil {
+					loops = loopBlocks(fn)
+				}
+				qual := types.RelativeTo(fn.Pkg.Pkg)
+				use, pos := boxUse(mi)
+				if mi.Pos().IsValid() {
+					pos = mi.Pos()
+				}
+				sites = append(sites, BoxingSite{
+					Func:   fn.RelString(nil),
+					Type:   types.TypeString(mi.X.Type(), qual),
+					Iface:  types.TypeString(mi.Type(), qual),
+					Use:    use,
+					Pos:    prog.Fset.Position(pos),
+					InLoop: loops[b],
+					FanIn:  fanIn,
+				})
+			}
+		}
+	}
+	sort.SliceStable(sites, func(i, j int) bool {
+		a, b := sites[i], sites[j]
+		if a.InLoop != b.InLoop {
+			return a.InLoop
+		}
+		if a.FanIn != b.FanIn {
+			return a.FanIn > b.FanIn
+		}
+		if a.Pos.Filename != b.Pos.Filename {
+			return a.Pos.Filename < b.Pos.Filename
+		}
+		return a.Pos.Offset < b.Pos.Offset
+	})
+	return sites
+}
+
+// boxSizes は値の大きさを求めるのに使う、実行している環境の gc コンパイラーの型の大きさ
+var boxSizes = types.SizesFor("gc", runtime.GOARCH)
+
+// boxAllocates は mi がインターフェースの値のためにヒープを確保しうるかどうかを返す
+func boxAllocates(mi *ssa.MakeInterface) bool {
+	if _, ok := mi.X.(*ssa.Const); ok {
+		return false
+	}
+	switch t := mi.X.Type().Underlying().(type) {
+	case *types.Pointer, *types.Map, *types.Chan, *types.Signature:
+		return false
+	case *types.Basic:
+		if t.Kind() == types.UnsafePointer {
+			return false
+		}
+	}
+	return boxSizes.Sizeof(mi.X.Type()) > 1
+}
+
+// boxUse は mi を引数として受け取る関数の名前と呼び出しの位置を返す。可変長引数のスライスに入れている場合は
+// そのスライスを渡している呼び出しを探す。見つからなければ空を返す
+func boxUse(mi *ssa.MakeInterface) (string, token.Pos) {
+	for _, ref := range *mi.Referrers() {
+		switch ref := ref.(type) {
+		case ssa.CallInstruction:
+			return calleeName(ref.Common()), ref.Pos()
+		case *ssa.Store:
+			addr, ok := ref.Addr.(*ssa.IndexAddr)
+			if !ok {
+				continue
+			}
+			alloc, ok := addr.X.(*ssa.Alloc)
+			if !ok || alloc.Comment != "varargs" {
+				continue
+			}
+			for _, aref := range *alloc.Referrers() {
+				slice, ok := aref.(*ssa.Slice)
+				if !ok {
+					continue
+				}
+				for _, sref := range *slice.Referrers() {
+					if call, ok := sref.(ssa.CallInstruction); ok {
+						return calleeName(call.Common()), call.Pos()
+					}
+				}
+			}
+		}
+	}
+	return "", token.NoPos
+}
+
+func writeBoxingSites(w io.Writer, sites []BoxingSite) error {
+	for _, s := range sites {
+		use := ""
+		if s.Use != "" {
+			use = " for " + s.Use
+		}
+		loop := ""
+		if s.InLoop {
+			loop = "in loop, "
+		}
+		fmt.Fprintf(w, "%s: %s: %s boxed into %s%s (%sfan-in %d)\n", s.Pos, s.Func, s.Type, s.Iface, use, loop, s.FanIn)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestBoxingSites(t *testing.T) {
+	src := `package main
+
+import "fmt"
+
+type point struct{ x, y int }
+
+func show(names []string, p *point) {
+	for i, name := range names {
+		fmt.Println(i, name)
+		fmt.Println("const", p)
+	}
+}
+
+func describe(p point) string { return fmt.Sprint(p) }
+
+func a() string { return describe(point{}) }
+func b() string { return describe(point{1, 2}) }
+
+func flag(ok bool) { fmt.Println(ok) }
+
+func main() {
+	show(nil, nil)
+	_ = a() + b()
+	flag(true)
+}
+`
+	prog := loadTestProgram(t, map[string]string{"main.go": src})
+	var got []string
+	for _, s := range boxingSites(prog) {
+		got = append(got, fmt.Sprintf("%d: %s %s %s %s %v %d", s.Pos.Line, s.Func, s.Type, s.Iface, s.Use, s.InLoop, s.FanIn))
+	}
+	assertLines(t, got, []string{
+		"9: example.com/m.show int any fmt.Println true 1",
+		"9: example.com/m.show string any fmt.Println true 1",
+		"14: example.com/m.describe point any fmt.Sprint false 2",
+	})
+}
@@ -0,0 +1,516 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/types"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+// 標準ライブラリ (と vendor) の関数の扱い
+const (
+	stdKeep     = "keep"     // そのまま出力する
+	stdCollapse = "collapse" // パッケージ 1 つのノードにまとめる
+	stdExclude  = "exclude"  // 出力しない
+)
+
+// callGraphOptions は callEdges で出力するノードの選び方
+type callGraphOptions struct {
+	Std         string          // 標準ライブラリの関数の扱い (stdKeep, stdCollapse, stdExclude)
+	Proto       string          // protoc で生成されたメッセージの関数の扱い (空なら stdKeep と同じ)
+	Include     []string        // 空でなければ、いずれかに一致するパッケージの関数だけを残す
+	Exclude     []string        // 一致するパッケージの関数を除く
+	IncludeFunc *regexp.Regexp  // nil でなければ、名前が一致する関数だけを残す
+	ExcludeFunc *regexp.Regexp  // 名前が一致する関数を除く
+	Roots       []*ssa.Function // 空でなければ、これらの関数から到達できる関数だけを残す
+	Depth       int             // Roots からたどる呼び出しの深さ (負なら制限しない)
+	Annotate    bool            // ノードに panic しうるか、reflect で出力辺が不完全かの属性を付ける
+}
+
+// CallEdge はコールグラフの辺 (同じ関数間の複数の呼び出しは 1 つにまとめる)
+type CallEdge struct {
+	Caller   string `json:"caller"`
+	Callee   string `json:"callee"`
+	Assembly bool   `json:"assembly,omitempty"` // Callee は本体のない (アセンブリで実装された) 関数
+
+	// 以下は callGraphOptions.Annotate のときだけ付ける、両端のノードの属性
+	CallerMayPanic   bool `json:"callerMayPanic,omitempty"` // Caller は panic しうる関数を含む (mayPanic)
+	CalleeMayPanic   bool `json:"calleeMayPanic,omitempty"`
+	CallerReflection bool `json:"callerReflection,omitempty"` // Caller は reflect による動的な呼び出しを含み、出力辺が不完全 (reflectionTainted)
+	CalleeReflection bool `json:"calleeReflection,omitempty"`
+}
+
+func runCallGraph(args []string) error {
+	fs := flag.NewFlagSet("callgraph", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output as JSON")
+	format := fs.String("format", "text", "output format: text or dot")
+	std := fs.String("std", stdKeep, "how to show standard library and vendored functions: keep, collapse or exclude")
+	proto := fs.String("proto", stdKeep, "how to show protobuf generated getters and marshaling functions: keep, collapse (one node per message) or exclude")
+	include := fs.String("include", "", "comma-separated package globs (e.g. ./internal/...) whose functions are kept")
+	exclude := fs.String("exclude", "", "comma-separated package globs whose functions are removed")
+	includeFunc := fs.String("include-func", "", "keep only functions whose name matches the `regexp`")
+	excludeFunc := fs.String("exclude-func", "", "remove functions whose name matches the `regexp`")
+	root := fs.String("root", "", "show only functions reachable from the `function` (e.g. pkg.Func or (*pkg.T).Method)")
+	depth := fs.Int("depth", -1, "maximum call depth from -root (negative means unlimited)")
+	fs.Parse(args)
+	if *format != "text" && *format != "dot" {
+		return fmt.Errorf("invalid -format value %q (want text or dot)", *format)
+	}
+	if err := checkStdMode(*std); err != nil {
+		return err
+	}
+	if err := checkStdMode(*proto); err != nil {
+		return err
+	}
+	opts := callGraphOptions{Std: *std, Proto: *proto, Depth: *depth, Annotate: true}
+	var err error
+	if opts.IncludeFunc, err = compileOptional(*includeFunc); err != nil {
+		return err
+	}
+	if opts.ExcludeFunc, err = compileOptional(*excludeFunc); err != nil {
+		return err
+	}
+	prog, err := loadProgram(".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	if opts.Include, err = resolvePackagePatterns(prog, ".", splitList(*include)); err != nil {
+		return err
+	}
+	if opts.Exclude, err = resolvePackagePatterns(prog, ".", splitList(*exclude)); err != nil {
+		return err
+	}
+	if *root != "" {
+		if opts.Roots = findFunctions(prog, *root); len(opts.Roots) == 0 {
+			return notFound("function %s not found", *root)
+		}
+	}
+	edges := callEdges(prog, opts)
+	if *asJSON {
+		return writeJSON(os.Stdout, edges)
+	}
+	if *format == "dot" {
+		return writeCallGraphDOT(os.Stdout, edges)
+	}
+	return writeCallEdges(os.Stdout, edges)
+}
+
+func checkStdMode(mode string) error {
+	switch mode {
+	case stdKeep, stdCollapse, stdExclude:
+		return nil
+	}
+	return fmt.Errorf("invalid -std value %q (want keep, collapse or exclude)", mode)
+}
+
+// isStdPackage は path が標準ライブラリまたは vendor のパッケージかどうかを返す
+func isStdPackage(path string) bool {
+	return isGorootPackage(path) || strings.HasPrefix(path, "vendor/") || strings.Contains(path, "/vendor/")
+}
+
+// gorootPackages は isGorootPackage で調べた import path と結果
+var gorootPackages sync.Map
+
+// isGorootPackage は path が GOROOT の標準ライブラリのパッケージかどうかを返す。モジュールのパスは最初の要素に
+// ドットがなくてもよい (module myapp) ので、パスの形ではなく GOROOT/src にディレクトリがあるかで決める
+func isGorootPackage(path string) bool {
+	if path == "" || path == "C" {
+		return path == "C" // cgo の疑似パッケージ
+	}
+	if std, ok := gorootPackages.Load(path); ok {
+		return std.(bool)
+	}
+	info, err := os.Stat(filepath.Join(build.Default.GOROOT, "src", filepath.FromSlash(path)))
+	std := err == nil && info.IsDir()
+	gorootPackages.Store(path, std)
+	return std
+}
+
+func isStdFunc(fn *ssa.Function) bool {
+	pkg := ssaFuncPackage(fn)
+	return pkg != nil && isStdPackage(pkg.Path())
+}
+
+// ssaFuncPackage は fn が属するパッケージを返す。ジェネリクスのインスタンスやその無名関数は元の関数のパッケージ
+func ssaFuncPackage(fn *ssa.Function) *types.Package {
+	if fn.Pkg != nil {
+		return fn.Pkg.Pkg
+	}
+	if obj := fn.Object(); obj != nil {
+		return obj.Pkg()
+	}
+	if fn.Parent() != nil {
+		return ssaFuncPackage(fn.Parent())
+	}
+	return nil
+}
+
+// stdNodeName は std に従ってコールグラフのノードの名前を返す。除外する場合は ok が false
+func stdNodeName(fn *ssa.Function, std string) (name string, ok bool) {
+	if !isStdFunc(fn) {
+		return fn.RelString(nil), true
+	}
+	switch std {
+	case stdCollapse:
+		return ssaFuncPackage(fn).Path(), true
+	case stdExclude:
+		return "", false
+	}
+	return fn.RelString(nil), true
+}
+
+// nodeName は opts に従ってコールグラフのノードの名前を返す。除外する場合は ok が false。
+// protoc で生成されたメッセージの関数は、Proto が collapse ならメッセージごとに 1 つのノードにまとめる
+func (opts callGraphOptions) nodeName(prog *Program, fn *ssa.Function) (name string, ok bool) {
+	if opts.Proto == stdCollapse || opts.Proto == stdExclude {
+		if prog.isProtoFunc(fn) {
+			return protoNodeName(fn), opts.Proto == stdCollapse
+		}
+	}
+	return stdNodeName(fn, opts.Std)
+}
+
+// compileOptional は空でなければ expr をコンパイルする
+func compileOptional(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	return regexp.Compile(expr)
+}
+
+// resolvePackagePatterns は ./ や ../ で始まるパターンを dir からの相対パスとして import path に直す
+func resolvePackagePatterns(prog *Program, dir string, patterns []string) ([]string, error) {
+	var resolved []string
+	for _, pattern := range patterns {
+		if !strings.HasPrefix(pattern, "./") && !strings.HasPrefix(pattern, "../") && pattern != "." {
+			resolved = append(resolved, pattern)
+			continue
+		}
+		abs, err := filepath.Abs(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		importPath, ok := importPathOf(prog, filepath.ToSlash(abs))
+		if !ok {
+			return nil, fmt.Errorf("%s is not inside the main module", pattern)
+		}
+		resolved = append(resolved, importPath)
+	}
+	return resolved, nil
+}
+
+// importPathOf は解析対象のモジュールのディレクトリ dir (末尾の /... を含んでよい) を import path に直す
+func importPathOf(prog *Program, dir string) (string, bool) {
+	for _, pkg := range prog.Packages {
+		mod := pkg.Module
+		if mod == nil {
+			continue
+		}
+		root := filepath.ToSlash(mod.Dir)
+		if dir == root {
+			return mod.Path, true
+		}
+		if rest, ok := strings.CutPrefix(dir, root+"/"); ok {
+			if rest == "..." {
+				return mod.Path + "/...", true
+			}
+			return mod.Path + "/" + rest, true
+		}
+	}
+	return "", false
+}
+
+// matchPackageGlob は path が pattern に一致するかどうかを返す。
+// pattern は path.Match のワイルドカードを含んでよく、末尾が /... ならその下のパッケージにも一致する
+func matchPackageGlob(pattern, pkgPath string) bool {
+	if pattern == "..." {
+		return true
+	}
+	prefix, recursive := strings.CutSuffix(pattern, "/...")
+	for p := pkgPath; ; {
+		if ok, _ := path.Match(prefix, p); ok {
+			return true
+		}
+		i := strings.LastIndex(p, "/")
+		if !recursive || i < 0 {
+			return false
+		}
+		p = p[:i]
+	}
+}
+
+// keep は fn を opts の条件でコールグラフに残すかどうかを返す
+func (opts callGraphOptions) keep(fn *ssa.Function) bool {
+	pkgPath := ""
+	if pkg := ssaFuncPackage(fn); pkg != nil {
+		pkgPath = pkg.Path()
+	}
+	matchAny := func(patterns []string) bool {
+		for _, pattern := range patterns {
+			if matchPackageGlob(pattern, pkgPath) {
+				return true
+			}
+		}
+		return false
+	}
+	name := fn.RelString(nil)
+	switch {
+	case len(opts.Include) > 0 && !matchAny(opts.Include),
+		matchAny(opts.Exclude),
+		opts.IncludeFunc != nil && !opts.IncludeFunc.MatchString(name),
+		opts.ExcludeFunc != nil && opts.ExcludeFunc.MatchString(name):
+		return false
+	}
+	return true
+}
+
+// pkgPathPrefix は関数名のうち import path の最後の要素より前の部分
+var pkgPathPrefix = regexp.MustCompile(`(?:[\w.\-~]+/)+`)
+
+// findFunctions は name の関数を返す。name は RelString(nil) の形 (example.com/m/pkg.Func, (*example.com/m/pkg.T).Method) か、
+// import path をパッケージのディレクトリ名だけにした形 (pkg.Func, (*pkg.T).Method)
+func findFunctions(prog *Program, name string) []*ssa.Function {
+	var exact, short []*ssa.Function
+	for fn := range ssautil.AllFunctions(prog.SSA()) {
+		full := fn.RelString(nil)
+		switch {
+		case full == name:
+			exact = append(exact, fn)
+		case pkgPathPrefix.ReplaceAllString(full, "") == name:
+			short = append(short, fn)
+		}
+	}
+	if len(exact) > 0 {
+		return exact
+	}
+	sortFunctions(short)
+	return short
+}
+
+// callDepths は roots から呼び出しをたどって、depth 以内で到達できる関数とその深さを返す
+func callDepths(cg *callgraph.Graph, roots []*ssa.Function, depth int) map[*ssa.Function]int {
+	depths := make(map[*ssa.Function]int)
+	var queue []*ssa.Function
+	for _, fn := range roots {
+		if _, ok := depths[fn]; !ok {
+			depths[fn] = 0
+			queue = append(queue, fn)
+		}
+	}
+	for len(queue) > 0 {
+		fn := queue[0]
+		queue = queue[1:]
+		node := cg.Nodes[fn]
+		if node == nil || depth >= 0 && depths[fn] >= depth {
+			continue
+		}
+		for _, e := range node.Out {
+			if _, ok := depths[e.Callee.Func]; !ok {
+				depths[e.Callee.Func] = depths[fn] + 1
+				queue = append(queue, e.Callee.Func)
+			}
+		}
+	}
+	return depths
+}
+
+// callEdges は CHA のコールグラフのうち、両端の関数が opts の条件に合う辺を返す。
+// Roots を指定した場合は、Roots から Depth 以内でたどった辺だけを返す。
+// Std が collapse なら標準ライブラリの関数はパッケージにまとめ、標準ライブラリの中だけの辺は出力しない。
+// exclude なら標準ライブラリの関数を含む辺を出力しない。Proto も同じように protoc で生成されたメッセージの関数を扱い、
+// まとめた同じノードの中の辺は出力しない
+func callEdges(prog *Program, opts callGraphOptions) []CallEdge {
+	cg := prog.CallGraph()
+	var depths map[*ssa.Function]int
+	if len(opts.Roots) > 0 {
+		depths = callDepths(cg, opts.Roots, opts.Depth)
+	}
+	seen := make(map[CallEdge]bool)
+	var edges []CallEdge
+	for fn, node := range cg.Nodes {
+		if fn == nil || !opts.keep(fn) {
+			continue
+		}
+		if depths != nil {
+			if d, ok := depths[fn]; !ok || opts.Depth >= 0 && d >= opts.Depth {
+				continue
+			}
+		}
+		caller, ok := opts.nodeName(prog, fn)
+		if !ok {
+			continue
+		}
+		for _, e := range node.Out {
+			if !opts.keep(e.Callee.Func) {
+				continue
+			}
+			if _, ok := depths[e.Callee.Func]; depths != nil && !ok {
+				continue
+			}
+			callee, ok := opts.nodeName(prog, e.Callee.Func)
+			if !ok {
+				continue
+			}
+			if opts.Std == stdCollapse && isStdFunc(fn) && isStdFunc(e.Callee.Func) {
+				continue
+			}
+			if caller == callee && fn != e.Callee.Func {
+				continue
+			}
+			edge := CallEdge{Caller: caller, Callee: callee}
+			if isAssemblyFunc(e.Callee.Func) && callee == e.Callee.Func.RelString(nil) {
+				edge.Assembly = true
+			}
+			if !seen[edge] {
+				seen[edge] = true
+				edges = append(edges, edge)
+			}
+		}
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].Caller != edges[j].Caller {
+			return edges[i].Caller < edges[j].Caller
+		}
+		return edges[i].Callee < edges[j].Callee
+	})
+	if opts.Annotate {
+		annotateCallEdges(prog, opts, edges)
+	}
+	return edges
+}
+
+// annotateCallEdges は辺の両端のノードに、panic しうる関数と reflect で出力辺が不完全な関数を含むかどうかを付ける。
+// まとめたノード (collapse) は、まとめた関数のどれかに当てはまれば当てはまるとする
+func annotateCallEdges(prog *Program, opts callGraphOptions, edges []CallEdge) {
+	mayPanicNodes := make(map[string]bool)
+	for fn := range mayPanic(prog) {
+		if name, ok := opts.nodeName(prog, fn); ok {
+			mayPanicNodes[name] = true
+		}
+	}
+	tainted := reflectionTainted(reflectUses(prog))
+	reflectionNodes := make(map[string]bool)
+	for _, fn := range prog.targetFunctions() {
+		if !tainted[fn.RelString(nil)] {
+			continue
+		}
+		if name, ok := opts.nodeName(prog, fn); ok {
+			reflectionNodes[name] = true
+		}
+	}
+	for i := range edges {
+		edges[i].CallerMayPanic = mayPanicNodes[edges[i].Caller]
+		edges[i].CalleeMayPanic = mayPanicNodes[edges[i].Callee]
+		edges[i].CallerReflection = reflectionNodes[edges[i].Caller]
+		edges[i].CalleeReflection = reflectionNodes[edges[i].Callee]
+	}
+}
+
+// callerMarks と calleeMarks は、テキストと DOT で辺の両端のノードに付ける印を返す
+func (e CallEdge) callerMarks() []string {
+	var marks []string
+	if e.CallerMayPanic {
+		marks = append(marks, "may-panic")
+	}
+	if e.CallerReflection {
+		marks = append(marks, "reflection")
+	}
+	return marks
+}
+
+func (e CallEdge) calleeMarks() []string {
+	var marks []string
+	if e.Assembly {
+		marks = append(marks, "assembly")
+	}
+	if e.CalleeMayPanic {
+		marks = append(marks, "may-panic")
+	}
+	if e.CalleeReflection {
+		marks = append(marks, "reflection")
+	}
+	return marks
+}
+
+// isAssemblyFunc は fn が本体のない宣言かどうかを返す。.s ファイルのアセンブリで実装された関数
+// (と go:linkname で別の関数を参照する宣言) で、コールグラフでは呼び出し先のない葉になる
+func isAssemblyFunc(fn *ssa.Function) bool {
+	decl, ok := fn.Syntax().(*ast.FuncDecl)
+	return ok && decl.Body == nil
+}
+
+// writeCallEdges は辺を 1 行ずつ出力する。ノードの印は名前の後に [may-panic, reflection] のように付ける
+func writeCallEdges(w io.Writer, edges []CallEdge) error {
+	marked := func(name string, marks []string) string {
+		if len(marks) == 0 {
+			return name
+		}
+		return name + " [" + strings.Join(marks, ", ") + "]"
+	}
+	for _, e := range edges {
+		fmt.Fprintf(w, "%s --> %s\n", marked(e.Caller, e.callerMarks()), marked(e.Callee, e.calleeMarks()))
+	}
+	return nil
+}
+
+// writeCallGraphDOT はコールグラフを DOT で出力する。panic しうるノードは赤く、アセンブリの関数は破線に、
+// reflect で出力辺が不完全なノードは八角形にする
+func writeCallGraphDOT(w io.Writer, edges []CallEdge) error {
+	fmt.Fprintln(w, "digraph callgraph {")
+	fmt.Fprintln(w, "  rankdir=LR;")
+	fmt.Fprintln(w, "  node [shape=box];")
+	var nodes []string
+	marks := make(map[string][]string)
+	addNode := func(name string, ms []string) {
+		if _, ok := marks[name]; !ok {
+			nodes = append(nodes, name)
+			marks[name] = nil
+		}
+		for _, m := range ms {
+			if !slices.Contains(marks[name], m) {
+				marks[name] = append(marks[name], m)
+			}
+		}
+	}
+	for _, e := range edges {
+		addNode(e.Caller, e.callerMarks())
+		addNode(e.Callee, e.calleeMarks())
+	}
+	for _, name := range nodes {
+		var attrs []string
+		for _, m := range marks[name] {
+			switch m {
+			case "assembly":
+				attrs = append(attrs, "style=dashed")
+			case "may-panic":
+				attrs = append(attrs, "color=red")
+			case "reflection":
+				attrs = append(attrs, "shape=octagon")
+			}
+		}
+		if len(attrs) > 0 {
+			fmt.Fprintf(w, "  %q [%s];\n", name, strings.Join(attrs, ", "))
+		} else {
+			fmt.Fprintf(w, "  %q;\n", name)
+		}
+	}
+	for _, e := range edges {
+		fmt.Fprintf(w, "  %q -> %q;\n", e.Caller, e.Callee)
+	}
+	fmt.Fprintln(w, "}")
+	return nil
+}
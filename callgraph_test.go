@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var testdata_callgraph = `package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+func greet(name string) string {
+	return fmt.Sprintf("hello %s", strings.ToUpper(name))
+}
+
+func main() {
+	fmt.Println(greet("x"))
+}
+`
+
+func TestCallEdges(t *testing.T) {
+	prog := loadTestProgram(t, map[string]string{"main.go": testdata_callgraph})
+	edgeLines := func(std string) []string {
+		var lines []string
+		for _, e := range callEdges(prog, callGraphOptions{Std: std}) {
+			// 標準ライブラリの中の辺は多すぎるので解析対象からの辺だけを比べる
+			if strings.HasPrefix(e.Caller, "example.com/m") {
+				lines = append(lines, e.Caller+" --> "+e.Callee)
+			}
+		}
+		return lines
+	}
+	assertLines(t, edgeLines(stdKeep), []string{
+		"example.com/m.greet --> fmt.Sprintf",
+		"example.com/m.greet --> strings.ToUpper",
+		"example.com/m.init --> fmt.init",
+		"example.com/m.init --> strings.init",
+		"example.com/m.main --> example.com/m.greet",
+		"example.com/m.main --> fmt.Println",
+	})
+	assertLines(t, edgeLines(stdCollapse), []string{
+		"example.com/m.greet --> fmt",
+		"example.com/m.greet --> strings",
+		"example.com/m.init --> fmt",
+		"example.com/m.init --> strings",
+		"example.com/m.main --> example.com/m.greet",
+		"example.com/m.main --> fmt",
+	})
+	assertLines(t, edgeLines(stdExclude), []string{
+		"example.com/m.main --> example.com/m.greet",
+	})
+
+	// collapse では標準ライブラリの中だけの辺を出力しない
+	for _, e := range callEdges(prog, callGraphOptions{Std: stdCollapse}) {
+		if !strings.HasPrefix(e.Caller, "example.com/") && !strings.HasPrefix(e.Callee, "example.com/") {
+			t.Errorf("unexpected edge %s --> %s", e.Caller, e.Callee)
+		}
+	}
+	if got := len(callEdges(prog, callGraphOptions{Std: stdExclude})); got != 1 {
+		t.Errorf("-std=exclude: got %d edges, want 1", got)
+	}
+}
+
+func TestPurityStdMode(t *testing.T) {
+	prog := loadTestProgram(t, map[string]string{"main.go": testdata_callgraph})
+	reasons := func(std string) []string {
+		for _, p := range purityWith(prog, std) {
+			if p.Func == "example.com/m.main" {
+				return p.Reasons
+			}
+		}
+		return nil
+	}
+	assertLines(t, reasons(stdCollapse), []string{"calls greet", "calls fmt"})
+	assertLines(t, reasons(stdExclude), []string{"calls greet", "calls the standard library"})
+}
+
+func TestCallEdgesModulePath(t *testing.T) {
+	// 最初の要素にドットがないモジュールのパッケージも標準ライブラリとみなさない
+	prog := loadTestProgram(t, map[string]string{"go.mod": "module myapp\n\ngo 1.22\n", "main.go": testdata_callgraph})
+	var got []string
+	for _, e := range callEdges(prog, callGraphOptions{Std: stdExclude}) {
+		got = append(got, e.Caller+" --> "+e.Callee)
+	}
+	assertLines(t, got, []string{"myapp.main --> myapp.greet"})
+	if !isStdPackage("fmt") || !isStdPackage("example.com/m/vendor/x") || isStdPackage("myapp") {
+		t.Error("isStdPackage: want fmt and vendored packages but not myapp")
+	}
+}
+
+func TestCallEdgesFilter(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"main.go": `package main
+
+import (
+	"fmt"
+
+	"example.com/m/internal/store"
+	"example.com/m/internal/web"
+)
+
+func main() {
+	fmt.Println(web.Serve(store.Open()))
+}
+`,
+		"internal/web/web.go": `package web
+
+import "example.com/m/internal/store"
+
+func Serve(s *store.Store) string { return render(s.Get()) }
+
+func render(v string) string { return "<" + v + ">" }
+`,
+		"internal/store/store.go": `package store
+
+type Store struct{ v string }
+
+func Open() *Store { return &Store{v: "x"} }
+
+func (s *Store) Get() string { return s.v }
+`,
+	})
+	prog, err := loadProgram(dir, "./...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	edgeLines := func(opts callGraphOptions) []string {
+		var lines []string
+		for _, e := range callEdges(prog, opts) {
+			lines = append(lines, e.Caller+" --> "+e.Callee)
+		}
+		return lines
+	}
+
+	include, err := resolvePackagePatterns(prog, dir, []string{"./internal/..."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertLines(t, include, []string{"example.com/m/internal/..."})
+	assertLines(t, edgeLines(callGraphOptions{Std: stdKeep, Include: include}), []string{
+		"example.com/m/internal/web.Serve --> (*example.com/m/internal/store.Store).Get",
+		"example.com/m/internal/web.Serve --> example.com/m/internal/web.render",
+		"example.com/m/internal/web.init --> example.com/m/internal/store.init",
+	})
+	assertLines(t, edgeLines(callGraphOptions{Std: stdExclude, Exclude: []string{"example.com/m/*/store"}}), []string{
+		"example.com/m.init --> example.com/m/internal/web.init",
+		"example.com/m.main --> example.com/m/internal/web.Serve",
+		"example.com/m/internal/web.Serve --> example.com/m/internal/web.render",
+	})
+	assertLines(t, edgeLines(callGraphOptions{
+		Std:         stdExclude,
+		ExcludeFunc: regexp.MustCompile(`\.(render|init)$`),
+	}), []string{
+		"example.com/m.main --> example.com/m/internal/store.Open",
+		"example.com/m.main --> example.com/m/internal/web.Serve",
+		"example.com/m/internal/web.Serve --> (*example.com/m/internal/store.Store).Get",
+	})
+	assertLines(t, edgeLines(callGraphOptions{
+		Std:         stdCollapse,
+		IncludeFunc: regexp.MustCompile(`^example\.com/m\.main$|^fmt\.`),
+	}), []string{
+		"example.com/m.main --> fmt",
+	})
+
+	roots := findFunctions(prog, "m.main")
+	assertLines(t, edgeLines(callGraphOptions{Std: stdExclude, Roots: roots, Depth: 1}), []string{
+		"example.com/m.main --> example.com/m/internal/store.Open",
+		"example.com/m.main --> example.com/m/internal/web.Serve",
+	})
+	assertLines(t, edgeLines(callGraphOptions{Std: stdExclude, Roots: roots, Depth: -1}), []string{
+		"example.com/m.main --> example.com/m/internal/store.Open",
+		"example.com/m.main --> example.com/m/internal/web.Serve",
+		"example.com/m/internal/web.Serve --> (*example.com/m/internal/store.Store).Get",
+		"example.com/m/internal/web.Serve --> example.com/m/internal/web.render",
+	})
+	roots = findFunctions(prog, "(*store.Store).Get")
+	if len(roots) != 1 || roots[0].RelString(nil) != "(*example.com/m/internal/store.Store).Get" {
+		t.Errorf("findFunctions((*store.Store).Get) = %v", roots)
+	}
+	assertLines(t, edgeLines(callGraphOptions{Std: stdKeep, Roots: findFunctions(prog, "example.com/m/internal/web.Serve"), Depth: 1}), []string{
+		"example.com/m/internal/web.Serve --> (*example.com/m/internal/store.Store).Get",
+		"example.com/m/internal/web.Serve --> example.com/m/internal/web.render",
+	})
+}
+
+func TestAssemblyFuncs(t *testing.T) {
+	prog := loadTestProgram(t, map[string]string{
+		"main.go": `package main
+
+// add は add_amd64.s で実装する
+func add(a, b int) int
+
+// sub は add からだけ呼ばれる
+func sub(a, b int) int
+
+func main() { println(add(1, 2)) }
+`,
+		"add_amd64.s": `#include "textflag.h"
+
+TEXT ·add(SB), NOSPLIT, $0-24
+	CALL ·sub(SB)
+	RET
+
+TEXT ·sub(SB), NOSPLIT, $0-24
+	RET
+`,
+	})
+	var buf bytes.Buffer
+	if err := writeCallEdges(&buf, callEdges(prog, callGraphOptions{Std: stdExclude})); err != nil {
+		t.Fatal(err)
+	}
+	assertLines(t, strings.Split(strings.TrimSpace(buf.String()), "\n"), []string{
+		"example.com/m.main --> example.com/m.add [assembly]",
+	})
+	// アセンブリからだけ呼ばれる sub も到達できないとは指摘しない
+	if diags := deadCode(prog, allEntryKinds); len(diags) != 0 {
+		t.Errorf("got dead code %v", diagnosticMessages(diags))
+	}
+}
+
+func TestCallGraphAnnotate(t *testing.T) {
+	prog := loadTestProgram(t, map[string]string{
+		"main.go": `package main
+
+func div(a, b int) int { return a / b }
+
+func twice(a int) int { return a * 2 }
+
+func main() { println(div(twice(1), 3)) }
+`,
+	})
+	edges := callEdges(prog, callGraphOptions{Std: stdExclude, Annotate: true})
+	var buf bytes.Buffer
+	if err := writeCallEdges(&buf, edges); err != nil {
+		t.Fatal(err)
+	}
+	assertLines(t, strings.Split(strings.TrimSpace(buf.String()), "\n"), []string{
+		"example.com/m.main [may-panic] --> example.com/m.div [may-panic]",
+		"example.com/m.main [may-panic] --> example.com/m.twice",
+	})
+	buf.Reset()
+	if err := writeCallGraphDOT(&buf, edges); err != nil {
+		t.Fatal(err)
+	}
+	assertLines(t, strings.Split(strings.TrimSpace(buf.String()), "\n"), []string{
+		"digraph callgraph {",
+		"  rankdir=LR;",
+		"  node [shape=box];",
+		`  "example.com/m.main" [color=red];`,
+		`  "example.com/m.div" [color=red];`,
+		`  "example.com/m.twice";`,
+		`  "example.com/m.main" -> "example.com/m.div";`,
+		`  "example.com/m.main" -> "example.com/m.twice";`,
+		"}",
+	})
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/ssa"
+)
+
+// 呼び出しの種類
+const (
+	callFunc       = "func"       // パッケージの関数
+	callMethod     = "method"     // 具体的な型のメソッド
+	callInterface  = "interface"  // インターフェースのメソッド (動的ディスパッチ)
+	callMethodExpr = "methodexpr" // T.M(x) のようなメソッド式
+	callValue      = "value"      // 変数やフィールドの関数値、関数リテラル、関数を返す呼び出し
+	callBuiltin    = "builtin"    // len や append のような組み込み関数
+)
+
+// builtinModel は組み込み関数の呼び出しがすること
+type builtinModel struct {
+	Writes  bool   `json:"writes,omitempty"`  // 第 1 引数の指す先を書き換える
+	Allocs  bool   `json:"allocs,omitempty"`  // メモリを確保しうる
+	TypeArg bool   `json:"typearg,omitempty"` // 第 1 引数が型
+	Effect  string `json:"effect,omitempty"`  // 値の計算以外の作用 (io, close, panic, recover)
+}
+
+// builtinModels は組み込み関数 (unsafe のものを含む) の名前と、その呼び出しがすること
+var builtinModels = map[string]builtinModel{
+	"append":  {Allocs: true},
+	"cap":     {},
+	"clear":   {Writes: true},
+	"close":   {Effect: "close"},
+	"complex": {},
+	"copy":    {Writes: true},
+	"delete":  {Writes: true},
+	"imag":    {},
+	"len":     {},
+	"make":    {Allocs: true, TypeArg: true},
+	"max":     {},
+	"min":     {},
+	"new":     {Allocs: true, TypeArg: true},
+	"panic":   {Effect: "panic"},
+	"print":   {Effect: "io"},
+	"println": {Effect: "io"},
+	"real":    {},
+	"recover": {Effect: "recover"},
+
+	"Add":        {},
+	"Alignof":    {},
+	"Offsetof":   {},
+	"Sizeof":     {},
+	"Slice":      {},
+	"SliceData":  {},
+	"String":     {},
+	"StringData": {},
+}
+
+// CallSite は呼び出し 1 件。型変換は含まない
+type CallSite struct {
+	Pkg        *packages.Package `json:"-"`
+	Call       *ast.CallExpr     `json:"-"`
+	Caller     *types.Func       `json:"-"` // 呼び出しを含む関数宣言。パッケージ変数の初期化では nil
+	Callee     types.Object      `json:"-"` // *types.Func, *types.Builtin, 関数値の *types.Var。分からなければ nil
+	Recv       types.Type        `json:"-"` // メソッドとメソッド式のレシーバの型
+	Kind       string            `json:"kind"`
+	CallerName string            `json:"caller,omitempty"` // 無名関数の中では pkg.Func$1 のような SSA と同じ名前
+	CalleeName string            `json:"callee,omitempty"`
+	RecvType   string            `json:"recv,omitempty"`
+	Args       []string          `json:"args,omitempty"`
+	// ArgTypes は引数の型。可変長引数は []any に詰める前の型で、s... で渡した []T{a, b} のような
+	// スライスのリテラルは要素ごとの型になる。要素が分からないスライスを展開していればスライスの型のまま
+	ArgTypes []string       `json:"argtypes,omitempty"`
+	Spread   bool           `json:"spread,omitempty"` // s... で可変長引数にスライスを渡している
+	Pos      token.Position `json:"pos"`
+	// 組み込み関数の呼び出しでは、その作用と結果の型。make と new の型の引数は ArgTypes の先頭に入る
+	Builtin *builtinModel `json:"builtin,omitempty"`
+	Result  string        `json:"result,omitempty"`
+	// Program.CallSites が SSA で求めた関数値の呼び出し先。Kind が value のときだけ設定する
+	Resolved []*ssa.Function `json:"-"`
+	Targets  []string        `json:"targets,omitempty"`
+}
+
+// Func は呼び出される関数またはメソッドを返す。関数値や組み込み関数の呼び出しでは nil
+func (s *CallSite) Func() *types.Func {
+	fn, _ := s.Callee.(*types.Func)
+	return fn
+}
+
+func runCallSites(args []string) error {
+	fs := flag.NewFlagSet("callsites", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output as JSON")
+	fs.Parse(args)
+	prog, err := loadProgram(".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	sites := prog.CallSites()
+	relativizePositions(sites)
+	if *asJSON {
+		return writeJSON(os.Stdout, sites)
+	}
+	return writeCallSites(os.Stdout, sites)
+}
+
+// CallSites は pkgs の全ての呼び出しを出現順に集め、types.Info.Selections を使って呼び出しの種類と
+// レシーバの型を求める。呼び出しを調べるコマンドはファイルを走査し直さずにこの結果を使う
+func CallSites(pkgs []*packages.Package) []*CallSite {
+	var sites []*CallSite
+	for _, pkg := range pkgs {
+		names := newAnonNames(pkg)
+		inspector.New(pkg.Syntax).WithStack([]ast.Node{(*ast.CallExpr)(nil)}, func(n ast.Node, push bool, stack []ast.Node) bool {
+			if !push {
+				return true
+			}
+			call := n.(*ast.CallExpr)
+			site := classifyCall(pkg.TypesInfo, call)
+			if site == nil {
+				return true
+			}
+			site.Pkg, site.Call = pkg, call
+			if fd, ok := stack[1].(*ast.FuncDecl); ok {
+				site.Caller, _ = pkg.TypesInfo.Defs[fd.Name].(*types.Func)
+			}
+			if site.Caller != nil {
+				site.CallerName = site.Caller.FullName()
+			}
+			// 関数リテラルの中の呼び出しは、いちばん内側の無名関数 (pkg.Func$1) から呼んだことにする
+			for i := len(stack) - 2; i > 1; i-- {
+				if lit, ok := stack[i].(*ast.FuncLit); ok {
+					site.CallerName = names.funcName(lit)
+					break
+				}
+			}
+			switch callee := site.Callee.(type) {
+			case *types.Func:
+				site.CalleeName = callee.FullName()
+			case nil:
+			default:
+				site.CalleeName = callee.Name()
+			}
+			if site.Recv != nil {
+				site.RecvType = names.typeString(site.Recv)
+			}
+			for _, arg := range call.Args {
+				site.Args = append(site.Args, types.ExprString(arg))
+			}
+			site.Spread = call.Ellipsis.IsValid()
+			args, _ := spreadArgs(call)
+			for _, arg := range args {
+				if t := pkg.TypesInfo.TypeOf(arg); t != nil {
+					site.ArgTypes = append(site.ArgTypes, names.typeString(t))
+				}
+			}
+			if site.Kind == callBuiltin {
+				model := builtinModels[site.Callee.Name()]
+				site.Builtin = &model
+				if tv := pkg.TypesInfo.Types[call]; tv.Type != nil && !tv.IsVoid() {
+					site.Result = names.typeString(tv.Type)
+				}
+			}
+			site.Pos = pkg.Fset.Position(call.Pos())
+			sites = append(sites, site)
+			return true
+		})
+	}
+	return sites
+}
+
+// CallSites は CallSites(p.Packages) に加えて、f := pkg.Fn; f() や m := x.Method; m() のような
+// 関数値やメソッド値の呼び出し先を SSA で辿って Resolved と Targets に設定する。
+// 辿れるのはローカル変数 (φ を含む) とクロージャまでで、フィールドや引数に入った関数値は分からない
+func (p *Program) CallSites() []*CallSite {
+	sites := CallSites(p.Packages)
+	calls := make(map[token.Pos]*ssa.CallCommon)
+	for _, fn := range p.targetFunctions() {
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				if call, ok := instr.(ssa.CallInstruction); ok {
+					// go 文と defer 文の命令の Pos はキーワードの位置なので、命令ではなく CallCommon の Pos
+					// (呼び出し式の Lparen) で引く
+					calls[call.Common().Pos()] = call.Common()
+				}
+			}
+		}
+	}
+	for _, site := range sites {
+		if site.Kind != callValue {
+			continue
+		}
+		call, ok := calls[site.Call.Lparen]
+		if !ok || call.IsInvoke() {
+			continue
+		}
+		site.Resolved = funcValues(call.Value, make(map[ssa.Value]bool))
+		for _, fn := range site.Resolved {
+			if obj, ok := fn.Object().(*types.Func); ok {
+				site.Targets = append(site.Targets, obj.FullName())
+			} else {
+				site.Targets = append(site.Targets, fn.String())
+			}
+		}
+	}
+	return sites
+}
+
+// funcValues は関数値 v になりうる関数を返す。メソッド値は $bound のラッパーになる
+func funcValues(v ssa.Value, seen map[ssa.Value]bool) []*ssa.Function {
+	if seen[v] {
+		return nil
+	}
+	seen[v] = true
+	switch v := v.(type) {
+	case *ssa.Function:
+		return []*ssa.Function{v}
+	case *ssa.MakeClosure:
+		return funcValues(v.Fn, seen)
+	case *ssa.ChangeType:
+		return funcValues(v.X, seen)
+	case *ssa.Phi:
+		var fns []*ssa.Function
+		for _, e := range v.Edges {
+			for _, fn := range funcValues(e, seen) {
+				if !slices.Contains(fns, fn) {
+					fns = append(fns, fn)
+				}
+			}
+		}
+		return fns
+	}
+	return nil
+}
+
+// classifyCall は call の呼び出し先と種類を求める。型変換であれば nil を返す
+func classifyCall(info *types.Info, call *ast.CallExpr) *CallSite {
+	if tv, ok := info.Types[call.Fun]; ok && tv.IsType() {
+		return nil
+	}
+	fun := ast.Unparen(call.Fun)
+	// ジェネリック関数のインスタンス化 f[T](x)
+	switch ix := fun.(type) {
+	case *ast.IndexExpr:
+		if tv, ok := info.Types[ix.X]; ok && isFuncType(tv.Type) {
+			fun = ast.Unparen(ix.X)
+		}
+	case *ast.IndexListExpr:
+		fun = ast.Unparen(ix.X)
+	}
+	var id *ast.Ident
+	switch fun := fun.(type) {
+	case *ast.Ident:
+		id = fun
+	case *ast.SelectorExpr:
+		if sel, ok := info.Selections[fun]; ok {
+			switch sel.Kind() {
+			case types.MethodVal:
+				kind := callMethod
+				if types.IsInterface(sel.Recv()) {
+					kind = callInterface
+				}
+				return &CallSite{Kind: kind, Callee: sel.Obj(), Recv: sel.Recv()}
+			case types.MethodExpr:
+				return &CallSite{Kind: callMethodExpr, Callee: sel.Obj(), Recv: sel.Recv()}
+			default:
+				return &CallSite{Kind: callValue, Callee: sel.Obj()}
+			}
+		}
+		id = fun.Sel // パッケージ名で修飾された識別子
+	}
+	if id == nil {
+		return &CallSite{Kind: callValue}
+	}
+	switch obj := info.Uses[id].(type) {
+	case *types.Func:
+		return &CallSite{Kind: callFunc, Callee: obj}
+	case *types.Builtin:
+		return &CallSite{Kind: callBuiltin, Callee: obj}
+	case *types.Var:
+		return &CallSite{Kind: callValue, Callee: obj}
+	}
+	return &CallSite{Kind: callValue}
+}
+
+// spreadArgs は call の引数の式を返す。f(xs...) の xs が []T{a, b} のようなスライスのリテラルであれば
+// 要素に展開する。それ以外のスライスを展開していれば要素が分からないので、xs のまま ok を false で返す
+func spreadArgs(call *ast.CallExpr) (args []ast.Expr, ok bool) {
+	if !call.Ellipsis.IsValid() || len(call.Args) == 0 {
+		return call.Args, true
+	}
+	last := len(call.Args) - 1
+	lit, ok := ast.Unparen(call.Args[last]).(*ast.CompositeLit)
+	if !ok {
+		return call.Args, false
+	}
+	for _, elt := range lit.Elts {
+		if _, ok := elt.(*ast.KeyValueExpr); ok {
+			return call.Args, false
+		}
+	}
+	return append(slices.Clip(call.Args[:last]), lit.Elts...), true
+}
+
+// variadicValues は SSA の可変長引数の呼び出しで、スライスに詰められた値を interface への変換の前の値で返す。
+// 詰める前の値が分からない (s... で変数のスライスを渡している) ときは ok が false になる
+func variadicValues(call *ssa.CallCommon) (vals []ssa.Value, ok bool) {
+	if !call.Signature().Variadic() || len(call.Args) == 0 {
+		return nil, false
+	}
+	last := call.Args[len(call.Args)-1]
+	if c, ok := last.(*ssa.Const); ok && c.IsNil() {
+		return nil, true // 可変長引数がない
+	}
+	slice, ok := last.(*ssa.Slice)
+	if !ok {
+		return nil, false
+	}
+	alloc, ok := slice.X.(*ssa.Alloc)
+	if !ok {
+		return nil, false
+	}
+	array, ok := alloc.Type().(*types.Pointer).Elem().Underlying().(*types.Array)
+	if !ok {
+		return nil, false
+	}
+	vals = make([]ssa.Value, array.Len())
+	for _, ref := range *alloc.Referrers() {
+		addr, ok := ref.(*ssa.IndexAddr)
+		if !ok {
+			continue
+		}
+		idx, ok := addr.Index.(*ssa.Const)
+		if !ok {
+			return nil, false
+		}
+		for _, ref := range *addr.Referrers() {
+			if store, ok := ref.(*ssa.Store); ok && store.Addr == addr {
+				v := store.Val
+				if mi, ok := v.(*ssa.MakeInterface); ok {
+					v = mi.X
+				}
+				vals[idx.Int64()] = v
+			}
+		}
+	}
+	if slices.Contains(vals, nil) {
+		return nil, false
+	}
+	return vals, true
+}
+
+// isFuncType は t が関数型 (型パラメータを持つ関数を含む) かどうかを返す
+func isFuncType(t types.Type) bool {
+	_, ok := t.Underlying().(*types.Signature)
+	return ok
+}
+
+func writeCallSites(w io.Writer, sites []*CallSite) error {
+	for _, s := range sites {
+		callee := s.CalleeName
+		if callee == "" {
+			callee = types.ExprString(s.Call.Fun)
+		}
+		if len(s.Targets) > 0 {
+			callee += " => " + strings.Join(s.Targets, ", ")
+		}
+		if s.Builtin != nil {
+			callee += "(" + strings.Join(s.ArgTypes, ", ") + ")"
+			if s.Result != "" {
+				callee += " " + s.Result
+			}
+		}
+		if s.RecvType != "" {
+			fmt.Fprintf(w, "%s: %s -> %s [%s %s]\n", s.Pos, s.CallerName, callee, s.Kind, s.RecvType)
+		} else {
+			fmt.Fprintf(w, "%s: %s -> %s [%s]\n", s.Pos, s.CallerName, callee, s.Kind)
+		}
+	}
+	return nil
+}
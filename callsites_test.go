@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+func TestCallSites(t *testing.T) {
+	prog := loadTestProgram(t, map[string]string{"main.go": `package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+type T struct{ f func() }
+
+func (t *T) M(n int) {}
+
+func Map[E any](s []E, f func(E) E) []E { return s }
+
+var w io.Writer = os.Stdout
+
+func main() {
+	t := &T{}
+	t.M(1)
+	(*T).M(t, 2)
+	t.f()
+	w.Write(nil)
+	fmt.Println(len("x"))
+	g := func() {}
+	g()
+	_ = int64(3)
+	Map([]int{1}, func(n int) int { return n })
+	Map[string](nil, nil)
+}
+`})
+	var buf bytes.Buffer
+	sites := CallSites(prog.Packages)
+	for _, s := range sites {
+		s.Pos.Filename = filepath.Base(s.Pos.Filename)
+	}
+	if err := writeCallSites(&buf, sites); err != nil {
+		t.Fatal(err)
+	}
+	assertLines(t, strings.Split(strings.TrimSpace(buf.String()), "\n"), []string{
+		"main.go:19:2: example.com/m.main -> (*example.com/m.T).M [method *example.com/m.T]",
+		"main.go:20:2: example.com/m.main -> (*example.com/m.T).M [methodexpr *example.com/m.T]",
+		"main.go:21:2: example.com/m.main -> f [value]",
+		"main.go:22:2: example.com/m.main -> (io.Writer).Write [interface io.Writer]",
+		"main.go:23:2: example.com/m.main -> fmt.Println [func]",
+		"main.go:23:14: example.com/m.main -> len(untyped string) int [builtin]",
+		"main.go:25:2: example.com/m.main -> g [value]",
+		"main.go:27:2: example.com/m.main -> example.com/m.Map [func]",
+		"main.go:28:2: example.com/m.main -> example.com/m.Map [func]",
+	})
+}
+
+func TestCallSitesFuncValues(t *testing.T) {
+	prog := loadTestProgram(t, map[string]string{"main.go": `package main
+
+import "strings"
+
+type T struct{ n int }
+
+func (t T) Show() {}
+
+func apply(f func(string) string) string { return f("x") }
+
+func main() {
+	f := strings.ToUpper
+	f("a")
+	m := T{}.Show
+	m()
+	g := strings.ToLower
+	if len("ab") > 1 {
+		g = strings.TrimSpace
+	}
+	g("b")
+	h := func() {}
+	h()
+	d := strings.TrimSpace
+	defer d("c")
+	k := T{}.Show
+	go k()
+	apply(strings.ToUpper)
+}
+`})
+	var got []string
+	for _, s := range prog.CallSites() {
+		if s.Kind == callValue {
+			got = append(got, fmt.Sprintf("%d: %s => %s", s.Pos.Line, s.CalleeName, strings.Join(s.Targets, ", ")))
+		}
+	}
+	// apply の引数 f は呼び出し元が分からないので辿らない
+	assertLines(t, got, []string{
+		"9: f => ",
+		"13: f => strings.ToUpper",
+		"15: m => (example.com/m.T).Show",
+		"20: g => strings.ToLower, strings.TrimSpace",
+		"22: h => example.com/m.main$1",
+		"24: d => strings.TrimSpace",
+		"26: k => (example.com/m.T).Show",
+	})
+}
+
+func TestCallSitesArgTypes(t *testing.T) {
+	prog := loadTestProgram(t, map[string]string{"main.go": `package main
+
+import "fmt"
+
+func main() {
+	n, s := 1, "x"
+	fmt.Println(n, s)
+	fmt.Println([]any{s, 2.5}...)
+	xs := []any{n}
+	fmt.Println(xs...)
+	fmt.Println()
+}
+`})
+	var got []string
+	for _, s := range CallSites(prog.Packages) {
+		got = append(got, fmt.Sprintf("%d: spread=%t %s", s.Pos.Line, s.Spread, strings.Join(s.ArgTypes, ", ")))
+	}
+	assertLines(t, got, []string{
+		"7: spread=false int, string",
+		"8: spread=true string, float64",
+		"10: spread=true []any",
+		"11: spread=false ",
+	})
+
+	// SSA では []any に詰める前の値を取り出す。ローカル変数のスライスもリテラルまで辿れる
+	var vals []string
+	for _, fn := range prog.targetFunctions() {
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				call, ok := instr.(ssa.CallInstruction)
+				if !ok || call.Common().StaticCallee() == nil || call.Common().StaticCallee().Name() != "Println" {
+					continue
+				}
+				args, ok := variadicValues(call.Common())
+				var typs []string
+				for _, a := range args {
+					typs = append(typs, a.Type().String())
+				}
+				vals = append(vals, fmt.Sprintf("%d: ok=%t %s", prog.Fset.Position(call.Pos()).Line, ok, strings.Join(typs, ", ")))
+			}
+		}
+	}
+	assertLines(t, vals, []string{
+		"7: ok=true int, string",
+		"8: ok=true string, float64",
+		"10: ok=true int",
+		"11: ok=true ",
+	})
+}
+
+func TestCallSitesBuiltins(t *testing.T) {
+	prog := loadTestProgram(t, map[string]string{"main.go": `package main
+
+func main() {
+	s := make([]int, 0, 4)
+	s = append(s, 1, 2)
+	m := make(map[string]bool)
+	delete(m, "k")
+	p := new(int)
+	n := copy(s, []int{*p})
+	println(cap(s), n)
+}
+`})
+	var got []string
+	for _, s := range CallSites(prog.Packages) {
+		got = append(got, fmt.Sprintf("%s(%s) %s %+v", s.CalleeName, strings.Join(s.ArgTypes, ", "), s.Result, *s.Builtin))
+	}
+	assertLines(t, got, []string{
+		"make([]int, int, int) []int {Writes:false Allocs:true TypeArg:true Effect:}",
+		"append([]int, int, int) []int {Writes:false Allocs:true TypeArg:false Effect:}",
+		"make(map[string]bool) map[string]bool {Writes:false Allocs:true TypeArg:true Effect:}",
+		"delete(map[string]bool, string)  {Writes:true Allocs:false TypeArg:false Effect:}",
+		"new(int) *int {Writes:false Allocs:true TypeArg:true Effect:}",
+		"copy([]int, []int) int {Writes:true Allocs:false TypeArg:false Effect:}",
+		"println(int, int)  {Writes:false Allocs:false TypeArg:false Effect:io}",
+		"cap([]int) int {Writes:false Allocs:false TypeArg:false Effect:}",
+	})
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// ChangeSet はファイル (絶対パス) ごとの変更された行の範囲
+type ChangeSet map[string][]lineRange
+
+// lineRange は Start 行から End 行まで (両端を含む)
+type lineRange struct {
+	Start, End int
+}
+
+// changeOptions は main の -changed-only、-diff、-base の指定
+type changeOptions struct {
+	Enabled bool
+	Diff    string // unified diff のファイル ("-" なら標準入力)。空なら git diff を使う
+	Base    string // git diff の比較元のコミット
+}
+
+// defaultChanges が有効なら loadProgramWith は変更を読み込み、指摘とメトリクスを変更された関数とその呼び出し元に絞る
+var defaultChanges changeOptions
+
+var hunkHeader = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,(\d+))? @@`)
+
+// loadChanges は opts.Diff の diff か、opts.Base と ref (空なら作業ツリー) の git diff から変更された行を読む
+func loadChanges(dir, ref string, opts changeOptions) (ChangeSet, error) {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if out, err := git(dir, "rev-parse", "--show-toplevel"); err == nil {
+		root = strings.TrimSpace(string(out))
+	}
+	if opts.Diff != "" {
+		var data []byte
+		if opts.Diff == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(opts.Diff)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return parseUnifiedDiff(bytes.NewReader(data), root)
+	}
+	base := opts.Base
+	if base == "" {
+		base = "HEAD"
+	}
+	args := []string{"diff", "--no-color", "--no-ext-diff", "-U0", base}
+	if ref != "" {
+		args = append(args, ref)
+	}
+	out, err := git(root, args...)
+	if err != nil {
+		return nil, err
+	}
+	return parseUnifiedDiff(bytes.NewReader(out), root)
+}
+
+// parseUnifiedDiff は unified diff の変更後のファイルで追加・変更された行を返す。
+// 削除だけの箇所は、削除された位置の次の行を変更されたものとする。パスは root からの相対パスとして扱う
+func parseUnifiedDiff(r io.Reader, root string) (ChangeSet, error) {
+	changes := make(ChangeSet)
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	var file string
+	line := 0
+	mark := func(n int) {
+		if file == "" {
+			return
+		}
+		ranges := changes[file]
+		if k := len(ranges); k > 0 && ranges[k-1].End >= n-1 {
+			ranges[k-1].End = max(ranges[k-1].End, n)
+			return
+		}
+		changes[file] = append(ranges, lineRange{n, n})
+	}
+	for sc.Scan() {
+		text := sc.Text()
+		switch {
+		case strings.HasPrefix(text, "+++ "):
+			name := strings.TrimPrefix(text, "+++ ")
+			if i := strings.IndexByte(name, '\t'); i >= 0 {
+				name = name[:i]
+			}
+			file = ""
+			if name != "/dev/null" {
+				file = filepath.Join(root, filepath.FromSlash(strings.TrimPrefix(name, "b/")))
+			}
+		case strings.HasPrefix(text, "--- "):
+		case strings.HasPrefix(text, "@@"):
+			m := hunkHeader.FindStringSubmatch(text)
+			if m == nil {
+				return nil, fmt.Errorf("malformed hunk header %q", text)
+			}
+			line, _ = strconv.Atoi(m[1])
+			if m[2] == "0" {
+				line++ // 削除だけのハンクでは +n,0 の n は削除された位置の前の行
+			}
+		case strings.HasPrefix(text, "+"):
+			mark(line)
+			line++
+		case strings.HasPrefix(text, "-"):
+			mark(line)
+		case strings.HasPrefix(text, " "):
+			line++
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// overlaps は filename の start 行から end 行までに変更された行があるかどうかを返す
+func (c ChangeSet) overlaps(filename string, start, end int) bool {
+	for _, r := range c[filename] {
+		if r.Start <= end && start <= r.End {
+			return true
+		}
+	}
+	return false
+}
+
+// funcLines は fn の宣言のファイル名と行の範囲を返す。ソースのない関数は ok が false
+func funcLines(fset *token.FileSet, fn *ssa.Function) (filename string, lines lineRange, ok bool) {
+	syntax := fn.Syntax()
+	if syntax == nil {
+		return "", lineRange{}, false
+	}
+	start, end := fset.Position(syntax.Pos()), fset.Position(syntax.End())
+	return start.Filename, lineRange{start.Line, end.Line}, true
+}
+
+// changedFunctions は宣言の行の範囲に変更がある解析対象の関数 (無名関数を除く) を返す
+func changedFunctions(prog *Program, changes ChangeSet) []*ssa.Function {
+	var fns []*ssa.Function
+	for _, fn := range prog.targetFunctions() {
+		if fn.Parent() != nil || fn.Synthetic != "" {
+			continue
+		}
+		if name, lines, ok := funcLines(prog.Fset, fn); ok && changes.overlaps(name, lines.Start, lines.End) {
+			fns = append(fns, fn)
+		}
+	}
+	return fns
+}
+
+// focusFunctions は -changed-only のときに対象にする関数 (変更された関数とその直接の呼び出し元) を返す。
+// -changed-only でなければ nil
+func (p *Program) focusFunctions() map[*ssa.Function]bool {
+	if p.changes == nil {
+		return nil
+	}
+	p.focusOnce.Do(func() {
+		p.focus = make(map[*ssa.Function]bool)
+		cg := p.CallGraph()
+		for _, fn := range changedFunctions(p, p.changes) {
+			p.focus[fn] = true
+			if node := cg.Nodes[fn]; node != nil {
+				for _, e := range node.In {
+					if caller := e.Caller.Func; caller.Pkg != nil && p.isTarget(caller.Pkg.Pkg) {
+						for caller.Parent() != nil {
+							caller = caller.Parent()
+						}
+						p.focus[caller] = true
+					}
+				}
+			}
+		}
+	})
+	return p.focus
+}
+
+// inFocus は pos が -changed-only で対象にする関数の中にあるかどうかを返す。-changed-only でなければ常に true
+func (p *Program) inFocus(pos token.Position) bool {
+	focus := p.focusFunctions()
+	if focus == nil {
+		return true
+	}
+	for fn := range focus {
+		if name, lines, ok := funcLines(p.Fset, fn); ok && name == pos.Filename && lines.Start <= pos.Line && pos.Line <= lines.End {
+			return true
+		}
+	}
+	return false
+}
+
+// filterFocus は -changed-only で対象にする関数の中の指摘だけを返す
+func filterFocus(prog *Program, diags []Diagnostic) []Diagnostic {
+	if prog.changes == nil {
+		return diags
+	}
+	var result []Diagnostic
+	for _, d := range diags {
+		if prog.inFocus(d.Pos) {
+			result = append(result, d)
+		}
+	}
+	return result
+}
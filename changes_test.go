@@ -0,0 +1,99 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParseUnifiedDiff(t *testing.T) {
+	diff := `diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -3,0 +4,2 @@ func a() {
++	x := 1
++	println(x)
+@@ -10,2 +12 @@ func b() {
+-	old()
+-	old()
++	new()
+@@ -20,1 +20,0 @@ func c() {
+-	gone()
+diff --git a/removed.go b/removed.go
+--- a/removed.go
++++ /dev/null
+@@ -1,3 +0,0 @@
+-package main
+-
+-func removed() {}
+`
+	changes, err := parseUnifiedDiff(strings.NewReader(diff), "/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 {
+		t.Errorf("got changes for %d files, want 1: %v", len(changes), changes)
+	}
+	got := changes["/repo/main.go"]
+	want := []lineRange{{4, 5}, {12, 12}, {21, 21}}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+}
+
+var testdata_changes = `package main
+
+func leaf() int {
+	return 1
+}
+
+func middle() int {
+	return leaf() + 1
+}
+
+func top() int {
+	return middle() * 2
+}
+
+func other() {
+	var unusedVar int
+	_ = unusedVar
+}
+
+func main() {
+	println(top())
+	other()
+}
+`
+
+func TestChangedOnly(t *testing.T) {
+	prog := loadTestProgram(t, map[string]string{"main.go": testdata_changes})
+	file := prog.Packages[0].GoFiles[0]
+	prog.changes = ChangeSet{file: {{8, 8}}} // middle の本体
+
+	var got []string
+	for fn := range prog.focusFunctions() {
+		got = append(got, fn.Name())
+	}
+	sort.Strings(got)
+	assertLines(t, got, []string{"middle", "top"})
+
+	diags := []Diagnostic{
+		newDiagnostic(prog.Fset, prog.Packages[0].Syntax[0].Decls[0].Pos(), "test", "in leaf"),
+		newDiagnostic(prog.Fset, prog.Packages[0].Syntax[0].Decls[1].Pos(), "test", "in middle"),
+		newDiagnostic(prog.Fset, prog.Packages[0].Syntax[0].Decls[2].Pos(), "test", "in top"),
+		newDiagnostic(prog.Fset, prog.Packages[0].Syntax[0].Decls[3].Pos(), "test", "in other"),
+	}
+	assertLines(t, diagnosticMessages(filterFocus(prog, diags)), []string{
+		"main.go:7: in middle",
+		"main.go:11: in top",
+	})
+	if m := packageMetrics(prog); m[0].Funcs != 2 {
+		t.Errorf("got %d funcs in metrics, want 2", m[0].Funcs)
+	}
+}
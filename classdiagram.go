@@ -0,0 +1,300 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// クラス図の関係の種類
+const (
+	relEmbeds      = "embeds"      // 構造体・インターフェースへの埋め込み
+	relImplements  = "implements"  // インターフェースの実装
+	relAssociation = "association" // フィールドの型
+)
+
+// ClassNode はクラス図の型
+type ClassNode struct {
+	Name    string   `json:"name"` // パッケージ名で修飾した型名
+	Kind    string   `json:"kind"` // struct, interface, または other
+	Fields  []string `json:"fields,omitempty"`
+	Methods []string `json:"methods,omitempty"`
+}
+
+// ClassRelation はクラス図の型の間の関係
+type ClassRelation struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Kind  string `json:"kind"`
+	Label string `json:"label,omitempty"` // 関連ではフィールド名
+}
+
+// ClassDiagram はクラス図の型と関係
+type ClassDiagram struct {
+	Classes   []ClassNode     `json:"classes"`
+	Relations []ClassRelation `json:"relations"`
+}
+
+func runClassDiagram(args []string) error {
+	fs := flag.NewFlagSet("classdiagram", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output as JSON")
+	format := fs.String("format", "mermaid", "diagram format: mermaid or plantuml")
+	fs.Parse(args)
+	if *format != "mermaid" && *format != "plantuml" {
+		return fmt.Errorf("invalid -format value %q (want mermaid or plantuml)", *format)
+	}
+	prog, err := loadProgram(".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	diagram := classDiagram(prog.Packages)
+	if *asJSON {
+		return writeJSON(os.Stdout, diagram)
+	}
+	if *format == "plantuml" {
+		return writePlantUMLClasses(os.Stdout, diagram)
+	}
+	return writeMermaidClasses(os.Stdout, diagram)
+}
+
+// classDiagram は解析対象のパッケージで宣言された構造体とインターフェースと、埋め込み、インターフェースの実装、
+// フィールドの型による関連を返す。埋め込まれた他のパッケージの型も (メンバーなしで) 含める
+func classDiagram(pkgs []*packages.Package) *ClassDiagram {
+	var named []*types.Named
+	for _, pkg := range pkgs {
+		scope := pkg.Types.Scope()
+		for _, name := range scope.Names() {
+			if tn, ok := scope.Lookup(name).(*types.TypeName); ok && !tn.IsAlias() {
+				if t, ok := tn.Type().(*types.Named); ok && classKind(t) != "other" {
+					named = append(named, t)
+				}
+			}
+		}
+	}
+	declared := make(map[*types.TypeName]bool)
+	for _, t := range named {
+		declared[t.Obj()] = true
+	}
+
+	d := &ClassDiagram{}
+	classes := make(map[string]bool)
+	addClass := func(node ClassNode) {
+		if !classes[node.Name] {
+			classes[node.Name] = true
+			d.Classes = append(d.Classes, node)
+		}
+	}
+	for _, t := range named {
+		addClass(classNode(t))
+	}
+	for _, t := range named {
+		from := className(t)
+		switch u := t.Underlying().(type) {
+		case *types.Struct:
+			for i := 0; i < u.NumFields(); i++ {
+				f := u.Field(i)
+				target := fieldNamed(f.Type())
+				if target == nil {
+					continue
+				}
+				switch {
+				case f.Embedded():
+					if !declared[target.Obj()] {
+						addClass(ClassNode{Name: className(target), Kind: classKind(target)})
+					}
+					d.Relations = append(d.Relations, ClassRelation{From: from, To: className(target), Kind: relEmbeds})
+				case declared[target.Obj()]:
+					d.Relations = append(d.Relations, ClassRelation{From: from, To: className(target), Kind: relAssociation, Label: f.Name()})
+				}
+			}
+		case *types.Interface:
+			for i := 0; i < u.NumEmbeddeds(); i++ {
+				if target, ok := u.EmbeddedType(i).(*types.Named); ok {
+					if !declared[target.Obj()] {
+						addClass(ClassNode{Name: className(target), Kind: classKind(target)})
+					}
+					d.Relations = append(d.Relations, ClassRelation{From: from, To: className(target), Kind: relEmbeds})
+				}
+			}
+		}
+	}
+	for _, iface := range named {
+		it, ok := iface.Underlying().(*types.Interface)
+		if !ok || it.NumMethods() == 0 {
+			continue
+		}
+		for _, t := range named {
+			if types.IsInterface(t) || t.TypeParams().Len() > 0 {
+				continue
+			}
+			if types.Implements(t, it) || types.Implements(types.NewPointer(t), it) {
+				d.Relations = append(d.Relations, ClassRelation{From: className(t), To: className(iface), Kind: relImplements})
+			}
+		}
+	}
+	sort.Slice(d.Classes, func(i, j int) bool { return d.Classes[i].Name < d.Classes[j].Name })
+	sort.SliceStable(d.Relations, func(i, j int) bool {
+		a, b := d.Relations[i], d.Relations[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.To < b.To
+	})
+	return d
+}
+
+// className はパッケージ名で修飾した型名を返す
+func className(t *types.Named) string {
+	return types.TypeString(t.Origin(), (*types.Package).Name)
+}
+
+func classKind(t *types.Named) string {
+	switch t.Underlying().(type) {
+	case *types.Struct:
+		return "struct"
+	case *types.Interface:
+		return "interface"
+	}
+	return "other"
+}
+
+// classNode は t のフィールドと、t で宣言されたメソッド (インターフェースではメソッド) を返す
+func classNode(t *types.Named) ClassNode {
+	qual := (*types.Package).Name
+	node := ClassNode{Name: className(t), Kind: classKind(t)}
+	switch u := t.Underlying().(type) {
+	case *types.Struct:
+		for i := 0; i < u.NumFields(); i++ {
+			if f := u.Field(i); !f.Embedded() {
+				node.Fields = append(node.Fields, f.Name()+" "+types.TypeString(f.Type(), qual))
+			}
+		}
+	case *types.Interface:
+		for i := 0; i < u.NumExplicitMethods(); i++ {
+			m := u.ExplicitMethod(i)
+			node.Methods = append(node.Methods, methodString(m, qual))
+		}
+		return node
+	}
+	for i := 0; i < t.NumMethods(); i++ {
+		node.Methods = append(node.Methods, methodString(t.Method(i), qual))
+	}
+	return node
+}
+
+// methodString はメソッドを Name(params) results の形にする
+func methodString(m *types.Func, qual types.Qualifier) string {
+	return m.Name() + strings.TrimPrefix(types.TypeString(m.Type(), qual), "func")
+}
+
+// fieldNamed はフィールドの型からポインタ、スライス、配列、マップの値、チャネルを外した名前付きの型を返す
+func fieldNamed(t types.Type) *types.Named {
+	for {
+		switch u := t.(type) {
+		case *types.Named:
+			return u
+		case *types.Pointer:
+			t = u.Elem()
+		case *types.Slice:
+			t = u.Elem()
+		case *types.Array:
+			t = u.Elem()
+		case *types.Map:
+			t = u.Elem()
+		case *types.Chan:
+			t = u.Elem()
+		default:
+			return nil
+		}
+	}
+}
+
+// memberVisibility は UML の可視性の記号を返す
+func memberVisibility(member string) string {
+	name := member
+	if i := strings.IndexAny(member, " ("); i >= 0 {
+		name = member[:i]
+	}
+	if token.IsExported(name) {
+		return "+"
+	}
+	return "-"
+}
+
+// classIDs は図の中で使う型の識別子 (C0, C1, ...) を返す
+func classIDs(d *ClassDiagram) map[string]string {
+	ids := make(map[string]string)
+	for i, c := range d.Classes {
+		ids[c.Name] = fmt.Sprintf("C%d", i)
+	}
+	return ids
+}
+
+func writeMermaidClasses(w io.Writer, d *ClassDiagram) error {
+	ids := classIDs(d)
+	fmt.Fprintln(w, "classDiagram")
+	for _, c := range d.Classes {
+		fmt.Fprintf(w, "    class %s[\"%s\"]\n", ids[c.Name], c.Name)
+		if c.Kind == "interface" {
+			fmt.Fprintf(w, "    <<interface>> %s\n", ids[c.Name])
+		}
+		for _, f := range c.Fields {
+			fmt.Fprintf(w, "    %s : %s%s\n", ids[c.Name], memberVisibility(f), f)
+		}
+		for _, m := range c.Methods {
+			fmt.Fprintf(w, "    %s : %s%s\n", ids[c.Name], memberVisibility(m), m)
+		}
+	}
+	for _, r := range d.Relations {
+		switch r.Kind {
+		case relEmbeds:
+			fmt.Fprintf(w, "    %s *-- %s : embeds\n", ids[r.From], ids[r.To])
+		case relImplements:
+			fmt.Fprintf(w, "    %s <|.. %s\n", ids[r.To], ids[r.From])
+		case relAssociation:
+			fmt.Fprintf(w, "    %s --> %s : %s\n", ids[r.From], ids[r.To], r.Label)
+		}
+	}
+	return nil
+}
+
+func writePlantUMLClasses(w io.Writer, d *ClassDiagram) error {
+	ids := classIDs(d)
+	fmt.Fprintln(w, "@startuml")
+	for _, c := range d.Classes {
+		keyword := "class"
+		if c.Kind == "interface" {
+			keyword = "interface"
+		}
+		fmt.Fprintf(w, "%s %q as %s {\n", keyword, c.Name, ids[c.Name])
+		for _, f := range c.Fields {
+			fmt.Fprintf(w, "  %s%s\n", memberVisibility(f), f)
+		}
+		for _, m := range c.Methods {
+			fmt.Fprintf(w, "  %s%s\n", memberVisibility(m), m)
+		}
+		fmt.Fprintln(w, "}")
+	}
+	for _, r := range d.Relations {
+		switch r.Kind {
+		case relEmbeds:
+			fmt.Fprintf(w, "%s *-- %s : embeds\n", ids[r.From], ids[r.To])
+		case relImplements:
+			fmt.Fprintf(w, "%s <|.. %s\n", ids[r.To], ids[r.From])
+		case relAssociation:
+			fmt.Fprintf(w, "%s --> %s : %s\n", ids[r.From], ids[r.To], r.Label)
+		}
+	}
+	fmt.Fprintln(w, "@enduml")
+	return nil
+}
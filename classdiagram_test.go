@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var testdata_classdiagram = map[string]string{
+	"go.mod": `module example.com/m
+
+go 1.22
+
+require example v0.0.0
+
+replace example => ./third_party/example
+`,
+	"third_party/example/go.mod": "module example\n\ngo 1.22\n",
+	"third_party/example/example.go": `package example
+
+type MyStructB struct {
+	Field1 int
+}
+`,
+	"main.go": `package main
+
+import (
+	"fmt"
+
+	"example"
+)
+
+type Shape interface {
+	fmt.Stringer
+	Area() float64
+}
+
+type MyStructA struct {
+	example.MyStructB
+	name  string
+	Items []*MyStructC
+}
+
+func (ms MyStructA) Method1() int { return ms.Field1 }
+
+type MyStructC struct {
+	w, h float64
+}
+
+func (c *MyStructC) Area() float64   { return c.w * c.h }
+func (c *MyStructC) String() string  { return "c" }
+func (c *MyStructC) scale(k float64) { c.w *= k }
+
+type ID int
+
+func main() {
+	a := MyStructA{}
+	fmt.Println(a.Method1())
+}
+`,
+}
+
+func TestClassDiagram(t *testing.T) {
+	prog := loadTestProgram(t, testdata_classdiagram)
+	d := classDiagram(prog.Packages)
+
+	var buf bytes.Buffer
+	if err := writeMermaidClasses(&buf, d); err != nil {
+		t.Fatal(err)
+	}
+	assertLines(t, strings.Split(strings.TrimSpace(buf.String()), "\n"), []string{
+		"classDiagram",
+		`    class C0["example.MyStructB"]`,
+		`    class C1["fmt.Stringer"]`,
+		"    <<interface>> C1",
+		`    class C2["main.MyStructA"]`,
+		"    C2 : -name string",
+		"    C2 : +Items []*main.MyStructC",
+		"    C2 : +Method1() int",
+		`    class C3["main.MyStructC"]`,
+		"    C3 : -w float64",
+		"    C3 : -h float64",
+		"    C3 : +Area() float64",
+		"    C3 : +String() string",
+		"    C3 : -scale(k float64)",
+		`    class C4["main.Shape"]`,
+		"    <<interface>> C4",
+		"    C4 : +Area() float64",
+		"    C2 --> C3 : Items",
+		"    C2 *-- C0 : embeds",
+		"    C4 <|.. C3",
+		"    C4 *-- C1 : embeds",
+	})
+
+	buf.Reset()
+	if err := writePlantUMLClasses(&buf, d); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"class \"main.MyStructA\" as C2 {\n  -name string\n",
+		"interface \"main.Shape\" as C4 {\n  +Area() float64\n}\n",
+		"C2 *-- C0 : embeds\n",
+		"C4 <|.. C3\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("PlantUML output does not contain %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// 構造体の複合リテラルの書き方
+const (
+	litKeyed      = "keyed"      // Point{X: 1, Y: 2}
+	litPositional = "positional" // Point{1, 2}
+)
+
+func runCompLit(args []string) error {
+	fs := flag.NewFlagSet("complit", flag.ExitOnError)
+	style := fs.String("style", litKeyed, "rewrite struct literals to `style` keyed or positional")
+	write := fs.Bool("w", false, "write the changed files instead of printing them")
+	fs.Parse(args)
+	if *style != litKeyed && *style != litPositional {
+		return fmt.Errorf("-style must be %s or %s", litKeyed, litPositional)
+	}
+	prog, err := loadProgramWith(loadOptions{Tests: true}, ".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	files, err := normalizeLiterals(prog, *style)
+	if err != nil {
+		return err
+	}
+	return writeGenerated(files, *write)
+}
+
+// normalizeLiterals は構造体の複合リテラルを style の書き方にそろえ、変更したファイルの内容を返す。
+// キー付きにするときは位置で並べたリテラルの値に、型情報から対応するフィールドの名前を付ける。
+// 位置で並べるときは、すべてのフィールドにキーがあり、並べ替えで評価の順が変わる場合は値に呼び出しや
+// 受信を含まないリテラルだけを書き換える。空のリテラルと、キーにできない _ のフィールドがある構造体の
+// 位置で並べたリテラルはそのままにする
+func normalizeLiterals(prog *Program, style string) (map[string][]byte, error) {
+	return rewriteFiles(prog, func(pkg *packages.Package, file *ast.File) bool {
+		changed := false
+		ast.Inspect(file, func(n ast.Node) bool {
+			lit, ok := n.(*ast.CompositeLit)
+			if !ok || len(lit.Elts) == 0 {
+				return true
+			}
+			st := literalStruct(pkg.TypesInfo, lit)
+			if st == nil {
+				return true
+			}
+			switch keyed := isKeyedLiteral(lit); {
+			case style == litKeyed && !keyed:
+				changed = keyLiteral(lit, structFieldNames(st)) || changed
+			case style == litPositional && keyed:
+				changed = positionLiteral(lit, structFieldNames(st)) || changed
+			}
+			return true
+		})
+		return changed
+	})
+}
+
+// positionLiteral はキー付きの構造体のリテラル lit を、names の順に値を並べた形にする。書き換えられなければ false を返す
+func positionLiteral(lit *ast.CompositeLit, names []string) bool {
+	if len(lit.Elts) != len(names) {
+		return false
+	}
+	index := make(map[string]int)
+	for i, name := range names {
+		index[name] = i
+	}
+	values := make([]ast.Expr, len(names))
+	reordered := false
+	for i, elt := range lit.Elts {
+		kv := elt.(*ast.KeyValueExpr)
+		key, ok := kv.Key.(*ast.Ident)
+		if !ok {
+			return false
+		}
+		j, ok := index[key.Name]
+		if !ok || values[j] != nil {
+			return false
+		}
+		values[j] = kv.Value
+		reordered = reordered || i != j
+	}
+	if reordered {
+		for _, v := range values {
+			if hasSideEffects(v) {
+				return false
+			}
+		}
+	}
+	lit.Elts = values
+	return true
+}
+
+// hasSideEffects は e が関数の呼び出しかチャネルの受信を含むかどうかを返す (型変換も呼び出しとみなす)
+func hasSideEffects(e ast.Expr) bool {
+	found := false
+	ast.Inspect(e, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.CallExpr:
+			found = true
+		case *ast.UnaryExpr:
+			found = found || n.Op == token.ARROW
+		case *ast.FuncLit:
+			return false
+		}
+		return !found
+	})
+	return found
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestNormalizeLiterals(t *testing.T) {
+	src := `package main
+
+import "fmt"
+
+type Base struct{ id int }
+
+type Point struct {
+	X, Y int
+}
+
+// _ のフィールドはキーにできないので、キー付きにしない
+type Padded struct {
+	A int
+	_ int32
+	B int
+}
+
+type Item struct {
+	*Base
+	Name string
+	At   Point
+}
+
+func next() int { return 1 }
+
+func main() {
+	p := Point{1, 2}
+	q := Point{Y: 2, X: 1}
+	r := Point{Y: next(), X: 1}
+	s := Point{X: next(), Y: 2}
+	u := Point{X: 1}
+	v := Padded{1, 0, 2}
+	items := []Item{{&Base{1}, "a", Point{}}, {Name: "b", At: Point{3, 4}, Base: nil}}
+	fmt.Println(p, q, r, s, u, v, items, []int{1, 2}, map[string]Point{"o": {0, 0}})
+}
+`
+	for _, tt := range []struct {
+		style, want string
+	}{
+		{litKeyed, `package main
+
+import "fmt"
+
+type Base struct{ id int }
+
+type Point struct {
+	X, Y int
+}
+
+// _ のフィールドはキーにできないので、キー付きにしない
+type Padded struct {
+	A int
+	_ int32
+	B int
+}
+
+type Item struct {
+	*Base
+	Name string
+	At   Point
+}
+
+func next() int { return 1 }
+
+func main() {
+	p := Point{X: 1, Y: 2}
+	q := Point{Y: 2, X: 1}
+	r := Point{Y: next(), X: 1}
+	s := Point{X: next(), Y: 2}
+	u := Point{X: 1}
+	v := Padded{1, 0, 2}
+	items := []Item{{Base: &Base{id: 1}, Name: "a", At: Point{}}, {Name: "b", At: Point{X: 3, Y: 4}, Base: nil}}
+	fmt.Println(p, q, r, s, u, v, items, []int{1, 2}, map[string]Point{"o": {X: 0, Y: 0}})
+}
+`},
+		{litPositional, `package main
+
+import "fmt"
+
+type Base struct{ id int }
+
+type Point struct {
+	X, Y int
+}
+
+// _ のフィールドはキーにできないので、キー付きにしない
+type Padded struct {
+	A int
+	_ int32
+	B int
+}
+
+type Item struct {
+	*Base
+	Name string
+	At   Point
+}
+
+func next() int { return 1 }
+
+func main() {
+	p := Point{1, 2}
+	q := Point{1, 2}
+	r := Point{Y: next(), X: 1}
+	s := Point{next(), 2}
+	u := Point{X: 1}
+	v := Padded{1, 0, 2}
+	items := []Item{{&Base{1}, "a", Point{}}, {nil, "b", Point{3, 4}}}
+	fmt.Println(p, q, r, s, u, v, items, []int{1, 2}, map[string]Point{"o": {0, 0}})
+}
+`},
+	} {
+		dir := writeModule(t, map[string]string{"main.go": src})
+		prog, err := loadProgramWith(loadOptions{Tests: true}, dir, "./...")
+		if err != nil {
+			t.Fatal(err)
+		}
+		files, err := normalizeLiterals(prog, tt.style)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(files[filepath.Join(dir, "main.go")]); got != tt.want {
+			t.Errorf("%s: got:\n%s\nwant:\n%s", tt.style, got, tt.want)
+		}
+	}
+}
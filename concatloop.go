@@ -0,0 +1,409 @@
+package main
+
+import (
+	"flag"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+func runConcatLoop(args []string) error {
+	fs := flag.NewFlagSet("concatloop", flag.ExitOnError)
+	fix := fs.Bool("fix", false, "rewrite the loops to use strings.Builder where it is safe and write the files")
+	return runDiagnostics(fs, args, func(prog *Program) ([]Diagnostic, error) {
+		groups := concatLoops(prog)
+		if *fix {
+			files, err := fixConcatLoops(prog, groups)
+			if err != nil {
+				return nil, err
+			}
+			if err := writeFiles(files); err != nil {
+				return nil, err
+			}
+		}
+		var diags []Diagnostic
+		for _, g := range groups {
+			diags = append(diags, g.diagnostic(prog))
+		}
+		sortDiagnostics(diags)
+		return diags, nil
+	})
+}
+
+// checkConcatLoops は concatLoops の指摘を返す
+func checkConcatLoops(prog *Program) []Diagnostic {
+	var diags []Diagnostic
+	for _, g := range concatLoops(prog) {
+		diags = append(diags, g.diagnostic(prog))
+	}
+	return diags
+}
+
+// fixConcatLoopAt は指摘 d のループだけを strings.Builder に書き直したファイルの内容を返す (書き直せなければ空)
+func fixConcatLoopAt(prog *Program, d Diagnostic) (map[string][]byte, error) {
+	for _, g := range concatLoops(prog) {
+		if diag := g.diagnostic(prog); diag.Pos == d.Pos && diag.Message == d.Message {
+			return fixConcatLoops(prog, []*concatGroup{g})
+		}
+	}
+	return nil, nil
+}
+
+// concatGroup はループの中で 1 つの文字列変数に繰り返し連結している代入の集まり
+type concatGroup struct {
+	pkg     *packages.Package
+	file    *ast.File
+	v       *types.Var
+	loop    ast.Stmt // for か range 文 (ラベルがあればラベル付きの文)
+	parent  ast.Node // loop を含む文の並び (ブロックか case 節)
+	assigns []*ast.AssignStmt
+	sprintf bool // fmt.Sprintf で連結している
+}
+
+func (g *concatGroup) diagnostic(prog *Program) Diagnostic {
+	if g.sprintf {
+		return newDiagnostic(prog.Fset, g.assigns[0].Pos(), "concat",
+			"%s is built with fmt.Sprintf in a loop; write to a strings.Builder with fmt.Fprintf", g.v.Name())
+	}
+	return newDiagnostic(prog.Fset, g.assigns[0].Pos(), "concat",
+		"%s is built by string concatenation in a loop; use a strings.Builder", g.v.Name())
+}
+
+// concatLoops は解析対象のパッケージから、ループの外で宣言した string の変数にループの中で
+// += や s = s + x、s = fmt.Sprintf(..., s, ...) で連結している箇所を、変数と最も外側のループごとにまとめて返す
+func concatLoops(prog *Program) []*concatGroup {
+	var groups []*concatGroup
+	for _, pkg := range prog.Packages {
+		for _, file := range pkg.Syntax {
+			if !prog.inFocus(prog.Fset.Position(file.Pos())) {
+				continue
+			}
+			byKey := make(map[[2]any]*concatGroup)
+			var stack []ast.Node
+			ast.Inspect(file, func(n ast.Node) bool {
+				if n == nil {
+					stack = stack[:len(stack)-1]
+					return true
+				}
+				stack = append(stack, n)
+				assign, ok := n.(*ast.AssignStmt)
+				if !ok {
+					return true
+				}
+				v, sprintf := concatTarget(pkg.TypesInfo, assign)
+				if v == nil {
+					return true
+				}
+				// v を宣言した位置より外側に出ない範囲で最も外側のループを探す。関数の外には出ない
+				loop, parent := -1, -1
+			walk:
+				for i := len(stack) - 2; i >= 0; i-- {
+					switch s := stack[i].(type) {
+					case *ast.FuncLit, *ast.FuncDecl:
+						break walk
+					case *ast.ForStmt, *ast.RangeStmt:
+						if v.Pos() >= s.Pos() && v.Pos() < s.End() {
+							break walk
+						}
+						loop = i
+					}
+				}
+				if loop < 0 {
+					return true
+				}
+				if _, ok := stack[loop-1].(*ast.LabeledStmt); ok {
+					loop--
+				}
+				switch stack[loop-1].(type) {
+				case *ast.BlockStmt, *ast.CaseClause, *ast.CommClause:
+					parent = loop - 1
+				}
+				key := [2]any{v, stack[loop]}
+				g, ok := byKey[key]
+				if !ok {
+					g = &concatGroup{pkg: pkg, file: file, v: v, loop: stack[loop].(ast.Stmt)}
+					if parent >= 0 {
+						g.parent = stack[parent]
+					}
+					byKey[key] = g
+					groups = append(groups, g)
+				}
+				g.assigns = append(g.assigns, assign)
+				g.sprintf = g.sprintf || sprintf
+				return true
+			})
+		}
+	}
+	return groups
+}
+
+// concatTarget は assign が string の変数への連結なら、その変数と fmt.Sprintf を使っているかどうかを返す
+func concatTarget(info *types.Info, assign *ast.AssignStmt) (*types.Var, bool) {
+	if len(assign.Lhs) != 1 || len(assign.Rhs) != 1 {
+		return nil, false
+	}
+	id, ok := assign.Lhs[0].(*ast.Ident)
+	if !ok {
+		return nil, false
+	}
+	v, ok := info.Uses[id].(*types.Var)
+	if !ok || !types.Identical(v.Type(), types.Typ[types.String]) {
+		return nil, false
+	}
+	rhs := ast.Unparen(assign.Rhs[0])
+	switch assign.Tok {
+	case token.ADD_ASSIGN:
+		return v, isSprintf(info, rhs)
+	case token.ASSIGN:
+		if concatHead(info, rhs) == v {
+			return v, false
+		}
+		if isSprintf(info, rhs) {
+			for _, arg := range rhs.(*ast.CallExpr).Args[1:] {
+				if id, ok := ast.Unparen(arg).(*ast.Ident); ok && info.Uses[id] == v {
+					return v, true
+				}
+			}
+		}
+	}
+	return nil, false
+}
+
+// concatHead は a + b + ... の最も左の項が変数ならその変数を返す
+func concatHead(info *types.Info, e ast.Expr) *types.Var {
+	bin, ok := e.(*ast.BinaryExpr)
+	if !ok || bin.Op != token.ADD {
+		return nil
+	}
+	for {
+		x := ast.Unparen(bin.X)
+		if next, ok := x.(*ast.BinaryExpr); ok && next.Op == token.ADD {
+			bin = next
+			continue
+		}
+		if id, ok := x.(*ast.Ident); ok {
+			v, _ := info.Uses[id].(*types.Var)
+			return v
+		}
+		return nil
+	}
+}
+
+// concatTerms は a + b + ... の項を左から順に返す
+func concatTerms(e ast.Expr) []ast.Expr {
+	if bin, ok := ast.Unparen(e).(*ast.BinaryExpr); ok && bin.Op == token.ADD {
+		return append(concatTerms(bin.X), concatTerms(bin.Y)...)
+	}
+	return []ast.Expr{e}
+}
+
+func isSprintf(info *types.Info, e ast.Expr) bool {
+	call, ok := e.(*ast.CallExpr)
+	if !ok || len(call.Args) == 0 {
+		return false
+	}
+	fn, ok := typeutil.Callee(info, call).(*types.Func)
+	return ok && fn.FullName() == "fmt.Sprintf"
+}
+
+// fixConcatLoops は groups のループを strings.Builder に書き直したファイルの内容を、ファイル名をキーにして返す。
+// 安全に書き直せないもの (ループの中で変数を読んでいる、ループから return する、パッケージ変数など) と
+// 生成されたファイルのものは書き直さない
+func fixConcatLoops(prog *Program, groups []*concatGroup) (map[string][]byte, error) {
+	editors := make(map[*ast.File]*StmtEditor)
+	for _, g := range groups {
+		if prog.skipGenerated(prog.Fset.Position(g.file.Pos()).Filename) {
+			continue
+		}
+		e := editors[g.file]
+		if e == nil {
+			e = NewStmtEditor()
+		}
+		if g.fix(e) {
+			editors[g.file] = e
+		}
+	}
+	files := make(map[string][]byte)
+	for file, e := range editors {
+		if err := e.Apply(file); err != nil {
+			return nil, err
+		}
+		name, src, err := formatFile(prog.Fset, file)
+		if err != nil {
+			return nil, err
+		}
+		files[name] = src
+	}
+	return files, nil
+}
+
+// fix は g のループを strings.Builder を使うように書き直す編集を e に登録する。書き直せなければ false を返す
+func (g *concatGroup) fix(e *StmtEditor) bool {
+	info := g.pkg.TypesInfo
+	if g.parent == nil || g.v.Parent() == nil || g.v.Parent() == g.pkg.Types.Scope() {
+		return false
+	}
+	// ループの中での変数の参照は連結の代入の中のものだけでなければならない
+	allowed := make(map[*ast.Ident]bool)
+	replacements := make(map[*ast.AssignStmt][]ast.Stmt)
+	sb := builderName(g.parent, g.v.Name())
+	for _, a := range g.assigns {
+		allowed[a.Lhs[0].(*ast.Ident)] = true
+		stmts, self := g.rewrite(a, sb)
+		if stmts == nil {
+			return false
+		}
+		if self != nil {
+			allowed[self] = true
+		}
+		replacements[a] = stmts
+	}
+	ok := true
+	ast.Inspect(g.loop, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Ident:
+			if info.Uses[n] == g.v && !allowed[n] {
+				ok = false
+			}
+		case *ast.ReturnStmt:
+			ok = false
+		case *ast.BranchStmt:
+			if n.Tok == token.GOTO {
+				ok = false
+			}
+		}
+		return ok
+	})
+	if !ok {
+		return false
+	}
+
+	// 挿入する文に周りの位置を付け、後ろのコメントが文の中に出力されないようにする
+	for a, stmts := range replacements {
+		for _, stmt := range stmts {
+			fillPositions(stmt, a.Pos())
+		}
+		e.Replace(a, stmts...)
+	}
+	before := []ast.Stmt{MustParseStmt("var %s strings.Builder", sb)}
+	if !g.startsEmpty() {
+		before = append(before, MustParseStmt("%s.WriteString(%s)", sb, g.v.Name()))
+	}
+	after := MustParseStmt("%s = %s.String()", g.v.Name(), sb)
+	for _, stmt := range before {
+		fillPositions(stmt, g.loop.Pos())
+	}
+	fillPositions(after, g.loop.End())
+	e.InsertBefore(g.loop, before...)
+	e.InsertAfter(g.loop, after)
+	return true
+}
+
+// rewrite は連結の代入 a を sb に書き込む文に書き直したものと、その中で読んでいる連結先の変数の識別子を返す。
+// 書き直せなければ nil を返す
+func (g *concatGroup) rewrite(a *ast.AssignStmt, sb string) ([]ast.Stmt, *ast.Ident) {
+	info := g.pkg.TypesInfo
+	rhs := ast.Unparen(a.Rhs[0])
+	var terms []ast.Expr
+	var self *ast.Ident
+	switch {
+	case a.Tok == token.ADD_ASSIGN:
+		terms = []ast.Expr{rhs}
+	case concatHead(info, rhs) == g.v:
+		terms = concatTerms(rhs)
+		self = ast.Unparen(terms[0]).(*ast.Ident)
+		terms = terms[1:]
+	default:
+		// s = fmt.Sprintf("%s...", s, ...) は書式の先頭の %s が s のときだけ書き直せる
+		call := rhs.(*ast.CallExpr)
+		lit, ok := call.Args[0].(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING || len(call.Args) < 2 || call.Ellipsis.IsValid() {
+			return nil, nil
+		}
+		format, err := strconv.Unquote(lit.Value)
+		id, isIdent := ast.Unparen(call.Args[1]).(*ast.Ident)
+		if err != nil || !strings.HasPrefix(format, "%s") || !isIdent || info.Uses[id] != g.v {
+			return nil, nil
+		}
+		rest := MustParseExpr("%q", strings.TrimPrefix(format, "%s"))
+		return []ast.Stmt{fprintfStmt(sb, append([]ast.Expr{rest}, call.Args[2:]...), false)}, id
+	}
+	var stmts []ast.Stmt
+	for _, t := range terms {
+		if call, ok := ast.Unparen(t).(*ast.CallExpr); ok && isSprintf(info, call) {
+			stmts = append(stmts, fprintfStmt(sb, call.Args, call.Ellipsis.IsValid()))
+			continue
+		}
+		stmts = append(stmts, MustParseStmt("%s.WriteString(%s)", sb, t))
+	}
+	return stmts, self
+}
+
+// fprintfStmt は fmt.Fprintf(&sb, args...) の文を作る
+func fprintfStmt(sb string, args []ast.Expr, ellipsis bool) ast.Stmt {
+	call := MustParseExpr("fmt.Fprintf(&%s)", sb).(*ast.CallExpr)
+	call.Args = append(call.Args, args...)
+	if ellipsis {
+		// 位置が有効でないと ... が出力されないので、最後の引数の終わりを使う
+		call.Ellipsis = args[len(args)-1].End()
+	}
+	return &ast.ExprStmt{X: call}
+}
+
+// startsEmpty はループの直前の文が変数を空の文字列で宣言しているかどうかを返す
+func (g *concatGroup) startsEmpty() bool {
+	var list []ast.Stmt
+	switch p := g.parent.(type) {
+	case *ast.BlockStmt:
+		list = p.List
+	case *ast.CaseClause:
+		list = p.Body
+	case *ast.CommClause:
+		list = p.Body
+	}
+	i := sort.Search(len(list), func(i int) bool { return list[i].Pos() >= g.loop.Pos() })
+	if i == 0 || i >= len(list) {
+		return false
+	}
+	switch prev := list[i-1].(type) {
+	case *ast.DeclStmt:
+		gd, ok := prev.Decl.(*ast.GenDecl)
+		if !ok || len(gd.Specs) != 1 {
+			return false
+		}
+		spec, ok := gd.Specs[0].(*ast.ValueSpec)
+		return ok && len(spec.Names) == 1 && spec.Names[0].Name == g.v.Name() && (len(spec.Values) == 0 || isEmptyString(spec.Values[0]))
+	case *ast.AssignStmt:
+		return prev.Tok == token.DEFINE && len(prev.Lhs) == 1 && len(prev.Rhs) == 1 &&
+			prev.Lhs[0].(*ast.Ident).Name == g.v.Name() && isEmptyString(prev.Rhs[0])
+	}
+	return false
+}
+
+func isEmptyString(e ast.Expr) bool {
+	lit, ok := e.(*ast.BasicLit)
+	return ok && lit.Kind == token.STRING && (lit.Value == `""` || lit.Value == "``")
+}
+
+// builderName は block (ループを含む文の並び) の中で使われていない strings.Builder の変数の名前を返す
+func builderName(block ast.Node, name string) string {
+	used := make(map[string]bool)
+	ast.Inspect(block, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok {
+			used[id.Name] = true
+		}
+		return true
+	})
+	base := name + "Builder"
+	candidate := base
+	for i := 2; used[candidate]; i++ {
+		candidate = base + strconv.Itoa(i)
+	}
+	return candidate
+}
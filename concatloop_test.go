@@ -0,0 +1,156 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestConcatLoops(t *testing.T) {
+	src := `package main
+
+import "fmt"
+
+var global string
+
+func join(names []string) string {
+	s := ""
+	for _, name := range names {
+		s += name + ","
+	}
+	return s
+}
+
+func lines(n int) string {
+	out := "header\n"
+	for i := 0; i < n; i++ {
+		out = out + fmt.Sprint(i) + "\n"
+	}
+	return out
+}
+
+func table(rows [][]string) string {
+	var s string
+	for i, row := range rows {
+		for _, cell := range row {
+			s = fmt.Sprintf("%s%d:%s ", s, i, cell)
+		}
+		s += fmt.Sprintf("(%d)\n", len(row))
+	}
+	return s
+}
+
+func firstLong(names []string) string {
+	s := ""
+	for _, name := range names {
+		s += name
+		if len(s) > 10 {
+			return s
+		}
+	}
+	return s
+}
+
+func record(names []string) {
+	for _, name := range names {
+		global += name
+	}
+}
+
+func main() {
+	fmt.Println(join(nil), lines(1), table(nil), firstLong(nil))
+	record(nil)
+}
+`
+	prog := loadTestProgram(t, map[string]string{"main.go": src})
+	groups := concatLoops(prog)
+	var diags []Diagnostic
+	for _, g := range groups {
+		diags = append(diags, g.diagnostic(prog))
+	}
+	sortDiagnostics(diags)
+	assertLines(t, diagnosticMessages(diags), []string{
+		"main.go:10: s is built by string concatenation in a loop; use a strings.Builder",
+		"main.go:18: out is built by string concatenation in a loop; use a strings.Builder",
+		"main.go:27: s is built with fmt.Sprintf in a loop; write to a strings.Builder with fmt.Fprintf",
+		"main.go:37: s is built by string concatenation in a loop; use a strings.Builder",
+		"main.go:47: global is built by string concatenation in a loop; use a strings.Builder",
+	})
+
+	files, err := fixConcatLoops(prog, groups)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("got %d files, want 1", len(files))
+	}
+	for _, got := range files {
+		want := `package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+var global string
+
+func join(names []string) string {
+	s := ""
+	var sBuilder strings.Builder
+	for _, name := range names {
+		sBuilder.WriteString(name + ",")
+	}
+	s = sBuilder.String()
+	return s
+}
+
+func lines(n int) string {
+	out := "header\n"
+	var outBuilder strings.Builder
+	outBuilder.WriteString(out)
+	for i := 0; i < n; i++ {
+		outBuilder.WriteString(fmt.Sprint(i))
+		outBuilder.WriteString("\n")
+	}
+	out = outBuilder.String()
+	return out
+}
+
+func table(rows [][]string) string {
+	var s string
+	var sBuilder strings.Builder
+	for i, row := range rows {
+		for _, cell := range row {
+			fmt.Fprintf(&sBuilder, "%d:%s ", i, cell)
+		}
+		fmt.Fprintf(&sBuilder, "(%d)\n", len(row))
+	}
+	s = sBuilder.String()
+	return s
+}
+
+func firstLong(names []string) string {
+	s := ""
+	for _, name := range names {
+		s += name
+		if len(s) > 10 {
+			return s
+		}
+	}
+	return s
+}
+
+func record(names []string) {
+	for _, name := range names {
+		global += name
+	}
+}
+
+func main() {
+	fmt.Println(join(nil), lines(1), table(nil), firstLong(nil))
+	record(nil)
+}
+`
+		if string(got) != want {
+			t.Errorf("got:\n%s\nwant:\n%s", got, want)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"go/ast"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// isContextType は t が context.Context かどうかを返す
+func isContextType(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == "context" && obj.Name() == "Context"
+}
+
+// checkContext は context.Context の誤用を検出する
+//   - 構造体のフィールドに保持されている
+//   - 第 1 引数以外で受け取っている
+//   - ctx を受け取っている呼び出し経路の中で context.Background / TODO を使っている
+func checkContext(prog *Program) []Diagnostic {
+	var diags []Diagnostic
+	r := NewVisitorRegistry()
+	registerContextFields(r, prog, func(d Diagnostic) { diags = append(diags, d) })
+	r.Walk(prog.Packages)
+	diags = append(diags, checkContextBackground(prog)...)
+	sortDiagnostics(diags)
+	return diags
+}
+
+// registerContextFields は構造体のフィールドに保持された context.Context と、第 1 引数以外で受け取る
+// context.Context を報告する関数を r に登録する
+func registerContextFields(r *VisitorRegistry, prog *Program, report func(Diagnostic)) {
+	r.Register([]ast.Node{(*ast.StructType)(nil)}, func(c *VisitContext, n ast.Node) {
+		for _, field := range n.(*ast.StructType).Fields.List {
+			if !isContextType(c.Pkg.TypesInfo.TypeOf(field.Type)) {
+				continue
+			}
+			if len(field.Names) == 0 {
+				report(newDiagnostic(prog.Fset, field.Pos(), "context", "context.Context stored in struct field embedded"))
+			}
+			for _, name := range field.Names {
+				report(newDiagnostic(prog.Fset, name.Pos(), "context",
+					"context.Context stored in struct field %s", name.Name))
+			}
+		}
+	})
+	r.Register([]ast.Node{(*ast.FuncType)(nil)}, func(c *VisitContext, n ast.Node) {
+		params := n.(*ast.FuncType).Params
+		if params == nil {
+			return
+		}
+		i := 0
+		for _, field := range params.List {
+			isContext := isContextType(c.Pkg.TypesInfo.TypeOf(field.Type))
+			if len(field.Names) == 0 {
+				if i > 0 && isContext {
+					report(newDiagnostic(prog.Fset, field.Pos(), "context",
+						"context.Context should be the first parameter, found at position %d", i+1))
+				}
+				i++
+			}
+			for _, name := range field.Names {
+				if i > 0 && isContext {
+					report(newDiagnostic(prog.Fset, name.Pos(), "context",
+						"context.Context should be the first parameter, found at position %d", i+1))
+				}
+				i++
+			}
+		}
+	})
+}
+
+// receivesContext は fn が context.Context を引数に取るかどうかを返す
+func receivesContext(fn *ssa.Function) bool {
+	for _, param := range fn.Params {
+		if isContextType(param.Type()) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkContextBackground は ctx を受け取る関数から到達できる関数での context.Background / TODO の呼び出しを検出する
+func checkContextBackground(prog *Program) []Diagnostic {
+	cg := prog.CallGraph()
+
+	// ctx を受け取る関数から BFS して、到達元を記録する
+	from := make(map[*ssa.Function]*ssa.Function)
+	var queue []*ssa.Function
+	for _, fn := range prog.targetFunctions() {
+		if receivesContext(fn) {
+			from[fn] = nil
+			queue = append(queue, fn)
+		}
+	}
+	for len(queue) > 0 {
+		fn := queue[0]
+		queue = queue[1:]
+		var callees []*ssa.Function
+		if node := cg.Nodes[fn]; node != nil {
+			for _, e := range node.Out {
+				callees = append(callees, e.Callee.Func)
+			}
+		}
+		for _, anon := range fn.AnonFuncs {
+			callees = append(callees, anon)
+		}
+		for _, callee := range callees {
+			if _, seen := from[callee]; seen || callee.Pkg == nil || !prog.isTarget(callee.Pkg.Pkg) {
+				continue
+			}
+			from[callee] = fn
+			queue = append(queue, callee)
+		}
+	}
+
+	var diags []Diagnostic
+	for _, fn := range prog.targetFunctions() {
+		if _, ok := from[fn]; !ok {
+			continue
+		}
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				call, ok := instr.(ssa.CallInstruction)
+				if !ok {
+					continue
+				}
+				callee := call.Common().StaticCallee()
+				if callee == nil || callee.Pkg == nil || callee.Pkg.Pkg.Path() != "context" {
+					continue
+				}
+				if name := callee.Name(); name != "Background" && name != "TODO" {
+					continue
+				}
+				diags = append(diags, newDiagnostic(prog.Fset, call.Pos(), "context",
+					"context.%s() called in %s, which is reached from a function that already receives a ctx (%s)",
+					callee.Name(), fn.Name(), contextChain(from, fn)))
+			}
+		}
+	}
+	return diags
+}
+
+// contextChain は ctx を受け取る関数から fn までの呼び出し経路を文字列にする
+func contextChain(from map[*ssa.Function]*ssa.Function, fn *ssa.Function) string {
+	var chain []string
+	for f := fn; f != nil; f = from[f] {
+		chain = append([]string{f.Name()}, chain...)
+	}
+	return strings.Join(chain, " -> ")
+}
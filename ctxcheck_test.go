@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+func TestCheckContext(t *testing.T) {
+	src := `package main
+
+import "context"
+
+type Server struct {
+	ctx  context.Context
+	name string
+}
+
+func handle(ctx context.Context, id int) {
+	lookup(id)
+	go func() {
+		_ = context.TODO()
+	}()
+}
+
+func lookup(id int) {
+	_ = context.Background()
+}
+
+func process(id int, ctx context.Context) {}
+
+func main() {
+	_ = context.Background()
+	handle(context.Background(), 1)
+}
+
+type Pair struct {
+	parent, child context.Context
+}
+
+func merge(parent, child context.Context) {}
+`
+	prog := loadTestProgram(t, map[string]string{"main.go": src})
+	assertLines(t, diagnosticMessages(checkContext(prog)), []string{
+		"main.go:6: context.Context stored in struct field ctx",
+		"main.go:13: context.TODO() called in handle$1, which is reached from a function that already receives a ctx (handle -> handle$1)",
+		"main.go:18: context.Background() called in lookup, which is reached from a function that already receives a ctx (handle -> lookup)",
+		"main.go:21: context.Context should be the first parameter, found at position 2",
+		"main.go:29: context.Context stored in struct field parent",
+		"main.go:29: context.Context stored in struct field child",
+		"main.go:32: context.Context should be the first parameter, found at position 2",
+	})
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// Cycle はコールグラフの強連結成分 1 つ (再帰呼び出しのグループ)
+type Cycle struct {
+	Kind  string   `json:"kind"` // direct (自己再帰) または mutual (相互再帰)
+	Funcs []string `json:"funcs"`
+
+	Fns []*ssa.Function `json:"-"` // Funcs と同じ順の関数
+}
+
+func runCycles(args []string) error {
+	fs := flag.NewFlagSet("cycles", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output as JSON")
+	failPkgs := fs.String("fail", "", "comma-separated package patterns (path or path/...) in which cycles are an error")
+	allow := fs.String("allow", "", "comma-separated full names of functions whose cycles are expected")
+	fs.Parse(args)
+	prog, err := loadProgram(".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	cycles := callCycles(prog)
+	if *asJSON {
+		err = writeJSON(os.Stdout, cycles)
+	} else {
+		err = writeCycles(os.Stdout, cycles)
+	}
+	if err != nil {
+		return err
+	}
+	if *failPkgs == "" {
+		return nil
+	}
+	if bad := unexpectedCycles(cycles, splitList(*failPkgs), splitList(*allow)); len(bad) > 0 {
+		return fmt.Errorf("%d unexpected call cycle(s)", len(bad))
+	}
+	return nil
+}
+
+// callCycles はコールグラフから、解析対象の関数だけでできた強連結成分 (stronglyConnected) を探す
+func callCycles(prog *Program) []Cycle {
+	cg := prog.CallGraph()
+	byName := make(map[string]*ssa.Function)
+	var nodes []string
+	for _, fn := range prog.targetFunctions() {
+		name := fn.RelString(nil)
+		byName[name] = fn
+		nodes = append(nodes, name)
+	}
+	// インスタンス (Walk[int]) はジェネリックな元の関数 (Walk) にまとめる
+	origin := func(fn *ssa.Function) *ssa.Function {
+		if o := fn.Origin(); o != nil {
+			return o
+		}
+		return fn
+	}
+	succs := make(map[string][]string)
+	for fn, node := range cg.Nodes {
+		if fn == nil {
+			continue
+		}
+		caller := origin(fn)
+		name := caller.RelString(nil)
+		if byName[name] != caller {
+			continue
+		}
+		for _, e := range node.Out {
+			callee := origin(e.Callee.Func)
+			if cname := callee.RelString(nil); byName[cname] == callee && !slices.Contains(succs[name], cname) {
+				succs[name] = append(succs[name], cname)
+			}
+		}
+	}
+	var cycles []Cycle
+	for _, scc := range stronglyConnected(nodes, succs) {
+		c := Cycle{Kind: "mutual", Funcs: scc}
+		if len(scc) == 1 {
+			if !slices.Contains(succs[scc[0]], scc[0]) {
+				continue
+			}
+			c.Kind = "direct"
+		}
+		for _, name := range scc {
+			c.Fns = append(c.Fns, byName[name])
+		}
+		cycles = append(cycles, c)
+	}
+	sort.Slice(cycles, func(i, j int) bool { return cycles[i].Funcs[0] < cycles[j].Funcs[0] })
+	return cycles
+}
+
+// unexpectedCycles は pkgs のいずれかに属する関数を含み、allow に含まれる関数を含まない循環を返す。
+// 関数のパッケージは名前ではなく関数から求める (インスタンスの名前 pkg.F[example.com/x.T] は型引数に / を含む)
+func unexpectedCycles(cycles []Cycle, pkgs, allow []string) []Cycle {
+	allowed := make(map[string]bool)
+	for _, name := range allow {
+		allowed[name] = true
+	}
+	var bad []Cycle
+	for _, c := range cycles {
+		inPkgs, isAllowed := false, false
+		for _, name := range c.Funcs {
+			if allowed[name] {
+				isAllowed = true
+			}
+		}
+		for _, fn := range c.Fns {
+			pkg := ssaFuncPackage(fn)
+			if pkg == nil {
+				continue
+			}
+			for _, pattern := range pkgs {
+				if matchPackage(pattern, pkg.Path()) {
+					inPkgs = true
+				}
+			}
+		}
+		if inPkgs && !isAllowed {
+			bad = append(bad, c)
+		}
+	}
+	return bad
+}
+
+// matchPackage は path が pattern (import path、または末尾が /... のもの) に一致するかどうかを返す
+func matchPackage(pattern, path string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "/..."); ok {
+		return path == prefix || strings.HasPrefix(path, prefix+"/")
+	}
+	return path == pattern
+}
+
+// splitList はカンマ区切りの文字列を空要素を除いて分割する
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func writeCycles(w io.Writer, cycles []Cycle) error {
+	for _, c := range cycles {
+		fmt.Fprintf(w, "%s: %s\n", c.Kind, strings.Join(c.Funcs, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCallCycles(t *testing.T) {
+	prog := loadTestProgram(t, map[string]string{
+		"main.go": `package main
+
+import "example.com/m/internal/parse"
+
+func fib(n int) int {
+	if n < 2 {
+		return n
+	}
+	return fib(n-1) + fib(n-2)
+}
+
+func even(n int) bool {
+	if n == 0 {
+		return true
+	}
+	return odd(n - 1)
+}
+
+func odd(n int) bool {
+	if n == 0 {
+		return false
+	}
+	return even(n - 1)
+}
+
+type node struct{}
+
+func main() {
+	_ = parse.Walk(node{}, 3)
+	_ = fib(10)
+	_ = even(3)
+	_ = parse.Expr("1+2")
+}
+`,
+		"internal/parse/parse.go": `package parse
+
+func Expr(s string) int {
+	return term(s)
+}
+
+func term(s string) int {
+	if len(s) > 1 && s[0] == '(' {
+		return Expr(s[1:])
+	}
+	return len(s)
+}
+
+func Walk[T any](v T, n int) int {
+	if n == 0 {
+		return 0
+	}
+	return Walk(v, n-1)
+}
+`,
+	})
+	cycles := callCycles(prog)
+	var buf bytes.Buffer
+	if err := writeCycles(&buf, cycles); err != nil {
+		t.Fatal(err)
+	}
+	assertLines(t, strings.Split(strings.TrimSpace(buf.String()), "\n"), []string{
+		"mutual: example.com/m.even, example.com/m.odd",
+		"direct: example.com/m.fib",
+		"mutual: example.com/m/internal/parse.Expr, example.com/m/internal/parse.term",
+		"direct: example.com/m/internal/parse.Walk",
+	})
+
+	bad := unexpectedCycles(cycles, []string{"example.com/m/internal/..."}, nil)
+	if len(bad) != 2 || bad[0].Funcs[0] != "example.com/m/internal/parse.Expr" || bad[1].Funcs[0] != "example.com/m/internal/parse.Walk" {
+		t.Errorf("unexpected cycles = %v", bad)
+	}
+	if bad := unexpectedCycles(cycles, []string{"example.com/m/internal/..."}, []string{"example.com/m/internal/parse.term", "example.com/m/internal/parse.Walk"}); len(bad) != 0 {
+		t.Errorf("allowed cycle reported: %v", bad)
+	}
+}
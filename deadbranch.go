@@ -0,0 +1,145 @@
+package main
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/cfg"
+)
+
+// checkDeadBranches は条件が定数の if と、タグと case がどちらも定数の switch (タグがなければ case が定数の bool) を見つけ、
+// 制御フローグラフから通らない方の辺を除いて、その分岐でしか到達できない文を指摘する。
+// const debug = false のようなビルドタグ代わりの定数による分岐も対象になる。もともと到達できない文は指摘しない
+func checkDeadBranches(prog *Program) []Diagnostic {
+	var diags []Diagnostic
+	for _, pkg := range prog.Packages {
+		for _, file := range pkg.Syntax {
+			ast.Inspect(file, func(n ast.Node) bool {
+				var body *ast.BlockStmt
+				switch n := n.(type) {
+				case *ast.FuncDecl:
+					body = n.Body
+				case *ast.FuncLit:
+					body = n.Body
+				}
+				if body != nil {
+					diags = append(diags, deadBranches(prog.Fset, pkg.TypesInfo, body)...)
+				}
+				return true
+			})
+		}
+	}
+	sortDiagnostics(diags)
+	return diags
+}
+
+func deadBranches(fset *token.FileSet, info *types.Info, body *ast.BlockStmt) []Diagnostic {
+	// 条件になる式と、switch の case ならそのタグ
+	conds := make(map[ast.Expr]bool)
+	caseTags := make(map[ast.Expr]ast.Expr)
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.IfStmt:
+			conds[n.Cond] = true
+		case *ast.SwitchStmt:
+			for _, clause := range n.Body.List {
+				for _, e := range clause.(*ast.CaseClause).List {
+					conds[e] = true
+					caseTags[e] = n.Tag
+				}
+			}
+		}
+		return true
+	})
+	var diags []Diagnostic
+	g := cfg.New(body, func(*ast.CallExpr) bool { return true })
+	// 定数の条件の分岐では通らない方の辺を除く
+	succs := make(map[*cfg.Block][]*cfg.Block)
+	var pruned []*cfg.Block
+	for _, b := range g.Blocks {
+		succs[b] = b.Succs
+		if len(b.Succs) != 2 || len(b.Nodes) == 0 {
+			continue
+		}
+		e, ok := b.Nodes[len(b.Nodes)-1].(ast.Expr)
+		if !ok || !conds[e] {
+			continue
+		}
+		taken, ok := constantCondition(info, e, caseTags[e])
+		if !ok {
+			continue
+		}
+		if tag := caseTags[e]; tag != nil {
+			verb := "never matches"
+			if taken {
+				verb = "always matches"
+			}
+			diags = append(diags, newDiagnostic(fset, e.Pos(), "deadbranch", "case %s %s constant switch tag %s", types.ExprString(e), verb, types.ExprString(tag)))
+		} else {
+			diags = append(diags, newDiagnostic(fset, e.Pos(), "deadbranch", "condition %s is always %v", types.ExprString(e), taken))
+		}
+		if taken {
+			succs[b] = b.Succs[:1]
+			pruned = append(pruned, b.Succs[1])
+		} else {
+			succs[b] = b.Succs[1:]
+			pruned = append(pruned, b.Succs[0])
+		}
+	}
+	reachable := make(map[*cfg.Block]bool)
+	var visit func(b *cfg.Block)
+	visit = func(b *cfg.Block) {
+		if reachable[b] {
+			return
+		}
+		reachable[b] = true
+		for _, s := range succs[b] {
+			visit(s)
+		}
+	}
+	visit(g.Blocks[0])
+	reported := make(map[*cfg.Block]bool)
+	for _, b := range pruned {
+		if reachable[b] || !b.Live || reported[b] {
+			continue
+		}
+		reported[b] = true
+		// 条件の続きの case の式ではなく、到達できない最初の文を指摘する
+		for len(b.Nodes) == 0 || b.Kind == cfg.KindSwitchNextCase {
+			if len(succs[b]) == 0 {
+				break
+			}
+			b = succs[b][len(succs[b])-1]
+			if reachable[b] {
+				break
+			}
+		}
+		if len(b.Nodes) > 0 && !reachable[b] {
+			diags = append(diags, newDiagnostic(fset, b.Nodes[0].Pos(), "deadbranch", "unreachable code"))
+		}
+	}
+	return diags
+}
+
+// constantCondition は条件 e (tag があれば tag == e) が定数ならその値を返す
+func constantCondition(info *types.Info, e, tag ast.Expr) (value, ok bool) {
+	v := info.Types[e].Value
+	if v == nil {
+		return false, false
+	}
+	if tag == nil {
+		if v.Kind() != constant.Bool {
+			return false, false
+		}
+		return constant.BoolVal(v), true
+	}
+	tv := info.Types[tag].Value
+	if tv == nil {
+		return false, false
+	}
+	return constant.Compare(tv, token.EQL, v), true
+}
@@ -0,0 +1,48 @@
+package main
+
+import "testing"
+
+func TestCheckDeadBranches(t *testing.T) {
+	src := `package main
+
+import "fmt"
+
+const debug = false
+
+const mode = "prod"
+
+func run(n int) {
+	if debug {
+		fmt.Println("debug")
+	}
+	if n > 0 && true {
+		fmt.Println(n)
+	}
+	switch mode {
+	case "dev":
+		fmt.Println("dev")
+	case "prod":
+		fmt.Println("prod")
+	default:
+		fmt.Println("other")
+	}
+	if true {
+		return
+	}
+	fmt.Println("after")
+}
+
+func main() { run(1) }
+`
+	prog := loadTestProgram(t, map[string]string{"main.go": src})
+	assertLines(t, diagnosticMessages(checkDeadBranches(prog)), []string{
+		"main.go:10: condition debug is always false",
+		"main.go:11: unreachable code",
+		"main.go:17: case \"dev\" never matches constant switch tag mode",
+		"main.go:18: unreachable code",
+		"main.go:19: case \"prod\" always matches constant switch tag mode",
+		"main.go:22: unreachable code",
+		"main.go:24: condition true is always true",
+		"main.go:27: unreachable code",
+	})
+}
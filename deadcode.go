@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"strings"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+func runDeadCode(args []string) error {
+	fs := flag.NewFlagSet("deadcode", flag.ExitOnError)
+	kinds := fs.String("roots", strings.Join(allEntryKinds, ","), "comma-separated entry point kinds used as roots")
+	return runDiagnostics(fs, args, func(prog *Program) ([]Diagnostic, error) {
+		return deadCode(prog, splitList(*kinds)), nil
+	})
+}
+
+// reachableFunctions は roots から、呼び出し (CHA)、関数値としての参照、無名関数をたどって到達できる関数を返す。
+// 関数値の呼び出しは CHA では同じシグネチャの全関数につながるので、参照した時点で到達できるものとして扱う。
+// パッケージ変数の初期化は常に到達できるものとする
+func reachableFunctions(prog *Program, roots []*ssa.Function) map[*ssa.Function]bool {
+	roots = append([]*ssa.Function{}, roots...)
+	for _, pkg := range prog.Packages {
+		// 型の情報のないパッケージは SSA が作られない
+		if spkg := prog.SSA().Package(pkg.Types); spkg != nil && spkg.Func("init") != nil {
+			roots = append(roots, spkg.Func("init"))
+		}
+	}
+	return reachableFrom(prog, roots)
+}
+
+// reachableFrom は reachableFunctions と同じだが、パッケージ変数の初期化を根に加えない
+func reachableFrom(prog *Program, roots []*ssa.Function) map[*ssa.Function]bool {
+	cg := prog.CallGraph()
+	reachable := make(map[*ssa.Function]bool)
+	queue := append([]*ssa.Function{}, roots...)
+	for len(queue) > 0 {
+		fn := queue[0]
+		queue = queue[1:]
+		if fn == nil || reachable[fn] {
+			continue
+		}
+		reachable[fn] = true
+		// インスタンス (Generic[int]) に到達できれば、ジェネリックな元の関数にも到達できる
+		queue = append(queue, fn.Origin())
+		if node := cg.Nodes[fn]; node != nil {
+			for _, e := range node.Out {
+				if e.Site == nil || e.Site.Common().IsInvoke() || e.Site.Common().StaticCallee() != nil {
+					queue = append(queue, e.Callee.Func)
+				}
+			}
+		}
+		queue = append(queue, fn.AnonFuncs...)
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				for _, op := range instr.Operands(nil) {
+					if f, ok := (*op).(*ssa.Function); ok {
+						queue = append(queue, f)
+					}
+				}
+			}
+		}
+	}
+	return reachable
+}
+
+// entryFunctions はエントリポイントのうち kinds に含まれる種類のものを SSA の関数にして返す
+func entryFunctions(prog *Program, kinds []string) []*ssa.Function {
+	var roots []*ssa.Function
+	for _, e := range filterEntryPoints(EntryPoints(prog.Packages), kinds) {
+		if fn := prog.SSA().FuncValue(e.Func); fn != nil {
+			roots = append(roots, fn)
+		}
+	}
+	return roots
+}
+
+// deadCode は kinds の種類のエントリポイントから到達できない関数を指摘する。
+// アセンブリで実装された関数はアセンブリから呼ばれることがあるので指摘しない
+func deadCode(prog *Program, kinds []string) []Diagnostic {
+	reachable := reachableFunctions(prog, entryFunctions(prog, kinds))
+	var diags []Diagnostic
+	for _, fn := range prog.targetFunctions() {
+		if reachable[fn] || fn.Synthetic != "" || fn.Parent() != nil || isAssemblyFunc(fn) {
+			continue
+		}
+		diags = append(diags, newDiagnostic(prog.Fset, fn.Pos(), "deadcode",
+			"%s is unreachable from the entry points", fn.RelString(fn.Pkg.Pkg)))
+	}
+	sortDiagnostics(diags)
+	return diags
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"slices"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// 値が関数の境界を越える経路
+const (
+	flowLocal   = ""        // 同じ関数の中での使用
+	flowArg     = "arg"     // 呼び出しの引数から呼び出し先の引数へ
+	flowReturn  = "return"  // 戻り値から呼び出し元の呼び出しの結果へ
+	flowClosure = "closure" // 無名関数の自由変数へ
+	flowFormat  = "format"  // printf の関数やそのラッパー (Sprintf や Errorf など) の引数から、書式化した結果へ
+)
+
+// DefUse は SSA の値の 1 つの使用
+type DefUse struct {
+	Func  string         `json:"func"`
+	Value string         `json:"value"` // 使われる値 (呼び出し先の引数などに移った後の名前)
+	Instr string         `json:"instr"`
+	Depth int            `json:"depth"`         // たどった関数の境界の数
+	Via   string         `json:"via,omitempty"` // 最後に越えた境界の種類
+	Pos   token.Position `json:"pos"`
+
+	instr ssa.Instruction
+	value ssa.Value
+}
+
+func runDefUse(args []string) error {
+	fs := flag.NewFlagSet("defuse", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output as JSON")
+	fnName := fs.String("func", "", "`function` containing the value (e.g. pkg.Func or (*pkg.T).Method)")
+	valueName := fs.String("value", "", "parameter name or SSA value name (e.g. t3) to start from")
+	depth := fs.Int("depth", 2, "maximum number of function boundaries to follow")
+	fs.Parse(args)
+	if *fnName == "" || *valueName == "" {
+		return errors.New("-func and -value are required")
+	}
+	prog, err := loadProgram(".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	fns := findFunctions(prog, *fnName)
+	if len(fns) == 0 {
+		return notFound("function %s not found", *fnName)
+	}
+	v := ssaValueNamed(fns[0], *valueName)
+	if v == nil {
+		return notFoundAt(prog.Fset.Position(fns[0].Pos()), "value %s not found in %s", *valueName, fns[0].RelString(nil))
+	}
+	uses := prog.DefUse(fns[0], v, *depth)
+	relativizePositions(uses)
+	if *asJSON {
+		return writeJSON(os.Stdout, uses)
+	}
+	return writeDefUses(os.Stdout, uses)
+}
+
+// ssaValueNamed は fn の引数か、名前が name の命令の値を返す
+func ssaValueNamed(fn *ssa.Function, name string) ssa.Value {
+	for _, p := range fn.Params {
+		if p.Name() == name {
+			return p
+		}
+	}
+	for _, fv := range fn.FreeVars {
+		if fv.Name() == name {
+			return fv
+		}
+	}
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			if v, ok := instr.(ssa.Value); ok && v.Name() == name {
+				return v
+			}
+		}
+	}
+	return nil
+}
+
+// DefUse は fn の値 v の使用を列挙する。v が呼び出しの引数になれば呼び出し先の引数の使用を、
+// 戻り値になれば呼び出し元での呼び出しの結果の使用を、無名関数に捕捉されれば自由変数の使用を、
+// depth 個の関数の境界までたどる。ローカル変数に保存された値は変数からの読み出しもたどる。
+// 呼び出し先と呼び出し元はコールグラフ (CHA) から求める。結果を 1 つ返す printf の関数とそのラッパー (printfWrappers) は
+// 呼び出し先に入らず、引数の値がそのまま結果に流れるものとしてたどる
+func (p *Program) DefUse(fn *ssa.Function, v ssa.Value, depth int) []DefUse {
+	cg := p.CallGraph()
+	wrappers := printfWrappers(p, nil)
+	var result []DefUse
+	seen := make(map[ssa.Value]bool)
+	var visit func(fn *ssa.Function, v ssa.Value, d int, via string)
+	visit = func(fn *ssa.Function, v ssa.Value, d int, via string) {
+		if seen[v] || v.Referrers() == nil {
+			return
+		}
+		seen[v] = true
+		for _, instr := range *v.Referrers() {
+			if _, ok := instr.(*ssa.DebugRef); ok {
+				continue
+			}
+			if store, ok := instr.(*ssa.Store); ok && store.Addr == v {
+				continue // 変数への代入は変数の定義
+			}
+			result = append(result, DefUse{
+				Func:  fn.RelString(nil),
+				Value: v.Name(),
+				Instr: instr.String(),
+				Depth: d,
+				Via:   via,
+				Pos:   p.Fset.Position(instr.Pos()),
+				instr: instr,
+				value: v,
+			})
+			cross := d < depth // 関数の境界をさらに越えられる
+			if call := printfCall(instr, v, wrappers); call != nil {
+				visit(fn, call, d, flowFormat)
+				continue
+			}
+			switch instr := instr.(type) {
+			case ssa.CallInstruction:
+				common := instr.Common()
+				node := cg.Nodes[fn]
+				if node == nil || !cross {
+					continue
+				}
+				for _, e := range node.Out {
+					if e.Site != instr || e.Callee.Func.Blocks == nil {
+						continue
+					}
+					callee := e.Callee.Func
+					args := common.Args
+					if common.IsInvoke() {
+						// invoke ではレシーバは Args に含まれない
+						args = append([]ssa.Value{common.Value}, args...)
+					}
+					for i, arg := range args {
+						if arg == v && i < len(callee.Params) {
+							visit(callee, callee.Params[i], d+1, flowArg)
+						}
+					}
+				}
+			case *ssa.Return:
+				node := cg.Nodes[fn]
+				if node == nil || !cross {
+					continue
+				}
+				for i, r := range instr.Results {
+					if r != v {
+						continue
+					}
+					for _, e := range node.In {
+						call, ok := e.Site.(*ssa.Call)
+						if !ok {
+							continue
+						}
+						if len(instr.Results) == 1 {
+							visit(e.Caller.Func, call, d+1, flowReturn)
+						} else if x := tupleExtract(call, i); x != nil {
+							visit(e.Caller.Func, x, d+1, flowReturn)
+						}
+					}
+				}
+			case *ssa.Store:
+				// 無名関数に捕捉される変数などのローカル変数に保存された値は、変数からの読み出しをたどる
+				if alloc, ok := instr.Addr.(*ssa.Alloc); ok {
+					visit(fn, alloc, d, via)
+				}
+			case *ssa.UnOp:
+				switch v.(type) {
+				case *ssa.Alloc, *ssa.FreeVar:
+					if instr.Op == token.MUL {
+						visit(fn, instr, d, via)
+					}
+				}
+			case *ssa.MakeClosure:
+				if !cross {
+					continue
+				}
+				closure := instr.Fn.(*ssa.Function)
+				for i, b := range instr.Bindings {
+					if b == v {
+						visit(closure, closure.FreeVars[i], d+1, flowClosure)
+					}
+				}
+			}
+		}
+	}
+	visit(fn, v, 0, flowLocal)
+	return result
+}
+
+// printfCall は instr で v を引数 (可変長引数の要素を含む) として渡している、結果を 1 つ返す funcs の関数の呼び出しを返す。
+// 可変長引数の要素はインターフェースに変換 (MakeInterface か ChangeInterface) され、配列に保存されてからスライスとして渡される
+func printfCall(instr ssa.Instruction, v ssa.Value, funcs map[string]printfFunc) *ssa.Call {
+	switch instr := instr.(type) {
+	case *ssa.Call:
+		if isPrintfCall(instr, funcs) && slices.Contains(instr.Call.Args, v) {
+			return instr
+		}
+	case *ssa.MakeInterface, *ssa.ChangeInterface:
+		conv := instr.(ssa.Value)
+		for _, ref := range *conv.Referrers() {
+			store, ok := ref.(*ssa.Store)
+			if !ok || store.Val != conv {
+				continue
+			}
+			addr, ok := store.Addr.(*ssa.IndexAddr)
+			if !ok || addr.X.Referrers() == nil {
+				continue
+			}
+			for _, ref := range *addr.X.Referrers() {
+				slice, ok := ref.(*ssa.Slice)
+				if !ok {
+					continue
+				}
+				for _, ref := range *slice.Referrers() {
+					if call, ok := ref.(*ssa.Call); ok && isPrintfCall(call, funcs) && slices.Contains(call.Call.Args, ssa.Value(slice)) {
+						return call
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// isPrintfCall は call が funcs の関数の静的な呼び出しで、結果を 1 つ返すかどうかを返す
+func isPrintfCall(call *ssa.Call, funcs map[string]printfFunc) bool {
+	callee := call.Call.StaticCallee()
+	if callee == nil || callee.Signature.Results().Len() != 1 {
+		return false
+	}
+	obj, ok := callee.Object().(*types.Func)
+	if !ok {
+		return false
+	}
+	_, ok = funcs[obj.FullName()]
+	return ok
+}
+
+func writeDefUses(w io.Writer, uses []DefUse) error {
+	for _, u := range uses {
+		via := ""
+		if u.Via != "" {
+			via = " via " + u.Via
+		}
+		fmt.Fprintf(w, "%s: [%d%s] %s: %s used by %s\n", u.Pos, u.Depth, via, u.Func, u.Value, u.Instr)
+	}
+	return nil
+}
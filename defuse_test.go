@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDefUse(t *testing.T) {
+	src := `package main
+
+import "strings"
+
+func clean(s string) string {
+	return strings.TrimSpace(s)
+}
+
+func parse(input string) (string, error) {
+	name := clean(input)
+	return name, nil
+}
+
+func handle(input string) {
+	name, _ := parse(input)
+	go func() { println(name) }()
+}
+
+func main() { handle(" x ") }
+`
+	prog := loadTestProgram(t, map[string]string{"main.go": src})
+	fns := findFunctions(prog, "example.com/m.handle")
+	if len(fns) == 0 {
+		t.Fatal("handle not found")
+	}
+	var buf bytes.Buffer
+	if err := writeDefUses(&buf, prog.DefUse(fns[0], ssaValueNamed(fns[0], "input"), 2)); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		got = append(got, line[strings.Index(line, "["):])
+	}
+	assertLines(t, got, []string{
+		"[0] example.com/m.handle: input used by parse(input)",
+		"[1 via arg] example.com/m.parse: input used by clean(input)",
+		"[2 via arg] example.com/m.clean: s used by strings.TrimSpace(s)",
+	})
+
+	// 戻り値から呼び出し元へ、さらに無名関数へたどる
+	fns = findFunctions(prog, "example.com/m.parse")
+	buf.Reset()
+	if err := writeDefUses(&buf, prog.DefUse(fns[0], ssaValueNamed(fns[0], "t0"), 2)); err != nil {
+		t.Fatal(err)
+	}
+	got = nil
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		got = append(got, line[strings.Index(line, "["):])
+	}
+	assertLines(t, got, []string{
+		"[0] example.com/m.parse: t0 used by return t0, nil:error",
+		"[1 via return] example.com/m.handle: t2 used by *t0 = t2",
+		"[1 via return] example.com/m.handle: t0 used by make closure handle$1 [t0]",
+		"[2 via closure] example.com/m.handle$1: name used by *name",
+		"[2 via closure] example.com/m.handle$1: t0 used by println(t0)",
+	})
+}
+
+func TestDefUseFormat(t *testing.T) {
+	src := `package main
+
+import "fmt"
+
+func wrapf(format string, args ...any) error {
+	return fmt.Errorf("app: "+format, args...)
+}
+
+func check(id int) error {
+	err := wrapf("bad id %d", id)
+	return fmt.Errorf("check: %w", err)
+}
+
+func main() { println(check(1)) }
+`
+	prog := loadTestProgram(t, map[string]string{"main.go": src})
+	fns := findFunctions(prog, "example.com/m.check")
+	if len(fns) == 0 {
+		t.Fatal("check not found")
+	}
+	var buf bytes.Buffer
+	if err := writeDefUses(&buf, prog.DefUse(fns[0], ssaValueNamed(fns[0], "id"), 0)); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		got = append(got, line[strings.Index(line, "["):])
+	}
+	// printf のラッパーと fmt.Errorf は呼び出し先に入らず、引数から結果へたどる
+	assertLines(t, got, []string{
+		"[0] example.com/m.check: id used by make any <- int (id)",
+		"[0 via format] example.com/m.check: t4 used by change interface any <- error (t4)",
+		"[0 via format] example.com/m.check: t9 used by return t9",
+	})
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"go/ast"
+	"go/types"
+	"regexp"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// Deprecation は "Deprecated: " の段落と、そこから読み取った代わりに使うもの
+type Deprecation struct {
+	Notice      string
+	Replacement string
+}
+
+var (
+	deprecationReplacement = regexp.MustCompile(`\b[Uu]se\s+(\S+?)[.,;:]?(?:\s|$)`)
+	docLink                = regexp.MustCompile(`\[([\w./*]+)\]`)
+)
+
+func newDeprecation(notice string) Deprecation {
+	notice = docLink.ReplaceAllString(notice, "$1") // [os.ReadFile] のような doc リンク
+	d := Deprecation{Notice: notice}
+	if m := deprecationReplacement.FindStringSubmatch(notice); m != nil {
+		d.Replacement = strings.Trim(m[1], "`\"")
+	}
+	return d
+}
+
+// deprecatedIndex は依存パッケージを含む読み込んだ全パッケージから、doc コメントに
+// "Deprecated: " がある宣言 (構造体のフィールドを含む) とパッケージを集める
+func deprecatedIndex(pkgs []*packages.Package) (map[types.Object]Deprecation, map[*types.Package]Deprecation) {
+	objs := make(map[types.Object]Deprecation)
+	deprecatedPkgs := make(map[*types.Package]Deprecation)
+	add := func(info *types.Info, id *ast.Ident, doc *ast.CommentGroup) {
+		if doc == nil {
+			return
+		}
+		if notice, ok := deprecationNotice(doc.Text()); ok {
+			if obj := info.Defs[id]; obj != nil {
+				objs[obj] = newDeprecation(notice)
+			}
+		}
+	}
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		if pkg.TypesInfo == nil {
+			return
+		}
+		for _, file := range pkg.Syntax {
+			if file.Doc != nil {
+				if notice, ok := deprecationNotice(file.Doc.Text()); ok {
+					deprecatedPkgs[pkg.Types] = newDeprecation(notice)
+				}
+			}
+			for _, d := range packageDecls(file) {
+				add(pkg.TypesInfo, d.Name, d.Doc)
+			}
+			ast.Inspect(file, func(n ast.Node) bool {
+				if st, ok := n.(*ast.StructType); ok {
+					for _, field := range st.Fields.List {
+						for _, name := range field.Names {
+							add(pkg.TypesInfo, name, field.Doc)
+						}
+					}
+				}
+				return true
+			})
+		}
+	})
+	return objs, deprecatedPkgs
+}
+
+// checkDeprecated は解析対象のパッケージから、他のパッケージの非推奨の宣言や非推奨のパッケージへの参照を指摘する
+func checkDeprecated(prog *Program) []Diagnostic {
+	objs, deprecatedPkgs := deprecatedIndex(prog.Packages)
+	var diags []Diagnostic
+	for _, pkg := range prog.Packages {
+		for id, obj := range pkg.TypesInfo.Uses {
+			var name string
+			var d Deprecation
+			var ok bool
+			if pn, isPkg := obj.(*types.PkgName); isPkg {
+				name = pn.Imported().Path()
+				d, ok = deprecatedPkgs[pn.Imported()]
+			} else if obj.Pkg() != nil && obj.Pkg() != pkg.Types {
+				name = deprecatedObjectName(obj)
+				d, ok = objs[originObject(obj)]
+			}
+			if !ok {
+				continue
+			}
+			if d.Replacement != "" {
+				diags = append(diags, newDiagnostic(prog.Fset, id.Pos(), "deprecated", "%s is deprecated: use %s", name, d.Replacement))
+			} else {
+				diags = append(diags, newDiagnostic(prog.Fset, id.Pos(), "deprecated", "%s is deprecated: %s", name, d.Notice))
+			}
+		}
+	}
+	sortDiagnostics(diags)
+	return diags
+}
+
+// originObject はジェネリックな型のインスタンスのメソッドやフィールドを、宣言されたものに戻す
+func originObject(obj types.Object) types.Object {
+	switch obj := obj.(type) {
+	case *types.Func:
+		return obj.Origin()
+	case *types.Var:
+		return obj.Origin()
+	}
+	return obj
+}
+
+// deprecatedObjectName は参照しているものを ioutil.ReadFile や (*pkg.T).M のように表示する
+func deprecatedObjectName(obj types.Object) string {
+	if fn, ok := obj.(*types.Func); ok {
+		return fn.FullName()
+	}
+	return obj.Pkg().Path() + "." + obj.Name()
+}
@@ -0,0 +1,50 @@
+package main
+
+import "testing"
+
+var testdata_deprecated = map[string]string{
+	"client/client.go": `package client
+
+type Options struct {
+	// Deprecated: use Timeout.
+	Deadline int
+	Timeout  int
+}
+
+// Dial connects to addr.
+//
+// Deprecated: Use NewClient instead.
+func Dial(addr string, opts Options) {}
+
+// NewClient returns a client.
+func NewClient(addr string) {}
+
+func legacy() { Dial("", Options{}) }
+`,
+	"main.go": `package main
+
+import (
+	"io/ioutil"
+	"strings"
+
+	"example.com/m/client"
+)
+
+func main() {
+	client.Dial("localhost", client.Options{Deadline: 1})
+	ioutil.ReadFile("x")
+	strings.Title("x")
+}
+`,
+}
+
+func TestCheckDeprecated(t *testing.T) {
+	prog := loadTestProgram(t, testdata_deprecated)
+	assertLines(t, diagnosticMessages(checkDeprecated(prog)), []string{
+		"main.go:11: example.com/m/client.Dial is deprecated: use NewClient",
+		"main.go:11: example.com/m/client.Deadline is deprecated: use Timeout",
+		"main.go:12: io/ioutil is deprecated: As of Go 1.16, the same functionality is now provided by package io or package os, and those implementations should be preferred in new code. See the specific function documentation for details.",
+		"main.go:12: io/ioutil.ReadFile is deprecated: As of Go 1.16, this function simply calls os.ReadFile.",
+		"main.go:13: strings.Title is deprecated: use golang.org/x/text/cases",
+	})
+}
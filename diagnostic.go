@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// Diagnostic は解析結果の 1 件の指摘
+type Diagnostic struct {
+	Pos         token.Position `json:"pos"`
+	Category    string         `json:"category"`
+	Severity    string         `json:"severity,omitempty"` // 空ならカテゴリーの既定の重大度 (severityOf)
+	Message     string         `json:"message"`
+	Approximate bool           `json:"approximate,omitempty"` // 構文や型のエラーのあるパッケージの指摘 (型の情報が欠けているので不正確かもしれない)
+	Origin      string         `json:"origin,omitempty"`      // 生成されたファイルの指摘なら、代わりに直すべき生成元 (go:generate と入力ファイル)
+}
+
+func (d Diagnostic) String() string {
+	s := fmt.Sprintf("%s: [%s] %s", d.Pos, d.Category, d.Message)
+	if d.Approximate {
+		s += " (approximate)"
+	}
+	if d.Origin != "" {
+		s += " (generated code; fix the generator input: " + d.Origin + ")"
+	}
+	return s
+}
+
+// newDiagnostic は pos を解決して Diagnostic を作る
+func newDiagnostic(fset *token.FileSet, pos token.Pos, category, format string, args ...interface{}) Diagnostic {
+	return Diagnostic{
+		Pos:      fset.Position(pos),
+		Category: category,
+		Message:  fmt.Sprintf(format, args...),
+	}
+}
+
+// sortDiagnostics はファイル名、行、列、メッセージの順に並べる
+func sortDiagnostics(diags []Diagnostic) {
+	sort.Slice(diags, func(i, j int) bool {
+		a, b := diags[i].Pos, diags[j].Pos
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		if a.Column != b.Column {
+			return a.Column < b.Column
+		}
+		if diags[i].Category != diags[j].Category {
+			return diags[i].Category < diags[j].Category
+		}
+		return diags[i].Message < diags[j].Message
+	})
+}
+
+// writeDiagnostics は指摘をテキストまたは JSON で出力する
+func writeDiagnostics(w io.Writer, diags []Diagnostic, asJSON bool) error {
+	relativizePositions(diags)
+	setSeverities(diags)
+	if asJSON {
+		if diags == nil {
+			diags = []Diagnostic{}
+		}
+		return writeJSON(w, diags)
+	}
+	for _, d := range diags {
+		if _, err := fmt.Fprintln(w, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeDiagnosticsCSV は指摘をヘッダー行付きの CSV で出力する
+func writeDiagnosticsCSV(w io.Writer, diags []Diagnostic) error {
+	relativizePositions(diags)
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"file", "line", "column", "category", "severity", "message"})
+	for _, d := range diags {
+		cw.Write([]string{d.Pos.Filename, strconv.Itoa(d.Pos.Line), strconv.Itoa(d.Pos.Column), d.Category, severityOf(d), d.Message})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeJSON は v をインデント付きの JSON で出力する
+func writeJSON(w io.Writer, v interface{}) error {
+	if outputRoot != "" && v != nil {
+		// 値で渡された構造体の位置も書き換えられるようにコピーする
+		cp := reflect.New(reflect.TypeOf(v))
+		cp.Elem().Set(reflect.ValueOf(v))
+		relativizePositions(cp.Interface())
+		v = cp.Elem().Interface()
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
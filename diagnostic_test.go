@@ -0,0 +1,24 @@
+package main
+
+import (
+	"bytes"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestWriteDiagnosticsCSV(t *testing.T) {
+	var buf bytes.Buffer
+	err := writeDiagnosticsCSV(&buf, []Diagnostic{
+		{Pos: token.Position{Filename: "a.go", Line: 3, Column: 2}, Category: "printf", Message: `"%d" needs an integer, got string`},
+		{Pos: token.Position{Filename: "b.go", Line: 10, Column: 1}, Category: "deadcode", Message: "f is unreachable from the entry points"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertLines(t, strings.Split(strings.TrimSpace(buf.String()), "\n"), []string{
+		"file,line,column,category,severity,message",
+		`a.go,3,2,printf,error,"""%d"" needs an integer, got string"`,
+		"b.go,10,1,deadcode,info,f is unreachable from the entry points",
+	})
+}
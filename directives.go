@@ -0,0 +1,248 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Directive は //go:generate または //go:embed の 1 行
+type Directive struct {
+	Kind    string         `json:"kind"` // generate, embed
+	Args    []string       `json:"args"`
+	Files   []string       `json:"files,omitempty"`   // embed のパターンに一致したファイル (パッケージのディレクトリからの相対パス)
+	Problem string         `json:"problem,omitempty"` // 一致するファイルがない、tools.go にないツールを使っている
+	Pos     token.Position `json:"pos"`
+}
+
+// generateShellCommands は tools.go で管理しない go:generate のコマンド
+var generateShellCommands = map[string]bool{
+	"sh": true, "bash": true, "echo": true, "cp": true, "mv": true, "rm": true, "mkdir": true, "touch": true, "make": true,
+}
+
+func runDirectives(args []string) error {
+	flags := flag.NewFlagSet("directives", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "output as JSON")
+	problems := flags.Bool("problems", false, "only list directives with problems")
+	flags.Parse(args)
+	dirs := flags.Args()
+	if len(dirs) == 0 {
+		dirs = []string{"."}
+	}
+	var directives []Directive
+	for _, dir := range dirs {
+		ds, err := goDirectives(dir)
+		if err != nil {
+			return err
+		}
+		directives = append(directives, ds...)
+	}
+	if *problems {
+		var filtered []Directive
+		for _, d := range directives {
+			if d.Problem != "" {
+				filtered = append(filtered, d)
+			}
+		}
+		directives = filtered
+	}
+	relativizePositions(directives)
+	if *asJSON {
+		return writeJSON(os.Stdout, directives)
+	}
+	return writeDirectives(os.Stdout, directives)
+}
+
+// goDirectives は root 以下の Go ファイルのコメントから go:generate と go:embed を集める。
+// 埋め込むファイルが見つからないパッケージは go/packages で読み込めないので、ファイルを直接構文解析する
+func goDirectives(root string) ([]Directive, error) {
+	fset := token.NewFileSet()
+	var files []*ast.File
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if p != root && (name == "testdata" || name == "vendor" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(name, ".go") {
+			return nil
+		}
+		f, err := parser.ParseFile(fset, p, nil, parser.ParseComments)
+		if err != nil {
+			return err
+		}
+		files = append(files, f)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tools := toolImports(files)
+	var directives []Directive
+	for _, f := range files {
+		dir := filepath.Dir(fset.Position(f.Pos()).Filename)
+		for _, group := range f.Comments {
+			for _, c := range group.List {
+				text, ok := strings.CutPrefix(c.Text, "//go:")
+				if !ok {
+					continue
+				}
+				kind, rest, _ := strings.Cut(text, " ")
+				d := Directive{Kind: kind, Pos: fset.Position(c.Pos())}
+				switch kind {
+				case "generate":
+					d.Args = strings.Fields(rest)
+					if tool := generateTool(d.Args); tool != "" && !tools[tool] {
+						d.Problem = fmt.Sprintf("tool %s is not imported in tools.go", tool)
+					}
+				case "embed":
+					d.Args = embedPatterns(rest)
+					for _, pattern := range d.Args {
+						matches := embedMatches(dir, pattern)
+						if len(matches) == 0 {
+							d.Problem = fmt.Sprintf("pattern %s matches no files", pattern)
+						}
+						d.Files = append(d.Files, matches...)
+					}
+				default:
+					continue
+				}
+				directives = append(directives, d)
+			}
+		}
+	}
+	return directives, nil
+}
+
+// toolImports は tools.go (//go:build tools) で import されているパッケージを、
+// パスとその最後の要素 (コマンド名) の両方をキーにして返す
+func toolImports(files []*ast.File) map[string]bool {
+	tools := make(map[string]bool)
+	for _, f := range files {
+		isTools := false
+		for _, group := range f.Comments {
+			for _, c := range group.List {
+				if c.Text == "//go:build tools" || c.Text == "// +build tools" {
+					isTools = true
+				}
+			}
+		}
+		if !isTools {
+			continue
+		}
+		for _, spec := range f.Imports {
+			if p, err := strconv.Unquote(spec.Path.Value); err == nil {
+				tools[p] = true
+				tools[path.Base(p)] = true
+			}
+		}
+	}
+	return tools
+}
+
+// generateTool は go:generate のコマンドが使う Go のツールを返す。
+// go run path@version のようにバージョンを指定したものやローカルのパッケージ、シェルのコマンドは対象にしない
+func generateTool(args []string) string {
+	if len(args) == 0 || generateShellCommands[args[0]] || strings.HasPrefix(args[0], "$") {
+		return ""
+	}
+	if args[0] != "go" {
+		return args[0]
+	}
+	if len(args) < 3 || args[1] != "run" {
+		return ""
+	}
+	for _, arg := range args[2:] {
+		if strings.HasPrefix(arg, "-") {
+			continue
+		}
+		if strings.Contains(arg, "@") || strings.HasPrefix(arg, ".") || strings.HasSuffix(arg, ".go") {
+			return ""
+		}
+		return arg
+	}
+	return ""
+}
+
+// embedPatterns は go:embed の引数を分割する。パターンは "..." や `...` で囲まれていてもよい
+func embedPatterns(s string) []string {
+	var patterns []string
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		end := strings.IndexAny(s, " \t")
+		if s[0] == '"' || s[0] == '`' {
+			end = strings.IndexByte(s[1:], s[0]) + 2
+		}
+		if end <= 0 || end > len(s) {
+			end = len(s)
+		}
+		p := s[:end]
+		if unquoted, err := strconv.Unquote(p); err == nil {
+			p = unquoted
+		}
+		patterns = append(patterns, p)
+		s = s[end:]
+	}
+	return patterns
+}
+
+// embedMatches は dir からの相対パターンに一致するファイルを返す。ディレクトリに一致した場合は
+// その中のファイル (all: がなければ . と _ で始まるものを除く) を再帰的に含める
+func embedMatches(dir, pattern string) []string {
+	all := false
+	if p, ok := strings.CutPrefix(pattern, "all:"); ok {
+		pattern, all = p, true
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, filepath.FromSlash(pattern)))
+	if err != nil {
+		return nil
+	}
+	var files []string
+	for _, m := range matches {
+		filepath.WalkDir(m, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if p != m && !all && (strings.HasPrefix(d.Name(), ".") || strings.HasPrefix(d.Name(), "_")) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !d.IsDir() {
+				rel, _ := filepath.Rel(dir, p)
+				files = append(files, filepath.ToSlash(rel))
+			}
+			return nil
+		})
+	}
+	return files
+}
+
+func writeDirectives(w io.Writer, directives []Directive) error {
+	for _, d := range directives {
+		detail := ""
+		switch {
+		case d.Problem != "":
+			detail = ": " + d.Problem
+		case d.Kind == "embed":
+			detail = fmt.Sprintf(" (%d files)", len(d.Files))
+		}
+		fmt.Fprintf(w, "%s: go:%s %s%s\n", d.Pos, d.Kind, strings.Join(d.Args, " "), detail)
+	}
+	return nil
+}
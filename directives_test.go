@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestGoDirectives(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"tools.go": `//go:build tools
+
+package tools
+
+import _ "golang.org/x/tools/cmd/stringer"
+`,
+		"color.go": `package main
+
+import "embed"
+
+//go:generate stringer -type=Color
+//go:generate mockgen -source=color.go -destination=mock.go
+//go:generate go run github.com/a/gen@v1.0.0 -out x.go
+//go:generate go run ./internal/gen
+type Color int
+
+//go:embed static
+var static embed.FS
+
+//go:embed "templates/*.tmpl" missing.txt
+var templates embed.FS
+`,
+		"static/index.html":    "<html></html>",
+		"static/.hidden":       "",
+		"templates/a.tmpl":     "a",
+		"templates/b.tmpl":     "b",
+		"testdata/skip/x.go":   "package skip\n\n//go:generate skipped\n",
+		"internal/gen/main.go": "package main\n\nfunc main() {}\n",
+	})
+	directives, err := goDirectives(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := writeDirectives(&buf, directives); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		got = append(got, strings.TrimPrefix(line, dir+"/"))
+	}
+	assertLines(t, got, []string{
+		"color.go:5:1: go:generate stringer -type=Color",
+		"color.go:6:1: go:generate mockgen -source=color.go -destination=mock.go: tool mockgen is not imported in tools.go",
+		"color.go:7:1: go:generate go run github.com/a/gen@v1.0.0 -out x.go",
+		"color.go:8:1: go:generate go run ./internal/gen",
+		"color.go:11:1: go:embed static (1 files)",
+		"color.go:14:1: go:embed templates/*.tmpl missing.txt: pattern missing.txt matches no files",
+	})
+	if files := directives[5].Files; strings.Join(files, ",") != "templates/a.tmpl,templates/b.tmpl" {
+		t.Errorf("embedded files = %v", files)
+	}
+}
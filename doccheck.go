@@ -0,0 +1,127 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// docDecl はパッケージレベルで宣言された名前と、その doc コメント
+type docDecl struct {
+	Kind  string // function, method, type, var, const
+	Name  *ast.Ident
+	Doc   *ast.CommentGroup
+	Group bool // Doc が var ( ... ) などのグループ全体の doc
+}
+
+// packageDecls はファイルのパッケージレベルの宣言を順にたどる。
+// グループ化された var, const は個々の doc がなければグループの doc を使う
+func packageDecls(file *ast.File) []docDecl {
+	var decls []docDecl
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			kind := "function"
+			if d.Recv != nil {
+				kind = "method"
+			}
+			decls = append(decls, docDecl{Kind: kind, Name: d.Name, Doc: d.Doc})
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					doc := s.Doc
+					if doc == nil && d.Lparen == token.NoPos {
+						doc = d.Doc
+					}
+					decls = append(decls, docDecl{Kind: "type", Name: s.Name, Doc: doc})
+				case *ast.ValueSpec:
+					doc, group := s.Doc, false
+					if doc == nil {
+						doc, group = d.Doc, d.Lparen != token.NoPos || len(s.Names) > 1
+					}
+					for _, name := range s.Names {
+						decls = append(decls, docDecl{Kind: d.Tok.String(), Name: name, Doc: doc, Group: group})
+					}
+				}
+			}
+		}
+	}
+	return decls
+}
+
+// checkDocs はエクスポートされた名前の doc コメントがないもの、名前で始まっていないもの、
+// Deprecated: の書き方が正しくないものを指摘する (package main は対象にしない)
+func checkDocs(prog *Program) []Diagnostic {
+	var diags []Diagnostic
+	for _, pkg := range prog.Packages {
+		if pkg.Name == "main" {
+			continue
+		}
+		for _, file := range pkg.Syntax {
+			for _, d := range packageDecls(file) {
+				if !d.Name.IsExported() {
+					continue
+				}
+				if fn, ok := pkg.TypesInfo.Defs[d.Name].(*types.Func); ok && !receiverExported(fn) {
+					continue
+				}
+				name := d.Name.Name
+				if d.Doc == nil {
+					diags = append(diags, newDiagnostic(prog.Fset, d.Name.Pos(), "doc",
+						"exported %s %s should have a doc comment", d.Kind, name))
+					continue
+				}
+				text := d.Doc.Text()
+				if !d.Group && !docStartsWith(text, name) {
+					diags = append(diags, newDiagnostic(prog.Fset, d.Doc.Pos(), "doc",
+						"doc comment for %s should start with %q", name, name+" "))
+				}
+				if malformedDeprecation(text) {
+					diags = append(diags, newDiagnostic(prog.Fset, d.Doc.Pos(), "doc",
+						"deprecation notice for %s should be a paragraph starting with \"Deprecated: \"", name))
+				}
+			}
+		}
+	}
+	sortDiagnostics(diags)
+	return diags
+}
+
+// docStartsWith は doc コメントが name (冠詞 A, An, The を前においてもよい) で始まっているかどうかを返す
+func docStartsWith(text, name string) bool {
+	for _, article := range []string{"", "A ", "An ", "The "} {
+		if rest, ok := strings.CutPrefix(text, article+name); ok && (rest == "" || !isIdentRune(rest[0])) {
+			return true
+		}
+	}
+	return false
+}
+
+func isIdentRune(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+// deprecationNotice は doc コメントの "Deprecated: " で始まる段落を返す
+func deprecationNotice(text string) (string, bool) {
+	for _, para := range strings.Split(text, "\n\n") {
+		if notice, ok := strings.CutPrefix(para, "Deprecated: "); ok {
+			return strings.Join(strings.Fields(notice), " "), true
+		}
+	}
+	return "", false
+}
+
+// malformedDeprecation は Deprecated の印があるのに、段落の先頭の "Deprecated: " になっていないかどうかを返す
+func malformedDeprecation(text string) bool {
+	if _, ok := deprecationNotice(text); ok {
+		return false
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.ToLower(strings.TrimSpace(line)), "deprecated") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+var testdata_doccheck = `package lib
+
+// API のクライアント
+type Client struct{}
+
+// Do sends a request.
+func (c *Client) Do() {}
+
+func (c *Client) Close() {}
+
+type internal struct{}
+
+func (internal) Exported() {}
+
+// Errors returned by the client.
+var (
+	ErrTimeout = errTimeout()
+	ErrClosed  = errTimeout()
+)
+
+// MaxRetries is the default number of retries.
+const MaxRetries = 3
+
+// A Config holds options.
+type Config struct{}
+
+// OldDo sends a request.
+//
+// deprecated: use Do instead.
+func OldDo() {}
+
+// NewClient returns a client.
+//
+// Deprecated: use Client{} directly.
+func NewClient() *Client { return nil }
+
+func errTimeout() error { return nil }
+`
+
+func TestCheckDocs(t *testing.T) {
+	prog := loadTestProgram(t, map[string]string{
+		"lib/lib.go": testdata_doccheck,
+		"main.go":    "package main\n\nfunc Exported() {}\n\nfunc main() {}\n",
+	})
+	assertLines(t, diagnosticMessages(checkDocs(prog)), []string{
+		"lib.go:3: doc comment for Client should start with \"Client \"",
+		"lib.go:9: exported method Close should have a doc comment",
+		"lib.go:27: deprecation notice for OldDo should be a paragraph starting with \"Deprecated: \"",
+	})
+}
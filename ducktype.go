@@ -0,0 +1,175 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// あと少しでインターフェースを実装する理由
+const (
+	nearMissing   = "missing"   // メソッドが 1 つ足りない
+	nearSignature = "signature" // メソッドが 1 つだけシグネチャが違う
+	nearPointer   = "pointer"   // ポインタ型なら実装している
+)
+
+// NearImpl はインターフェースをあと少しで実装する具象型
+type NearImpl struct {
+	Type      string         `json:"type"`
+	Interface string         `json:"interface"`
+	Kind      string         `json:"kind"`
+	Method    string         `json:"method,omitempty"`
+	Have      string         `json:"have,omitempty"` // Kind が signature のときの型のメソッドのシグネチャ
+	Want      string         `json:"want,omitempty"` // Kind が missing か signature のときのインターフェースのメソッドのシグネチャ
+	Pos       token.Position `json:"pos"`
+}
+
+func runNearImpl(args []string) error {
+	fs := flag.NewFlagSet("nearimpl", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output as JSON")
+	std := fs.Bool("std", true, "also compare against exported interfaces of imported standard library packages")
+	fs.Parse(args)
+	prog, err := loadProgram(".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	impls := nearImplementations(prog, *std)
+	relativizePositions(impls)
+	if *asJSON {
+		return writeJSON(os.Stdout, impls)
+	}
+	return writeNearImpls(os.Stdout, impls)
+}
+
+// candidateInterface は比較するインターフェースと、それが解析対象のパッケージで宣言されているかどうか
+type candidateInterface struct {
+	name  *types.TypeName
+	local bool
+}
+
+// candidateInterfaces は解析対象のパッケージのインターフェースと、std なら読み込んだ標準ライブラリの
+// エクスポートされたインターフェース (internal を除く) を返す
+func candidateInterfaces(prog *Program, std bool) []candidateInterface {
+	var ifaces []candidateInterface
+	for _, pkg := range prog.Packages {
+		scope := pkg.Types.Scope()
+		for _, name := range scope.Names() {
+			if tn, ok := scope.Lookup(name).(*types.TypeName); ok && !tn.IsAlias() && types.IsInterface(tn.Type()) {
+				ifaces = append(ifaces, candidateInterface{tn, true})
+			}
+		}
+	}
+	if std {
+		packages.Visit(prog.Packages, nil, func(pkg *packages.Package) {
+			if !isStdPackage(pkg.PkgPath) || strings.Contains(pkg.PkgPath, "internal") {
+				return
+			}
+			scope := pkg.Types.Scope()
+			for _, name := range scope.Names() {
+				if tn, ok := scope.Lookup(name).(*types.TypeName); ok && tn.Exported() && types.IsInterface(tn.Type()) {
+					ifaces = append(ifaces, candidateInterface{tn, false})
+				}
+			}
+		})
+	}
+	return ifaces
+}
+
+// nearImplementations は解析対象のパッケージの具象型ごとに、メソッドが 1 つ足りないか 1 つだけシグネチャが違うために
+// 実装していないインターフェースを探す。メソッドが 1 つのインターフェースは候補が多すぎるので除く。
+// ポインタ型なら実装しているものは、解析対象のパッケージのインターフェースだけを報告する
+func nearImplementations(prog *Program, std bool) []NearImpl {
+	var named []*types.TypeName
+	for _, pkg := range prog.Packages {
+		scope := pkg.Types.Scope()
+		for _, name := range scope.Names() {
+			tn, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || tn.IsAlias() || types.IsInterface(tn.Type()) {
+				continue
+			}
+			if t, ok := tn.Type().(*types.Named); ok && t.TypeParams() == nil {
+				named = append(named, tn)
+			}
+		}
+	}
+	ifaces := candidateInterfaces(prog, std)
+	var result []NearImpl
+	for _, tn := range named {
+		for _, c := range ifaces {
+			iface := c.name.Type().Underlying().(*types.Interface)
+			if !iface.IsMethodSet() || iface.NumMethods() == 0 || types.Implements(tn.Type(), iface) {
+				continue
+			}
+			near := NearImpl{
+				Type:      types.TypeString(tn.Type(), nil),
+				Interface: types.TypeString(c.name.Type(), nil),
+				Pos:       prog.Fset.Position(tn.Pos()),
+			}
+			ptr := types.NewPointer(tn.Type())
+			if types.Implements(ptr, iface) {
+				if c.local {
+					near.Kind = nearPointer
+					result = append(result, near)
+				}
+				continue
+			}
+			if iface.NumMethods() < 2 {
+				continue
+			}
+			misses := 0
+			for i := 0; i < iface.NumMethods(); i++ {
+				m := iface.Method(i)
+				obj, _, _ := types.LookupFieldOrMethod(ptr, false, m.Pkg(), m.Name())
+				f, ok := obj.(*types.Func)
+				switch {
+				case !ok:
+					misses++
+					near.Kind, near.Method, near.Have = nearMissing, m.Name(), ""
+				case !types.Identical(f.Type(), m.Type()):
+					misses++
+					near.Kind, near.Method, near.Have = nearSignature, m.Name(), signatureString(f.Type())
+				default:
+					continue
+				}
+				near.Want = signatureString(m.Type())
+			}
+			if misses == 1 {
+				result = append(result, near)
+			}
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Type != result[j].Type {
+			return result[i].Type < result[j].Type
+		}
+		return result[i].Interface < result[j].Interface
+	})
+	return result
+}
+
+// signatureString は func を除いたシグネチャを返す
+func signatureString(t types.Type) string {
+	return strings.TrimPrefix(types.TypeString(t, nil), "func")
+}
+
+func writeNearImpls(w io.Writer, impls []NearImpl) error {
+	for _, n := range impls {
+		fmt.Fprintf(w, "%s: %s nearly implements %s: ", n.Pos, n.Type, n.Interface)
+		switch n.Kind {
+		case nearMissing:
+			fmt.Fprintf(w, "missing method %s%s\n", n.Method, n.Want)
+		case nearSignature:
+			fmt.Fprintf(w, "method %s has signature %s, want %s\n", n.Method, n.Have, n.Want)
+		case nearPointer:
+			fmt.Fprintf(w, "methods have pointer receivers; use *%s\n", n.Type)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestNearImplementations(t *testing.T) {
+	src := `package main
+
+import "io"
+
+type Store interface {
+	Get(key string) string
+	Put(key, value string)
+}
+
+type mem struct{}
+
+func (*mem) Get(key string) string { return "" }
+func (*mem) Put(key, value string) {}
+
+type cache struct{}
+
+func (cache) Get(key string) string { return "" }
+
+type buffer struct{}
+
+func (buffer) Read(p []byte) (int, error)  { return 0, nil }
+func (buffer) Close() int                  { return 0 }
+func (buffer) Write(p []byte) (int, error) { return 0, nil }
+
+var _ io.Reader
+
+func main() {}
+`
+	prog := loadTestProgram(t, map[string]string{"main.go": src})
+	var buf bytes.Buffer
+	if err := writeNearImpls(&buf, nearImplementations(prog, true)); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		got = append(got, line[strings.Index(line, "main.go:"):])
+	}
+	assertLines(t, got, []string{
+		"main.go:19:6: example.com/m.buffer nearly implements io.ReadCloser: method Close has signature () int, want () error",
+		"main.go:19:6: example.com/m.buffer nearly implements io.ReadSeeker: missing method Seek(offset int64, whence int) (int64, error)",
+		"main.go:19:6: example.com/m.buffer nearly implements io.ReadWriteCloser: method Close has signature () int, want () error",
+		"main.go:19:6: example.com/m.buffer nearly implements io.ReadWriteSeeker: missing method Seek(offset int64, whence int) (int64, error)",
+		"main.go:19:6: example.com/m.buffer nearly implements io.WriteCloser: method Close has signature () int, want () error",
+		"main.go:19:6: example.com/m.buffer nearly implements io.WriteSeeker: missing method Seek(offset int64, whence int) (int64, error)",
+		"main.go:15:6: example.com/m.cache nearly implements example.com/m.Store: missing method Put(key string, value string)",
+		"main.go:10:6: example.com/m.mem nearly implements example.com/m.Store: methods have pointer receivers; use *example.com/m.mem",
+	})
+}
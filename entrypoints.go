@@ -0,0 +1,227 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// エントリポイントの種類
+const (
+	entryMain     = "main"
+	entryInit     = "init"
+	entryTestMain = "testmain"
+	entryTest     = "test"
+	entryExported = "exported"
+	entryHTTP     = "http"
+	entryGRPC     = "grpc"
+)
+
+var allEntryKinds = []string{entryMain, entryInit, entryTestMain, entryTest, entryExported, entryHTTP, entryGRPC}
+
+// EntryPoint はプログラムの外から呼び出される関数
+type EntryPoint struct {
+	Kind   string         `json:"kind"`
+	Func   *types.Func    `json:"-"`
+	Name   string         `json:"name"`
+	Detail string         `json:"detail,omitempty"` // HTTP のパターンや gRPC のメソッド名
+	Pos    token.Position `json:"pos"`              // 宣言または登録している位置
+}
+
+// httpRegisterFuncs はハンドラを登録する関数と、ハンドラの引数の位置
+var httpRegisterFuncs = map[string]int{
+	"net/http.Handle":                 1,
+	"net/http.HandleFunc":             1,
+	"(*net/http.ServeMux).Handle":     1,
+	"(*net/http.ServeMux).HandleFunc": 1,
+}
+
+func runEntryPoints(args []string) error {
+	fs := flag.NewFlagSet("entrypoints", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output as JSON")
+	kinds := fs.String("kinds", strings.Join(allEntryKinds, ","), "comma-separated entry point kinds to list")
+	fs.Parse(args)
+	prog, err := loadProgramWith(loadOptions{Tests: true}, ".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	entries := filterEntryPoints(EntryPoints(prog.Packages), splitList(*kinds))
+	if *asJSON {
+		return writeJSON(os.Stdout, entries)
+	}
+	return writeEntryPoints(os.Stdout, entries)
+}
+
+// EntryPoints は main 関数、init 関数、TestMain とテスト関数、エクスポートされた関数、
+// HTTP ハンドラと gRPC サービスの実装として登録されている関数を返す
+func EntryPoints(pkgs []*packages.Package) []EntryPoint {
+	var entries []EntryPoint
+	names := grpcServiceNames(pkgs)
+	add := func(kind string, fn *types.Func, detail string, pos token.Position) {
+		entries = append(entries, EntryPoint{Kind: kind, Func: fn, Name: fn.FullName(), Detail: detail, Pos: pos})
+	}
+	for _, pkg := range pkgs {
+		isMain := pkg.Name == "main"
+		for _, file := range pkg.Syntax {
+			isTest := strings.HasSuffix(pkg.Fset.Position(file.Pos()).Filename, "_test.go")
+			for _, decl := range file.Decls {
+				fd, ok := decl.(*ast.FuncDecl)
+				if !ok {
+					continue
+				}
+				fn, ok := pkg.TypesInfo.Defs[fd.Name].(*types.Func)
+				if !ok {
+					continue
+				}
+				pos := pkg.Fset.Position(fd.Pos())
+				name := fd.Name.Name
+				switch {
+				case fd.Recv == nil && isMain && name == "main":
+					add(entryMain, fn, "", pos)
+				case fd.Recv == nil && name == "init":
+					add(entryInit, fn, "", pos)
+				case fd.Recv == nil && isTest && name == "TestMain":
+					add(entryTestMain, fn, "", pos)
+				case fd.Recv == nil && isTest && isTestFuncName(name):
+					add(entryTest, fn, "", pos)
+				case !isMain && !isTest && fn.Exported() && receiverExported(fn):
+					add(entryExported, fn, "", pos)
+				}
+			}
+		}
+	}
+	for _, site := range CallSites(pkgs) {
+		for _, e := range handlerRegistrations(site, names) {
+			add(e.Kind, e.Func, e.Detail, site.Pos)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Kind != entries[j].Kind {
+			return kindIndex(entries[i].Kind) < kindIndex(entries[j].Kind)
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	return entries
+}
+
+func kindIndex(kind string) int {
+	for i, k := range allEntryKinds {
+		if k == kind {
+			return i
+		}
+	}
+	return len(allEntryKinds)
+}
+
+// isTestFuncName は go test が呼び出す関数名かどうかを返す
+func isTestFuncName(name string) bool {
+	for _, prefix := range []string{"Test", "Benchmark", "Fuzz", "Example"} {
+		if rest, ok := strings.CutPrefix(name, prefix); ok && (rest == "" || !('a' <= rest[0] && rest[0] <= 'z')) {
+			return true
+		}
+	}
+	return false
+}
+
+// receiverExported はメソッドであればレシーバの型がエクスポートされているかどうかを返す
+func receiverExported(fn *types.Func) bool {
+	recv := fn.Type().(*types.Signature).Recv()
+	if recv == nil {
+		return true
+	}
+	t := recv.Type()
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	named, ok := t.(*types.Named)
+	return ok && named.Obj().Exported()
+}
+
+// handlerRegistrations は site が HTTP ハンドラや gRPC サービスの登録であれば、登録される関数を返す
+func handlerRegistrations(site *CallSite, names map[string]string) []EntryPoint {
+	pkg, call, callee := site.Pkg, site.Call, site.Func()
+	if callee == nil {
+		return nil
+	}
+	if idx, ok := httpRegisterFuncs[callee.FullName()]; ok && idx < len(call.Args) {
+		pattern := ""
+		if tv := pkg.TypesInfo.Types[call.Args[0]]; tv.Value != nil && tv.Value.Kind() == constant.String {
+			pattern = constant.StringVal(tv.Value)
+		}
+		if fn := handlerFunc(pkg.TypesInfo, call.Args[idx]); fn != nil {
+			return []EntryPoint{{Kind: entryHTTP, Func: fn, Detail: pattern}}
+		}
+		return nil
+	}
+
+	// 生成コードの RegisterXxxServer(s, impl) は impl の XxxServer インターフェースのメソッドを公開する
+	var entries []EntryPoint
+	for _, m := range grpcRegistration(site, names) {
+		entries = append(entries, EntryPoint{Kind: entryGRPC, Func: m.Func, Detail: m.Service + "/" + m.Method})
+	}
+	return entries
+}
+
+// handlerFunc は HTTP ハンドラとして渡された式から実際に呼び出される関数を返す
+func handlerFunc(info *types.Info, expr ast.Expr) *types.Func {
+	expr = ast.Unparen(expr)
+	// http.HandlerFunc(f) のような型変換
+	if call, ok := expr.(*ast.CallExpr); ok && len(call.Args) == 1 {
+		if tv, ok := info.Types[call.Fun]; ok && tv.IsType() {
+			return handlerFunc(info, call.Args[0])
+		}
+	}
+	switch e := expr.(type) {
+	case *ast.Ident:
+		if fn, ok := info.Uses[e].(*types.Func); ok {
+			return fn
+		}
+	case *ast.SelectorExpr:
+		if fn, ok := info.Uses[e.Sel].(*types.Func); ok {
+			return fn
+		}
+	}
+	// http.Handler を実装した値なら ServeHTTP
+	if t := info.TypeOf(expr); t != nil {
+		obj, _, _ := types.LookupFieldOrMethod(t, true, nil, "ServeHTTP")
+		if fn, ok := obj.(*types.Func); ok {
+			return fn
+		}
+	}
+	return nil
+}
+
+// filterEntryPoints は kinds に含まれる種類のエントリポイントだけを返す
+func filterEntryPoints(entries []EntryPoint, kinds []string) []EntryPoint {
+	want := make(map[string]bool)
+	for _, k := range kinds {
+		want[k] = true
+	}
+	var result []EntryPoint
+	for _, e := range entries {
+		if want[e.Kind] {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+func writeEntryPoints(w io.Writer, entries []EntryPoint) error {
+	for _, e := range entries {
+		if e.Detail != "" {
+			fmt.Fprintf(w, "%-9s %s (%s)\n", e.Kind, e.Name, e.Detail)
+		} else {
+			fmt.Fprintf(w, "%-9s %s\n", e.Kind, e.Name)
+		}
+	}
+	return nil
+}
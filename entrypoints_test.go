@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var testdata_entrypoints = map[string]string{
+	"main.go": `package main
+
+import (
+	"net/http"
+
+	"example.com/m/greeter"
+)
+
+type statusHandler struct{}
+
+func (statusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {}
+
+func users(w http.ResponseWriter, r *http.Request) {}
+
+func health(w http.ResponseWriter, r *http.Request) {}
+
+func unused() {}
+
+type server struct{ greeter.UnimplementedGreeterServer }
+
+func (server) SayHello(name string) string { return "hello " + name }
+
+func main() {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users", users)
+	mux.Handle("/health", http.HandlerFunc(health))
+	http.Handle("/status", statusHandler{})
+	greeter.RegisterGreeterServer(&greeter.Server{}, server{})
+}
+`,
+	"main_test.go": `package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) { os.Exit(m.Run()) }
+
+func TestUsers(t *testing.T) { helper() }
+
+func helper() {}
+`,
+	"greeter/greeter.go": `package greeter
+
+type Server struct{ impls []GreeterServer }
+
+type GreeterServer interface {
+	SayHello(name string) string
+	mustEmbedUnimplementedGreeterServer()
+}
+
+type UnimplementedGreeterServer struct{}
+
+func (UnimplementedGreeterServer) mustEmbedUnimplementedGreeterServer() {}
+
+func RegisterGreeterServer(s *Server, srv GreeterServer) {
+	s.impls = append(s.impls, srv)
+}
+
+func (s *Server) Serve() error { return nil }
+
+func internalOnly() {}
+`,
+}
+
+func TestEntryPoints(t *testing.T) {
+	prog, err := loadProgramWith(loadOptions{Tests: true}, writeModule(t, testdata_entrypoints), "./...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := writeEntryPoints(&buf, EntryPoints(prog.Packages)); err != nil {
+		t.Fatal(err)
+	}
+	assertLines(t, strings.Split(strings.TrimSpace(buf.String()), "\n"), []string{
+		"main      example.com/m.main",
+		"testmain  example.com/m.TestMain",
+		"test      example.com/m.TestUsers",
+		"exported  (*example.com/m/greeter.Server).Serve",
+		"exported  example.com/m/greeter.RegisterGreeterServer",
+		"http      (example.com/m.statusHandler).ServeHTTP (/status)",
+		"http      example.com/m.health (/health)",
+		"http      example.com/m.users (/users)",
+		"grpc      (example.com/m.server).SayHello (Greeter/SayHello)",
+	})
+}
+
+func TestDeadCode(t *testing.T) {
+	dir := writeModule(t, testdata_entrypoints)
+	prog, err := loadProgramWith(loadOptions{Tests: true}, dir, "./...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertLines(t, diagnosticMessages(deadCode(prog, allEntryKinds)), []string{
+		"greeter.go:12: (UnimplementedGreeterServer).mustEmbedUnimplementedGreeterServer is unreachable from the entry points",
+		"greeter.go:20: internalOnly is unreachable from the entry points",
+		"main.go:17: unused is unreachable from the entry points",
+	})
+
+	// main だけをルートにすると、ライブラリの関数や、サーバーを起動していないのでハンドラにも到達できない
+	prog, err = loadProgram(dir, "./...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertLines(t, diagnosticMessages(deadCode(prog, []string{entryMain})), []string{
+		"greeter.go:12: (UnimplementedGreeterServer).mustEmbedUnimplementedGreeterServer is unreachable from the entry points",
+		"greeter.go:18: (*Server).Serve is unreachable from the entry points",
+		"greeter.go:20: internalOnly is unreachable from the entry points",
+		"main.go:11: (statusHandler).ServeHTTP is unreachable from the entry points",
+		"main.go:17: unused is unreachable from the entry points",
+		"main.go:21: (server).SayHello is unreachable from the entry points",
+	})
+}
+
+func TestDeadCodeGenerics(t *testing.T) {
+	prog := loadTestProgram(t, map[string]string{"main.go": `package main
+
+type Box[T any] struct{ v T }
+
+func (b Box[T]) Get() T { return b.v }
+
+func Generic[T any](v T) T { return v }
+
+func unusedGeneric[T any](v T) {}
+
+func main() {
+	println(Generic(1))
+	println(Box[int]{}.Get())
+}
+`})
+	assertLines(t, diagnosticMessages(deadCode(prog, []string{entryMain})), []string{
+		"main.go:9: unusedGeneric is unreachable from the entry points",
+	})
+}
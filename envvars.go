@@ -0,0 +1,217 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/constant"
+	"go/token"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// EnvVar はプログラムが読む環境変数 (viper の場合は設定キー)
+type EnvVar struct {
+	Name    string         `json:"name"`
+	Source  string         `json:"source"` // os.Getenv, os.LookupEnv, viper など
+	Default string         `json:"default,omitempty"`
+	Package string         `json:"package"`
+	Func    string         `json:"func"`
+	Pos     token.Position `json:"pos"`
+}
+
+func runEnvVars(args []string) error {
+	fs := flag.NewFlagSet("envvars", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output as JSON")
+	fs.Parse(args)
+	prog, err := loadProgram(".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	vars := envVars(prog)
+	if *asJSON {
+		return writeJSON(os.Stdout, vars)
+	}
+	return writeEnvVars(os.Stdout, vars)
+}
+
+// envSource は環境変数や設定を読む関数であれば、その種類を返す
+func envSource(name string) string {
+	switch name {
+	case "os.Getenv", "os.LookupEnv", "syscall.Getenv":
+		return strings.TrimPrefix(name, "syscall.")
+	}
+	if strings.HasPrefix(name, "github.com/spf13/viper.Get") || strings.HasPrefix(name, "(*github.com/spf13/viper.Viper).Get") {
+		return "viper"
+	}
+	return ""
+}
+
+// envVars は環境変数と viper の設定キーを読んでいる箇所を探す。キーが関数の引数であれば
+// (getenv("PORT", "8080") のようなラッパー) 呼び出し元の定数を使う
+func envVars(prog *Program) []EnvVar {
+	cg := prog.CallGraph()
+	viperDefaults := make(map[string]string)
+	var vars []EnvVar
+	for _, fn := range prog.targetFunctions() {
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				call, ok := instr.(*ssa.Call)
+				if !ok {
+					continue
+				}
+				name := calleeName(&call.Call)
+				args := callArgs(&call.Call)
+				if strings.HasSuffix(name, "viper.SetDefault") || strings.HasSuffix(name, "viper.Viper).SetDefault") {
+					if key := constString(args[0]); key != "" {
+						viperDefaults[key] = constValue(args[1])
+					}
+					continue
+				}
+				source := envSource(name)
+				if source == "" || len(args) == 0 {
+					continue
+				}
+				def := envDefault(call)
+				add := func(key, def string, site ssa.CallInstruction, caller *ssa.Function) {
+					vars = append(vars, EnvVar{
+						Name:    key,
+						Source:  source,
+						Default: def,
+						Package: caller.Pkg.Pkg.Path(),
+						Func:    caller.RelString(nil),
+						Pos:     prog.Fset.Position(site.Pos()),
+					})
+				}
+				if key := constString(args[0]); key != "" {
+					add(key, constValue(def), call, fn)
+					continue
+				}
+				keyParam := paramIndex(fn, args[0])
+				node := cg.Nodes[fn]
+				if keyParam < 0 || node == nil {
+					continue
+				}
+				defParam := paramIndex(fn, def)
+				for _, e := range node.In {
+					if e.Site == nil || e.Site.Common().StaticCallee() != fn || !prog.isTarget(e.Caller.Func.Pkg.Pkg) {
+						continue
+					}
+					siteArgs := e.Site.Common().Args
+					key := constString(siteArgs[keyParam])
+					if key == "" {
+						continue
+					}
+					d := constValue(def)
+					if defParam >= 0 {
+						d = constValue(siteArgs[defParam])
+					}
+					add(key, d, e.Site, e.Caller.Func)
+				}
+			}
+		}
+	}
+	for i, v := range vars {
+		if d, ok := viperDefaults[v.Name]; ok && v.Source == "viper" && v.Default == "" {
+			vars[i].Default = d
+		}
+	}
+	sort.SliceStable(vars, func(i, j int) bool {
+		if vars[i].Package != vars[j].Package {
+			return vars[i].Package < vars[j].Package
+		}
+		return vars[i].Name < vars[j].Name
+	})
+	return vars
+}
+
+// envDefault は読んだ値が空のときに使われる値を探す。
+//
+//	v := os.Getenv("PORT")
+//	if v == "" {
+//		v = "8080"   // Phi のもう一方の辺
+//	}
+//
+//	if v := os.Getenv("PORT"); v != "" {
+//		return v
+//	}
+//	return "8080"    // 同じ関数の他の return
+func envDefault(call *ssa.Call) ssa.Value {
+	val := ssa.Value(call)
+	for _, ref := range *call.Referrers() {
+		// LookupEnv の (value, ok) の value
+		if ext, ok := ref.(*ssa.Extract); ok && ext.Index == 0 {
+			val = ext
+		}
+	}
+	if val.Referrers() == nil {
+		return nil
+	}
+	for _, ref := range *val.Referrers() {
+		switch ref := ref.(type) {
+		case *ssa.Phi:
+			for _, e := range ref.Edges {
+				if e != val {
+					return e
+				}
+			}
+		case *ssa.Return:
+			for _, b := range ref.Parent().Blocks {
+				ret, ok := b.Instrs[len(b.Instrs)-1].(*ssa.Return)
+				if ok && ret != ref && len(ret.Results) == len(ref.Results) {
+					for i, r := range ref.Results {
+						if r == val {
+							return ret.Results[i]
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// constValue は定数 (interface に変換されたものを含む) を文字列にする。定数でなければ空文字列
+func constValue(v ssa.Value) string {
+	if mi, ok := v.(*ssa.MakeInterface); ok {
+		v = mi.X
+	}
+	c, ok := v.(*ssa.Const)
+	if !ok || c.Value == nil {
+		return ""
+	}
+	if c.Value.Kind() == constant.String {
+		return constant.StringVal(c.Value)
+	}
+	return c.Value.ExactString()
+}
+
+// paramIndex は v が fn の引数であればその位置を返す
+func paramIndex(fn *ssa.Function, v ssa.Value) int {
+	for i, p := range fn.Params {
+		if p == v {
+			return i
+		}
+	}
+	return -1
+}
+
+// writeEnvVars はパッケージごとに環境変数を一覧にする
+func writeEnvVars(w io.Writer, vars []EnvVar) error {
+	pkg := ""
+	for _, v := range vars {
+		if v.Package != pkg {
+			pkg = v.Package
+			fmt.Fprintln(w, pkg)
+		}
+		def := ""
+		if v.Default != "" {
+			def = fmt.Sprintf(" (default %q)", v.Default)
+		}
+		fmt.Fprintf(w, "\t%s%s\t%s\t%s\n", v.Name, def, v.Source, v.Func)
+	}
+	return nil
+}
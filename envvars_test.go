@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var testdata_envvars = map[string]string{
+	"go.mod": `module example.com/m
+
+go 1.22
+
+require github.com/spf13/viper v0.0.0
+
+replace github.com/spf13/viper => ./third_party/viper
+`,
+	"third_party/viper/go.mod": "module github.com/spf13/viper\n\ngo 1.22\n",
+	"third_party/viper/viper.go": `package viper
+
+func SetDefault(key string, value any) {}
+func GetString(key string) string      { return "" }
+func GetInt(key string) int            { return 0 }
+`,
+	"main.go": `package main
+
+import (
+	"os"
+
+	"example.com/m/config"
+)
+
+func getenv(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func main() {
+	home := os.Getenv("HOME")
+	port := getenv("PORT", "8080")
+	debug, ok := os.LookupEnv("DEBUG")
+	if !ok {
+		debug = "false"
+	}
+	println(home, port, debug)
+	config.Load()
+}
+`,
+	"config/config.go": `package config
+
+import "github.com/spf13/viper"
+
+func Load() {
+	viper.SetDefault("db.host", "localhost")
+	viper.GetString("db.host")
+	viper.GetInt("db.port")
+}
+`,
+}
+
+func TestEnvVars(t *testing.T) {
+	prog := loadTestProgram(t, testdata_envvars)
+	var buf bytes.Buffer
+	if err := writeEnvVars(&buf, envVars(prog)); err != nil {
+		t.Fatal(err)
+	}
+	assertLines(t, strings.Split(strings.TrimSpace(buf.String()), "\n"), []string{
+		"example.com/m",
+		"\tDEBUG (default \"false\")\tos.LookupEnv\texample.com/m.main",
+		"\tHOME\tos.Getenv\texample.com/m.main",
+		"\tPORT (default \"8080\")\tos.Getenv\texample.com/m.main",
+		"example.com/m/config",
+		"\tdb.host (default \"localhost\")\tviper\texample.com/m/config.Load",
+		"\tdb.port\tviper\texample.com/m/config.Load",
+	})
+}
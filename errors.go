@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// 呼び出し側が errors.Is で見分けられるエラーの種類
+var (
+	ErrParse     = errors.New("parse error")
+	ErrTypeCheck = errors.New("type error")
+	ErrNotFound  = errors.New("not found")
+)
+
+// PosError はソースの位置のわかるエラー。Kind は ErrParse などのエラーの種類で、errors.Is で比べられる
+type PosError struct {
+	Pos  token.Position // 位置がわからなければ無効な値
+	Kind error
+	Msg  string
+}
+
+func (e *PosError) Error() string {
+	if e.Pos.IsValid() {
+		return e.Pos.String() + ": " + e.Msg
+	}
+	return e.Msg
+}
+
+func (e *PosError) Unwrap() error {
+	return e.Kind
+}
+
+// notFound は位置のわからない ErrNotFound のエラーを作る
+func notFound(format string, args ...interface{}) error {
+	return &PosError{Kind: ErrNotFound, Msg: fmt.Sprintf(format, args...)}
+}
+
+// notFoundAt は pos の ErrNotFound のエラーを作る
+func notFoundAt(pos token.Position, format string, args ...interface{}) error {
+	return &PosError{Pos: pos, Kind: ErrNotFound, Msg: fmt.Sprintf(format, args...)}
+}
+
+// parseError は go/parser のエラーを ErrParse の PosError にする
+func parseError(err error) error {
+	var list scanner.ErrorList
+	if errors.As(err, &list) && len(list) > 0 {
+		return &PosError{Pos: list[0].Pos, Kind: ErrParse, Msg: list[0].Msg}
+	}
+	return &PosError{Kind: ErrParse, Msg: err.Error()}
+}
+
+// packageError は go/packages のエラーを PosError にする
+func packageError(e packages.Error) *PosError {
+	var kind error
+	switch e.Kind {
+	case packages.ParseError:
+		kind = ErrParse
+	case packages.TypeError:
+		kind = ErrTypeCheck
+	}
+	return &PosError{Pos: parsePosition(e.Pos), Kind: kind, Msg: e.Msg}
+}
+
+// parsePosition は "file:line:col" または "file:line" の形の位置を読む。読めなければ無効な値を返す
+func parsePosition(s string) token.Position {
+	var pos token.Position
+	for i := 0; i < 2; i++ {
+		j := strings.LastIndexByte(s, ':')
+		if j < 0 {
+			break
+		}
+		n, err := strconv.Atoi(s[j+1:])
+		if err != nil {
+			break
+		}
+		pos.Column, pos.Line = pos.Line, n
+		s = s[:j]
+	}
+	if pos.Line == 0 {
+		return token.Position{}
+	}
+	pos.Filename = s
+	return pos
+}
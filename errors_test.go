@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		kind error
+		line int
+	}{
+		{"package main\n\nfunc main() {\n\tvar x int = \"s\"\n\t_ = x\n}\n", ErrTypeCheck, 4},
+		{"package main\n\nfunc main() {\n\tx := \n}\n", ErrParse, 5},
+	}
+	for _, tt := range tests {
+		_, err := loadProgram(writeModule(t, map[string]string{"main.go": tt.src}), "./...")
+		if !errors.Is(err, tt.kind) {
+			t.Errorf("got %v, want %v", err, tt.kind)
+			continue
+		}
+		var perr *PosError
+		if !errors.As(err, &perr) {
+			t.Fatalf("%v is not a PosError", err)
+		}
+		if filepath.Base(perr.Pos.Filename) != "main.go" || perr.Pos.Line != tt.line {
+			t.Errorf("got position %v, want main.go:%d", perr.Pos, tt.line)
+		}
+	}
+}
+
+func TestErrorKinds(t *testing.T) {
+	_, err := ParseExpr("f(%s", "x")
+	var perr *PosError
+	if !errors.Is(err, ErrParse) || !errors.As(err, &perr) || !perr.Pos.IsValid() {
+		t.Errorf("ParseExpr: got %v, want a positioned ErrParse", err)
+	}
+
+	at, _ := parseInsertPoint("func-end:missing")
+	_, err = InsertTemplate("main.go", []byte("package main\n"), at, "x++", nil)
+	if !errors.Is(err, ErrNotFound) || err.Error() != "function missing not found" {
+		t.Errorf("InsertTemplate: got %v, want ErrNotFound", err)
+	}
+
+	for s, want := range map[string]string{
+		"a/b.go:3:7": "a/b.go:3:7",
+		"b.go:3":     "b.go:3",
+		"C:/b.go:3":  "C:/b.go:3",
+		"-":          "-",
+		"":           "-",
+	} {
+		if got := parsePosition(s).String(); got != want {
+			t.Errorf("parsePosition(%q) = %s, want %s", s, got, want)
+		}
+	}
+}
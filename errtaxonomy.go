@@ -0,0 +1,537 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/packages"
+)
+
+// エラーの分類の種類
+const (
+	errorSentinel = "sentinel" // errors.New などで作ったパッケージ変数
+	errorType     = "type"     // error を実装する型
+)
+
+// エラーの使われ方
+const (
+	errorCreated  = "created"  // 値を作る (番兵の宣言、複合リテラル、new)
+	errorWrapped  = "wrapped"  // fmt.Errorf の %w や errors.Join で包む
+	errorCompared = "compared" // errors.Is、errors.As、==、型アサーション、switch で見分ける
+	errorString   = "string"   // Error() で文字列にする
+)
+
+// ErrorClass は番兵エラーかエラー型 1 つと、それが使われている場所
+type ErrorClass struct {
+	Name     string         `json:"name"`               // import path で修飾した名前
+	Kind     string         `json:"kind"`               // sentinel か type
+	Message  string         `json:"message,omitempty"`  // 番兵を作った errors.New や fmt.Errorf の文字列
+	External bool           `json:"external,omitempty"` // 解析対象の外 (io.EOF など) で宣言されている
+	Pos      token.Position `json:"pos"`
+	Uses     []ErrorUse     `json:"uses,omitempty"`
+}
+
+// ErrorUse はエラーの使われ方 1 件
+type ErrorUse struct {
+	Kind string         `json:"kind"`           // created, wrapped, compared, string
+	How  string         `json:"how"`            // errors.Is、==、fmt.Errorf、literal など
+	Func string         `json:"func,omitempty"` // 使っている関数。パッケージ変数の初期化では空
+	Pos  token.Position `json:"pos"`
+}
+
+// ErrorEdge は From のエラーから errors.Is や errors.As で To のエラーが見つかること
+type ErrorEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Via  string `json:"via"` // fmt.Errorf (番兵の初期化で包む)、field Kind (Unwrap のある型のフィールド)、value (番兵の値の型)
+}
+
+// ErrorTaxonomy はモジュールのエラーの分類と、その間の包む関係
+type ErrorTaxonomy struct {
+	Classes []*ErrorClass `json:"classes"`
+	Edges   []ErrorEdge   `json:"edges,omitempty"`
+}
+
+func runErrTaxonomy(args []string) error {
+	fs := flag.NewFlagSet("errtaxonomy", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output as JSON")
+	format := fs.String("format", "text", "output format: text, mermaid or dot")
+	fs.Parse(args)
+	if *format != "text" && *format != "mermaid" && *format != "dot" {
+		return fmt.Errorf("invalid -format value %q (want text, mermaid or dot)", *format)
+	}
+	prog, err := loadProgram(".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	tax := errorTaxonomy(prog)
+	relativizePositions(tax)
+	switch {
+	case *asJSON:
+		return writeJSON(os.Stdout, tax)
+	case *format == "mermaid":
+		return writeErrorMermaid(os.Stdout, tax)
+	case *format == "dot":
+		return writeErrorDOT(os.Stdout, tax)
+	}
+	return writeErrorTaxonomy(os.Stdout, tax)
+}
+
+// errorTaxonomy は解析対象のパッケージの番兵エラー (error を実装する型のパッケージ変数) とエラー型を集め、
+// それぞれが作られ、包まれ、比べられ、文字列にされる場所を調べる。解析対象の外の番兵とエラー型は、
+// 包んだり比べたりしているものだけを含める。包む関係は、番兵の初期化の fmt.Errorf の %w と、
+// Unwrap のあるエラー型の複合リテラルのフィールドに入れた番兵やエラー型から求める
+func errorTaxonomy(prog *Program) *ErrorTaxonomy {
+	t := &errorTaxonomyBuilder{prog: prog, classes: make(map[types.Object]*ErrorClass), edges: make(map[ErrorEdge]bool)}
+	for _, pkg := range prog.Packages {
+		scope := pkg.Types.Scope()
+		for _, name := range scope.Names() {
+			t.class(scope.Lookup(name))
+		}
+	}
+	for _, pkg := range prog.Packages {
+		t.walk(pkg)
+	}
+
+	tax := &ErrorTaxonomy{}
+	linked := make(map[string]bool)
+	for e := range t.edges {
+		tax.Edges = append(tax.Edges, e)
+		linked[e.From], linked[e.To] = true, true
+	}
+	for _, c := range t.classes {
+		if c.External && len(c.Uses) == 0 && !linked[c.Name] {
+			continue // 解析対象の外の型の値を作っているだけ
+		}
+		sort.SliceStable(c.Uses, func(i, j int) bool { return positionLess(c.Uses[i].Pos, c.Uses[j].Pos) })
+		tax.Classes = append(tax.Classes, c)
+	}
+	sort.Slice(tax.Classes, func(i, j int) bool { return tax.Classes[i].Name < tax.Classes[j].Name })
+	sort.Slice(tax.Edges, func(i, j int) bool {
+		a, b := tax.Edges[i], tax.Edges[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		if a.To != b.To {
+			return a.To < b.To
+		}
+		return a.Via < b.Via
+	})
+	return tax
+}
+
+// positionLess はファイル名、行、列の順に位置を比べる
+func positionLess(a, b token.Position) bool {
+	if a.Filename != b.Filename {
+		return a.Filename < b.Filename
+	}
+	if a.Line != b.Line {
+		return a.Line < b.Line
+	}
+	return a.Column < b.Column
+}
+
+type errorTaxonomyBuilder struct {
+	prog    *Program
+	classes map[types.Object]*ErrorClass
+	edges   map[ErrorEdge]bool
+}
+
+// class は obj が番兵エラーかエラー型ならその分類を返す (初めてなら作る)
+func (t *errorTaxonomyBuilder) class(obj types.Object) *ErrorClass {
+	if obj == nil || obj.Pkg() == nil || obj.Parent() != obj.Pkg().Scope() {
+		return nil
+	}
+	if c, ok := t.classes[obj]; ok {
+		return c
+	}
+	c := &ErrorClass{Name: obj.Pkg().Path() + "." + obj.Name(), External: !t.prog.isTarget(obj.Pkg())}
+	switch obj := obj.(type) {
+	case *types.Var:
+		if !implementsError(obj.Type()) {
+			return nil
+		}
+		c.Kind = errorSentinel
+	case *types.TypeName:
+		if obj.IsAlias() || types.IsInterface(obj.Type()) || !implementsError(obj.Type()) && !implementsError(types.NewPointer(obj.Type())) {
+			return nil
+		}
+		c.Kind = errorType
+	default:
+		return nil
+	}
+	if obj.Pos().IsValid() {
+		c.Pos = t.prog.Fset.Position(obj.Pos())
+	}
+	t.classes[obj] = c
+	return c
+}
+
+// implementsError は型 typ の値が error を実装するかどうかを返す
+func implementsError(typ types.Type) bool {
+	return types.Implements(typ, types.Universe.Lookup("error").Type().Underlying().(*types.Interface))
+}
+
+// valueClass は式 e が番兵エラーを参照していればその分類を返す
+func (t *errorTaxonomyBuilder) valueClass(info *types.Info, e ast.Expr) *ErrorClass {
+	switch e := ast.Unparen(e).(type) {
+	case *ast.Ident:
+		return t.class(info.Uses[e])
+	case *ast.SelectorExpr:
+		if _, ok := info.Selections[e]; !ok {
+			return t.class(info.Uses[e.Sel])
+		}
+	}
+	return nil
+}
+
+// typeClass は typ (またはそのポインタの指す型) がエラー型ならその分類を返す
+func (t *errorTaxonomyBuilder) typeClass(typ types.Type) *ErrorClass {
+	if ptr, ok := typ.(*types.Pointer); ok {
+		typ = ptr.Elem()
+	}
+	if named, ok := typ.(*types.Named); ok {
+		return t.class(named.Origin().Obj())
+	}
+	return nil
+}
+
+// exprClass は式 e の番兵エラー、なければ e の型のエラー型の分類を返す
+func (t *errorTaxonomyBuilder) exprClass(info *types.Info, e ast.Expr) *ErrorClass {
+	if c := t.valueClass(info, e); c != nil {
+		return c
+	}
+	if typ := info.TypeOf(e); typ != nil {
+		return t.typeClass(typ)
+	}
+	return nil
+}
+
+func (t *errorTaxonomyBuilder) use(c *ErrorClass, kind, how, fn string, pos token.Pos) {
+	if c != nil {
+		c.Uses = append(c.Uses, ErrorUse{Kind: kind, How: how, Func: fn, Pos: t.prog.Fset.Position(pos)})
+	}
+}
+
+// walk は pkg のファイル (生成されたものは -generated がなければ除く) でのエラーの使われ方を集める
+func (t *errorTaxonomyBuilder) walk(pkg *packages.Package) {
+	info := pkg.TypesInfo
+	names := newAnonNames(pkg)
+	var files []*ast.File
+	for _, file := range pkg.Syntax {
+		if !t.prog.skipGenerated(t.prog.Fset.Position(file.Pos()).Filename) {
+			files = append(files, file)
+		}
+	}
+	filter := []ast.Node{
+		(*ast.ValueSpec)(nil),
+		(*ast.CallExpr)(nil),
+		(*ast.CompositeLit)(nil),
+		(*ast.BinaryExpr)(nil),
+		(*ast.TypeAssertExpr)(nil),
+		(*ast.TypeSwitchStmt)(nil),
+		(*ast.SwitchStmt)(nil),
+	}
+	inspector.New(files).WithStack(filter, func(n ast.Node, push bool, stack []ast.Node) bool {
+		if !push {
+			return true
+		}
+		fn := enclosingFuncName(info, names, stack)
+		switch n := n.(type) {
+		case *ast.ValueSpec:
+			if fn == "" {
+				t.sentinelSpec(info, n)
+			}
+		case *ast.CallExpr:
+			t.call(info, n, fn)
+		case *ast.CompositeLit:
+			if c := t.typeClass(info.TypeOf(n)); c != nil && !c.External {
+				t.use(c, errorCreated, "literal", fn, n.Pos())
+				t.fieldEdges(info, c, n)
+			}
+		case *ast.BinaryExpr:
+			if n.Op == token.EQL || n.Op == token.NEQ {
+				for _, operand := range []ast.Expr{n.X, n.Y} {
+					t.use(t.valueClass(info, operand), errorCompared, n.Op.String(), fn, n.Pos())
+				}
+			}
+		case *ast.TypeAssertExpr:
+			if n.Type != nil {
+				t.use(t.typeClass(info.TypeOf(n.Type)), errorCompared, "type assertion", fn, n.Pos())
+			}
+		case *ast.TypeSwitchStmt:
+			for _, clause := range n.Body.List {
+				for _, e := range clause.(*ast.CaseClause).List {
+					t.use(t.typeClass(info.TypeOf(e)), errorCompared, "type switch", fn, e.Pos())
+				}
+			}
+		case *ast.SwitchStmt:
+			if n.Tag == nil || !implementsError(info.TypeOf(n.Tag)) {
+				break
+			}
+			for _, clause := range n.Body.List {
+				for _, e := range clause.(*ast.CaseClause).List {
+					t.use(t.valueClass(info, e), errorCompared, "switch", fn, e.Pos())
+				}
+			}
+		}
+		return true
+	})
+}
+
+// enclosingFuncName は stack のいちばん内側の関数の名前を CallSite.CallerName と同じ形で返す。
+// パッケージ変数の初期化では空を返す
+func enclosingFuncName(info *types.Info, names *anonNames, stack []ast.Node) string {
+	for i := len(stack) - 1; i >= 0; i-- {
+		switch n := stack[i].(type) {
+		case *ast.FuncLit:
+			return names.funcName(n)
+		case *ast.FuncDecl:
+			if obj, ok := info.Defs[n.Name].(*types.Func); ok {
+				return obj.FullName()
+			}
+			return ""
+		}
+	}
+	return ""
+}
+
+// sentinelSpec はパッケージ変数の宣言で作られる番兵の文字列と、初期化の式で包んでいるエラーを記録する
+func (t *errorTaxonomyBuilder) sentinelSpec(info *types.Info, spec *ast.ValueSpec) {
+	for i, name := range spec.Names {
+		c := t.class(info.Defs[name])
+		if c == nil || len(spec.Values) != len(spec.Names) {
+			continue
+		}
+		value := ast.Unparen(spec.Values[i])
+		how := "value"
+		if call, ok := value.(*ast.CallExpr); ok {
+			if fn, ok := calleeObject(info, call).(*types.Func); ok {
+				how = fn.FullName()
+				if (how == "errors.New" || how == "fmt.Errorf") && len(call.Args) > 0 {
+					if tv := info.Types[call.Args[0]]; tv.Value != nil && tv.Value.Kind() == constant.String {
+						c.Message = constant.StringVal(tv.Value)
+					}
+				}
+				for _, arg := range wrappedArgs(info, call) {
+					if to := t.exprClass(info, arg); to != nil {
+						t.edges[ErrorEdge{From: c.Name, To: to.Name, Via: how}] = true
+					}
+				}
+			}
+		}
+		if u, ok := value.(*ast.UnaryExpr); ok && u.Op == token.AND {
+			value = u.X
+		}
+		if lit, ok := value.(*ast.CompositeLit); ok {
+			if to := t.typeClass(info.TypeOf(lit)); to != nil {
+				t.edges[ErrorEdge{From: c.Name, To: to.Name, Via: "value"}] = true
+			}
+		}
+		t.use(c, errorCreated, how, "", name.Pos())
+	}
+}
+
+// wrappedArgs は fmt.Errorf の %w に対応する引数と errors.Join の引数を返す
+func wrappedArgs(info *types.Info, call *ast.CallExpr) []ast.Expr {
+	fn, ok := calleeObject(info, call).(*types.Func)
+	if !ok {
+		return nil
+	}
+	switch fn.FullName() {
+	case "errors.Join":
+		return call.Args
+	case "fmt.Errorf":
+		if len(call.Args) == 0 {
+			return nil
+		}
+		tv := info.Types[call.Args[0]]
+		if tv.Value == nil || tv.Value.Kind() != constant.String {
+			return nil
+		}
+		directives, _ := parseFormat(constant.StringVal(tv.Value))
+		var args []ast.Expr
+		for _, d := range directives {
+			if n := len(d.args); d.verb == 'w' && n > 0 && d.args[n-1]+1 < len(call.Args) {
+				args = append(args, call.Args[d.args[n-1]+1])
+			}
+		}
+		return args
+	}
+	return nil
+}
+
+// call は呼び出しでエラーを包む、比べる、文字列にする、new で作るものを記録する
+func (t *errorTaxonomyBuilder) call(info *types.Info, call *ast.CallExpr, fn string) {
+	switch callee := calleeObject(info, call).(type) {
+	case *types.Builtin:
+		if callee.Name() == "new" && len(call.Args) == 1 {
+			if c := t.typeClass(info.TypeOf(call.Args[0])); c != nil && !c.External {
+				t.use(c, errorCreated, "new", fn, call.Pos())
+			}
+		}
+	case *types.Func:
+		name := callee.FullName()
+		switch {
+		case name == "errors.Is" && len(call.Args) == 2:
+			t.use(t.exprClass(info, call.Args[1]), errorCompared, name, fn, call.Pos())
+		case name == "errors.As" && len(call.Args) == 2:
+			if ptr, ok := info.TypeOf(call.Args[1]).(*types.Pointer); ok {
+				t.use(t.typeClass(ptr.Elem()), errorCompared, name, fn, call.Pos())
+			}
+		case callee.Name() == "Error" && len(call.Args) == 0:
+			if sel, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr); ok {
+				t.use(t.exprClass(info, sel.X), errorString, "Error()", fn, call.Pos())
+			}
+		}
+		for _, arg := range wrappedArgs(info, call) {
+			t.use(t.exprClass(info, arg), errorWrapped, name, fn, arg.Pos())
+		}
+	}
+}
+
+// fieldEdges はエラー型 c の複合リテラルのフィールドに入れた番兵やエラー型を、c が Unwrap で返しうるものとして記録する
+func (t *errorTaxonomyBuilder) fieldEdges(info *types.Info, c *ErrorClass, lit *ast.CompositeLit) {
+	typ := info.TypeOf(lit)
+	if obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(typ), false, nil, "Unwrap"); obj == nil {
+		return
+	}
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			continue
+		}
+		key, ok := kv.Key.(*ast.Ident)
+		if !ok {
+			continue
+		}
+		if to := t.exprClass(info, kv.Value); to != nil && to != c {
+			t.edges[ErrorEdge{From: c.Name, To: to.Name, Via: "field " + key.Name}] = true
+		}
+	}
+}
+
+func writeErrorTaxonomy(w io.Writer, tax *ErrorTaxonomy) error {
+	wraps := make(map[string][]ErrorEdge)
+	for _, e := range tax.Edges {
+		wraps[e.From] = append(wraps[e.From], e)
+	}
+	for _, c := range tax.Classes {
+		line := c.Kind + " " + c.Name
+		if c.Message != "" {
+			line += fmt.Sprintf(" %q", c.Message)
+		}
+		if c.External {
+			line += " (external)"
+		} else if c.Pos.IsValid() {
+			line += " (" + c.Pos.String() + ")"
+		}
+		fmt.Fprintln(w, line)
+		for _, e := range wraps[c.Name] {
+			fmt.Fprintf(w, "  wraps %s via %s\n", e.To, e.Via)
+		}
+		for _, u := range c.Uses {
+			where := u.Func
+			if where == "" {
+				where = "package var"
+			}
+			fmt.Fprintf(w, "  %-8s %-14s %s at %s\n", u.Kind, u.How, where, u.Pos)
+		}
+	}
+	return nil
+}
+
+// errorGraphUses はグラフに描く関数からエラーへの使われ方 (包む、比べる) を、関数、分類、種類ごとに 1 つにまとめて返す
+func errorGraphUses(tax *ErrorTaxonomy) (funcs []string, uses [][3]string) {
+	seenFunc := make(map[string]bool)
+	seen := make(map[[3]string]bool)
+	for _, c := range tax.Classes {
+		for _, u := range c.Uses {
+			if u.Func == "" || u.Kind != errorWrapped && u.Kind != errorCompared {
+				continue
+			}
+			k := [3]string{u.Func, c.Name, u.Kind}
+			if !seen[k] {
+				seen[k] = true
+				uses = append(uses, k)
+			}
+			if !seenFunc[u.Func] {
+				seenFunc[u.Func] = true
+				funcs = append(funcs, u.Func)
+			}
+		}
+	}
+	sort.Strings(funcs)
+	sort.Slice(uses, func(i, j int) bool {
+		return strings.Join(uses[i][:], "\x00") < strings.Join(uses[j][:], "\x00")
+	})
+	return funcs, uses
+}
+
+// writeErrorMermaid は番兵を角の丸いノード、エラー型を四角のノードにして、包む関係を実線、
+// 関数がエラーを包む・比べることを点線で描く
+func writeErrorMermaid(w io.Writer, tax *ErrorTaxonomy) error {
+	fmt.Fprintln(w, "flowchart LR")
+	ids := make(map[string]string)
+	for i, c := range tax.Classes {
+		ids[c.Name] = fmt.Sprintf("E%d", i)
+		if c.Kind == errorSentinel {
+			fmt.Fprintf(w, "    %s(\"%s\")\n", ids[c.Name], c.Name)
+		} else {
+			fmt.Fprintf(w, "    %s[\"%s\"]\n", ids[c.Name], c.Name)
+		}
+		if c.External {
+			fmt.Fprintf(w, "    style %s stroke-dasharray:4\n", ids[c.Name])
+		}
+	}
+	funcs, uses := errorGraphUses(tax)
+	for i, f := range funcs {
+		ids[f] = fmt.Sprintf("F%d", i)
+		fmt.Fprintf(w, "    %s[/\"%s\"/]\n", ids[f], f)
+	}
+	for _, e := range tax.Edges {
+		fmt.Fprintf(w, "    %s -->|%s| %s\n", ids[e.From], e.Via, ids[e.To])
+	}
+	for _, u := range uses {
+		fmt.Fprintf(w, "    %s -.->|%s| %s\n", ids[u[0]], u[2], ids[u[1]])
+	}
+	return nil
+}
+
+func writeErrorDOT(w io.Writer, tax *ErrorTaxonomy) error {
+	fmt.Fprintln(w, "digraph errors {")
+	fmt.Fprintln(w, "  rankdir=LR;")
+	for _, c := range tax.Classes {
+		var attrs []string
+		if c.Kind == errorSentinel {
+			attrs = append(attrs, "shape=ellipse")
+		} else {
+			attrs = append(attrs, "shape=box")
+		}
+		if c.External {
+			attrs = append(attrs, "style=dashed")
+		}
+		fmt.Fprintf(w, "  %q [%s];\n", c.Name, strings.Join(attrs, ", "))
+	}
+	funcs, uses := errorGraphUses(tax)
+	for _, f := range funcs {
+		fmt.Fprintf(w, "  %q [shape=plain];\n", f)
+	}
+	for _, e := range tax.Edges {
+		fmt.Fprintf(w, "  %q -> %q [label=%q];\n", e.From, e.To, e.Via)
+	}
+	for _, u := range uses {
+		fmt.Fprintf(w, "  %q -> %q [label=%q, style=dashed];\n", u[0], u[1], u[2])
+	}
+	fmt.Fprintln(w, "}")
+	return nil
+}
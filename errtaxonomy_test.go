@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestErrorTaxonomy(t *testing.T) {
+	prog := loadTestProgram(t, map[string]string{
+		"store.go": `package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	ErrNotFound = errors.New("not found")
+	ErrMissing  = fmt.Errorf("missing: %w", ErrNotFound)
+)
+
+type QueryError struct {
+	Query string
+	Err   error
+}
+
+func (e *QueryError) Error() string { return e.Query + ": " + e.Err.Error() }
+
+func (e *QueryError) Unwrap() error { return e.Err }
+
+type codeError int
+
+func (c codeError) Error() string { return "code" }
+
+func find(q string) error {
+	if q == "" {
+		return &QueryError{Query: q, Err: ErrNotFound}
+	}
+	return fmt.Errorf("find %s: %w", q, codeError(1))
+}
+
+func handle(err error) string {
+	var qe *QueryError
+	switch {
+	case errors.Is(err, ErrNotFound), err == io.EOF:
+		return ErrNotFound.Error()
+	case errors.As(err, &qe):
+		return qe.Query
+	}
+	if _, ok := err.(codeError); ok {
+		return "code"
+	}
+	return ""
+}
+
+func main() { println(handle(find(""))) }
+`,
+	})
+	tax := errorTaxonomy(prog)
+	var got []string
+	for _, c := range tax.Classes {
+		got = append(got, fmt.Sprintf("%s %s %q external=%t", c.Kind, c.Name, c.Message, c.External))
+		for _, u := range c.Uses {
+			got = append(got, fmt.Sprintf("  %s %s %s", u.Kind, u.How, u.Func))
+		}
+	}
+	for _, e := range tax.Edges {
+		got = append(got, e.From+" -> "+e.To+" via "+e.Via)
+	}
+	assertLines(t, got, []string{
+		`sentinel example.com/m.ErrMissing "missing: %w" external=false`,
+		"  created fmt.Errorf ",
+		`sentinel example.com/m.ErrNotFound "not found" external=false`,
+		"  created errors.New ",
+		"  wrapped fmt.Errorf ",
+		"  compared errors.Is example.com/m.handle",
+		"  string Error() example.com/m.handle",
+		`type example.com/m.QueryError "" external=false`,
+		"  created literal example.com/m.find",
+		"  compared errors.As example.com/m.handle",
+		`type example.com/m.codeError "" external=false`,
+		"  wrapped fmt.Errorf example.com/m.find",
+		"  compared type assertion example.com/m.handle",
+		`sentinel io.EOF "" external=true`,
+		"  compared == example.com/m.handle",
+		"example.com/m.ErrMissing -> example.com/m.ErrNotFound via fmt.Errorf",
+		"example.com/m.QueryError -> example.com/m.ErrNotFound via field Err",
+	})
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/ssa"
+)
+
+// 値が関数の外に出る経路
+const (
+	escapeReturn    = "return"    // 戻り値として返される
+	escapeGlobal    = "global"    // パッケージレベルの変数に保存される
+	escapeHeap      = "heap"      // 構造体のフィールド、スライスの要素、ポインタの指す先などに保存される
+	escapeGoroutine = "goroutine" // goroutine に渡されるか捕捉される
+	escapeClosure   = "closure"   // 無名関数に捕捉される
+	escapeChannel   = "channel"   // チャネルに送られる
+	escapeCall      = "call"      // 中身のわからない関数に渡される
+)
+
+// maxEscapeDepth は値を渡した先の関数をたどる深さ
+const maxEscapeDepth = 3
+
+// Escape は値が関数の外に出る 1 つの経路
+type Escape struct {
+	Kind   string         `json:"kind"`
+	Detail string         `json:"detail"`
+	Func   string         `json:"func"` // 経路のある関数 (値を渡した先の関数のこともある)
+	Pos    token.Position `json:"pos"`
+}
+
+func runEscape(args []string) error {
+	fs := flag.NewFlagSet("escape", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output as JSON")
+	at := fs.String("pos", "", "`position` (file:line:column) of a local variable or a receiver field selector such as r.f")
+	fs.Parse(args)
+	if *at == "" {
+		return errors.New("-pos is required")
+	}
+	prog, err := loadProgram(".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	pkg, file, pos, err := findSourcePos(prog, *at)
+	if err != nil {
+		return err
+	}
+	fn, v, isAddr, err := escapeTarget(prog, pkg, file, pos)
+	if err != nil {
+		return err
+	}
+	escapes := valueEscapes(prog, fn, v, isAddr)
+	relativizePositions(escapes)
+	if *asJSON {
+		return writeJSON(os.Stdout, escapes)
+	}
+	return writeEscapes(os.Stdout, escapes)
+}
+
+// escapeTarget は pos の識別子 (フィールドの名前ならセレクタ全体) の SSA の値を返す。
+// 式から値を引くにはデバッグ情報が必要なので、SSA をデバッグ情報付きで作り直す。
+// isAddr は値が変数のアドレス (アドレスを取られる変数や捕捉される変数) かどうか
+func escapeTarget(prog *Program, pkg *packages.Package, file *ast.File, pos token.Pos) (fn *ssa.Function, v ssa.Value, isAddr bool, err error) {
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+	id, ok := path[0].(*ast.Ident)
+	if !ok {
+		return nil, nil, false, notFoundAt(prog.Fset.Position(pos), "no variable or field at this position")
+	}
+	var expr ast.Expr = id
+	if sel, ok := path[1].(*ast.SelectorExpr); ok && sel.Sel == id {
+		expr = sel
+	}
+	sprog, _ := buildSSA(prog.Packages, ssa.GlobalDebug|ssa.InstantiateGenerics)
+	sprog.Build()
+	spkg := sprog.Package(pkg.Types)
+	if spkg == nil || len(pkg.Errors) > 0 {
+		return nil, nil, false, notFoundAt(prog.Fset.Position(pos), "package %s has errors and has no SSA", pkg.PkgPath)
+	}
+	fn = ssa.EnclosingFunction(spkg, path)
+	if fn == nil {
+		return nil, nil, false, notFoundAt(prog.Fset.Position(pos), "not inside a function")
+	}
+	if v, isAddr = fn.ValueForExpr(expr); v == nil {
+		return nil, nil, false, notFoundAt(prog.Fset.Position(expr.Pos()), "%s has no SSA value", types.ExprString(expr))
+	}
+	return fn, v, isAddr, nil
+}
+
+// valueEscapes は v が関数の外に出る経路を返す。値のコピー (φ、型の変換、インターフェースへの変換) と
+// ローカル変数への保存と読み出しをたどり、解析対象のパッケージの静的な呼び出し先には引数としてたどる。
+// isAddr なら v は変数のアドレスで、アドレスそのものが外に出る経路と、読み出した値が外に出る経路を調べる
+func valueEscapes(prog *Program, fn *ssa.Function, v ssa.Value, isAddr bool) []Escape {
+	var escapes []Escape
+	seen := make(map[ssa.Value]bool)
+	var visit func(fn *ssa.Function, v ssa.Value, isAddr bool, depth int)
+	add := func(fn *ssa.Function, kind string, pos token.Pos, format string, args ...interface{}) {
+		escapes = append(escapes, Escape{Kind: kind, Detail: fmt.Sprintf(format, args...), Func: fn.RelString(nil), Pos: prog.Fset.Position(pos)})
+	}
+	visit = func(fn *ssa.Function, v ssa.Value, isAddr bool, depth int) {
+		if seen[v] || v.Referrers() == nil {
+			return
+		}
+		seen[v] = true
+		for _, instr := range *v.Referrers() {
+			switch instr := instr.(type) {
+			case *ssa.DebugRef:
+			case *ssa.Return:
+				add(fn, escapeReturn, instr.Pos(), "returned from %s", fn.Name())
+			case *ssa.Store:
+				switch {
+				case instr.Addr == v:
+					// 変数への代入
+				case isLocalAlloc(instr.Addr):
+					visit(fn, instr.Addr, true, depth)
+				case isArgsElem(instr.Addr):
+					// 可変長引数とスライスリテラルの要素は、配列を使う先をたどる
+					visit(fn, instr.Addr.(*ssa.IndexAddr).X, true, depth)
+				default:
+					kind, detail := storeTarget(instr.Addr)
+					add(fn, kind, instr.Pos(), "stored into %s", detail)
+				}
+			case *ssa.UnOp:
+				if isAddr && instr.Op == token.MUL && instr.X == v {
+					visit(fn, instr, false, depth)
+				}
+			case *ssa.Phi, *ssa.ChangeType, *ssa.MakeInterface, *ssa.ChangeInterface, *ssa.Slice:
+				visit(fn, instr.(ssa.Value), isAddr, depth)
+			case *ssa.FieldAddr, *ssa.IndexAddr:
+				// 変数のフィールドや要素のアドレスも変数の一部
+				if isAddr {
+					visit(fn, instr.(ssa.Value), true, depth)
+				}
+			case *ssa.Send:
+				add(fn, escapeChannel, instr.Pos(), "sent on a channel")
+			case *ssa.MakeClosure:
+				closure := instr.Fn.(*ssa.Function)
+				kind := escapeClosure
+				for _, ref := range *instr.Referrers() {
+					if g, ok := ref.(*ssa.Go); ok && g.Call.Value == instr {
+						kind = escapeGoroutine
+					}
+				}
+				add(fn, kind, closure.Pos(), "captured by %s", closure.Name())
+			case *ssa.Go:
+				add(fn, escapeGoroutine, instr.Pos(), "passed to goroutine %s", callDescription(instr.Common()))
+			case ssa.CallInstruction:
+				common := instr.Common()
+				callee := common.StaticCallee()
+				if callee != nil && callee.Pkg != nil && prog.isTarget(callee.Pkg.Pkg) && callee.Blocks != nil && depth < maxEscapeDepth {
+					for i, arg := range common.Args {
+						if arg == v && i < len(callee.Params) {
+							visit(callee, callee.Params[i], false, depth+1)
+						}
+					}
+					continue
+				}
+				if _, ok := common.Value.(*ssa.Builtin); ok {
+					continue // len, append などの組み込み関数 (append の結果は値として追わない)
+				}
+				add(fn, escapeCall, instr.Pos(), "passed to %s", callDescription(common))
+			}
+		}
+	}
+	visit(fn, v, isAddr, 0)
+	return escapes
+}
+
+// isLocalAlloc は addr が関数のローカル変数かどうかを返す
+func isLocalAlloc(addr ssa.Value) bool {
+	alloc, ok := addr.(*ssa.Alloc)
+	return ok && alloc.Comment != "complit" && alloc.Comment != "new"
+}
+
+// isArgsElem は addr が可変長引数かスライスリテラルのために作られた配列の要素かどうかを返す
+func isArgsElem(addr ssa.Value) bool {
+	index, ok := addr.(*ssa.IndexAddr)
+	if !ok {
+		return false
+	}
+	alloc, ok := index.X.(*ssa.Alloc)
+	return ok && (alloc.Comment == "varargs" || alloc.Comment == "slicelit")
+}
+
+// storeTarget は Store の保存先の種類と説明を返す
+func storeTarget(addr ssa.Value) (kind, detail string) {
+	switch addr := addr.(type) {
+	case *ssa.Global:
+		return escapeGlobal, "package variable " + addr.RelString(nil)
+	case *ssa.FieldAddr:
+		field := addr.X.Type().Underlying().(*types.Pointer).Elem().Underlying().(*types.Struct).Field(addr.Field)
+		return escapeHeap, fmt.Sprintf("field %s of %s", field.Name(), addr.X.Type())
+	case *ssa.IndexAddr:
+		return escapeHeap, "an element of " + addr.X.Type().String()
+	}
+	return escapeHeap, "memory pointed to by " + addr.Name()
+}
+
+func writeEscapes(w io.Writer, escapes []Escape) error {
+	for _, e := range escapes {
+		fmt.Fprintf(w, "%s: [%s] %s: %s\n", e.Pos, e.Kind, e.Func, e.Detail)
+	}
+	return nil
+}
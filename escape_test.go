@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValueEscapes(t *testing.T) {
+	src := `package main
+
+import "fmt"
+
+type server struct {
+	name  string
+	cache map[string]string
+}
+
+var last string
+
+type holder struct{ value string }
+
+func keep(h *holder, s string) { h.value = s }
+
+func (s *server) handle(ch chan string) string {
+	name := s.name
+	last = name
+	fmt.Println(name)
+	go func() { ch <- name }()
+	ch <- name
+	keep(&holder{}, name)
+	return name
+}
+
+func (s *server) size() int {
+	return len(s.cache)
+}
+
+func main() {
+	s := &server{}
+	s.handle(nil)
+	s.size()
+}
+`
+	dir := writeModule(t, map[string]string{"main.go": src})
+	prog, err := loadProgram(dir, "./...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	escapes := func(at string) []string {
+		t.Helper()
+		pkg, file, pos, err := findSourcePos(prog, filepath.Join(dir, "main.go")+":"+at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fn, v, isAddr, err := escapeTarget(prog, pkg, file, pos)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := writeEscapes(&buf, valueEscapes(prog, fn, v, isAddr)); err != nil {
+			t.Fatal(err)
+		}
+		var lines []string
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			if line != "" {
+				lines = append(lines, line[strings.Index(line, "main.go:"):])
+			}
+		}
+		return lines
+	}
+	assertLines(t, escapes("17:2"), []string{
+		"main.go:18:2: [global] (*example.com/m.server).handle: stored into package variable example.com/m.last",
+		"main.go:19:13: [call] (*example.com/m.server).handle: passed to fmt.Println",
+		"main.go:20:5: [goroutine] (*example.com/m.server).handle: captured by handle$1",
+		"main.go:21:5: [channel] (*example.com/m.server).handle: sent on a channel",
+		"main.go:14:36: [heap] example.com/m.keep: stored into field value of *example.com/m.holder",
+		"main.go:23:2: [return] (*example.com/m.server).handle: returned from handle",
+	})
+	// s.cache は len に渡されるだけなので外に出ない
+	if got := escapes("27:15"); len(got) != 0 {
+		t.Errorf("s.cache escapes: %v", got)
+	}
+}
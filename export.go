@@ -0,0 +1,219 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/token"
+	"io"
+	"os"
+	"sort"
+)
+
+// exportChecks は export に含められる指摘の解析
+var exportChecks = map[string]func(*Program) []Diagnostic{
+	"ctxcheck":   checkContext,
+	"deadcode":   func(prog *Program) []Diagnostic { return deadCode(prog, allEntryKinds) },
+	"deprecated": checkDeprecated,
+	"doccheck":   checkDocs,
+}
+
+// 以下の型は results.proto のメッセージに対応する。JSON のタグは protojson の名前 (lowerCamelCase) で、
+// proto3 と同じく既定値のフィールドは出力しない
+
+type exportPosition struct {
+	Filename string `json:"filename,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+}
+
+type exportSymbol struct {
+	Name     string          `json:"name,omitempty"`
+	Kind     string          `json:"kind,omitempty"`
+	Package  string          `json:"package,omitempty"`
+	Exported bool            `json:"exported,omitempty"`
+	Pos      *exportPosition `json:"pos,omitempty"`
+}
+
+type exportCallEdge struct {
+	Caller   string `json:"caller,omitempty"`
+	Callee   string `json:"callee,omitempty"`
+	Assembly bool   `json:"assembly,omitempty"`
+}
+
+type exportDiagnostic struct {
+	Pos      *exportPosition `json:"pos,omitempty"`
+	Category string          `json:"category,omitempty"`
+	Message  string          `json:"message,omitempty"`
+	Severity string          `json:"severity,omitempty"`
+}
+
+type exportResults struct {
+	Symbols     []exportSymbol     `json:"symbols,omitempty"`
+	CallEdges   []exportCallEdge   `json:"callEdges,omitempty"`
+	Diagnostics []exportDiagnostic `json:"diagnostics,omitempty"`
+}
+
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", "binary", "output format: binary (protobuf wire format) or protojson")
+	checks := fs.String("checks", "deadcode", "comma-separated analyses whose diagnostics are included (ctxcheck, deadcode, deprecated, doccheck)")
+	out := fs.String("o", "", "write to `file` instead of standard output")
+	fs.Parse(args)
+	if *format != "binary" && *format != "protojson" {
+		return fmt.Errorf("invalid -format value %q (want binary or protojson)", *format)
+	}
+	for _, name := range splitList(*checks) {
+		if exportChecks[name] == nil {
+			return fmt.Errorf("unknown check %q", name)
+		}
+	}
+	prog, err := loadProgram(".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	results := exportAnalysis(prog, splitList(*checks))
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	if *format == "protojson" {
+		return writeJSON(w, results)
+	}
+	_, err = w.Write(results.marshal(nil))
+	return err
+}
+
+// exportAnalysis は解析対象のパッケージのシンボル、標準ライブラリを除いたコールグラフの辺と、checks の指摘を集める
+func exportAnalysis(prog *Program, checks []string) *exportResults {
+	r := &exportResults{}
+	ix := NewIndex(prog)
+	for _, pkg := range prog.Packages {
+		for _, s := range ix.Members(pkg.PkgPath) {
+			r.Symbols = append(r.Symbols, exportSymbolOf(s))
+		}
+	}
+	for _, e := range callEdges(prog, callGraphOptions{Std: stdExclude}) {
+		r.CallEdges = append(r.CallEdges, exportCallEdge{Caller: e.Caller, Callee: e.Callee, Assembly: e.Assembly})
+	}
+	var diags []Diagnostic
+	for _, name := range checks {
+		diags = append(diags, exportChecks[name](prog)...)
+	}
+	sortDiagnostics(diags)
+	for _, d := range diags {
+		r.Diagnostics = append(r.Diagnostics, exportDiagnostic{Pos: exportPos(d.Pos), Category: d.Category, Message: d.Message, Severity: severityOf(d)})
+	}
+	sort.SliceStable(r.Symbols, func(i, j int) bool { return r.Symbols[i].Name < r.Symbols[j].Name })
+	return r
+}
+
+func exportSymbolOf(s Symbol) exportSymbol {
+	return exportSymbol{Name: s.Name, Kind: s.Kind, Package: s.Package, Exported: s.Exported, Pos: exportPos(s.Pos)}
+}
+
+func exportPos(pos token.Position) *exportPosition {
+	return &exportPosition{Filename: relPath(pos.Filename, outputRoot), Line: pos.Line, Column: pos.Column}
+}
+
+// protobuf のワイヤ形式
+
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+func appendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func appendTag(b []byte, field, wireType int) []byte {
+	return appendVarint(b, uint64(field)<<3|uint64(wireType))
+}
+
+// appendString は空でなければ文字列のフィールドを追加する
+func appendString(b []byte, field int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = appendTag(b, field, wireBytes)
+	b = appendVarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// appendInt は 0 でなければ int32 のフィールドを追加する (負の数は 10 バイトの varint になる)
+func appendInt(b []byte, field int, v int) []byte {
+	if v == 0 {
+		return b
+	}
+	b = appendTag(b, field, wireVarint)
+	return appendVarint(b, uint64(int64(int32(v))))
+}
+
+func appendBool(b []byte, field int, v bool) []byte {
+	if !v {
+		return b
+	}
+	b = appendTag(b, field, wireVarint)
+	return append(b, 1)
+}
+
+// appendMessage はエンコードしたメッセージ msg を長さ付きのフィールドとして追加する
+func appendMessage(b []byte, field int, msg []byte) []byte {
+	b = appendTag(b, field, wireBytes)
+	b = appendVarint(b, uint64(len(msg)))
+	return append(b, msg...)
+}
+
+func (p *exportPosition) marshal(b []byte) []byte {
+	b = appendString(b, 1, p.Filename)
+	b = appendInt(b, 2, p.Line)
+	return appendInt(b, 3, p.Column)
+}
+
+func (s *exportSymbol) marshal(b []byte) []byte {
+	b = appendString(b, 1, s.Name)
+	b = appendString(b, 2, s.Kind)
+	b = appendString(b, 3, s.Package)
+	b = appendBool(b, 4, s.Exported)
+	if s.Pos != nil {
+		b = appendMessage(b, 5, s.Pos.marshal(nil))
+	}
+	return b
+}
+
+func (e *exportCallEdge) marshal(b []byte) []byte {
+	b = appendString(b, 1, e.Caller)
+	b = appendString(b, 2, e.Callee)
+	return appendBool(b, 3, e.Assembly)
+}
+
+func (d *exportDiagnostic) marshal(b []byte) []byte {
+	if d.Pos != nil {
+		b = appendMessage(b, 1, d.Pos.marshal(nil))
+	}
+	b = appendString(b, 2, d.Category)
+	b = appendString(b, 3, d.Message)
+	return appendString(b, 4, d.Severity)
+}
+
+func (r *exportResults) marshal(b []byte) []byte {
+	for i := range r.Symbols {
+		b = appendMessage(b, 1, r.Symbols[i].marshal(nil))
+	}
+	for i := range r.CallEdges {
+		b = appendMessage(b, 2, r.CallEdges[i].marshal(nil))
+	}
+	for i := range r.Diagnostics {
+		b = appendMessage(b, 3, r.Diagnostics[i].marshal(nil))
+	}
+	return b
+}
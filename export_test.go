@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestExportMarshal(t *testing.T) {
+	r := &exportResults{
+		CallEdges: []exportCallEdge{{Caller: "a", Callee: "b"}},
+		Diagnostics: []exportDiagnostic{{
+			Pos:      &exportPosition{Filename: "f", Line: 300, Column: -1},
+			Category: "c",
+		}},
+	}
+	want := []byte{
+		0x12, 0x06, // call_edges (2), 6 bytes
+		0x0a, 0x01, 'a', // caller
+		0x12, 0x01, 'b', // callee
+		0x1a, 0x16, // diagnostics (3), 22 bytes
+		0x0a, 0x11, // pos (1), 17 bytes
+		0x0a, 0x01, 'f', // filename
+		0x10, 0xac, 0x02, // line 300
+		0x18, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01, // column -1
+		0x12, 0x01, 'c', // category
+	}
+	if got := r.marshal(nil); !bytes.Equal(got, want) {
+		t.Errorf("got  % x\nwant % x", got, want)
+	}
+}
+
+func TestExportAnalysis(t *testing.T) {
+	prog := loadTestProgram(t, map[string]string{
+		"main.go": `package main
+
+type T struct{}
+
+func (T) Run() { helper() }
+
+func helper() {}
+
+func unused() {}
+
+func main() { T{}.Run() }
+`,
+	})
+	r := exportAnalysis(prog, []string{"deadcode"})
+	var got []string
+	for _, s := range r.Symbols {
+		got = append(got, s.Kind+" "+s.Name)
+	}
+	assertLines(t, got, []string{
+		"method (example.com/m.T).Run",
+		"type example.com/m.T",
+		"func example.com/m.helper",
+		"func example.com/m.main",
+		"func example.com/m.unused",
+	})
+	got = nil
+	for _, e := range r.CallEdges {
+		got = append(got, e.Caller+" --> "+e.Callee)
+	}
+	assertLines(t, got, []string{
+		"(example.com/m.T).Run --> example.com/m.helper",
+		"example.com/m.main --> (example.com/m.T).Run",
+	})
+
+	// protojson では既定値のフィールドを出力しない
+	data, err := json.Marshal(r.Diagnostics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var diags []map[string]interface{}
+	if err := json.Unmarshal(data, &diags); err != nil {
+		t.Fatal(err)
+	}
+	if len(diags) != 1 || diags[0]["category"] != "deadcode" || diags[0]["pos"].(map[string]interface{})["line"] != 9.0 {
+		t.Errorf("unexpected diagnostics: %s", data)
+	}
+	if _, ok := diags[0]["pos"].(map[string]interface{})["column"]; !ok {
+		t.Errorf("column is missing: %s", data)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// defaultFactsDir は main の -facts。空でなければ解析がパッケージについて求めた事実をこのディレクトリに保存し、
+// 後で依存するパッケージを解析するときに読み込む
+var defaultFactsDir string
+
+// PackageFacts は 1 つのパッケージについて解析が求めた事実。解析対象でない (依存先の) パッケージの関数について、
+// そのパッケージを解析し直さずに使う。関数の名前は ssa.Function.RelString(nil) の形
+type PackageFacts struct {
+	Package string `json:"package"`
+	// Fingerprint はパッケージと、それが依存するパッケージのファイルの内容のハッシュ。
+	// 一致しない事実は古いので使わない
+	Fingerprint    string                `json:"fingerprint"`
+	Pure           map[string]bool       `json:"pure,omitempty"`           // 関数が純粋かどうか
+	PrintfWrappers map[string]printfFunc `json:"printfWrappers,omitempty"` // printf のラッパーの書式引数の位置と Errorf か
+}
+
+// factStore は -facts のディレクトリの事実を読み書きする
+type factStore struct {
+	dir  string
+	prog *Program
+
+	mu           sync.Mutex
+	fingerprints map[string]string // パッケージのパスから
+	cache        map[string]*PackageFacts
+}
+
+func newFactStore(dir string, prog *Program) *factStore {
+	return &factStore{dir: dir, prog: prog, cache: make(map[string]*PackageFacts)}
+}
+
+// factsFile はパッケージの事実を保存するファイルの名前を返す
+func (s *factStore) factsFile(pkgPath string) string {
+	return filepath.Join(s.dir, strings.ReplaceAll(pkgPath, "/", "%")+".json")
+}
+
+// fingerprint は pkgPath のパッケージの指紋を返す。読み込んだパッケージの中になければ空を返す
+func (s *factStore) fingerprint(pkgPath string) string {
+	if s.fingerprints == nil {
+		s.fingerprints = make(map[string]string)
+		// Visit は依存先を先に訪れるので、import したパッケージの指紋はすでに求まっている
+		packages.Visit(s.prog.Packages, nil, func(pkg *packages.Package) {
+			h := sha256.New()
+			h.Write([]byte(pkg.PkgPath + "\n"))
+			if !isGorootPackage(pkg.PkgPath) {
+				files := append([]string(nil), pkg.GoFiles...)
+				sort.Strings(files)
+				for _, name := range files {
+					data, _ := s.prog.ReadFile(name)
+					sum := sha256.Sum256(data)
+					h.Write([]byte(filepath.Base(name) + " " + hex.EncodeToString(sum[:]) + "\n"))
+				}
+			}
+			var imports []string
+			for path := range pkg.Imports {
+				imports = append(imports, path)
+			}
+			sort.Strings(imports)
+			for _, path := range imports {
+				h.Write([]byte(path + " " + s.fingerprints[pkg.Imports[path].PkgPath] + "\n"))
+			}
+			s.fingerprints[pkg.PkgPath] = hex.EncodeToString(h.Sum(nil))
+		})
+	}
+	return s.fingerprints[pkgPath]
+}
+
+// load は pkgPath のパッケージの事実を返す。保存されていないか古ければ nil を返す
+func (s *factStore) load(pkgPath string) *PackageFacts {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f, ok := s.cache[pkgPath]; ok {
+		return f
+	}
+	var facts *PackageFacts
+	if data, err := os.ReadFile(s.factsFile(pkgPath)); err == nil {
+		var f PackageFacts
+		if json.Unmarshal(data, &f) == nil && f.Package == pkgPath && f.Fingerprint == s.fingerprint(pkgPath) {
+			facts = &f
+		}
+	}
+	s.cache[pkgPath] = facts
+	return facts
+}
+
+// save は pkgPath のパッケージの事実を update で書き換えて保存する。ほかの解析が保存した事実は残す
+func (s *factStore) save(pkgPath string, update func(*PackageFacts)) error {
+	facts := s.load(pkgPath)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if facts == nil {
+		facts = &PackageFacts{Package: pkgPath, Fingerprint: s.fingerprint(pkgPath)}
+	} else {
+		copied := *facts
+		facts = &copied
+	}
+	update(facts)
+	data, err := json.MarshalIndent(facts, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.factsFile(pkgPath), append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	s.cache[pkgPath] = facts
+	return nil
+}
+
+// importFacts は解析対象でない pkgPath のパッケージの保存された事実を返す。-facts を指定していないか、
+// 事実がなければ nil を返す
+func (p *Program) importFacts(pkgPath string) *PackageFacts {
+	if p.facts == nil || isGorootPackage(pkgPath) {
+		return nil
+	}
+	return p.facts.load(pkgPath)
+}
+
+// exportFacts は解析対象の各パッケージについて update で事実を書き換えて保存する
+func (p *Program) exportFacts(update func(pkgPath string, facts *PackageFacts)) error {
+	if p.facts == nil {
+		return nil
+	}
+	var errs []error
+	for _, pkg := range p.Packages {
+		errs = append(errs, p.facts.save(pkg.PkgPath, func(f *PackageFacts) { update(pkg.PkgPath, f) }))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// withFactsDir はテストの間だけ -facts を t.TempDir() にする
+func withFactsDir(t *testing.T) {
+	t.Helper()
+	saved := defaultFactsDir
+	defaultFactsDir = t.TempDir()
+	t.Cleanup(func() { defaultFactsDir = saved })
+}
+
+func purityOf(t *testing.T, prog *Program, name string) Purity {
+	t.Helper()
+	for _, p := range purity(prog) {
+		if p.Func == name {
+			return p
+		}
+	}
+	t.Fatalf("%s not found", name)
+	return Purity{}
+}
+
+func TestFactsPurity(t *testing.T) {
+	withFactsDir(t)
+	dir := writeModule(t, map[string]string{
+		"lib/lib.go": `package lib
+
+func Add(a, b int) int { return a + b }
+`,
+		"main.go": `package main
+
+import "example.com/m/lib"
+
+func Sum(xs []int) int {
+	n := 0
+	for _, x := range xs {
+		n = lib.Add(n, x)
+	}
+	return n
+}
+
+func main() { println(Sum(nil)) }
+`,
+	})
+	load := func(pattern string) *Program {
+		t.Helper()
+		prog, err := loadProgram(dir, pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return prog
+	}
+
+	// 事実がなければ依存先の関数は純粋とみなさない
+	if p := purityOf(t, load("."), "example.com/m.Sum"); p.Pure {
+		t.Errorf("Sum is pure without facts")
+	}
+
+	lib := load("./lib")
+	if err := exportPurity(lib, purity(lib)); err != nil {
+		t.Fatal(err)
+	}
+	if p := purityOf(t, load("."), "example.com/m.Sum"); !p.Pure {
+		t.Errorf("Sum is not pure with facts: %v", p.Reasons)
+	}
+
+	// lib が変わると保存した事実は古くなる
+	if err := os.WriteFile(filepath.Join(dir, "lib", "lib.go"), []byte(`package lib
+
+var Calls int
+
+func Add(a, b int) int { Calls++; return a + b }
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if p := purityOf(t, load("."), "example.com/m.Sum"); p.Pure {
+		t.Errorf("Sum is pure with stale facts")
+	}
+}
+
+func TestFactsPrintfWrappers(t *testing.T) {
+	withFactsDir(t)
+	dir := writeModule(t, map[string]string{
+		"log/log.go": `package log
+
+import "fmt"
+
+func Logf(format string, args ...any) { fmt.Printf(format, args...) }
+`,
+		"main.go": `package main
+
+import "example.com/m/log"
+
+func main() { log.Logf("%d", "x") }
+`,
+	})
+	lib, err := loadProgram(dir, "./log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := exportPrintfWrappers(lib, printfWrappers(lib, nil)); err != nil {
+		t.Fatal(err)
+	}
+	prog, err := loadProgram(dir, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertLines(t, diagnosticMessages(checkPrintf(prog)), []string{
+		`main.go:5: Logf format %d has arg "x" of wrong type string`,
+	})
+}
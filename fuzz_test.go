@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// addFuzzSeeds は testdata の Go ファイルと、これまでに問題になった入力を f のシードにする
+func addFuzzSeeds(f *testing.F) {
+	f.Add([]byte("package main\nconst variable = \"value\"\n"))
+	f.Add([]byte("package main\nvar a, b = 1, 2\nfunc f() int { for {} }\n"))
+	f.Add([]byte("package main\nfunc f() { L: g(); goto L }\nfunc g() {}\n"))
+	err := filepath.WalkDir("testdata", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".go") {
+			return err
+		}
+		src, err := os.ReadFile(path)
+		if err == nil {
+			f.Add(src)
+		}
+		return err
+	})
+	if err != nil {
+		f.Fatal(err)
+	}
+}
+
+// typeCheck は src を 1 つのファイルのパッケージとして型検査する。標準ライブラリ以外の import や
+// 型のエラーがあれば nil を返す
+func typeCheck(fset *token.FileSet, file *ast.File) *Program {
+	info := &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Implicits:  make(map[ast.Node]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		Scopes:     make(map[ast.Node]*types.Scope),
+		Instances:  make(map[*ast.Ident]types.Instance),
+	}
+	conf := types.Config{Importer: importer.Default()}
+	tpkg, err := conf.Check("example.com/fuzz", fset, []*ast.File{file}, info)
+	if err != nil {
+		return nil
+	}
+	pkg := &packages.Package{
+		ID:        tpkg.Path(),
+		Name:      tpkg.Name(),
+		PkgPath:   tpkg.Path(),
+		Fset:      fset,
+		Syntax:    []*ast.File{file},
+		Types:     tpkg,
+		TypesInfo: info,
+	}
+	return &Program{Fset: fset, Packages: []*packages.Package{pkg}}
+}
+
+// checkRewrite は書き換えた結果 out が構文として正しく、元のソースが型検査を通るなら結果も通ることを確かめる
+func checkRewrite(t *testing.T, src, out []byte, typed bool) {
+	t.Helper()
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "fuzz.go", out, 0)
+	if err != nil {
+		t.Fatalf("rewritten source does not parse: %v\n--- input\n%s\n--- output\n%s", err, src, out)
+	}
+	if typed && typeCheck(fset, file) == nil {
+		t.Fatalf("rewritten source does not type-check\n--- input\n%s\n--- output\n%s", src, out)
+	}
+}
+
+// FuzzInsertTemplate は各関数の最後と import の後にコードを挿入し、結果が壊れていないことを確かめる
+func FuzzInsertTemplate(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, src []byte) {
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, "fuzz.go", src, 0)
+		if err != nil {
+			return
+		}
+		typed := typeCheck(fset, file) != nil
+		points := []InsertPoint{{Kind: insertAfterImports}}
+		for _, decl := range file.Decls {
+			if fd, ok := decl.(*ast.FuncDecl); ok && fd.Body != nil && fd.Name.Name != "_" {
+				points = append(points, InsertPoint{Kind: insertFuncEnd, Name: funcDeclName(fd)})
+			}
+		}
+		for _, at := range points {
+			tmpl := "var _ = 0"
+			if at.Kind == insertFuncEnd {
+				tmpl = "_ = 0"
+			}
+			out, err := InsertTemplate("fuzz.go", src, at, tmpl, nil)
+			if err != nil {
+				t.Fatalf("insert at %s:%s: %v\n--- input\n%s", at.Kind, at.Name, err, src)
+			}
+			checkRewrite(t, src, out, typed)
+		}
+	})
+}
+
+// FuzzStmtEditor は文の並びにある全ての文の前に文を挿入し、結果が壊れていないことを確かめる
+func FuzzStmtEditor(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, src []byte) {
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, "fuzz.go", src, 0)
+		if err != nil {
+			return
+		}
+		typed := typeCheck(fset, file) != nil
+		e := NewStmtEditor()
+		ast.Inspect(file, func(n ast.Node) bool {
+			var list []ast.Stmt
+			switch n := n.(type) {
+			case *ast.BlockStmt:
+				list = n.List
+			case *ast.CaseClause:
+				list = n.Body
+			case *ast.CommClause:
+				list = n.Body
+			}
+			for _, stmt := range list {
+				e.InsertBefore(stmt, MustParseStmt("_ = 0"))
+			}
+			return true
+		})
+		if err := e.Apply(file); err != nil {
+			t.Fatalf("%v\n--- input\n%s", err, src)
+		}
+		var out bytes.Buffer
+		if err := format.Node(&out, fset, file); err != nil {
+			t.Fatalf("format: %v\n--- input\n%s", err, src)
+		}
+		checkRewrite(t, src, out.Bytes(), typed)
+	})
+}
+
+// FuzzQueries は型検査を通るソースに AST と型の情報による問い合わせを実行し、パニックしないことを確かめる
+func FuzzQueries(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, src []byte) {
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, "fuzz.go", src, parser.ParseComments)
+		if err != nil {
+			return
+		}
+		prog := typeCheck(fset, file)
+		if prog == nil {
+			return
+		}
+		CallSites(prog.Packages)
+		typeAssertions(prog)
+		checkDocs(prog)
+		checkDeadBranches(prog)
+		packageDecls(file)
+	})
+}
@@ -0,0 +1,42 @@
+package main
+
+import "go/ast"
+
+// defaultGenerated は main の -generated。true なら生成されたファイルも書き換えとスタイルの指摘の対象にする
+var defaultGenerated bool
+
+// generatedFiles は pkgs のファイルのうち、生成されたもの (// Code generated ... DO NOT EDIT.) の名前を返す
+func generatedFiles(prog *Program) map[string]bool {
+	generated := make(map[string]bool)
+	for _, pkg := range prog.Packages {
+		for _, file := range pkg.Syntax {
+			if ast.IsGenerated(file) {
+				generated[prog.Fset.Position(file.Pos()).Filename] = true
+			}
+		}
+	}
+	return generated
+}
+
+// isGenerated は filename が解析対象のパッケージの生成されたファイルかどうかを返す
+func (p *Program) isGenerated(filename string) bool {
+	return p.generated[filename]
+}
+
+// skipGenerated は filename が生成されたファイルで、-generated を指定していないので書き換えないかどうかを返す。
+// 生成されたファイルも読み込むので、コールグラフや到達可能性の解析には含まれる
+func (p *Program) skipGenerated(filename string) bool {
+	return !defaultGenerated && p.isGenerated(filename)
+}
+
+// filterGenerated は -generated を指定していなければ、生成されたファイルの info の指摘 (スタイルや改善の提案) を除く。
+// 生成元を直さないと消せず、生成されたコードを読む人もいないので、不具合につながりうる warning 以上のものだけを残す
+func filterGenerated(prog *Program, diags []Diagnostic) []Diagnostic {
+	var result []Diagnostic
+	for _, d := range diags {
+		if !prog.skipGenerated(d.Pos.Filename) || severityOf(d) != severityInfo {
+			result = append(result, d)
+		}
+	}
+	return result
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"go/token"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGeneratedFiles(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"gen.go": `// Code generated by stringer; DO NOT EDIT.
+
+package main
+
+func genPoint() Point { return Point{X: 0, Y: 1} }
+`,
+		"main.go": `package main
+
+type Point struct{ X, Y int }
+
+func main() { _ = Point{X: 0, Y: genPoint().Y} }
+`,
+	})
+	prog, err := loadProgramWith(loadOptions{Tests: true}, dir, "./...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen, main := filepath.Join(dir, "gen.go"), filepath.Join(dir, "main.go")
+	if !prog.isGenerated(gen) || prog.isGenerated(main) {
+		t.Errorf("isGenerated(gen.go, main.go) = %v, %v", prog.isGenerated(gen), prog.isGenerated(main))
+	}
+	// 生成されたファイルもコールグラフには含まれる
+	reachable := reachableFunctions(prog, entryFunctions(prog, []string{entryMain}))
+	found := false
+	for fn := range reachable {
+		found = found || fn.Name() == "genPoint"
+	}
+	if !found {
+		t.Error("genPoint is not reachable from main")
+	}
+
+	var msgs []string
+	for _, d := range filterGenerated(prog, []Diagnostic{
+		{Pos: token.Position{Filename: gen, Line: 5}, Category: "doc", Message: "style finding in gen.go"},
+		{Pos: token.Position{Filename: gen, Line: 5}, Category: "nilness", Message: "bug in gen.go"},
+		{Pos: token.Position{Filename: main, Line: 5}, Category: "doc", Message: "style finding in main.go"},
+	}) {
+		msgs = append(msgs, d.Message)
+	}
+	assertLines(t, msgs, []string{"bug in gen.go", "style finding in main.go"})
+
+	for _, include := range []bool{false, true} {
+		// 書き換えは構文木を変更するので読み込み直す
+		prog, err := loadProgramWith(loadOptions{Tests: true}, dir, "./...")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defaultGenerated = include
+		files, err := simplifyZeroValues(prog, "")
+		defaultGenerated = false
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := files[gen]; ok != include {
+			t.Errorf("-generated=%v: rewrote gen.go = %v", include, ok)
+		}
+		if _, ok := files[main]; !ok {
+			t.Errorf("-generated=%v: main.go was not rewritten", include)
+		}
+	}
+
+	if _, err := renameField(prog, "Point.X", "Left", false); err == nil || !strings.Contains(err.Error(), "generated file") {
+		t.Errorf("renameField error = %v, want one about the generated file", err)
+	}
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/tools/go/packages"
+)
+
+func runGenExample(args []string) error {
+	fs := flag.NewFlagSet("genexample", flag.ExitOnError)
+	write := fs.Bool("w", false, "write the examples to example_test.go in each package directory instead of stdout")
+	fs.Parse(args)
+	// 既にある Example 関数を除くためにテストも読み込む
+	prog, err := loadProgramWith(loadOptions{Tests: true}, ".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	files, err := generateExamples(prog)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return errors.New("no exported functions without examples")
+	}
+	return writeGenerated(files, *write)
+}
+
+// generateExamples は解析対象のパッケージ (main を除く) のエクスポートされた関数とメソッドのうち、
+// Example 関数がまだないものについて、型から作った見本の値を引数にして呼び出す Example 関数を作る。
+// Example はパッケージのディレクトリの example_test.go に (あれば末尾に追加して) 書く。
+// 新しく作るファイルは外部テストパッケージ (<パッケージ名>_test) にする。
+// 見本の値を作れない型の引数やレシーバを持つもの、ジェネリックなものは扱わない
+func generateExamples(prog *Program) (map[string][]byte, error) {
+	existing := testFuncNames(prog)
+	files := make(map[string][]byte)
+	for _, pkg := range prog.Packages {
+		if pkg.Name == "main" || strings.HasSuffix(pkg.PkgPath, "_test") || len(pkg.GoFiles) == 0 ||
+			!prog.inFocus(prog.Fset.Position(pkg.Syntax[0].Pos())) {
+			continue
+		}
+		name := filepath.Join(filepath.Dir(pkg.GoFiles[0]), "example_test.go")
+		filePkg, err := filePackage(name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if filePkg == "" {
+			filePkg = pkg.Name + "_test"
+		}
+		g := &exampleGen{prog: prog, pkg: pkg, internal: filePkg == pkg.Name}
+		var src strings.Builder
+		for _, ex := range g.examples() {
+			if !existing[pkg.PkgPath+"."+ex.name] {
+				fmt.Fprintf(&src, "\nfunc %s() {\n%s}\n", ex.name, ex.body)
+			}
+		}
+		if src.Len() == 0 {
+			continue
+		}
+		out, err := appendGenerated(name, filePkg, []byte(src.String()))
+		if err != nil {
+			return nil, err
+		}
+		files[name] = out
+	}
+	return files, nil
+}
+
+// testFuncNames は _test.go で宣言された関数を "パッケージのパス.関数名" の集合で返す。
+// 外部テストパッケージの関数はテストするパッケージのパスにする
+func testFuncNames(prog *Program) map[string]bool {
+	names := make(map[string]bool)
+	for _, pkg := range prog.Packages {
+		for _, file := range pkg.Syntax {
+			if !strings.HasSuffix(prog.Fset.Position(file.Pos()).Filename, "_test.go") {
+				continue
+			}
+			for _, decl := range file.Decls {
+				if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil {
+					names[strings.TrimSuffix(pkg.PkgPath, "_test")+"."+fn.Name.Name] = true
+				}
+			}
+		}
+	}
+	return names
+}
+
+// exampleGen は 1 つのパッケージの Example 関数を作る
+type exampleGen struct {
+	prog     *Program
+	pkg      *packages.Package
+	internal bool // Example をパッケージ自身に書く (識別子をパッケージ名で修飾しない)
+}
+
+type example struct {
+	name, body string
+}
+
+// examples はパッケージの関数と型のメソッドの Example を名前の順に返す
+func (g *exampleGen) examples() []example {
+	var result []example
+	scope := g.pkg.Types.Scope()
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		if !obj.Exported() || strings.HasSuffix(g.prog.Fset.Position(obj.Pos()).Filename, "_test.go") {
+			continue
+		}
+		switch obj := obj.(type) {
+		case *types.Func:
+			if call, ok := g.call(g.qualify(obj.Name()), obj.Type().(*types.Signature)); ok {
+				result = append(result, example{"Example" + obj.Name(), "\t" + call + "\n"})
+			}
+		case *types.TypeName:
+			named, ok := obj.Type().(*types.Named)
+			if !ok || types.IsInterface(named) || named.TypeParams().Len() > 0 {
+				continue
+			}
+			recv, v, ok := g.receiver(named)
+			if !ok {
+				continue
+			}
+			for i := 0; i < named.NumMethods(); i++ {
+				m := named.Method(i)
+				if !m.Exported() {
+					continue
+				}
+				if call, ok := g.call(v+"."+m.Name(), m.Type().(*types.Signature)); ok {
+					result = append(result, example{"Example" + obj.Name() + "_" + m.Name(), recv + "\t" + call + "\n"})
+				}
+			}
+		}
+	}
+	return result
+}
+
+// call は sig の関数 fun を見本の値で呼び出す文を返す。結果があれば fmt.Println で出力する
+func (g *exampleGen) call(fun string, sig *types.Signature) (string, bool) {
+	args, ok := g.args(sig)
+	if !ok || sig.TypeParams().Len() > 0 {
+		return "", false
+	}
+	call := fmt.Sprintf("%s(%s)", fun, args)
+	if sig.Results().Len() == 0 {
+		return call, true
+	}
+	return fmt.Sprintf("fmt.Println(%s)", call), true
+}
+
+// args は sig の引数の見本の値を "," で区切って返す。可変長引数には要素を 1 つ渡す
+func (g *exampleGen) args(sig *types.Signature) (string, bool) {
+	var args []string
+	for i := 0; i < sig.Params().Len(); i++ {
+		t := sig.Params().At(i).Type()
+		if sig.Variadic() && i == sig.Params().Len()-1 {
+			t = t.(*types.Slice).Elem()
+		}
+		v, ok := g.sample(t)
+		if !ok {
+			return "", false
+		}
+		args = append(args, v)
+	}
+	return strings.Join(args, ", "), true
+}
+
+// receiver は named のメソッドを呼ぶ値を用意する文とその変数名を返す。
+// New<型名> (なければ New) が named かそのポインタを返すならそれで作り、なければゼロ値の変数を宣言する
+func (g *exampleGen) receiver(named *types.Named) (string, string, bool) {
+	name := named.Obj().Name()
+	r, _ := utf8.DecodeRuneInString(name)
+	v := string(unicode.ToLower(r))
+	for _, ctor := range []string{"New" + name, "New"} {
+		fn, ok := g.pkg.Types.Scope().Lookup(ctor).(*types.Func)
+		if !ok {
+			continue
+		}
+		sig := fn.Type().(*types.Signature)
+		res := sig.Results()
+		if res.Len() == 0 || res.Len() > 2 || res.Len() == 2 && !isErrorType(res.At(1).Type()) {
+			continue
+		}
+		t := res.At(0).Type()
+		if p, ok := t.(*types.Pointer); ok {
+			t = p.Elem()
+		}
+		args, ok := g.args(sig)
+		if !types.Identical(t, named) || !ok || sig.TypeParams().Len() > 0 {
+			continue
+		}
+		call := fmt.Sprintf("%s(%s)", g.qualify(ctor), args)
+		if res.Len() == 1 {
+			return fmt.Sprintf("\t%s := %s\n", v, call), v, true
+		}
+		return fmt.Sprintf("\t%s, err := %s\n\tif err != nil {\n\t\tlog.Fatal(err)\n\t}\n", v, call), v, true
+	}
+	if !g.nameable(named) {
+		return "", "", false
+	}
+	return fmt.Sprintf("\tvar %s %s\n", v, g.typeString(named)), v, true
+}
+
+// sample は t の見本の値の式を返す。基本型は "example"、1、true、構造体などは空の複合リテラル、
+// よく使われる標準ライブラリのインターフェースは具体的な値、その他のインターフェースと関数は nil にする
+func (g *exampleGen) sample(t types.Type) (string, bool) {
+	if !g.nameable(t) {
+		return "", false
+	}
+	switch types.TypeString(t, nil) {
+	case "context.Context":
+		return "context.Background()", true
+	case "io.Reader":
+		return `strings.NewReader("example")`, true
+	case "io.Writer":
+		return "os.Stdout", true
+	case "time.Duration":
+		return "time.Second", true
+	case "time.Time":
+		return "time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)", true
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsString != 0:
+			return `"example"`, true
+		case u.Info()&types.IsBoolean != 0:
+			return "true", true
+		case u.Info()&types.IsNumeric != 0:
+			return "1", true
+		}
+		return "nil", true
+	case *types.Pointer:
+		if _, ok := u.Elem().Underlying().(*types.Struct); ok {
+			return "&" + g.typeString(u.Elem()) + "{}", true
+		}
+		return "new(" + g.typeString(u.Elem()) + ")", true
+	case *types.Slice:
+		if b, ok := u.Elem().(*types.Basic); ok && b.Kind() == types.Byte {
+			return g.typeString(t) + `("example")`, true
+		}
+		if elem, ok := g.sample(u.Elem()); ok {
+			return g.typeString(t) + "{" + elem + "}", true
+		}
+		return g.typeString(t) + "{}", true
+	case *types.Struct, *types.Array, *types.Map:
+		return g.typeString(t) + "{}", true
+	case *types.Chan:
+		return "make(" + g.typeString(t) + ")", true
+	case *types.Interface:
+		if u.Empty() {
+			return `"example"`, true
+		}
+		return "nil", true
+	case *types.Signature:
+		return "nil", true
+	}
+	return "", false
+}
+
+// nameable は t を Example のファイルで書けるかどうか (エクスポートされていない型や型パラメータを含まないか) を返す
+func (g *exampleGen) nameable(t types.Type) bool {
+	switch t := types.Unalias(t).(type) {
+	case *types.Named:
+		obj := t.Obj()
+		if obj.Pkg() != nil && !obj.Exported() && !(g.internal && obj.Pkg() == g.pkg.Types) {
+			return false
+		}
+		for i := 0; i < t.TypeArgs().Len(); i++ {
+			if !g.nameable(t.TypeArgs().At(i)) {
+				return false
+			}
+		}
+		return true
+	case *types.Pointer:
+		return g.nameable(t.Elem())
+	case *types.Slice:
+		return g.nameable(t.Elem())
+	case *types.Array:
+		return g.nameable(t.Elem())
+	case *types.Chan:
+		return g.nameable(t.Elem())
+	case *types.Map:
+		return g.nameable(t.Key()) && g.nameable(t.Elem())
+	case *types.Signature:
+		for _, tuple := range []*types.Tuple{t.Params(), t.Results()} {
+			for i := 0; i < tuple.Len(); i++ {
+				if !g.nameable(tuple.At(i).Type()) {
+					return false
+				}
+			}
+		}
+		return true
+	case *types.Basic:
+		return true
+	case *types.Interface:
+		return t.Empty()
+	}
+	return false
+}
+
+func (g *exampleGen) typeString(t types.Type) string {
+	return types.TypeString(t, g.qualifier)
+}
+
+func (g *exampleGen) qualifier(pkg *types.Package) string {
+	if g.internal && pkg == g.pkg.Types {
+		return ""
+	}
+	return pkg.Name()
+}
+
+// qualify は解析しているパッケージの識別子 name を、Example のファイルから参照する形にする
+func (g *exampleGen) qualify(name string) string {
+	if q := g.qualifier(g.pkg.Types); q != "" {
+		return q + "." + name
+	}
+	return name
+}
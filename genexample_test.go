@@ -0,0 +1,106 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestGenerateExamples(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"parse/parse.go": `package parse
+
+import (
+	"context"
+	"io"
+)
+
+type Parser struct{ strict bool }
+
+type options struct{}
+
+func NewParser(strict bool) (*Parser, error) { return &Parser{strict}, nil }
+
+func (p *Parser) Parse(ctx context.Context, r io.Reader, tags ...string) ([]string, error) {
+	return nil, nil
+}
+
+func (p *Parser) Reset() {}
+
+func (p *Parser) configure(o options) {}
+
+func Join(parts []string, sep byte) string { return "" }
+
+func WithOptions(o options) *Parser { return nil }
+
+func Lookup(m map[string]int, key []byte) int { return 0 }
+
+type Level int
+
+func (l Level) String() string { return "" }
+`,
+		"parse/example_test.go": `package parse_test
+
+import "example.com/m/parse"
+
+func ExampleJoin() {
+	parse.Join(nil, ',')
+}
+`,
+	})
+	prog, err := loadProgramWith(loadOptions{Tests: true}, dir, "./...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := generateExamples(prog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `package parse_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"example.com/m/parse"
+)
+
+func ExampleJoin() {
+	parse.Join(nil, ',')
+}
+
+func ExampleLevel_String() {
+	var l parse.Level
+	fmt.Println(l.String())
+}
+
+func ExampleLookup() {
+	fmt.Println(parse.Lookup(map[string]int{}, []byte("example")))
+}
+
+func ExampleNewParser() {
+	fmt.Println(parse.NewParser(true))
+}
+
+func ExampleParser_Parse() {
+	p, err := parse.NewParser(true)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(p.Parse(context.Background(), strings.NewReader("example"), "example"))
+}
+
+func ExampleParser_Reset() {
+	p, err := parse.NewParser(true)
+	if err != nil {
+		log.Fatal(err)
+	}
+	p.Reset()
+}
+`
+	got := string(files[filepath.Join(dir, "parse", "example_test.go")])
+	if len(files) != 1 || got != want {
+		t.Errorf("got %d files, example_test.go:\n%s\nwant:\n%s", len(files), got, want)
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+func runGenFuzz(args []string) error {
+	fs := flag.NewFlagSet("genfuzz", flag.ExitOnError)
+	write := fs.Bool("w", false, "write the fuzz targets to fuzz_test.go in each package directory instead of stdout")
+	seeds := fs.Int("seeds", 8, "add at most `n` corpus seeds per fuzz target")
+	fs.Parse(args)
+	// 既にある Fuzz 関数を除くためにテストも読み込む
+	prog, err := loadProgramWith(loadOptions{Tests: true}, ".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	files, err := generateFuzzTargets(prog, *seeds)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return errors.New("no exported functions with fuzzable parameters")
+	}
+	return writeGenerated(files, *write)
+}
+
+// generateFuzzTargets は解析対象のパッケージ (main を除く) のエクスポートされた関数のうち、
+// 引数がすべてファズテストで生成できる型 (文字列、[]byte、bool、整数、浮動小数点数) で、
+// 文字列か []byte の引数を持ち、Fuzz 関数がまだないものについて、ファズテストを作る。
+// コーパスの種にはパッケージの文字列の定数とリテラルを最大 seeds 個使う。
+// ファズテストはパッケージのディレクトリの fuzz_test.go に (あれば末尾に追加して) 書く
+func generateFuzzTargets(prog *Program, seeds int) (map[string][]byte, error) {
+	existing := testFuncNames(prog)
+	files := make(map[string][]byte)
+	for _, pkg := range prog.Packages {
+		if pkg.Name == "main" || strings.HasSuffix(pkg.PkgPath, "_test") || len(pkg.GoFiles) == 0 ||
+			!prog.inFocus(prog.Fset.Position(pkg.Syntax[0].Pos())) {
+			continue
+		}
+		name := filepath.Join(filepath.Dir(pkg.GoFiles[0]), "fuzz_test.go")
+		filePkg, err := filePackage(name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if filePkg == "" {
+			filePkg = pkg.Name + "_test"
+		}
+		g := &exampleGen{prog: prog, pkg: pkg, internal: filePkg == pkg.Name}
+		corpus := stringSeeds(prog, pkg, seeds)
+		var src strings.Builder
+		scope := pkg.Types.Scope()
+		for _, objName := range scope.Names() {
+			fn, ok := scope.Lookup(objName).(*types.Func)
+			if !ok || !fn.Exported() || strings.HasSuffix(prog.Fset.Position(fn.Pos()).Filename, "_test.go") ||
+				existing[pkg.PkgPath+".Fuzz"+fn.Name()] {
+				continue
+			}
+			if target, ok := fuzzTarget(g, fn, corpus); ok {
+				src.WriteString(target)
+			}
+		}
+		if src.Len() == 0 {
+			continue
+		}
+		out, err := appendGenerated(name, filePkg, []byte(src.String()))
+		if err != nil {
+			return nil, err
+		}
+		files[name] = out
+	}
+	return files, nil
+}
+
+// fuzzTarget は fn を呼び出すファズテストの関数を返す。fn の引数にファズテストで生成できない型があれば false を返す
+func fuzzTarget(g *exampleGen, fn *types.Func, corpus []string) (string, bool) {
+	sig := fn.Type().(*types.Signature)
+	if sig.TypeParams().Len() > 0 || sig.Variadic() || sig.Params().Len() == 0 {
+		return "", false
+	}
+	var params, args, seedArgs []string // seedArgs は f.Add の引数の書式。%[1]s が種の文字列になる
+	hasText := false
+	for i := 0; i < sig.Params().Len(); i++ {
+		p := sig.Params().At(i)
+		basic, isBytes, ok := fuzzType(p.Type())
+		if !ok || !g.nameable(p.Type()) {
+			return "", false
+		}
+		name := p.Name()
+		if name == "" || name == "_" || name == "t" {
+			name = fmt.Sprintf("arg%d", i)
+		}
+		typ := basic
+		if isBytes {
+			typ = "[]byte"
+		}
+		params = append(params, name+" "+typ)
+		arg := name
+		if _, named := types.Unalias(p.Type()).(*types.Named); named {
+			arg = fmt.Sprintf("%s(%s)", g.typeString(p.Type()), name)
+		}
+		args = append(args, arg)
+		switch {
+		case isBytes:
+			seedArgs = append(seedArgs, "[]byte(%[1]s)")
+			hasText = true
+		case basic == "string":
+			seedArgs = append(seedArgs, "%[1]s")
+			hasText = true
+		case basic == "bool":
+			seedArgs = append(seedArgs, "false")
+		case basic == "int":
+			seedArgs = append(seedArgs, "0")
+		default:
+			seedArgs = append(seedArgs, basic+"(0)")
+		}
+	}
+	if !hasText {
+		return "", false
+	}
+	if len(corpus) == 0 {
+		corpus = []string{""}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "\nfunc Fuzz%s(f *testing.F) {\n", fn.Name())
+	for _, seed := range corpus {
+		fmt.Fprintf(&b, "\tf.Add(%s)\n", fmt.Sprintf(strings.Join(seedArgs, ", "), strconv.Quote(seed)))
+	}
+	fmt.Fprintf(&b, "\tf.Fuzz(func(t *testing.T, %s) {\n", strings.Join(params, ", "))
+	fmt.Fprintf(&b, "\t\t%s(%s)\n\t})\n}\n", g.qualify(fn.Name()), strings.Join(args, ", "))
+	return b.String(), true
+}
+
+// fuzzType は t がファズテストの引数にできる型ならその基本型の名前を返す。isBytes なら []byte (要素が byte のスライス)
+func fuzzType(t types.Type) (basic string, isBytes bool, ok bool) {
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		if u.Info()&(types.IsString|types.IsBoolean|types.IsInteger|types.IsFloat) == 0 || u.Kind() == types.Uintptr {
+			return "", false, false
+		}
+		return u.Name(), false, true
+	case *types.Slice:
+		if b, ok := u.Elem().(*types.Basic); ok && b.Kind() == types.Byte {
+			return "", true, true
+		}
+	}
+	return "", false, false
+}
+
+// stringSeeds はパッケージの _test.go 以外のファイルの空でない文字列の定数とリテラルを、現れた順に重複なく最大 n 個返す。
+// import のパスと構造体のタグは除く
+func stringSeeds(prog *Program, pkg *packages.Package, n int) []string {
+	var seeds []string
+	seen := make(map[string]bool)
+	add := func(s string) {
+		if s != "" && !seen[s] && len(seeds) < n {
+			seen[s] = true
+			seeds = append(seeds, s)
+		}
+	}
+	for _, file := range pkg.Syntax {
+		if strings.HasSuffix(prog.Fset.Position(file.Pos()).Filename, "_test.go") {
+			continue
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.ImportSpec, *ast.Field:
+				return false
+			case *ast.BasicLit:
+				if n.Kind == token.STRING {
+					if tv, ok := pkg.TypesInfo.Types[n]; ok && tv.Value != nil {
+						add(constant.StringVal(tv.Value))
+					}
+				}
+			}
+			return true
+		})
+	}
+	return seeds
+}
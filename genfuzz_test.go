@@ -0,0 +1,84 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestGenerateFuzzTargets(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"token/token.go": `package token
+
+import "strings"
+
+const Separator = ":"
+
+type Kind string
+
+type Token struct {
+	Kind Kind ` + "`json:\"kind\"`" + `
+}
+
+func Split(s string, limit int8) []string {
+	return strings.SplitN(s, Separator, int(limit))
+}
+
+func Decode(data []byte, strict bool) (Token, error) {
+	if strict && string(data) == "null" {
+		return Token{}, nil
+	}
+	return Token{Kind: "word"}, nil
+}
+
+func IsKind(k Kind) bool { return k == "word" }
+
+func Count(n int) int { return n }
+
+func Join(parts []string) string { return "" }
+`,
+		"token/fuzz_test.go": `package token
+
+import "testing"
+
+func FuzzSplit(f *testing.F) {
+	f.Fuzz(func(t *testing.T, s string, limit int8) { Split(s, limit) })
+}
+`,
+	})
+	prog, err := loadProgramWith(loadOptions{Tests: true}, dir, "./...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := generateFuzzTargets(prog, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `package token
+
+import "testing"
+
+func FuzzSplit(f *testing.F) {
+	f.Fuzz(func(t *testing.T, s string, limit int8) { Split(s, limit) })
+}
+
+func FuzzDecode(f *testing.F) {
+	f.Add([]byte(":"), false)
+	f.Add([]byte("null"), false)
+	f.Fuzz(func(t *testing.T, data []byte, strict bool) {
+		Decode(data, strict)
+	})
+}
+
+func FuzzIsKind(f *testing.F) {
+	f.Add(":")
+	f.Add("null")
+	f.Fuzz(func(t *testing.T, k string) {
+		IsKind(Kind(k))
+	})
+}
+`
+	got := string(files[filepath.Join(dir, "token", "fuzz_test.go")])
+	if len(files) != 1 || got != want {
+		t.Errorf("got %d files, fuzz_test.go:\n%s\nwant:\n%s", len(files), got, want)
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"golang.org/x/tools/imports"
+)
+
+func runGenTest(args []string) error {
+	fs := flag.NewFlagSet("gentest", flag.ExitOnError)
+	funcPattern := fs.String("func", "", "only generate tests for functions whose name matches the `regexp`")
+	write := fs.Bool("w", false, "write the tests to the _test.go file next to each source file instead of stdout")
+	fs.Parse(args)
+	var match *regexp.Regexp
+	if *funcPattern != "" {
+		var err error
+		if match, err = regexp.Compile(*funcPattern); err != nil {
+			return fmt.Errorf("-func: %v", err)
+		}
+	}
+	// 既にテストがある関数を除くためにテストも読み込む
+	prog, err := loadProgramWith(loadOptions{Tests: true}, ".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	files, err := generateTests(prog, match)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return errors.New("no pure functions without tests")
+	}
+	return writeGenerated(files, *write)
+}
+
+// generateTests は純粋と判定された関数のうち、結果を返し、同じ名前のテストがまだないものについて、
+// テーブル駆動テストの雛形を作る。テストはソースファイルと同じディレクトリの <ファイル名>_test.go に
+// (あれば末尾に追加して) 書く。match が nil でなければ名前が一致する関数だけを扱う。
+// メソッド、ジェネリックな関数、_test.go の関数は扱わない
+func generateTests(prog *Program, match *regexp.Regexp) (map[string][]byte, error) {
+	pure := pureFunctions(prog)
+	existing := make(map[string]bool) // パッケージのパス + "." + テスト関数名
+	for _, fn := range prog.targetFunctions() {
+		if strings.HasSuffix(prog.Fset.Position(fn.Pos()).Filename, "_test.go") && fn.Parent() == nil {
+			existing[strings.TrimSuffix(fn.Pkg.Pkg.Path(), "_test")+"."+fn.Name()] = true
+		}
+	}
+	gen := make(map[string][]*types.Func) // テストのファイル名から関数
+	for _, fn := range prog.targetFunctions() {
+		obj, ok := fn.Object().(*types.Func)
+		if !ok || fn.Parent() != nil || fn.Synthetic != "" || fn.Origin() != nil || fn.Signature.Recv() != nil ||
+			fn.Signature.TypeParams().Len() > 0 || fn.Signature.Results().Len() == 0 || !pure[fn.RelString(nil)] ||
+			obj.Name() == "main" || obj.Name() == "init" || match != nil && !match.MatchString(obj.Name()) {
+			continue
+		}
+		src := prog.Fset.Position(fn.Pos()).Filename
+		if strings.HasSuffix(src, "_test.go") || existing[obj.Pkg().Path()+"."+testName(obj.Name())] {
+			continue
+		}
+		name := strings.TrimSuffix(src, ".go") + "_test.go"
+		gen[name] = append(gen[name], obj)
+	}
+	files := make(map[string][]byte)
+	for name, fns := range gen {
+		var buf bytes.Buffer
+		for _, fn := range fns {
+			buf.WriteString("\n")
+			if err := tableTestTemplate.Execute(&buf, newTableTest(fn)); err != nil {
+				return nil, err
+			}
+		}
+		src, err := appendGenerated(name, fns[0].Pkg().Name(), buf.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		files[name] = src
+	}
+	return files, nil
+}
+
+// filePackage は name のファイルのパッケージ名を返す。ファイルがなければ空を返す
+func filePackage(name string) (string, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), name, nil, parser.PackageClauseOnly)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return f.Name.Name, nil
+}
+
+// appendGenerated は name のファイル (なければ package pkg の空のファイル) の末尾に生成したコード src を追加して、
+// import を整えた内容を返す。既存のファイルのパッケージが pkg でなければエラーにする
+func appendGenerated(name, pkg string, src []byte) ([]byte, error) {
+	old, err := os.ReadFile(name)
+	if errors.Is(err, os.ErrNotExist) {
+		old, err = []byte(fmt.Sprintf("package %s\n", pkg)), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if got, err := filePackage(name); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	} else if got != "" && got != pkg {
+		return nil, fmt.Errorf("%s is in package %s, not %s", name, got, pkg)
+	}
+	out, err := imports.Process(name, append(old, src...), nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, parseError(err))
+	}
+	return out, nil
+}
+
+// writeGenerated は生成したファイルを write なら書き込み、そうでなければファイル名の行に続けて標準出力に出力する
+func writeGenerated(files map[string][]byte, write bool) error {
+	if write {
+		return writeFiles(files)
+	}
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("// %s\n%s", relPath(name, outputRoot), files[name])
+	}
+	return nil
+}
+
+// testName は関数 name のテスト関数の名前を返す
+func testName(name string) string {
+	r := []rune(name)
+	return "Test" + string(unicode.ToUpper(r[0])) + string(r[1:])
+}
+
+// tableTest は tableTestTemplate に渡す、1 つの関数のテストの内容
+type tableTest struct {
+	Name, TestName string
+	Params         []tableTestParam
+	Results        []tableTestResult
+	Err            bool   // 最後の結果が error
+	Gots           string // 呼び出しの結果を受ける変数の並び
+	Call           string // tt のフィールドを引数にした呼び出し
+	Format, Args   string // 失敗のメッセージの呼び出しの部分の書式と引数 (引数があれば末尾に ", " が付く)
+}
+
+type tableTestParam struct {
+	Field, Type string
+}
+
+type tableTestResult struct {
+	Got, Want, Type string
+	Label           string // 失敗のメッセージで結果を区別する名前。結果が 1 つなら空
+	DeepEqual       bool   // == で比べられないので reflect.DeepEqual で比べる
+}
+
+func newTableTest(fn *types.Func) tableTest {
+	sig := fn.Type().(*types.Signature)
+	qualifier := types.RelativeTo(fn.Pkg())
+	tt := tableTest{Name: fn.Name(), TestName: testName(fn.Name())}
+	reserved := map[string]bool{"name": true, "wantErr": true}
+	results := sig.Results()
+	n := results.Len()
+	if isErrorType(results.At(n - 1).Type()) {
+		tt.Err = true
+		n--
+	}
+	for i := 0; i < n; i++ {
+		r := tableTestResult{Got: "got", Want: "want", Type: types.TypeString(results.At(i).Type(), qualifier)}
+		if i > 0 {
+			r.Got, r.Want = fmt.Sprintf("got%d", i), fmt.Sprintf("want%d", i)
+		}
+		if n > 1 {
+			r.Label = " " + r.Got
+		}
+		t := results.At(i).Type()
+		_, isIface := t.Underlying().(*types.Interface)
+		r.DeepEqual = isIface || !types.Comparable(t)
+		reserved[r.Want] = true
+		tt.Results = append(tt.Results, r)
+	}
+	var gots, args, verbs []string
+	for _, r := range tt.Results {
+		gots = append(gots, r.Got)
+	}
+	if tt.Err {
+		gots = append(gots, "err")
+	}
+	tt.Gots = strings.Join(gots, ", ")
+	params := sig.Params()
+	for i := 0; i < params.Len(); i++ {
+		p := tableTestParam{Field: params.At(i).Name(), Type: types.TypeString(params.At(i).Type(), qualifier)}
+		if p.Field == "" || p.Field == "_" {
+			p.Field = fmt.Sprintf("arg%d", i)
+		}
+		if reserved[p.Field] {
+			p.Field += "Arg"
+		}
+		arg := "tt." + p.Field
+		if sig.Variadic() && i == params.Len()-1 {
+			arg += "..."
+		}
+		tt.Params = append(tt.Params, p)
+		args = append(args, arg)
+		verbs = append(verbs, "%v")
+	}
+	tt.Call = fmt.Sprintf("%s(%s)", fn.Name(), strings.Join(args, ", "))
+	tt.Format = fmt.Sprintf("%s(%s)", fn.Name(), strings.Join(verbs, ", "))
+	for _, p := range tt.Params {
+		tt.Args += "tt." + p.Field + ", "
+	}
+	return tt
+}
+
+var tableTestTemplate = template.Must(template.New("test").Parse(`func {{.TestName}}(t *testing.T) {
+	tests := []struct {
+		name string
+{{- range .Params}}
+		{{.Field}} {{.Type}}
+{{- end}}
+{{- range .Results}}
+		{{.Want}} {{.Type}}
+{{- end}}
+{{- if .Err}}
+		wantErr bool
+{{- end}}
+	}{
+		// TODO: add test cases
+		{name: "zero values"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			{{.Gots}} := {{.Call}}
+{{- if .Err}}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("{{.Format}} error = %v, wantErr %v", {{.Args}}err, tt.wantErr)
+			}
+{{- end}}
+{{- range .Results}}
+{{- if .DeepEqual}}
+			if !reflect.DeepEqual({{.Got}}, tt.{{.Want}}) {
+{{- else}}
+			if {{.Got}} != tt.{{.Want}} {
+{{- end}}
+				t.Errorf("{{$.Format}}{{.Label}} = %v, want %v", {{$.Args}}{{.Got}}, tt.{{.Want}})
+			}
+{{- end}}
+		})
+	}
+}
+`))
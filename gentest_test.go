@@ -0,0 +1,144 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestGenerateTests(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"calc.go": `package m
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+func add(a, b int) int { return a + b }
+
+func calc1(a int) int { return add(a, 1) }
+
+func Divide(a, b int) (int, error) {
+	if b == 0 {
+		return 0, errors.New("division by zero")
+	}
+	return a / b, nil
+}
+
+func Fields(s string, name ...string) ([]string, int) {
+	f := strings.Fields(s)
+	return f, len(f) + len(name)
+}
+
+func Print(a int) int {
+	fmt.Println(a)
+	return a
+}
+
+func noResult(a int) {}
+`,
+		"calc_test.go": `package m
+
+import "testing"
+
+func TestAdd(t *testing.T) {
+	if add(1, 2) != 3 {
+		t.Fail()
+	}
+}
+`,
+	})
+	prog, err := loadProgramWith(loadOptions{Tests: true}, dir, "./...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := generateTests(prog, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `package m
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAdd(t *testing.T) {
+	if add(1, 2) != 3 {
+		t.Fail()
+	}
+}
+
+func TestCalc1(t *testing.T) {
+	tests := []struct {
+		name string
+		a    int
+		want int
+	}{
+		// TODO: add test cases
+		{name: "zero values"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := calc1(tt.a)
+			if got != tt.want {
+				t.Errorf("calc1(%v) = %v, want %v", tt.a, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDivide(t *testing.T) {
+	tests := []struct {
+		name    string
+		a       int
+		b       int
+		want    int
+		wantErr bool
+	}{
+		// TODO: add test cases
+		{name: "zero values"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Divide(tt.a, tt.b)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Divide(%v, %v) error = %v, wantErr %v", tt.a, tt.b, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Divide(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		nameArg []string
+		want    []string
+		want1   int
+	}{
+		// TODO: add test cases
+		{name: "zero values"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, got1 := Fields(tt.s, tt.nameArg...)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Fields(%v, %v) got = %v, want %v", tt.s, tt.nameArg, got, tt.want)
+			}
+			if got1 != tt.want1 {
+				t.Errorf("Fields(%v, %v) got1 = %v, want %v", tt.s, tt.nameArg, got1, tt.want1)
+			}
+		})
+	}
+}
+`
+	got := string(files[filepath.Join(dir, "calc_test.go")])
+	if len(files) != 1 || got != want {
+		t.Errorf("got %d files, calc_test.go:\n%s\nwant:\n%s", len(files), got, want)
+	}
+}
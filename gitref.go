@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ignoredOverlay は ref に存在しない作業ツリーの Go ファイルを置き換える内容。
+// packages.Config.Overlay ではファイルを削除できないので、ビルド制約で除外する
+const ignoredOverlay = "//go:build ignore\n\npackage ignore\n"
+
+// git は dir で git を実行して標準出力を返す
+func git(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// isOverlayFile はパッケージの読み込みに必要なファイルかどうかを返す
+func isOverlayFile(name string) bool {
+	switch filepath.Base(name) {
+	case "go.mod", "go.sum", "go.work", "go.work.sum":
+		return true
+	}
+	return strings.HasSuffix(name, ".go")
+}
+
+// gitOverlay は dir を含むリポジトリの ref の時点の Go ファイルと go.mod などを、作業ツリーの絶対パスをキーにして返す。
+// ref にない作業ツリーの Go ファイルは ignoredOverlay で置き換える
+func gitOverlay(dir, ref string) (map[string][]byte, error) {
+	out, err := git(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	top := strings.TrimSpace(string(out))
+	if out, err = git(top, "ls-tree", "-r", "-z", "--name-only", ref); err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, name := range strings.Split(string(out), "\x00") {
+		if name != "" && isOverlayFile(name) {
+			names = append(names, name)
+		}
+	}
+	contents, err := gitCatFiles(top, ref, names)
+	if err != nil {
+		return nil, err
+	}
+	overlay := make(map[string][]byte)
+	for i, name := range names {
+		overlay[filepath.Join(top, filepath.FromSlash(name))] = contents[i]
+	}
+	err = filepath.WalkDir(top, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != top && (d.Name() == ".git" || d.Name() == "testdata" || strings.HasPrefix(d.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if _, ok := overlay[path]; !ok && strings.HasSuffix(path, ".go") {
+			overlay[path] = []byte(ignoredOverlay)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return overlay, nil
+}
+
+// gitCatFiles は ref の names のファイルの内容を git cat-file --batch でまとめて読む
+func gitCatFiles(top, ref string, names []string) ([][]byte, error) {
+	var input bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&input, "%s:%s\n", ref, name)
+	}
+	cmd := exec.Command("git", "cat-file", "--batch")
+	cmd.Dir = top
+	cmd.Stdin = &input
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git cat-file: %v", err)
+	}
+	r := bufio.NewReader(bytes.NewReader(out))
+	contents := make([][]byte, len(names))
+	for i, name := range names {
+		// <object> <type> <size>\n<contents>\n
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("git cat-file %s:%s: %v", ref, name, err)
+		}
+		var object, typ string
+		var size int
+		if _, err := fmt.Sscanf(header, "%s %s %d", &object, &typ, &size); err != nil {
+			return nil, fmt.Errorf("git cat-file %s:%s: %s", ref, name, strings.TrimSpace(header))
+		}
+		contents[i] = make([]byte, size)
+		if _, err := io.ReadFull(r, contents[i]); err != nil {
+			return nil, err
+		}
+		r.ReadByte() // 内容の後の改行
+	}
+	return contents, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadProgramRef(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"main.go":      "package main\n\nfunc old() {}\n\nfunc main() { old() }\n",
+		"util/util.go": "package util\n\nfunc Helper() {}\n",
+	})
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "-A"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "initial"},
+	} {
+		if _, err := git(dir, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 作業ツリーだけの変更: main.go の書き換え、ファイルの追加と削除
+	files := map[string]string{
+		"main.go":    "package main\n\nfunc current() {}\n\nfunc main() { current() }\n",
+		"extra.go":   "package main\n\nfunc extra() {}\n",
+		"new/new.go": "package new\n\nfunc New() {}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.RemoveAll(filepath.Join(dir, "util")); err != nil {
+		t.Fatal(err)
+	}
+
+	funcNames := func(prog *Program) []string {
+		var names []string
+		for _, fn := range prog.targetFunctions() {
+			if fn.Synthetic == "" {
+				names = append(names, fn.RelString(nil))
+			}
+		}
+		return names
+	}
+	prog, err := loadProgramWith(loadOptions{Ref: "HEAD"}, dir, "./...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertLines(t, funcNames(prog), []string{
+		"example.com/m.old",
+		"example.com/m.main",
+		"example.com/m/util.Helper",
+	})
+	src, err := prog.ReadFile(filepath.Join(dir, "main.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "package main\n\nfunc old() {}\n\nfunc main() { old() }\n"; string(src) != want {
+		t.Errorf("ReadFile(main.go) = %q, want %q", src, want)
+	}
+
+	prog, err = loadProgram(dir, "./...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertLines(t, funcNames(prog), []string{
+		"example.com/m.extra",
+		"example.com/m.current",
+		"example.com/m.main",
+		"example.com/m/new.New",
+	})
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// グローバル変数の分類
+const (
+	globalConstAfterInit = "constant-after-init" // 初期化後に書き換えられない
+	globalMutated        = "mutated"             // 実行時に書き換えられる
+	globalEscapes        = "escapes"             // 参照が他の関数に渡されるので書き換えられる可能性がある
+)
+
+// GlobalVar はパッケージ変数 1 つの分類結果
+type GlobalVar struct {
+	Name     string         `json:"name"`
+	Pos      token.Position `json:"pos"`
+	Kind     string         `json:"kind"`
+	Mutators []string       `json:"mutators,omitempty"` // 書き換えている関数
+	Escapes  []string       `json:"escapes,omitempty"`  // 参照を他の関数に渡している関数
+}
+
+func runGlobals(args []string) error {
+	fs := flag.NewFlagSet("globals", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output as JSON")
+	fs.Parse(args)
+	prog, err := loadProgram(".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	globals := globalState(prog)
+	if *asJSON {
+		return writeJSON(os.Stdout, globals)
+	}
+	return writeGlobals(os.Stdout, globals)
+}
+
+// globalState は解析対象パッケージの変数を、init 以外の関数での書き込み (SSA の Store など) をもとに分類する
+func globalState(prog *Program) []GlobalVar {
+	mutators := make(map[*ssa.Global]map[string]bool)
+	escapes := make(map[*ssa.Global]map[string]bool)
+	record := func(m map[*ssa.Global]map[string]bool, g *ssa.Global, fn *ssa.Function) {
+		if m[g] == nil {
+			m[g] = make(map[string]bool)
+		}
+		m[g][fn.RelString(nil)] = true
+	}
+
+	for _, fn := range prog.targetFunctions() {
+		if isInitFunction(fn) {
+			continue
+		}
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				switch instr := instr.(type) {
+				case *ssa.Store:
+					if g := globalOf(instr.Addr); g != nil {
+						record(mutators, g, fn)
+					}
+					if g := globalOf(instr.Val); g != nil && isReference(instr.Val.Type()) {
+						record(escapes, g, fn)
+					}
+				case *ssa.MapUpdate:
+					if g := globalOf(instr.Map); g != nil {
+						record(mutators, g, fn)
+					}
+				case ssa.CallInstruction:
+					common := instr.Common()
+					if b, ok := common.Value.(*ssa.Builtin); ok {
+						// delete, copy, clear は第 1 引数を書き換える。組み込み関数は参照を保持しない
+						if builtinModels[b.Name()].Writes {
+							if g := globalOf(common.Args[0]); g != nil {
+								record(mutators, g, fn)
+							}
+						}
+						continue
+					}
+					for _, arg := range common.Args {
+						if g := globalOf(arg); g != nil && isReference(arg.Type()) {
+							record(escapes, g, fn)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	var globals []GlobalVar
+	for _, pkg := range prog.Packages {
+		ssaPkg := prog.SSA().Package(pkg.Types)
+		if ssaPkg == nil {
+			continue // 型の情報のないパッケージ
+		}
+		for _, member := range ssaPkg.Members {
+			g, ok := member.(*ssa.Global)
+			if !ok || g.Object() == nil {
+				continue // init$guard などの合成された変数
+			}
+			v := GlobalVar{
+				Name:     g.RelString(nil),
+				Pos:      prog.Fset.Position(g.Pos()),
+				Kind:     globalConstAfterInit,
+				Mutators: sortedKeys(mutators[g]),
+				Escapes:  sortedKeys(escapes[g]),
+			}
+			switch {
+			case len(v.Mutators) > 0:
+				v.Kind = globalMutated
+			case len(v.Escapes) > 0:
+				v.Kind = globalEscapes
+			}
+			globals = append(globals, v)
+		}
+	}
+	sort.Slice(globals, func(i, j int) bool { return globals[i].Name < globals[j].Name })
+	return globals
+}
+
+// isInitFunction は fn が init 関数、パッケージ変数の初期化、またはそれらの中の無名関数かどうかを返す
+func isInitFunction(fn *ssa.Function) bool {
+	for fn.Parent() != nil {
+		fn = fn.Parent()
+	}
+	return fn.Synthetic == "package initializer" || fn.Name() == "init" || strings.HasPrefix(fn.Name(), "init#")
+}
+
+// globalOf は v がパッケージ変数 (またはその要素・フィールド・参照先) を指していればその変数を返す
+func globalOf(v ssa.Value) *ssa.Global {
+	for {
+		switch x := v.(type) {
+		case *ssa.Global:
+			return x
+		case *ssa.FieldAddr:
+			v = x.X
+		case *ssa.IndexAddr:
+			v = x.X
+		case *ssa.Slice:
+			v = x.X
+		case *ssa.UnOp:
+			if x.Op != token.MUL {
+				return nil
+			}
+			v = x.X
+		default:
+			return nil
+		}
+	}
+}
+
+// isReference は t の値を通して参照先を書き換えられるかどうかを返す
+func isReference(t types.Type) bool {
+	switch t.Underlying().(type) {
+	case *types.Pointer, *types.Map, *types.Slice, *types.Chan:
+		return true
+	}
+	return false
+}
+
+func sortedKeys(m map[string]bool) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// writeGlobals は分類結果をテキストで出力する
+func writeGlobals(w io.Writer, globals []GlobalVar) error {
+	for _, g := range globals {
+		fmt.Fprintf(w, "%s\t%s\n", g.Name, g.Kind)
+		for _, fn := range g.Mutators {
+			fmt.Fprintf(w, "  mutated by %s\n", fn)
+		}
+		for _, fn := range g.Escapes {
+			fmt.Fprintf(w, "  escapes via %s\n", fn)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestGlobalState(t *testing.T) {
+	src := `package main
+
+import "sync"
+
+var version = "1.0"
+
+var defaults = map[string]int{}
+
+var counter int
+
+var cache = map[string]string{}
+
+var registry []string
+
+var mu sync.Mutex
+
+func init() {
+	defaults["size"] = 10
+}
+
+func inc() {
+	counter++
+}
+
+func remember(k, v string) {
+	mu.Lock()
+	defer mu.Unlock()
+	cache[k] = v
+}
+
+func forget(k string) {
+	delete(cache, k)
+}
+
+func register(name string) {
+	registry = append(registry, name)
+}
+
+func main() {
+	println(version, defaults["size"])
+	inc()
+	remember("a", "b")
+	forget("a")
+	go func() {
+		register("x")
+	}()
+}
+`
+	prog := loadTestProgram(t, map[string]string{"main.go": src})
+	var buf bytes.Buffer
+	if err := writeGlobals(&buf, globalState(prog)); err != nil {
+		t.Fatal(err)
+	}
+	assertLines(t, strings.Split(strings.TrimSpace(buf.String()), "\n"), []string{
+		"example.com/m.cache\tmutated",
+		"  mutated by example.com/m.forget",
+		"  mutated by example.com/m.remember",
+		"example.com/m.counter\tmutated",
+		"  mutated by example.com/m.inc",
+		"example.com/m.defaults\tconstant-after-init",
+		"example.com/m.mu\tescapes",
+		"  escapes via example.com/m.remember",
+		"example.com/m.registry\tmutated",
+		"  mutated by example.com/m.register",
+		"example.com/m.version\tconstant-after-init",
+	})
+}
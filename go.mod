@@ -2,9 +2,13 @@ module github.com/kis9a/learn_ast
 
 go 1.22.1
 
-require golang.org/x/tools v0.22.0
+require (
+	go.starlark.net v0.0.0-20231121155337-90ade8b19d09
+	golang.org/x/tools v0.22.0
+)
 
 require (
 	golang.org/x/mod v0.18.0 // indirect
 	golang.org/x/sync v0.7.0 // indirect
+	golang.org/x/sys v0.21.0 // indirect
 )
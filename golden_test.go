@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files under testdata")
+
+// checkGolden は got を testdata/<name>.golden と比べる。-update を指定すると golden ファイルを got で書き換える
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", filepath.FromSlash(name)+".golden")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test -run %s -update to create it)", err, t.Name())
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s (run go test -run %s -update to accept it):\n%s", path, t.Name(), lineDiff(string(want), string(got)))
+	}
+}
+
+// lineDiff は want と got の行の違いを、共通の先頭と末尾を除いて - と + の行で表す
+func lineDiff(want, got string) string {
+	a, b := strings.SplitAfter(want, "\n"), strings.SplitAfter(got, "\n")
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	j, k := len(a), len(b)
+	for j > i && k > i && a[j-1] == b[k-1] {
+		j, k = j-1, k-1
+	}
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "@@ line %d @@\n", i+1)
+	for _, l := range a[i:j] {
+		buf.WriteString("-" + strings.TrimSuffix(l, "\n") + "\n")
+	}
+	for _, l := range b[i:k] {
+		buf.WriteString("+" + strings.TrimSuffix(l, "\n") + "\n")
+	}
+	return buf.String()
+}
+
+// TestGoldenCommands は testdata/golden/<ケース> のモジュールで commands.txt の各行のコマンドを実行し、
+// 標準出力を testdata/golden/<ケース>/<名前>.golden と比べる。commands.txt の行は「名前: コマンド 引数...」で、
+// 引数は空白で区切る (テンプレートなど空白を含むものはファイルにしておく)
+func TestGoldenCommands(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "golden", "*", "commands.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no golden test cases")
+	}
+	for _, file := range files {
+		dir := filepath.Dir(file)
+		f, err := os.Open(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			line := strings.TrimSpace(sc.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			name, cmdline, ok := strings.Cut(line, ":")
+			args := strings.Fields(cmdline)
+			if !ok || len(args) == 0 || commands[args[0]].run == nil {
+				t.Fatalf("%s: invalid line %q", file, line)
+			}
+			t.Run(filepath.Base(dir)+"/"+name, func(t *testing.T) {
+				out := runCommandIn(t, dir, args)
+				checkGolden(t, filepath.ToSlash(filepath.Join("golden", filepath.Base(dir), name)), out)
+			})
+		}
+		f.Close()
+	}
+}
+
+// runCommandIn は dir をカレントディレクトリにして args のコマンドを実行し、標準出力を返す。
+// 位置は dir からの相対パスで出力し、エラーは出力の最後に error: の行として加える
+func runCommandIn(t *testing.T, dir string, args []string) []byte {
+	t.Helper()
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(abs); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	defer func(old string) { outputRoot = old }(outputRoot)
+	outputRoot = abs
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	done := make(chan []byte)
+	go func() {
+		out, _ := io.ReadAll(r)
+		done <- out
+	}()
+	runErr := commands[args[0]].run(args[1:])
+	os.Stdout = stdout
+	w.Close()
+	out := <-done
+	if runErr != nil {
+		out = append(out, "error: "+runErr.Error()+"\n"...)
+	}
+	return out
+}
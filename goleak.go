@@ -0,0 +1,545 @@
+package main
+
+import (
+	"fmt"
+	"go/constant"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/ssa"
+)
+
+// checkGoroutineLeaks は go 文で起動する関数の CFG を調べ、どの return にも必ず通るチャネルの操作が
+// 進めないために終了できない goroutine を指摘する。次の場合を進めないと見なす
+//   - 送信するチャネルを、main などのエントリポイントから到達できる他のコードが受信しない
+//   - 受信するチャネルに、到達できる他のコードが送信もクローズもしない
+//   - range (ok が false の分岐) でしか抜けられないループで受信するチャネルを、どこもクローズしない
+//   - default のない select の、return につながるどのケースも上のどれかで進めない
+//
+// 中身のわからない関数に渡されるなど、出どころや使われ方を追えないチャネルは進めるものとする
+func checkGoroutineLeaks(prog *Program) []Diagnostic {
+	a := newChanAnalysis(prog)
+	reachable := reachableFunctions(prog, entryFunctions(prog, allEntryKinds))
+	var diags []Diagnostic
+	for _, fn := range prog.targetFunctions() {
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				g, ok := instr.(*ssa.Go)
+				if !ok {
+					continue
+				}
+				callee := g.Call.StaticCallee()
+				if callee == nil || callee.Blocks == nil {
+					continue
+				}
+				if reason := a.blockingExit(callee, reachable); reason != "" {
+					diags = append(diags, newDiagnostic(prog.Fset, g.Pos(), "goleak",
+						"goroutine running %s may never exit: %s", callee, reason))
+				}
+			}
+		}
+	}
+	sortDiagnostics(diags)
+	return diags
+}
+
+// チャネルの操作の種類
+const (
+	chanSend = iota
+	chanRecv
+	chanClose
+)
+
+// chanOrigin はチャネルの出どころ。make で作ったチャネルか、チャネルを入れる構造体のフィールドかパッケージ変数
+type chanOrigin struct {
+	make *ssa.MakeChan
+	v    *types.Var
+}
+
+// chanOps は 1 つの出どころのチャネルに対する操作
+type chanOps struct {
+	ops     [3][]ssa.Instruction // 操作の種類ごとの命令
+	escaped bool                 // 中身のわからない関数に渡されるなど、追えないところで使われる
+}
+
+// chanAnalysis は解析対象の関数のチャネルの出どころと操作をまとめたもの
+type chanAnalysis struct {
+	prog   *Program
+	cg     *callgraph.Graph
+	parent map[chanOrigin]chanOrigin // 同じチャネルと見なす出どころ (フィールドに入れた make など) の union-find
+	byOrig map[chanOrigin]*chanOps
+}
+
+func newChanAnalysis(prog *Program) *chanAnalysis {
+	a := &chanAnalysis{
+		prog:   prog,
+		cg:     prog.CallGraph(),
+		parent: make(map[chanOrigin]chanOrigin),
+		byOrig: make(map[chanOrigin]*chanOps),
+	}
+	for _, fn := range prog.targetFunctions() {
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				a.visit(instr)
+			}
+		}
+	}
+	return a
+}
+
+// visit は命令でのチャネルの使われ方を記録する
+func (a *chanAnalysis) visit(instr ssa.Instruction) {
+	switch instr := instr.(type) {
+	case *ssa.Send:
+		a.use(instr.Chan, chanSend, instr)
+		a.escape(instr.X)
+	case *ssa.UnOp:
+		if instr.Op == token.ARROW {
+			a.use(instr.X, chanRecv, instr)
+		}
+	case *ssa.Select:
+		for _, st := range instr.States {
+			if st.Dir == types.SendOnly {
+				a.use(st.Chan, chanSend, instr)
+				a.escape(st.Send)
+			} else {
+				a.use(st.Chan, chanRecv, instr)
+			}
+		}
+	case ssa.CallInstruction:
+		common := instr.Common()
+		if b, ok := common.Value.(*ssa.Builtin); ok {
+			if b.Name() == "close" {
+				a.use(common.Args[0], chanClose, instr)
+			}
+			return
+		}
+		// 中身のある関数の引数は Parameter からたどる
+		if callee := common.StaticCallee(); callee != nil && callee.Blocks != nil {
+			return
+		}
+		for _, arg := range common.Args {
+			a.escape(arg)
+		}
+	case *ssa.Store:
+		switch addr := instr.Addr.(type) {
+		case *ssa.FieldAddr, *ssa.Global:
+			origins, _ := a.origins(instr.Val)
+			for _, o := range origins {
+				a.union(o, addrOrigin(addr))
+			}
+		case *ssa.Alloc:
+			// ローカル変数から読むときに保存した値をたどる
+		default:
+			a.escape(instr.Val)
+		}
+	case *ssa.Return:
+		if a.callers(instr.Parent()) == nil {
+			for _, r := range instr.Results {
+				a.escape(r)
+			}
+		}
+	case *ssa.MakeInterface:
+		a.escape(instr.X)
+	case *ssa.MapUpdate:
+		a.escape(instr.Key)
+		a.escape(instr.Value)
+	}
+}
+
+func (a *chanAnalysis) get(o chanOrigin) *chanOps {
+	ops, ok := a.byOrig[o]
+	if !ok {
+		ops = &chanOps{}
+		a.byOrig[o] = ops
+	}
+	return ops
+}
+
+func (a *chanAnalysis) use(ch ssa.Value, kind int, instr ssa.Instruction) {
+	origins, _ := a.origins(ch)
+	for _, o := range origins {
+		ops := a.get(o)
+		ops.ops[kind] = append(ops.ops[kind], instr)
+	}
+}
+
+func (a *chanAnalysis) escape(v ssa.Value) {
+	if !isChanType(v.Type()) {
+		return
+	}
+	origins, _ := a.origins(v)
+	for _, o := range origins {
+		a.get(o).escaped = true
+	}
+}
+
+func (a *chanAnalysis) find(o chanOrigin) chanOrigin {
+	for {
+		p, ok := a.parent[o]
+		if !ok {
+			return o
+		}
+		o = p
+	}
+}
+
+func (a *chanAnalysis) union(x, y chanOrigin) {
+	if x, y = a.find(x), a.find(y); x != y {
+		a.parent[x] = y
+	}
+}
+
+// callers は fn を呼び出す箇所を返す。解析対象の外から呼ばれうる関数 (main 以外のパッケージの
+// エクスポートされた関数とメソッド) や、呼び出し元が見つからない関数なら nil を返す
+func (a *chanAnalysis) callers(fn *ssa.Function) []*callgraph.Edge {
+	if obj := fn.Object(); obj != nil && obj.Exported() && fn.Pkg != nil && fn.Pkg.Pkg.Name() != "main" {
+		return nil
+	}
+	if node := a.cg.Nodes[fn]; node != nil {
+		return node.In
+	}
+	return nil
+}
+
+// origins は v のチャネルの出どころを返す。φ、無名関数が捕捉した変数、引数、呼び出した関数の戻り値、
+// ローカル変数に保存された値をたどる。たどれない値があれば unknown を true にする。nil のチャネルには出どころがない
+func (a *chanAnalysis) origins(v ssa.Value) (origins []chanOrigin, unknown bool) {
+	seen := make(map[ssa.Value]bool)
+	added := make(map[chanOrigin]bool)
+	add := func(o chanOrigin) {
+		if !added[o] {
+			added[o] = true
+			origins = append(origins, o)
+		}
+	}
+	var walk, walkAddr func(v ssa.Value)
+	walkResults := func(call *ssa.Call, index int) {
+		callee := call.Call.StaticCallee()
+		if callee == nil || callee.Blocks == nil {
+			unknown = true
+			return
+		}
+		for _, b := range callee.Blocks {
+			if ret, ok := b.Instrs[len(b.Instrs)-1].(*ssa.Return); ok {
+				walk(ret.Results[index])
+			}
+		}
+	}
+	// walkAddr は読み出したアドレスに保存されうるチャネルをたどる
+	walkAddr = func(addr ssa.Value) {
+		switch addr := addr.(type) {
+		case *ssa.FieldAddr, *ssa.Global:
+			add(addrOrigin(addr))
+		case *ssa.Alloc:
+			for _, ref := range *addr.Referrers() {
+				if store, ok := ref.(*ssa.Store); ok && store.Addr == addr {
+					walk(store.Val)
+				}
+			}
+		case *ssa.FreeVar:
+			for _, b := range closureBindings(addr) {
+				walkAddr(b)
+			}
+		default:
+			unknown = true
+		}
+	}
+	walk = func(v ssa.Value) {
+		if seen[v] {
+			return
+		}
+		seen[v] = true
+		switch v := v.(type) {
+		case *ssa.MakeChan:
+			add(chanOrigin{make: v})
+		case *ssa.Const:
+			unknown = unknown || !v.IsNil()
+		case *ssa.Phi:
+			for _, e := range v.Edges {
+				walk(e)
+			}
+		case *ssa.ChangeType:
+			walk(v.X)
+		case *ssa.UnOp:
+			if v.Op != token.MUL {
+				unknown = true
+				return
+			}
+			walkAddr(v.X)
+		case *ssa.Field:
+			add(chanOrigin{v: structField(v.X.Type(), v.Field)})
+		case *ssa.FreeVar:
+			bindings := closureBindings(v)
+			if bindings == nil {
+				unknown = true
+			}
+			for _, b := range bindings {
+				walk(b)
+			}
+		case *ssa.Parameter:
+			edges := a.callers(v.Parent())
+			if edges == nil {
+				unknown = true
+			}
+			index := paramIndex(v.Parent(), v)
+			for _, e := range edges {
+				if e.Site == nil {
+					unknown = true
+					continue
+				}
+				common := e.Site.Common()
+				i := index
+				if common.IsInvoke() {
+					i--
+				}
+				if i < 0 || i >= len(common.Args) {
+					unknown = true
+					continue
+				}
+				walk(common.Args[i])
+			}
+		case *ssa.Call:
+			walkResults(v, 0)
+		case *ssa.Extract:
+			if call, ok := v.Tuple.(*ssa.Call); ok {
+				walkResults(call, v.Index)
+				return
+			}
+			unknown = true
+		default:
+			unknown = true
+		}
+	}
+	walk(v)
+	return origins, unknown
+}
+
+// closureBindings は無名関数の捕捉した変数 v に、無名関数を作る箇所で渡される値を返す
+func closureBindings(v *ssa.FreeVar) []ssa.Value {
+	fn := v.Parent()
+	index := -1
+	for i, fv := range fn.FreeVars {
+		if fv == v {
+			index = i
+		}
+	}
+	var bindings []ssa.Value
+	if refs := fn.Referrers(); refs != nil && index >= 0 {
+		for _, ref := range *refs {
+			if mc, ok := ref.(*ssa.MakeClosure); ok && mc.Fn == fn {
+				bindings = append(bindings, mc.Bindings[index])
+			}
+		}
+	}
+	return bindings
+}
+
+// addrOrigin はフィールドかパッケージ変数のアドレス addr に保存されるチャネルの出どころを返す
+func addrOrigin(addr ssa.Value) chanOrigin {
+	switch addr := addr.(type) {
+	case *ssa.FieldAddr:
+		return chanOrigin{v: structField(addr.X.Type().Underlying().(*types.Pointer).Elem(), addr.Field)}
+	case *ssa.Global:
+		v, _ := addr.Object().(*types.Var)
+		return chanOrigin{v: v}
+	}
+	return chanOrigin{}
+}
+
+func structField(t types.Type, index int) *types.Var {
+	return t.Underlying().(*types.Struct).Field(index)
+}
+
+func isChanType(t types.Type) bool {
+	_, ok := t.Underlying().(*types.Chan)
+	return ok
+}
+
+// canProceed は ch に対する操作 self が、reachable な関数の他の操作によって進めるかどうかを返す。
+// need は相手に必要な操作の種類
+func (a *chanAnalysis) canProceed(ch ssa.Value, self ssa.Instruction, reachable map[*ssa.Function]bool, need ...int) bool {
+	origins, unknown := a.origins(ch)
+	if unknown {
+		return true
+	}
+	roots := make(map[chanOrigin]bool)
+	for _, o := range origins {
+		roots[a.find(o)] = true
+	}
+	for o, ops := range a.byOrig {
+		if !roots[a.find(o)] {
+			continue
+		}
+		if ops.escaped {
+			return true
+		}
+		for _, kind := range need {
+			for _, instr := range ops.ops[kind] {
+				if instr != self && reachable[instr.Parent()] {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// describe はチャネル ch の出どころを説明する
+func (a *chanAnalysis) describe(ch ssa.Value) string {
+	origins, _ := a.origins(ch)
+	if len(origins) == 0 {
+		return "a nil channel"
+	}
+	switch o := origins[0]; {
+	case o.make != nil:
+		return fmt.Sprintf("the channel made at line %d", a.prog.Fset.Position(o.make.Pos()).Line)
+	case o.v.IsField():
+		return "field " + o.v.Name()
+	default:
+		return "variable " + o.v.Name()
+	}
+}
+
+// blockingExit は fn のどの return にも必ず通るチャネルの操作のうち、進めないものがあればその説明を返す
+func (a *chanAnalysis) blockingExit(fn *ssa.Function, reachable map[*ssa.Function]bool) string {
+	var exits []*ssa.BasicBlock
+	for _, b := range fn.Blocks {
+		if _, ok := b.Instrs[len(b.Instrs)-1].(*ssa.Return); ok {
+			exits = append(exits, b)
+		}
+	}
+	// return のない関数は終わらないように書かれている
+	if len(exits) == 0 {
+		return ""
+	}
+	line := func(instr ssa.Instruction) int { return a.prog.Fset.Position(instr.Pos()).Line }
+	for _, b := range fn.Blocks {
+		if !dominatesAll(b, exits) {
+			continue
+		}
+		for _, instr := range b.Instrs {
+			switch instr := instr.(type) {
+			case *ssa.Send:
+				if !a.canProceed(instr.Chan, instr, reachable, chanRecv) {
+					return fmt.Sprintf("the send at line %d on %s has no receiver", line(instr), a.describe(instr.Chan))
+				}
+			case *ssa.UnOp:
+				if instr.Op != token.ARROW {
+					continue
+				}
+				if exitsOnClose(instr, exits) {
+					if !a.canProceed(instr.X, instr, reachable, chanClose) {
+						return fmt.Sprintf("it exits only when %s received from at line %d is closed, but nothing closes it",
+							a.describe(instr.X), line(instr))
+					}
+				} else if !a.canProceed(instr.X, instr, reachable, chanSend, chanClose) {
+					return fmt.Sprintf("the receive at line %d from %s has no sender and the channel is never closed", line(instr), a.describe(instr.X))
+				}
+			case *ssa.Select:
+				if !instr.Blocking {
+					continue
+				}
+				states := selectExitStates(instr, exits)
+				proceeds := false
+				for _, i := range states {
+					st := instr.States[i]
+					need := []int{chanSend, chanClose}
+					if st.Dir == types.SendOnly {
+						need = []int{chanRecv}
+					}
+					proceeds = proceeds || a.canProceed(st.Chan, instr, reachable, need...)
+				}
+				if proceeds {
+					continue
+				}
+				if len(states) < len(instr.States) {
+					return fmt.Sprintf("it exits only through cases of the select at line %d that cannot proceed", line(instr))
+				}
+				return fmt.Sprintf("no case of the select at line %d can proceed", line(instr))
+			}
+		}
+	}
+	return ""
+}
+
+func dominatesAll(b *ssa.BasicBlock, blocks []*ssa.BasicBlock) bool {
+	for _, c := range blocks {
+		if !b.Dominates(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// exitsOnClose は ok 付きの受信 recv の ok が false の分岐からしか exits に行けない (range で受信している) かどうかを返す
+func exitsOnClose(recv *ssa.UnOp, exits []*ssa.BasicBlock) bool {
+	if !recv.CommaOk {
+		return false
+	}
+	for _, ref := range *recv.Referrers() {
+		ok, isExtract := ref.(*ssa.Extract)
+		if !isExtract || ok.Index != 1 {
+			continue
+		}
+		for _, ref := range *ok.Referrers() {
+			if cond, isIf := ref.(*ssa.If); isIf && dominatesAll(cond.Block().Succs[1], exits) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// selectExitStates は select のケースのうち、本体から exits に行けるものの番号を返す。
+// どのケースの本体も通らずに行ける exit があれば (select の後で合流するなら) すべてのケースを返す
+func selectExitStates(sel *ssa.Select, exits []*ssa.BasicBlock) []int {
+	bodies := make(map[*ssa.BasicBlock]int) // ケースの本体の最初のブロックからケースの番号
+	for _, ref := range *sel.Referrers() {
+		idx, ok := ref.(*ssa.Extract)
+		if !ok || idx.Index != 0 {
+			continue
+		}
+		for _, ref := range *idx.Referrers() {
+			eq, ok := ref.(*ssa.BinOp)
+			if !ok || eq.Op != token.EQL {
+				continue
+			}
+			c, ok := eq.Y.(*ssa.Const)
+			if !ok || c.Value == nil || c.Value.Kind() != constant.Int {
+				continue
+			}
+			i, _ := constant.Int64Val(c.Value)
+			for _, ref := range *eq.Referrers() {
+				if cond, ok := ref.(*ssa.If); ok {
+					bodies[cond.Block().Succs[0]] = int(i)
+				}
+			}
+		}
+	}
+	var all []int
+	for i := range sel.States {
+		all = append(all, i)
+	}
+	states := make(map[int]bool)
+	for _, exit := range exits {
+		found := false
+		for body, i := range bodies {
+			if body.Dominates(exit) {
+				states[i] = true
+				found = true
+			}
+		}
+		if !found {
+			return all
+		}
+	}
+	var result []int
+	for _, i := range all {
+		if states[i] {
+			result = append(result, i)
+		}
+	}
+	return result
+}
@@ -0,0 +1,101 @@
+package main
+
+import "testing"
+
+func TestCheckGoroutineLeaks(t *testing.T) {
+	src := `package main
+
+import (
+	"context"
+	"fmt"
+)
+
+type pool struct {
+	jobs chan int
+}
+
+func newPool() *pool {
+	return &pool{jobs: make(chan int)}
+}
+
+func (p *pool) run() {
+	for j := range p.jobs {
+		fmt.Println(j)
+	}
+}
+
+func (p *pool) submit(j int) {
+	p.jobs <- j
+}
+
+func orphanSend() {
+	ch := make(chan int)
+	go func() {
+		ch <- 1
+	}()
+}
+
+func handled() int {
+	ch := make(chan int, 1)
+	go func() {
+		ch <- 1
+	}()
+	return <-ch
+}
+
+func worker(ctx context.Context, in chan int) {
+	for {
+		select {
+		case v := <-in:
+			fmt.Println(v)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func main() {
+	p := newPool()
+	go p.run()
+	p.submit(1)
+	orphanSend()
+	handled()
+	go worker(context.Background(), make(chan int))
+
+	never := make(chan int)
+	go func() {
+		fmt.Println(<-never)
+	}()
+
+	ticks := make(chan int)
+	quit := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-quit:
+				return
+			case t := <-ticks:
+				fmt.Println(t)
+			}
+		}
+	}()
+	ticks <- 1
+
+	results := make(chan int)
+	go func() {
+		for r := range results {
+			fmt.Println(r)
+		}
+	}()
+	results <- 1
+	close(results)
+}
+`
+	prog := loadTestProgram(t, map[string]string{"main.go": src})
+	assertLines(t, diagnosticMessages(checkGoroutineLeaks(prog)), []string{
+		"main.go:28: goroutine running example.com/m.orphanSend$1 may never exit: the send at line 29 on the channel made at line 27 has no receiver",
+		"main.go:54: goroutine running (*example.com/m.pool).run may never exit: it exits only when field jobs received from at line 17 is closed, but nothing closes it",
+		"main.go:61: goroutine running example.com/m.main$1 may never exit: the receive at line 62 from the channel made at line 60 has no sender and the channel is never closed",
+		"main.go:67: goroutine running example.com/m.main$2 may never exit: it exits only through cases of the select at line 69 that cannot proceed",
+	})
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/token"
+	"go/types"
+	"go/version"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// GoVersionReport はモジュールのコードが必要とする最小の Go のバージョン
+type GoVersionReport struct {
+	Module   string           `json:"module"`
+	Declared string           `json:"declared,omitempty"` // go.mod の go ディレクティブ (go1.N.M の形)
+	Required string           `json:"required"`
+	Mismatch string           `json:"mismatch,omitempty"` // Declared が Required より低いか高いときの説明
+	Features []VersionFeature `json:"features,omitempty"` // バージョンの新しい順
+}
+
+// VersionFeature は特定のバージョンから使える言語機能か標準ライブラリの宣言と、最初に使っている位置
+type VersionFeature struct {
+	Version string         `json:"version"`
+	Feature string         `json:"feature"`
+	Pos     token.Position `json:"pos"`
+}
+
+// minModuleVersion は go.mod を使うのに必要なバージョン。これより低いものは報告しない
+const minModuleVersion = "go1.11"
+
+func runGoVersion(args []string) error {
+	fs := flag.NewFlagSet("goversion", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output as JSON")
+	fs.Parse(args)
+	prog, err := loadProgramWith(loadOptions{Tests: true}, ".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	reports := requiredGoVersions(prog)
+	for i := range reports {
+		relativizePositions(reports[i].Features)
+	}
+	if *asJSON {
+		return writeJSON(os.Stdout, reports)
+	}
+	return writeGoVersions(os.Stdout, reports)
+}
+
+// requiredGoVersions は解析対象のパッケージをモジュールごとにまとめ、使っている言語機能と標準ライブラリの宣言から
+// 必要な最小のバージョンを求めて go.mod の go ディレクティブと比べる。標準ライブラリの宣言が追加されたバージョンは
+// GOROOT/api の一覧から読み取る (見つからなければ言語機能だけで判断する)
+func requiredGoVersions(prog *Program) []GoVersionReport {
+	apiVersions := stdlibAPIVersions()
+	byModule := make(map[string]*GoVersionReport)
+	seenFeature := make(map[*GoVersionReport]map[string]bool)
+	seenFile := make(map[string]bool)
+	for _, pkg := range prog.Packages {
+		module, declared := "", ""
+		if pkg.Module != nil {
+			module = pkg.Module.Path
+			if pkg.Module.GoVersion != "" {
+				declared = "go" + pkg.Module.GoVersion
+			}
+		}
+		r := byModule[module]
+		if r == nil {
+			r = &GoVersionReport{Module: module, Declared: declared, Required: minModuleVersion}
+			byModule[module] = r
+			seenFeature[r] = make(map[string]bool)
+		}
+		record := func(v, feature string, pos token.Pos) {
+			if seenFeature[r][feature] || version.Compare(v, minModuleVersion) <= 0 {
+				return
+			}
+			seenFeature[r][feature] = true
+			r.Features = append(r.Features, VersionFeature{Version: v, Feature: feature, Pos: prog.Fset.Position(pos)})
+			if version.Compare(v, r.Required) > 0 {
+				r.Required = v
+			}
+		}
+		// テストを読み込むと同じファイルが複数のパッケージに現れるので、最初のものだけを調べる
+		files := make(map[*token.File]bool)
+		for _, file := range pkg.Syntax {
+			name := prog.Fset.Position(file.Pos()).Filename
+			if !seenFile[name] {
+				seenFile[name] = true
+				files[prog.Fset.File(file.Pos())] = true
+				languageFeatures(pkg.TypesInfo, file, record)
+			}
+		}
+		for id, obj := range pkg.TypesInfo.Uses {
+			if !files[prog.Fset.File(id.Pos())] {
+				continue
+			}
+			if v, ok := apiVersions[apiKey(obj)]; ok {
+				record(v, apiKey(obj), id.Pos())
+			}
+		}
+	}
+	var reports []GoVersionReport
+	for _, r := range byModule {
+		sort.Slice(r.Features, func(i, j int) bool {
+			a, b := r.Features[i], r.Features[j]
+			if c := version.Compare(a.Version, b.Version); c != 0 {
+				return c > 0
+			}
+			return a.Feature < b.Feature
+		})
+		if r.Declared != "" {
+			switch declared := version.Lang(r.Declared); version.Compare(declared, r.Required) {
+			case -1:
+				r.Mismatch = fmt.Sprintf("go.mod declares %s but the code needs %s", declared, r.Required)
+			case 1:
+				r.Mismatch = fmt.Sprintf("go.mod declares %s but %s would be enough", declared, r.Required)
+			}
+		}
+		reports = append(reports, *r)
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Module < reports[j].Module })
+	return reports
+}
+
+// languageFeatures は file の中のバージョンの決まった言語機能を record に渡す。
+// go1.22 以降のファイルで関数リテラルが for のループ変数を捕捉していれば、go1.22 より下げるとループ変数が
+// 繰り返しごとに作られなくなり動作が変わるので go1.22 を必要とする
+func languageFeatures(info *types.Info, file *ast.File, record func(v, feature string, pos token.Pos)) {
+	perIteration := version.Compare(info.FileVersions[file], "go1.22") >= 0
+	loopVars := make(map[types.Object]bool)
+	defineLoopVars := func(exprs ...ast.Expr) {
+		for _, e := range exprs {
+			if id, ok := e.(*ast.Ident); ok && info.Defs[id] != nil {
+				loopVars[info.Defs[id]] = true
+			}
+		}
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.ForStmt:
+			if init, ok := n.Init.(*ast.AssignStmt); ok && init.Tok == token.DEFINE {
+				defineLoopVars(init.Lhs...)
+			}
+		case *ast.FuncLit:
+			if !perIteration || len(loopVars) == 0 {
+				break
+			}
+			ast.Inspect(n.Body, func(n ast.Node) bool {
+				if id, ok := n.(*ast.Ident); ok && loopVars[info.Uses[id]] {
+					record("go1.22", "per-iteration loop variables captured by closures", id.Pos())
+				}
+				return true
+			})
+		case *ast.FuncType:
+			if n.TypeParams != nil {
+				record("go1.18", "generic functions", n.TypeParams.Pos())
+			}
+		case *ast.TypeSpec:
+			if n.TypeParams != nil {
+				record("go1.18", "generic types", n.TypeParams.Pos())
+			}
+		case *ast.Ident:
+			switch obj := info.Uses[n]; obj {
+			case types.Universe.Lookup("any"), types.Universe.Lookup("comparable"):
+				record("go1.18", obj.Name(), n.Pos())
+			case types.Universe.Lookup("min"), types.Universe.Lookup("max"), types.Universe.Lookup("clear"):
+				record("go1.21", "builtin "+obj.Name(), n.Pos())
+			}
+		case *ast.BasicLit:
+			if n.Kind == token.INT || n.Kind == token.FLOAT || n.Kind == token.IMAG {
+				lit := strings.ToLower(n.Value)
+				switch {
+				case strings.HasPrefix(lit, "0b"), strings.HasPrefix(lit, "0o"):
+					record("go1.13", "binary and octal literals", n.Pos())
+				case strings.Contains(lit, "_"):
+					record("go1.13", "digit separators", n.Pos())
+				}
+			}
+		case *ast.RangeStmt:
+			if n.Tok == token.DEFINE {
+				defineLoopVars(n.Key, n.Value)
+			}
+			switch t := info.TypeOf(n.X); {
+			case t == nil:
+			case isInteger(t):
+				record("go1.22", "range over int", n.Pos())
+			case isSignature(t):
+				record("go1.23", "range over func", n.Pos())
+			}
+		case *ast.CallExpr:
+			if tv := info.Types[n.Fun]; tv.IsType() && len(n.Args) == 1 {
+				if _, ok := info.TypeOf(n.Args[0]).Underlying().(*types.Slice); !ok {
+					break
+				}
+				switch t := tv.Type.Underlying().(type) {
+				case *types.Array:
+					record("go1.20", "slice to array conversion", n.Pos())
+				case *types.Pointer:
+					if _, ok := t.Elem().Underlying().(*types.Array); ok {
+						record("go1.17", "slice to array pointer conversion", n.Pos())
+					}
+				}
+			}
+		}
+		return true
+	})
+}
+
+func isSignature(t types.Type) bool {
+	_, ok := t.Underlying().(*types.Signature)
+	return ok
+}
+
+// apiKey は標準ライブラリの宣言 obj の、GOROOT/api の一覧での名前 (path.Name か path.Type.Member) を返す
+func apiKey(obj types.Object) string {
+	if obj.Pkg() == nil || !isStdPackage(obj.Pkg().Path()) || strings.Contains(obj.Pkg().Path(), "internal") {
+		return ""
+	}
+	path := obj.Pkg().Path()
+	if obj.Parent() == obj.Pkg().Scope() {
+		return path + "." + obj.Name()
+	}
+	var recv types.Type
+	switch obj := obj.(type) {
+	case *types.Func:
+		if sig, ok := obj.Type().(*types.Signature); ok && sig.Recv() != nil {
+			recv = sig.Recv().Type()
+		}
+	case *types.Var:
+		if !obj.IsField() {
+			return ""
+		}
+		// フィールドの持ち主の型は構造体の型からはわからないので、同じパッケージの名前付きの型から探す
+		for _, name := range obj.Pkg().Scope().Names() {
+			tn, ok := obj.Pkg().Scope().Lookup(name).(*types.TypeName)
+			if !ok {
+				continue
+			}
+			if st, ok := tn.Type().Underlying().(*types.Struct); ok {
+				for i := 0; i < st.NumFields(); i++ {
+					if st.Field(i) == obj {
+						return path + "." + name + "." + obj.Name()
+					}
+				}
+			}
+		}
+	}
+	if recv == nil {
+		return ""
+	}
+	if p, ok := recv.(*types.Pointer); ok {
+		recv = p.Elem()
+	}
+	named, ok := recv.(*types.Named)
+	if !ok {
+		return ""
+	}
+	return path + "." + named.Obj().Name() + "." + obj.Name()
+}
+
+var stdlibAPIVersions = sync.OnceValue(func() map[string]string {
+	versions := make(map[string]string)
+	files, _ := filepath.Glob(filepath.Join(build.Default.GOROOT, "api", "go1.*.txt"))
+	// go1.txt (Go 1.0) のものは minModuleVersion より古いので読まない
+	sort.Slice(files, func(i, j int) bool { return version.Compare(apiFileVersion(files[i]), apiFileVersion(files[j])) < 0 })
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			continue
+		}
+		v := apiFileVersion(name)
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			if key := parseAPILine(sc.Text()); key != "" {
+				if _, ok := versions[key]; !ok {
+					versions[key] = v
+				}
+			}
+		}
+		f.Close()
+	}
+	return versions
+})
+
+func apiFileVersion(name string) string {
+	return strings.TrimSuffix(filepath.Base(name), ".txt")
+}
+
+// parseAPILine は GOROOT/api の一覧の 1 行 (例: "pkg slices, func Sort[...](...)",
+// "pkg net/http, method (*Request) PathValue(string) string", "pkg net/http, type Request struct, Pattern string")
+// から宣言の名前を返す
+func parseAPILine(line string) string {
+	rest, ok := strings.CutPrefix(line, "pkg ")
+	if !ok {
+		return ""
+	}
+	pkg, decl, ok := strings.Cut(rest, ", ")
+	if !ok {
+		return ""
+	}
+	pkg, _, _ = strings.Cut(pkg, " ") // "syscall (linux-386)" のような環境の指定
+	ident := func(s string) string {
+		if i := strings.IndexAny(s, " ([,"); i >= 0 {
+			return s[:i]
+		}
+		return s
+	}
+	kind, decl, _ := strings.Cut(decl, " ")
+	switch kind {
+	case "func", "const", "var":
+		return pkg + "." + ident(decl)
+	case "method":
+		recv, name, ok := strings.Cut(strings.TrimPrefix(decl, "("), ") ")
+		if !ok {
+			return ""
+		}
+		return pkg + "." + ident(strings.TrimPrefix(recv, "*")) + "." + ident(name)
+	case "type":
+		name := ident(decl)
+		// "type T struct, F int" はフィールド、"type I interface, M()" はインターフェースのメソッド
+		if _, member, ok := strings.Cut(decl, ", "); ok {
+			if member = ident(member); member != "embedded" {
+				return pkg + "." + name + "." + member
+			}
+			return ""
+		}
+		return pkg + "." + name
+	}
+	return ""
+}
+
+func writeGoVersions(w io.Writer, reports []GoVersionReport) error {
+	for _, r := range reports {
+		module := r.Module
+		if module == "" {
+			module = "(no module)"
+		}
+		fmt.Fprintf(w, "%s: requires %s", module, r.Required)
+		if r.Declared != "" {
+			fmt.Fprintf(w, " (go.mod: %s)", version.Lang(r.Declared))
+		}
+		fmt.Fprintln(w)
+		if r.Mismatch != "" {
+			fmt.Fprintf(w, "  mismatch: %s\n", r.Mismatch)
+		}
+		for _, f := range r.Features {
+			fmt.Fprintf(w, "  %s %s at %s:%d\n", f.Version, f.Feature, f.Pos.Filename, f.Pos.Line)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+const loopClosureSrc = `package main
+
+import "fmt"
+
+func main() {
+	var fs []func()
+	for i := 0; i < 3; i++ {
+		fs = append(fs, func() { fmt.Println(i) })
+	}
+	for _, f := range fs {
+		f()
+	}
+}
+`
+
+func TestRequiredGoVersions(t *testing.T) {
+	for _, tt := range []struct {
+		goMod, src string
+		want       []string
+	}{
+		{"1.24", `package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+func first[T any](s []T) T { return s[0] }
+
+func main() {
+	s := []int{3, 1_000, 2}
+	slices.Sort(s)
+	for i := range 3 {
+		fmt.Println(min(i, first(s)), strings.ToUpper("x"))
+	}
+}
+`, []string{
+			"example.com/m go1.22 go.mod declares go1.24 but go1.22 would be enough",
+			"go1.22 range over int main.go:14",
+			"go1.21 builtin min main.go:15",
+			"go1.21 slices.Sort main.go:13",
+			"go1.18 any main.go:9",
+			"go1.18 generic functions main.go:9",
+			"go1.13 digit separators main.go:12",
+		}},
+		{"1.20", `package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+func main() {
+	var r *http.Request
+	fmt.Println(slices.Contains([]string{"a"}, r.Pattern), r.PathValue("id"))
+}
+`, []string{
+			"example.com/m go1.23 go.mod declares go1.20 but the code needs go1.23",
+			"go1.23 net/http.Request.Pattern main.go:11",
+			"go1.22 net/http.Request.PathValue main.go:11",
+			"go1.21 slices.Contains main.go:11",
+		}},
+		{"1.24", loopClosureSrc, []string{
+			"example.com/m go1.22 go.mod declares go1.24 but go1.22 would be enough",
+			"go1.22 per-iteration loop variables captured by closures main.go:8",
+		}},
+		// go1.22 より前のファイルのループ変数はもともと繰り返しの間で共有される
+		{"1.21", loopClosureSrc, []string{
+			"example.com/m go1.11 go.mod declares go1.21 but go1.11 would be enough",
+		}},
+	} {
+		dir := writeModule(t, map[string]string{"main.go": tt.src, "go.mod": "module example.com/m\n\ngo " + tt.goMod + "\n"})
+		prog, err := loadProgramWith(loadOptions{Tests: true}, dir, "./...")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, r := range requiredGoVersions(prog) {
+			got = append(got, fmt.Sprintf("%s %s %s", r.Module, r.Required, r.Mismatch))
+			for _, f := range r.Features {
+				got = append(got, fmt.Sprintf("%s %s %s:%d", f.Version, f.Feature, filepath.Base(f.Pos.Filename), f.Pos.Line))
+			}
+		}
+		assertLines(t, got, tt.want)
+	}
+}
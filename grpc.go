@@ -0,0 +1,242 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// GRPCMethod は gRPC サービスの RPC メソッドと、それを実装している関数
+type GRPCMethod struct {
+	Service string         `json:"service"` // ServiceDesc の ServiceName (見つからなければインターフェース名から Server を除いたもの)
+	Method  string         `json:"method"`
+	Impl    string         `json:"impl,omitempty"` // 登録されていなければ空
+	Func    *types.Func    `json:"-"`
+	Stub    bool           `json:"stub,omitempty"` // UnimplementedXxxServer のメソッドがそのまま使われている
+	Pos     token.Position `json:"pos"`            // 登録している位置、登録がなければインターフェースの宣言位置
+}
+
+var (
+	grpcRegisterFunc   = regexp.MustCompile(`^Register(\w+)Server$`)
+	grpcServerIface    = regexp.MustCompile(`^(\w+)Server$`)
+	grpcServiceDescVar = regexp.MustCompile(`^_?(\w+)_[sS]erviceDesc$`)
+)
+
+func runGRPC(args []string) error {
+	fs := flag.NewFlagSet("grpc", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output as JSON")
+	fs.Parse(args)
+	prog, err := loadProgram(".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	methods := grpcMethods(prog.Packages)
+	if *asJSON {
+		return writeJSON(os.Stdout, methods)
+	}
+	return writeGRPCMethods(os.Stdout, methods)
+}
+
+// grpcMethods は生成された gRPC のサービスインターフェース (XxxServer) の各メソッドを、
+// RegisterXxxServer で登録された実装に対応付ける。登録のないメソッドは Impl が空になる
+func grpcMethods(pkgs []*packages.Package) []GRPCMethod {
+	names := grpcServiceNames(pkgs)
+	registered := make(map[*types.Named]bool)
+	var methods []GRPCMethod
+	for _, site := range CallSites(pkgs) {
+		for _, m := range grpcRegistration(site, names) {
+			m.Pos = site.Pos
+			methods = append(methods, m)
+			registered[grpcServiceIface(site.Func())] = true
+		}
+	}
+
+	// 登録されていないサービス
+	for _, pkg := range pkgs {
+		scope := pkg.Types.Scope()
+		for _, name := range scope.Names() {
+			tn, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || !isGRPCServerIface(tn) || registered[tn.Type().(*types.Named)] {
+				continue
+			}
+			iface := tn.Type().Underlying().(*types.Interface)
+			for i := 0; i < iface.NumMethods(); i++ {
+				if m := iface.Method(i); m.Exported() {
+					methods = append(methods, GRPCMethod{Service: grpcServiceName(tn, names), Method: m.Name(), Pos: pkg.Fset.Position(tn.Pos())})
+				}
+			}
+		}
+	}
+	sort.SliceStable(methods, func(i, j int) bool {
+		if methods[i].Service != methods[j].Service {
+			return methods[i].Service < methods[j].Service
+		}
+		return methods[i].Method < methods[j].Method
+	})
+	return methods
+}
+
+// grpcRegistration は site が生成コードの RegisterXxxServer(s, impl) であれば、
+// XxxServer インターフェースの各メソッドと impl の実装を返す (Pos は設定しない)
+func grpcRegistration(site *CallSite, names map[string]string) []GRPCMethod {
+	info, call, callee := site.Pkg.TypesInfo, site.Call, site.Func()
+	if callee == nil || len(call.Args) != 2 {
+		return nil
+	}
+	named := grpcServiceIface(callee)
+	if named == nil {
+		return nil
+	}
+	iface := named.Underlying().(*types.Interface)
+	impl := info.TypeOf(call.Args[1])
+	service := grpcServiceName(named.Obj(), names)
+	var methods []GRPCMethod
+	for i := 0; i < iface.NumMethods(); i++ {
+		m := iface.Method(i)
+		if !m.Exported() {
+			continue // mustEmbedUnimplementedXxxServer など
+		}
+		obj, index, _ := types.LookupFieldOrMethod(impl, true, m.Pkg(), m.Name())
+		fn, ok := obj.(*types.Func)
+		if !ok {
+			continue
+		}
+		methods = append(methods, GRPCMethod{
+			Service: service,
+			Method:  m.Name(),
+			Impl:    fn.FullName(),
+			Func:    fn,
+			Stub:    len(index) > 1 && strings.HasPrefix(recvTypeName(fn), "Unimplemented"),
+		})
+	}
+	return methods
+}
+
+// grpcServiceIface は RegisterXxxServer(s, srv XxxServer) のような関数であれば XxxServer を返す
+func grpcServiceIface(fn *types.Func) *types.Named {
+	sig := fn.Type().(*types.Signature)
+	if sig.Recv() != nil || !grpcRegisterFunc.MatchString(fn.Name()) || sig.Params().Len() != 2 {
+		return nil
+	}
+	named, ok := sig.Params().At(1).Type().(*types.Named)
+	if !ok {
+		return nil
+	}
+	if _, ok := named.Underlying().(*types.Interface); !ok {
+		return nil
+	}
+	return named
+}
+
+// isGRPCServerIface は tn が生成された XxxServer インターフェース
+// (mustEmbedUnimplementedXxxServer メソッドを持つか、同じパッケージに RegisterXxxServer がある) かどうかを返す
+func isGRPCServerIface(tn *types.TypeName) bool {
+	m := grpcServerIface.FindStringSubmatch(tn.Name())
+	if m == nil || !types.IsInterface(tn.Type()) {
+		return false
+	}
+	iface := tn.Type().Underlying().(*types.Interface)
+	for i := 0; i < iface.NumMethods(); i++ {
+		if iface.Method(i).Name() == "mustEmbedUnimplemented"+tn.Name() {
+			return true
+		}
+	}
+	reg, ok := tn.Pkg().Scope().Lookup("Register" + tn.Name()).(*types.Func)
+	return ok && grpcServiceIface(reg) == tn.Type()
+}
+
+// grpcServiceNames は Xxx_ServiceDesc (古い生成コードでは _Xxx_serviceDesc) の ServiceName を
+// "パッケージパス.Xxx" をキーにして集める
+func grpcServiceNames(pkgs []*packages.Package) map[string]string {
+	names := make(map[string]string)
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Syntax {
+			for _, decl := range file.Decls {
+				gd, ok := decl.(*ast.GenDecl)
+				if !ok || gd.Tok != token.VAR {
+					continue
+				}
+				for _, spec := range gd.Specs {
+					vs := spec.(*ast.ValueSpec)
+					for i, id := range vs.Names {
+						m := grpcServiceDescVar.FindStringSubmatch(id.Name)
+						if m == nil || i >= len(vs.Values) {
+							continue
+						}
+						if name := serviceNameField(vs.Values[i]); name != "" {
+							names[pkg.PkgPath+"."+m[1]] = name
+						}
+					}
+				}
+			}
+		}
+	}
+	return names
+}
+
+// serviceNameField は grpc.ServiceDesc{ServiceName: "..."} の ServiceName を返す
+func serviceNameField(expr ast.Expr) string {
+	lit, ok := ast.Unparen(expr).(*ast.CompositeLit)
+	if !ok {
+		return ""
+	}
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			continue
+		}
+		key, ok := kv.Key.(*ast.Ident)
+		val, ok2 := kv.Value.(*ast.BasicLit)
+		if ok && ok2 && key.Name == "ServiceName" && val.Kind == token.STRING {
+			if s, err := strconv.Unquote(val.Value); err == nil {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// grpcServiceName は XxxServer インターフェースのサービス名を返す
+func grpcServiceName(tn *types.TypeName, names map[string]string) string {
+	base := strings.TrimSuffix(tn.Name(), "Server")
+	if name, ok := names[tn.Pkg().Path()+"."+base]; ok {
+		return name
+	}
+	return base
+}
+
+// recvTypeName はメソッドのレシーバの型名を返す
+func recvTypeName(fn *types.Func) string {
+	t := fn.Type().(*types.Signature).Recv().Type()
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	if named, ok := t.(*types.Named); ok {
+		return named.Obj().Name()
+	}
+	return ""
+}
+
+func writeGRPCMethods(w io.Writer, methods []GRPCMethod) error {
+	for _, m := range methods {
+		impl := m.Impl
+		switch {
+		case impl == "":
+			impl = "(not registered)"
+		case m.Stub:
+			impl += " (unimplemented)"
+		}
+		fmt.Fprintf(w, "%s/%s\t%s\n", m.Service, m.Method, impl)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var testdata_grpc = map[string]string{
+	"pb/greeter_grpc.pb.go": `package pb
+
+type ServiceDesc struct {
+	ServiceName string
+}
+
+type Server struct{}
+
+type GreeterServer interface {
+	SayHello(name string) (string, error)
+	SayBye(name string) (string, error)
+	mustEmbedUnimplementedGreeterServer()
+}
+
+type UnimplementedGreeterServer struct{}
+
+func (UnimplementedGreeterServer) SayHello(name string) (string, error) { return "", nil }
+func (UnimplementedGreeterServer) SayBye(name string) (string, error)   { return "", nil }
+func (UnimplementedGreeterServer) mustEmbedUnimplementedGreeterServer() {}
+
+func RegisterGreeterServer(s *Server, srv GreeterServer) {}
+
+var Greeter_ServiceDesc = ServiceDesc{
+	ServiceName: "helloworld.Greeter",
+}
+
+type EchoServer interface {
+	Echo(msg string) (string, error)
+	mustEmbedUnimplementedEchoServer()
+}
+`,
+	"main.go": `package main
+
+import "example.com/m/pb"
+
+type greeter struct {
+	pb.UnimplementedGreeterServer
+}
+
+func (*greeter) SayHello(name string) (string, error) { return "hello " + name, nil }
+
+func main() {
+	pb.RegisterGreeterServer(&pb.Server{}, &greeter{})
+}
+`,
+}
+
+func TestGRPCMethods(t *testing.T) {
+	prog := loadTestProgram(t, testdata_grpc)
+	var buf bytes.Buffer
+	if err := writeGRPCMethods(&buf, grpcMethods(prog.Packages)); err != nil {
+		t.Fatal(err)
+	}
+	assertLines(t, strings.Split(strings.TrimSpace(buf.String()), "\n"), []string{
+		"Echo/Echo\t(not registered)",
+		"helloworld.Greeter/SayBye\t(example.com/m/pb.UnimplementedGreeterServer).SayBye (unimplemented)",
+		"helloworld.Greeter/SayHello\t(*example.com/m.greeter).SayHello",
+	})
+
+	// 登録された実装は到達可能性解析のルートになる
+	var roots []string
+	for _, e := range filterEntryPoints(EntryPoints(prog.Packages), []string{entryGRPC}) {
+		roots = append(roots, e.Name+" "+e.Detail)
+	}
+	assertLines(t, roots, []string{
+		"(*example.com/m.greeter).SayHello helloworld.Greeter/SayHello",
+		"(example.com/m/pb.UnimplementedGreeterServer).SayBye helloworld.Greeter/SayBye",
+	})
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"sort"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// Impact は変更された関数と、その変更の影響を受けうる関数とインターフェース
+type Impact struct {
+	Changed    []string            `json:"changed"`
+	Impacted   []ImpactedFunc      `json:"impacted"`   // 変更された関数を (推移的に) 呼び出す関数
+	Interfaces []ImpactedInterface `json:"interfaces"` // 実装が変更されたインターフェース
+}
+
+// ImpactedFunc は変更の影響を受けうる関数
+type ImpactedFunc struct {
+	Func  string         `json:"func"`
+	Depth int            `json:"depth"` // 変更された関数からの呼び出しの段数
+	Pos   token.Position `json:"pos"`
+
+	fn *ssa.Function
+}
+
+// ImpactedInterface は実装のメソッドが変更されたインターフェース
+type ImpactedInterface struct {
+	Interface string `json:"interface"`
+	Method    string `json:"method"`
+	Impl      string `json:"impl"` // 変更された実装のメソッド
+}
+
+func runImpact(args []string) error {
+	fs := flag.NewFlagSet("impact", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output as JSON")
+	fs.Parse(args)
+	prog, err := loadProgramWith(loadOptions{Tests: true}, ".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	changes, err := loadChanges(".", defaultRef, defaultChanges)
+	if err != nil {
+		return err
+	}
+	impact := impactOf(prog, changedFunctions(prog, changes))
+	if *asJSON {
+		return writeJSON(os.Stdout, impact)
+	}
+	return writeImpact(os.Stdout, impact)
+}
+
+// impactOf は逆向きのコールグラフをたどって changed を呼び出しうる関数を集め、
+// 変更されたメソッドを含むインターフェースを探す。無名関数からの呼び出しはそれを含む関数の呼び出しとする
+func impactOf(prog *Program, changed []*ssa.Function) *Impact {
+	impact := &Impact{}
+	cg := prog.CallGraph()
+	depth := make(map[*ssa.Function]int)
+	var queue []*ssa.Function
+	for _, fn := range changed {
+		impact.Changed = append(impact.Changed, fn.RelString(nil))
+		depth[fn] = 0
+		queue = append(queue, fn)
+	}
+	for len(queue) > 0 {
+		fn := queue[0]
+		queue = queue[1:]
+		node := cg.Nodes[fn]
+		if node == nil {
+			continue
+		}
+		for _, e := range node.In {
+			caller := e.Caller.Func
+			if caller.Pkg == nil || !prog.isTarget(caller.Pkg.Pkg) {
+				continue
+			}
+			for caller.Parent() != nil {
+				caller = caller.Parent()
+			}
+			if _, ok := depth[caller]; ok {
+				continue
+			}
+			depth[caller] = depth[fn] + 1
+			queue = append(queue, caller)
+			impact.Impacted = append(impact.Impacted, ImpactedFunc{
+				Func:  caller.RelString(nil),
+				Depth: depth[caller],
+				Pos:   prog.Fset.Position(caller.Pos()),
+				fn:    caller,
+			})
+		}
+	}
+	sort.SliceStable(impact.Impacted, func(i, j int) bool {
+		a, b := impact.Impacted[i], impact.Impacted[j]
+		if a.Depth != b.Depth {
+			return a.Depth < b.Depth
+		}
+		return a.Func < b.Func
+	})
+	impact.Interfaces = changedInterfaces(prog, changed)
+	return impact
+}
+
+// changedInterfaces は changed のメソッドを実装として持つ、解析対象のパッケージのインターフェースを返す
+func changedInterfaces(prog *Program, changed []*ssa.Function) []ImpactedInterface {
+	var ifaces []*types.TypeName
+	for _, pkg := range prog.Packages {
+		scope := pkg.Types.Scope()
+		for _, name := range scope.Names() {
+			if tn, ok := scope.Lookup(name).(*types.TypeName); ok && types.IsInterface(tn.Type()) {
+				ifaces = append(ifaces, tn)
+			}
+		}
+	}
+	var result []ImpactedInterface
+	for _, fn := range changed {
+		recv := fn.Signature.Recv()
+		if recv == nil {
+			continue
+		}
+		for _, tn := range ifaces {
+			iface := tn.Type().Underlying().(*types.Interface)
+			obj, _, _ := types.LookupFieldOrMethod(iface, false, nil, fn.Name())
+			if _, ok := obj.(*types.Func); !ok {
+				continue
+			}
+			t := recv.Type()
+			if ptr, ok := t.(*types.Pointer); ok {
+				t = ptr.Elem()
+			}
+			if types.Implements(t, iface) || types.Implements(types.NewPointer(t), iface) {
+				result = append(result, ImpactedInterface{
+					Interface: types.TypeString(tn.Type(), nil),
+					Method:    fn.Name(),
+					Impl:      fn.RelString(nil),
+				})
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Interface != result[j].Interface {
+			return result[i].Interface < result[j].Interface
+		}
+		return result[i].Impl < result[j].Impl
+	})
+	return result
+}
+
+func writeImpact(w io.Writer, impact *Impact) error {
+	for _, fn := range impact.Changed {
+		fmt.Fprintf(w, "changed   %s\n", fn)
+	}
+	for _, f := range impact.Impacted {
+		fmt.Fprintf(w, "impacted  %s (depth %d)\n", f.Func, f.Depth)
+	}
+	for _, i := range impact.Interfaces {
+		fmt.Fprintf(w, "interface %s.%s (implemented by %s)\n", i.Interface, i.Method, i.Impl)
+	}
+	return nil
+}
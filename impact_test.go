@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+var testdata_impact = map[string]string{
+	"store/store.go": `package store
+
+type Store interface {
+	Get(key string) string
+}
+
+type mem struct{ m map[string]string }
+
+func (s *mem) Get(key string) string { return s.m[key] }
+
+func New() Store { return &mem{m: map[string]string{}} }
+`,
+	"svc/svc.go": `package svc
+
+import "example.com/m/store"
+
+func Lookup(s store.Store, key string) string { return s.Get(key) }
+
+func Handle(key string) string {
+	return func() string { return Lookup(store.New(), key) }()
+}
+
+func Unrelated() int { return 1 }
+`,
+	"svc/svc_test.go": `package svc
+
+import "testing"
+
+func TestHandle(t *testing.T) { Handle("x") }
+
+func TestUnrelated(t *testing.T) { Unrelated() }
+`,
+}
+
+func TestImpact(t *testing.T) {
+	prog, err := loadProgramWith(loadOptions{Tests: true}, writeModule(t, testdata_impact), "./...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var changed []*ssa.Function
+	for _, fn := range prog.targetFunctions() {
+		if fn.RelString(nil) == "(*example.com/m/store.mem).Get" {
+			changed = append(changed, fn)
+		}
+	}
+	var buf bytes.Buffer
+	if err := writeImpact(&buf, impactOf(prog, changed)); err != nil {
+		t.Fatal(err)
+	}
+	assertLines(t, strings.Split(strings.TrimSpace(buf.String()), "\n"), []string{
+		"changed   (*example.com/m/store.mem).Get",
+		"impacted  example.com/m/svc.Lookup (depth 1)",
+		"impacted  example.com/m/svc.Handle (depth 2)",
+		"impacted  example.com/m/svc.TestHandle (depth 3)",
+		"interface example.com/m/store.Store.Get (implemented by (*example.com/m/store.mem).Get)",
+	})
+}
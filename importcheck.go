@@ -0,0 +1,380 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// ImportConfig は import の書き方の規則
+//
+//	{
+//	  "local": ["example.com/m", "example.com/shared"],
+//	  "aliases": {"github.com/sirupsen/logrus": "log", "k8s.io/api/core/v1": "corev1"}
+//	}
+type ImportConfig struct {
+	Local   []string          `json:"local,omitempty"`   // ローカル (内部) のパッケージの import path の接頭辞。空ならパッケージと同じモジュールのもの
+	Aliases map[string]string `json:"aliases,omitempty"` // import path から、そのパッケージを参照する名前
+}
+
+// import のグループ (この順に並べ、空行で区切る)
+const (
+	importStd = iota
+	importThirdParty
+	importLocal
+)
+
+var importGroupNames = []string{"standard library", "third-party", "local"}
+
+func runImportCheck(args []string) error {
+	fs := flag.NewFlagSet("importcheck", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to the import rules `file` (local prefixes and canonical aliases)")
+	fix := fs.Bool("fix", false, "regroup imports, apply the canonical aliases, replace dot imports and write the files")
+	return runDiagnosticsWith(fs, args, loadOptions{Tests: true}, func(prog *Program) ([]Diagnostic, error) {
+		conf := &ImportConfig{}
+		if *configPath != "" {
+			var err error
+			if conf, err = loadImportConfig(*configPath); err != nil {
+				return nil, err
+			}
+		}
+		diags, files, err := checkImports(prog, conf, *fix)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeFiles(files); err != nil {
+			return nil, err
+		}
+		return diags, nil
+	})
+}
+
+// checkDefaultImports は既定の規則 (設定ファイルなし) で checkImports の指摘を返す
+func checkDefaultImports(prog *Program) []Diagnostic {
+	diags, _, _ := checkImports(prog, &ImportConfig{}, false)
+	return diags
+}
+
+// fixImportsAt は既定の規則で d のファイルの import を直した内容を返す。ファイルの import はまとめて
+// 書き直すので、同じファイルの最初の指摘にだけ返す
+func fixImportsAt(prog *Program, d Diagnostic) (map[string][]byte, error) {
+	diags, files, err := checkImports(prog, &ImportConfig{}, true)
+	if err != nil {
+		return nil, err
+	}
+	for _, diag := range diags {
+		if diag.Pos.Filename != d.Pos.Filename {
+			continue
+		}
+		if diag.Pos != d.Pos || diag.Message != d.Message {
+			return nil, nil
+		}
+		if src, ok := files[d.Pos.Filename]; ok {
+			return map[string][]byte{d.Pos.Filename: src}, nil
+		}
+		return nil, nil
+	}
+	return nil, nil
+}
+
+func loadImportConfig(path string) (*ImportConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var conf ImportConfig
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for importPath, name := range conf.Aliases {
+		if !token.IsIdentifier(name) || name == "_" {
+			return nil, fmt.Errorf("%s: alias %q of %s is not a valid package name", path, name, importPath)
+		}
+	}
+	return &conf, nil
+}
+
+// group は pkg のファイルで import した path のグループを返す
+func (c *ImportConfig) group(pkg *packages.Package, path string) int {
+	local := c.Local
+	if len(local) == 0 && pkg.Module != nil {
+		local = []string{pkg.Module.Path}
+	}
+	for _, prefix := range local {
+		if path == prefix || strings.HasPrefix(path, strings.TrimSuffix(prefix, "/")+"/") {
+			return importLocal
+		}
+	}
+	if isStdPackage(path) {
+		return importStd
+	}
+	return importThirdParty
+}
+
+// checkImports は import のグループ分け (標準ライブラリ、サードパーティ、ローカルの順に空行で区切る)、
+// conf の別名と異なる名前での import、ドットでの import を指摘する。fix なら修正したファイルの内容も返す。
+// 別名の変更とドットの import の置き換えは、新しい名前がファイルの中の他の名前とぶつかるときはしない
+func checkImports(prog *Program, conf *ImportConfig, fix bool) ([]Diagnostic, map[string][]byte, error) {
+	var diags []Diagnostic
+	files := make(map[string][]byte)
+	seen := make(map[string]bool)
+	for _, pkg := range prog.Packages {
+		for _, file := range pkg.Syntax {
+			name := prog.Fset.Position(file.Pos()).Filename
+			if seen[name] || prog.skipGenerated(name) {
+				continue
+			}
+			seen[name] = true
+			changed := false
+			for _, spec := range file.Imports {
+				path, err := strconv.Unquote(spec.Path.Value)
+				if err != nil {
+					continue
+				}
+				imported := importedName(pkg.TypesInfo, spec)
+				if imported == "" {
+					continue
+				}
+				alias, hasAlias := conf.Aliases[path]
+				switch {
+				case spec.Name != nil && spec.Name.Name == ".":
+					diags = append(diags, newDiagnostic(prog.Fset, spec.Pos(), "imports",
+						"dot import of %q; refer to its names through the package name", path))
+					if !hasAlias {
+						alias = imported
+					}
+					changed = fix && qualifyDotImport(pkg.TypesInfo, file, spec, alias) || changed
+				case hasAlias && imported != alias:
+					diags = append(diags, newDiagnostic(prog.Fset, spec.Pos(), "imports",
+						"%q is imported as %s; import it as %s", path, imported, alias))
+					changed = fix && renameImport(pkg.TypesInfo, file, spec, alias) || changed
+				}
+			}
+			d, grouped := importGrouping(prog.Fset, pkg, file, conf)
+			if !grouped {
+				diags = append(diags, d)
+			}
+			if !fix || (grouped && !changed) {
+				continue
+			}
+			// goimports の並べ替えはコメントを import に付けたまま動かさないので、構文木を出力したものを直接並べ替える
+			var buf bytes.Buffer
+			if err := format.Node(&buf, prog.Fset, file); err != nil {
+				return nil, nil, err
+			}
+			src, err := regroupImports(buf.Bytes(), func(path string) int { return conf.group(pkg, path) })
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", name, err)
+			}
+			files[name] = src
+		}
+	}
+	sortDiagnostics(diags)
+	return diags, files, nil
+}
+
+// importedName は spec で import したパッケージの名前 (ドットの import でもパッケージの名前) を返す。
+// _ での import や型検査できなかったものは空を返す
+func importedName(info *types.Info, spec *ast.ImportSpec) string {
+	obj := info.Implicits[spec]
+	if spec.Name != nil {
+		obj = info.Defs[spec.Name]
+	}
+	pkgName, ok := obj.(*types.PkgName)
+	if !ok {
+		return ""
+	}
+	if spec.Name != nil && spec.Name.Name == "." {
+		return pkgName.Imported().Name()
+	}
+	return pkgName.Name()
+}
+
+// nameTaken は file の中に name という名前の参照か宣言があるか、name で参照する他の import があるかどうかを返す
+func nameTaken(info *types.Info, file *ast.File, spec *ast.ImportSpec, name string) bool {
+	taken := false
+	ast.Inspect(file, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && id.Name == name && (info.Uses[id] != nil || info.Defs[id] != nil) {
+			taken = true
+		}
+		return !taken
+	})
+	for _, other := range file.Imports {
+		if other != spec && importedName(info, other) == name && (other.Name == nil || other.Name.Name != ".") {
+			taken = true
+		}
+	}
+	return taken
+}
+
+// renameImport は spec の import を name で参照するように書き換える
+func renameImport(info *types.Info, file *ast.File, spec *ast.ImportSpec, name string) bool {
+	obj := info.Implicits[spec]
+	if spec.Name != nil {
+		obj = info.Defs[spec.Name]
+	}
+	pkgName := obj.(*types.PkgName)
+	if nameTaken(info, file, spec, name) {
+		return false
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && info.Uses[id] == pkgName {
+			id.Name = name
+		}
+		return true
+	})
+	spec.Name = &ast.Ident{Name: name, NamePos: spec.Path.Pos()}
+	if name == pkgName.Imported().Name() {
+		spec.Name = nil
+	}
+	return true
+}
+
+// qualifyDotImport はドットで import したパッケージの名前の参照を name.X の形にし、spec を通常の import にする
+func qualifyDotImport(info *types.Info, file *ast.File, spec *ast.ImportSpec, name string) bool {
+	imported := info.Defs[spec.Name].(*types.PkgName).Imported()
+	if nameTaken(info, file, spec, name) {
+		return false
+	}
+	astutil.Apply(file, func(c *astutil.Cursor) bool {
+		id, ok := c.Node().(*ast.Ident)
+		if !ok {
+			return true
+		}
+		if sel, ok := c.Parent().(*ast.SelectorExpr); ok && sel.Sel == id {
+			return true
+		}
+		if obj := info.Uses[id]; obj != nil && obj.Pkg() == imported && obj.Parent() == imported.Scope() {
+			c.Replace(&ast.SelectorExpr{X: &ast.Ident{Name: name, NamePos: id.Pos()}, Sel: id})
+		}
+		return true
+	}, nil)
+	spec.Name = nil
+	if name != imported.Name() {
+		spec.Name = &ast.Ident{Name: name, NamePos: spec.Path.Pos()}
+	}
+	return true
+}
+
+// importGrouping は file の import がグループの順に並び、異なるグループの間が空行で区切られているかどうかを調べ、
+// そうでなければ最初に見つかった問題の指摘を返す。別々の import 宣言は区切られているものとみなす
+func importGrouping(fset *token.FileSet, pkg *packages.Package, file *ast.File, conf *ImportConfig) (Diagnostic, bool) {
+	var prev *ast.ImportSpec
+	prevGroup := -1
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.IMPORT {
+			continue
+		}
+		for i, s := range gen.Specs {
+			spec := s.(*ast.ImportSpec)
+			path, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				continue
+			}
+			group := conf.group(pkg, path)
+			switch {
+			case group < prevGroup:
+				return newDiagnostic(fset, spec.Pos(), "imports", "%s import %s comes after %s imports; group imports as standard library, third-party, then local",
+					importGroupNames[group], spec.Path.Value, importGroupNames[prevGroup]), false
+			case group != prevGroup && i > 0:
+				start := spec.Pos()
+				if spec.Doc != nil {
+					start = spec.Doc.Pos()
+				}
+				if fset.Position(start).Line-fset.Position(prev.End()).Line < 2 {
+					return newDiagnostic(fset, spec.Pos(), "imports", "%s import %s is not separated from the %s imports by a blank line",
+						importGroupNames[group], spec.Path.Value, importGroupNames[prevGroup]), false
+				}
+			}
+			prev, prevGroup = spec, group
+		}
+	}
+	return Diagnostic{}, true
+}
+
+// regroupImports は src のすべての import 宣言を、グループごとに import path の順に並べて空行で区切った
+// 1 つの import 宣言にまとめる。import の間のコメントは次の import の前に、import と同じ行のコメントはその後に残す
+func regroupImports(src []byte, group func(path string) int) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ImportsOnly|parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	var decls []*ast.GenDecl
+	for _, decl := range file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			decls = append(decls, gen)
+		}
+	}
+	if len(decls) == 0 {
+		return src, nil
+	}
+	tf := fset.File(file.Pos())
+	text := func(n ast.Node) string { return string(src[tf.Offset(n.Pos()):tf.Offset(n.End())]) }
+	start, end := decls[0].Pos(), decls[len(decls)-1].End()
+	var comments []*ast.CommentGroup
+	for _, c := range file.Comments {
+		if c.Pos() > start && c.End() < end {
+			comments = append(comments, c)
+		}
+	}
+	type chunk struct {
+		path string
+		text string
+	}
+	var groups [3][]chunk
+	for _, decl := range decls {
+		for _, s := range decl.Specs {
+			spec := s.(*ast.ImportSpec)
+			var b strings.Builder
+			for len(comments) > 0 && comments[0].Pos() < spec.Pos() {
+				b.WriteString(text(comments[0]) + "\n")
+				comments = comments[1:]
+			}
+			b.WriteString(text(spec))
+			line := fset.Position(spec.End()).Line
+			for len(comments) > 0 && fset.Position(comments[0].Pos()).Line == line {
+				b.WriteString(" " + text(comments[0]))
+				comments = comments[1:]
+			}
+			path, _ := strconv.Unquote(spec.Path.Value)
+			g := group(path)
+			groups[g] = append(groups[g], chunk{path, b.String()})
+		}
+	}
+	var blocks []string
+	for _, chunks := range groups {
+		if len(chunks) == 0 {
+			continue
+		}
+		sort.SliceStable(chunks, func(i, j int) bool { return chunks[i].path < chunks[j].path })
+		var lines []string
+		for _, c := range chunks {
+			lines = append(lines, c.text)
+		}
+		blocks = append(blocks, strings.Join(lines, "\n"))
+	}
+	var buf bytes.Buffer
+	buf.Write(src[:tf.Offset(start)])
+	buf.WriteString("import (\n" + strings.Join(blocks, "\n\n") + "\n")
+	for _, c := range comments {
+		buf.WriteString(text(c) + "\n")
+	}
+	buf.WriteString(")")
+	buf.Write(src[tf.Offset(end):])
+	return format.Source(buf.Bytes())
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckImports(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"ext/ext.go":         "package ext\n\nconst Version = 1\n",
+		"internal/db/db.go":  "package db\n\nconst Name = \"db\"\n",
+		"grouped/grouped.go": "package grouped\n\nimport (\n\t\"fmt\"\n\n\textlib \"example.com/m/ext\"\n)\n\nvar _ = fmt.Sprint(extlib.Version)\n",
+		"main.go": `package main
+
+import (
+	"fmt"
+	"example.com/m/internal/db" // database
+	. "strings"
+
+	// the extension library
+	"example.com/m/ext"
+	"os"
+)
+
+func main() {
+	fmt.Println(ToUpper(db.Name), ext.Version, os.Args)
+}
+`,
+	})
+	prog, err := loadProgramWith(loadOptions{Tests: true}, dir, "./...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := &ImportConfig{Local: []string{"example.com/m/internal"}, Aliases: map[string]string{"example.com/m/ext": "extlib"}}
+	diags, files, err := checkImports(prog, conf, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertLines(t, diagnosticMessages(diags), []string{
+		`main.go:5: local import "example.com/m/internal/db" is not separated from the standard library imports by a blank line`,
+		`main.go:6: dot import of "strings"; refer to its names through the package name`,
+		`main.go:9: "example.com/m/ext" is imported as ext; import it as extlib`,
+	})
+	want := `package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	// the extension library
+	extlib "example.com/m/ext"
+
+	"example.com/m/internal/db" // database
+)
+
+func main() {
+	fmt.Println(strings.ToUpper(db.Name), extlib.Version, os.Args)
+}
+`
+	if got := string(files[filepath.Join(dir, "main.go")]); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if len(files) != 1 {
+		t.Errorf("rewrote %d files, want 1", len(files))
+	}
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+)
+
+// SymbolID は Index の中の宣言の番号
+type SymbolID int32
+
+// Index は識別子の位置と宣言の対応を、AST や types.Info を持たずに保持する索引。
+// 文字列は 1 度だけ持って番号で参照し、識別子はファイルと位置の順の配列にするので、
+// 読み込んだ Program を捨てても大きなリポジトリの問い合わせに答えられる
+type Index struct {
+	strs    []string
+	files   []indexFile
+	syms    []indexSymbol
+	idents  []indexIdent // ファイル、オフセットの順
+	byName  map[string][]SymbolID
+	byFile  map[string]int32
+	members map[string][]SymbolID // import path からパッケージのメンバー
+	// 宣言 i の識別子は idents[refs[refStart[i]:refStart[i+1]]] で、ファイル、オフセットの順に並ぶ
+	refStart []int32
+	refs     []int32
+}
+
+// indexFile は 1 つのファイルの名前と行の先頭のオフセット
+type indexFile struct {
+	name  int32
+	lines []int32
+}
+
+// indexSymbol は 1 つの宣言。name はパッケージのメンバーなら pkg.Name (メソッドは (pkg.T).M)、ローカルなら名前だけ
+type indexSymbol struct {
+	name, pkg int32
+	kind      uint8
+	flags     uint8
+	file      int32 // 宣言の位置がなければ -1
+	offset    int32
+}
+
+// indexSymbol の flags
+const (
+	symExported uint8 = 1 << iota
+	symMember         // パッケージレベルの宣言か、インターフェースでない型のメソッド
+)
+
+// indexIdent は 1 つの識別子
+type indexIdent struct {
+	file, offset int32
+	sym          SymbolID
+	length       uint16
+}
+
+// シンボルの種類。export の exportSymbol.Kind と同じ名前で出力する
+const (
+	symFunc uint8 = iota
+	symMethod
+	symType
+	symVar
+	symConst
+	symOther
+)
+
+var symKindNames = [...]string{"func", "method", "type", "var", "const", "other"}
+
+// Symbol は Index の宣言を文字列と位置にしたもの
+type Symbol struct {
+	ID       SymbolID       `json:"-"`
+	Name     string         `json:"name"`
+	Package  string         `json:"package,omitempty"`
+	Kind     string         `json:"kind"`
+	Exported bool           `json:"exported,omitempty"`
+	Pos      token.Position `json:"pos"`
+}
+
+// indexBuilder は NewIndex の作業中の状態。Index には残さない
+type indexBuilder struct {
+	ix    *Index
+	strs  map[string]int32
+	files map[*token.File]int32
+	syms  map[types.Object]SymbolID
+	fset  *token.FileSet
+}
+
+// NewIndex は prog の解析対象のパッケージの識別子から索引を作る
+func NewIndex(prog *Program) *Index {
+	b := &indexBuilder{
+		ix:    &Index{byName: make(map[string][]SymbolID), byFile: make(map[string]int32), members: make(map[string][]SymbolID)},
+		strs:  make(map[string]int32),
+		files: make(map[*token.File]int32),
+		syms:  make(map[types.Object]SymbolID),
+		fset:  prog.Fset,
+	}
+	for _, pkg := range prog.Packages {
+		for _, file := range pkg.Syntax {
+			ast.Inspect(file, func(n ast.Node) bool {
+				id, ok := n.(*ast.Ident)
+				if !ok {
+					return true
+				}
+				obj := pkg.TypesInfo.Defs[id]
+				if obj == nil {
+					obj = pkg.TypesInfo.Uses[id]
+				}
+				if obj == nil || obj.Pkg() == nil {
+					return true // パッケージ名、組み込みの識別子、型 switch の変数の宣言など
+				}
+				if _, ok := obj.(*types.PkgName); ok {
+					return true
+				}
+				file, offset := b.position(id.Pos())
+				b.ix.idents = append(b.ix.idents, indexIdent{
+					file: file, offset: offset, sym: b.symbol(originObject(obj)), length: uint16(len(id.Name)),
+				})
+				return true
+			})
+		}
+	}
+	sort.Slice(b.ix.idents, func(i, j int) bool {
+		a, c := b.ix.idents[i], b.ix.idents[j]
+		if a.file != c.file {
+			return a.file < c.file
+		}
+		return a.offset < c.offset
+	})
+	b.postings()
+	return b.ix
+}
+
+// postings は宣言ごとの識別子の一覧を、識別子の数を数えてから詰めて作る
+func (b *indexBuilder) postings() {
+	ix := b.ix
+	ix.refStart = make([]int32, len(ix.syms)+1)
+	for _, id := range ix.idents {
+		ix.refStart[id.sym+1]++
+	}
+	for i := 1; i < len(ix.refStart); i++ {
+		ix.refStart[i] += ix.refStart[i-1]
+	}
+	ix.refs = make([]int32, len(ix.idents))
+	next := append([]int32(nil), ix.refStart[:len(ix.syms)]...)
+	for i, id := range ix.idents {
+		ix.refs[next[id.sym]] = int32(i)
+		next[id.sym]++
+	}
+}
+
+func (b *indexBuilder) intern(s string) int32 {
+	if id, ok := b.strs[s]; ok {
+		return id
+	}
+	id := int32(len(b.ix.strs))
+	b.ix.strs = append(b.ix.strs, s)
+	b.strs[s] = id
+	return id
+}
+
+// position は pos をファイルの番号とオフセットにする。はじめて出てきたファイルは行の表を写し取る
+func (b *indexBuilder) position(pos token.Pos) (int32, int32) {
+	tf := b.fset.File(pos)
+	if tf == nil {
+		return -1, 0
+	}
+	id, ok := b.files[tf]
+	if !ok {
+		id = int32(len(b.ix.files))
+		b.files[tf] = id
+		lines := make([]int32, tf.LineCount())
+		for i := range lines {
+			lines[i] = int32(tf.Offset(tf.LineStart(i + 1)))
+		}
+		b.ix.files = append(b.ix.files, indexFile{name: b.intern(tf.Name()), lines: lines})
+		b.ix.byFile[tf.Name()] = id
+	}
+	return id, int32(tf.Offset(pos))
+}
+
+func (b *indexBuilder) symbol(obj types.Object) SymbolID {
+	if id, ok := b.syms[obj]; ok {
+		return id
+	}
+	s := indexSymbol{pkg: b.intern(obj.Pkg().Path()), kind: symOther, file: -1}
+	name := obj.Name()
+	if obj.Parent() == obj.Pkg().Scope() {
+		name = obj.Pkg().Path() + "." + name
+		// init や _ は obj.Parent() がパッケージのスコープでもスコープから引けない
+		if obj.Pkg().Scope().Lookup(obj.Name()) == obj {
+			s.flags |= symMember
+		}
+	}
+	if obj.Exported() {
+		s.flags |= symExported
+	}
+	switch obj := obj.(type) {
+	case *types.Func:
+		s.kind = symFunc
+		if recv := obj.Type().(*types.Signature).Recv(); recv != nil {
+			s.kind = symMethod
+			name = obj.FullName()
+			if !types.IsInterface(recv.Type()) {
+				s.flags |= symMember
+			}
+		}
+	case *types.TypeName:
+		s.kind = symType
+	case *types.Var:
+		s.kind = symVar
+	case *types.Const:
+		s.kind = symConst
+	}
+	s.name = b.intern(name)
+	name = b.ix.strs[s.name]
+	if obj.Pos().IsValid() {
+		s.file, s.offset = b.position(obj.Pos())
+	}
+	id := SymbolID(len(b.ix.syms))
+	b.ix.syms = append(b.ix.syms, s)
+	b.ix.byName[name] = append(b.ix.byName[name], id)
+	if s.flags&symMember != 0 {
+		path := b.ix.strs[s.pkg]
+		b.ix.members[path] = append(b.ix.members[path], id)
+	}
+	b.syms[obj] = id
+	return id
+}
+
+// fileIndex は filename のファイルの番号を返す
+func (ix *Index) fileIndex(filename string) (int32, bool) {
+	i, ok := ix.byFile[filename]
+	return i, ok
+}
+
+// position はファイルの番号とオフセットを token.Position にする
+func (ix *Index) position(file, offset int32) token.Position {
+	if file < 0 {
+		return token.Position{}
+	}
+	f := ix.files[file]
+	line := sort.Search(len(f.lines), func(i int) bool { return f.lines[i] > offset })
+	return token.Position{
+		Filename: ix.strs[f.name],
+		Offset:   int(offset),
+		Line:     line,
+		Column:   int(offset-f.lines[line-1]) + 1,
+	}
+}
+
+// Symbol は id の宣言を返す
+func (ix *Index) Symbol(id SymbolID) Symbol {
+	s := ix.syms[id]
+	return Symbol{
+		ID:       id,
+		Name:     ix.strs[s.name],
+		Package:  ix.strs[s.pkg],
+		Kind:     symKindNames[s.kind],
+		Exported: s.flags&symExported != 0,
+		Pos:      ix.position(s.file, s.offset),
+	}
+}
+
+// Lookup は名前が name (pkg.Name または (pkg.T).M の形) の宣言を返す
+func (ix *Index) Lookup(name string) []Symbol {
+	var result []Symbol
+	for _, id := range ix.byName[name] {
+		result = append(result, ix.Symbol(id))
+	}
+	return result
+}
+
+// Members は import path が pkg のパッケージのパッケージレベルの宣言と、インターフェースでない型のメソッドを返す
+func (ix *Index) Members(pkg string) []Symbol {
+	var result []Symbol
+	for _, id := range ix.members[pkg] {
+		result = append(result, ix.Symbol(id))
+	}
+	return result
+}
+
+// At は pos (ファイル名と行と列) にある識別子の宣言を返す
+func (ix *Index) At(pos token.Position) (Symbol, bool) {
+	file, ok := ix.fileIndex(pos.Filename)
+	if !ok || pos.Line < 1 || pos.Line > len(ix.files[file].lines) {
+		return Symbol{}, false
+	}
+	offset := ix.files[file].lines[pos.Line-1] + int32(pos.Column-1)
+	// offset より後ろから始まる最初の識別子の 1 つ前が offset を含みうる
+	i := sort.Search(len(ix.idents), func(i int) bool {
+		id := ix.idents[i]
+		return id.file > file || id.file == file && id.offset > offset
+	})
+	if i == 0 {
+		return Symbol{}, false
+	}
+	id := ix.idents[i-1]
+	if id.file != file || offset >= id.offset+int32(id.length) {
+		return Symbol{}, false
+	}
+	return ix.Symbol(id.sym), true
+}
+
+// Refs は sym の宣言と参照の位置をファイル、位置の順に返す
+func (ix *Index) Refs(sym SymbolID) []token.Position {
+	var result []token.Position
+	for _, i := range ix.refs[ix.refStart[sym]:ix.refStart[sym+1]] {
+		id := ix.idents[i]
+		result = append(result, ix.position(id.file, id.offset))
+	}
+	return result
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"go/token"
+	"path/filepath"
+	"testing"
+)
+
+func TestIndex(t *testing.T) {
+	prog := loadTestProgram(t, map[string]string{"main.go": `package main
+
+import "fmt"
+
+type Box[T any] struct{ v T }
+
+func (b *Box[T]) Get() T { return b.v }
+
+func main() {
+	b := &Box[int]{v: 1}
+	n := b.Get()
+	fmt.Println(n, b.Get())
+}
+
+type Getter interface{ Get() int }
+
+func init() {}
+`})
+	ix := NewIndex(prog)
+	filename := prog.Packages[0].GoFiles[0]
+	describe := func(s Symbol) string {
+		if s.Package != "example.com/m" {
+			return s.Kind + " " + s.Name
+		}
+		return fmt.Sprintf("%s %s %s:%d:%d", s.Kind, s.Name, filepath.Base(s.Pos.Filename), s.Pos.Line, s.Pos.Column)
+	}
+	var got []string
+	for _, pos := range [][2]int{{10, 2}, {11, 9}, {11, 10}, {11, 8}, {12, 6}, {12, 14}, {5, 25}} {
+		s, ok := ix.At(token.Position{Filename: filename, Line: pos[0], Column: pos[1]})
+		if !ok {
+			got = append(got, fmt.Sprintf("%d:%d: none", pos[0], pos[1]))
+			continue
+		}
+		got = append(got, fmt.Sprintf("%d:%d: %s", pos[0], pos[1], describe(s)))
+	}
+	assertLines(t, got, []string{
+		"10:2: var b main.go:10:2",
+		"11:9: method (*example.com/m.Box[T]).Get main.go:7:18",
+		"11:10: method (*example.com/m.Box[T]).Get main.go:7:18",
+		"11:8: none",
+		"12:6: func fmt.Println",
+		"12:14: var n main.go:11:2",
+		"5:25: var v main.go:5:25",
+	})
+
+	syms := ix.Lookup("(*example.com/m.Box[T]).Get")
+	if len(syms) != 1 {
+		t.Fatalf("Lookup: got %d symbols, want 1", len(syms))
+	}
+	got = nil
+	for _, pos := range ix.Refs(syms[0].ID) {
+		got = append(got, fmt.Sprintf("%d:%d", pos.Line, pos.Column))
+	}
+	assertLines(t, got, []string{"7:18", "11:9", "12:19"})
+
+	// インターフェースのメソッドと init はメンバーにしない
+	got = nil
+	for _, s := range ix.Members("example.com/m") {
+		got = append(got, fmt.Sprintf("%s exported=%t", describe(s), s.Exported))
+	}
+	assertLines(t, got, []string{
+		"type example.com/m.Box main.go:5:6 exported=true",
+		"method (*example.com/m.Box[T]).Get main.go:7:18 exported=true",
+		"func example.com/m.main main.go:9:6 exported=false",
+		"type example.com/m.Getter main.go:15:6 exported=true",
+	})
+}
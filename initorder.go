@@ -0,0 +1,191 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// PackageInit は 1 パッケージの初期化情報
+type PackageInit struct {
+	Path      string     `json:"path"`
+	Order     int        `json:"order"` // 解析対象パッケージの中で初期化される順番
+	InitFuncs []InitFunc `json:"init_funcs,omitempty"`
+	Vars      []string   `json:"vars,omitempty"`  // 型検査器が決めたパッケージ変数の初期化順
+	Chain     []string   `json:"chain,omitempty"` // ルートのパッケージからこのパッケージまでの import の経路
+}
+
+// InitFunc は init 関数とその中で呼び出している関数
+type InitFunc struct {
+	Pos   token.Position `json:"pos"`
+	Calls []string       `json:"calls,omitempty"`
+}
+
+func runInitOrder(args []string) error {
+	fs := flag.NewFlagSet("initorder", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output as JSON")
+	fs.Parse(args)
+	prog, err := loadProgram(".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	inits := initOrder(prog)
+	relativizePositions(inits)
+	if *asJSON {
+		return writeJSON(os.Stdout, inits)
+	}
+	return writeInitOrder(os.Stdout, inits)
+}
+
+// initOrder は解析対象パッケージを初期化される順に並べ、それぞれの init 関数と変数の初期化順を返す
+func initOrder(prog *Program) []PackageInit {
+	byPath := make(map[string]*packages.Package)
+	for _, pkg := range prog.Packages {
+		byPath[pkg.PkgPath] = pkg
+	}
+
+	// 仕様どおり、依存先がすべて初期化済みのパッケージのうち import path が最小のものから初期化する
+	var order []*packages.Package
+	done := make(map[string]bool)
+	for len(order) < len(byPath) {
+		var next *packages.Package
+		for path, pkg := range byPath {
+			if done[path] || next != nil && path > next.PkgPath {
+				continue
+			}
+			ready := true
+			for imp := range pkg.Imports {
+				if _, ok := byPath[imp]; ok && !done[imp] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				next = pkg
+			}
+		}
+		if next == nil {
+			break // import の循環 (型検査でエラーになっているはず)
+		}
+		done[next.PkgPath] = true
+		order = append(order, next)
+	}
+
+	chains := importChains(byPath)
+	var inits []PackageInit
+	for i, pkg := range order {
+		pi := PackageInit{Path: pkg.PkgPath, Order: i, Chain: chains[pkg.PkgPath]}
+		for _, file := range pkg.Syntax {
+			for _, decl := range file.Decls {
+				fd, ok := decl.(*ast.FuncDecl)
+				if !ok || fd.Recv != nil || fd.Name.Name != "init" || fd.Body == nil {
+					continue
+				}
+				pi.InitFuncs = append(pi.InitFuncs, InitFunc{
+					Pos:   prog.Fset.Position(fd.Pos()),
+					Calls: calledFunctions(pkg.TypesInfo, fd.Body),
+				})
+			}
+		}
+		for _, initializer := range pkg.TypesInfo.InitOrder {
+			pi.Vars = append(pi.Vars, initializer.String())
+		}
+		inits = append(inits, pi)
+	}
+	return inits
+}
+
+// calledFunctions は node の中で呼び出している関数の名前を出現順に重複なく返す
+func calledFunctions(info *types.Info, node ast.Node) []string {
+	var calls []string
+	seen := make(map[string]bool)
+	ast.Inspect(node, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		var name string
+		switch callee := typeutil.Callee(info, call).(type) {
+		case *types.Func:
+			name = callee.FullName()
+		case *types.Builtin:
+			name = callee.Name()
+		default:
+			return true
+		}
+		if !seen[name] {
+			seen[name] = true
+			calls = append(calls, name)
+		}
+		return true
+	})
+	return calls
+}
+
+// importChains は他のパッケージから import されていないパッケージを起点に、
+// 各パッケージまでの最短の import の経路を返す
+func importChains(byPath map[string]*packages.Package) map[string][]string {
+	imported := make(map[string]bool)
+	for _, pkg := range byPath {
+		for imp := range pkg.Imports {
+			imported[imp] = true
+		}
+	}
+	var queue []string
+	chains := make(map[string][]string)
+	for path := range byPath {
+		if !imported[path] {
+			queue = append(queue, path)
+			chains[path] = []string{path}
+		}
+	}
+	sort.Strings(queue)
+	for len(queue) > 0 {
+		path := queue[0]
+		queue = queue[1:]
+		var imports []string
+		for imp := range byPath[path].Imports {
+			if _, ok := byPath[imp]; ok {
+				imports = append(imports, imp)
+			}
+		}
+		sort.Strings(imports)
+		for _, imp := range imports {
+			if _, ok := chains[imp]; ok {
+				continue
+			}
+			chains[imp] = append(append([]string{}, chains[path]...), imp)
+			queue = append(queue, imp)
+		}
+	}
+	return chains
+}
+
+// writeInitOrder は初期化情報をテキストで出力する
+func writeInitOrder(w io.Writer, inits []PackageInit) error {
+	for _, pi := range inits {
+		fmt.Fprintf(w, "%d. %s\n", pi.Order+1, pi.Path)
+		if len(pi.Chain) > 1 {
+			fmt.Fprintf(w, "  imported via: %s\n", strings.Join(pi.Chain, " -> "))
+		}
+		for _, v := range pi.Vars {
+			fmt.Fprintf(w, "  var %s\n", v)
+		}
+		for _, fn := range pi.InitFuncs {
+			fmt.Fprintf(w, "  func init at %s\n", fn.Pos)
+			for _, call := range fn.Calls {
+				fmt.Fprintf(w, "    calls %s\n", call)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestInitOrder(t *testing.T) {
+	prog := loadTestProgram(t, map[string]string{
+		"main.go": testdata_src1 + `
+var total = sum(count, 1)
+var count = len(names)
+var names = []string{"a", "b"}
+
+func sum(a, b int) int { return a + b }
+`,
+		"example/example.go": `package example
+
+import "example.com/m/example/inner"
+
+var Value = inner.Base * 2
+
+func init() {
+	inner.Register("example")
+}
+`,
+		"example/inner/inner.go": `package inner
+
+var Base = 21
+
+var registered []string
+
+func Register(name string) { registered = append(registered, name) }
+`,
+		"cmd/tool/main.go": `package main
+
+import _ "example.com/m/example"
+
+func main() {}
+`,
+	})
+
+	var buf bytes.Buffer
+	if err := writeInitOrder(&buf, initOrder(prog)); err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	for i, line := range got {
+		// ファイルの絶対パスは一時ディレクトリなので除く
+		if j := strings.Index(line, "func init at "); j >= 0 {
+			got[i] = line[:j] + "func init at " + line[strings.LastIndex(line, "/")+1:]
+		}
+	}
+	assertLines(t, got, []string{
+		"1. example.com/m",
+		"  var names = []string{…}",
+		"  var count = len(names)",
+		"  var total = sum(count, 1)",
+		"  func init at main.go:68:1",
+		"    calls example.com/m.useInterface",
+		"2. example.com/m/example/inner",
+		"  imported via: example.com/m/cmd/tool -> example.com/m/example -> example.com/m/example/inner",
+		"  var Base = 21",
+		"3. example.com/m/example",
+		"  imported via: example.com/m/cmd/tool -> example.com/m/example",
+		"  var Value = inner.Base * 2",
+		"  func init at example.go:7:1",
+		"    calls example.com/m/example/inner.Register",
+		"4. example.com/m/cmd/tool",
+	})
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// JSONType は encoding/json で (デ)シリアライズされる構造体と、そのワイヤー上のスキーマ
+type JSONType struct {
+	Type    string           `json:"type"`
+	Encode  bool             `json:"encode,omitempty"` // Marshal や Encode に渡される (またはそうされる型のフィールド)
+	Decode  bool             `json:"decode,omitempty"` // Unmarshal や Decode に渡される (またはそうされる型のフィールド)
+	Custom  []string         `json:"custom,omitempty"` // MarshalJSON などを実装していて、フィールドがそのままは使われない
+	Fields  []JSONField      `json:"fields,omitempty"`
+	Dropped []JSONDropped    `json:"dropped,omitempty"`
+	Sites   []token.Position `json:"sites,omitempty"` // 渡している呼び出し (フィールドとしてだけ使われる型なら空)
+	Pos     token.Position   `json:"pos"`             // 型の宣言位置
+
+	named *types.Named
+}
+
+// JSONField はワイヤー上のフィールド 1 つ
+type JSONField struct {
+	Name      string `json:"name"`  // JSON のキー
+	Field     string `json:"field"` // Go のフィールド (埋め込まれた構造体のものなら Embedded.Field)
+	Type      string `json:"type"`  // Go の型
+	OmitEmpty bool   `json:"omitempty,omitempty"`
+	OmitZero  bool   `json:"omitzero,omitempty"`
+	String    bool   `json:"string,omitempty"` // ,string で数値や真偽値を文字列にする
+	Tagged    bool   `json:"tagged,omitempty"` // json タグで名前を付けている
+
+	typ types.Type
+}
+
+// JSONDropped は encoding/json に黙って無視されるフィールド
+type JSONDropped struct {
+	Field  string         `json:"field"`
+	Reason string         `json:"reason"`
+	Pos    token.Position `json:"pos"`
+}
+
+// jsonCodecFuncs は値を (デ)シリアライズする関数と、値の引数の位置 (レシーバを除く)。
+// gin と echo の JSON の応答とリクエストのバインドも含める
+var jsonCodecFuncs = map[string]struct {
+	Arg    int
+	Encode bool
+}{
+	"encoding/json.Marshal":                              {0, true},
+	"encoding/json.MarshalIndent":                        {0, true},
+	"encoding/json.Unmarshal":                            {1, false},
+	"(*encoding/json.Encoder).Encode":                    {0, true},
+	"(*encoding/json.Decoder).Decode":                    {0, false},
+	"(*github.com/gin-gonic/gin.Context).JSON":           {1, true},
+	"(*github.com/gin-gonic/gin.Context).IndentedJSON":   {1, true},
+	"(*github.com/gin-gonic/gin.Context).BindJSON":       {0, false},
+	"(*github.com/gin-gonic/gin.Context).ShouldBindJSON": {0, false},
+	"(github.com/labstack/echo/v4.Context).JSON":         {1, true},
+	"(github.com/labstack/echo/v4.Context).Bind":         {0, false},
+}
+
+func runJSONSchema(args []string) error {
+	fs := flag.NewFlagSet("jsonschema", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output as JSON")
+	droppedOnly := fs.Bool("dropped", false, "only list types with fields encoding/json silently ignores")
+	fs.Parse(args)
+	prog, err := loadProgram(".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	result := jsonTypes(prog)
+	if *droppedOnly {
+		result = slices.DeleteFunc(result, func(t *JSONType) bool { return len(t.Dropped) == 0 })
+	}
+	if *asJSON {
+		relativizePositions(result)
+		return writeJSON(os.Stdout, result)
+	}
+	return writeJSONTypes(os.Stdout, result)
+}
+
+// jsonTypes は jsonCodecFuncs に渡される解析対象のパッケージの構造体 (ポインタ、スライス、マップの要素を含む) と、
+// それらのフィールドから参照される構造体について、encoding/json と同じ規則で決まるワイヤー上のフィールドを型の名前の順に返す
+func jsonTypes(prog *Program) []*JSONType {
+	byType := make(map[*types.Named]*JSONType)
+	var queue []*JSONType
+	add := func(t types.Type, encode bool, site *token.Position) {
+		for _, named := range jsonStructs(t) {
+			if named.Obj().Pkg() == nil || !prog.isTarget(named.Obj().Pkg()) {
+				continue
+			}
+			jt, ok := byType[named]
+			if !ok {
+				jt = &JSONType{Type: named.Obj().Pkg().Path() + "." + named.Obj().Name(), Pos: prog.Fset.Position(named.Obj().Pos()), named: named}
+				byType[named] = jt
+			}
+			if site != nil && !slices.Contains(jt.Sites, *site) {
+				jt.Sites = append(jt.Sites, *site)
+			}
+			if encode && !jt.Encode || !encode && !jt.Decode {
+				jt.Encode, jt.Decode = jt.Encode || encode, jt.Decode || !encode
+				queue = append(queue, jt) // 初めて見たか方向が増えたので、フィールドの型にも伝える
+			}
+		}
+	}
+	for _, site := range CallSites(prog.Packages) {
+		codec, ok := jsonCodecFuncs[site.CalleeName]
+		if !ok || codec.Arg >= len(site.Call.Args) {
+			continue
+		}
+		if t := site.Pkg.TypesInfo.TypeOf(site.Call.Args[codec.Arg]); t != nil {
+			add(t, codec.Encode, &site.Pos)
+		}
+	}
+	for len(queue) > 0 {
+		jt := queue[0]
+		queue = queue[1:]
+		if jt.Fields == nil && jt.Dropped == nil {
+			jt.Custom = jsonCustomMethods(jt.named)
+			jt.Fields, jt.Dropped = jsonFields(prog.Fset, jt.named.Underlying().(*types.Struct))
+		}
+		if len(jt.Custom) > 0 {
+			continue // フィールドの型はワイヤー上に現れるとは限らない
+		}
+		for _, f := range jt.Fields {
+			if jt.Encode {
+				add(f.typ, true, nil)
+			}
+			if jt.Decode {
+				add(f.typ, false, nil)
+			}
+		}
+	}
+	var result []*JSONType
+	for _, jt := range byType {
+		result = append(result, jt)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Type < result[j].Type })
+	return result
+}
+
+// jsonStructs は t (のポインタ、スライス、配列、マップの値) の名前付きの構造体を返す
+func jsonStructs(t types.Type) []*types.Named {
+	for {
+		switch u := t.(type) {
+		case *types.Pointer:
+			t = u.Elem()
+			continue
+		case *types.Slice:
+			t = u.Elem()
+			continue
+		case *types.Array:
+			t = u.Elem()
+			continue
+		case *types.Map:
+			t = u.Elem()
+			continue
+		case *types.Alias:
+			t = types.Unalias(u)
+			continue
+		case *types.Named:
+			if _, ok := u.Underlying().(*types.Struct); ok {
+				return []*types.Named{u}
+			}
+		}
+		return nil
+	}
+}
+
+// jsonCustomMethods は named (またはそのポインタ) が実装している、(デ)シリアライズを置き換えるメソッドを返す
+func jsonCustomMethods(named *types.Named) []string {
+	var methods []string
+	mset := types.NewMethodSet(types.NewPointer(named))
+	for _, name := range []string{"MarshalJSON", "UnmarshalJSON", "MarshalText", "UnmarshalText"} {
+		if mset.Lookup(nil, name) != nil {
+			methods = append(methods, name)
+		}
+	}
+	return methods
+}
+
+// jsonField は探索中のフィールドの候補
+type jsonField struct {
+	JSONField
+	index []int
+	pos   token.Position
+}
+
+// jsonFields は encoding/json の typeFields と同じように、埋め込まれた構造体のフィールドを展開して
+// ワイヤー上のフィールドをフィールドの順に返す。同じ名前のフィールドは浅いものが優先され、同じ深さなら
+// タグで名前を付けたものが 1 つだけのときにそれが残る。残らなかったものと、エクスポートされていないフィールドは dropped に入る
+func jsonFields(fset *token.FileSet, st *types.Struct) (fields []JSONField, dropped []JSONDropped) {
+	type level struct {
+		st     *types.Struct
+		index  []int
+		prefix string
+	}
+	var candidates []jsonField
+	depths := make(map[string]int) // 名前ごとのいちばん浅い深さ
+	current := []level{{st: st}}
+	visited := make(map[*types.Struct]bool)
+	for depth := 0; len(current) > 0; depth++ {
+		var next []level
+		for _, l := range current {
+			if visited[l.st] {
+				continue
+			}
+			visited[l.st] = true
+			for i := 0; i < l.st.NumFields(); i++ {
+				f := l.st.Field(i)
+				index := append(slices.Clip(l.index), i)
+				tag := reflect.StructTag(l.st.Tag(i)).Get("json")
+				if tag == "-" {
+					continue
+				}
+				name, opts, _ := strings.Cut(tag, ",")
+				ft := f.Type()
+				if ptr, ok := ft.(*types.Pointer); ok {
+					ft = ptr.Elem()
+				}
+				embedded, isStruct := ft.Underlying().(*types.Struct)
+				if f.Anonymous() {
+					if !f.Exported() && !isStruct {
+						continue
+					}
+				} else if !f.Exported() {
+					reason := "unexported field"
+					if tag != "" {
+						reason += " with a json tag"
+					}
+					dropped = append(dropped, JSONDropped{Field: l.prefix + f.Name(), Reason: reason, Pos: fset.Position(f.Pos())})
+					continue
+				}
+				if name == "" && f.Anonymous() && isStruct {
+					next = append(next, level{st: embedded, index: index, prefix: l.prefix + f.Name() + "."})
+					continue
+				}
+				if !f.Exported() {
+					continue // 埋め込まれたエクスポートされていない構造体のポインタに名前を付けたもの
+				}
+				jf := JSONField{Name: name, Field: l.prefix + f.Name(), Type: types.TypeString(f.Type(), (*types.Package).Name), Tagged: name != "", typ: f.Type()}
+				if jf.Name == "" {
+					jf.Name = f.Name()
+				}
+				for _, opt := range strings.Split(opts, ",") {
+					switch opt {
+					case "omitempty":
+						jf.OmitEmpty = true
+					case "omitzero":
+						jf.OmitZero = true
+					case "string":
+						jf.String = true
+					}
+				}
+				if d, ok := depths[jf.Name]; !ok || depth < d {
+					depths[jf.Name] = depth
+				}
+				candidates = append(candidates, jsonField{JSONField: jf, index: index, pos: fset.Position(f.Pos())})
+			}
+		}
+		current = next
+	}
+
+	// 同じ名前のフィールドのうち残るものを選ぶ
+	byName := make(map[string][]jsonField)
+	for _, c := range candidates {
+		if len(c.index)-1 == depths[c.Name] {
+			byName[c.Name] = append(byName[c.Name], c)
+		} else {
+			dropped = append(dropped, JSONDropped{Field: c.Field, Reason: "shadowed by a shallower field " + c.Name, Pos: c.pos})
+		}
+	}
+	var kept []jsonField
+	for name, cs := range byName {
+		if len(cs) > 1 {
+			var tagged []jsonField
+			for _, c := range cs {
+				if c.Tagged {
+					tagged = append(tagged, c)
+				}
+			}
+			if len(tagged) != 1 {
+				for _, c := range cs {
+					dropped = append(dropped, JSONDropped{Field: c.Field, Reason: "conflicts with another field named " + name, Pos: c.pos})
+				}
+				continue
+			}
+			cs = tagged
+		}
+		kept = append(kept, cs[0])
+	}
+	sort.Slice(kept, func(i, j int) bool { return slices.Compare(kept[i].index, kept[j].index) < 0 })
+	for _, k := range kept {
+		fields = append(fields, k.JSONField)
+	}
+	sort.SliceStable(dropped, func(i, j int) bool { return dropped[i].Field < dropped[j].Field })
+	return fields, dropped
+}
+
+func writeJSONTypes(w io.Writer, result []*JSONType) error {
+	for _, t := range result {
+		var modes []string
+		if t.Encode {
+			modes = append(modes, "encode")
+		}
+		if t.Decode {
+			modes = append(modes, "decode")
+		}
+		fmt.Fprintf(w, "%s (%s)\n", t.Type, strings.Join(modes, ", "))
+		if len(t.Custom) > 0 {
+			fmt.Fprintf(w, "  custom %s\n", strings.Join(t.Custom, ", "))
+		}
+		for _, f := range t.Fields {
+			details := []string{f.Field}
+			if f.OmitEmpty {
+				details = append(details, "omitempty")
+			}
+			if f.OmitZero {
+				details = append(details, "omitzero")
+			}
+			if f.String {
+				details = append(details, "string")
+			}
+			fmt.Fprintf(w, "  %s: %s (%s)\n", f.Name, f.Type, strings.Join(details, ", "))
+		}
+		for _, d := range t.Dropped {
+			fmt.Fprintf(w, "  ! %s: %s\n", d.Field, d.Reason)
+		}
+	}
+	return nil
+}
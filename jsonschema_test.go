@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestJSONTypes(t *testing.T) {
+	prog := loadTestProgram(t, map[string]string{
+		"main.go": `package main
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+type Base struct {
+	ID      int64 ` + "`json:\"id\"`" + `
+	Created string
+}
+
+type Address struct {
+	City string ` + "`json:\"city,omitempty\"`" + `
+}
+
+type User struct {
+	Base
+	Name     string   ` + "`json:\"name\"`" + `
+	Age      int      ` + "`json:\"age,string,omitempty\"`" + `
+	Password string   ` + "`json:\"-\"`" + `
+	secret   string   ` + "`json:\"secret\"`" + `
+	Home     *Address ` + "`json:\"home\"`" + `
+	Tags     []string
+	ID       string ` + "`json:\"uid\"`" + `
+}
+
+type Stamp struct{ t time.Time }
+
+func (s Stamp) MarshalJSON() ([]byte, error) { return nil, nil }
+
+type Event struct {
+	At   Stamp
+	User User ` + "`json:\"user\"`" + `
+}
+
+type A struct{ X int }
+
+type B struct{ X int }
+
+type Pair struct {
+	A
+	B
+	Y int
+}
+
+func main() {
+	var u User
+	json.Unmarshal([]byte("{}"), &u)
+	json.NewEncoder(os.Stdout).Encode([]Event{})
+	json.Marshal(Pair{})
+}
+`,
+	})
+	result := jsonTypes(prog)
+	var buf bytes.Buffer
+	if err := writeJSONTypes(&buf, result); err != nil {
+		t.Fatal(err)
+	}
+	assertLines(t, strings.Split(strings.TrimSpace(buf.String()), "\n"), []string{
+		"example.com/m.Address (encode, decode)",
+		"  city: string (City, omitempty)",
+		"example.com/m.Event (encode)",
+		"  At: main.Stamp (At)",
+		"  user: main.User (User)",
+		"example.com/m.Pair (encode)",
+		"  Y: int (Y)",
+		"  ! A.X: conflicts with another field named X",
+		"  ! B.X: conflicts with another field named X",
+		"example.com/m.Stamp (encode)",
+		"  custom MarshalJSON",
+		"  ! t: unexported field",
+		"example.com/m.User (encode, decode)",
+		"  id: int64 (Base.ID)",
+		"  Created: string (Base.Created)",
+		"  name: string (Name)",
+		"  age: int (Age, omitempty, string)",
+		"  home: *main.Address (Home)",
+		"  Tags: []string (Tags)",
+		"  uid: string (ID)",
+		"  ! secret: unexported field with a json tag",
+	})
+	if got := len(result[4].Sites); got != 1 {
+		t.Errorf("got %d call sites for %s, want 1", got, result[4].Type)
+	}
+}
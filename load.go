@@ -0,0 +1,272 @@
+package main
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/callgraph/cha"
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+// Program は解析対象のパッケージと、必要に応じて構築する SSA・コールグラフをまとめたもの。
+// 読み込んだ後は変更せず、SSA・コールグラフなどは初めて必要になったときに 1 度だけ作るので、
+// メソッドは複数の goroutine から同時に呼んでよい
+type Program struct {
+	Fset     *token.FileSet
+	Packages []*packages.Package // 解析対象のパッケージ (依存パッケージは含まない)
+	Errors   []*PosError         // loadOptions.Tolerant で読み込んだときのパッケージのエラー
+
+	approximate map[string]bool   // エラーのあるパッケージのファイル
+	generated   map[string]bool   // 生成されたファイル
+	protos      map[string]string // protoc で生成されたメッセージのファイルと生成元の .proto (protoSources)
+
+	overlay   map[string][]byte // -ref を指定したときの ref の時点のファイルの内容
+	changes   ChangeSet         // -changed-only を指定したときの変更された行
+	focus     map[*ssa.Function]bool
+	facts     *factStore // -facts を指定したときの依存パッケージの事実
+	ssa       *ssa.Program
+	ssaPkgs   []*ssa.Package
+	callGraph *callgraph.Graph
+
+	focusOnce, ssaOnce, callGraphOnce, protoOnce sync.Once
+}
+
+const loadMode = packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedDeps |
+	packages.NeedTypes | packages.NeedSyntax | packages.NeedTypesInfo | packages.NeedModule
+
+// loadOptions は loadProgramWith での読み込み方法
+type loadOptions struct {
+	Tests bool     // _test.go も含めて読み込む
+	Ref   string   // 空でなければ作業ツリーではなく git のこのコミットのファイルを読み込む
+	Tags  []string // ビルドタグ (go build -tags)
+	// Tolerant なら構文や型のエラーがあっても読み込みを続け、エラーを Program.Errors に集める。
+	// エラーのあるパッケージの解析結果は、型の情報が欠けているので近似になる
+	Tolerant bool
+}
+
+// defaultRef は main の -ref で指定されたコミット。loadOptions.Ref が空のときに使う
+var defaultRef string
+
+// defaultTolerant は main の -tolerant。true なら loadOptions.Tolerant を指定しなくても true とみなす
+var defaultTolerant bool
+
+// loadProgram は dir を起点に patterns のパッケージを読み込む
+func loadProgram(dir string, patterns ...string) (*Program, error) {
+	return loadProgramWith(loadOptions{}, dir, patterns...)
+}
+
+// loadProgramWith は opts に従って dir を起点に patterns のパッケージを読み込む
+func loadProgramWith(opts loadOptions, dir string, patterns ...string) (*Program, error) {
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+	if opts.Ref == "" {
+		opts.Ref = defaultRef
+	}
+	opts.Tolerant = opts.Tolerant || defaultTolerant
+	var overlay map[string][]byte
+	if opts.Ref != "" {
+		var err error
+		if overlay, err = gitOverlay(dir, opts.Ref); err != nil {
+			return nil, err
+		}
+	}
+	ws, err := findWorkspace(dir)
+	if err != nil {
+		return nil, err
+	}
+	if ws != nil {
+		if patterns, err = ws.patterns(dir, patterns); err != nil {
+			return nil, err
+		}
+	}
+	fset := token.NewFileSet()
+	conf := &packages.Config{
+		Mode:    loadMode,
+		Dir:     dir,
+		Fset:    fset,
+		Tests:   opts.Tests,
+		Overlay: overlay,
+	}
+	if ws != nil {
+		conf.Env = ws.Env
+	}
+	if len(opts.Tags) > 0 {
+		conf.BuildFlags = []string{"-tags=" + strings.Join(opts.Tags, ",")}
+	}
+	pkgs, err := packages.Load(conf, patterns...)
+	if err != nil {
+		return nil, fmt.Errorf("load %v: %w", patterns, err)
+	}
+	if opts.Tests {
+		pkgs = testVariants(pkgs)
+	}
+	// パターンや go list の出力の順によらず、出力が同じ順になるようにパッケージをパスの順に並べる
+	sort.Slice(pkgs, func(i, j int) bool { return pkgs[i].ID < pkgs[j].ID })
+	prog := &Program{Fset: fset, Packages: pkgs, overlay: overlay}
+	prog.generated = generatedFiles(prog)
+	if defaultFactsDir != "" {
+		prog.facts = newFactStore(defaultFactsDir, prog)
+	}
+	var loadErr error
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		if len(pkg.Errors) == 0 {
+			return
+		}
+		if !opts.Tolerant {
+			if loadErr == nil {
+				loadErr = fmt.Errorf("%s: %w", pkg.PkgPath, packageError(pkg.Errors[0]))
+			}
+			return
+		}
+		for _, e := range pkg.Errors {
+			prog.Errors = append(prog.Errors, packageError(e))
+		}
+		if prog.approximate == nil {
+			prog.approximate = make(map[string]bool)
+		}
+		for _, name := range pkg.GoFiles {
+			prog.approximate[name] = true
+		}
+	})
+	if loadErr != nil {
+		return nil, loadErr
+	}
+	if defaultChanges.Enabled {
+		if prog.changes, err = loadChanges(dir, opts.Ref, defaultChanges); err != nil {
+			return nil, err
+		}
+	}
+	return prog, nil
+}
+
+// ReadFile は読み込んだときと同じ内容のファイルを返す (-ref を指定していれば ref の時点の内容)
+func (p *Program) ReadFile(filename string) ([]byte, error) {
+	if src, ok := p.overlay[filename]; ok {
+		return src, nil
+	}
+	return os.ReadFile(filename)
+}
+
+// testVariants はテストを含むパッケージがあれば元のパッケージの代わりにそれを使い、
+// go test が生成する main パッケージ (path.test) を除く
+func testVariants(pkgs []*packages.Package) []*packages.Package {
+	hasTest := make(map[string]bool)
+	for _, pkg := range pkgs {
+		if pkg.ID != pkg.PkgPath && strings.HasPrefix(pkg.ID, pkg.PkgPath+" [") {
+			hasTest[pkg.PkgPath] = true
+		}
+	}
+	var result []*packages.Package
+	for _, pkg := range pkgs {
+		if strings.HasSuffix(pkg.PkgPath, ".test") || pkg.ID == pkg.PkgPath && hasTest[pkg.PkgPath] {
+			continue
+		}
+		result = append(result, pkg)
+	}
+	return result
+}
+
+// SSA は全パッケージの SSA を構築して返す (初回のみ構築)
+func (p *Program) SSA() *ssa.Program {
+	p.ssaOnce.Do(func() {
+		p.ssa, p.ssaPkgs = buildSSA(p.Packages, ssa.InstantiateGenerics)
+		p.ssa.Build()
+	})
+	return p.ssa
+}
+
+// buildSSA は pkgs とその依存パッケージの SSA を作る (Build は呼ばない)。ssautil.AllPackages は
+// エラーのあるパッケージに依存するだけのパッケージ (IllTyped) も除くので、自身にエラーがなければ関数の本体まで作り、
+// エラーのあるパッケージは宣言だけ (本体のない外部の関数) にする。返すスライスは pkgs と同じ順で、
+// エラーのあるパッケージは nil
+func buildSSA(pkgs []*packages.Package, mode ssa.BuilderMode) (*ssa.Program, []*ssa.Package) {
+	var fset *token.FileSet
+	if len(pkgs) > 0 {
+		fset = pkgs[0].Fset
+	}
+	prog := ssa.NewProgram(fset, mode)
+	created := make(map[*packages.Package]*ssa.Package)
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		if pkg.Types == nil {
+			return
+		}
+		if len(pkg.Errors) == 0 && pkg.TypesInfo != nil {
+			created[pkg] = prog.CreatePackage(pkg.Types, pkg.Syntax, pkg.TypesInfo, true)
+		} else {
+			prog.CreatePackage(pkg.Types, nil, nil, true)
+		}
+	})
+	ssaPkgs := make([]*ssa.Package, len(pkgs))
+	for i, pkg := range pkgs {
+		ssaPkgs[i] = created[pkg]
+	}
+	return prog, ssaPkgs
+}
+
+// ssaSkipped は Tolerant で読み込んだときに、エラーがあるので SSA の関数の本体を作らない解析対象のパッケージを返す
+func (p *Program) ssaSkipped() []*packages.Package {
+	var skipped []*packages.Package
+	for _, pkg := range p.Packages {
+		if len(pkg.Errors) > 0 {
+			skipped = append(skipped, pkg)
+		}
+	}
+	return skipped
+}
+
+// CallGraph は CHA によるコールグラフを返す
+func (p *Program) CallGraph() *callgraph.Graph {
+	p.callGraphOnce.Do(func() {
+		p.callGraph = cha.CallGraph(p.SSA())
+		p.callGraph.DeleteSyntheticNodes()
+	})
+	return p.callGraph
+}
+
+// isTarget は pkg が解析対象のパッケージかどうかを返す
+func (p *Program) isTarget(pkg *types.Package) bool {
+	if pkg == nil {
+		return false
+	}
+	for _, target := range p.Packages {
+		if target.Types == pkg {
+			return true
+		}
+	}
+	return false
+}
+
+// targetFunctions は解析対象パッケージに属する SSA 関数を返す (無名関数を含む)
+func (p *Program) targetFunctions() []*ssa.Function {
+	var fns []*ssa.Function
+	for fn := range ssautil.AllFunctions(p.SSA()) {
+		if fn.Pkg != nil && p.isTarget(fn.Pkg.Pkg) {
+			fns = append(fns, fn)
+		}
+	}
+	sortFunctions(fns)
+	return fns
+}
+
+// sortFunctions は関数を宣言位置 (ファイル名、オフセット)、名前の順に並べる
+func sortFunctions(fns []*ssa.Function) {
+	sort.Slice(fns, func(i, j int) bool {
+		a, b := fns[i].Prog.Fset.Position(fns[i].Pos()), fns[j].Prog.Fset.Position(fns[j].Pos())
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		if a.Offset != b.Offset {
+			return a.Offset < b.Offset
+		}
+		return fns[i].String() < fns[j].String()
+	})
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeModule は files をモジュール example.com/m として一時ディレクトリに書き出す
+func writeModule(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if _, ok := files["go.mod"]; !ok {
+		files["go.mod"] = "module example.com/m\n\ngo 1.22\n"
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// loadTestProgram は files を書き出して全パッケージを読み込む
+func loadTestProgram(t *testing.T, files map[string]string) *Program {
+	t.Helper()
+	prog, err := loadProgram(writeModule(t, files), "./...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return prog
+}
+
+// diagnosticMessages は Diagnostic を "ファイル名:行: メッセージ" の形にする
+func diagnosticMessages(diags []Diagnostic) []string {
+	var msgs []string
+	for _, d := range diags {
+		msgs = append(msgs, fmt.Sprintf("%s:%d: %s", filepath.Base(d.Pos.Filename), d.Pos.Line, d.Message))
+	}
+	return msgs
+}
+
+func TestLoadProgram(t *testing.T) {
+	prog := loadTestProgram(t, map[string]string{
+		"main.go":            testdata_src1,
+		"example/example.go": testdata_src_2_example,
+	})
+	if len(prog.Packages) != 2 {
+		t.Fatalf("got %d packages, want 2", len(prog.Packages))
+	}
+	var got []string
+	for _, fn := range prog.targetFunctions() {
+		got = append(got, fn.RelString(nil))
+	}
+	assertLines(t, got, []string{
+		"example.com/m.init",
+		"example.com/m/example.init",
+		"example.com/m/example.Example",
+		"(example.com/m/example.AnotherImplementation).AnotherMethod",
+		"example.com/m.main",
+		"example.com/m.main$1",
+		"(example.com/m.MyImplementation).Method1",
+		"(example.com/m.MyImplementation).Method2",
+		"example.com/m.useInterface",
+		"example.com/m.init#1",
+	})
+}
+
+// assertLines は got と want を 1 行ずつ比較する
+func assertLines(t *testing.T, got, want []string) {
+	t.Helper()
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
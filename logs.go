@@ -0,0 +1,229 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// LogStatement はログを出力する呼び出し 1 件
+type LogStatement struct {
+	Library string         `json:"library"` // log, slog, zap, logrus
+	Level   string         `json:"level"`
+	Message string         `json:"message"` // メッセージまたは書式。定数でなければ {式}
+	Fields  []string       `json:"fields,omitempty"`
+	Func    string         `json:"func"`
+	Pos     token.Position `json:"pos"`
+}
+
+// logLibraries はロガーのパッケージと、LogStatement.Library の名前
+var logLibraries = map[string]string{
+	"log":                        "log",
+	"log/slog":                   "slog",
+	"go.uber.org/zap":            "zap",
+	"github.com/sirupsen/logrus": "logrus",
+}
+
+// logLevels はメソッド名 (f, ln, w, Context を除いたもの) とログレベル
+var logLevels = map[string]string{
+	"Print":   "info",
+	"Trace":   "trace",
+	"Debug":   "debug",
+	"Info":    "info",
+	"Warn":    "warn",
+	"Warning": "warn",
+	"Error":   "error",
+	"DPanic":  "dpanic",
+	"Panic":   "panic",
+	"Fatal":   "fatal",
+	"Log":     "", // slog の Log(ctx, level, msg, ...) は引数で決まる
+}
+
+func runLogs(args []string) error {
+	fs := flag.NewFlagSet("logs", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output as JSON")
+	fs.Parse(args)
+	prog, err := loadProgram(".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	stmts := logStatements(prog.Packages)
+	relativizePositions(stmts)
+	if *asJSON {
+		return writeJSON(os.Stdout, stmts)
+	}
+	return writeLogStatements(os.Stdout, stmts)
+}
+
+// logStatements は log, slog, zap, logrus のログ出力の呼び出しを、レベル、メッセージ、フィールドとともに集める
+func logStatements(pkgs []*packages.Package) []LogStatement {
+	var stmts []LogStatement
+	for _, site := range CallSites(pkgs) {
+		if stmt, ok := logStatement(site); ok {
+			stmt.Func = site.CallerName // 関数の外 (パッケージ変数の初期化) では空、無名関数の中では pkg.Func$1
+			stmt.Pos = site.Pos
+			stmts = append(stmts, stmt)
+		}
+	}
+	return stmts
+}
+
+// logStatement は site がログ出力であれば LogStatement を返す (Func と Pos は設定しない)
+func logStatement(site *CallSite) (LogStatement, bool) {
+	info, call, fn := site.Pkg.TypesInfo, site.Call, site.Func()
+	if fn == nil || fn.Pkg() == nil {
+		return LogStatement{}, false
+	}
+	lib, ok := logLibraries[fn.Pkg().Path()]
+	recv := fn.Type().(*types.Signature).Recv()
+	if !ok || (lib == "zap" && recv == nil) { // zap.Error などはフィールドを作る関数
+		return LogStatement{}, false
+	}
+	name := strings.TrimSuffix(fn.Name(), "Context")
+	style := ""
+	for _, suffix := range []string{"ln", "f", "w"} {
+		if base, ok := strings.CutSuffix(name, suffix); ok {
+			if _, known := logLevels[base]; known {
+				name, style = base, suffix
+				break
+			}
+		}
+	}
+	level, ok := logLevels[name]
+	if !ok {
+		return LogStatement{}, false
+	}
+
+	args := call.Args
+	if len(args) > 0 && isContextType(info.TypeOf(args[0])) {
+		args = args[1:]
+	}
+	if name == "Log" {
+		if len(args) == 0 {
+			return LogStatement{}, false
+		}
+		// slog.LevelWarn, logrus.WarnLevel
+		level = types.ExprString(args[0])
+		level = strings.ToLower(strings.TrimSuffix(strings.TrimPrefix(level[strings.LastIndex(level, ".")+1:], "Level"), "Level"))
+		args = args[1:]
+	}
+	stmt := LogStatement{Library: lib, Level: level}
+	if len(args) > 0 {
+		stmt.Message = logMessage(info, args[0])
+		args = args[1:]
+	}
+
+	// slog と zap.Logger はメッセージの後ろがキーと値の組または属性、SugaredLogger の Infow も同様
+	isZapLogger := lib == "zap" && recvTypeName(fn) == "Logger"
+	structured := lib == "slog" || isZapLogger || style == "w"
+	stmt.Fields = logFields(info, args, structured)
+	if lib == "logrus" {
+		stmt.Fields = append(logrusFields(info, call.Fun), stmt.Fields...)
+	}
+	return stmt, true
+}
+
+// logMessage は定数であればその文字列を、そうでなければ {式} を返す
+func logMessage(info *types.Info, expr ast.Expr) string {
+	if tv := info.Types[expr]; tv.Value != nil && tv.Value.Kind() == constant.String {
+		return constant.StringVal(tv.Value)
+	}
+	return "{" + types.ExprString(expr) + "}"
+}
+
+// logFields はメッセージ以外の引数をフィールド名にする。structured ならキーと値の組、
+// zap.String("user", u) や slog.Int("n", n) のような属性はキー、それ以外は式のソースにする
+func logFields(info *types.Info, args []ast.Expr, structured bool) []string {
+	var fields []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if structured {
+			if tv := info.Types[arg]; tv.Value != nil && tv.Value.Kind() == constant.String {
+				fields = append(fields, constant.StringVal(tv.Value))
+				i++ // 値
+				continue
+			}
+			if key := attrKey(info, arg); key != "" {
+				fields = append(fields, key)
+				continue
+			}
+		}
+		fields = append(fields, types.ExprString(arg))
+	}
+	return fields
+}
+
+// attrKey は slog.String("k", v) や zap.Error(err) のような属性を作る呼び出しのキーを返す
+func attrKey(info *types.Info, expr ast.Expr) string {
+	call, ok := ast.Unparen(expr).(*ast.CallExpr)
+	if !ok {
+		return ""
+	}
+	fn, ok := typeutil.Callee(info, call).(*types.Func)
+	if !ok || fn.Pkg() == nil || (fn.Pkg().Path() != "log/slog" && fn.Pkg().Path() != "go.uber.org/zap") {
+		return ""
+	}
+	if fn.Name() == "Error" && len(call.Args) == 1 {
+		return "error"
+	}
+	if len(call.Args) > 0 {
+		if tv := info.Types[call.Args[0]]; tv.Value != nil && tv.Value.Kind() == constant.String {
+			return constant.StringVal(tv.Value)
+		}
+	}
+	return ""
+}
+
+// logrusFields は logrus.WithField("k", v).WithError(err).Info(...) のレシーバの連鎖からフィールド名を集める
+func logrusFields(info *types.Info, fun ast.Expr) []string {
+	sel, ok := ast.Unparen(fun).(*ast.SelectorExpr)
+	if !ok {
+		return nil
+	}
+	call, ok := ast.Unparen(sel.X).(*ast.CallExpr)
+	if !ok {
+		return nil
+	}
+	fields := logrusFields(info, call.Fun)
+	fn, ok := typeutil.Callee(info, call).(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Pkg().Path() != "github.com/sirupsen/logrus" {
+		return fields
+	}
+	switch fn.Name() {
+	case "WithField":
+		if len(call.Args) == 2 {
+			fields = append(fields, logMessage(info, call.Args[0]))
+		}
+	case "WithError":
+		fields = append(fields, "error")
+	case "WithFields":
+		if lit, ok := call.Args[0].(*ast.CompositeLit); ok {
+			for _, elt := range lit.Elts {
+				if kv, ok := elt.(*ast.KeyValueExpr); ok {
+					fields = append(fields, logMessage(info, kv.Key))
+				}
+			}
+		}
+	}
+	return fields
+}
+
+func writeLogStatements(w io.Writer, stmts []LogStatement) error {
+	for _, s := range stmts {
+		fields := ""
+		if len(s.Fields) > 0 {
+			fields = " [" + strings.Join(s.Fields, ", ") + "]"
+		}
+		fmt.Fprintf(w, "%s: %s %s: %q%s\n", s.Pos, s.Library, s.Level, s.Message, fields)
+	}
+	return nil
+}
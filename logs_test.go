@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var testdata_logs = map[string]string{
+	"go.mod": `module example.com/m
+
+go 1.22
+
+require (
+	github.com/sirupsen/logrus v0.0.0
+	go.uber.org/zap v0.0.0
+)
+
+replace github.com/sirupsen/logrus => ./third_party/logrus
+
+replace go.uber.org/zap => ./third_party/zap
+`,
+	"third_party/zap/go.mod": "module go.uber.org/zap\n\ngo 1.22\n",
+	"third_party/zap/zap.go": `package zap
+
+type Field struct{}
+
+func String(key, val string) Field { return Field{} }
+func Error(err error) Field        { return Field{} }
+
+type Logger struct{}
+
+func (*Logger) Info(msg string, fields ...Field)  {}
+func (*Logger) Sugar() *SugaredLogger             { return nil }
+
+type SugaredLogger struct{}
+
+func (*SugaredLogger) Infof(template string, args ...interface{})         {}
+func (*SugaredLogger) Errorw(msg string, keysAndValues ...interface{})    {}
+`,
+	"third_party/logrus/go.mod": "module github.com/sirupsen/logrus\n\ngo 1.22\n",
+	"third_party/logrus/logrus.go": `package logrus
+
+type Fields map[string]interface{}
+
+type Entry struct{}
+
+func (*Entry) WithError(err error) *Entry  { return nil }
+func (*Entry) Warn(args ...interface{})     {}
+
+func WithField(key string, value interface{}) *Entry { return nil }
+func WithFields(fields Fields) *Entry                { return nil }
+`,
+	"main.go": `package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"log/slog"
+
+	"github.com/sirupsen/logrus"
+	"go.uber.org/zap"
+)
+
+func main() {
+	ctx := context.Background()
+	user, err := "alice", errors.New("boom")
+	log.Printf("user %s logged in", user)
+	log.Fatal(err)
+	slog.InfoContext(ctx, "login", "user", user, slog.Int("attempts", 3))
+	slog.Log(ctx, slog.LevelWarn, "slow request")
+
+	var z *zap.Logger
+	z.Info("login", zap.String("user", user), zap.Error(err))
+	z.Sugar().Infof("user %s", user)
+	z.Sugar().Errorw("failed", "user", user)
+
+	logrus.WithField("user", user).WithError(err).Warn("retrying")
+	logrus.WithFields(logrus.Fields{"id": 1}).Warn(user)
+}
+`,
+}
+
+func TestLogStatements(t *testing.T) {
+	prog := loadTestProgram(t, testdata_logs)
+	var buf bytes.Buffer
+	if err := writeLogStatements(&buf, logStatements(prog.Packages)); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		got = append(got, line[strings.Index(line, "main.go"):])
+	}
+	assertLines(t, got, []string{
+		`main.go:16:2: log info: "user %s logged in" [user]`,
+		`main.go:17:2: log fatal: "{err}"`,
+		`main.go:18:2: slog info: "login" [user, attempts]`,
+		`main.go:19:2: slog warn: "slow request"`,
+		`main.go:22:2: zap info: "login" [user, error]`,
+		`main.go:23:2: zap info: "user %s" [user]`,
+		`main.go:24:2: zap error: "failed" [user]`,
+		`main.go:26:2: logrus warn: "retrying" [user, error]`,
+		`main.go:27:2: logrus warn: "{user}" [id]`,
+	})
+}
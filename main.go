@@ -1 +1,190 @@
 package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+)
+
+type command struct {
+	summary string
+	run     func(args []string) error
+}
+
+var commands = map[string]command{
+	"allocs":         {"list heap allocation candidates per function", runAllocs},
+	"archrules":      {"check import and call directions between package groups", runArchRules},
+	"assertcheck":    {"report impossible and unchecked type assertions", diagnosticsCommand("assertcheck", checkTypeAssertions)},
+	"binsize":        {"attribute the text size of a built binary to packages or functions reachable from main", runBinSize},
+	"boxing":         {"report conversions to interfaces in loops and frequently called functions", runBoxing},
+	"callgraph":      {"print call graph edges, optionally collapsing the standard library", runCallGraph},
+	"callsites":      {"list call sites with their callee, receiver type and call kind", runCallSites},
+	"classdiagram":   {"generate a Mermaid or PlantUML class diagram of structs and interfaces", runClassDiagram},
+	"complit":        {"rewrite struct literals to keyed or positional form", runCompLit},
+	"concatloop":     {"report strings built by concatenation or fmt.Sprintf in loops and rewrite them to strings.Builder", runConcatLoop},
+	"ctxcheck":       {"report context.Context misuse", diagnosticsCommand("ctxcheck", checkContext)},
+	"cycles":         {"report recursion groups in the call graph", runCycles},
+	"deadbranch":     {"report branches guarded by constant conditions and the code they make unreachable", diagnosticsCommand("deadbranch", checkDeadBranches)},
+	"deadcode":       {"report functions unreachable from the entry points", runDeadCode},
+	"defuse":         {"list uses of an SSA value across calls, returns, closures and printf wrappers", runDefUse},
+	"deprecated":     {"report references to deprecated declarations and packages", diagnosticsCommand("deprecated", checkDeprecated)},
+	"directives":     {"list go:generate and go:embed directives and their problems", runDirectives},
+	"doccheck":       {"report missing or malformed doc comments on exported identifiers", diagnosticsCommand("doccheck", checkDocs)},
+	"entrypoints":    {"list main, init, test, exported and handler entry points", runEntryPoints},
+	"envvars":        {"list environment variables and viper keys read by the program", runEnvVars},
+	"escape":         {"report how a local variable or receiver field leaves its function", runEscape},
+	"errtaxonomy":    {"catalog sentinel errors and error types with where they are created, wrapped, compared and stringified", runErrTaxonomy},
+	"export":         {"export symbols, call edges and diagnostics as protobuf or protojson", runExport},
+	"genexample":     {"generate Example functions for exported functions and methods", runGenExample},
+	"genfuzz":        {"generate fuzz targets for exported functions taking strings and byte slices", runGenFuzz},
+	"gentest":        {"generate table-driven test skeletons for pure functions", runGenTest},
+	"globals":        {"classify package-level variables and the functions that mutate them", runGlobals},
+	"goleak":         {"report goroutines that can block forever on a channel operation before they exit", diagnosticsCommand("goleak", checkGoroutineLeaks)},
+	"goversion":      {"report the minimum Go version the code needs and compare it with go.mod", runGoVersion},
+	"grpc":           {"map gRPC service methods to their registered implementations", runGRPC},
+	"impact":         {"list functions and interfaces impacted by a change set", runImpact},
+	"importcheck":    {"check import grouping, canonical aliases and dot imports, optionally fixing them", runImportCheck},
+	"initorder":      {"list init functions and package initialization order", runInitOrder},
+	"insert":         {"insert code rendered from a template at a structural location in a file", runInsert},
+	"jsonschema":     {"report the JSON wire schema of structs passed to encoding/json and the fields it silently drops", runJSONSchema},
+	"logs":           {"list log statements with level, message and fields", runLogs},
+	"makecap":        {"suggest size hints for map and chan makes filled by the following loop", runMakeCap},
+	"metrics":        {"print per-package size and complexity metrics", runMetrics},
+	"microperf":      {"rank defers, per-iteration closures and interface conversions by reachability from main", runMicroPerf},
+	"migrate":        {"report and rewrite code superseded by newer Go versions (io/ioutil, sort.Slice, rand.Seed, interface{})", runMigrate},
+	"minbinary":      {"report imported packages contributing no code reachable from main", runMinBinary},
+	"narrowiface":    {"suggest narrower interfaces for interface parameters", runNarrowIface},
+	"nearimpl":       {"suggest interfaces that concrete types almost implement", runNearImpl},
+	"nilness":        {"report pointer dereferences that may be nil on some path", diagnosticsCommand("nilness", checkNilness)},
+	"openapi":        {"generate a draft OpenAPI document from the route table and the JSON bodies handlers read and write", runOpenAPI},
+	"options":        {"list command-line flags and envconfig settings with defaults", runOptions},
+	"panicflow":      {"trace how each panic propagates to a recover, a goroutine boundary or an entry point", runPanicFlow},
+	"panics":         {"list functions that may panic with an example path", runPanics},
+	"params":         {"report unused parameters, ignored results and constant bool arguments", diagnosticsCommand("params", checkParams)},
+	"pkggraph":       {"print package dependencies as a layered DOT graph with cycle highlighting", runPkgGraph},
+	"printf":         {"check printf format verbs against argument types", runPrintf},
+	"printfwrappers": {"list functions that forward their arguments to printf", runPrintfWrappers},
+	"protomap":       {"map protobuf generated Go types to their message and enum definitions in .proto files", runProtoMap},
+	"provenance":     {"show which go:generate directive and input files produce each generated file", runProvenance},
+	"providercheck":  {"check google/wire provider sets and fx.Provide calls for unresolvable dependencies and unused providers", runProviderCheck},
+	"purity":         {"classify functions as pure or impure", runPurity},
+	"racecheck":      {"report variables written in one goroutine and accessed in another without synchronization", diagnosticsCommand("racecheck", checkRaces)},
+	"reflection":     {"report reflect and unsafe usage and reflection-tainted functions", runReflection},
+	"renamefield":    {"rename a struct field with its selectors and composite literal keys", runRenameField},
+	"report":         {"compose metrics, dead code, interfaces and graphs into a Markdown report", runReport},
+	"routes":         {"list HTTP routes registered with net/http, chi, gin and echo", runRoutes},
+	"rules":          {"run external rule programs over the JSON-over-stdio plugin protocol", runRules},
+	"script":         {"run custom checks written in Starlark against symbols, call sites and the call graph", runScript},
+	"selectcheck":    {"report blocking select statements in request handling paths and time.After in loops", diagnosticsCommand("selectcheck", checkSelects)},
+	"selects":        {"list select statements with their default and timeout cases", runSelects},
+	"sequence":       {"generate a Mermaid or PlantUML sequence diagram from a function", runSequence},
+	"simplify":       {"remove redundant zero values in struct literals, collapse nil-or-empty checks and normalize zero value declarations", runSimplify},
+	"slice":          {"print the backward and forward slice of a variable within its function", runSlice},
+	"sqlqueries":     {"list SQL query strings passed to database/sql, sqlx and gorm", runSQLQueries},
+	"testcheck":      {"report test helpers without t.Helper, misused subtest variables and packages without t.Parallel", runTestCheck},
+	"testselect":     {"print go test commands that exercise a change set", runTestSelect},
+	"thirdparty":     {"list external dependency APIs referenced by the module", runThirdParty},
+	"todos":          {"extract TODO, FIXME and HACK comments with their declarations", runTodos},
+	"typeasserts":    {"audit type assertions and type switches against method sets", runTypeAsserts},
+	"wirecheck":      {"report dependency cycles, missing and ambiguous providers among NewX constructors", diagnosticsCommand("wirecheck", checkWiring)},
+	"wiring":         {"generate a Mermaid or DOT diagram of which NewX constructors consume which constructed types", runWiring},
+}
+
+func main() {
+	log.SetFlags(0)
+	fs := flag.NewFlagSet("learn_ast", flag.ExitOnError)
+	fs.StringVar(&defaultRef, "ref", "", "analyze the files at the git `commit` instead of the working tree")
+	fs.BoolVar(&defaultChanges.Enabled, "changed-only", false, "restrict diagnostics and metrics to changed functions and their direct callers")
+	fs.StringVar(&defaultChanges.Diff, "diff", "", "unified diff `file` (- for stdin) used by -changed-only instead of git diff")
+	fs.StringVar(&defaultChanges.Base, "base", "HEAD", "`commit` compared with -ref (or the working tree) by -changed-only")
+	fs.BoolVar(&defaultTolerant, "tolerant", false, "keep analyzing packages with parse or type errors and mark their results as approximate")
+	fs.BoolVar(&defaultGenerated, "generated", false, "include generated files (// Code generated ... DO NOT EDIT.) in rewrites and style findings")
+	fs.StringVar(&defaultFactsDir, "facts", "", "save facts (purity, printf wrappers) about the analyzed packages in `dir` and reuse them when analyzing packages that import them")
+	failOnFlag := fs.String("fail-on", "none", "exit with status 1 when a diagnostics command reports a finding of this `severity` or higher (info, warning, error or none)")
+	abs := fs.Bool("abs", false, "print absolute file names instead of names relative to the module (or go.work) directory")
+	fs.Usage = usage
+	fs.Parse(os.Args[1:])
+	var err error
+	if failOn, err = parseSeverity(*failOnFlag); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if !*abs {
+		root, err := moduleRoot(".")
+		if err != nil {
+			log.Fatal(err)
+		}
+		outputRoot = root
+	}
+	args := fs.Args()
+	if len(args) < 1 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", args[0])
+		usage()
+		os.Exit(2)
+	}
+	if err := cmd.run(args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: learn_ast [-ref commit] [-changed-only [-base commit | -diff file]] [-tolerant] [-generated] [-abs] [-fail-on severity] [-facts dir] <command> [flags] [packages]")
+	fmt.Fprintln(os.Stderr, "commands:")
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", name, commands[name].summary)
+	}
+}
+
+// diagnosticsCommand は Diagnostic を返す解析をサブコマンドにする
+func diagnosticsCommand(name string, analyze func(*Program) []Diagnostic) func(args []string) error {
+	return func(args []string) error {
+		return runDiagnostics(flag.NewFlagSet(name, flag.ExitOnError), args, func(prog *Program) ([]Diagnostic, error) {
+			return analyze(prog), nil
+		})
+	}
+}
+
+// runDiagnostics は fs に共通のフラグを追加して args を解析し、読み込んだパッケージに analyze を適用する
+func runDiagnostics(fs *flag.FlagSet, args []string, analyze func(*Program) ([]Diagnostic, error)) error {
+	return runDiagnosticsWith(fs, args, loadOptions{}, analyze)
+}
+
+// runDiagnosticsWith は runDiagnostics と同じだが、opts に従ってパッケージを読み込む。-test を指定すると opts.Tests も true にする
+func runDiagnosticsWith(fs *flag.FlagSet, args []string, opts loadOptions, analyze func(*Program) ([]Diagnostic, error)) error {
+	asJSON := fs.Bool("json", false, "output diagnostics as JSON")
+	asCSV := fs.Bool("csv", false, "output diagnostics as CSV with a header row")
+	tests := fs.Bool("test", false, "also analyze test files")
+	fs.Parse(args)
+	opts.Tests = opts.Tests || *tests
+	prog, err := loadProgramWith(opts, ".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	diags, err := analyze(prog)
+	if err != nil {
+		return err
+	}
+	diags = filterGenerated(prog, filterFocus(prog, withLoadErrors(prog, diags)))
+	annotateGenerated(prog, diags)
+	if *asCSV {
+		err = writeDiagnosticsCSV(os.Stdout, diags)
+	} else {
+		err = writeDiagnostics(os.Stdout, diags, *asJSON)
+	}
+	if err != nil {
+		return err
+	}
+	return gateDiagnostics(diags)
+}
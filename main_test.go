@@ -243,7 +243,7 @@ func TestFindMainFunction(t *testing.T) {
 	fset := token.NewFileSet()
 	node, err := parser.ParseFile(fset, "", testdata_src1, parser.AllErrors)
 	if err != nil {
-		log.Fatalf("Failed to parse file: %v", err)
+		t.Fatalf("Failed to parse file: %v", err)
 	}
 
 	// ASTを巡回してmain関数を探す
@@ -262,7 +262,7 @@ func TestUsedFromMainFunction(t *testing.T) {
 	fset := token.NewFileSet()
 	node, err := parser.ParseFile(fset, "", testdata_src1, parser.AllErrors)
 	if err != nil {
-		log.Fatalf("Failed to parse file: %v", err)
+		t.Fatalf("Failed to parse file: %v", err)
 	}
 
 	// main関数を探す
@@ -302,7 +302,7 @@ func TestFindFunctionsAndTypes(t *testing.T) {
 			fset := token.NewFileSet()
 			node, err := parser.ParseFile(fset, "", src, parser.AllErrors)
 			if err != nil {
-				log.Fatalf("Failed to parse file: %v", err)
+				t.Fatalf("Failed to parse file: %v", err)
 			}
 
 			// ASTを巡回して関数と型を探す
@@ -336,7 +336,7 @@ func TestUsedFromMainFunctionSrc2(t *testing.T) {
 	fset := token.NewFileSet()
 	node, err := parser.ParseFile(fset, "", sourceMain, parser.AllErrors)
 	if err != nil {
-		log.Fatalf("Failed to parse file: %v", err)
+		t.Fatalf("Failed to parse file: %v", err)
 	}
 
 	// main関数を探す
@@ -421,7 +421,7 @@ const variable = "value"
 	fset := token.NewFileSet()
 	file, err := parser.ParseFile(fset, "", src, 0)
 	if err != nil {
-		log.Fatal(err)
+		t.Fatal(err)
 	}
 	ast.Inspect(file, func(n ast.Node) bool {
 		switch x := n.(type) {
@@ -468,7 +468,7 @@ func main() {
 	fset := token.NewFileSet()
 	node, err := parser.ParseFile(fset, "", src, 0)
 	if err != nil {
-		log.Fatal(err)
+		t.Fatal(err)
 	}
 
 	ast.Inspect(node, func(n ast.Node) bool {
@@ -513,7 +513,7 @@ func main() {
 	fset := token.NewFileSet()
 	node, err := parser.ParseFile(fset, "", src, 0)
 	if err != nil {
-		log.Fatal(err)
+		t.Fatal(err)
 	}
 
 	conf := types.Config{Importer: importer.Default()}
@@ -525,7 +525,7 @@ func main() {
 
 	_, err = conf.Check("", fset, []*ast.File{node}, info)
 	if err != nil {
-		log.Fatal(err)
+		t.Fatal(err)
 	}
 
 	ast.Inspect(node, func(n ast.Node) bool {
@@ -581,7 +581,7 @@ func (ms MyStructA) Method1() int {
 	fset := token.NewFileSet()
 	node, err := parser.ParseFile(fset, "", src, 0)
 	if err != nil {
-		log.Fatal(err)
+		t.Fatal(err)
 	}
 	conf := types.Config{Importer: importer.Default()}
 	astFiles := []*ast.File{node}
@@ -624,11 +624,11 @@ type MyStructB struct {
 	fset := token.NewFileSet()
 	file1, err := parser.ParseFile(fset, "", src1, 0)
 	if err != nil {
-		log.Fatal(err)
+		t.Fatal(err)
 	}
 	file2, err := parser.ParseFile(fset, "", src2, 0)
 	if err != nil {
-		log.Fatal(err)
+		t.Fatal(err)
 	}
 	conf := types.Config{Importer: importer.Default()}
 	astFiles := []*ast.File{file1, file2}
@@ -679,12 +679,12 @@ func main() {
 
 	file1, err := parser.ParseFile(fset, "src1.go", src1, 0)
 	if err != nil {
-		log.Fatal("Error parsing src1: ", err)
+		t.Fatal("Error parsing src1: ", err)
 	}
 
 	file2, err := parser.ParseFile(fset, "src2.go", src2, 0)
 	if err != nil {
-		log.Fatal("Error parsing src2: ", err)
+		t.Fatal("Error parsing src2: ", err)
 	}
 
 	conf := &packages.Config{
@@ -693,7 +693,7 @@ func main() {
 
 	pkgs, err := packages.Load(conf, file1.Name.Name, file2.Name.Name)
 	if err != nil {
-		log.Fatalf("Failed to load packages: %v", err)
+		t.Fatalf("Failed to load packages: %v", err)
 	}
 
 	targetPkgName := "main"
@@ -754,12 +754,12 @@ func main() {
 
 	file1, err := parser.ParseFile(fset, "src1.go", src1, 0)
 	if err != nil {
-		log.Fatal("Error parsing src1: ", err)
+		t.Fatal("Error parsing src1: ", err)
 	}
 
 	file2, err := parser.ParseFile(fset, "src2.go", src2, 0)
 	if err != nil {
-		log.Fatal("Error parsing src2: ", err)
+		t.Fatal("Error parsing src2: ", err)
 	}
 
 	targetPkgName := "main"
@@ -833,9 +833,10 @@ func main() {
 	fset := token.NewFileSet()
 	file, err := parser.ParseFile(fset, "", src, 0)
 	if err != nil {
-		log.Fatal(err)
+		t.Fatal(err)
 	}
 
+	var buf bytes.Buffer
 	ast.Inspect(file, func(n ast.Node) bool {
 		fd, ok := n.(*ast.FuncDecl)
 		if !ok {
@@ -861,9 +862,9 @@ func main() {
 						case *ast.Ident:
 							recvName = e.Name
 						}
-						log.Printf("Recv '%s', Function '%s' calls add\n", recvName, fd.Name)
+						fmt.Fprintf(&buf, "Recv '%s', Function '%s' calls add\n", recvName, fd.Name)
 					} else {
-						log.Printf("Function '%s' calls add\n", fd.Name)
+						fmt.Fprintf(&buf, "Function '%s' calls add\n", fd.Name)
 					}
 				}
 			}
@@ -873,6 +874,7 @@ func main() {
 
 		return true
 	})
+	checkGolden(t, "function_references", buf.Bytes())
 }
 
 func TestInspectFunctionReferences2(t *testing.T) {
@@ -913,7 +915,7 @@ func main() {
 	fset := token.NewFileSet()
 	file, err := parser.ParseFile(fset, "", src, 0)
 	if err != nil {
-		log.Fatal(err)
+		t.Fatal(err)
 	}
 
 	ast.Inspect(file, func(n ast.Node) bool {
@@ -983,7 +985,7 @@ func main() {
 	fset := token.NewFileSet()
 	file, err := parser.ParseFile(fset, "", src, 0)
 	if err != nil {
-		log.Fatal(err)
+		t.Fatal(err)
 	}
 
 	info := &types.Info{
@@ -996,7 +998,7 @@ func main() {
 
 	_, err = conf.Check("main", fset, []*ast.File{file}, info)
 	if err != nil {
-		log.Fatal(err)
+		t.Fatal(err)
 	}
 
 	ast.Inspect(file, func(n ast.Node) bool {
@@ -1023,25 +1025,14 @@ func main() {
 								var newFormat string
 								switch tv.Type.String() {
 								case "int":
-									newFormat = "\"%d\""
+									newFormat = "%d"
 								case "string":
-									newFormat = "\"%s\""
+									newFormat = "%s"
 								default:
 									return true
 								}
 
-								newCall := &ast.CallExpr{
-									Fun: &ast.SelectorExpr{
-										X:   ast.NewIdent("fmt"),
-										Sel: ast.NewIdent("Printf"),
-									},
-									Args: []ast.Expr{
-										ast.NewIdent(newFormat),
-										arg,
-									},
-								}
-
-								*call = *newCall
+								*call = *MustParseExpr("fmt.Printf(%q, %s)", newFormat, arg).(*ast.CallExpr)
 							}
 						}
 					}
@@ -1057,7 +1048,7 @@ func main() {
 	})
 
 	if err := format.Node(os.Stdout, fset, file); err != nil {
-		log.Fatal(err)
+		t.Fatal(err)
 	}
 }
 
@@ -1088,7 +1079,7 @@ func main() {
 	fset := token.NewFileSet()
 	file, err := parser.ParseFile(fset, "", src, 0)
 	if err != nil {
-		log.Fatal(err)
+		t.Fatal(err)
 	}
 
 	ast.Inspect(file, func(n ast.Node) bool {
@@ -1112,15 +1103,7 @@ func main() {
 					if ident.Name == "fmt" && fun.Sel.Name == "Println" {
 						arg, ok := call.Args[0].(*ast.Ident)
 						if ok && arg.Name == "calced" {
-							args := []ast.Expr{&ast.BasicLit{
-								Kind:  token.STRING,
-								Value: `"%d"`,
-							}, arg}
-							call.Fun = &ast.SelectorExpr{
-								X:   ast.NewIdent("fmt"),
-								Sel: ast.NewIdent("Printf"),
-							}
-							call.Args = args
+							*call = *MustParseExpr("fmt.Printf(%q, %s)", "%d", arg).(*ast.CallExpr)
 						}
 					}
 					log.Printf("1 Recv '%s', Function '%s'\n", ident.Name, fun.Sel.Name)
@@ -1145,7 +1128,7 @@ func main() {
 	})
 
 	if err := format.Node(os.Stdout, fset, file); err != nil {
-		log.Fatal(err)
+		t.Fatal(err)
 	}
 }
 
@@ -1235,7 +1218,7 @@ func main() {
 	fset := token.NewFileSet()
 	file, err := parser.ParseFile(fset, "", src, 0)
 	if err != nil {
-		log.Fatal(err)
+		t.Fatal(err)
 	}
 
 	ast.Inspect(file, func(n ast.Node) bool {
@@ -1296,7 +1279,7 @@ func main() {
 	fset := token.NewFileSet()
 	file, err := parser.ParseFile(fset, "", src, 0)
 	if err != nil {
-		log.Fatal(err)
+		t.Fatal(err)
 	}
 
 	ast.Inspect(file, func(n ast.Node) bool {
@@ -1351,7 +1334,7 @@ func main() {
 	fset := token.NewFileSet()
 	file, err := parser.ParseFile(fset, "", src, 0)
 	if err != nil {
-		log.Fatal(err)
+		t.Fatal(err)
 	}
 
 	conf := types.Config{Importer: importer.Default()}
@@ -1362,7 +1345,7 @@ func main() {
 
 	_, err = conf.Check("", fset, []*ast.File{file}, info)
 	if err != nil {
-		log.Fatal(err)
+		t.Fatal(err)
 	}
 
 	ast.Inspect(file, func(n ast.Node) bool {
@@ -1417,7 +1400,7 @@ func main() {
 	fset := token.NewFileSet()
 	file, err := parser.ParseFile(fset, "", src, 0)
 	if err != nil {
-		log.Fatal(err)
+		t.Fatal(err)
 	}
 
 	conf := types.Config{Importer: importer.Default()}
@@ -1429,7 +1412,7 @@ func main() {
 
 	_, err = conf.Check("", fset, []*ast.File{file}, info)
 	if err != nil {
-		log.Fatal(err)
+		t.Fatal(err)
 	}
 
 	ast.Inspect(file, func(n ast.Node) bool {
@@ -1484,7 +1467,7 @@ func main() {
 	fset := token.NewFileSet()
 	file, err := parser.ParseFile(fset, "", src, 0)
 	if err != nil {
-		log.Fatal(err)
+		t.Fatal(err)
 	}
 
 	conf := types.Config{Importer: importer.Default()}
@@ -1496,7 +1479,7 @@ func main() {
 
 	_, err = conf.Check("", fset, []*ast.File{file}, info)
 	if err != nil {
-		log.Fatal(err)
+		t.Fatal(err)
 	}
 
 	var mainFn *ast.FuncDecl
@@ -1588,16 +1571,22 @@ func main() {
 	ssaProg.Build()
 
 	// Inspect SSA functions
+	var buf bytes.Buffer
 	for _, pkg := range ssaProg.AllPackages() {
-		for _, mem := range pkg.Members {
-			if fn, ok := mem.(*ssa.Function); ok {
-				fmt.Printf("Function: %s\n", fn.Name())
+		var names []string
+		for name := range pkg.Members {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if fn, ok := pkg.Members[name].(*ssa.Function); ok {
+				fmt.Fprintf(&buf, "Function: %s\n", fn.Name())
 				for _, block := range fn.Blocks {
 					for _, instr := range block.Instrs {
 						if call, ok := instr.(*ssa.Call); ok {
 							callee := call.Call.StaticCallee()
 							if callee != nil && callee.Name() == "add" {
-								fmt.Printf("  %s calls add\n", fn.Name())
+								fmt.Fprintf(&buf, "  %s calls add\n", fn.Name())
 							}
 						}
 					}
@@ -1605,6 +1594,7 @@ func main() {
 			}
 		}
 	}
+	checkGolden(t, "function_references_ssa", buf.Bytes())
 }
 
 func TestReplaceFmtSSA(t *testing.T) {
@@ -1639,6 +1629,7 @@ func main() {
 	ssaProg := ssautil.CreateProgram(prog, ssa.SanityCheckFunctions)
 	ssaProg.Build()
 
+	var formats []string
 	for _, pkg := range ssaProg.AllPackages() {
 		for _, mem := range pkg.Members {
 			if fn, ok := mem.(*ssa.Function); ok {
@@ -1646,12 +1637,19 @@ func main() {
 					for i, instr := range block.Instrs {
 						if call, ok := instr.(*ssa.Call); ok {
 							if callee := call.Call.StaticCallee(); callee != nil && callee.Name() == "Println" && callee.Pkg.Pkg.Name() == "fmt" {
-								// TODO: type is []any
-								fmt.Println(call.Call.Args[0].Type())
-								if len(call.Call.Args) == 1 {
-									arg := call.Call.Args[0]
-									var newFormat constant.Value
-									newFormat = constant.MakeString("%d")
+								// 引数は []any に詰められているので、詰める前の値の型で書式を選ぶ
+								args, ok := variadicValues(&call.Call)
+								if ok && len(args) == 1 {
+									arg := args[0]
+									newFormat := constant.MakeString("%v")
+									if basic, ok := arg.Type().Underlying().(*types.Basic); ok {
+										if basic.Info()&types.IsInteger != 0 {
+											newFormat = constant.MakeString("%d")
+										} else if basic.Info()&types.IsString != 0 {
+											newFormat = constant.MakeString("%s")
+										}
+									}
+									formats = append(formats, constant.StringVal(newFormat))
 									printfFn := pkg.Func("fmt.Printf")
 									newCall := &ssa.Call{
 										Call: ssa.CallCommon{
@@ -1669,6 +1667,9 @@ func main() {
 			}
 		}
 	}
+	if strings.Join(formats, " ") != "%d %s" {
+		t.Errorf("got formats %v, want [%%d %%s]", formats)
+	}
 }
 
 // buildutil.FakeContext wrapper
@@ -1745,11 +1746,9 @@ type MyStructB struct {
 	prog := ssautil.CreateProgram(iprog, ssa.InstantiateGenerics)
 	prog.Build()
 
-	fmt.Println(prog.AllPackages())
-	fmt.Println(ssautil.AllFunctions(prog))
 	cg := cha.CallGraph(prog)
 	cg.DeleteSyntheticNodes()
-	fmt.Println(printGraph(cg, nil, "", "All calls"))
+	checkGolden(t, "ssa_callgraph", []byte(printGraph(cg, nil, "", "All calls")+"\n"))
 }
 
 // TODO: test reaching definition without ssa
@@ -1768,24 +1767,10 @@ func getParentNode(node ast.Node) ast.Node {
 	return parent
 }
 
-func replaceStmtInPlace(file *ast.File, old, new ast.Stmt) {
-	for _, stmt := range file.Decls {
-		if decl, ok := stmt.(*ast.FuncDecl); ok {
-			for j, bodyStmt := range decl.Body.List {
-				if bodyStmt == old {
-					decl.Body.List[j] = new
-					return
-				}
-			}
-		}
-	}
-}
-
-func formatFunctionDefinition(funcDecl *ast.FuncDecl) string {
+func formatFunctionDefinition(funcDecl *ast.FuncDecl) (string, error) {
 	var buf bytes.Buffer
-	err := format.Node(&buf, token.NewFileSet(), funcDecl)
-	if err != nil {
-		log.Fatalf("Failed to format function definition: %v", err)
+	if err := format.Node(&buf, token.NewFileSet(), funcDecl); err != nil {
+		return "", fmt.Errorf("format function definition: %w", err)
 	}
-	return buf.String()
+	return buf.String(), nil
 }
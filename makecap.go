@@ -0,0 +1,322 @@
+package main
+
+import (
+	"flag"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/packages"
+)
+
+func runMakeCap(args []string) error {
+	fs := flag.NewFlagSet("makecap", flag.ExitOnError)
+	fix := fs.Bool("fix", false, "add the suggested size hints and write the files")
+	return runDiagnostics(fs, args, func(prog *Program) ([]Diagnostic, error) {
+		hints := makeCapHints(prog)
+		var diags []Diagnostic
+		for _, h := range hints {
+			diags = append(diags, h.diagnostic(prog))
+		}
+		sortDiagnostics(diags)
+		if *fix {
+			files, err := fixMakeCaps(prog, hints)
+			if err != nil {
+				return nil, err
+			}
+			if err := writeFiles(files); err != nil {
+				return nil, err
+			}
+		}
+		return diags, nil
+	})
+}
+
+// checkMakeCaps は makeCapHints の指摘を返す
+func checkMakeCaps(prog *Program) []Diagnostic {
+	var diags []Diagnostic
+	for _, h := range makeCapHints(prog) {
+		diags = append(diags, h.diagnostic(prog))
+	}
+	return diags
+}
+
+// fixMakeCapAt は指摘 d の make に大きさの引数を加えたファイルの内容を返す (大きさが分からなければ空)
+func fixMakeCapAt(prog *Program, d Diagnostic) (map[string][]byte, error) {
+	for _, h := range makeCapHints(prog) {
+		if diag := h.diagnostic(prog); diag.Pos == d.Pos && diag.Message == d.Message {
+			return fixMakeCaps(prog, []*capHint{h})
+		}
+	}
+	return nil, nil
+}
+
+// capHint は大きさを指定していない map か chan の make
+type capHint struct {
+	pkg    *packages.Package
+	file   *ast.File
+	call   *ast.CallExpr
+	name   string   // make の結果を代入している変数
+	hint   ast.Expr // 直後のループから求めた大きさ。求まらなければ nil
+	inLoop bool
+}
+
+func (h *capHint) diagnostic(prog *Program) Diagnostic {
+	call := types.ExprString(h.call)
+	switch {
+	case h.hint != nil && isChanMake(h.pkg.TypesInfo, h.call):
+		return newDiagnostic(prog.Fset, h.call.Pos(), "makecap",
+			"%s has no buffer but the loop below sends up to %s values to %s; consider a buffer of that size", call, types.ExprString(h.hint), h.name)
+	case h.hint != nil:
+		return newDiagnostic(prog.Fset, h.call.Pos(), "makecap",
+			"%s has no size hint but the loop below adds up to %s entries to %s", call, types.ExprString(h.hint), h.name)
+	default:
+		return newDiagnostic(prog.Fset, h.call.Pos(), "makecap",
+			"%s allocates a new map on every iteration; consider reusing it with clear", call)
+	}
+}
+
+// makeCapHints は解析対象のパッケージから、大きさを指定していない make(map[K]V) と make(chan T) のうち、
+// 直後のループで要素を追加 (送信) していてループの回数から大きさが分かるものと、ループの中で毎回作っている map を探す
+func makeCapHints(prog *Program) []*capHint {
+	var hints []*capHint
+	for _, pkg := range prog.Packages {
+		info := pkg.TypesInfo
+		for _, file := range pkg.Syntax {
+			if !prog.inFocus(prog.Fset.Position(file.Pos())) {
+				continue
+			}
+			var stack []ast.Node
+			ast.Inspect(file, func(n ast.Node) bool {
+				if n == nil {
+					stack = stack[:len(stack)-1]
+					return true
+				}
+				stack = append(stack, n)
+				call, ok := n.(*ast.CallExpr)
+				if !ok || len(call.Args) != 1 || !isBuiltin(info, call.Fun, "make") {
+					return true
+				}
+				isMap := false
+				switch info.TypeOf(call.Args[0]).Underlying().(type) {
+				case *types.Map:
+					isMap = true
+				case *types.Chan:
+				default:
+					return true
+				}
+				h := &capHint{pkg: pkg, file: file, call: call, inLoop: inLoop(stack)}
+				if v, stmt, list := makeTarget(info, stack); v != nil {
+					h.name = v.Name()
+					h.hint = fillLoopSize(info, v, nextStmt(list, stmt), isMap)
+				}
+				if h.hint != nil || (h.inLoop && isMap) {
+					hints = append(hints, h)
+				}
+				return true
+			})
+		}
+	}
+	return hints
+}
+
+func isBuiltin(info *types.Info, fun ast.Expr, name string) bool {
+	id, ok := ast.Unparen(fun).(*ast.Ident)
+	if !ok {
+		return false
+	}
+	b, ok := info.Uses[id].(*types.Builtin)
+	return ok && b.Name() == name
+}
+
+func isChanMake(info *types.Info, call *ast.CallExpr) bool {
+	_, ok := info.TypeOf(call.Args[0]).Underlying().(*types.Chan)
+	return ok
+}
+
+// inLoop は stack の最後のノードが (同じ関数の中で) ループの中にあるかどうかを返す
+func inLoop(stack []ast.Node) bool {
+	for i := len(stack) - 2; i >= 0; i-- {
+		switch stack[i].(type) {
+		case *ast.FuncLit, *ast.FuncDecl:
+			return false
+		case *ast.ForStmt, *ast.RangeStmt:
+			return true
+		}
+	}
+	return false
+}
+
+// makeTarget は stack の最後の make の呼び出しを代入している変数と、その代入の文、文を含む文の並びを返す
+func makeTarget(info *types.Info, stack []ast.Node) (types.Object, ast.Stmt, []ast.Stmt) {
+	if len(stack) < 3 {
+		return nil, nil, nil
+	}
+	call := stack[len(stack)-1]
+	var obj types.Object
+	var stmt ast.Stmt
+	switch p := stack[len(stack)-2].(type) {
+	case *ast.AssignStmt:
+		for i, rhs := range p.Rhs {
+			if rhs == call && len(p.Lhs) == len(p.Rhs) {
+				if id, ok := p.Lhs[i].(*ast.Ident); ok {
+					obj = info.ObjectOf(id)
+				}
+			}
+		}
+		stmt = p
+	case *ast.ValueSpec:
+		for i, value := range p.Values {
+			if value == call && len(p.Names) == len(p.Values) {
+				obj = info.ObjectOf(p.Names[i])
+			}
+		}
+		// ValueSpec は GenDecl と DeclStmt の中にある
+		if len(stack) >= 5 {
+			stmt, _ = stack[len(stack)-4].(*ast.DeclStmt)
+		}
+	}
+	if obj == nil || stmt == nil {
+		return nil, nil, nil
+	}
+	for i := len(stack) - 2; i >= 0; i-- {
+		if stack[i] != stmt {
+			continue
+		}
+		switch p := stack[i-1].(type) {
+		case *ast.BlockStmt:
+			return obj, stmt, p.List
+		case *ast.CaseClause:
+			return obj, stmt, p.Body
+		case *ast.CommClause:
+			return obj, stmt, p.Body
+		}
+	}
+	return nil, nil, nil
+}
+
+// nextStmt は list の中で stmt の次の文を返す
+func nextStmt(list []ast.Stmt, stmt ast.Stmt) ast.Stmt {
+	for i, s := range list {
+		if s == stmt && i+1 < len(list) {
+			return list[i+1]
+		}
+	}
+	return nil
+}
+
+// fillLoopSize は stmt が v に要素を追加する (isMap でなければ v に送信する) ループなら、ループの回数の式を返す。
+// range の対象かループの上限が副作用のない式のものだけを扱う。返す式は位置を持たない新しいノード
+func fillLoopSize(info *types.Info, v types.Object, stmt ast.Stmt, isMap bool) ast.Expr {
+	if l, ok := stmt.(*ast.LabeledStmt); ok {
+		stmt = l.Stmt
+	}
+	var body *ast.BlockStmt
+	var size ast.Expr
+	switch loop := stmt.(type) {
+	case *ast.RangeStmt:
+		if !isPlainExpr(loop.X) {
+			return nil
+		}
+		switch t := info.TypeOf(loop.X).Underlying().(type) {
+		case *types.Slice, *types.Map, *types.Array:
+			size = MustParseExpr("len(%s)", types.ExprString(loop.X))
+		case *types.Pointer:
+			if _, ok := t.Elem().Underlying().(*types.Array); !ok {
+				return nil
+			}
+			size = MustParseExpr("len(%s)", types.ExprString(loop.X))
+		case *types.Basic:
+			if t.Info()&types.IsInteger == 0 {
+				return nil
+			}
+			size = MustParseExpr("%s", types.ExprString(loop.X))
+		default:
+			return nil
+		}
+		body = loop.Body
+	case *ast.ForStmt:
+		// for i := 0; i < n; i++
+		init, ok := loop.Init.(*ast.AssignStmt)
+		if !ok || init.Tok != token.DEFINE || len(init.Lhs) != 1 || !isZeroLit(init.Rhs[0]) {
+			return nil
+		}
+		i, _ := init.Lhs[0].(*ast.Ident)
+		cond, ok := loop.Cond.(*ast.BinaryExpr)
+		post, isInc := loop.Post.(*ast.IncDecStmt)
+		if i == nil || !ok || cond.Op != token.LSS || !isIdentOf(info, cond.X, info.ObjectOf(i)) ||
+			!isPlainExpr(cond.Y) || !isInc || post.Tok != token.INC || !isIdentOf(info, post.X, info.ObjectOf(i)) {
+			return nil
+		}
+		size, body = MustParseExpr("%s", types.ExprString(cond.Y)), loop.Body
+	default:
+		return nil
+	}
+	fills := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			for _, lhs := range n.Lhs {
+				if idx, ok := lhs.(*ast.IndexExpr); ok && isMap && isIdentOf(info, idx.X, v) {
+					fills = true
+				}
+			}
+		case *ast.SendStmt:
+			if !isMap && isIdentOf(info, n.Chan, v) {
+				fills = true
+			}
+		}
+		return !fills
+	})
+	if !fills {
+		return nil
+	}
+	return size
+}
+
+// isPlainExpr は e が識別子、セレクター、定数、len の呼び出しのように評価しても副作用のない式かどうかを返す
+func isPlainExpr(e ast.Expr) bool {
+	switch e := ast.Unparen(e).(type) {
+	case *ast.Ident, *ast.BasicLit:
+		return true
+	case *ast.SelectorExpr:
+		return isPlainExpr(e.X)
+	case *ast.CallExpr:
+		id, ok := e.Fun.(*ast.Ident)
+		return ok && id.Name == "len" && len(e.Args) == 1 && isPlainExpr(e.Args[0])
+	}
+	return false
+}
+
+func isZeroLit(e ast.Expr) bool {
+	lit, ok := e.(*ast.BasicLit)
+	return ok && lit.Kind == token.INT && lit.Value == "0"
+}
+
+func isIdentOf(info *types.Info, e ast.Expr, obj types.Object) bool {
+	id, ok := ast.Unparen(e).(*ast.Ident)
+	return ok && obj != nil && info.ObjectOf(id) == obj
+}
+
+// fixMakeCaps は大きさが分かった make に大きさの引数を加えたファイルの内容を、ファイル名をキーにして返す。
+// 生成されたファイルは書き換えない
+func fixMakeCaps(prog *Program, hints []*capHint) (map[string][]byte, error) {
+	changed := make(map[*ast.File]bool)
+	for _, h := range hints {
+		if h.hint != nil && !prog.skipGenerated(prog.Fset.Position(h.file.Pos()).Filename) {
+			// 位置のない式のままだと行末のコメントが引数の中に出力される
+			fillPositions(h.hint, h.call.Rparen)
+			h.call.Args = append(h.call.Args, h.hint)
+			changed[h.file] = true
+		}
+	}
+	files := make(map[string][]byte)
+	for file := range changed {
+		name, src, err := formatFile(prog.Fset, file)
+		if err != nil {
+			return nil, err
+		}
+		files[name] = src
+	}
+	return files, nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMakeCapHints(t *testing.T) {
+	src := `package main
+
+func index(names []string) map[string]int {
+	m := make(map[string]int)
+	for i, name := range names {
+		m[name] = i
+	}
+	return m
+}
+
+func squares(n int) map[int]int {
+	var m = make(map[int]int)
+	for i := 0; i < n; i++ {
+		m[i] = i * i
+	}
+	return m
+}
+
+func results(jobs []func() int) chan int {
+	ch := make(chan int)
+	for _, job := range jobs {
+		go func() { ch <- job() }()
+	}
+	return ch
+}
+
+func counts(groups [][]string) int {
+	total := 0
+	for _, g := range groups {
+		seen := make(map[string]bool)
+		for _, s := range g {
+			if !seen[s] {
+				total++
+			}
+		}
+	}
+	return total
+}
+
+func lazy(names []string) map[string]bool {
+	m := make(map[string]bool)
+	println(len(names))
+	for _, name := range names {
+		m[name] = true
+	}
+	return m
+}
+
+func main() {
+	_ = index(nil)
+	_ = squares(3)
+	_ = results(nil)
+	_ = counts(nil)
+	_ = lazy(nil)
+}
+`
+	prog := loadTestProgram(t, map[string]string{"main.go": src})
+	hints := makeCapHints(prog)
+	var diags []Diagnostic
+	for _, h := range hints {
+		diags = append(diags, h.diagnostic(prog))
+	}
+	sortDiagnostics(diags)
+	assertLines(t, diagnosticMessages(diags), []string{
+		"main.go:4: make(map[string]int) has no size hint but the loop below adds up to len(names) entries to m",
+		"main.go:12: make(map[int]int) has no size hint but the loop below adds up to n entries to m",
+		"main.go:20: make(chan int) has no buffer but the loop below sends up to len(jobs) values to ch; consider a buffer of that size",
+		"main.go:30: make(map[string]bool) allocates a new map on every iteration; consider reusing it with clear",
+	})
+
+	files, err := fixMakeCaps(prog, hints)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, got := range files {
+		for _, want := range []string{
+			"m := make(map[string]int, len(names))",
+			"var m = make(map[int]int, n)",
+			"ch := make(chan int, len(jobs))",
+			"seen := make(map[string]bool)\n",
+			"m := make(map[string]bool)\n",
+		} {
+			if !strings.Contains(string(got), want) {
+				t.Errorf("fixed source does not contain %q:\n%s", want, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// PackageMetrics はパッケージの規模と複雑さ
+type PackageMetrics struct {
+	Package       string  `json:"package"`
+	Files         int     `json:"files"`
+	Lines         int     `json:"lines"`
+	Funcs         int     `json:"funcs"` // 関数とメソッドの数
+	Types         int     `json:"types"`
+	Exported      int     `json:"exported"` // エクスポートされた関数、メソッド、型の数
+	MaxComplexity int     `json:"maxComplexity"`
+	AvgComplexity float64 `json:"avgComplexity"`
+	ProtoFuncs    int     `json:"protoFuncs,omitempty"` // protoc で生成されたメッセージの関数の数 (Funcs、Exported、複雑度には含めない)
+	MayPanic      int     `json:"mayPanic,omitempty"`   // panic しうる関数の数 (mayPanic と同じ判定)
+}
+
+func runMetrics(args []string) error {
+	fs := flag.NewFlagSet("metrics", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output as JSON")
+	asCSV := fs.Bool("csv", false, "output as CSV with a header row")
+	maxComplexity := fs.Int("max-complexity", 0, "fail and report the functions whose cyclomatic complexity exceeds `n` on stderr (0 disables the check)")
+	fs.Parse(args)
+	prog, err := loadProgram(".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	metrics := packageMetrics(prog)
+	switch {
+	case *asJSON:
+		err = writeJSON(os.Stdout, metrics)
+	case *asCSV:
+		err = writeMetricsCSV(os.Stdout, metrics)
+	default:
+		err = writeMetrics(os.Stdout, metrics)
+	}
+	if err != nil || *maxComplexity <= 0 {
+		return err
+	}
+	if diags := complexityDiagnostics(prog, *maxComplexity); len(diags) > 0 {
+		if err := writeDiagnostics(os.Stderr, diags, false); err != nil {
+			return err
+		}
+		return fmt.Errorf("-max-complexity=%d exceeded by %s", *maxComplexity, plural(len(diags), "function"))
+	}
+	return nil
+}
+
+// packageMetrics は解析対象のパッケージごとにファイル数、行数、宣言の数と関数の循環的複雑度を集計する。
+// -changed-only では変更された関数とその呼び出し元だけを関数として数える。
+// protoc で生成されたメッセージのゲッターや Marshal は複雑さの指標をゆがめるので ProtoFuncs に分けて数える
+func packageMetrics(prog *Program) []PackageMetrics {
+	var result []PackageMetrics
+	protos := prog.protoSources()
+	panics := mayPanicCounts(prog, protos)
+	for _, pkg := range prog.Packages {
+		m := PackageMetrics{Package: pkg.PkgPath, Files: len(pkg.Syntax), MayPanic: panics[pkg.PkgPath]}
+		total := 0
+		for _, file := range pkg.Syntax {
+			m.Lines += prog.Fset.File(file.Pos()).LineCount()
+			_, isProto := protos[prog.Fset.Position(file.Pos()).Filename]
+			for _, decl := range file.Decls {
+				switch decl := decl.(type) {
+				case *ast.FuncDecl:
+					if !prog.inFocus(prog.Fset.Position(decl.Pos())) {
+						continue
+					}
+					if isProto {
+						m.ProtoFuncs++
+						continue
+					}
+					m.Funcs++
+					if decl.Name.IsExported() {
+						m.Exported++
+					}
+					c := cyclomaticComplexity(decl)
+					total += c
+					m.MaxComplexity = max(m.MaxComplexity, c)
+				case *ast.GenDecl:
+					if decl.Tok != token.TYPE {
+						continue
+					}
+					for _, spec := range decl.Specs {
+						m.Types++
+						if spec.(*ast.TypeSpec).Name.IsExported() {
+							m.Exported++
+						}
+					}
+				}
+			}
+		}
+		if m.Funcs > 0 {
+			m.AvgComplexity = float64(total) / float64(m.Funcs)
+		}
+		result = append(result, m)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Package < result[j].Package })
+	return result
+}
+
+// mayPanicCounts は panic しうる関数の数をパッケージのパスごとに返す。
+// Funcs と同じく、宣言された関数とメソッドだけを数え、クロージャ、インスタンス、protoc で生成された関数は数えない
+func mayPanicCounts(prog *Program, protos map[string]string) map[string]int {
+	counts := make(map[string]int)
+	for fn := range mayPanic(prog) {
+		if fn.Parent() != nil || fn.Origin() != nil || fn.Synthetic != "" {
+			continue
+		}
+		pos := prog.Fset.Position(fn.Pos())
+		if _, isProto := protos[pos.Filename]; isProto || !prog.inFocus(pos) {
+			continue
+		}
+		counts[fn.Pkg.Pkg.Path()]++
+	}
+	return counts
+}
+
+// cyclomaticComplexity は 1 に分岐 (if, for, case, select の case, && と ||) の数を足したものを返す。
+// 関数の中の関数リテラルの分岐も含める
+func cyclomaticComplexity(fn *ast.FuncDecl) int {
+	c := 1
+	if fn.Body == nil {
+		return c
+	}
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.IfStmt, *ast.ForStmt, *ast.RangeStmt:
+			c++
+		case *ast.CaseClause:
+			if n.List != nil {
+				c++
+			}
+		case *ast.CommClause:
+			if n.Comm != nil {
+				c++
+			}
+		case *ast.BinaryExpr:
+			if n.Op == token.LAND || n.Op == token.LOR {
+				c++
+			}
+		}
+		return true
+	})
+	return c
+}
+
+func writeMetrics(w io.Writer, metrics []PackageMetrics) error {
+	fmt.Fprintf(w, "%-40s %5s %6s %5s %5s %8s %7s %7s %5s %5s\n", "PACKAGE", "FILES", "LINES", "FUNCS", "TYPES", "EXPORTED", "MAXCPLX", "AVGCPLX", "PROTO", "PANIC")
+	for _, m := range metrics {
+		fmt.Fprintf(w, "%-40s %5d %6d %5d %5d %8d %7d %7.2f %5d %5d\n",
+			m.Package, m.Files, m.Lines, m.Funcs, m.Types, m.Exported, m.MaxComplexity, m.AvgComplexity, m.ProtoFuncs, m.MayPanic)
+	}
+	return nil
+}
+
+// writeMetricsCSV はメトリクスをヘッダー行付きの CSV で出力する。列の順序は PackageMetrics のフィールドの順
+func writeMetricsCSV(w io.Writer, metrics []PackageMetrics) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"package", "files", "lines", "funcs", "types", "exported", "max_complexity", "avg_complexity", "proto_funcs", "may_panic"})
+	for _, m := range metrics {
+		cw.Write([]string{
+			m.Package,
+			strconv.Itoa(m.Files),
+			strconv.Itoa(m.Lines),
+			strconv.Itoa(m.Funcs),
+			strconv.Itoa(m.Types),
+			strconv.Itoa(m.Exported),
+			strconv.Itoa(m.MaxComplexity),
+			strconv.FormatFloat(m.AvgComplexity, 'f', 2, 64),
+			strconv.Itoa(m.ProtoFuncs),
+			strconv.Itoa(m.MayPanic),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPackageMetrics(t *testing.T) {
+	prog := loadTestProgram(t, map[string]string{
+		"main.go": `package main
+
+type Mode int
+
+type config struct{ debug bool }
+
+func classify(n int, debug bool) string {
+	switch {
+	case n < 0 && debug:
+		return "negative"
+	case n == 0:
+		return "zero"
+	default:
+		for i := 0; i < n; i++ {
+			if i > 10 || debug {
+				return "large"
+			}
+		}
+	}
+	return "small"
+}
+
+func Run() { println(classify(1, false)) }
+
+func main() { Run() }
+`,
+		"util/util.go": "package util\n\nfunc Helper() {}\n\nfunc Div(a, b int) int { return a / b }\n",
+	})
+	got := packageMetrics(prog)
+	want := []PackageMetrics{
+		{Package: "example.com/m", Files: 1, Lines: 25, Funcs: 3, Types: 2, Exported: 2, MaxComplexity: 7, AvgComplexity: 3},
+		{Package: "example.com/m/util", Files: 1, Lines: 5, Funcs: 2, Exported: 2, MaxComplexity: 1, AvgComplexity: 1, MayPanic: 1},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d packages, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got %+v\nwant %+v", got[i], want[i])
+		}
+	}
+}
+
+func TestWriteMetricsCSV(t *testing.T) {
+	var buf bytes.Buffer
+	err := writeMetricsCSV(&buf, []PackageMetrics{
+		{Package: "example.com/m", Files: 2, Lines: 40, Funcs: 3, Types: 1, Exported: 2, MaxComplexity: 5, AvgComplexity: 7.0 / 3, MayPanic: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertLines(t, strings.Split(strings.TrimSpace(buf.String()), "\n"), []string{
+		"package,files,lines,funcs,types,exported,max_complexity,avg_complexity,proto_funcs,may_panic",
+		"example.com/m,2,40,3,1,2,5,2.33,0,1",
+	})
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/token"
+	"io"
+	"os"
+	"sort"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// MicroPerf は 1 つの関数の細かい性能上の問題の数と、main からの到達しやすさ
+type MicroPerf struct {
+	Func         string         `json:"func"`
+	Pos          token.Position `json:"pos"`
+	Defers       int            `json:"defers"`
+	LoopDefers   int            `json:"loop_defers"`   // ループの中の defer (関数の終わりまで積み上がる)
+	LoopClosures int            `json:"loop_closures"` // 反復ごとに確保される、変数を捕捉する無名関数
+	Boxing       int            `json:"boxing"`        // ヒープを確保しうるインターフェースへの変換
+	LoopBoxing   int            `json:"loop_boxing"`
+	Depth        int            `json:"depth"`   // main からの最短の呼び出しの深さ。到達できなければ -1
+	Callers      int            `json:"callers"` // この関数に (間接的に) 到達する、main から到達できる関数の数
+}
+
+func runMicroPerf(args []string) error {
+	fs := flag.NewFlagSet("microperf", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output as JSON")
+	top := fs.Int("top", 0, "only list the `n` highest ranked functions (0 for all)")
+	unreachable := fs.Bool("unreachable", false, "also list functions unreachable from main")
+	fs.Parse(args)
+	prog, err := loadProgram(".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	var result []MicroPerf
+	for _, m := range microPerf(prog) {
+		if m.Depth >= 0 || *unreachable {
+			result = append(result, m)
+		}
+	}
+	if *top > 0 && len(result) > *top {
+		result = result[:*top]
+	}
+	relativizePositions(result)
+	if *asJSON {
+		return writeJSON(os.Stdout, result)
+	}
+	return writeMicroPerf(os.Stdout, result)
+}
+
+// microPerf は解析対象の関数の defer、ループの中で確保する無名関数、インターフェースへの変換を数え、
+// main (main パッケージがなければすべてのエントリポイント) から到達できる関数を、多くの関数から呼ばれるもの、
+// main に近いものの順に並べて返す。何もない関数は含めない
+func microPerf(prog *Program) []MicroPerf {
+	byFunc := make(map[*ssa.Function]*MicroPerf)
+	get := func(fn *ssa.Function) *MicroPerf {
+		m, ok := byFunc[fn]
+		if !ok {
+			m = &MicroPerf{Func: fn.RelString(nil), Pos: prog.Fset.Position(fn.Pos())}
+			byFunc[fn] = m
+		}
+		return m
+	}
+	byName := make(map[string]*ssa.Function)
+	for _, fn := range prog.targetFunctions() {
+		byName[fn.RelString(nil)] = fn
+		var loops map[*ssa.BasicBlock]bool
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				switch instr := instr.(type) {
+				case *ssa.Defer:
+					if loops == nil {
+						loops = loopBlocks(fn)
+					}
+					m := get(fn)
+					m.Defers++
+					if loops[b] {
+						m.LoopDefers++
+					}
+				case *ssa.MakeClosure:
+					if loops == nil {
+						loops = loopBlocks(fn)
+					}
+					if len(instr.Bindings) > 0 && loops[b] {
+						get(fn).LoopClosures++
+					}
+				}
+			}
+		}
+	}
+	for _, s := range boxingSites(prog) {
+		if fn := byName[s.Func]; fn != nil {
+			m := get(fn)
+			m.Boxing++
+			if s.InLoop {
+				m.LoopBoxing++
+			}
+		}
+	}
+
+	depth, callers := reachDepths(prog, microPerfRoots(prog))
+	var result []MicroPerf
+	for fn, m := range byFunc {
+		m.Depth = -1
+		if d, ok := depth[fn]; ok {
+			m.Depth = d
+			m.Callers = callers(fn)
+		}
+		result = append(result, *m)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if (a.Depth >= 0) != (b.Depth >= 0) {
+			return a.Depth >= 0
+		}
+		if a.Callers != b.Callers {
+			return a.Callers > b.Callers
+		}
+		if a.Depth != b.Depth {
+			return a.Depth < b.Depth
+		}
+		if sa, sb := a.LoopDefers+a.LoopClosures+a.LoopBoxing, b.LoopDefers+b.LoopClosures+b.LoopBoxing; sa != sb {
+			return sa > sb
+		}
+		return a.Func < b.Func
+	})
+	return result
+}
+
+// microPerfRoots は main パッケージの main と init を返す。main パッケージがなければすべてのエントリポイントを返す
+func microPerfRoots(prog *Program) []*ssa.Function {
+	var roots []*ssa.Function
+	for _, pkg := range prog.Packages {
+		if spkg := prog.SSA().Package(pkg.Types); spkg != nil && pkg.Name == "main" && spkg.Func("main") != nil {
+			roots = append(roots, spkg.Func("main"), spkg.Func("init"))
+		}
+	}
+	if len(roots) == 0 {
+		roots = entryFunctions(prog, allEntryKinds)
+	}
+	return roots
+}
+
+// reachDepths は roots から静的な呼び出しとインターフェースのメソッドの呼び出しをたどった最短の深さと、
+// 関数ごとにその関数へ到達する (到達できる) 関数の数を求める関数を返す。
+// 関数値の呼び出しは CHA では同じシグネチャの全関数につながるのでたどらない
+func reachDepths(prog *Program, roots []*ssa.Function) (map[*ssa.Function]int, func(*ssa.Function) int) {
+	cg := prog.CallGraph()
+	followed := func(site ssa.CallInstruction) bool {
+		return site != nil && (site.Common().IsInvoke() || site.Common().StaticCallee() != nil)
+	}
+	depth := make(map[*ssa.Function]int)
+	var queue []*ssa.Function
+	for _, fn := range roots {
+		if _, ok := depth[fn]; fn != nil && !ok {
+			depth[fn] = 0
+			queue = append(queue, fn)
+		}
+	}
+	for len(queue) > 0 {
+		fn := queue[0]
+		queue = queue[1:]
+		node := cg.Nodes[fn]
+		if node == nil {
+			continue
+		}
+		for _, e := range node.Out {
+			if _, ok := depth[e.Callee.Func]; !ok && followed(e.Site) {
+				depth[e.Callee.Func] = depth[fn] + 1
+				queue = append(queue, e.Callee.Func)
+			}
+		}
+	}
+	callers := func(fn *ssa.Function) int {
+		seen := map[*ssa.Function]bool{fn: true}
+		stack := []*ssa.Function{fn}
+		for len(stack) > 0 {
+			f := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			node := cg.Nodes[f]
+			if node == nil {
+				continue
+			}
+			for _, e := range node.In {
+				_, reachable := depth[e.Caller.Func]
+				if reachable && followed(e.Site) && !seen[e.Caller.Func] {
+					seen[e.Caller.Func] = true
+					stack = append(stack, e.Caller.Func)
+				}
+			}
+		}
+		return len(seen) - 1
+	}
+	return depth, callers
+}
+
+func writeMicroPerf(w io.Writer, result []MicroPerf) error {
+	// BOXING はループの中のもの/全体
+	fmt.Fprintf(w, "%7s %5s %6s %10s %12s %7s %s\n", "CALLERS", "DEPTH", "DEFERS", "LOOP-DEFER", "LOOP-CLOSURE", "BOXING", "FUNC")
+	for _, m := range result {
+		depth := "-"
+		if m.Depth >= 0 {
+			depth = fmt.Sprint(m.Depth)
+		}
+		fmt.Fprintf(w, "%7d %5s %6d %10d %12d %3d/%-3d %s\n", m.Callers, depth, m.Defers, m.LoopDefers, m.LoopClosures, m.LoopBoxing, m.Boxing, m.Func)
+	}
+	return nil
+}
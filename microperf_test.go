@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMicroPerf(t *testing.T) {
+	src := `package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func closeAll(names []string) {
+	for _, name := range names {
+		f, err := os.Open(name)
+		if err != nil {
+			continue
+		}
+		defer f.Close()
+	}
+}
+
+func logAll(values []int) {
+	for _, v := range values {
+		fmt.Println(v)
+	}
+}
+
+func handlers(names []string) []func() string {
+	var fs []func() string
+	for _, name := range names {
+		fs = append(fs, func() string { return name })
+	}
+	return fs
+}
+
+func a() { logAll(nil) }
+func b() { logAll(nil); handlers(nil) }
+
+func unused(names []string) {
+	for _, name := range names {
+		defer println(name)
+	}
+}
+
+func main() {
+	defer println("done")
+	a()
+	b()
+	closeAll(nil)
+}
+`
+	prog := loadTestProgram(t, map[string]string{"main.go": src})
+	var got []string
+	for _, m := range microPerf(prog) {
+		got = append(got, fmt.Sprintf("%s callers=%d depth=%d defers=%d/%d closures=%d boxing=%d/%d",
+			m.Func, m.Callers, m.Depth, m.LoopDefers, m.Defers, m.LoopClosures, m.LoopBoxing, m.Boxing))
+	}
+	assertLines(t, got, []string{
+		"example.com/m.logAll callers=3 depth=2 defers=0/0 closures=0 boxing=1/1",
+		"example.com/m.handlers callers=2 depth=2 defers=0/0 closures=1 boxing=0/0",
+		"example.com/m.closeAll callers=1 depth=1 defers=1/1 closures=0 boxing=0/0",
+		"example.com/m.main callers=0 depth=0 defers=0/1 closures=0 boxing=0/0",
+		"example.com/m.unused callers=0 depth=-1 defers=1/1 closures=0 boxing=0/0",
+	})
+}
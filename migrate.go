@@ -0,0 +1,309 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"go/version"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// migrationRule は Go のバージョンを上げたときに書き換えられる古い書き方の規則。
+// MinGo より古いバージョンのファイルには適用しない
+type migrationRule struct {
+	Name  string
+	MinGo string
+	Doc   string
+	apply func(m *migration, c *astutil.Cursor)
+}
+
+// migrationRules は組み込みの規則
+var migrationRules = []migrationRule{
+	{"ioutil", "go1.16", "replace io/ioutil functions with their io and os equivalents", migrateIoutil},
+	{"sortslice", "go1.21", "replace sort.Slice with an ascending less function, sort.Ints and sort.Strings with slices.Sort", migrateSortSlice},
+	{"randseed", "go1.20", "remove math/rand.Seed calls; the global source is seeded randomly", migrateRandSeed},
+	{"any", "go1.18", "replace interface{} with any", migrateAny},
+}
+
+// migration は 1 つのファイルに規則を適用している状態
+type migration struct {
+	prog    *Program
+	pkg     *packages.Package
+	fix     bool
+	rule    string
+	diags   []Diagnostic
+	changed bool
+	deleted []int // 削除した 1 行の文の行
+}
+
+func (m *migration) report(pos token.Pos, format string, args ...interface{}) {
+	d := newDiagnostic(m.prog.Fset, pos, "migrate", format, args...)
+	d.Message = m.rule + ": " + d.Message
+	m.diags = append(m.diags, d)
+}
+
+// replace は fix なら c のノードを n に置き換える
+func (m *migration) replace(c *astutil.Cursor, n ast.Node) {
+	if m.fix {
+		c.Replace(n)
+		m.changed = true
+	}
+}
+
+// resolves は pos で name が want (nil なら未定義か、path のパッケージの import) を指すかどうかを返す
+func (m *migration) resolves(pos token.Pos, name string, want types.Object, path string) bool {
+	scope := m.pkg.Types.Scope().Innermost(pos)
+	if scope == nil {
+		return false
+	}
+	_, obj := scope.LookupParent(name, pos)
+	if want != nil {
+		return obj == want
+	}
+	if pkgName, ok := obj.(*types.PkgName); ok {
+		return pkgName.Imported().Path() == path
+	}
+	return obj == nil
+}
+
+func runMigrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	usage := "comma-separated `rules` to apply (default all):"
+	for _, r := range migrationRules {
+		usage += fmt.Sprintf("\n  %s (%s and later): %s", r.Name, r.MinGo, r.Doc)
+	}
+	names := fs.String("rules", "", usage)
+	fix := fs.Bool("fix", false, "rewrite the code and write the files")
+	return runDiagnosticsWith(fs, args, loadOptions{Tests: true}, func(prog *Program) ([]Diagnostic, error) {
+		rules, err := selectMigrationRules(splitList(*names))
+		if err != nil {
+			return nil, err
+		}
+		diags, files, err := migrate(prog, rules, *fix)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeFiles(files); err != nil {
+			return nil, err
+		}
+		return diags, nil
+	})
+}
+
+func selectMigrationRules(names []string) ([]migrationRule, error) {
+	if len(names) == 0 {
+		return migrationRules, nil
+	}
+	var rules []migrationRule
+	for _, name := range names {
+		found := false
+		for _, r := range migrationRules {
+			if r.Name == name {
+				rules = append(rules, r)
+				found = true
+			}
+		}
+		if !found {
+			return nil, notFound("migration rule %s not found", name)
+		}
+	}
+	return rules, nil
+}
+
+// migrate は解析対象のファイルに rules を適用し、書き換えられる箇所の指摘と、fix なら書き換えたファイルの内容を返す
+func migrate(prog *Program, rules []migrationRule, fix bool) ([]Diagnostic, map[string][]byte, error) {
+	var diags []Diagnostic
+	files, err := rewriteFiles(prog, func(pkg *packages.Package, file *ast.File) bool {
+		m := &migration{prog: prog, pkg: pkg, fix: fix}
+		goVersion := fileGoVersion(pkg, file)
+		for _, r := range rules {
+			if goVersion != "" && version.Compare(goVersion, r.MinGo) < 0 {
+				continue
+			}
+			m.rule = r.Name
+			astutil.Apply(file, nil, func(c *astutil.Cursor) bool {
+				r.apply(m, c)
+				return true
+			})
+		}
+		diags = append(diags, m.diags...)
+		// 削除した文の行を次の行とつなげ、整形したときに空行が残らないようにする
+		sort.Sort(sort.Reverse(sort.IntSlice(m.deleted)))
+		tf := prog.Fset.File(file.Pos())
+		for _, line := range m.deleted {
+			if line < tf.LineCount() {
+				tf.MergeLine(line)
+			}
+		}
+		return m.changed
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	sortDiagnostics(diags)
+	return diags, files, nil
+}
+
+// fileGoVersion はファイルの Go のバージョン (//go:build の go1.N か go.mod の go) を go1.N の形で返す。わからなければ空
+func fileGoVersion(pkg *packages.Package, file *ast.File) string {
+	goVersion := pkg.TypesInfo.FileVersions[file]
+	if goVersion == "" && pkg.Module != nil && pkg.Module.GoVersion != "" {
+		goVersion = "go" + pkg.Module.GoVersion
+	}
+	return goVersion
+}
+
+// ioutilReplacements は io/ioutil の宣言の代わりになるもの。os.ReadDir は結果の型が違うので書き換えない
+var ioutilReplacements = map[string]string{
+	"Discard":   "io.Discard",
+	"NopCloser": "io.NopCloser",
+	"ReadAll":   "io.ReadAll",
+	"ReadDir":   "os.ReadDir",
+	"ReadFile":  "os.ReadFile",
+	"TempDir":   "os.MkdirTemp",
+	"TempFile":  "os.CreateTemp",
+	"WriteFile": "os.WriteFile",
+}
+
+func migrateIoutil(m *migration, c *astutil.Cursor) {
+	sel, ok := c.Node().(*ast.SelectorExpr)
+	if !ok {
+		return
+	}
+	obj := m.pkg.TypesInfo.Uses[sel.Sel]
+	if obj == nil || obj.Pkg() == nil || obj.Pkg().Path() != "io/ioutil" {
+		return
+	}
+	replacement, ok := ioutilReplacements[obj.Name()]
+	if !ok {
+		return
+	}
+	m.report(sel.Pos(), "ioutil.%s is deprecated; use %s", obj.Name(), replacement)
+	path, name, _ := strings.Cut(replacement, ".")
+	if obj.Name() != "ReadDir" && m.resolves(sel.Pos(), path, nil, path) {
+		m.replace(c, &ast.SelectorExpr{X: &ast.Ident{Name: path, NamePos: sel.Pos()}, Sel: &ast.Ident{Name: name, NamePos: sel.Sel.Pos()}})
+	}
+}
+
+// migrateRandSeed は文としての rand.Seed の呼び出しを削除する。定数で初期化しているものは再現できる乱数列を
+// 意図しているかもしれないので、rand.New(rand.NewSource(seed)) を勧めるだけにする
+func migrateRandSeed(m *migration, c *astutil.Cursor) {
+	stmt, ok := c.Node().(*ast.ExprStmt)
+	if !ok {
+		return
+	}
+	call, ok := stmt.X.(*ast.CallExpr)
+	if !ok {
+		return
+	}
+	if path, name := pkgFuncName(m.pkg.TypesInfo, call); path != "math/rand" || name != "Seed" {
+		return
+	}
+	if m.pkg.TypesInfo.Types[call.Args[0]].Value != nil {
+		m.report(call.Pos(), "rand.Seed is deprecated; use rand.New(rand.NewSource(%s)) for a reproducible sequence", types.ExprString(call.Args[0]))
+		return
+	}
+	m.report(call.Pos(), "rand.Seed is deprecated and unnecessary; the global source is seeded randomly")
+	if m.fix && c.Index() >= 0 {
+		c.Delete()
+		m.changed = true
+		if start, end := m.prog.Fset.Position(stmt.Pos()), m.prog.Fset.Position(stmt.End()); start.Line == end.Line {
+			m.deleted = append(m.deleted, start.Line)
+		}
+	}
+}
+
+func migrateAny(m *migration, c *astutil.Cursor) {
+	it, ok := c.Node().(*ast.InterfaceType)
+	if !ok || len(it.Methods.List) > 0 {
+		return
+	}
+	if !m.resolves(it.Pos(), "any", types.Universe.Lookup("any"), "") {
+		return
+	}
+	m.report(it.Pos(), "use any instead of interface{}")
+	m.replace(c, &ast.Ident{Name: "any", NamePos: it.Pos()})
+}
+
+// migrateSortSlice は sort.Ints、sort.Strings と、less 関数が s[i] < s[j] の sort.Slice を slices.Sort にする
+func migrateSortSlice(m *migration, c *astutil.Cursor) {
+	call, ok := c.Node().(*ast.CallExpr)
+	if !ok {
+		return
+	}
+	info := m.pkg.TypesInfo
+	path, name := pkgFuncName(info, call)
+	if path != "sort" {
+		return
+	}
+	switch name {
+	case "Ints", "Strings":
+	case "Slice":
+		if !ascendingLess(info, call.Args[0], call.Args[1]) {
+			return
+		}
+	default:
+		return
+	}
+	m.report(call.Pos(), "sort.%s can be replaced with slices.Sort", name)
+	if m.resolves(call.Pos(), "slices", nil, "slices") {
+		m.replace(c, &ast.CallExpr{
+			Fun:    &ast.SelectorExpr{X: &ast.Ident{Name: "slices", NamePos: call.Pos()}, Sel: ast.NewIdent("Sort")},
+			Lparen: call.Lparen,
+			Args:   call.Args[:1],
+			Rparen: call.Rparen,
+		})
+	}
+}
+
+// ascendingLess は less が func(i, j int) bool { return s[i] < s[j] } の形で、s の要素が順序のある基本型かどうかを返す
+func ascendingLess(info *types.Info, s, less ast.Expr) bool {
+	slice, ok := info.TypeOf(s).Underlying().(*types.Slice)
+	if !ok {
+		return false
+	}
+	if basic, ok := slice.Elem().Underlying().(*types.Basic); !ok || basic.Info()&types.IsOrdered == 0 {
+		return false
+	}
+	// s を 2 回評価することになるので、変数かフィールドの参照に限る
+	switch x := ast.Unparen(s).(type) {
+	case *ast.Ident:
+	case *ast.SelectorExpr:
+		if _, ok := x.X.(*ast.Ident); !ok {
+			return false
+		}
+	default:
+		return false
+	}
+	lit, ok := less.(*ast.FuncLit)
+	if !ok || len(lit.Body.List) != 1 {
+		return false
+	}
+	params := lit.Type.Params.List
+	if len(params) != 1 || len(params[0].Names) != 2 {
+		return false
+	}
+	ret, ok := lit.Body.List[0].(*ast.ReturnStmt)
+	if !ok || len(ret.Results) != 1 {
+		return false
+	}
+	cmp, ok := ret.Results[0].(*ast.BinaryExpr)
+	if !ok || cmp.Op != token.LSS {
+		return false
+	}
+	isElem := func(e ast.Expr, param *ast.Ident) bool {
+		index, ok := e.(*ast.IndexExpr)
+		if !ok || types.ExprString(index.X) != types.ExprString(s) {
+			return false
+		}
+		id, ok := index.Index.(*ast.Ident)
+		return ok && info.Uses[id] == info.Defs[param]
+	}
+	return isElem(cmp.X, params[0].Names[0]) && isElem(cmp.Y, params[0].Names[1])
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	src := `package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+type point struct{ x, y int }
+
+func main() {
+	rand.Seed(time.Now().UnixNano())
+	rand.Seed(42)
+	data, _ := ioutil.ReadFile("in.txt")
+	entries, _ := ioutil.ReadDir(".")
+	names := []string{"b", "a"}
+	sort.Strings(names)
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	sort.Slice(names, func(i, j int) bool { return names[i] > names[j] })
+	points := []point{{1, 2}}
+	sort.Slice(points, func(i, j int) bool { return points[i].x < points[j].x })
+	var v interface{} = data
+	fmt.Println(v, entries, points)
+}
+`
+	for _, tt := range []struct {
+		goVersion string
+		diags     []string
+		want      string
+	}{
+		{"1.22", []string{
+			"main.go:14: randseed: rand.Seed is deprecated and unnecessary; the global source is seeded randomly",
+			"main.go:15: randseed: rand.Seed is deprecated; use rand.New(rand.NewSource(42)) for a reproducible sequence",
+			"main.go:16: ioutil: ioutil.ReadFile is deprecated; use os.ReadFile",
+			"main.go:17: ioutil: ioutil.ReadDir is deprecated; use os.ReadDir",
+			"main.go:19: sortslice: sort.Strings can be replaced with slices.Sort",
+			"main.go:20: sortslice: sort.Slice can be replaced with slices.Sort",
+			"main.go:24: any: use any instead of interface{}",
+		}, `package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"slices"
+	"sort"
+)
+
+type point struct{ x, y int }
+
+func main() {
+	rand.Seed(42)
+	data, _ := os.ReadFile("in.txt")
+	entries, _ := ioutil.ReadDir(".")
+	names := []string{"b", "a"}
+	slices.Sort(names)
+	slices.Sort(names)
+	sort.Slice(names, func(i, j int) bool { return names[i] > names[j] })
+	points := []point{{1, 2}}
+	sort.Slice(points, func(i, j int) bool { return points[i].x < points[j].x })
+	var v any = data
+	fmt.Println(v, entries, points)
+}
+`},
+		// sort.Slice から slices.Sort への書き換えは Go 1.21 から
+		{"1.20", []string{
+			"main.go:14: randseed: rand.Seed is deprecated and unnecessary; the global source is seeded randomly",
+			"main.go:15: randseed: rand.Seed is deprecated; use rand.New(rand.NewSource(42)) for a reproducible sequence",
+			"main.go:16: ioutil: ioutil.ReadFile is deprecated; use os.ReadFile",
+			"main.go:17: ioutil: ioutil.ReadDir is deprecated; use os.ReadDir",
+			"main.go:24: any: use any instead of interface{}",
+		}, ""},
+	} {
+		dir := writeModule(t, map[string]string{"main.go": src, "go.mod": "module example.com/m\n\ngo " + tt.goVersion + "\n"})
+		prog, err := loadProgramWith(loadOptions{Tests: true}, dir, "./...")
+		if err != nil {
+			t.Fatal(err)
+		}
+		diags, files, err := migrate(prog, migrationRules, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertLines(t, diagnosticMessages(diags), tt.diags)
+		if tt.want == "" {
+			continue
+		}
+		if got := string(files[filepath.Join(dir, "main.go")]); got != tt.want {
+			t.Errorf("go %s: got:\n%s\nwant:\n%s", tt.goVersion, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+// ImportUsage は main パッケージが (間接的に) import しているパッケージのうち、main から到達できるコードの量
+type ImportUsage struct {
+	Main       string   `json:"main"`
+	Package    string   `json:"package"`
+	Functions  int      `json:"functions"` // init を除く関数の数
+	Reachable  int      `json:"reachable"` // そのうち main と init から到達できる関数の数
+	InitOnly   bool     `json:"initOnly"`  // 到達できる関数はないが、パッケージの初期化が何かをしている
+	Direct     bool     `json:"direct"`    // main パッケージが直接 import している
+	ImportedBy []string `json:"importedBy"`
+}
+
+// Removable は import をやめてもプログラムの動作が変わらないと考えられるかどうかを返す
+func (u ImportUsage) Removable() bool { return u.Reachable == 0 && !u.InitOnly }
+
+func runMinBinary(args []string) error {
+	fs := flag.NewFlagSet("minbinary", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output as JSON")
+	all := fs.Bool("all", false, "list every imported package, not only those contributing no reachable code")
+	std := fs.Bool("std", false, "include standard library packages")
+	fs.Parse(args)
+	prog, err := loadProgram(".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	var result []ImportUsage
+	for _, u := range importUsage(prog) {
+		if (*all || u.Reachable == 0) && (*std || !isStdPackage(u.Package)) {
+			result = append(result, u)
+		}
+	}
+	if *asJSON {
+		return writeJSON(os.Stdout, result)
+	}
+	return writeImportUsage(os.Stdout, result)
+}
+
+// importUsage は解析対象の main パッケージごとに、main 関数とパッケージの初期化から CHA のコールグラフと関数値の参照を
+// たどって到達できる関数を求め、import しているパッケージごとに数える。パッケージの初期化 (init 関数と変数の初期化) は
+// 数えないので、Reachable が 0 のパッケージは import をやめても (副作用がなければ) バイナリから消せる
+func importUsage(prog *Program) []ImportUsage {
+	funcs := make(map[*ssa.Package][]*ssa.Function)
+	for fn := range ssautil.AllFunctions(prog.SSA()) {
+		if fn.Pkg != nil && fn.Synthetic == "" && !isInitCode(fn) {
+			funcs[fn.Pkg] = append(funcs[fn.Pkg], fn)
+		}
+	}
+	var result []ImportUsage
+	for _, main := range prog.Packages {
+		spkg := prog.SSA().Package(main.Types)
+		if main.Name != "main" || spkg == nil || spkg.Func("main") == nil {
+			continue
+		}
+		reachable := reachableFrom(prog, []*ssa.Function{spkg.Func("main"), spkg.Func("init")})
+		importedBy := make(map[string][]string)
+		packages.Visit([]*packages.Package{main}, nil, func(pkg *packages.Package) {
+			for _, imp := range pkg.Imports {
+				importedBy[imp.PkgPath] = append(importedBy[imp.PkgPath], pkg.PkgPath)
+			}
+		})
+		packages.Visit([]*packages.Package{main}, nil, func(pkg *packages.Package) {
+			dep := prog.SSA().Package(pkg.Types)
+			if pkg == main || dep == nil || pkg.PkgPath == "unsafe" {
+				return
+			}
+			u := ImportUsage{Main: main.PkgPath, Package: pkg.PkgPath, Direct: main.Imports[pkg.PkgPath] != nil}
+			for _, fn := range funcs[dep] {
+				u.Functions++
+				if reachable[fn] {
+					u.Reachable++
+				}
+			}
+			u.InitOnly = u.Reachable == 0 && initDoesWork(dep)
+			u.ImportedBy = importedBy[pkg.PkgPath]
+			sort.Strings(u.ImportedBy)
+			result = append(result, u)
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Main != result[j].Main {
+			return result[i].Main < result[j].Main
+		}
+		return result[i].Package < result[j].Package
+	})
+	return result
+}
+
+// isInitCode は fn がパッケージの初期化 (変数の初期化、init 関数、その中の無名関数) かどうかを返す
+func isInitCode(fn *ssa.Function) bool {
+	for fn.Parent() != nil {
+		fn = fn.Parent()
+	}
+	return fn.Synthetic == "package initializer" || strings.HasPrefix(fn.Name(), "init#")
+}
+
+// initDoesWork は pkg の初期化が、依存先の初期化を呼ぶこと以外に何かをしているかどうかを返す
+func initDoesWork(pkg *ssa.Package) bool {
+	init := pkg.Func("init")
+	if init == nil {
+		return false
+	}
+	for _, b := range init.Blocks {
+		for _, instr := range b.Instrs {
+			switch instr := instr.(type) {
+			case *ssa.If, *ssa.Jump, *ssa.Return:
+				continue
+			case *ssa.UnOp:
+				// init$guard の読み込み
+				if g, ok := instr.X.(*ssa.Global); ok && g.Name() == "init$guard" {
+					continue
+				}
+			case *ssa.Store:
+				if g, ok := instr.Addr.(*ssa.Global); ok && g.Name() == "init$guard" {
+					continue
+				}
+			case *ssa.Call:
+				if callee := instr.Call.StaticCallee(); callee != nil && callee.Pkg != pkg && callee.Synthetic == "package initializer" {
+					continue
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
+
+func writeImportUsage(w io.Writer, usage []ImportUsage) error {
+	main := ""
+	for _, u := range usage {
+		if u.Main != main {
+			main = u.Main
+			fmt.Fprintln(w, main)
+		}
+		var note string
+		switch {
+		case u.Removable():
+			note = "no reachable code, import could be removed"
+		case u.InitOnly:
+			note = "no reachable code, imported for the side effects of its initialization"
+		default:
+			note = fmt.Sprintf("%d/%d functions reachable", u.Reachable, u.Functions)
+		}
+		imported := "indirect"
+		if u.Direct {
+			imported = "direct"
+		}
+		fmt.Fprintf(w, "\t%s: %s (%s, imported by %s)\n", u.Package, note, imported, strings.Join(u.ImportedBy, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestImportUsage(t *testing.T) {
+	prog := loadTestProgram(t, map[string]string{
+		"main.go": `package main
+
+import (
+	"example.com/m/consts"
+	"example.com/m/used"
+	_ "example.com/m/register"
+)
+
+func main() { println(used.Greet(consts.Name)) }
+`,
+		"consts/consts.go": `package consts
+
+const Name = "x"
+
+func Helper() string { return Name }
+`,
+		"used/used.go": `package used
+
+import "example.com/m/helper"
+
+func Greet(s string) string { return helper.Wrap(s) }
+
+func Unused() {}
+`,
+		"helper/helper.go": `package helper
+
+func Wrap(s string) string { return "<" + s + ">" }
+`,
+		"register/register.go": `package register
+
+var registry = map[string]bool{}
+
+func init() { registry["x"] = true }
+`,
+	})
+	var buf bytes.Buffer
+	if err := writeImportUsage(&buf, importUsage(prog)); err != nil {
+		t.Fatal(err)
+	}
+	assertLines(t, strings.Split(strings.TrimSpace(buf.String()), "\n"), []string{
+		"example.com/m",
+		"\texample.com/m/consts: no reachable code, import could be removed (direct, imported by example.com/m)",
+		"\texample.com/m/helper: 1/1 functions reachable (indirect, imported by example.com/m/used)",
+		"\texample.com/m/register: no reachable code, imported for the side effects of its initialization (direct, imported by example.com/m)",
+		"\texample.com/m/used: 1/2 functions reachable (direct, imported by example.com/m)",
+	})
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// anonNames は無名関数と無名の構造体型の、実行ごとに変わらない名前。
+// 無名関数は SSA の RelString(nil) と同じ pkg.Func$1 (入れ子は pkg.Func$1$1、パッケージ変数の初期化式では
+// 初期化の順に pkg.init$1) になるので、AST から作る出力とコールグラフなど SSA から作る出力で名前が一致する。
+// 無名の構造体型は、それを含む関数や宣言の名前に $struct1, $struct2 を続けた名前になる
+type anonNames struct {
+	funcs   map[*ast.FuncLit]string
+	structs typeutil.Map // *types.Struct -> string
+}
+
+// newAnonNames は pkg の無名関数と無名の構造体型に名前をつける
+func newAnonNames(pkg *packages.Package) *anonNames {
+	a := &anonNames{funcs: make(map[*ast.FuncLit]string)}
+	info := pkg.TypesInfo
+	funcs := make(map[string]int)
+	structs := make(map[string]int)
+	// walk は n の中のリテラルに owner の名前で番号をつける。SSA と同じく評価の順に数えるので、
+	// for 文の後処理は本体の後になる
+	var walk func(owner string, n ast.Node)
+	walk = func(owner string, n ast.Node) {
+		if n == nil {
+			return
+		}
+		ast.Inspect(n, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FuncLit:
+				funcs[owner]++
+				name := fmt.Sprintf("%s$%d", owner, funcs[owner])
+				a.funcs[n] = name
+				walk(name, n.Type)
+				walk(name, n.Body)
+				return false
+			case *ast.ForStmt:
+				for _, c := range []ast.Node{n.Init, n.Cond, n.Body, n.Post} {
+					walk(owner, c)
+				}
+				return false
+			case *ast.StructType:
+				if t, ok := info.TypeOf(n).(*types.Struct); ok && a.structs.At(t) == nil {
+					structs[owner]++
+					a.structs.Set(t, fmt.Sprintf("%s$struct%d", owner, structs[owner]))
+				}
+			}
+			return true
+		})
+	}
+
+	path := pkg.Types.Path()
+	inits := 0
+	for _, file := range pkg.Syntax {
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				fn, ok := info.Defs[decl.Name].(*types.Func)
+				if !ok {
+					continue
+				}
+				owner := fn.FullName()
+				if decl.Recv == nil && decl.Name.Name == "init" {
+					inits++
+					owner = fmt.Sprintf("%s.init#%d", path, inits)
+				}
+				walk(owner, decl.Type)
+				if decl.Body != nil {
+					walk(owner, decl.Body)
+				}
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					switch spec := spec.(type) {
+					case *ast.TypeSpec:
+						// 宣言した構造体型自身は無名ではないのでフィールドから名前をつける
+						if st, ok := spec.Type.(*ast.StructType); ok {
+							walk(path+"."+spec.Name.Name, st.Fields)
+						} else {
+							walk(path+"."+spec.Name.Name, spec.Type)
+						}
+					case *ast.ValueSpec:
+						if spec.Type != nil && len(spec.Names) > 0 {
+							walk(path+"."+spec.Names[0].Name, spec.Type)
+						}
+					}
+				}
+			}
+		}
+	}
+	// パッケージ変数の初期化式は SSA ではパッケージの初期化関数の中に初期化の順に置かれる
+	for _, init := range info.InitOrder {
+		walk(path+".init", init.Rhs)
+	}
+	return a
+}
+
+// funcName は lit の名前を返す
+func (a *anonNames) funcName(lit *ast.FuncLit) string {
+	return a.funcs[lit]
+}
+
+// typeString は types.TypeString(t, nil) の無名の構造体型を名前に置き換えた文字列を返す
+func (a *anonNames) typeString(t types.Type) string {
+	s := types.TypeString(t, nil)
+	if !strings.Contains(s, "struct{") {
+		return s
+	}
+	// 入れ子の構造体より先に外側の構造体を置き換える
+	type repl struct{ from, to string }
+	var repls []repl
+	a.structs.Iterate(func(key types.Type, value any) {
+		repls = append(repls, repl{types.TypeString(key, nil), value.(string)})
+	})
+	sort.Slice(repls, func(i, j int) bool {
+		if len(repls[i].from) != len(repls[j].from) {
+			return len(repls[i].from) > len(repls[j].from)
+		}
+		return repls[i].to < repls[j].to
+	})
+	for _, r := range repls {
+		s = strings.ReplaceAll(s, r.from, r.to)
+	}
+	return s
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"sort"
+	"testing"
+
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+func TestAnonNames(t *testing.T) {
+	prog := loadTestProgram(t, map[string]string{
+		"a.go": `package main
+
+var handlers = map[string]func(){
+	"a": func() {},
+}
+
+var later = func() int { return first() }
+
+var first = func() int { return 1 }
+
+type T struct {
+	cfg struct {
+		name string
+		opt  struct{ on bool }
+	}
+}
+
+func (t *T) Run() {
+	defer func() {
+		go func() {}()
+	}()
+	for i := 0; i < 2; func() { i++ }() {
+		func() {}()
+	}
+}
+
+func init() { _ = func() {} }
+`,
+		"b.go": `package main
+
+func init() { _ = func() {} }
+
+func Map[E any](s []E, f func(E) E) []E {
+	g := func(e E) E { return f(e) }
+	_ = g
+	return s
+}
+
+func main() {
+	p := struct{ x, y int }{1, 2}
+	_ = p
+	Map([]int{1}, func(n int) int { return n })
+}
+`,
+	})
+	names := newAnonNames(prog.Packages[0])
+	var got []string
+	for _, file := range prog.Packages[0].Syntax {
+		ast.Inspect(file, func(n ast.Node) bool {
+			if lit, ok := n.(*ast.FuncLit); ok {
+				got = append(got, names.funcName(lit))
+			}
+			return true
+		})
+	}
+	sort.Strings(got)
+
+	// SSA の無名関数の名前と一致する
+	var want []string
+	var anon func(fn *ssa.Function)
+	anon = func(fn *ssa.Function) {
+		for _, a := range fn.AnonFuncs {
+			want = append(want, a.RelString(nil))
+			anon(a)
+		}
+	}
+	ssaPkg := prog.SSA().Package(prog.Packages[0].Types)
+	for _, m := range ssaPkg.Members {
+		if fn, ok := m.(*ssa.Function); ok {
+			anon(fn)
+		}
+	}
+	for fn := range ssautil.AllFunctions(prog.SSA()) {
+		if fn.Signature.Recv() != nil && fn.Pkg == ssaPkg && fn.Synthetic == "" {
+			anon(fn)
+		}
+	}
+	sort.Strings(want)
+	assertLines(t, got, want)
+
+	T := prog.Packages[0].Types.Scope().Lookup("T").Type().Underlying().(*types.Struct)
+	got = []string{names.typeString(T), names.typeString(T.Field(0).Type())}
+	main := ssaPkg.Func("main")
+	for _, b := range main.Blocks {
+		for _, instr := range b.Instrs {
+			if alloc, ok := instr.(*ssa.Alloc); ok {
+				got = append(got, names.typeString(alloc.Type()))
+			}
+		}
+	}
+	assertLines(t, got, []string{
+		"struct{cfg example.com/m.T$struct1}",
+		"example.com/m.T$struct1",
+		"*example.com/m.main$struct1",
+		"*[1]int",
+	})
+}
+
+func TestCallSitesAnonCaller(t *testing.T) {
+	prog := loadTestProgram(t, map[string]string{"main.go": `package main
+
+var hook = func() { println("hook") }
+
+func main() {
+	run := func(p struct{ n int }) {
+		println(p.n)
+	}
+	run(struct{ n int }{1})
+}
+`})
+	var got []string
+	for _, s := range CallSites(prog.Packages) {
+		got = append(got, fmt.Sprintf("%d: %s -> %s%v", s.Pos.Line, s.CallerName, s.CalleeName, s.ArgTypes))
+	}
+	assertLines(t, got, []string{
+		"3: example.com/m.init$1 -> println[string]",
+		"7: example.com/m.main$1 -> println[int]",
+		// 同じ構造体型は最初に現れた場所の名前になる
+		"9: example.com/m.main -> run[example.com/m.main$1$struct1]",
+	})
+}
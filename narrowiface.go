@@ -0,0 +1,197 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// NarrowInterface はメソッドの一部しか使われていないインターフェース型の引数
+type NarrowInterface struct {
+	Func    string         `json:"func"`
+	Param   string         `json:"param"`
+	Type    string         `json:"type"`
+	Used    []string       `json:"used"`              // 関数の中 (と引数を渡した先) で呼び出されるメソッド
+	Suggest string         `json:"suggest,omitempty"` // 使われるメソッドとちょうど同じメソッドを持つ既存のインターフェース
+	Pos     token.Position `json:"pos"`
+}
+
+func runNarrowIface(args []string) error {
+	fs := flag.NewFlagSet("narrowiface", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output as JSON")
+	fs.Parse(args)
+	prog, err := loadProgram(".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	narrow := narrowInterfaces(prog)
+	relativizePositions(narrow)
+	if *asJSON {
+		return writeJSON(os.Stdout, narrow)
+	}
+	return writeNarrowInterfaces(os.Stdout, narrow)
+}
+
+// narrowInterfaces は解析対象の関数のインターフェース型の引数ごとに、実際に呼び出されるメソッドを集め、
+// それがインターフェースのメソッドの一部だけなら報告する。引数が保存される、返される、動的な呼び出しに渡されるなど
+// 使われ方を追えない場合は報告しない
+func narrowInterfaces(prog *Program) []NarrowInterface {
+	ifaces := candidateInterfaces(prog, true)
+	memo := make(map[ssa.Value]map[string]bool)
+	var result []NarrowInterface
+	for _, fn := range prog.targetFunctions() {
+		if fn.Synthetic != "" || fn.Parent() != nil || fn.Blocks == nil {
+			continue
+		}
+		params := fn.Params
+		if fn.Signature.Recv() != nil {
+			params = params[1:]
+		}
+		for _, param := range params {
+			iface, ok := param.Type().Underlying().(*types.Interface)
+			if !ok || !iface.IsMethodSet() || iface.NumMethods() < 2 {
+				continue
+			}
+			used, ok := usedMethods(param, memo)
+			if !ok || len(used) == iface.NumMethods() {
+				continue
+			}
+			n := NarrowInterface{
+				Func:  fn.RelString(nil),
+				Param: param.Name(),
+				Type:  types.TypeString(param.Type(), nil),
+				Pos:   prog.Fset.Position(param.Pos()),
+			}
+			for name := range used {
+				n.Used = append(n.Used, name)
+			}
+			sort.Strings(n.Used)
+			n.Suggest = matchingInterface(ifaces, iface, used)
+			result = append(result, n)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Func != result[j].Func {
+			return result[i].Func < result[j].Func
+		}
+		return result[i].Param < result[j].Param
+	})
+	return result
+}
+
+// usedMethods はインターフェース型の値 v (またはそれを保存した変数のアドレス) に対して呼び出されるメソッドの名前を返す。
+// 静的な呼び出し先の引数と無名関数の自由変数は推移的にたどり、別のインターフェースへの変換ではその型のメソッドを使うものとする。
+// 使われ方を追えなければ ok は false
+func usedMethods(v ssa.Value, memo map[ssa.Value]map[string]bool) (used map[string]bool, ok bool) {
+	if used, ok := memo[v]; ok {
+		return used, used != nil
+	}
+	memo[v] = make(map[string]bool) // 再帰呼び出しでは使われていないものとする
+	used = make(map[string]bool)
+	merge := func(w ssa.Value) bool {
+		m, ok := usedMethods(w, memo)
+		for name := range m {
+			used[name] = true
+		}
+		return ok
+	}
+	ok = true
+	for _, instr := range *v.Referrers() {
+		switch instr := instr.(type) {
+		case *ssa.DebugRef, *ssa.BinOp, *ssa.TypeAssert:
+			// 比較とアサーションではメソッドを使わない
+		case *ssa.ChangeInterface:
+			iface := instr.Type().Underlying().(*types.Interface)
+			for i := 0; i < iface.NumMethods(); i++ {
+				used[iface.Method(i).Name()] = true
+			}
+		case *ssa.Store:
+			// 無名関数が参照する変数は Alloc に保存され、読み出した値が使われる
+			if alloc, isAlloc := instr.Addr.(*ssa.Alloc); instr.Addr != v && !(isAlloc && instr.Val == v && merge(alloc)) {
+				ok = false
+			}
+		case *ssa.UnOp:
+			if instr.Op != token.MUL || !merge(instr) {
+				ok = false
+			}
+		case *ssa.MakeClosure:
+			closure := instr.Fn.(*ssa.Function)
+			for i, b := range instr.Bindings {
+				if b == v && !merge(closure.FreeVars[i]) {
+					ok = false
+				}
+			}
+		case ssa.CallInstruction:
+			common := instr.Common()
+			if common.IsInvoke() && common.Value == v {
+				used[common.Method.Name()] = true
+			}
+			callee := common.StaticCallee()
+			for i, arg := range common.Args {
+				if arg != v {
+					continue
+				}
+				if callee == nil || callee.Blocks == nil || !merge(callee.Params[i]) {
+					ok = false
+				}
+			}
+			if !common.IsInvoke() && common.Value == v {
+				ok = false
+			}
+		default:
+			ok = false
+		}
+	}
+	if !ok {
+		used = nil
+	}
+	memo[v] = used
+	return used, ok
+}
+
+// matchingInterface は used とちょうど同じメソッドを持ち、iface のメソッドと同じシグネチャの既存のインターフェースを返す
+func matchingInterface(ifaces []candidateInterface, iface *types.Interface, used map[string]bool) string {
+	var names []string
+	for _, c := range ifaces {
+		candidate := c.name.Type().Underlying().(*types.Interface)
+		if candidate.NumMethods() != len(used) || !types.Implements(iface, candidate) {
+			continue
+		}
+		match := true
+		for i := 0; i < candidate.NumMethods(); i++ {
+			if !used[candidate.Method(i).Name()] {
+				match = false
+			}
+		}
+		if match {
+			names = append(names, types.TypeString(c.name.Type(), nil))
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	sort.Strings(names)
+	return names[0]
+}
+
+func writeNarrowInterfaces(w io.Writer, narrow []NarrowInterface) error {
+	for _, n := range narrow {
+		used := strings.Join(n.Used, ", ")
+		if used == "" {
+			used = "no methods"
+		}
+		fmt.Fprintf(w, "%s: %s: %s %s only needs %s", n.Pos, n.Func, n.Param, n.Type, used)
+		if n.Suggest != "" {
+			fmt.Fprintf(w, " (use %s)", n.Suggest)
+		}
+		fmt.Fprintln(w)
+	}
+	return nil
+}
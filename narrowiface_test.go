@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestNarrowInterfaces(t *testing.T) {
+	src := `package main
+
+import (
+	"fmt"
+	"io"
+)
+
+type Store interface {
+	Get(key string) string
+	Put(key, value string)
+	Delete(key string)
+}
+
+func copyAll(rw io.ReadWriteCloser) {
+	defer rw.Close()
+	drain(rw)
+}
+
+func drain(r io.Reader) {
+	r.Read(nil)
+}
+
+func lookup(s Store, keys []string) {
+	for _, k := range keys {
+		func() { fmt.Println(s.Get(k)) }()
+	}
+	forward(s)
+}
+
+func forward(s Store) {
+	if s != nil {
+		s.Put("k", "v")
+	}
+}
+
+var saved Store
+
+func keep(s Store) { saved = s }
+
+func main() {
+	copyAll(nil)
+	lookup(nil, nil)
+	keep(nil)
+}
+`
+	prog := loadTestProgram(t, map[string]string{"main.go": src})
+	var buf bytes.Buffer
+	if err := writeNarrowInterfaces(&buf, narrowInterfaces(prog)); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		got = append(got, line[strings.Index(line, "main.go:"):])
+	}
+	assertLines(t, got, []string{
+		"main.go:14:14: example.com/m.copyAll: rw io.ReadWriteCloser only needs Close, Read (use io.ReadCloser)",
+		"main.go:30:14: example.com/m.forward: s example.com/m.Store only needs Put",
+		"main.go:23:13: example.com/m.lookup: s example.com/m.Store only needs Get, Put",
+	})
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// checkNilness は関数ごとに SSA を調べ、ある経路で nil になりうるポインタの参照外しを指摘する。
+// 対象にするのは次の値で、nil との比較で nil でない側の分岐の中にある参照外しは指摘しない
+//   - nil の定数
+//   - ある経路から nil が流れ込む φ
+//   - error と一緒に返された値で、その error が nil であることを確かめる前に参照外しされるもの
+//
+// nil と比較して nil である側の分岐の中での参照外しも指摘する。値ごとに最初の参照外しだけを報告する
+func checkNilness(prog *Program) []Diagnostic {
+	var diags []Diagnostic
+	for _, fn := range prog.targetFunctions() {
+		diags = append(diags, nilDerefs(prog.Fset, fn)...)
+	}
+	sortDiagnostics(diags)
+	return diags
+}
+
+func nilDerefs(fset *token.FileSet, fn *ssa.Function) []Diagnostic {
+	var diags []Diagnostic
+	walkNilDerefs(fset, fn, func(pos token.Pos, format string, args ...interface{}) {
+		diags = append(diags, newDiagnostic(fset, pos, "nilness", "possible nil dereference: "+format, args...))
+	})
+	return diags
+}
+
+// walkNilDerefs は checkNilness の基準で nil になりうるポインタの参照外しを見つけるたびに、その位置と理由で report を呼ぶ
+func walkNilDerefs(fset *token.FileSet, fn *ssa.Function, report func(pos token.Pos, format string, args ...interface{})) {
+	reported := make(map[ssa.Value]bool)
+	found := func(x ssa.Value, pos token.Pos, format string, args ...interface{}) {
+		reported[x] = true
+		report(pos, format, args...)
+	}
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			x := derefOperand(instr)
+			if x == nil || reported[x] || !instr.Pos().IsValid() {
+				continue
+			}
+			isNil, guard := nilGuard(fn, x, b)
+			if guard != nil && !isNil {
+				continue
+			}
+			if guard != nil {
+				found(x, instr.Pos(), "value compared to nil at line %d is nil on this path", fset.Position(guard.Pos()).Line)
+				continue
+			}
+			switch x := x.(type) {
+			case *ssa.Const:
+				if x.IsNil() {
+					found(x, instr.Pos(), "value is always nil")
+				}
+			case *ssa.Phi:
+				for i, edge := range x.Edges {
+					if c, ok := edge.(*ssa.Const); ok && c.IsNil() {
+						found(x, instr.Pos(), "value is nil %s", pathExplanation(fset, x.Block(), x.Block().Preds[i]))
+						break
+					}
+				}
+			case *ssa.Extract:
+				call, ok := x.Tuple.(*ssa.Call)
+				if !ok {
+					continue
+				}
+				results := call.Call.Signature().Results()
+				last := results.Len() - 1
+				if x.Index == last || !isErrorType(results.At(last).Type()) {
+					continue
+				}
+				err := tupleExtract(call, last)
+				if err == nil || len(*err.Referrers()) == 0 {
+					found(x, instr.Pos(), "result %d of %s is used although its error is discarded (line %d)",
+						x.Index, callDescription(&call.Call), fset.Position(call.Pos()).Line)
+					continue
+				}
+				if errNil, guard := nilGuard(fn, err, b); guard == nil || !errNil {
+					found(x, instr.Pos(), "result %d of %s may be nil because its error is not checked before this point (line %d)",
+						x.Index, callDescription(&call.Call), fset.Position(call.Pos()).Line)
+				}
+			}
+		}
+	}
+}
+
+// derefOperand は instr が参照外しするポインタを返す
+func derefOperand(instr ssa.Instruction) ssa.Value {
+	switch instr := instr.(type) {
+	case *ssa.FieldAddr:
+		return instr.X
+	case *ssa.IndexAddr:
+		if _, ok := instr.X.Type().Underlying().(*types.Pointer); ok {
+			return instr.X
+		}
+	case *ssa.UnOp:
+		if instr.Op == token.MUL {
+			return instr.X
+		}
+	case *ssa.Store:
+		return instr.Addr
+	}
+	return nil
+}
+
+// nilGuard は b を支配する if v == nil / v != nil の分岐を探し、b で v が nil かどうかを返す。見つからなければ guard は nil
+func nilGuard(fn *ssa.Function, v ssa.Value, b *ssa.BasicBlock) (isNil bool, guard *ssa.BinOp) {
+	for _, c := range fn.Blocks {
+		ifInstr, ok := c.Instrs[len(c.Instrs)-1].(*ssa.If)
+		if !ok {
+			continue
+		}
+		cond, ok := ifInstr.Cond.(*ssa.BinOp)
+		if !ok || cond.Op != token.EQL && cond.Op != token.NEQ || !comparesToNil(cond, v) {
+			continue
+		}
+		for k, succ := range c.Succs {
+			if len(succ.Preds) == 1 && succ.Dominates(b) {
+				return (cond.Op == token.EQL) == (k == 0), cond
+			}
+		}
+	}
+	return false, nil
+}
+
+// comparesToNil は cond が v と nil の比較かどうかを返す
+func comparesToNil(cond *ssa.BinOp, v ssa.Value) bool {
+	isNil := func(x ssa.Value) bool {
+		c, ok := x.(*ssa.Const)
+		return ok && c.IsNil()
+	}
+	return cond.X == v && isNil(cond.Y) || cond.Y == v && isNil(cond.X)
+}
+
+// pathExplanation は pred から b に進む経路を説明する
+func pathExplanation(fset *token.FileSet, b, pred *ssa.BasicBlock) string {
+	if ifInstr, ok := pred.Instrs[len(pred.Instrs)-1].(*ssa.If); ok {
+		if _, isInstr := ifInstr.Cond.(ssa.Instruction); !isInstr {
+			return fmt.Sprintf("when %s is %v", ifInstr.Cond.Name(), pred.Succs[0] == b)
+		}
+		if pos := ifInstr.Cond.Pos(); pos.IsValid() {
+			return fmt.Sprintf("when the condition at line %d is %v", fset.Position(pos).Line, pred.Succs[0] == b)
+		}
+	}
+	for i := len(pred.Instrs) - 1; i >= 0; i-- {
+		if pos := pred.Instrs[i].Pos(); pos.IsValid() {
+			return fmt.Sprintf("on the path through line %d", fset.Position(pos).Line)
+		}
+	}
+	return "on some path"
+}
+
+// tupleExtract は call の index 番目の戻り値を取り出す Extract を返す
+func tupleExtract(call *ssa.Call, index int) *ssa.Extract {
+	for _, instr := range *call.Referrers() {
+		if e, ok := instr.(*ssa.Extract); ok && e.Index == index {
+			return e
+		}
+	}
+	return nil
+}
+
+func isErrorType(t types.Type) bool {
+	return types.Identical(t, types.Universe.Lookup("error").Type())
+}
+
+// callDescription は呼び出し先の名前を返す (動的な呼び出しなら "call")
+func callDescription(call *ssa.CallCommon) string {
+	if name := calleeName(call); name != "" {
+		return name
+	}
+	return "call"
+}
@@ -0,0 +1,71 @@
+package main
+
+import "testing"
+
+func TestCheckNilness(t *testing.T) {
+	src := `package main
+
+import "errors"
+
+type config struct{ name string }
+
+func load(path string) (*config, error) {
+	if path == "" {
+		return nil, errors.New("empty path")
+	}
+	return &config{name: path}, nil
+}
+
+func unchecked() string {
+	c, err := load("a")
+	name := c.name
+	if err != nil {
+		return ""
+	}
+	return name
+}
+
+func checked() string {
+	c, err := load("b")
+	if err != nil {
+		return ""
+	}
+	return c.name
+}
+
+func discarded() string {
+	c, _ := load("c")
+	return c.name
+}
+
+func branch(ok bool) string {
+	var c *config
+	if ok {
+		c = &config{}
+	}
+	return c.name
+}
+
+func inverted(c *config) string {
+	if c == nil {
+		return c.name
+	}
+	return c.name
+}
+
+func main() {
+	unchecked()
+	checked()
+	discarded()
+	branch(true)
+	inverted(nil)
+}
+`
+	prog := loadTestProgram(t, map[string]string{"main.go": src})
+	assertLines(t, diagnosticMessages(checkNilness(prog)), []string{
+		"main.go:16: possible nil dereference: result 0 of example.com/m.load may be nil because its error is not checked before this point (line 15)",
+		"main.go:33: possible nil dereference: result 0 of example.com/m.load is used although its error is discarded (line 32)",
+		"main.go:41: possible nil dereference: value is nil when ok is false",
+		"main.go:46: possible nil dereference: value compared to nil at line 45 is nil on this path",
+	})
+}
@@ -0,0 +1,478 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/types"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// OpenAPIDoc は静的解析だけから作る OpenAPI 3.0 の下書き。推測した部分には x-uncertain に理由を書く
+type OpenAPIDoc struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       OpenAPIInfo                             `json:"info"`
+	Paths      map[string]map[string]*OpenAPIOperation `json:"paths"`
+	Components struct {
+		Schemas map[string]*OpenAPISchema `json:"schemas,omitempty"`
+	} `json:"components"`
+}
+
+// OpenAPIInfo は API の名前とバージョン
+type OpenAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// OpenAPIOperation はルート 1 つの操作
+type OpenAPIOperation struct {
+	Summary     string                      `json:"summary"` // ハンドラの関数
+	Parameters  []OpenAPIParameter          `json:"parameters,omitempty"`
+	RequestBody *OpenAPIBody                `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+	Uncertain   []string                    `json:"x-uncertain,omitempty"`
+}
+
+// OpenAPIParameter はパスかクエリのパラメーター
+type OpenAPIParameter struct {
+	Name     string         `json:"name"`
+	In       string         `json:"in"` // path か query
+	Required bool           `json:"required,omitempty"`
+	Schema   *OpenAPISchema `json:"schema"`
+}
+
+// OpenAPIBody はリクエストの本文
+type OpenAPIBody struct {
+	Content map[string]OpenAPIMedia `json:"content"`
+}
+
+// OpenAPIResponse はステータスコード 1 つの応答
+type OpenAPIResponse struct {
+	Description string                  `json:"description"`
+	Content     map[string]OpenAPIMedia `json:"content,omitempty"`
+}
+
+// OpenAPIMedia は本文のメディアタイプごとのスキーマ
+type OpenAPIMedia struct {
+	Schema *OpenAPISchema `json:"schema"`
+}
+
+// OpenAPISchema は JSON Schema のうち、Go の型から分かる部分
+type OpenAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Nullable             bool                      `json:"nullable,omitempty"`
+	Items                *OpenAPISchema            `json:"items,omitempty"`
+	Properties           map[string]*OpenAPISchema `json:"properties,omitempty"`
+	AdditionalProperties *OpenAPISchema            `json:"additionalProperties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+	OneOf                []*OpenAPISchema          `json:"oneOf,omitempty"`
+	Uncertain            string                    `json:"x-uncertain,omitempty"`
+}
+
+var (
+	// routeParam はルートのパスのパラメーター (chi と net/http の {id}, {id:[0-9]+}, {path...}、gin と echo の :id, *path)
+	routeParam = regexp.MustCompile(`\{(\w+)(?:\.\.\.|:[^}]*)?\}|[:*](\w+)`)
+
+	// openAPIStatusFuncs は応答のステータスコードを決める呼び出しと、ステータスコードの引数の位置
+	openAPIStatusFuncs = map[string]int{
+		"(net/http.ResponseWriter).WriteHeader":           0,
+		"(*github.com/gin-gonic/gin.Context).Status":      0,
+		"(github.com/labstack/echo/v4.Context).NoContent": 0,
+	}
+	// openAPIErrorFuncs は本文がテキストのエラーの応答を返す呼び出しと、ステータスコードの引数の位置
+	openAPIErrorFuncs = map[string]int{
+		"net/http.Error": 2,
+		"(*github.com/gin-gonic/gin.Context).AbortWithStatus": 0,
+		"(*github.com/gin-gonic/gin.Context).String":          0,
+		"(github.com/labstack/echo/v4.Context).String":        0,
+	}
+	// openAPIQueryFuncs はクエリパラメーターを読む呼び出しと、名前の引数の位置
+	openAPIQueryFuncs = map[string]int{
+		"(*github.com/gin-gonic/gin.Context).Query":        0,
+		"(*github.com/gin-gonic/gin.Context).DefaultQuery": 0,
+		"(github.com/labstack/echo/v4.Context).QueryParam": 0,
+		"(net/url.Values).Get":                             0, // r.URL.Query().Get のときだけ
+	}
+)
+
+func runOpenAPI(args []string) error {
+	fs := flag.NewFlagSet("openapi", flag.ExitOnError)
+	title := fs.String("title", "", "API title (defaults to the module path)")
+	version := fs.String("version", "0.0.0", "API version")
+	summary := fs.Bool("summary", false, "print one line per operation and the uncertain parts instead of the document")
+	fs.Parse(args)
+	prog, err := loadProgram(".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	doc := openAPIDocument(prog, httpRoutes(prog))
+	doc.Info = OpenAPIInfo{Title: *title, Version: *version}
+	if doc.Info.Title == "" && len(prog.Packages) > 0 && prog.Packages[0].Module != nil {
+		doc.Info.Title = prog.Packages[0].Module.Path
+	}
+	if *summary {
+		for _, line := range openAPISummary(doc) {
+			fmt.Println(line)
+		}
+		return nil
+	}
+	return writeJSON(os.Stdout, doc)
+}
+
+// openAPIDocument はルート表の各ルートのハンドラの中の JSON の (デ)シリアライズ (jsonCodecFuncs) から
+// リクエストと応答の本文を、WriteHeader や c.JSON の定数のステータスコードから応答のコードを求めて OpenAPI の下書きを作る。
+// ハンドラの外 (呼び出している関数やミドルウェア) の読み書きは見ないので、見つからなければ x-uncertain に書く
+func openAPIDocument(prog *Program, routes []Route) *OpenAPIDoc {
+	doc := &OpenAPIDoc{OpenAPI: "3.0.3", Paths: make(map[string]map[string]*OpenAPIOperation)}
+	doc.Components.Schemas = make(map[string]*OpenAPISchema)
+	sites := CallSites(prog.Packages)
+	for _, r := range routes {
+		path, params := openAPIPath(r.Path)
+		op := &OpenAPIOperation{Summary: r.Handler, Parameters: params, Responses: make(map[string]*OpenAPIResponse)}
+		if strings.HasSuffix(r.Path, "/") && r.Path != "/" || strings.Contains(r.Path, "*") {
+			op.Uncertain = append(op.Uncertain, "the route also matches every path below "+path)
+		}
+		if r.Func == nil || r.Func.Pkg == nil || r.Func.Syntax() == nil {
+			op.Uncertain = append(op.Uncertain, "the handler could not be resolved")
+		} else {
+			openAPIHandler(prog, doc, op, r, sites)
+		}
+		if len(op.Responses) == 0 {
+			op.Responses["default"] = &OpenAPIResponse{Description: "unknown"}
+			op.Uncertain = append(op.Uncertain, "no response written in the handler was found")
+		}
+		method := strings.ToLower(r.Method)
+		if r.Method == "ANY" {
+			method = "get"
+			if op.RequestBody != nil {
+				method = "post"
+			}
+			op.Uncertain = append(op.Uncertain, "the route accepts any method; "+method+" is a guess")
+		}
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]*OpenAPIOperation)
+		}
+		if _, ok := doc.Paths[path][method]; ok {
+			op.Uncertain = append(op.Uncertain, "replaces another route registered for the same method and path")
+		}
+		doc.Paths[path][method] = op
+	}
+	return doc
+}
+
+// openAPIPath はルートのパスを OpenAPI のパスにして、パスのパラメーターを返す
+func openAPIPath(path string) (string, []OpenAPIParameter) {
+	var params []OpenAPIParameter
+	path = routeParam.ReplaceAllStringFunc(path, func(m string) string {
+		sub := routeParam.FindStringSubmatch(m)
+		name := sub[1] + sub[2]
+		if name == "" {
+			return m
+		}
+		params = append(params, OpenAPIParameter{Name: name, In: "path", Required: true, Schema: &OpenAPISchema{Type: "string"}})
+		return "{" + name + "}"
+	})
+	if path == "" {
+		path = "/"
+	}
+	return path, params
+}
+
+// openAPIHandler はハンドラ r.Func の中 (関数リテラルを含む) の呼び出しから、op の本文、応答、クエリパラメーターを埋める
+func openAPIHandler(prog *Program, doc *OpenAPIDoc, op *OpenAPIOperation, r Route, sites []*CallSite) {
+	syntax := r.Func.Syntax()
+	status := "200"
+	for _, site := range sites {
+		if site.Call.Pos() < syntax.Pos() || site.Call.End() > syntax.End() || site.Pkg.Types != r.Func.Pkg.Pkg {
+			continue
+		}
+		args := site.Call.Args
+		if i, ok := openAPIStatusFuncs[site.CalleeName]; ok && i < len(args) {
+			status = openAPIStatus(site.Pkg, args[i], op)
+			if strings.HasSuffix(site.CalleeName, ".NoContent") {
+				openAPIAddResponse(op, status, nil)
+			}
+		}
+		if i, ok := openAPIErrorFuncs[site.CalleeName]; ok && i < len(args) {
+			openAPIAddResponse(op, openAPIStatus(site.Pkg, args[i], op), nil)
+		}
+		if i, ok := openAPIQueryFuncs[site.CalleeName]; ok && i < len(args) && isURLQuery(site) {
+			if name := constStringExpr(site.Pkg, args[i]); name != "" && !hasParameter(op, name, "query") {
+				op.Parameters = append(op.Parameters, OpenAPIParameter{Name: name, In: "query", Schema: &OpenAPISchema{Type: "string"}})
+			}
+		}
+		codec, ok := jsonCodecFuncs[site.CalleeName]
+		if !ok || codec.Arg >= len(args) {
+			continue
+		}
+		t := site.Pkg.TypesInfo.TypeOf(args[codec.Arg])
+		if t == nil {
+			continue
+		}
+		if ptr, ok := t.(*types.Pointer); ok && !codec.Encode {
+			t = ptr.Elem()
+		}
+		schema := openAPISchemaOf(prog, doc, t, false)
+		if !codec.Encode {
+			if op.RequestBody != nil {
+				op.Uncertain = append(op.Uncertain, "the request body is decoded more than once")
+				continue
+			}
+			op.RequestBody = &OpenAPIBody{Content: map[string]OpenAPIMedia{"application/json": {Schema: schema}}}
+			continue
+		}
+		code := status
+		if strings.Contains(site.CalleeName, "gin-gonic") || strings.Contains(site.CalleeName, "echo") {
+			code = openAPIStatus(site.Pkg, args[0], op) // c.JSON(code, v)
+		}
+		openAPIAddResponse(op, code, schema)
+	}
+	sort.SliceStable(op.Parameters, func(i, j int) bool { return op.Parameters[i].In == "path" && op.Parameters[j].In != "path" })
+}
+
+// openAPIStatus は定数のステータスコードの式を文字列にする。定数でなければ default にして op に記録する
+func openAPIStatus(pkg *packages.Package, expr ast.Expr, op *OpenAPIOperation) string {
+	if tv, ok := pkg.TypesInfo.Types[expr]; ok && tv.Value != nil && tv.Value.Kind() == constant.Int {
+		return tv.Value.ExactString()
+	}
+	op.Uncertain = append(op.Uncertain, "status code "+types.ExprString(expr)+" is not a constant")
+	return "default"
+}
+
+// openAPIAddResponse は status の応答を追加する。同じコードの応答の本文が違えば oneOf にまとめる
+func openAPIAddResponse(op *OpenAPIOperation, status string, schema *OpenAPISchema) {
+	resp, ok := op.Responses[status]
+	if !ok {
+		resp = &OpenAPIResponse{Description: "unknown"}
+		if code, err := strconv.Atoi(status); err == nil && http.StatusText(code) != "" {
+			resp.Description = http.StatusText(code)
+		}
+		op.Responses[status] = resp
+	}
+	if schema == nil {
+		return
+	}
+	media, ok := resp.Content["application/json"]
+	switch {
+	case !ok:
+		resp.Content = map[string]OpenAPIMedia{"application/json": {Schema: schema}}
+	case media.Schema.OneOf != nil:
+		media.Schema.OneOf = append(media.Schema.OneOf, schema)
+	case schemaName(media.Schema) != schemaName(schema):
+		resp.Content["application/json"] = OpenAPIMedia{Schema: &OpenAPISchema{OneOf: []*OpenAPISchema{media.Schema, schema}}}
+	}
+}
+
+// isURLQuery は url.Values の Get なら、レシーバが r.URL.Query() の呼び出しかどうかを返す (フォームの値などを除く)
+func isURLQuery(site *CallSite) bool {
+	if site.CalleeName != "(net/url.Values).Get" {
+		return true
+	}
+	sel, ok := ast.Unparen(site.Call.Fun).(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	call, ok := ast.Unparen(sel.X).(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	fn, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	obj, ok := site.Pkg.TypesInfo.Uses[fn.Sel].(*types.Func)
+	return ok && obj.FullName() == "(*net/url.URL).Query"
+}
+
+// constStringExpr は expr が文字列定数であればその値を返す
+func constStringExpr(pkg *packages.Package, expr ast.Expr) string {
+	if tv, ok := pkg.TypesInfo.Types[expr]; ok && tv.Value != nil && tv.Value.Kind() == constant.String {
+		return constant.StringVal(tv.Value)
+	}
+	return ""
+}
+
+// hasParameter は op に in の name のパラメーターがあるかどうかを返す
+func hasParameter(op *OpenAPIOperation, name, in string) bool {
+	for _, p := range op.Parameters {
+		if p.Name == name && p.In == in {
+			return true
+		}
+	}
+	return false
+}
+
+// openAPISchemaOf は encoding/json が t の値を書き出す形のスキーマを返す。名前付きの構造体は components に登録して参照にする。
+// asString は ,string のフィールドで、数値や真偽値を文字列にする
+func openAPISchemaOf(prog *Program, doc *OpenAPIDoc, t types.Type, asString bool) *OpenAPISchema {
+	t = types.Unalias(t)
+	if named, ok := t.(*types.Named); ok {
+		obj := named.Obj()
+		switch {
+		case obj.Pkg() != nil && obj.Pkg().Path() == "time" && obj.Name() == "Time":
+			return &OpenAPISchema{Type: "string", Format: "date-time"}
+		case obj.Pkg() != nil && obj.Pkg().Path() == "encoding/json" && obj.Name() == "RawMessage":
+			return &OpenAPISchema{Uncertain: "json.RawMessage can hold any value"}
+		}
+		if custom := jsonCustomMethods(named); len(custom) > 0 {
+			return &OpenAPISchema{Uncertain: types.TypeString(named, (*types.Package).Name) + " implements " + strings.Join(custom, " and ")}
+		}
+		if st, ok := named.Underlying().(*types.Struct); ok {
+			key := openAPIComponentKey(named)
+			if _, ok := doc.Components.Schemas[key]; !ok {
+				doc.Components.Schemas[key] = &OpenAPISchema{} // 再帰的な型のために先に登録する
+				*doc.Components.Schemas[key] = *openAPIStructSchema(prog, doc, st)
+			}
+			return &OpenAPISchema{Ref: "#/components/schemas/" + key}
+		}
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		var s OpenAPISchema
+		switch {
+		case u.Info()&types.IsBoolean != 0:
+			s.Type = "boolean"
+		case u.Info()&types.IsInteger != 0:
+			s.Type = "integer"
+			if u.Kind() == types.Int64 || u.Kind() == types.Uint64 {
+				s.Format = "int64"
+			} else if u.Kind() == types.Int32 || u.Kind() == types.Uint32 {
+				s.Format = "int32"
+			}
+		case u.Info()&types.IsFloat != 0:
+			s.Type = "number"
+		case u.Info()&types.IsString != 0:
+			s.Type = "string"
+		default:
+			return &OpenAPISchema{Uncertain: "unsupported type " + u.String()}
+		}
+		if asString && s.Type != "string" {
+			s = OpenAPISchema{Type: "string", Format: s.Type}
+		}
+		return &s
+	case *types.Pointer:
+		s := openAPISchemaOf(prog, doc, u.Elem(), asString)
+		if s.Ref != "" {
+			return s // $ref と並ぶキーは無視されるので nullable を付けない
+		}
+		s.Nullable = true
+		return s
+	case *types.Slice:
+		if b, ok := u.Elem().Underlying().(*types.Basic); ok && b.Kind() == types.Byte {
+			return &OpenAPISchema{Type: "string", Format: "byte"}
+		}
+		return &OpenAPISchema{Type: "array", Items: openAPISchemaOf(prog, doc, u.Elem(), false), Nullable: true}
+	case *types.Array:
+		return &OpenAPISchema{Type: "array", Items: openAPISchemaOf(prog, doc, u.Elem(), false)}
+	case *types.Map:
+		return &OpenAPISchema{Type: "object", AdditionalProperties: openAPISchemaOf(prog, doc, u.Elem(), false)}
+	case *types.Struct:
+		return openAPIStructSchema(prog, doc, u)
+	case *types.Interface:
+		return &OpenAPISchema{Uncertain: "the dynamic type of " + types.TypeString(t, (*types.Package).Name) + " is unknown"}
+	}
+	return &OpenAPISchema{Uncertain: "unsupported type " + types.TypeString(t, (*types.Package).Name)}
+}
+
+// openAPIStructSchema は jsonFields で求めたワイヤー上のフィールドからオブジェクトのスキーマを作る。
+// omitempty と omitzero のないフィールドは、常に書き出されるので required にする
+func openAPIStructSchema(prog *Program, doc *OpenAPIDoc, st *types.Struct) *OpenAPISchema {
+	s := &OpenAPISchema{Type: "object", Properties: make(map[string]*OpenAPISchema)}
+	fields, _ := jsonFields(prog.Fset, st)
+	for _, f := range fields {
+		s.Properties[f.Name] = openAPISchemaOf(prog, doc, f.typ, f.String)
+		if !f.OmitEmpty && !f.OmitZero {
+			s.Required = append(s.Required, f.Name)
+		}
+	}
+	return s
+}
+
+// openAPISummary は OpenAPI の下書きの操作 (パラメーター、本文の型、応答のコードと型) と推測した部分を 1 行ずつ返す
+func openAPISummary(doc *OpenAPIDoc) []string {
+	var lines []string
+	var paths []string
+	for path := range doc.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		var methods []string
+		for method := range doc.Paths[path] {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+		for _, method := range methods {
+			op := doc.Paths[path][method]
+			var parts []string
+			for _, p := range op.Parameters {
+				parts = append(parts, p.In+":"+p.Name)
+			}
+			if op.RequestBody != nil {
+				parts = append(parts, "body:"+schemaName(op.RequestBody.Content["application/json"].Schema))
+			}
+			var codes []string
+			for code := range op.Responses {
+				codes = append(codes, code)
+			}
+			sort.Strings(codes)
+			for _, code := range codes {
+				if media, ok := op.Responses[code].Content["application/json"]; ok {
+					parts = append(parts, code+":"+schemaName(media.Schema))
+				} else {
+					parts = append(parts, code)
+				}
+			}
+			lines = append(lines, fmt.Sprintf("%s %s %s", strings.ToUpper(method), path, strings.Join(parts, " ")))
+			for _, u := range op.Uncertain {
+				lines = append(lines, "  ? "+u)
+			}
+		}
+	}
+	return lines
+}
+
+// openAPIComponentKey は名前付きの型を登録する components のスキーマの名前を返す。パッケージ名や型の名前だけでは
+// 別のパッケージの同じ名前の型やジェネリックな型の別のインスタンス (Page[User] と Page[Order]) が重なるので、
+// パッケージのパスと型引数を含めた型の名前から、OpenAPI の名前に使えない文字を除いて作る
+// (example.com/m.Page[example.com/m.User] は example.com_m.Page_example.com_m.User)
+func openAPIComponentKey(named *types.Named) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == ']' || r == ' ':
+			return -1
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, types.TypeString(named, nil))
+}
+
+// schemaName はスキーマを短く表す (参照なら型の名前、配列なら []要素)
+func schemaName(s *OpenAPISchema) string {
+	switch {
+	case s.Ref != "":
+		return strings.TrimPrefix(s.Ref, "#/components/schemas/")
+	case s.Type == "array" && s.Items != nil:
+		return "[]" + schemaName(s.Items)
+	case len(s.OneOf) > 0:
+		var names []string
+		for _, o := range s.OneOf {
+			names = append(names, schemaName(o))
+		}
+		return strings.Join(names, "|")
+	case s.Type != "":
+		return s.Type
+	}
+	return "?"
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestOpenAPIDocument(t *testing.T) {
+	prog := loadTestProgram(t, map[string]string{
+		"go.mod": `module example.com/m
+
+go 1.22
+
+require github.com/gin-gonic/gin v0.0.0
+
+replace github.com/gin-gonic/gin => ./third_party/gin
+`,
+		"third_party/gin/go.mod": "module github.com/gin-gonic/gin\n\ngo 1.22\n",
+		"third_party/gin/gin.go": `package gin
+
+type Context struct{}
+
+func (c *Context) JSON(code int, obj any)        {}
+func (c *Context) ShouldBindJSON(obj any) error { return nil }
+func (c *Context) Query(key string) string      { return "" }
+
+type HandlerFunc func(*Context)
+
+type IRoutes interface {
+	GET(path string, handlers ...HandlerFunc) IRoutes
+}
+
+type RouterGroup struct{ prefix string }
+
+func (g *RouterGroup) Group(path string, handlers ...HandlerFunc) *RouterGroup {
+	return &RouterGroup{prefix: g.prefix + path}
+}
+
+func (g *RouterGroup) GET(path string, handlers ...HandlerFunc) IRoutes  { return g }
+func (g *RouterGroup) POST(path string, handlers ...HandlerFunc) IRoutes { return g }
+
+type Engine struct{ RouterGroup }
+
+func New() *Engine { return &Engine{} }
+`,
+		"main.go": `package main
+
+import "github.com/gin-gonic/gin"
+
+type CreateUser struct {
+	Name  string ` + "`json:\"name\"`" + `
+	Email string ` + "`json:\"email,omitempty\"`" + `
+}
+
+type User struct {
+	ID   int64  ` + "`json:\"id\"`" + `
+	Name string ` + "`json:\"name\"`" + `
+}
+
+type Order struct {
+	Total int ` + "`json:\"total\"`" + `
+}
+
+type Page[T any] struct {
+	Items []T    ` + "`json:\"items\"`" + `
+	Next  string ` + "`json:\"next,omitempty\"`" + `
+}
+
+type apiError struct {
+	Message string ` + "`json:\"message\"`" + `
+}
+
+func createUser(c *gin.Context) {
+	var req CreateUser
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, apiError{Message: err.Error()})
+		return
+	}
+	c.JSON(201, User{Name: req.Name})
+}
+
+func listUsers(c *gin.Context) {
+	_ = c.Query("limit")
+	c.JSON(200, []User{})
+}
+
+func getUser(c *gin.Context) {
+	code := 200
+	c.JSON(code, User{})
+}
+
+func userPage(c *gin.Context) {
+	c.JSON(200, Page[User]{})
+}
+
+func orderPage(c *gin.Context) {
+	c.JSON(200, Page[Order]{})
+}
+
+func main() {
+	e := gin.New()
+	v1 := e.Group("/v1")
+	v1.POST("/users", createUser)
+	v1.GET("/users", listUsers)
+	v1.GET("/users/:id", getUser)
+	v1.GET("/pages/users", userPage)
+	v1.GET("/pages/orders", orderPage)
+}
+`,
+	})
+	doc := openAPIDocument(prog, httpRoutes(prog))
+	assertLines(t, openAPISummary(doc), []string{
+		"GET /v1/pages/orders 200:example.com_m.Page_example.com_m.Order",
+		"GET /v1/pages/users 200:example.com_m.Page_example.com_m.User",
+		"GET /v1/users query:limit 200:[]example.com_m.User",
+		"POST /v1/users body:example.com_m.CreateUser 201:example.com_m.User 400:example.com_m.apiError",
+		"GET /v1/users/{id} path:id default:example.com_m.User",
+		"  ? status code code is not a constant",
+	})
+	user := doc.Components.Schemas["example.com_m.User"]
+	if user == nil || user.Properties["id"].Format != "int64" || !slices.Equal(user.Required, []string{"id", "name"}) {
+		t.Errorf("got schema %+v for example.com_m.User", user)
+	}
+	if got := doc.Components.Schemas["example.com_m.CreateUser"].Required; !slices.Equal(got, []string{"name"}) {
+		t.Errorf("example.com_m.CreateUser requires %v, want [name]", got)
+	}
+	// ジェネリックな型はインスタンスごとに別のスキーマになる
+	for name, item := range map[string]string{
+		"example.com_m.Page_example.com_m.User":  "#/components/schemas/example.com_m.User",
+		"example.com_m.Page_example.com_m.Order": "#/components/schemas/example.com_m.Order",
+	} {
+		page := doc.Components.Schemas[name]
+		if page == nil || page.Properties["items"].Items == nil || page.Properties["items"].Items.Ref != item {
+			t.Errorf("got schema %+v for %s, want items of %s", page, name, item)
+		}
+	}
+}
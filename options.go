@@ -0,0 +1,225 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/packages"
+)
+
+// Option はコマンドラインフラグまたは設定項目
+type Option struct {
+	Kind      string         `json:"kind"` // flag, pflag, envconfig
+	Name      string         `json:"name"`
+	Shorthand string         `json:"shorthand,omitempty"`
+	Type      string         `json:"type"`
+	Default   string         `json:"default,omitempty"`
+	Usage     string         `json:"usage,omitempty"`
+	Required  bool           `json:"required,omitempty"`
+	Pos       token.Position `json:"pos"`
+}
+
+// optionPackages はフラグを定義するパッケージと、その Option.Kind
+var optionPackages = map[string]string{
+	"flag":                   "flag",
+	"github.com/spf13/pflag": "pflag",
+}
+
+const envconfigPath = "github.com/kelseyhightower/envconfig"
+
+func runOptions(args []string) error {
+	fs := flag.NewFlagSet("options", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output as JSON")
+	fs.Parse(args)
+	prog, err := loadProgram(".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	opts := options(prog.Packages)
+	if *asJSON {
+		return writeJSON(os.Stdout, opts)
+	}
+	return writeOptions(os.Stdout, opts)
+}
+
+// options は flag, pflag によるフラグの定義と、envconfig のタグがついた構造体のフィールドを集める
+func options(pkgs []*packages.Package) []Option {
+	var opts []Option
+	prefixes := make(map[*types.Named]string)
+	var configs []*types.Named
+	for _, site := range CallSites(pkgs) {
+		if opt, ok := flagOption(site); ok {
+			opts = append(opts, opt)
+		}
+		// envconfig.Process("myapp", &cfg) のプレフィックス
+		info, args := site.Pkg.TypesInfo, site.Call.Args
+		if fn := site.Func(); fn != nil && fn.Pkg() != nil && fn.Pkg().Path() == envconfigPath && len(args) == 2 {
+			if named := pointerToNamed(info.TypeOf(args[1])); named != nil {
+				if tv := info.Types[args[0]]; tv.Value != nil && tv.Value.Kind() == constant.String {
+					prefixes[named] = constant.StringVal(tv.Value)
+				}
+			}
+		}
+	}
+	for _, pkg := range pkgs {
+		inspector.New(pkg.Syntax).Preorder([]ast.Node{(*ast.TypeSpec)(nil)}, func(n ast.Node) {
+			ts := n.(*ast.TypeSpec)
+			if named, ok := pkg.TypesInfo.Defs[ts.Name].Type().(*types.Named); ok && isEnvconfigStruct(named) {
+				configs = append(configs, named)
+			}
+		})
+	}
+	for _, named := range configs {
+		opts = append(opts, envconfigOptions(pkgs[0].Fset, named, prefixes[named])...)
+	}
+	sort.SliceStable(opts, func(i, j int) bool {
+		if opts[i].Kind != opts[j].Kind {
+			return opts[i].Kind < opts[j].Kind
+		}
+		return opts[i].Name < opts[j].Name
+	})
+	return opts
+}
+
+// flagOption は site が flag.String や (*pflag.FlagSet).IntVarP のようなフラグの定義であれば、
+// 引数の名前 (name, shorthand, value, usage) から Option を作る
+func flagOption(site *CallSite) (Option, bool) {
+	pkg, call, fn := site.Pkg, site.Call, site.Func()
+	if fn == nil || fn.Pkg() == nil {
+		return Option{}, false
+	}
+	kind := optionPackages[fn.Pkg().Path()]
+	sig := fn.Type().(*types.Signature)
+	if kind == "" || sig.Variadic() || sig.Params().Len() != len(call.Args) {
+		return Option{}, false
+	}
+	opt := Option{Kind: kind, Pos: site.Pos}
+	hasName, hasUsage := false, false
+	for i := 0; i < sig.Params().Len(); i++ {
+		param, arg := sig.Params().At(i), call.Args[i]
+		switch param.Name() {
+		case "name":
+			opt.Name, hasName = constOrExpr(pkg.TypesInfo, arg), true
+		case "shorthand":
+			opt.Shorthand = constOrExpr(pkg.TypesInfo, arg)
+		case "value":
+			opt.Default = constOrExpr(pkg.TypesInfo, arg)
+			opt.Type = types.TypeString(pkg.TypesInfo.TypeOf(arg), types.RelativeTo(pkg.Types))
+		case "usage":
+			opt.Usage, hasUsage = constOrExpr(pkg.TypesInfo, arg), true
+		}
+	}
+	if !hasName || !hasUsage {
+		return Option{}, false
+	}
+	if opt.Type == "" {
+		opt.Type = "func" // flag.Func, flag.BoolFunc
+	}
+	if name := fn.Name(); name == "Var" || name == "VarP" {
+		opt.Default, opt.Type = "", opt.Default
+	}
+	return opt, true
+}
+
+// constOrExpr は文字列定数であればその値を、そうでなければ式のソース (5*time.Second など) を返す
+func constOrExpr(info *types.Info, expr ast.Expr) string {
+	if tv := info.Types[expr]; tv.Value != nil && tv.Value.Kind() == constant.String {
+		return constant.StringVal(tv.Value)
+	}
+	return types.ExprString(expr)
+}
+
+// pointerToNamed は *T の T を返す
+func pointerToNamed(t types.Type) *types.Named {
+	if ptr, ok := t.(*types.Pointer); ok {
+		named, _ := ptr.Elem().(*types.Named)
+		return named
+	}
+	return nil
+}
+
+// isEnvconfigStruct は構造体のフィールドに envconfig のタグがあるかどうかを返す
+func isEnvconfigStruct(named *types.Named) bool {
+	st, ok := named.Underlying().(*types.Struct)
+	if !ok {
+		return false
+	}
+	for i := 0; i < st.NumFields(); i++ {
+		tag := reflect.StructTag(st.Tag(i))
+		if _, ok := tag.Lookup("envconfig"); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// envconfigOptions は envconfig の構造体のフィールドを Option にする。
+// 名前は envconfig タグ (なければフィールド名の大文字) に PREFIX_ をつけたもの
+func envconfigOptions(fset *token.FileSet, named *types.Named, prefix string) []Option {
+	st := named.Underlying().(*types.Struct)
+	var opts []Option
+	for i := 0; i < st.NumFields(); i++ {
+		field := st.Field(i)
+		tag := reflect.StructTag(st.Tag(i))
+		if !field.Exported() || tag.Get("ignored") == "true" {
+			continue
+		}
+		name := tag.Get("envconfig")
+		if name == "" {
+			name = strings.ToUpper(field.Name())
+		}
+		if prefix != "" {
+			name = strings.ToUpper(prefix) + "_" + name
+		}
+		required, _ := strconv.ParseBool(tag.Get("required"))
+		opts = append(opts, Option{
+			Kind:     "envconfig",
+			Name:     name,
+			Type:     types.TypeString(field.Type(), types.RelativeTo(named.Obj().Pkg())),
+			Default:  tag.Get("default"),
+			Usage:    tag.Get("desc"),
+			Required: required,
+			Pos:      fset.Position(field.Pos()),
+		})
+	}
+	return opts
+}
+
+func writeOptions(w io.Writer, opts []Option) error {
+	for _, o := range opts {
+		name := o.Name
+		switch {
+		case o.Kind == "envconfig":
+		case o.Shorthand != "":
+			name = "-" + o.Shorthand + ", --" + name
+		case o.Kind == "pflag":
+			name = "--" + name
+		default:
+			name = "-" + name
+		}
+		var extra []string
+		if o.Default != "" {
+			extra = append(extra, "default "+o.Default)
+		}
+		if o.Required {
+			extra = append(extra, "required")
+		}
+		detail := ""
+		if len(extra) > 0 {
+			detail = " (" + strings.Join(extra, ", ") + ")"
+		}
+		fmt.Fprintf(w, "%-9s %s %s%s\t%s\n", o.Kind, name, o.Type, detail, o.Usage)
+	}
+	return nil
+}
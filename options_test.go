@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var testdata_options = `package main
+
+import (
+	"flag"
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+)
+
+type Config struct {
+	Port    int    ` + "`envconfig:\"PORT\" default:\"8080\" desc:\"listen port\"`" + `
+	DSN     string ` + "`required:\"true\"`" + `
+	Debug   bool
+	secret  string
+	Ignored string ` + "`ignored:\"true\"`" + `
+}
+
+func main() {
+	verbose := flag.Bool("v", false, "verbose output")
+	var timeout time.Duration
+	flag.DurationVar(&timeout, "timeout", 5*time.Second, "request timeout")
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", ":8080", "listen address")
+	fs.Func("header", "add a request header", func(string) error { return nil })
+
+	var cfg Config
+	envconfig.Process("myapp", &cfg)
+	_, _ = verbose, addr
+}
+`
+
+func TestOptions(t *testing.T) {
+	prog := loadTestProgram(t, map[string]string{
+		"go.mod": `module example.com/m
+
+go 1.22
+
+require github.com/kelseyhightower/envconfig v0.0.0
+
+replace github.com/kelseyhightower/envconfig => ./third_party/envconfig
+`,
+		"third_party/envconfig/go.mod":       "module github.com/kelseyhightower/envconfig\n\ngo 1.22\n",
+		"third_party/envconfig/envconfig.go": "package envconfig\n\nfunc Process(prefix string, spec interface{}) error { return nil }\n",
+		"main.go":                            testdata_options,
+	})
+	var buf bytes.Buffer
+	if err := writeOptions(&buf, options(prog.Packages)); err != nil {
+		t.Fatal(err)
+	}
+	assertLines(t, strings.Split(strings.TrimSpace(buf.String()), "\n"), []string{
+		"envconfig MYAPP_DEBUG bool\t",
+		"envconfig MYAPP_DSN string (required)\t",
+		"envconfig MYAPP_PORT int (default 8080)\tlisten port",
+		"flag      -addr string (default :8080)\tlisten address",
+		"flag      -header func\tadd a request header",
+		"flag      -timeout time.Duration (default 5 * time.Second)\trequest timeout",
+		"flag      -v bool (default false)\tverbose output",
+	})
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/token"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/ssa"
+)
+
+// panic が伝わった先の終わり方
+const (
+	panicRecovered = "recovered" // 遅延した関数の recover で回復する
+	panicGoroutine = "goroutine" // go 文で起動した goroutine の外には伝わらず、プログラムが落ちる
+	panicEntry     = "entry"     // 回復しないままエントリポイントから出る
+	panicUncalled  = "uncalled"  // 回復しないまま、呼び出し元のない (エントリポイントでもない) 関数から出る
+)
+
+// PanicReport は panic と recover の箇所と、panic の伝わる経路
+type PanicReport struct {
+	Sites       []PanicSite  `json:"sites"`
+	Unprotected []EntryPanic `json:"unprotected"` // 回復する関数を通らずに panic に到達するエントリポイント
+}
+
+// PanicSite は 1 つの panic か recover の呼び出し
+type PanicSite struct {
+	Kind     string         `json:"kind"` // "panic" か "recover"
+	Func     string         `json:"func"`
+	Pos      token.Position `json:"pos"`
+	Deferred bool           `json:"deferred,omitempty"` // recover が遅延した関数から直接呼ばれる (効果がある)
+	Paths    []PanicPath    `json:"paths,omitempty"`    // panic が呼び出し元をさかのぼって伝わる経路。終わり方ごとに最短のもの
+}
+
+// PanicPath は panic が伝わる 1 つの経路
+type PanicPath struct {
+	Calls []string       `json:"calls"` // panic を起こす関数から呼び出し元へ向かう順
+	End   string         `json:"end"`
+	By    string         `json:"by,omitempty"` // recover する関数を遅延させている関数か、エントリポイントの種類
+	Pos   token.Position `json:"pos"`          // goroutine なら go 文の位置
+}
+
+// EntryPanic は回復する関数を通らずに panic に到達するエントリポイント
+type EntryPanic struct {
+	Kind   string         `json:"kind"`
+	Name   string         `json:"name"`
+	Panics int            `json:"panics"` // 到達する panic の箇所の数
+	First  token.Position `json:"first"`  // 到達する panic のうち位置の早いもの
+}
+
+func runPanicFlow(args []string) error {
+	fs := flag.NewFlagSet("panicflow", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output as JSON")
+	fs.Parse(args)
+	prog, err := loadProgram(".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	report := panicTopology(prog)
+	relativizePositions(&report)
+	if *asJSON {
+		return writeJSON(os.Stdout, report)
+	}
+	return writePanicFlow(os.Stdout, report)
+}
+
+// panicTopology は解析対象の関数の panic と recover を位置の順に並べ、panic ごとに呼び出し元をさかのぼって、
+// 遅延した関数で recover する関数、goroutine の起点、エントリポイントのどれに行き着くかを求める。
+// 呼び出しのどの経路をたどっても回復する関数を通らずに panic に到達するエントリポイントも返す
+func panicTopology(prog *Program) PanicReport {
+	entries := make(map[*ssa.Function]string)
+	for _, e := range EntryPoints(prog.Packages) {
+		if fn := prog.SSA().FuncValue(e.Func); fn != nil {
+			entries[fn] = e.Kind
+		}
+	}
+	var report PanicReport
+	panics := make(map[*ssa.Function][]token.Pos)
+	for _, fn := range prog.targetFunctions() {
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				switch instr := instr.(type) {
+				case *ssa.Panic:
+					if !instr.Pos().IsValid() {
+						continue
+					}
+					panics[fn] = append(panics[fn], instr.Pos())
+					report.Sites = append(report.Sites, PanicSite{Kind: "panic", Func: fn.String(), Pos: prog.Fset.Position(instr.Pos()),
+						Paths: panicPaths(prog, fn, entries)})
+				case *ssa.Call:
+					if b, ok := instr.Call.Value.(*ssa.Builtin); ok && b.Name() == "recover" {
+						report.Sites = append(report.Sites, PanicSite{Kind: "recover", Func: fn.String(), Pos: prog.Fset.Position(instr.Pos()),
+							Deferred: isDeferred(prog, fn)})
+					}
+				}
+			}
+		}
+	}
+	sort.SliceStable(report.Sites, func(i, j int) bool {
+		a, b := report.Sites[i].Pos, report.Sites[j].Pos
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Offset < b.Offset
+	})
+	for _, e := range EntryPoints(prog.Packages) {
+		fn := prog.SSA().FuncValue(e.Func)
+		if fn == nil {
+			continue
+		}
+		var reached []token.Pos
+		for f := range unrecoveredReach(prog, fn) {
+			reached = append(reached, panics[f]...)
+		}
+		if len(reached) == 0 {
+			continue
+		}
+		first := reached[0]
+		for _, p := range reached {
+			if a, b := prog.Fset.Position(p), prog.Fset.Position(first); a.Filename < b.Filename || a.Filename == b.Filename && a.Offset < b.Offset {
+				first = p
+			}
+		}
+		report.Unprotected = append(report.Unprotected, EntryPanic{Kind: e.Kind, Name: e.Name, Panics: len(reached), First: prog.Fset.Position(first)})
+	}
+	return report
+}
+
+// isDeferred は fn がどこかで defer で呼ばれるかどうかを返す
+func isDeferred(prog *Program, fn *ssa.Function) bool {
+	if node := prog.CallGraph().Nodes[fn]; node != nil {
+		for _, e := range node.In {
+			if _, ok := e.Site.(*ssa.Defer); ok && e.Site.Common().StaticCallee() == fn {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// panicPaths は fn で起きた panic が呼び出し元をさかのぼって行き着く先を、終わり方ごとに最短の経路で、終わり方と経路の順に返す。
+// go 文で起動された関数からは呼び出し元に伝わらない。解析対象の外の呼び出し元はたどらない
+func panicPaths(prog *Program, fn *ssa.Function, entries map[*ssa.Function]string) []PanicPath {
+	cg := prog.CallGraph()
+	next := make(map[*ssa.Function]*ssa.Function) // 呼び出し元から、panic が伝わってきた関数
+	next[fn] = nil
+	var paths []PanicPath
+	seen := make(map[string]bool)
+	end := func(f *ssa.Function, p PanicPath) {
+		key := p.End + p.By + p.Pos.String()
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		for ; f != nil; f = next[f] {
+			p.Calls = append([]string{f.String()}, p.Calls...)
+		}
+		paths = append(paths, p)
+	}
+	queue := []*ssa.Function{fn}
+	for len(queue) > 0 {
+		f := queue[0]
+		queue = queue[1:]
+		if recovers(f) {
+			end(f, PanicPath{End: panicRecovered, By: f.String()})
+			continue
+		}
+		if kind, ok := entries[f]; ok {
+			end(f, PanicPath{End: panicEntry, By: kind})
+		}
+		var in []*callgraph.Edge
+		if node := cg.Nodes[f]; node != nil {
+			in = node.In
+		}
+		called := false
+		for _, e := range in {
+			if e.Site == nil {
+				continue
+			}
+			// 関数値の呼び出しは CHA では同じシグネチャの全関数につながるので、go 文は静的なものだけを見る
+			if g, ok := e.Site.(*ssa.Go); ok {
+				if g.Call.StaticCallee() == f {
+					called = true
+					end(f, PanicPath{End: panicGoroutine, Pos: prog.Fset.Position(g.Pos())})
+				}
+				continue
+			}
+			if caller := e.Caller.Func; caller.Pkg == nil || !prog.isTarget(caller.Pkg.Pkg) {
+				continue
+			}
+			called = true
+			if _, ok := next[e.Caller.Func]; !ok {
+				next[e.Caller.Func] = f
+				queue = append(queue, e.Caller.Func)
+			}
+		}
+		if _, ok := entries[f]; !ok && !called {
+			end(f, PanicPath{End: panicUncalled})
+		}
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if paths[i].End != paths[j].End {
+			return paths[i].End < paths[j].End
+		}
+		return strings.Join(paths[i].Calls, " ") < strings.Join(paths[j].Calls, " ")
+	})
+	return paths
+}
+
+// unrecoveredReach は fn から静的な呼び出しとインターフェースのメソッドの呼び出しをたどり、
+// 回復する関数を通らずに到達する関数を返す。fn が回復する関数なら空を返す
+func unrecoveredReach(prog *Program, fn *ssa.Function) map[*ssa.Function]bool {
+	cg := prog.CallGraph()
+	reached := make(map[*ssa.Function]bool)
+	queue := []*ssa.Function{fn}
+	for len(queue) > 0 {
+		f := queue[0]
+		queue = queue[1:]
+		if reached[f] || recovers(f) {
+			continue
+		}
+		reached[f] = true
+		if node := cg.Nodes[f]; node != nil {
+			for _, e := range node.Out {
+				if _, isGo := e.Site.(*ssa.Go); !isGo && (e.Site.Common().IsInvoke() || e.Site.Common().StaticCallee() != nil) {
+					queue = append(queue, e.Callee.Func)
+				}
+			}
+		}
+	}
+	return reached
+}
+
+func writePanicFlow(w io.Writer, report PanicReport) error {
+	for _, s := range report.Sites {
+		if s.Kind == "recover" {
+			note := "deferred"
+			if !s.Deferred {
+				note = "not called from a deferred function: has no effect"
+			}
+			fmt.Fprintf(w, "recover %s:%d %s (%s)\n", s.Pos.Filename, s.Pos.Line, s.Func, note)
+			continue
+		}
+		fmt.Fprintf(w, "panic   %s:%d %s\n", s.Pos.Filename, s.Pos.Line, s.Func)
+		for _, p := range s.Paths {
+			calls := strings.Join(p.Calls, " <- ")
+			switch p.End {
+			case panicRecovered:
+				fmt.Fprintf(w, "        recovered by %s: %s\n", p.By, calls)
+			case panicGoroutine:
+				fmt.Fprintf(w, "        crashes the goroutine started at %s:%d: %s\n", p.Pos.Filename, p.Pos.Line, calls)
+			case panicEntry:
+				fmt.Fprintf(w, "        leaves %s entry point: %s\n", p.By, calls)
+			default:
+				fmt.Fprintf(w, "        leaves uncalled function: %s\n", calls)
+			}
+		}
+	}
+	if len(report.Unprotected) > 0 {
+		fmt.Fprintln(w, "\nentry points reaching a panic without a recover:")
+	}
+	for _, e := range report.Unprotected {
+		fmt.Fprintf(w, "%-9s %s: %d panics, first at %s:%d\n", e.Kind, e.Name, e.Panics, e.First.Filename, e.First.Line)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPanicTopology(t *testing.T) {
+	src := `package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+func must(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
+
+func safe() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("recovered: %v", r)
+		}
+	}()
+	must(errors.New("boom"))
+	return nil
+}
+
+func ignored() {
+	recover()
+}
+
+func worker() {
+	must(nil)
+}
+
+func handle(w http.ResponseWriter, r *http.Request) {
+	must(nil)
+}
+
+func main() {
+	http.HandleFunc("/", handle)
+	go worker()
+	safe()
+	ignored()
+}
+`
+	prog := loadTestProgram(t, map[string]string{"main.go": src})
+	report := panicTopology(prog)
+	for i := range report.Sites {
+		s := &report.Sites[i]
+		s.Pos.Filename = filepath.Base(s.Pos.Filename)
+		for j := range s.Paths {
+			s.Paths[j].Pos.Filename = filepath.Base(s.Paths[j].Pos.Filename)
+		}
+	}
+	for i := range report.Unprotected {
+		report.Unprotected[i].First.Filename = filepath.Base(report.Unprotected[i].First.Filename)
+	}
+	var buf bytes.Buffer
+	if err := writePanicFlow(&buf, report); err != nil {
+		t.Fatal(err)
+	}
+	assertLines(t, strings.Split(strings.TrimSpace(buf.String()), "\n"), []string{
+		"panic   main.go:11 example.com/m.must",
+		"        leaves http entry point: example.com/m.must <- example.com/m.handle",
+		"        crashes the goroutine started at main.go:39: example.com/m.must <- example.com/m.worker",
+		"        recovered by example.com/m.safe: example.com/m.must <- example.com/m.safe",
+		"recover main.go:17 example.com/m.safe$1 (deferred)",
+		"recover main.go:26 example.com/m.ignored (not called from a deferred function: has no effect)",
+		"",
+		"entry points reaching a panic without a recover:",
+		"http      example.com/m.handle: 1 panics, first at main.go:11",
+	})
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// PanicInfo は panic しうる関数と、その例となる経路
+type PanicInfo struct {
+	Func   string         `json:"func"`
+	Reason string         `json:"reason"`
+	Pos    token.Position `json:"pos"`  // panic しうる操作の位置
+	Path   []string       `json:"path"` // Func から panic しうる操作を含む関数までの呼び出し経路
+}
+
+func runPanics(args []string) error {
+	fs := flag.NewFlagSet("panics", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output as JSON")
+	fs.Parse(args)
+	prog, err := loadProgram(".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	var result []PanicInfo
+	panics := mayPanic(prog)
+	for _, fn := range prog.targetFunctions() {
+		if info, ok := panics[fn]; ok {
+			result = append(result, *info)
+		}
+	}
+	relativizePositions(result)
+	if *asJSON {
+		return writeJSON(os.Stdout, result)
+	}
+	return writePanics(os.Stdout, result)
+}
+
+// mayPanic は panic しうる関数を返す。明示的な panic、範囲外アクセス、nil 参照などを
+// 直接含む関数と、それらを (recover せずに) 呼び出している関数が対象
+func mayPanic(prog *Program) map[*ssa.Function]*PanicInfo {
+	fns := prog.targetFunctions()
+	panics := make(map[*ssa.Function]*PanicInfo)
+	for _, fn := range fns {
+		if reason, pos := panicInstr(fn); reason != "" {
+			panics[fn] = &PanicInfo{
+				Func:   fn.RelString(nil),
+				Reason: reason,
+				Pos:    prog.Fset.Position(pos),
+				Path:   []string{fn.RelString(nil)},
+			}
+		}
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for _, fn := range fns {
+			if _, ok := panics[fn]; ok || recovers(fn) {
+				continue
+			}
+			for _, callee := range staticCallees(fn) {
+				info, ok := panics[callee]
+				if !ok {
+					continue
+				}
+				panics[fn] = &PanicInfo{
+					Func:   fn.RelString(nil),
+					Reason: info.Reason,
+					Pos:    info.Pos,
+					Path:   append([]string{fn.RelString(nil)}, info.Path...),
+				}
+				changed = true
+				break
+			}
+		}
+	}
+	return panics
+}
+
+// panicInstr は fn の中で最初に見つかった panic しうる操作とその位置を返す
+func panicInstr(fn *ssa.Function) (string, token.Pos) {
+	if recovers(fn) {
+		return "", token.NoPos
+	}
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			switch instr := instr.(type) {
+			case *ssa.Panic:
+				return "explicit panic", instr.Pos()
+			case *ssa.IndexAddr:
+				if !isConstIndexIntoArray(instr.X.Type(), instr.Index) {
+					return "index out of range", instr.Pos()
+				}
+			case *ssa.Index:
+				if !isConstIndexIntoArray(instr.X.Type(), instr.Index) {
+					return "index out of range", instr.Pos()
+				}
+			case *ssa.Slice:
+				if instr.Low != nil || instr.High != nil || instr.Max != nil {
+					return "slice bounds out of range", instr.Pos()
+				}
+			case *ssa.MapUpdate:
+				if _, ok := instr.Map.(*ssa.MakeMap); !ok {
+					return "assignment to entry in possibly nil map", instr.Pos()
+				}
+			case *ssa.TypeAssert:
+				if !instr.CommaOk {
+					return "type assertion without comma-ok", instr.Pos()
+				}
+			case *ssa.BinOp:
+				if (instr.Op == token.QUO || instr.Op == token.REM) && isInteger(instr.Y.Type()) {
+					if _, ok := instr.Y.(*ssa.Const); !ok {
+						return "integer division by non-constant divisor", instr.Pos()
+					}
+				}
+			}
+		}
+	}
+	// 参照外しは、nilness と同じく nil になりうる経路のあるポインタだけを数える。
+	// 引数やレシーバの参照外しをすべて数えると、ほとんどのメソッドが panic しうることになる
+	nilPos := token.NoPos
+	walkNilDerefs(fn.Prog.Fset, fn, func(pos token.Pos, _ string, _ ...interface{}) {
+		if !nilPos.IsValid() {
+			nilPos = pos
+		}
+	})
+	if nilPos.IsValid() {
+		return "possible nil pointer dereference", nilPos
+	}
+	return "", token.NoPos
+}
+
+// recovers は fn が recover を呼び出す関数を defer しているかどうかを返す
+func recovers(fn *ssa.Function) bool {
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			d, ok := instr.(*ssa.Defer)
+			if !ok {
+				continue
+			}
+			callee := d.Call.StaticCallee()
+			if callee == nil {
+				continue
+			}
+			for _, cb := range callee.Blocks {
+				for _, ci := range cb.Instrs {
+					if call, ok := ci.(*ssa.Call); ok {
+						if builtin, ok := call.Call.Value.(*ssa.Builtin); ok && builtin.Name() == "recover" {
+							return true
+						}
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+// isConstIndexIntoArray は配列 (へのポインタ) を定数で添字アクセスしているかどうかを返す (コンパイル時に検査済み)
+func isConstIndexIntoArray(t types.Type, index ssa.Value) bool {
+	if _, ok := index.(*ssa.Const); !ok {
+		return false
+	}
+	if ptr, ok := t.Underlying().(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	_, ok := t.Underlying().(*types.Array)
+	return ok
+}
+
+func isInteger(t types.Type) bool {
+	basic, ok := t.Underlying().(*types.Basic)
+	return ok && basic.Info()&types.IsInteger != 0
+}
+
+// writePanics は結果をテキストで出力する
+func writePanics(w io.Writer, result []PanicInfo) error {
+	for _, p := range result {
+		fmt.Fprintf(w, "%s: %s\n", p.Func, p.Reason)
+		fmt.Fprintf(w, "  at %s\n", p.Pos)
+		if len(p.Path) > 1 {
+			fmt.Fprintf(w, "  via %s\n", strings.Join(p.Path, " -> "))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMayPanic(t *testing.T) {
+	src := `package main
+
+import "errors"
+
+type Calculator struct {
+	base int
+}
+
+func div(a, b int) int {
+	return a / b
+}
+
+func half(a int) int {
+	return a / 2
+}
+
+func first(s []int) int {
+	return s[0]
+}
+
+func must(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
+
+func safe() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New("recovered")
+		}
+	}()
+	must(errors.New("boom"))
+	return nil
+}
+
+func (c *Calculator) add(v int) int {
+	return v + c.base
+}
+
+func pick(flag bool) int {
+	var c *Calculator
+	if flag {
+		c = &Calculator{}
+	}
+	return c.base
+}
+
+func calc(v int) int {
+	return half(div(v, 3))
+}
+
+func main() {
+	c := &Calculator{}
+	_ = c.add(calc(1))
+	_ = first([]int{1})
+	_ = safe()
+	_ = pick(true)
+}
+`
+	prog := loadTestProgram(t, map[string]string{"main.go": src})
+	panics := mayPanic(prog)
+	var result []PanicInfo
+	for _, fn := range prog.targetFunctions() {
+		if info, ok := panics[fn]; ok {
+			info.Pos.Filename = filepath.Base(info.Pos.Filename)
+			result = append(result, *info)
+		}
+	}
+	var buf bytes.Buffer
+	if err := writePanics(&buf, result); err != nil {
+		t.Fatal(err)
+	}
+	assertLines(t, strings.Split(strings.TrimSpace(buf.String()), "\n"), []string{
+		"example.com/m.div: integer division by non-constant divisor",
+		"  at main.go:10:11",
+		"example.com/m.first: index out of range",
+		"  at main.go:18:10",
+		"example.com/m.must: explicit panic",
+		"  at main.go:23:8",
+		"example.com/m.pick: possible nil pointer dereference",
+		"  at main.go:46:11",
+		"example.com/m.calc: integer division by non-constant divisor",
+		"  at main.go:10:11",
+		"  via example.com/m.calc -> example.com/m.div",
+		"example.com/m.main: integer division by non-constant divisor",
+		"  at main.go:10:11",
+		"  via example.com/m.main -> example.com/m.calc -> example.com/m.div",
+	})
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// paramFunc は引数と戻り値の使われ方を調べる関数の宣言
+type paramFunc struct {
+	decl     *ast.FuncDecl
+	info     *types.Info
+	external bool // ほかのモジュールから呼ばれうる
+}
+
+// paramCallSite は関数の呼び出し箇所と、各戻り値が捨てられているかどうか
+type paramCallSite struct {
+	call    *ast.CallExpr
+	info    *types.Info
+	ignored []bool
+}
+
+// checkParams は関数 (メソッドを除く) の引数と戻り値の使われ方を調べ、次のものを指摘する
+//   - 本体で使われていない引数
+//   - すべての呼び出し元で捨てられている戻り値
+//   - すべての呼び出し元で同じ定数が渡されている bool の引数
+//
+// 関数が値として使われている場合はシグネチャを変えられないので対象にしない。
+// 戻り値と bool の引数は、package main 以外のエクスポートされた関数を対象にしない
+func checkParams(prog *Program) []Diagnostic {
+	funcs := make(map[*types.Func]paramFunc)
+	calls := make(map[*types.Func][]paramCallSite)
+	asValue := make(map[*types.Func]bool)
+	for _, pkg := range prog.Packages {
+		info := pkg.TypesInfo
+		for _, file := range pkg.Syntax {
+			for _, decl := range file.Decls {
+				fd, ok := decl.(*ast.FuncDecl)
+				if !ok || fd.Recv != nil || fd.Body == nil || fd.Name.Name == "main" || fd.Name.Name == "init" {
+					continue
+				}
+				if fn, ok := info.Defs[fd.Name].(*types.Func); ok {
+					funcs[fn] = paramFunc{fd, info, pkg.Name != "main" && fn.Exported()}
+				}
+			}
+			// 文として呼び出された関数の戻り値と、_ に代入された戻り値は捨てられている
+			ignored := make(map[*ast.CallExpr][]bool)
+			ignoreAll := func(call *ast.CallExpr) {
+				ignored[call] = []bool{}
+			}
+			ignoreBlank := func(expr ast.Expr, names []*ast.Ident) {
+				call, ok := ast.Unparen(expr).(*ast.CallExpr)
+				if !ok {
+					return
+				}
+				for _, name := range names {
+					ignored[call] = append(ignored[call], name != nil && name.Name == "_")
+				}
+			}
+			callees := make(map[*ast.Ident]bool)
+			ast.Inspect(file, func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.ExprStmt:
+					if call, ok := ast.Unparen(n.X).(*ast.CallExpr); ok {
+						ignoreAll(call)
+					}
+				case *ast.GoStmt:
+					ignoreAll(n.Call)
+				case *ast.DeferStmt:
+					ignoreAll(n.Call)
+				case *ast.AssignStmt:
+					if len(n.Rhs) == 1 {
+						var names []*ast.Ident
+						for _, lhs := range n.Lhs {
+							id, _ := lhs.(*ast.Ident)
+							names = append(names, id)
+						}
+						ignoreBlank(n.Rhs[0], names)
+					}
+				case *ast.ValueSpec:
+					if len(n.Values) == 1 {
+						ignoreBlank(n.Values[0], n.Names)
+					}
+				case *ast.CallExpr:
+					fun := ast.Unparen(n.Fun)
+					if index, ok := fun.(*ast.IndexExpr); ok {
+						fun = index.X
+					}
+					switch fun := fun.(type) {
+					case *ast.Ident:
+						callees[fun] = true
+					case *ast.SelectorExpr:
+						callees[fun.Sel] = true
+					}
+					fn, ok := typeutil.Callee(info, n).(*types.Func)
+					if !ok {
+						break
+					}
+					results := fn.Type().(*types.Signature).Results().Len()
+					site := paramCallSite{call: n, info: info, ignored: make([]bool, results)}
+					if marks, ok := ignored[n]; ok {
+						for i := range site.ignored {
+							site.ignored[i] = len(marks) == 0 || i < len(marks) && marks[i]
+						}
+					}
+					calls[fn] = append(calls[fn], site)
+				}
+				return true
+			})
+			for id, obj := range info.Uses {
+				if fn, ok := obj.(*types.Func); ok && !callees[id] {
+					asValue[fn] = true
+				}
+			}
+		}
+	}
+	var diags []Diagnostic
+	for fn, f := range funcs {
+		if asValue[fn] {
+			continue
+		}
+		for _, field := range f.decl.Type.Params.List {
+			for _, name := range field.Names {
+				if name.Name != "_" && !usesObject(f.info, f.decl.Body, f.info.Defs[name]) {
+					diags = append(diags, newDiagnostic(prog.Fset, name.Pos(), "params",
+						"parameter %s of %s is never used", name.Name, fn.Name()))
+				}
+			}
+		}
+		sites := calls[fn]
+		if f.external || len(sites) == 0 {
+			continue
+		}
+		sig := fn.Type().(*types.Signature)
+		for i := 0; i < sig.Results().Len(); i++ {
+			ignored := true
+			for _, s := range sites {
+				ignored = ignored && s.ignored[i]
+			}
+			if ignored {
+				diags = append(diags, newDiagnostic(prog.Fset, f.decl.Name.Pos(), "params",
+					"result %d (%s) of %s is ignored by all %d callers", i, types.TypeString(sig.Results().At(i).Type(), nil), fn.Name(), len(sites)))
+			}
+		}
+		for i := 0; i < sig.Params().Len(); i++ {
+			param := sig.Params().At(i)
+			if sig.Variadic() && i == sig.Params().Len()-1 || !types.Identical(param.Type().Underlying(), types.Typ[types.Bool]) {
+				continue
+			}
+			var value constant.Value
+			for _, s := range sites {
+				tv := s.info.Types[s.call.Args[i]]
+				if tv.Value == nil || value != nil && !constant.Compare(value, token.EQL, tv.Value) {
+					value = nil
+					break
+				}
+				value = tv.Value
+			}
+			if value != nil {
+				diags = append(diags, newDiagnostic(prog.Fset, param.Pos(), "params",
+					"bool parameter %s of %s is always %s in all %d calls", param.Name(), fn.Name(), value, len(sites)))
+			}
+		}
+	}
+	sortDiagnostics(diags)
+	return diags
+}
+
+// usesObject は node の中で obj が参照されているかどうかを返す
+func usesObject(info *types.Info, node ast.Node, obj types.Object) bool {
+	used := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && info.Uses[id] == obj {
+			used = true
+		}
+		return !used
+	})
+	return used
+}
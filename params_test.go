@@ -0,0 +1,40 @@
+package main
+
+import "testing"
+
+func TestCheckParams(t *testing.T) {
+	src := `package main
+
+import "fmt"
+
+func save(name string, verbose bool) error {
+	if verbose {
+		fmt.Println("saving")
+	}
+	return nil
+}
+
+func load(path string, _ int, unused string) (string, error) {
+	return path, nil
+}
+
+func handler(id int) {}
+
+func main() {
+	save("a", true)
+	_ = save("b", true)
+	s, _ := load("p", 1, "")
+	defer save(s, true)
+	f := handler
+	f(1)
+}
+`
+	prog := loadTestProgram(t, map[string]string{"main.go": src})
+	assertLines(t, diagnosticMessages(checkParams(prog)), []string{
+		"main.go:5: result 0 (error) of save is ignored by all 3 callers",
+		"main.go:5: parameter name of save is never used",
+		"main.go:5: bool parameter verbose of save is always true in all 3 calls",
+		"main.go:12: result 1 (error) of load is ignored by all 1 callers",
+		"main.go:12: parameter unused of load is never used",
+	})
+}
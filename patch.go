@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"strings"
+	"text/template"
+
+	"golang.org/x/tools/imports"
+)
+
+// コードを挿入する構造上の位置
+const (
+	insertAfterImports = "after-imports" // 最後の import 宣言 (なければ package 句) の後
+	insertAfterType    = "after-type"    // 型の宣言の後
+	insertFuncEnd      = "func-end"      // 関数の本体の最後 (最後の文が return か、結果のある関数ならその前)
+)
+
+// InsertPoint はコードを挿入する位置。Name は after-type の型名か func-end の関数名 (メソッドは T.M)
+type InsertPoint struct {
+	Kind string
+	Name string
+}
+
+func runInsert(args []string) error {
+	fs := flag.NewFlagSet("insert", flag.ExitOnError)
+	filename := fs.String("file", "", "Go source `file` to patch")
+	at := fs.String("at", "", "`location`: after-imports, after-type:T or func-end:F (T.M for methods)")
+	tmplFile := fs.String("template", "", "text/template `file` rendering the code to insert")
+	data := fs.String("data", "{}", "template data as a JSON object")
+	write := fs.Bool("w", false, "write the result to the file instead of stdout")
+	fs.Parse(args)
+	if *filename == "" || *at == "" || *tmplFile == "" {
+		return errors.New("-file, -at and -template are required")
+	}
+	point, err := parseInsertPoint(*at)
+	if err != nil {
+		return err
+	}
+	src, err := os.ReadFile(*filename)
+	if err != nil {
+		return err
+	}
+	tmpl, err := os.ReadFile(*tmplFile)
+	if err != nil {
+		return err
+	}
+	var values map[string]interface{}
+	if err := json.Unmarshal([]byte(*data), &values); err != nil {
+		return fmt.Errorf("-data: %v", err)
+	}
+	out, err := InsertTemplate(*filename, src, point, string(tmpl), values)
+	if err != nil {
+		return err
+	}
+	if *write {
+		return os.WriteFile(*filename, out, 0o644)
+	}
+	_, err = os.Stdout.Write(out)
+	return err
+}
+
+// parseInsertPoint は "after-imports", "after-type:T", "func-end:F" の形の位置を読む
+func parseInsertPoint(s string) (InsertPoint, error) {
+	kind, name, _ := strings.Cut(s, ":")
+	switch kind {
+	case insertAfterImports:
+		if name != "" {
+			return InsertPoint{}, fmt.Errorf("%s takes no name: %q", kind, s)
+		}
+	case insertAfterType, insertFuncEnd:
+		if name == "" {
+			return InsertPoint{}, fmt.Errorf("%s needs a name: %q", kind, s)
+		}
+	default:
+		return InsertPoint{}, fmt.Errorf("unknown location %q", s)
+	}
+	return InsertPoint{Kind: kind, Name: name}, nil
+}
+
+// InsertTemplate は tmpl を data で展開したコードを src の at の位置に挿入し、gofmt をかけて import を
+// 整えたソースを返す。after-imports と after-type には宣言を、func-end には文を挿入する
+func InsertTemplate(filename string, src []byte, at InsertPoint, tmpl string, data interface{}) ([]byte, error) {
+	t, err := template.New("insert").Parse(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	var code bytes.Buffer
+	if err := t.Execute(&code, data); err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, 0)
+	if err != nil {
+		return nil, parseError(err)
+	}
+	pos, err := insertPos(file, at)
+	if err != nil {
+		return nil, err
+	}
+	offset := fset.Position(pos).Offset
+	text := strings.TrimSpace(code.String())
+	var out bytes.Buffer
+	out.Write(src[:offset])
+	if at.Kind == insertFuncEnd {
+		// 1 行に書かれた本体では前の文と同じ行にならないように改行する
+		if line := bytes.TrimRight(src[:offset], " \t"); len(line) > 0 && line[len(line)-1] != '\n' {
+			out.WriteString("\n")
+		}
+		out.WriteString(text + "\n")
+	} else {
+		out.WriteString("\n\n" + text + "\n")
+	}
+	out.Write(src[offset:])
+	result, err := imports.Process(filename, out.Bytes(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("inserted code at %s: %w", at.Kind, parseError(err))
+	}
+	return result, nil
+}
+
+// insertPos は file の中で at の位置のコードを挿入する位置を返す
+func insertPos(file *ast.File, at InsertPoint) (token.Pos, error) {
+	switch at.Kind {
+	case insertAfterImports:
+		pos := file.Name.End()
+		for _, decl := range file.Decls {
+			if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+				pos = gen.End()
+			}
+		}
+		return pos, nil
+	case insertAfterType:
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				if spec.(*ast.TypeSpec).Name.Name == at.Name {
+					return gen.End(), nil
+				}
+			}
+		}
+		return token.NoPos, notFound("type %s not found", at.Name)
+	case insertFuncEnd:
+		for _, decl := range file.Decls {
+			fd, ok := decl.(*ast.FuncDecl)
+			if !ok || fd.Body == nil || funcDeclName(fd) != at.Name {
+				continue
+			}
+			if n := len(fd.Body.List); n > 0 {
+				last := fd.Body.List[n-1]
+				// 結果のある関数の最後の文は return や無限ループなどの終端文なので、その後には挿入できない
+				if _, ok := last.(*ast.ReturnStmt); ok || fd.Type.Results != nil {
+					return last.Pos(), nil
+				}
+			}
+			return fd.Body.Rbrace, nil
+		}
+		return token.NoPos, notFound("function %s not found", at.Name)
+	}
+	return token.NoPos, fmt.Errorf("unknown location %q", at.Kind)
+}
+
+// funcDeclName は関数の名前を返す。メソッドなら T.M (ポインタの * と型引数は付けない)
+func funcDeclName(fd *ast.FuncDecl) string {
+	if fd.Recv == nil || len(fd.Recv.List) == 0 {
+		return fd.Name.Name
+	}
+	t := fd.Recv.List[0].Type
+	if star, ok := t.(*ast.StarExpr); ok {
+		t = star.X
+	}
+	switch x := t.(type) {
+	case *ast.IndexExpr:
+		t = x.X
+	case *ast.IndexListExpr:
+		t = x.X
+	}
+	if id, ok := t.(*ast.Ident); ok {
+		return id.Name + "." + fd.Name.Name
+	}
+	return fd.Name.Name
+}
+
+// formatFile は編集した file を gofmt の形にして import を整え、ファイル名と内容を返す
+func formatFile(fset *token.FileSet, file *ast.File) (string, []byte, error) {
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, file); err != nil {
+		return "", nil, err
+	}
+	name := fset.Position(file.Pos()).Filename
+	src, err := imports.Process(name, buf.Bytes(), nil)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %w", name, parseError(err))
+	}
+	return name, src, nil
+}
+
+// writeFiles は files (ファイル名から内容) をファイルに書き込む
+func writeFiles(files map[string][]byte) error {
+	for name, src := range files {
+		if err := os.WriteFile(name, src, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInsertTemplate(t *testing.T) {
+	src := `package main
+
+import "fmt"
+
+type server struct {
+	name string
+}
+
+func (s *server) run() error {
+	fmt.Println(s.name)
+	return nil
+}
+
+func main() {
+	s := &server{}
+	s.run()
+}
+`
+	tests := []struct {
+		at   string
+		tmpl string
+		want []string
+	}{
+		{
+			at:   "after-imports",
+			tmpl: `var default{{.Name}} = {{printf "%q" .Name}}`,
+			want: []string{`import "fmt"`, ``, `var defaultaddr = "addr"`, ``, `type server struct {`},
+		},
+		{
+			at:   "after-type:server",
+			tmpl: `func (s *server) String() string { return strings.ToUpper(s.{{.Name}}) }`,
+			want: []string{`}`, ``, `func (s *server) String() string { return strings.ToUpper(s.addr) }`, ``, `func (s *server) run() error {`},
+		},
+		{
+			at:   "func-end:server.run",
+			tmpl: `log.Printf("stopped %s", s.{{.Name}})`,
+			want: []string{`	fmt.Println(s.name)`, `	log.Printf("stopped %s", s.addr)`, `	return nil`},
+		},
+		{
+			at:   "func-end:main",
+			tmpl: "defer s.run()\n_ = s",
+			want: []string{`	s.run()`, `	defer s.run()`, `	_ = s`, `}`},
+		},
+	}
+	for _, tt := range tests {
+		at, err := parseInsertPoint(tt.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := InsertTemplate("main.go", []byte(src), at, tt.tmpl, map[string]string{"Name": "addr"})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.at, err)
+		}
+		if got := string(out); !strings.Contains(got, strings.Join(tt.want, "\n")) {
+			t.Errorf("%s: got\n%s\nwant it to contain\n%s", tt.at, got, strings.Join(tt.want, "\n"))
+		}
+	}
+
+	// strings と log の import が追加される
+	at, _ := parseInsertPoint("after-type:server")
+	out, err := InsertTemplate("main.go", []byte(src), at, `func (s *server) String() string { return strings.ToUpper(s.name) }`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), "import (\n\t\"fmt\"\n\t\"strings\"\n)") {
+		t.Errorf("missing import of strings:\n%s", out)
+	}
+
+	for _, s := range []string{"after-type:missing", "func-end:server.stop"} {
+		at, _ := parseInsertPoint(s)
+		if _, err := InsertTemplate("main.go", []byte(src), at, "x := 1", nil); err == nil {
+			t.Errorf("%s: want an error", s)
+		}
+	}
+	for _, s := range []string{"before-main", "after-type", "after-imports:x"} {
+		if _, err := parseInsertPoint(s); err == nil {
+			t.Errorf("parseInsertPoint(%q): want an error", s)
+		}
+	}
+	at, _ = parseInsertPoint("after-imports")
+	if _, err := InsertTemplate("main.go", []byte(src), at, "func {", nil); err == nil {
+		t.Errorf("invalid inserted code: want an error")
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// PkgNode はパッケージ依存グラフのパッケージ
+type PkgNode struct {
+	Path    string `json:"path"`
+	Name    string `json:"name"`            // モジュールのルートからの相対パス (ルートは ".")
+	Group   string `json:"group,omitempty"` // ディレクトリの先頭の要素によるグループ
+	Layer   int    `json:"layer"`           // 依存される側ほど大きい層の番号 (グループがあればグループの層)
+	InCycle bool   `json:"inCycle,omitempty"`
+}
+
+// PkgEdge はパッケージの import
+type PkgEdge struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Cycle bool   `json:"cycle,omitempty"` // グループ (グループがなければパッケージ) の循環に含まれる
+}
+
+// PkgGraph は解析対象のパッケージ間の依存グラフ
+type PkgGraph struct {
+	Nodes []PkgNode `json:"nodes"`
+	Edges []PkgEdge `json:"edges"`
+}
+
+func runPkgGraph(args []string) error {
+	fs := flag.NewFlagSet("pkggraph", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output as JSON")
+	groupDepth := fs.Int("group-depth", 1, "number of leading directory elements used to group packages (0 disables grouping)")
+	fs.Parse(args)
+	prog, err := loadProgram(".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	g := packageGraph(prog.Packages, *groupDepth)
+	if *asJSON {
+		return writeJSON(os.Stdout, g)
+	}
+	return writePkgGraphDOT(os.Stdout, g)
+}
+
+// packageGraph は解析対象のパッケージ間の import をグラフにし、groupDepth 個のディレクトリの要素でグループに分ける。
+// グループ (groupDepth が 0 ならパッケージ) の間の循環に印をつけ、依存する側から順に層の番号をつける
+func packageGraph(pkgs []*packages.Package, groupDepth int) *PkgGraph {
+	g := &PkgGraph{}
+	target := make(map[string]bool)
+	for _, pkg := range pkgs {
+		target[pkg.PkgPath] = true
+	}
+	unitOf := make(map[string]string) // パッケージ -> 層と循環を求める単位 (グループまたはパッケージ)
+	for _, pkg := range pkgs {
+		node := PkgNode{Path: pkg.PkgPath, Name: moduleRelPath(pkg)}
+		unitOf[pkg.PkgPath] = pkg.PkgPath
+		if groupDepth > 0 {
+			node.Group = pathPrefix(node.Name, groupDepth)
+			unitOf[pkg.PkgPath] = node.Group
+		}
+		g.Nodes = append(g.Nodes, node)
+		for imp := range pkg.Imports {
+			if target[imp] {
+				g.Edges = append(g.Edges, PkgEdge{From: pkg.PkgPath, To: imp})
+			}
+		}
+	}
+	sort.Slice(g.Nodes, func(i, j int) bool { return g.Nodes[i].Name < g.Nodes[j].Name })
+	sort.Slice(g.Edges, func(i, j int) bool {
+		if g.Edges[i].From != g.Edges[j].From {
+			return g.Edges[i].From < g.Edges[j].From
+		}
+		return g.Edges[i].To < g.Edges[j].To
+	})
+
+	var units []string
+	succs := make(map[string][]string)
+	seen := make(map[string]bool)
+	for _, n := range g.Nodes {
+		if u := unitOf[n.Path]; !seen[u] {
+			seen[u] = true
+			units = append(units, u)
+		}
+	}
+	for _, e := range g.Edges {
+		if from, to := unitOf[e.From], unitOf[e.To]; from != to {
+			succs[from] = append(succs[from], to)
+		}
+	}
+	sccs := stronglyConnected(units, succs)
+	component := make(map[string]int)
+	for i, scc := range sccs {
+		for _, u := range scc {
+			component[u] = i
+		}
+	}
+	// Tarjan のアルゴリズムは依存される側の成分から返すので、逆順にたどると依存する側から順になる
+	layers := make([]int, len(sccs))
+	for i := len(sccs) - 1; i >= 0; i-- {
+		for _, u := range sccs[i] {
+			for _, v := range succs[u] {
+				if c := component[v]; c != i {
+					layers[c] = max(layers[c], layers[i]+1)
+				}
+			}
+		}
+	}
+	for i := range g.Nodes {
+		c := component[unitOf[g.Nodes[i].Path]]
+		g.Nodes[i].Layer = layers[c]
+		g.Nodes[i].InCycle = len(sccs[c]) > 1
+	}
+	for i, e := range g.Edges {
+		from, to := unitOf[e.From], unitOf[e.To]
+		g.Edges[i].Cycle = from != to && component[from] == component[to]
+	}
+	return g
+}
+
+// stronglyConnected は nodes と succs のグラフの強連結成分を Tarjan のアルゴリズムで求める。
+// 成分は依存される側 (後続) から順に返す
+func stronglyConnected(nodes []string, succs map[string][]string) [][]string {
+	index := make(map[string]int)
+	lowlink := make(map[string]int)
+	onStack := make(map[string]bool)
+	var stack []string
+	var sccs [][]string
+	var strongConnect func(n string)
+	strongConnect = func(n string) {
+		index[n] = len(index)
+		lowlink[n] = index[n]
+		stack = append(stack, n)
+		onStack[n] = true
+		for _, m := range succs[n] {
+			if _, visited := index[m]; !visited {
+				strongConnect(m)
+				lowlink[n] = min(lowlink[n], lowlink[m])
+			} else if onStack[m] {
+				lowlink[n] = min(lowlink[n], index[m])
+			}
+		}
+		if lowlink[n] != index[n] {
+			return
+		}
+		var scc []string
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			scc = append(scc, top)
+			if top == n {
+				break
+			}
+		}
+		sort.Strings(scc)
+		sccs = append(sccs, scc)
+	}
+	for _, n := range nodes {
+		if _, visited := index[n]; !visited {
+			strongConnect(n)
+		}
+	}
+	return sccs
+}
+
+// moduleRelPath は pkg のモジュールのルートからの相対パスを返す。モジュールの外なら import path
+func moduleRelPath(pkg *packages.Package) string {
+	if pkg.Module == nil {
+		return pkg.PkgPath
+	}
+	if pkg.PkgPath == pkg.Module.Path {
+		return "."
+	}
+	return strings.TrimPrefix(pkg.PkgPath, pkg.Module.Path+"/")
+}
+
+// pathPrefix は / 区切りのパスの先頭 n 個の要素を返す
+func pathPrefix(path string, n int) string {
+	elems := strings.Split(path, "/")
+	if len(elems) > n {
+		elems = elems[:n]
+	}
+	return strings.Join(elems, "/")
+}
+
+// writePkgGraphDOT は依存グラフを DOT で出力する。グループはクラスタにし、層の順に上から並ぶように
+// グループがあればグループの間に見えない辺を、なければ同じ層のパッケージに rank=same を指定する。
+// 循環に含まれるパッケージと辺は赤くする
+func writePkgGraphDOT(w io.Writer, g *PkgGraph) error {
+	fmt.Fprintln(w, "digraph packages {")
+	fmt.Fprintln(w, "  rankdir=TB;")
+	fmt.Fprintln(w, "  node [shape=box];")
+	names := make(map[string]string)
+	var groups []string
+	members := make(map[string][]PkgNode)
+	for _, n := range g.Nodes {
+		names[n.Path] = n.Name
+		if _, ok := members[n.Group]; !ok {
+			groups = append(groups, n.Group)
+		}
+		members[n.Group] = append(members[n.Group], n)
+	}
+	nodeLine := func(indent string, n PkgNode) {
+		if n.InCycle {
+			fmt.Fprintf(w, "%s%q [color=red];\n", indent, n.Name)
+		} else {
+			fmt.Fprintf(w, "%s%q;\n", indent, n.Name)
+		}
+	}
+	for _, group := range groups {
+		if group == "" {
+			for _, n := range members[group] {
+				nodeLine("  ", n)
+			}
+			continue
+		}
+		fmt.Fprintf(w, "  subgraph %q {\n", "cluster_"+group)
+		fmt.Fprintf(w, "    label=%q;\n", group)
+		for _, n := range members[group] {
+			nodeLine("    ", n)
+		}
+		fmt.Fprintln(w, "  }")
+	}
+
+	// 層ごとのグループ (グループがなければパッケージ) の代表のノード
+	layers := make(map[int][]string)
+	maxLayer := 0
+	for _, group := range groups {
+		if group == "" {
+			for _, n := range members[group] {
+				layers[n.Layer] = append(layers[n.Layer], n.Name)
+				maxLayer = max(maxLayer, n.Layer)
+			}
+			continue
+		}
+		n := members[group][0]
+		layers[n.Layer] = append(layers[n.Layer], n.Name)
+		maxLayer = max(maxLayer, n.Layer)
+	}
+	for layer := 0; layer <= maxLayer; layer++ {
+		if len(groups) == 1 && groups[0] == "" {
+			if len(layers[layer]) > 1 {
+				fmt.Fprintf(w, "  { rank=same; %s; }\n", quoteJoin(layers[layer], "; "))
+			}
+			continue
+		}
+		for _, from := range layers[layer] {
+			for _, to := range layers[layer+1] {
+				fmt.Fprintf(w, "  %q -> %q [style=invis];\n", from, to)
+			}
+		}
+	}
+
+	for _, e := range g.Edges {
+		if e.Cycle {
+			fmt.Fprintf(w, "  %q -> %q [color=red];\n", names[e.From], names[e.To])
+		} else {
+			fmt.Fprintf(w, "  %q -> %q;\n", names[e.From], names[e.To])
+		}
+	}
+	fmt.Fprintln(w, "}")
+	return nil
+}
+
+// quoteJoin は各要素を引用符で囲んで sep でつなぐ
+func quoteJoin(elems []string, sep string) string {
+	quoted := make([]string, len(elems))
+	for i, e := range elems {
+		quoted[i] = fmt.Sprintf("%q", e)
+	}
+	return strings.Join(quoted, sep)
+}
+
+// writePkgGraphMermaid は依存グラフを Mermaid の flowchart で出力する。循環に含まれる辺は赤くする
+func writePkgGraphMermaid(w io.Writer, g *PkgGraph) error {
+	fmt.Fprintln(w, "flowchart TD")
+	ids := make(map[string]string)
+	for i, n := range g.Nodes {
+		ids[n.Path] = fmt.Sprintf("P%d", i)
+		fmt.Fprintf(w, "    %s[\"%s\"]\n", ids[n.Path], n.Name)
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(w, "    %s --> %s\n", ids[e.From], ids[e.To])
+	}
+	for i, e := range g.Edges {
+		if e.Cycle {
+			fmt.Fprintf(w, "    linkStyle %d stroke:red\n", i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var testdata_pkggraph = map[string]string{
+	"cmd/app/main.go": `package main
+
+import "example.com/m/internal/svc"
+
+func main() { svc.Run() }
+`,
+	"internal/svc/svc.go": `package svc
+
+import (
+	"example.com/m/internal/store"
+	"example.com/m/pkg/util"
+)
+
+func Run() { util.Log(store.Get()) }
+`,
+	"internal/store/store.go": "package store\n\nfunc Get() string { return \"\" }\n",
+	"internal/logx/logx.go":   "package logx\n\nfunc Print(string) {}\n",
+	"pkg/util/util.go": `package util
+
+import "example.com/m/internal/logx"
+
+func Log(s string) { logx.Print(s) }
+`,
+}
+
+func TestPackageGraph(t *testing.T) {
+	prog := loadTestProgram(t, testdata_pkggraph)
+
+	var buf bytes.Buffer
+	if err := writePkgGraphDOT(&buf, packageGraph(prog.Packages, 1)); err != nil {
+		t.Fatal(err)
+	}
+	// internal と pkg がお互いに依存しているのでグループの循環になる
+	assertLines(t, strings.Split(strings.TrimSpace(buf.String()), "\n"), []string{
+		"digraph packages {",
+		"  rankdir=TB;",
+		"  node [shape=box];",
+		`  subgraph "cluster_cmd" {`,
+		`    label="cmd";`,
+		`    "cmd/app";`,
+		"  }",
+		`  subgraph "cluster_internal" {`,
+		`    label="internal";`,
+		`    "internal/logx" [color=red];`,
+		`    "internal/store" [color=red];`,
+		`    "internal/svc" [color=red];`,
+		"  }",
+		`  subgraph "cluster_pkg" {`,
+		`    label="pkg";`,
+		`    "pkg/util" [color=red];`,
+		"  }",
+		`  "cmd/app" -> "internal/logx" [style=invis];`,
+		`  "cmd/app" -> "pkg/util" [style=invis];`,
+		`  "cmd/app" -> "internal/svc";`,
+		`  "internal/svc" -> "internal/store";`,
+		`  "internal/svc" -> "pkg/util" [color=red];`,
+		`  "pkg/util" -> "internal/logx" [color=red];`,
+		"}",
+	})
+
+	buf.Reset()
+	if err := writePkgGraphDOT(&buf, packageGraph(prog.Packages, 0)); err != nil {
+		t.Fatal(err)
+	}
+	assertLines(t, strings.Split(strings.TrimSpace(buf.String()), "\n"), []string{
+		"digraph packages {",
+		"  rankdir=TB;",
+		"  node [shape=box];",
+		`  "cmd/app";`,
+		`  "internal/logx";`,
+		`  "internal/store";`,
+		`  "internal/svc";`,
+		`  "pkg/util";`,
+		`  { rank=same; "internal/store"; "pkg/util"; }`,
+		`  "cmd/app" -> "internal/svc";`,
+		`  "internal/svc" -> "internal/store";`,
+		`  "internal/svc" -> "pkg/util";`,
+		`  "pkg/util" -> "internal/logx";`,
+		"}",
+	})
+}
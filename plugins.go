@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// 外部のルールのプログラムとのプロトコル (JSON over stdio)。
+// learn_ast はルールのプログラムを起動して標準入力に pluginRequest を 1 つ書き、標準入力を閉じる。
+// プログラムは標準出力に pluginResponse を 1 つ書いて終了する。終了コードが 0 でなければ失敗とみなし、
+// 標準エラー出力はそのまま learn_ast の標準エラー出力に流す
+const pluginProtocolVersion = 1
+
+// pluginRequest はルールのプログラムに渡す解析結果。位置のファイル名は Root からの相対パス
+// (Root が空なら絶対パス) で、シンボルとコールグラフの辺は export と同じ形
+type pluginRequest struct {
+	Version   int              `json:"version"`
+	Root      string           `json:"root,omitempty"`
+	Packages  []pluginPackage  `json:"packages"`
+	Symbols   []exportSymbol   `json:"symbols"`
+	CallEdges []exportCallEdge `json:"callEdges"`
+	CallSites []*CallSite      `json:"callSites"`
+}
+
+// pluginPackage は解析対象のパッケージ。Files は絶対パス
+type pluginPackage struct {
+	Path  string   `json:"path"`
+	Name  string   `json:"name"`
+	Files []string `json:"files"`
+}
+
+// pluginResponse はルールのプログラムが返す指摘。位置のファイル名は絶対パスか Root からの相対パスで、
+// Category が空ならプログラムの名前にする
+type pluginResponse struct {
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+func runRules(args []string) error {
+	fs := flag.NewFlagSet("rules", flag.ExitOnError)
+	rules := fs.String("exec", "", "comma-separated rule `commands` (program and arguments separated by spaces) speaking the JSON-over-stdio protocol")
+	return runDiagnostics(fs, args, func(prog *Program) ([]Diagnostic, error) {
+		if *rules == "" {
+			return nil, errors.New("-exec is required")
+		}
+		req := newPluginRequest(prog)
+		var diags []Diagnostic
+		for _, rule := range splitList(*rules) {
+			d, err := runRulePlugin(strings.Fields(rule), req)
+			if err != nil {
+				return nil, err
+			}
+			diags = append(diags, d...)
+		}
+		sortDiagnostics(diags)
+		return diags, nil
+	})
+}
+
+// newPluginRequest は prog の解析結果をルールのプログラムに渡す形にする
+func newPluginRequest(prog *Program) *pluginRequest {
+	results := exportAnalysis(prog, nil)
+	req := &pluginRequest{
+		Version:   pluginProtocolVersion,
+		Root:      outputRoot,
+		Symbols:   results.Symbols,
+		CallEdges: results.CallEdges,
+		CallSites: prog.CallSites(),
+	}
+	relativizePositions(req.CallSites)
+	for _, pkg := range prog.Packages {
+		req.Packages = append(req.Packages, pluginPackage{Path: pkg.PkgPath, Name: pkg.Name, Files: pkg.GoFiles})
+	}
+	return req
+}
+
+// runRulePlugin は command を起動して req を渡し、返された指摘を読む
+func runRulePlugin(command []string, req *pluginRequest) ([]Diagnostic, error) {
+	input, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	name := filepath.Base(command[0])
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("rule %s: %w", name, err)
+	}
+	var resp pluginResponse
+	if err := json.Unmarshal(out, &resp); err != nil {
+		return nil, fmt.Errorf("rule %s: invalid response: %w", name, err)
+	}
+	for i := range resp.Diagnostics {
+		d := &resp.Diagnostics[i]
+		if d.Category == "" {
+			d.Category = name
+		}
+		if d.Severity != "" {
+			if _, ok := severityRank[d.Severity]; !ok {
+				return nil, fmt.Errorf("rule %s: unknown severity %q", name, d.Severity)
+			}
+		}
+		d.Pos.Filename = absolutePath(d.Pos.Filename)
+	}
+	return resp.Diagnostics, nil
+}
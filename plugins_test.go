@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+)
+
+// TestRulePluginHelper は LEARN_AST_RULE_PLUGIN が設定されたときだけ、ルールのプログラムとして動く。
+// fmt.Println の呼び出しを指摘する
+func TestRulePluginHelper(t *testing.T) {
+	if os.Getenv("LEARN_AST_RULE_PLUGIN") != "1" {
+		t.Skip("not running as a rule plugin")
+	}
+	var req pluginRequest
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil || req.Version != pluginProtocolVersion {
+		fmt.Fprintln(os.Stderr, "bad request", err)
+		os.Exit(1)
+	}
+	var resp pluginResponse
+	for _, s := range req.CallSites {
+		if s.CalleeName == "fmt.Println" {
+			resp.Diagnostics = append(resp.Diagnostics, Diagnostic{
+				Pos:     s.Pos,
+				Message: fmt.Sprintf("%s prints with fmt.Println; use the logger (%d packages, %d symbols)", s.CallerName, len(req.Packages), len(req.Symbols)),
+			})
+		}
+	}
+	json.NewEncoder(os.Stdout).Encode(resp)
+	os.Exit(0)
+}
+
+func TestRulePlugin(t *testing.T) {
+	prog := loadTestProgram(t, map[string]string{"main.go": `package main
+
+import "fmt"
+
+func Handle() { fmt.Println("handled") }
+
+func main() {
+	Handle()
+	fmt.Println("done")
+}
+`})
+	t.Setenv("LEARN_AST_RULE_PLUGIN", "1")
+	diags, err := runRulePlugin([]string{os.Args[0], "-test.run=^TestRulePluginHelper$"}, newPluginRequest(prog))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sortDiagnostics(diags)
+	assertLines(t, diagnosticMessages(diags), []string{
+		"main.go:5: example.com/m.Handle prints with fmt.Println; use the logger (1 packages, 2 symbols)",
+		"main.go:9: example.com/m.main prints with fmt.Println; use the logger (1 packages, 2 symbols)",
+	})
+	if diags[0].Category != "learn_ast.test" && diags[0].Category != "learn_ast.test.exe" {
+		t.Errorf("category: got %q, want the program name", diags[0].Category)
+	}
+
+	if _, err := runRulePlugin([]string{os.Args[0], "-test.run=^TestRulePluginHelper$"}, &pluginRequest{}); err == nil {
+		t.Error("want an error for a plugin exiting with a non-zero status")
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// outputRoot は出力する位置のファイル名を相対パスにするときの基準のディレクトリ。空なら絶対パスのまま出力する。
+// main が -abs を指定されなければモジュール (ワークスペースなら go.work) のディレクトリにする
+var outputRoot string
+
+// moduleRoot は dir のモジュールのディレクトリを返す。ワークスペースなら go.work のあるディレクトリ、
+// モジュールの外なら空を返す
+func moduleRoot(dir string) (string, error) {
+	ws, err := findWorkspace(dir)
+	if err != nil {
+		return "", err
+	}
+	if ws != nil {
+		return filepath.Dir(ws.File), nil
+	}
+	out, err := goCommand(dir, nil, "env", "GOMOD")
+	if err != nil {
+		return "", err
+	}
+	gomod := strings.TrimSpace(string(out))
+	if gomod == "" || gomod == "/dev/null" {
+		return "", nil
+	}
+	return filepath.Dir(gomod), nil
+}
+
+var positionType = reflect.TypeOf(token.Position{})
+
+// relativizePositions は v の中の token.Position のファイル名を outputRoot からの相対パスに書き換える。
+// v はポインタかスライスで、JSON に出力されるフィールド (公開されていて json:"-" でないもの) だけを辿る。
+// すでに相対パスになっている位置はそのままなので、何度呼んでもよい
+func relativizePositions(v any) {
+	if outputRoot == "" {
+		return
+	}
+	relativize(reflect.ValueOf(v), make(map[uintptr]bool))
+}
+
+func relativize(v reflect.Value, seen map[uintptr]bool) {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() || seen[v.Pointer()] {
+			return
+		}
+		seen[v.Pointer()] = true
+		relativize(v.Elem(), seen)
+	case reflect.Interface:
+		if !v.IsNil() && v.Elem().Kind() == reflect.Pointer {
+			relativize(v.Elem(), seen)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			relativize(v.Index(i), seen)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			elem := reflect.New(iter.Value().Type()).Elem()
+			elem.Set(iter.Value())
+			relativize(elem, seen)
+			v.SetMapIndex(iter.Key(), elem)
+		}
+	case reflect.Struct:
+		if v.Type() == positionType {
+			if v.CanSet() {
+				name := v.FieldByName("Filename")
+				name.SetString(relPath(name.String(), outputRoot))
+			}
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if f := v.Type().Field(i); f.IsExported() && f.Tag.Get("json") != "-" {
+				relativize(v.Field(i), seen)
+			}
+		}
+	}
+}
+
+// absolutePath は outputRoot からの相対パスで書かれたファイル名を絶対パスに戻す
+func absolutePath(name string) string {
+	if name == "" || filepath.IsAbs(name) || outputRoot == "" {
+		return name
+	}
+	return filepath.Join(outputRoot, name)
+}
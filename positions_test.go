@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRelativizePositions(t *testing.T) {
+	dir := writeModule(t, map[string]string{"main.go": "package main\n\nfunc main() {}\n", "sub/x.go": "package sub\n"})
+	root, err := moduleRoot(filepath.Join(dir, "sub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := filepath.EvalSymlinks(dir); root != dir && root != want {
+		t.Fatalf("moduleRoot: got %s, want %s", root, dir)
+	}
+	if root, err := moduleRoot(os.TempDir()); err != nil || root != "" {
+		t.Errorf("moduleRoot outside a module: got %q, %v", root, err)
+	}
+
+	defer func(old string) { outputRoot = old }(outputRoot)
+	outputRoot = root
+	type item struct {
+		Pos    token.Position   `json:"pos"`
+		Others []token.Position `json:"others"`
+		Hidden token.Position   `json:"-"`
+	}
+	abs := func(name string) token.Position { return token.Position{Filename: filepath.Join(root, name), Line: 1} }
+	items := []item{{Pos: abs("main.go"), Others: []token.Position{abs("sub/x.go"), {Filename: "/elsewhere/y.go"}}, Hidden: abs("main.go")}}
+	relativizePositions(items)
+	relativizePositions(items) // 2 回目は変わらない
+	got := []string{items[0].Pos.Filename, items[0].Others[0].Filename, items[0].Others[1].Filename, filepath.Base(items[0].Hidden.Filename)}
+	assertLines(t, got, []string{"main.go", "sub/x.go", "/elsewhere/y.go", "main.go"})
+	if items[0].Hidden.Filename == "main.go" {
+		t.Error("a json:\"-\" field was rewritten")
+	}
+
+	// 値で渡した構造体も JSON では相対パスになる
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, item{Pos: abs("main.go")}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"Filename": "main.go"`) {
+		t.Errorf("writeJSON did not relativize:\n%s", buf.String())
+	}
+}
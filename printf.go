@@ -0,0 +1,535 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/types"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// printfFuncs は書式文字列を受け取る関数 (types.Func.FullName) と書式引数の位置
+var printfFuncs = map[string]int{
+	"fmt.Printf":               0,
+	"fmt.Sprintf":              0,
+	"fmt.Errorf":               0,
+	"fmt.Fprintf":              1,
+	"fmt.Appendf":              1,
+	"log.Printf":               0,
+	"log.Fatalf":               0,
+	"log.Panicf":               0,
+	"(*log.Logger).Printf":     0,
+	"(*log.Logger).Fatalf":     0,
+	"(*log.Logger).Panicf":     0,
+	"(*testing.common).Errorf": 0,
+	"(*testing.common).Fatalf": 0,
+	"(*testing.common).Logf":   0,
+	"(*testing.common).Skipf":  0,
+}
+
+// printfFunc は printf 系の関数の書式引数の位置と、%w でエラーを包めるか (fmt.Errorf かそのラッパーか) どうか
+type printfFunc struct {
+	Format int  `json:"format"`
+	Errorf bool `json:"errorf,omitempty"`
+}
+
+func runPrintf(args []string) error {
+	fs := flag.NewFlagSet("printf", flag.ExitOnError)
+	funcs := fs.String("funcs", "", "comma-separated full names of additional printf wrappers")
+	return runDiagnostics(fs, args, func(prog *Program) ([]Diagnostic, error) {
+		registered, err := resolvePrintfFuncs(prog, splitList(*funcs))
+		if err != nil {
+			return nil, err
+		}
+		wrappers := printfWrappers(prog, registered)
+		if err := exportPrintfWrappers(prog, wrappers); err != nil {
+			return nil, err
+		}
+		return checkPrintfWith(prog, wrappers), nil
+	})
+}
+
+// runPrintfWrappers は検出した printf ラッパーと書式引数の位置を出力する
+func runPrintfWrappers(args []string) error {
+	fs := flag.NewFlagSet("printfwrappers", flag.ExitOnError)
+	fs.Parse(args)
+	prog, err := loadProgram(".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	funcs := printfWrappers(prog, nil)
+	if err := exportPrintfWrappers(prog, funcs); err != nil {
+		return err
+	}
+	var names []string
+	for name := range funcs {
+		if _, ok := printfFuncs[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("%s\t%d\n", name, funcs[name].Format)
+	}
+	return nil
+}
+
+// formatIndex は「書式文字列, ...interface{}」で終わるシグネチャの書式引数の位置を返す
+func formatIndex(sig *types.Signature) (int, bool) {
+	params := sig.Params()
+	if !sig.Variadic() || params.Len() < 2 {
+		return 0, false
+	}
+	last := params.At(params.Len() - 1).Type().(*types.Slice)
+	if iface, ok := last.Elem().Underlying().(*types.Interface); !ok || !iface.Empty() {
+		return 0, false
+	}
+	format := params.At(params.Len() - 2).Type()
+	if basic, ok := format.Underlying().(*types.Basic); !ok || basic.Kind() != types.String {
+		return 0, false
+	}
+	return params.Len() - 2, true
+}
+
+// resolvePrintfFuncs は -funcs で指定された関数の完全な名前 (pkg.F, (*pkg.T).M) を読み込んだパッケージ
+// (依存先を含む) のスコープとメソッドから探し、シグネチャから書式引数の位置を求める。
+// 見つからない名前と、「書式文字列, ...interface{}」で終わらない関数はエラーにする
+func resolvePrintfFuncs(prog *Program, names []string) (map[string]printfFunc, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	objs := make(map[string]*types.Func)
+	add := func(obj types.Object) {
+		if fn, ok := obj.(*types.Func); ok {
+			objs[fn.FullName()] = fn
+		}
+	}
+	packages.Visit(prog.Packages, nil, func(pkg *packages.Package) {
+		scope := pkg.Types.Scope()
+		for _, name := range scope.Names() {
+			obj := scope.Lookup(name)
+			add(obj)
+			if tn, ok := obj.(*types.TypeName); ok && !tn.IsAlias() {
+				if named, ok := tn.Type().(*types.Named); ok {
+					for i := 0; i < named.NumMethods(); i++ {
+						add(named.Method(i))
+					}
+				}
+			}
+		}
+	})
+	funcs := make(map[string]printfFunc)
+	var errs []error
+	for _, name := range names {
+		fn, ok := objs[name]
+		if !ok {
+			errs = append(errs, notFound("printf wrapper %s not found", name))
+			continue
+		}
+		idx, ok := formatIndex(fn.Type().(*types.Signature))
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s does not end with a format string and ...interface{} parameters", name))
+			continue
+		}
+		funcs[name] = printfFunc{Format: idx, Errorf: name == "fmt.Errorf"}
+	}
+	return funcs, errors.Join(errs...)
+}
+
+// printfWrappers は既知の printf 関数と registered に加え、書式引数と可変長引数を
+// そのまま printf 関数へ渡している関数を SSA から探して返す。fmt.Errorf に渡しているラッパーは Errorf にする
+func printfWrappers(prog *Program, registered map[string]printfFunc) map[string]printfFunc {
+	funcs := make(map[string]printfFunc)
+	for name, idx := range printfFuncs {
+		funcs[name] = printfFunc{Format: idx, Errorf: name == "fmt.Errorf"}
+	}
+	// 依存先のパッケージのラッパーは -facts に保存された事実から得る
+	packages.Visit(prog.Packages, nil, func(pkg *packages.Package) {
+		if prog.isTarget(pkg.Types) {
+			return
+		}
+		if facts := prog.importFacts(pkg.PkgPath); facts != nil {
+			for name, w := range facts.PrintfWrappers {
+				funcs[name] = w
+			}
+		}
+	})
+	for name, w := range registered {
+		funcs[name] = w
+	}
+
+	candidates := make(map[*ssa.Function]int)
+	for _, fn := range prog.targetFunctions() {
+		if _, ok := fn.Object().(*types.Func); !ok {
+			continue
+		}
+		if idx, ok := formatIndex(fn.Signature); ok {
+			candidates[fn] = idx
+		}
+	}
+
+	// ラッパーのラッパーも見つかるよう、変化がなくなるまで繰り返す。
+	// 登録した関数も、fmt.Errorf に渡していれば Errorf にする
+	for changed := true; changed; {
+		changed = false
+		for _, fn := range prog.targetFunctions() {
+			idx, ok := candidates[fn]
+			if !ok {
+				continue
+			}
+			forwards, errorf := forwardsPrintf(fn, idx, funcs)
+			if !forwards {
+				continue
+			}
+			name := fn.Object().(*types.Func).FullName()
+			if w, ok := funcs[name]; ok && w.Errorf == errorf {
+				continue
+			}
+			funcs[name] = printfFunc{Format: idx, Errorf: errorf}
+			changed = true
+		}
+	}
+	return funcs
+}
+
+// exportPrintfWrappers は funcs のうち解析対象のパッケージで定義されたラッパーをそのパッケージの事実として保存する
+func exportPrintfWrappers(prog *Program, funcs map[string]printfFunc) error {
+	byPkg := make(map[string]map[string]printfFunc)
+	for _, fn := range prog.targetFunctions() {
+		obj, ok := fn.Object().(*types.Func)
+		if !ok || obj.Pkg() == nil {
+			continue
+		}
+		w, ok := funcs[obj.FullName()]
+		if !ok {
+			continue
+		}
+		if byPkg[obj.Pkg().Path()] == nil {
+			byPkg[obj.Pkg().Path()] = make(map[string]printfFunc)
+		}
+		byPkg[obj.Pkg().Path()][obj.FullName()] = w
+	}
+	return prog.exportFacts(func(pkgPath string, f *PackageFacts) { f.PrintfWrappers = byPkg[pkgPath] })
+}
+
+// forwardsPrintf は fn が書式引数 (から作った文字列) と可変長引数をそのまま funcs のいずれかに渡しているかどうかと、
+// 渡している先に Errorf があるかどうかを返す
+func forwardsPrintf(fn *ssa.Function, formatIdx int, funcs map[string]printfFunc) (forwards, errorf bool) {
+	offset := len(fn.Params) - fn.Signature.Params().Len() // レシーバの分
+	format, args := fn.Params[offset+formatIdx], fn.Params[offset+formatIdx+1]
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			call, ok := instr.(ssa.CallInstruction)
+			if !ok {
+				continue
+			}
+			common := call.Common()
+			var callee *types.Func
+			if common.IsInvoke() {
+				callee = common.Method
+			} else if static := common.StaticCallee(); static != nil {
+				callee, _ = static.Object().(*types.Func)
+			}
+			if callee == nil {
+				continue
+			}
+			w, ok := funcs[callee.FullName()]
+			if !ok {
+				continue
+			}
+			idx := w.Format
+			callArgs := common.Args[len(common.Args)-callee.Type().(*types.Signature).Params().Len():]
+			if idx+1 < len(callArgs) && callArgs[idx+1] == args && dependsOn(callArgs[idx], format, make(map[ssa.Value]bool)) {
+				forwards = true
+				errorf = errorf || w.Errorf
+			}
+		}
+	}
+	return forwards, errorf
+}
+
+// dependsOn は v が target から (連結や型変換を経て) 作られた値かどうかを返す
+func dependsOn(v, target ssa.Value, seen map[ssa.Value]bool) bool {
+	if v == target {
+		return true
+	}
+	if seen[v] {
+		return false
+	}
+	seen[v] = true
+	switch v := v.(type) {
+	case *ssa.BinOp:
+		return dependsOn(v.X, target, seen) || dependsOn(v.Y, target, seen)
+	case *ssa.Phi:
+		for _, edge := range v.Edges {
+			if dependsOn(edge, target, seen) {
+				return true
+			}
+		}
+	case *ssa.ChangeType:
+		return dependsOn(v.X, target, seen)
+	case *ssa.Convert:
+		return dependsOn(v.X, target, seen)
+	}
+	return false
+}
+
+// checkPrintf は printf 系関数の呼び出しで書式指定子と引数の型が一致しているか検査する
+func checkPrintf(prog *Program) []Diagnostic {
+	return checkPrintfWith(prog, printfWrappers(prog, nil))
+}
+
+// checkPrintfWith は checkPrintf と同じだが、printf のラッパーとして funcs を使う
+func checkPrintfWith(prog *Program, funcs map[string]printfFunc) []Diagnostic {
+	var diags []Diagnostic
+	r := NewVisitorRegistry()
+	registerPrintfCalls(r, prog, funcs, func(d Diagnostic) { diags = append(diags, d) })
+	r.Walk(prog.Packages)
+	sortDiagnostics(diags)
+	return diags
+}
+
+// registerPrintfCalls は funcs (関数の完全な名前と書式の引数の位置) の呼び出しの書式を検査する関数を r に登録する
+func registerPrintfCalls(r *VisitorRegistry, prog *Program, funcs map[string]printfFunc, report func(Diagnostic)) {
+	r.Register([]ast.Node{(*ast.CallExpr)(nil)}, func(c *VisitContext, n ast.Node) {
+		call := n.(*ast.CallExpr)
+		info := c.Pkg.TypesInfo
+		fn, ok := typeutil.Callee(info, call).(*types.Func)
+		if !ok {
+			return
+		}
+		w, ok := funcs[fn.FullName()]
+		idx := w.Format
+		// f(format, []any{a, b}...) は要素を引数とみなす。要素の分からないスライスの展開は検査しない
+		args, spread := spreadArgs(call)
+		if !ok || !spread || idx >= len(args) {
+			return
+		}
+		tv := info.Types[args[idx]]
+		if tv.Value == nil || tv.Value.Kind() != constant.String {
+			return
+		}
+		for _, msg := range checkFormat(constant.StringVal(tv.Value), args[idx+1:], info, w.Errorf) {
+			report(newDiagnostic(prog.Fset, call.Pos(), "printf", "%s %s", fn.Name(), msg))
+		}
+	})
+}
+
+// formatDirective は書式文字列中の 1 つの指定子
+type formatDirective struct {
+	text string
+	verb rune
+	args []int // 消費する引数の位置 (* による幅・精度を含む)
+}
+
+// parseFormat は書式文字列を指定子に分解する
+func parseFormat(format string) ([]formatDirective, string) {
+	var directives []formatDirective
+	argNum := 0
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		start := i
+		i++
+		d := formatDirective{}
+		// フラグ
+		for i < len(format) && strings.ContainsRune("+-# 0", rune(format[i])) {
+			i++
+		}
+		// 幅と精度 (引数インデックス [n] と * を含む)
+	width:
+		for i < len(format) {
+			c := format[i]
+			switch {
+			case c == '[':
+				end := strings.IndexByte(format[i:], ']')
+				if end < 0 {
+					return directives, "bad argument index in " + format[start:]
+				}
+				n, err := strconv.Atoi(format[i+1 : i+end])
+				if err != nil || n < 1 {
+					return directives, "bad argument index in " + format[start:i+end+1]
+				}
+				argNum = n - 1
+				i += end + 1
+				continue
+			case c == '*':
+				d.args = append(d.args, argNum)
+				argNum++
+			case c == '.' || '0' <= c && c <= '9':
+			default:
+				break width
+			}
+			i++
+		}
+		if i >= len(format) {
+			return directives, "missing verb at end of format " + format[start:]
+		}
+		verb, size := utf8.DecodeRuneInString(format[i:])
+		i += size - 1
+		d.text = format[start : i+1]
+		d.verb = verb
+		if verb != '%' {
+			d.args = append(d.args, argNum)
+			argNum++
+		}
+		directives = append(directives, d)
+	}
+	return directives, ""
+}
+
+// checkFormat は format と args の不一致を文字列で返す。errorf なら %w でエラーを包める
+func checkFormat(format string, args []ast.Expr, info *types.Info, errorf bool) []string {
+	directives, errMsg := parseFormat(format)
+	var msgs []string
+	if errMsg != "" {
+		msgs = append(msgs, errMsg)
+	}
+	maxArg := -1
+	for _, d := range directives {
+		for i, argIdx := range d.args {
+			if argIdx > maxArg {
+				maxArg = argIdx
+			}
+			if argIdx >= len(args) {
+				msgs = append(msgs, fmt.Sprintf("format %s reads arg #%d, but call has %s", d.text, argIdx+1, plural(len(args), "arg")))
+				break
+			}
+			t := info.TypeOf(args[argIdx])
+			if i < len(d.args)-1 || d.verb == '%' {
+				// * による幅・精度は int でなければならない
+				if basic, ok := t.Underlying().(*types.Basic); !ok || basic.Info()&types.IsInteger == 0 {
+					msgs = append(msgs, fmt.Sprintf("format %s uses non-int %s as argument of *", d.text, types.ExprString(args[argIdx])))
+				}
+				continue
+			}
+			if d.verb == 'w' {
+				switch {
+				case !errorf:
+					msgs = append(msgs, fmt.Sprintf("format %s is only supported by Errorf", d.text))
+				case !isError(t):
+					msgs = append(msgs, fmt.Sprintf("format %s has arg %s of wrong type %s", d.text, types.ExprString(args[argIdx]), t))
+				}
+				continue
+			}
+			if !strings.ContainsRune(printVerbs, d.verb) {
+				msgs = append(msgs, fmt.Sprintf("format %s has unknown verb %c", d.text, d.verb))
+				continue
+			}
+			if !matchArgType(d.verb, t, true) {
+				msgs = append(msgs, fmt.Sprintf("format %s has arg %s of wrong type %s", d.text, types.ExprString(args[argIdx]), t))
+			}
+		}
+	}
+	if errMsg == "" && maxArg+1 < len(args) {
+		msgs = append(msgs, fmt.Sprintf("call needs %s but has %s", plural(maxArg+1, "arg"), plural(len(args), "arg")))
+	}
+	return msgs
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return "1 " + word
+	}
+	return fmt.Sprintf("%d %ss", n, word)
+}
+
+const printVerbs = "bcdeEfFgGoOpqstTvxXU"
+
+// matchArgType は verb で t を出力できるかどうかを返す
+func matchArgType(verb rune, t types.Type, top bool) bool {
+	if verb == 'v' || verb == 'T' {
+		return true
+	}
+	if isFormatter(t) {
+		return true
+	}
+	if strings.ContainsRune("sqvxX", verb) && (isStringer(t) || isError(t)) {
+		return true
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Interface:
+		// 動的な型は実行時まで分からない
+		return true
+	case *types.Basic:
+		info := u.Info()
+		switch {
+		case info&types.IsBoolean != 0:
+			return verb == 't'
+		case info&types.IsInteger != 0:
+			return strings.ContainsRune("bcdoOqxXU", verb)
+		case info&types.IsFloat != 0:
+			return strings.ContainsRune("beEfFgGxX", verb)
+		case info&types.IsComplex != 0:
+			return strings.ContainsRune("beEfFgG", verb)
+		case info&types.IsString != 0:
+			return strings.ContainsRune("sqxX", verb)
+		case u.Kind() == types.UnsafePointer:
+			return strings.ContainsRune("pbdoxX", verb)
+		}
+		return false
+	case *types.Pointer:
+		if strings.ContainsRune("pbdoxX", verb) {
+			return true
+		}
+		// 構造体などへのポインタは最上位だけ中身を表示する
+		if top {
+			switch u.Elem().Underlying().(type) {
+			case *types.Struct, *types.Slice, *types.Array, *types.Map:
+				return matchArgType(verb, u.Elem(), false)
+			}
+		}
+		return false
+	case *types.Chan, *types.Signature:
+		return verb == 'p'
+	case *types.Slice:
+		if basic, ok := u.Elem().Underlying().(*types.Basic); ok && basic.Kind() == types.Byte && strings.ContainsRune("sqxX", verb) {
+			return true
+		}
+		return verb == 'p' || matchArgType(verb, u.Elem(), false)
+	case *types.Array:
+		return matchArgType(verb, u.Elem(), false)
+	case *types.Map:
+		return verb == 'p' || matchArgType(verb, u.Key(), false) && matchArgType(verb, u.Elem(), false)
+	case *types.Struct:
+		for i := 0; i < u.NumFields(); i++ {
+			if !matchArgType(verb, u.Field(i).Type(), false) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// hasStringMethod は t のメソッド集合に name() string 形式のメソッドがあるかどうかを返す
+func hasStringMethod(t types.Type, name string) bool {
+	sel := types.NewMethodSet(t).Lookup(nil, name)
+	if sel == nil {
+		return false
+	}
+	sig := sel.Type().(*types.Signature)
+	if sig.Params().Len() != 0 || sig.Results().Len() != 1 {
+		return false
+	}
+	basic, ok := sig.Results().At(0).Type().(*types.Basic)
+	return ok && basic.Kind() == types.String
+}
+
+func isStringer(t types.Type) bool { return hasStringMethod(t, "String") }
+
+func isError(t types.Type) bool { return hasStringMethod(t, "Error") }
+
+func isFormatter(t types.Type) bool {
+	return types.NewMethodSet(t).Lookup(nil, "Format") != nil
+}
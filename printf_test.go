@@ -0,0 +1,182 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestCheckPrintf(t *testing.T) {
+	src := `package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"testing"
+)
+
+type Celsius float64
+
+func (c Celsius) String() string { return fmt.Sprintf("%.1fC", float64(c)) }
+
+func logf(format string, args ...interface{}) {
+	log.Printf("app: "+format, args...)
+}
+
+func debugf(format string, args ...interface{}) {
+	logf(format, args...)
+}
+
+func main() {
+	a := 1
+	b := "hello"
+	fmt.Printf("%d %s\n", a, b)
+	fmt.Printf("%s\n", a)
+	fmt.Printf("%d %d\n", a)
+	fmt.Printf("%d\n", a, b)
+	fmt.Fprintf(os.Stderr, "%t\n", b)
+	fmt.Printf("%s %v %x\n", Celsius(1), []int{a}, []byte(b))
+	fmt.Printf("%*d %z\n", a, a, a)
+	log.Printf("%d\n", &a)
+	logf("%d", b)
+	debugf("%s %s", b)
+	fmt.Printf("%d %s\n", []any{b, a}...)
+	args := []any{a}
+	fmt.Printf("%s\n", args...)
+	err := fmt.Errorf("run: %w", os.ErrNotExist)
+	_ = fmt.Errorf("%s: %w", b, err)
+	_ = fmt.Errorf("%w", a)
+	fmt.Printf("%w\n", err)
+}
+
+func errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("app: "+format, args...)
+}
+
+type Logger struct{}
+
+func (Logger) Errorf(format string, args ...interface{}) {
+	log.Printf(format, args...)
+}
+
+func wrap(t *testing.T, err error) {
+	_ = errorf("%w", err)
+	Logger{}.Errorf("%w", err)
+	t.Errorf("%w", err)
+}
+`
+	prog := loadTestProgram(t, map[string]string{"main.go": src})
+	assertLines(t, diagnosticMessages(checkPrintf(prog)), []string{
+		"main.go:26: Printf format %s has arg a of wrong type int",
+		"main.go:27: Printf format %d reads arg #2, but call has 1 arg",
+		"main.go:28: Printf call needs 1 arg but has 2 args",
+		"main.go:29: Fprintf format %t has arg b of wrong type string",
+		"main.go:31: Printf format %z has unknown verb z",
+		"main.go:33: logf format %d has arg b of wrong type string",
+		"main.go:34: debugf format %s reads arg #2, but call has 1 arg",
+		"main.go:35: Printf format %d has arg b of wrong type string",
+		"main.go:35: Printf format %s has arg a of wrong type int",
+		"main.go:40: Errorf format %w has arg a of wrong type int",
+		"main.go:41: Printf format %w is only supported by Errorf",
+		"main.go:56: Errorf format %w is only supported by Errorf",
+		"main.go:57: Errorf format %w is only supported by Errorf",
+	})
+}
+
+func TestPrintfWrappers(t *testing.T) {
+	src := `package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+)
+
+type Logger struct{ prefix string }
+
+func (l *Logger) Logf(format string, args ...interface{}) {
+	log.Printf(l.prefix+format, args...)
+}
+
+func errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("app: "+format, args...)
+}
+
+func wrapErrorf(format string, args ...interface{}) error {
+	return errorf(format, args...)
+}
+
+// 可変長引数をそのまま渡していないのでラッパーではない
+func dump(format string, args ...interface{}) string {
+	return fmt.Sprintf("%v", args)
+}
+
+func fail(format string, args ...interface{}) error {
+	return errors.New(format)
+}
+
+func main() {
+	l := &Logger{}
+	l.Logf("%d", 1)
+	_ = wrapErrorf("%s", "x")
+	_ = dump("%d", 1)
+	_ = fail("%d", 1)
+}
+`
+	prog := loadTestProgram(t, map[string]string{"main.go": src})
+	funcs := printfWrappers(prog, nil)
+	var got []string
+	for name, w := range funcs {
+		if _, ok := printfFuncs[name]; !ok {
+			got = append(got, fmt.Sprintf("%s %d errorf=%t", name, w.Format, w.Errorf))
+		}
+	}
+	sort.Strings(got)
+	assertLines(t, got, []string{
+		"(*example.com/m.Logger).Logf 0 errorf=false",
+		"example.com/m.errorf 0 errorf=true",
+		"example.com/m.wrapErrorf 0 errorf=true",
+	})
+}
+
+func TestResolvePrintfFuncs(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"logger/logger.go": `package logger
+
+import "fmt"
+
+type Logger struct{}
+
+func (l *Logger) Infof(msg string, args ...any) { fmt.Println(msg, len(args)) }
+
+func Info(msg string) { fmt.Println(msg) }
+`,
+		"main.go": `package main
+
+import "example.com/m/logger"
+
+func main() {
+	l := &logger.Logger{}
+	l.Infof("%d", "x")
+}
+`,
+	})
+	prog, err := loadProgram(dir, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	registered, err := resolvePrintfFuncs(prog, []string{"(*example.com/m/logger.Logger).Infof"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertLines(t, diagnosticMessages(checkPrintfWith(prog, printfWrappers(prog, registered))), []string{
+		`main.go:7: Infof format %d has arg "x" of wrong type string`,
+	})
+	_, err = resolvePrintfFuncs(prog, []string{"example.com/m/logger.Missing", "example.com/m/logger.Info"})
+	if !errors.Is(err, ErrNotFound) || !strings.Contains(err.Error(), "example.com/m/logger.Info does not end with a format string") {
+		t.Errorf("got error %v", err)
+	}
+}
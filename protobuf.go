@@ -0,0 +1,258 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// ProtoType は protoc で生成された Go の型と、それを定義している .proto の message か enum
+type ProtoType struct {
+	Type  string         `json:"type"`            // Go の型 (import path 付き)
+	Kind  string         `json:"kind,omitempty"`  // message か enum (.proto に定義が見つからなければ空)
+	Name  string         `json:"name,omitempty"`  // .proto での名前 (入れ子なら Outer.Inner)
+	Proto string         `json:"proto,omitempty"` // 生成元の .proto (見つからなければ空)
+	Line  int            `json:"line,omitempty"`
+	Pos   token.Position `json:"pos"` // Go の型の宣言位置
+
+	spans [][2]int // 型の宣言とメソッドの行の範囲 (protoTypeAt で使う)
+}
+
+// protoDefinition は .proto の message と enum の宣言
+var protoDefinition = regexp.MustCompile(`^\s*(message|enum)\s+(\w+)`)
+
+func runProtoMap(args []string) error {
+	fs := flag.NewFlagSet("protomap", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output as JSON")
+	fs.Parse(args)
+	prog, err := loadProgram(".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	result := protoTypes(prog)
+	if *asJSON {
+		for i := range result {
+			result[i].Proto = relPath(result[i].Proto, outputRoot)
+		}
+		return writeJSON(os.Stdout, result)
+	}
+	return writeProtoTypes(os.Stdout, result)
+}
+
+// protoSources は protoc-gen-go などで生成されたメッセージのファイル (.pb.go、_grpc.pb.go は除く) ごとに、
+// 生成元の .proto のパス (見つからなければ空) を返す (初回のみ調べる)。
+// これらのファイルの関数はゲッター、Marshal/Unmarshal、ディスクリプタなどの生成されたものだけなので、
+// グラフやメトリクスではまとめるか除く
+func (p *Program) protoSources() map[string]string {
+	p.protoOnce.Do(func() {
+		p.protos = make(map[string]string)
+		for _, prov := range generatedProvenance(p) {
+			if !isProtoMessageFile(prov) {
+				continue
+			}
+			p.protos[prov.File] = ""
+			for _, input := range prov.Inputs {
+				if strings.HasSuffix(input, ".proto") {
+					p.protos[prov.File] = input
+					break
+				}
+			}
+		}
+	})
+	return p.protos
+}
+
+// isProtoMessageFile は p が protoc のプラグインの生成したメッセージのファイルかどうかを返す。
+// gRPC のクライアントとサーバーのファイルは、呼び出しの流れに関わるので含めない
+func isProtoMessageFile(p *Provenance) bool {
+	if strings.HasSuffix(p.File, "_grpc.pb.go") || strings.Contains(p.Generator, "grpc") {
+		return false
+	}
+	return strings.HasSuffix(p.File, ".pb.go") || strings.HasPrefix(p.Generator, "protoc-gen-")
+}
+
+// isProtoFunc は fn が protoc で生成されたメッセージのファイルで宣言された関数 (無名関数を含む) かどうかを返す
+func (p *Program) isProtoFunc(fn *ssa.Function) bool {
+	for fn.Parent() != nil {
+		fn = fn.Parent()
+	}
+	if !fn.Pos().IsValid() {
+		return false
+	}
+	_, ok := p.protoSources()[p.Fset.Position(fn.Pos()).Filename]
+	return ok
+}
+
+// protoNodeName は protoc で生成された関数をまとめたノードの名前を返す。
+// メソッドならレシーバのメッセージ (pkg.Msg [protobuf])、関数ならパッケージ (pkg [protobuf])
+func protoNodeName(fn *ssa.Function) string {
+	for fn.Parent() != nil {
+		fn = fn.Parent()
+	}
+	if recv := fn.Signature.Recv(); recv != nil {
+		t := recv.Type()
+		if ptr, ok := t.(*types.Pointer); ok {
+			t = ptr.Elem()
+		}
+		if named, ok := t.(*types.Named); ok && named.Obj().Pkg() != nil {
+			return named.Obj().Pkg().Path() + "." + named.Obj().Name() + " [protobuf]"
+		}
+	}
+	if pkg := ssaFuncPackage(fn); pkg != nil {
+		return pkg.Path() + " [protobuf]"
+	}
+	return fn.RelString(nil)
+}
+
+// protoTypes は protoc で生成されたメッセージのファイルで宣言された型を、.proto の message と enum の定義に対応付けて
+// 型の名前の順に返す。入れ子の定義は Outer_Inner の名前の型になる。.proto が読めなければ、エクスポートされた型を
+// 定義なしで返し、読めた場合は定義の見つからない型 (oneof のラッパーなど) を除く
+func protoTypes(prog *Program) []ProtoType {
+	sources := prog.protoSources()
+	var result []ProtoType
+	seen := make(map[string]bool)
+	for _, pkg := range prog.Packages {
+		for _, file := range pkg.Syntax {
+			name := prog.Fset.Position(file.Pos()).Filename
+			proto, ok := sources[name]
+			if !ok || seen[name] {
+				continue
+			}
+			seen[name] = true
+			defs, err := protoDefinitions(prog, proto)
+			byName := make(map[string]int)
+			for _, decl := range file.Decls {
+				gd, ok := decl.(*ast.GenDecl)
+				if !ok || gd.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range gd.Specs {
+					ts := spec.(*ast.TypeSpec)
+					def, found := defs[ts.Name.Name]
+					if err == nil && !found || err != nil && !ts.Name.IsExported() {
+						continue
+					}
+					t := ProtoType{
+						Type:  pkg.PkgPath + "." + ts.Name.Name,
+						Kind:  def.Kind,
+						Name:  def.Name,
+						Proto: proto,
+						Line:  def.Line,
+						Pos:   prog.Fset.Position(ts.Pos()),
+						spans: [][2]int{{prog.Fset.Position(gd.Pos()).Line, prog.Fset.Position(gd.End()).Line}},
+					}
+					byName[ts.Name.Name] = len(result)
+					result = append(result, t)
+				}
+			}
+			for _, decl := range file.Decls {
+				fd, ok := decl.(*ast.FuncDecl)
+				if !ok || fd.Recv == nil {
+					continue
+				}
+				recv, _, _ := strings.Cut(funcDeclName(fd), ".")
+				if i, ok := byName[recv]; ok {
+					result[i].spans = append(result[i].spans, [2]int{prog.Fset.Position(fd.Pos()).Line, prog.Fset.Position(fd.End()).Line})
+				}
+			}
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Type < result[j].Type })
+	return result
+}
+
+// protoDef は .proto の message か enum の定義
+type protoDef struct {
+	Kind, Name string
+	Line       int
+}
+
+// protoDefinitions は .proto の message と enum の定義を、生成される Go の型名 (入れ子は Outer_Inner) で引けるように返す。
+// 構文は解析せず、行ごとに宣言と波括弧の深さを数えるだけなので、宣言と { は同じ行にあるものとみなす
+func protoDefinitions(prog *Program, proto string) (map[string]protoDef, error) {
+	if proto == "" {
+		return nil, os.ErrNotExist
+	}
+	src, err := prog.ReadFile(proto)
+	if err != nil {
+		return nil, err
+	}
+	type scope struct {
+		name  string
+		depth int
+	}
+	defs := make(map[string]protoDef)
+	var stack []scope
+	depth := 0
+	for i, line := range strings.Split(string(src), "\n") {
+		line, _, _ = strings.Cut(line, "//")
+		if m := protoDefinition.FindStringSubmatch(line); m != nil {
+			var protoNames, goNames []string
+			for _, s := range stack {
+				protoNames = append(protoNames, s.name)
+				goNames = append(goNames, protoGoName(s.name))
+			}
+			goName := strings.Join(append(goNames, protoGoName(m[2])), "_")
+			defs[goName] = protoDef{Kind: m[1], Name: strings.Join(append(protoNames, m[2]), "."), Line: i + 1}
+			stack = append(stack, scope{m[2], depth + 1})
+		}
+		depth += strings.Count(line, "{") - strings.Count(line, "}")
+		for len(stack) > 0 && stack[len(stack)-1].depth > depth {
+			stack = stack[:len(stack)-1]
+		}
+	}
+	return defs, nil
+}
+
+// protoGoName は protoc-gen-go と同じように .proto の名前を Go の名前にする (foo_bar なら FooBar)
+func protoGoName(name string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(name, "_") {
+		if part != "" {
+			b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+		}
+	}
+	return b.String()
+}
+
+// protoTypeAt は pos が protoc で生成された型の宣言かそのメソッドの中にあれば、その型を返す
+func protoTypeAt(protos []ProtoType, pos token.Position) *ProtoType {
+	for i := range protos {
+		if protos[i].Pos.Filename != pos.Filename {
+			continue
+		}
+		for _, span := range protos[i].spans {
+			if span[0] <= pos.Line && pos.Line <= span[1] {
+				return &protos[i]
+			}
+		}
+	}
+	return nil
+}
+
+// definition は .proto での定義を説明する (message Request at api/api.proto:3)
+func (t *ProtoType) definition() string {
+	switch {
+	case t.Line > 0:
+		return fmt.Sprintf("%s %s at %s:%d", t.Kind, t.Name, relPath(t.Proto, outputRoot), t.Line)
+	case t.Proto != "":
+		return relPath(t.Proto, outputRoot) + " (definition not found)"
+	}
+	return "(unknown .proto)"
+}
+
+func writeProtoTypes(w io.Writer, result []ProtoType) error {
+	for _, t := range result {
+		fmt.Fprintf(w, "%s: %s\n", t.Type, t.definition())
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testdata_protobuf = map[string]string{
+	"api/api.proto": `syntax = "proto3";
+
+package api;
+
+message Request {
+  string name = 1;
+  message Meta {
+    int64 id = 1;
+  }
+  Meta meta = 2;
+}
+
+enum Status {
+  STATUS_UNKNOWN = 0;
+}
+`,
+	"api/api.pb.go": `// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: api/api.proto
+
+package api
+
+type Request struct {
+	Name string
+	Meta *Request_Meta
+}
+
+func (x *Request) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Request) GetMeta() *Request_Meta {
+	if x != nil {
+		return x.Meta
+	}
+	return nil
+}
+
+func (x *Request) Reset() { *x = Request{} }
+
+type Request_Meta struct {
+	Id int64
+}
+
+func (x *Request_Meta) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+type Status int32
+
+func (x Status) Enum() *Status { return &x }
+
+func file_api_proto_init() {}
+`,
+	"main.go": `package main
+
+import "example.com/m/api"
+
+func describe(r *api.Request) string {
+	if r.GetMeta().GetId() > 0 {
+		return r.GetName()
+	}
+	return ""
+}
+
+func main() { println(describe(&api.Request{})) }
+`,
+}
+
+func TestProtoTypes(t *testing.T) {
+	dir := writeModule(t, testdata_protobuf)
+	prog, err := loadProgram(dir, "./...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	protos := protoTypes(prog)
+	var got []string
+	for _, p := range protos {
+		got = append(got, strings.ReplaceAll(p.Type+": "+p.definition(), filepath.ToSlash(dir)+"/", ""))
+	}
+	assertLines(t, got, []string{
+		"example.com/m/api.Request: message Request at api/api.proto:5",
+		"example.com/m/api.Request_Meta: message Request.Meta at api/api.proto:7",
+		"example.com/m/api.Status: enum Status at api/api.proto:13",
+	})
+
+	diags := []Diagnostic{{Pos: protos[0].Pos, Category: "nilness", Message: "m"}}
+	annotateGenerated(prog, diags)
+	if got, want := strings.ReplaceAll(diags[0].Origin, filepath.ToSlash(dir)+"/", ""), "generated by protoc-gen-go from api/api.proto; message Request at api/api.proto:5"; got != want {
+		t.Errorf("Origin = %q, want %q", got, want)
+	}
+
+	for _, m := range packageMetrics(prog) {
+		if m.Package == "example.com/m/api" && (m.Funcs != 0 || m.ProtoFuncs != 6) {
+			t.Errorf("got %d funcs and %d protobuf funcs in %s, want 0 and 6", m.Funcs, m.ProtoFuncs, m.Package)
+		}
+	}
+}
+
+func TestCallEdgesProto(t *testing.T) {
+	prog := loadTestProgram(t, testdata_protobuf)
+	edgeLines := func(proto string) []string {
+		var lines []string
+		for _, e := range callEdges(prog, callGraphOptions{Std: stdExclude, Proto: proto}) {
+			if strings.HasPrefix(e.Caller, "example.com/m.") {
+				lines = append(lines, e.Caller+" --> "+e.Callee)
+			}
+		}
+		return lines
+	}
+	assertLines(t, edgeLines(stdCollapse), []string{
+		"example.com/m.describe --> example.com/m/api.Request [protobuf]",
+		"example.com/m.describe --> example.com/m/api.Request_Meta [protobuf]",
+		"example.com/m.init --> example.com/m/api.init",
+		"example.com/m.main --> example.com/m.describe",
+	})
+	assertLines(t, edgeLines(stdExclude), []string{
+		"example.com/m.init --> example.com/m/api.init",
+		"example.com/m.main --> example.com/m.describe",
+	})
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Provenance は生成されたファイルの生成元
+type Provenance struct {
+	File      string     `json:"file"`
+	Generator string     `json:"generator,omitempty"` // ヘッダーの "Code generated by X" の X
+	Directive *Directive `json:"directive,omitempty"` // ファイルを生成する go:generate
+	Inputs    []string   `json:"inputs,omitempty"`    // 生成元の入力ファイル (proto、テンプレートなど、見つかったもの)
+	Decls     []string   `json:"decls,omitempty"`     // ファイルのパッケージレベルの宣言 (メソッドは T.M)
+}
+
+var (
+	generatedBy     = regexp.MustCompile(`^// Code generated by (.+?)[.;,]? DO NOT EDIT\.$`)
+	generatedSource = regexp.MustCompile(`^//\s*source:\s*(\S+)`)
+)
+
+func runProvenance(args []string) error {
+	fs := flag.NewFlagSet("provenance", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output as JSON")
+	decl := fs.String("decl", "", "only show the generated file declaring `name` (T.M for methods)")
+	fs.Parse(args)
+	prog, err := loadProgramWith(loadOptions{Tests: true}, ".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	var result []*Provenance
+	for _, p := range generatedProvenance(prog) {
+		if *decl == "" || slices.Contains(p.Decls, *decl) {
+			result = append(result, p)
+		}
+	}
+	if *decl != "" && len(result) == 0 {
+		return notFound("generated declaration %s not found", *decl)
+	}
+	if *asJSON {
+		for _, p := range result {
+			for i, input := range p.Inputs {
+				p.Inputs[i] = relPath(input, outputRoot)
+			}
+			p.File = relPath(p.File, outputRoot)
+		}
+		return writeJSON(os.Stdout, result)
+	}
+	return writeProvenance(os.Stdout, result)
+}
+
+// generatedProvenance は解析対象のパッケージの生成されたファイルごとに、それを生成する go:generate と入力ファイルを
+// 推定してファイル名の順に返す。go:generate は次の順に探す。
+//   - 引数 (-o=x.go などのフラグの値を含む) がそのファイルを指すもの
+//   - 同じディレクトリにあり、コマンドがヘッダーの生成したツール (protoc-gen-* なら protoc か buf) と一致するもの
+//   - 同じディレクトリに go:generate が 1 つだけならそれ
+//
+// 入力ファイルは、ヘッダーの "// source: x.proto" と、go:generate の引数のうち存在するファイル
+func generatedProvenance(prog *Program) []*Provenance {
+	directives := generateDirectives(prog)
+	var result []*Provenance
+	seen := make(map[string]bool)
+	for _, pkg := range prog.Packages {
+		for _, file := range pkg.Syntax {
+			name := prog.Fset.Position(file.Pos()).Filename
+			if seen[name] || !prog.isGenerated(name) {
+				continue
+			}
+			seen[name] = true
+			p := &Provenance{File: name}
+			var sources []string
+			for _, group := range file.Comments {
+				if group.Pos() > file.Package {
+					break
+				}
+				for _, c := range group.List {
+					if m := generatedBy.FindStringSubmatch(c.Text); m != nil {
+						p.Generator = strings.Trim(m[1], "\"`")
+					}
+					if m := generatedSource.FindStringSubmatch(c.Text); m != nil {
+						sources = append(sources, m[1])
+					}
+				}
+			}
+			p.Directive = findGenerateDirective(directives, name, p.Generator)
+			dirs := []string{filepath.Dir(name)}
+			if p.Directive != nil {
+				dirs = append([]string{filepath.Dir(p.Directive.Pos.Filename)}, dirs...)
+				for _, arg := range p.Directive.Args {
+					sources = append(sources, argPaths(arg)...)
+				}
+			}
+			if pkg.Module != nil {
+				dirs = append(dirs, pkg.Module.Dir)
+			}
+			for _, src := range sources {
+				if input := resolveInput(dirs, src); input != "" && input != name && !slices.Contains(p.Inputs, input) {
+					p.Inputs = append(p.Inputs, input)
+				}
+			}
+			for _, d := range file.Decls {
+				switch d := d.(type) {
+				case *ast.FuncDecl:
+					p.Decls = append(p.Decls, funcDeclName(d))
+				case *ast.GenDecl:
+					for _, spec := range d.Specs {
+						switch s := spec.(type) {
+						case *ast.TypeSpec:
+							p.Decls = append(p.Decls, s.Name.Name)
+						case *ast.ValueSpec:
+							for _, id := range s.Names {
+								p.Decls = append(p.Decls, id.Name)
+							}
+						}
+					}
+				}
+			}
+			result = append(result, p)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].File < result[j].File })
+	return result
+}
+
+// generateDirectives は解析対象のパッケージのファイルの //go:generate を集める
+func generateDirectives(prog *Program) []Directive {
+	var directives []Directive
+	seen := make(map[string]bool)
+	for _, pkg := range prog.Packages {
+		for _, file := range pkg.Syntax {
+			name := prog.Fset.Position(file.Pos()).Filename
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			for _, group := range file.Comments {
+				for _, c := range group.List {
+					if rest, ok := strings.CutPrefix(c.Text, "//go:generate "); ok {
+						directives = append(directives, Directive{Kind: "generate", Args: strings.Fields(rest), Pos: prog.Fset.Position(c.Pos())})
+					}
+				}
+			}
+		}
+	}
+	return directives
+}
+
+// findGenerateDirective は generatedProvenance の順で filename を生成する go:generate を探す
+func findGenerateDirective(directives []Directive, filename, generator string) *Directive {
+	dir := filepath.Dir(filename)
+	var sameDir []*Directive
+	for i := range directives {
+		d := &directives[i]
+		ddir := filepath.Dir(d.Pos.Filename)
+		for _, arg := range d.Args {
+			for _, p := range argPaths(arg) {
+				if filepath.Join(ddir, filepath.FromSlash(p)) == filename {
+					return d
+				}
+			}
+		}
+		if ddir == dir {
+			sameDir = append(sameDir, d)
+		}
+	}
+	if generator != "" {
+		tool, _, _ := strings.Cut(generator, " ")
+		tool = path.Base(tool)
+		for _, d := range sameDir {
+			command := generateCommand(d.Args)
+			if command == tool || strings.HasPrefix(tool, "protoc-gen-") && (command == "protoc" || command == "buf") {
+				return d
+			}
+		}
+	}
+	if len(sameDir) == 1 {
+		return sameDir[0]
+	}
+	return nil
+}
+
+// generateCommand は go:generate の実行するコマンドの名前を返す (go run path@version ならパスの最後の要素)
+func generateCommand(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	if args[0] != "go" || len(args) < 3 || args[1] != "run" {
+		return path.Base(args[0])
+	}
+	for _, arg := range args[2:] {
+		if !strings.HasPrefix(arg, "-") {
+			arg, _, _ = strings.Cut(arg, "@")
+			return path.Base(arg)
+		}
+	}
+	return ""
+}
+
+// argPaths は go:generate の引数 arg が指しうるファイルのパスを返す (-o=x.go、--go_out=opt:dir なら値も)
+func argPaths(arg string) []string {
+	paths := []string{arg}
+	if _, value, ok := strings.Cut(arg, "="); ok && strings.HasPrefix(arg, "-") {
+		paths = append(paths, value)
+		if i := strings.LastIndex(value, ":"); i >= 0 {
+			paths = append(paths, value[i+1:])
+		}
+	}
+	return paths
+}
+
+// resolveInput は dirs の順に name を探し、存在するファイルのパスを返す
+func resolveInput(dirs []string, name string) string {
+	if strings.HasPrefix(name, "-") || strings.HasPrefix(name, "$") {
+		return ""
+	}
+	for _, dir := range dirs {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if filepath.IsAbs(name) {
+			p = name
+		}
+		if info, err := os.Stat(p); err == nil && info.Mode().IsRegular() {
+			return p
+		}
+	}
+	return ""
+}
+
+// origin は生成されたファイルの指摘に添える、直すべき生成元の説明を返す
+func (p *Provenance) origin() string {
+	var s string
+	switch {
+	case p.Directive != nil:
+		s = fmt.Sprintf("go:generate %s at %s", strings.Join(p.Directive.Args, " "), relPosition(p.Directive.Pos, outputRoot))
+	case p.Generator != "":
+		s = "generated by " + p.Generator
+	default:
+		s = "unknown generator"
+	}
+	if len(p.Inputs) > 0 {
+		var inputs []string
+		for _, input := range p.Inputs {
+			inputs = append(inputs, relPath(input, outputRoot))
+		}
+		s += " from " + strings.Join(inputs, ", ")
+	}
+	return s
+}
+
+// annotateGenerated は生成されたファイルの指摘に、生成元を直すように Origin を設定する。
+// protoc で生成されたメッセージの型やメソッドの指摘には、.proto の定義の位置も添える
+func annotateGenerated(prog *Program, diags []Diagnostic) {
+	var origins map[string]string
+	var protos []ProtoType
+	for i, d := range diags {
+		if !prog.isGenerated(d.Pos.Filename) {
+			continue
+		}
+		if origins == nil {
+			origins = make(map[string]string)
+			for _, p := range generatedProvenance(prog) {
+				origins[p.File] = p.origin()
+			}
+			protos = protoTypes(prog)
+		}
+		diags[i].Origin = origins[d.Pos.Filename]
+		if t := protoTypeAt(protos, d.Pos); t != nil && t.Line > 0 {
+			diags[i].Origin += "; " + t.definition()
+		}
+	}
+}
+
+func writeProvenance(w io.Writer, result []*Provenance) error {
+	for _, p := range result {
+		fmt.Fprintf(w, "%s: %s\n", relPath(p.File, outputRoot), p.origin())
+		if len(p.Decls) > 0 {
+			fmt.Fprintf(w, "  declares %s\n", strings.Join(p.Decls, ", "))
+		}
+	}
+	return nil
+}
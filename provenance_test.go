@@ -0,0 +1,66 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGeneratedProvenance(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"api/api.proto": "syntax = \"proto3\";\n",
+		"api/gen.go":    "package api\n\n//go:generate protoc --go_out=. api.proto\n",
+		"api/api.pb.go": `// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.2
+// source: api/api.proto
+
+package api
+
+type Request struct{ Name string }
+
+func (r *Request) GetName() string { return r.Name }
+`,
+		"pill/pill.go": `package pill
+
+//go:generate stringer -type=Pill -output=pill_string.go
+//go:generate go run ./gen -tmpl names.tmpl
+
+type Pill int
+`,
+		"pill/names.tmpl": "{{.}}\n",
+		"pill/pill_string.go": `// Code generated by "stringer -type=Pill -output=pill_string.go"; DO NOT EDIT.
+
+package pill
+
+func (i Pill) String() string { return "" }
+`,
+		"pill/names.go": `// Code generated by gen. DO NOT EDIT.
+
+package pill
+
+var Names = []string{}
+`,
+		"pill/gen/main.go": "package main\n\nfunc main() {}\n",
+	})
+	prog, err := loadProgramWith(loadOptions{Tests: true}, dir, "./...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, p := range generatedProvenance(prog) {
+		line := filepath.ToSlash(strings.TrimPrefix(p.File, dir+string(filepath.Separator))) + ": " + p.origin() + " declares " + strings.Join(p.Decls, ",")
+		got = append(got, strings.ReplaceAll(line, filepath.ToSlash(dir)+"/", ""))
+	}
+	assertLines(t, got, []string{
+		"api/api.pb.go: go:generate protoc --go_out=. api.proto at api/gen.go:3 from api/api.proto declares Request,Request.GetName",
+		"pill/names.go: go:generate go run ./gen -tmpl names.tmpl at pill/pill.go:4 from pill/names.tmpl declares Names",
+		"pill/pill_string.go: go:generate stringer -type=Pill -output=pill_string.go at pill/pill.go:3 declares Pill.String",
+	})
+
+	diags := []Diagnostic{{Pos: prog.Fset.Position(prog.Packages[0].Syntax[0].Pos()), Category: "nilness", Message: "m"}}
+	annotateGenerated(prog, diags)
+	if prog.isGenerated(diags[0].Pos.Filename) == (diags[0].Origin == "") {
+		t.Errorf("Origin of %s = %q", diags[0].Pos.Filename, diags[0].Origin)
+	}
+}
@@ -0,0 +1,488 @@
+package main
+
+import (
+	"flag"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"reflect"
+)
+
+const (
+	wirePkg = "github.com/google/wire"
+	fxPkg   = "go.uber.org/fx"
+)
+
+func runProviderCheck(args []string) error {
+	fs := flag.NewFlagSet("providercheck", flag.ExitOnError)
+	// wire のインジェクタは wireinject のビルドタグ付きのファイルに書くので、そのタグで読み込む
+	return runDiagnosticsWith(fs, args, loadOptions{Tags: []string{"wireinject"}}, func(prog *Program) ([]Diagnostic, error) {
+		return checkProviders(prog), nil
+	})
+}
+
+// diContainer は wire のインジェクタ (wire.Build の呼び出し) か fx のアプリケーション (fx.New の呼び出し) 1 つ
+type diContainer struct {
+	kind      string // wire または fx
+	name      string // インジェクタの関数名か fx.New
+	providers []*diProvider
+	demands   []diDemand // インジェクタの結果、fx.Invoke に渡した関数の引数
+}
+
+// diProvider はコンテナに登録した値の作り方 1 つ (コンストラクタ、wire.Bind、wire.Value、fx.Supply など)
+type diProvider struct {
+	name     string
+	pos      token.Pos // 登録した位置
+	provides []types.Type
+	needs    []types.Type
+	fn       *types.Func // コンストラクタの関数。関数でなければ nil
+	arg      bool        // インジェクタの引数 (使われなくても指摘しない)
+}
+
+// diDemand はコンテナから取り出す型
+type diDemand struct {
+	typ types.Type
+	by  string
+	pos token.Pos
+}
+
+// checkProviders は google/wire の wire.Build と uber/fx の fx.New に登録したプロバイダ (wire.NewSet や fx.Options、
+// それらを代入したパッケージ変数をたどる) から依存を解決し、作れない依存、同じ型の重複したプロバイダ、
+// どのインジェクタの結果や fx.Invoke にも使われないプロバイダを指摘する。
+// wire か fx を使っていれば、どこにも登録していない NewX のコンストラクタ (wiringGraph と同じもの) も指摘する
+func checkProviders(prog *Program) []Diagnostic {
+	containers := diContainers(prog)
+	if len(containers) == 0 {
+		return nil
+	}
+	var diags []Diagnostic
+	report := func(pos token.Pos, severity, format string, args ...interface{}) {
+		d := newDiagnostic(prog.Fset, pos, "providers", format, args...)
+		d.Severity = severity
+		diags = append(diags, d)
+	}
+	used := make(map[*diProvider]bool)
+	registered := make(map[*types.Func]bool)
+	var all []*diProvider
+	for _, c := range containers {
+		byType := make(map[string][]*diProvider)
+		for _, p := range c.providers {
+			for _, t := range p.provides {
+				k := types.TypeString(t, nil)
+				if len(byType[k]) > 0 {
+					report(p.pos, severityError, "%s is provided by both %s and %s in %s", wiringTypeString(t), byType[k][0].name, p.name, c.name)
+				}
+				byType[k] = append(byType[k], p)
+			}
+			if p.fn != nil {
+				registered[p.fn] = true
+			}
+			if !p.arg {
+				all = append(all, p)
+			}
+		}
+		reported := make(map[string]bool)
+		queue := append([]diDemand(nil), c.demands...)
+		for len(queue) > 0 {
+			d := queue[0]
+			queue = queue[1:]
+			k := types.TypeString(d.typ, nil)
+			found := byType[k]
+			if len(found) == 0 && !reported[k+" "+d.by] {
+				reported[k+" "+d.by] = true
+				report(d.pos, severityError, "%s needed by %s has no provider in %s", wiringTypeString(d.typ), d.by, c.name)
+			}
+			for _, p := range found {
+				if used[p] {
+					continue
+				}
+				used[p] = true
+				for _, t := range p.needs {
+					queue = append(queue, diDemand{typ: t, by: p.name, pos: p.pos})
+				}
+			}
+		}
+	}
+	seen := make(map[*diProvider]bool)
+	for _, p := range all {
+		if used[p] || seen[p] {
+			continue
+		}
+		seen[p] = true
+		if isFxProvider(containers, p) {
+			report(p.pos, severityInfo, "provider %s is not needed by any fx.Invoke", p.name)
+		} else {
+			report(p.pos, severityWarning, "provider %s is not needed by any injector", p.name)
+		}
+	}
+	for _, ctor := range constructors(prog) {
+		if !registered[ctor.obj] {
+			report(ctor.obj.Pos(), severityInfo, "constructor %s is not registered with any wire injector or fx application", ctor.Name)
+		}
+	}
+	sortDiagnostics(diags)
+	return diags
+}
+
+// isFxProvider は p が fx のアプリケーションに登録されたものかどうかを返す
+func isFxProvider(containers []*diContainer, p *diProvider) bool {
+	for _, c := range containers {
+		for _, q := range c.providers {
+			if q == p {
+				return c.kind == "fx"
+			}
+		}
+	}
+	return false
+}
+
+// diContainers は解析対象のパッケージの wire.Build と fx.New の呼び出しから、登録したプロバイダと取り出す型を集める
+func diContainers(prog *Program) []*diContainer {
+	d := &diCollector{prog: prog, vars: make(map[types.Object]varInit), providers: make(map[token.Pos]*diProvider)}
+	for _, pkg := range prog.Packages {
+		for _, file := range pkg.Syntax {
+			for _, decl := range file.Decls {
+				gd, ok := decl.(*ast.GenDecl)
+				if !ok || gd.Tok != token.VAR {
+					continue
+				}
+				for _, spec := range gd.Specs {
+					vs := spec.(*ast.ValueSpec)
+					for i, name := range vs.Names {
+						if len(vs.Values) == len(vs.Names) {
+							d.vars[pkg.TypesInfo.Defs[name]] = varInit{vs.Values[i], pkg.TypesInfo}
+						}
+					}
+				}
+			}
+		}
+	}
+	var containers []*diContainer
+	for _, pkg := range prog.Packages {
+		info := pkg.TypesInfo
+		for _, file := range pkg.Syntax {
+			var fn *ast.FuncDecl
+			ast.Inspect(file, func(n ast.Node) bool {
+				if decl, ok := n.(*ast.FuncDecl); ok {
+					fn = decl
+				}
+				call, ok := n.(*ast.CallExpr)
+				if !ok {
+					return true
+				}
+				switch path, name := pkgFuncName(info, call); {
+				case path == wirePkg && name == "Build" && fn != nil:
+					d.c = &diContainer{kind: "wire", name: fn.Name.Name}
+					sig := info.Defs[fn.Name].Type().(*types.Signature)
+					for i := 0; i < sig.Params().Len(); i++ {
+						p := sig.Params().At(i)
+						d.c.providers = append(d.c.providers, &diProvider{
+							name: "argument " + p.Name(), pos: p.Pos(), provides: []types.Type{p.Type()}, arg: true,
+						})
+					}
+					for _, t := range providedTypes(sig.Results()) {
+						d.c.demands = append(d.c.demands, diDemand{typ: t, by: fn.Name.Name, pos: fn.Name.Pos()})
+					}
+				case path == fxPkg && name == "New":
+					d.c = &diContainer{kind: "fx", name: "fx.New"}
+					for _, builtin := range []string{"Lifecycle", "Shutdowner", "DotGraph"} {
+						if obj := calleeObject(info, call).Pkg().Scope().Lookup(builtin); obj != nil {
+							d.c.providers = append(d.c.providers, &diProvider{name: "fx." + builtin, pos: call.Pos(), provides: []types.Type{obj.Type()}, arg: true})
+						}
+					}
+				default:
+					return true
+				}
+				d.visiting = make(map[types.Object]bool)
+				for _, arg := range call.Args {
+					d.add(info, arg)
+				}
+				containers = append(containers, d.c)
+				return false
+			})
+		}
+	}
+	return containers
+}
+
+// varInit はパッケージ変数の初期化式
+type varInit struct {
+	expr ast.Expr
+	info *types.Info
+}
+
+// diCollector は wire と fx のプロバイダの式をたどって、コンテナ c に登録する
+type diCollector struct {
+	prog      *Program
+	vars      map[types.Object]varInit
+	providers map[token.Pos]*diProvider // 登録した位置ごとのプロバイダ。同じセットを複数のコンテナで使うと共有する
+	visiting  map[types.Object]bool
+	c         *diContainer
+}
+
+// add は式 e が表すプロバイダ、プロバイダのセット、fx のオプションを c に登録する
+func (d *diCollector) add(info *types.Info, e ast.Expr) {
+	e = ast.Unparen(e)
+	switch e := e.(type) {
+	case *ast.Ident, *ast.SelectorExpr:
+		switch obj := info.Uses[identOf(e)].(type) {
+		case *types.Func:
+			d.register(e.Pos(), func() *diProvider { return funcProvider(obj, obj.Type().(*types.Signature), e.Pos()) })
+		case *types.Var:
+			if init, ok := d.vars[obj]; ok && !d.visiting[obj] {
+				d.visiting[obj] = true
+				d.add(init.info, init.expr)
+				delete(d.visiting, obj)
+			}
+		}
+	case *ast.FuncLit:
+		d.register(e.Pos(), func() *diProvider {
+			return funcProvider(nil, info.TypeOf(e).(*types.Signature), e.Pos())
+		})
+	case *ast.CallExpr:
+		d.addCall(info, e)
+	}
+}
+
+// register は pos で登録したプロバイダを c に加える。初めての位置なら newProvider で作る
+func (d *diCollector) register(pos token.Pos, newProvider func() *diProvider) {
+	p, ok := d.providers[pos]
+	if !ok {
+		p = newProvider()
+		d.providers[pos] = p
+	}
+	d.c.providers = append(d.c.providers, p)
+}
+
+func (d *diCollector) addCall(info *types.Info, call *ast.CallExpr) {
+	path, name := pkgFuncName(info, call)
+	newType := func(i int) types.Type {
+		if i >= len(call.Args) {
+			return nil
+		}
+		if p, ok := info.TypeOf(call.Args[i]).(*types.Pointer); ok {
+			return p.Elem()
+		}
+		return nil
+	}
+	value := func(label string, t types.Type, needs ...types.Type) {
+		if t == nil {
+			return
+		}
+		d.register(call.Pos(), func() *diProvider {
+			return &diProvider{name: label, pos: call.Pos(), provides: []types.Type{t}, needs: needs}
+		})
+	}
+	switch path + "." + name {
+	case wirePkg + ".NewSet", fxPkg + ".Options", fxPkg + ".Provide":
+		for _, arg := range call.Args {
+			d.add(info, arg)
+		}
+	case fxPkg + ".Module":
+		for _, arg := range call.Args[1:] {
+			d.add(info, arg)
+		}
+	case wirePkg + ".Bind":
+		if iface, to := newType(0), newType(1); iface != nil && to != nil {
+			value("wire.Bind("+wiringTypeString(iface)+")", iface, to)
+		}
+	case wirePkg + ".Value":
+		value("wire.Value("+wiringTypeString(info.TypeOf(call.Args[0]))+")", info.TypeOf(call.Args[0]))
+	case wirePkg + ".InterfaceValue":
+		if iface := newType(0); iface != nil {
+			value("wire.InterfaceValue("+wiringTypeString(iface)+")", iface)
+		}
+	case wirePkg + ".Struct":
+		t := newType(0)
+		if t == nil {
+			return
+		}
+		st, ok := t.Underlying().(*types.Struct)
+		if !ok {
+			return
+		}
+		d.register(call.Pos(), func() *diProvider {
+			p := &diProvider{name: "wire.Struct(" + wiringTypeString(t) + ")", pos: call.Pos(), provides: []types.Type{t, types.NewPointer(t)}}
+			for _, f := range structFields(st, info, call.Args[1:]) {
+				p.needs = append(p.needs, f.Type())
+			}
+			return p
+		})
+	case wirePkg + ".FieldsOf":
+		t := newType(0)
+		if t == nil {
+			return
+		}
+		base := t
+		if p, ok := t.(*types.Pointer); ok {
+			base = p.Elem()
+		}
+		st, ok := base.Underlying().(*types.Struct)
+		if !ok {
+			return
+		}
+		d.register(call.Pos(), func() *diProvider {
+			p := &diProvider{name: "wire.FieldsOf(" + wiringTypeString(t) + ")", pos: call.Pos(), needs: []types.Type{t}}
+			for _, f := range structFields(st, info, call.Args[1:]) {
+				p.provides = append(p.provides, f.Type())
+			}
+			return p
+		})
+	case fxPkg + ".Supply":
+		for _, arg := range call.Args {
+			t := info.TypeOf(arg)
+			d.register(arg.Pos(), func() *diProvider {
+				return &diProvider{name: "fx.Supply(" + wiringTypeString(t) + ")", pos: arg.Pos(), provides: []types.Type{t}}
+			})
+		}
+	case fxPkg + ".Annotate":
+		d.annotate(info, call)
+	case fxPkg + ".Invoke":
+		for _, arg := range call.Args {
+			sig, ok := info.TypeOf(arg).Underlying().(*types.Signature)
+			if !ok {
+				continue
+			}
+			by := "fx.Invoke(" + types.ExprString(arg) + ")"
+			if _, ok := ast.Unparen(arg).(*ast.FuncLit); ok {
+				by = "fx.Invoke(func literal)"
+			}
+			for _, t := range neededTypes(sig) {
+				d.c.demands = append(d.c.demands, diDemand{typ: t, by: by, pos: arg.Pos()})
+			}
+		}
+	}
+}
+
+// annotate は fx.Annotate(f, ...) を f のプロバイダとして登録する。fx.As(new(I)) があれば I を作るものとする
+func (d *diCollector) annotate(info *types.Info, call *ast.CallExpr) {
+	if len(call.Args) == 0 {
+		return
+	}
+	sig, ok := info.TypeOf(call.Args[0]).Underlying().(*types.Signature)
+	if !ok {
+		return
+	}
+	var fn *types.Func
+	if id := identOf(call.Args[0]); id != nil {
+		fn, _ = info.Uses[id].(*types.Func)
+	}
+	d.register(call.Pos(), func() *diProvider {
+		p := funcProvider(fn, sig, call.Pos())
+		for _, ann := range call.Args[1:] {
+			as, ok := ast.Unparen(ann).(*ast.CallExpr)
+			if path, name := pkgFuncName(info, as); !ok || path != fxPkg || name != "As" {
+				continue
+			}
+			p.provides = nil
+			for _, arg := range as.Args {
+				if t, ok := info.TypeOf(arg).(*types.Pointer); ok {
+					p.provides = append(p.provides, t.Elem())
+				}
+			}
+		}
+		return p
+	})
+}
+
+// funcProvider は関数 (fn が nil なら関数リテラル) のプロバイダを作る
+func funcProvider(fn *types.Func, sig *types.Signature, pos token.Pos) *diProvider {
+	p := &diProvider{name: "func literal", pos: pos, fn: fn, needs: neededTypes(sig), provides: providedTypes(sig.Results())}
+	if fn != nil {
+		p.name = fn.Pkg().Name() + "." + fn.Name()
+	}
+	return p
+}
+
+// neededTypes はプロバイダの関数の引数の型を返す。fx.In を埋め込んだ構造体はフィールドに展開し、
+// optional:"true" と group のタグのあるフィールドと可変長引数は除く
+func neededTypes(sig *types.Signature) []types.Type {
+	var needs []types.Type
+	for i := 0; i < sig.Params().Len(); i++ {
+		if sig.Variadic() && i == sig.Params().Len()-1 {
+			continue
+		}
+		needs = append(needs, fxFields(sig.Params().At(i).Type(), "In")...)
+	}
+	return needs
+}
+
+// providedTypes はプロバイダの関数の結果のうち、error と後始末の func() を除いた型を返す。fx.Out を埋め込んだ構造体はフィールドに展開する
+func providedTypes(results *types.Tuple) []types.Type {
+	var provides []types.Type
+	for i := 0; i < results.Len(); i++ {
+		if t := results.At(i).Type(); !isErrorType(t) && !isCleanupFunc(t) {
+			provides = append(provides, fxFields(t, "Out")...)
+		}
+	}
+	return provides
+}
+
+// fxFields は t が fx.<marker> を埋め込んだ構造体なら、マーカーとオプションのもの、グループのものを除いたフィールドの型を返す。
+// そうでなければ t だけを返す
+func fxFields(t types.Type, marker string) []types.Type {
+	st, ok := t.Underlying().(*types.Struct)
+	if !ok {
+		return []types.Type{t}
+	}
+	isParam := false
+	var fields []types.Type
+	for i := 0; i < st.NumFields(); i++ {
+		f := st.Field(i)
+		if n, ok := types.Unalias(f.Type()).(*types.Named); ok && f.Embedded() && n.Obj().Pkg() != nil &&
+			n.Obj().Pkg().Path() == fxPkg && n.Obj().Name() == marker {
+			isParam = true
+			continue
+		}
+		tag := reflect.StructTag(st.Tag(i))
+		if tag.Get("optional") == "true" || tag.Get("group") != "" {
+			continue
+		}
+		fields = append(fields, f.Type())
+	}
+	if !isParam {
+		return []types.Type{t}
+	}
+	return fields
+}
+
+// structFields は wire.Struct と wire.FieldsOf のフィールド名の引数 names ("*" ならすべて) のフィールドを返す
+func structFields(st *types.Struct, info *types.Info, names []ast.Expr) []*types.Var {
+	want := make(map[string]bool)
+	for _, n := range names {
+		if tv, ok := info.Types[n]; ok && tv.Value != nil && tv.Value.Kind() == constant.String {
+			want[constant.StringVal(tv.Value)] = true
+		}
+	}
+	var fields []*types.Var
+	for i := 0; i < st.NumFields(); i++ {
+		if f := st.Field(i); want["*"] || want[f.Name()] {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// pkgFuncName は call がパッケージの関数の呼び出しなら、パッケージのパスと関数名を返す
+func pkgFuncName(info *types.Info, call *ast.CallExpr) (string, string) {
+	if call == nil {
+		return "", ""
+	}
+	fn, ok := calleeObject(info, call).(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Type().(*types.Signature).Recv() != nil {
+		return "", ""
+	}
+	return fn.Pkg().Path(), fn.Name()
+}
+
+// identOf は e が識別子かセレクターなら、その (セレクターなら右側の) 識別子を返す
+func identOf(e ast.Expr) *ast.Ident {
+	switch e := ast.Unparen(e).(type) {
+	case *ast.Ident:
+		return e
+	case *ast.SelectorExpr:
+		return e.Sel
+	}
+	return nil
+}
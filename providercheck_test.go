@@ -0,0 +1,166 @@
+package main
+
+import (
+	"testing"
+)
+
+const testdata_wire = `package wire
+
+type ProviderSet struct{}
+
+func NewSet(...interface{}) ProviderSet { return ProviderSet{} }
+
+func Build(...interface{}) string { return "" }
+
+type Binding struct{}
+
+func Bind(iface, to interface{}) Binding { return Binding{} }
+
+type ProvidedValue struct{}
+
+func Value(interface{}) ProvidedValue { return ProvidedValue{} }
+
+type StructProvider struct{}
+
+func Struct(structType interface{}, fieldNames ...string) StructProvider { return StructProvider{} }
+`
+
+const testdata_fx = `package fx
+
+type Option interface{}
+
+type App struct{}
+
+func New(opts ...Option) *App { return nil }
+
+func Provide(constructors ...interface{}) Option { return nil }
+
+func Invoke(funcs ...interface{}) Option { return nil }
+
+func Supply(values ...interface{}) Option { return nil }
+
+func Options(opts ...Option) Option { return nil }
+
+func Module(name string, opts ...Option) Option { return nil }
+
+type Annotation interface{}
+
+func Annotate(t interface{}, anns ...Annotation) interface{} { return t }
+
+func As(interfaces ...interface{}) Annotation { return nil }
+
+type In struct{}
+
+type Lifecycle interface{ Append() }
+`
+
+func TestCheckProviders(t *testing.T) {
+	files := map[string]string{
+		"go.mod": `module example.com/m
+
+go 1.22
+
+require (
+	github.com/google/wire v0.0.0
+	go.uber.org/fx v0.0.0
+)
+
+replace github.com/google/wire => ./third_party/wire
+
+replace go.uber.org/fx => ./third_party/fx
+`,
+		"third_party/wire/go.mod":  "module github.com/google/wire\n\ngo 1.22\n",
+		"third_party/wire/wire.go": testdata_wire,
+		"third_party/fx/go.mod":    "module go.uber.org/fx\n\ngo 1.22\n",
+		"third_party/fx/fx.go":     testdata_fx,
+		"app/app.go": `package app
+
+type Config struct{ DSN string }
+
+type DB struct{}
+
+func NewDB(cfg Config) (*DB, func(), error) { return &DB{}, func() {}, nil }
+
+type Store interface{ Get() string }
+
+type dbStore struct{ db *DB }
+
+func (dbStore) Get() string { return "" }
+
+func NewDBStore(db *DB) *dbStore { return &dbStore{db} }
+
+type Cache struct{}
+
+func NewCache() *Cache { return &Cache{} }
+
+type Logger struct{}
+
+func NewLogger() *Logger { return &Logger{} }
+
+type Server struct {
+	Store Store
+	Log   *Logger
+}
+
+type Metrics struct{}
+
+func NewMetrics(l *Logger) *Metrics { return &Metrics{} }
+
+type Audit struct{}
+
+func NewAudit() *Audit { return &Audit{} }
+`,
+		"app/wire.go": `//go:build wireinject
+
+package app
+
+import "github.com/google/wire"
+
+var StoreSet = wire.NewSet(NewDB, NewDBStore, wire.Bind(new(Store), new(*dbStore)))
+
+func InitServer(cfg Config) (*Server, func(), error) {
+	panic(wire.Build(StoreSet, NewLogger, NewCache, wire.Struct(new(Server), "*")))
+}
+
+func InitMetrics() *Metrics {
+	panic(wire.Build(NewMetrics))
+}
+`,
+		"main.go": `package main
+
+import (
+	"example.com/m/app"
+	"go.uber.org/fx"
+)
+
+var Module = fx.Module("app",
+	fx.Provide(app.NewLogger, fx.Annotate(app.NewDBStore, fx.As(new(app.Store)))),
+)
+
+func run(lc fx.Lifecycle, s app.Store) {}
+
+func main() {
+	fx.New(
+		Module,
+		fx.Provide(app.NewCache),
+		fx.Supply(&app.Cache{}),
+		fx.Invoke(run),
+	)
+}
+`,
+	}
+	prog, err := loadProgramWith(loadOptions{Tags: []string{"wireinject"}}, writeModule(t, files), "./...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertLines(t, diagnosticMessages(checkProviders(prog)), []string{
+		"app.go:36: constructor app.NewAudit is not registered with any wire injector or fx application",
+		"wire.go:10: provider app.NewCache is not needed by any injector",
+		"wire.go:14: *app.Logger needed by app.NewMetrics has no provider in InitMetrics",
+		"main.go:9: provider app.NewLogger is not needed by any fx.Invoke",
+		"main.go:9: *app.DB needed by app.NewDBStore has no provider in fx.New",
+		"main.go:17: provider app.NewCache is not needed by any fx.Invoke",
+		"main.go:18: *app.Cache is provided by both app.NewCache and fx.Supply(*app.Cache) in fx.New",
+		"main.go:18: provider fx.Supply(*app.Cache) is not needed by any fx.Invoke",
+	})
+}
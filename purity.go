@@ -0,0 +1,277 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/token"
+	"io"
+	"os"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// pureStdPackages は副作用のない関数だけを公開している標準パッケージ
+var pureStdPackages = map[string]bool{
+	"errors":       true,
+	"math":         true,
+	"math/bits":    true,
+	"strconv":      true,
+	"strings":      true,
+	"unicode":      true,
+	"unicode/utf8": true,
+}
+
+// Purity は関数 1 つの純粋性の判定結果
+type Purity struct {
+	Func    string         `json:"func"`
+	Pos     token.Position `json:"pos"`
+	Pure    bool           `json:"pure"`
+	Reasons []string       `json:"reasons,omitempty"` // 純粋でない理由
+}
+
+func runPurity(args []string) error {
+	fs := flag.NewFlagSet("purity", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output as JSON")
+	onlyPure := fs.Bool("pure", false, "list pure functions only")
+	std := fs.String("std", stdKeep, "how to show calls into the standard library in reasons: keep, collapse or exclude")
+	fs.Parse(args)
+	if err := checkStdMode(*std); err != nil {
+		return err
+	}
+	prog, err := loadProgram(".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	all := purityWith(prog, *std)
+	if err := exportPurity(prog, all); err != nil {
+		return err
+	}
+	var result []Purity
+	for _, p := range all {
+		if p.Pure || !*onlyPure {
+			result = append(result, p)
+		}
+	}
+	if *asJSON {
+		return writeJSON(os.Stdout, result)
+	}
+	return writePurity(os.Stdout, result)
+}
+
+// purity は解析対象の各関数について、外から見えるメモリへの書き込み、
+// I/O、パッケージ変数の読み書きがないかを SSA から判定する
+func purity(prog *Program) []Purity {
+	return purityWith(prog, stdKeep)
+}
+
+// purityWith は purity と同じだが、標準ライブラリの呼び出しによる理由を std に従って
+// パッケージごと (collapse) または "calls the standard library" 1 つ (exclude) にまとめる
+func purityWith(prog *Program, std string) []Purity {
+	fns := prog.targetFunctions()
+	reasons := make(map[*ssa.Function][]string)
+	for _, fn := range fns {
+		reasons[fn] = impureInstrs(fn)
+	}
+
+	// 純粋でない関数を呼び出している関数も純粋でない。変化がなくなるまで伝播させる
+	for changed := true; changed; {
+		changed = false
+		for _, fn := range fns {
+			if len(reasons[fn]) > 0 {
+				continue
+			}
+			for _, callee := range staticCallees(fn) {
+				r, ok := reasons[callee]
+				if !ok && callee.Pkg != nil && pureStdPackages[callee.Pkg.Pkg.Path()] {
+					continue
+				}
+				if !ok && importedPure(prog, callee) {
+					continue
+				}
+				if ok && len(r) == 0 {
+					continue
+				}
+				changed = true
+				switch {
+				case std == stdKeep || !isStdFunc(callee):
+					reasons[fn] = append(reasons[fn], "calls "+callee.RelString(fn.Pkg.Pkg))
+				case std == stdCollapse:
+					reasons[fn] = appendUnique(reasons[fn], "calls "+callee.Pkg.Pkg.Path())
+				default:
+					reasons[fn] = appendUnique(reasons[fn], "calls the standard library")
+				}
+			}
+		}
+	}
+
+	var result []Purity
+	for _, fn := range fns {
+		if fn.Synthetic != "" {
+			continue
+		}
+		result = append(result, Purity{
+			Func:    fn.RelString(nil),
+			Pos:     prog.Fset.Position(fn.Pos()),
+			Pure:    len(reasons[fn]) == 0,
+			Reasons: reasons[fn],
+		})
+	}
+	return result
+}
+
+// importedPure は解析対象でないパッケージの callee が、-facts に保存された事実で純粋とされているかどうかを返す
+func importedPure(prog *Program, callee *ssa.Function) bool {
+	pkg := ssaFuncPackage(callee)
+	if pkg == nil {
+		return false
+	}
+	facts := prog.importFacts(pkg.Path())
+	return facts != nil && facts.Pure[callee.RelString(nil)]
+}
+
+// exportPurity は result の純粋性を解析対象のパッケージの事実として保存する
+func exportPurity(prog *Program, result []Purity) error {
+	pure := make(map[string]bool)
+	for _, p := range result {
+		pure[p.Func] = p.Pure
+	}
+	byPkg := make(map[string]map[string]bool)
+	for _, fn := range prog.targetFunctions() {
+		pkg := ssaFuncPackage(fn)
+		name := fn.RelString(nil)
+		if _, ok := pure[name]; !ok || pkg == nil {
+			continue
+		}
+		if byPkg[pkg.Path()] == nil {
+			byPkg[pkg.Path()] = make(map[string]bool)
+		}
+		byPkg[pkg.Path()][name] = pure[name]
+	}
+	return prog.exportFacts(func(pkgPath string, f *PackageFacts) { f.Pure = byPkg[pkgPath] })
+}
+
+// appendUnique は list に s がなければ追加する
+func appendUnique(list []string, s string) []string {
+	for _, x := range list {
+		if x == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
+
+// pureFunctions は純粋と判定された関数の集合を返す
+func pureFunctions(prog *Program) map[string]bool {
+	pure := make(map[string]bool)
+	for _, p := range purity(prog) {
+		if p.Pure {
+			pure[p.Func] = true
+		}
+	}
+	return pure
+}
+
+// staticCallees は fn (と、その中の無名関数) が静的に呼び出している関数を返す
+func staticCallees(fn *ssa.Function) []*ssa.Function {
+	var callees []*ssa.Function
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			if call, ok := instr.(ssa.CallInstruction); ok {
+				if callee := call.Common().StaticCallee(); callee != nil {
+					callees = append(callees, callee)
+				}
+			}
+		}
+	}
+	return callees
+}
+
+// impureInstrs は fn 自身の命令のうち副作用のあるものを理由として返す
+func impureInstrs(fn *ssa.Function) []string {
+	var reasons []string
+	seen := make(map[string]bool)
+	add := func(reason string) {
+		if !seen[reason] {
+			seen[reason] = true
+			reasons = append(reasons, reason)
+		}
+	}
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			switch instr := instr.(type) {
+			case *ssa.Store:
+				if !isLocalMemory(fn, instr.Addr) {
+					add("writes to non-local memory")
+				}
+			case *ssa.MapUpdate:
+				if !isLocalMemory(fn, instr.Map) {
+					add("writes to non-local map")
+				}
+			case *ssa.Send, *ssa.Select:
+				add("communicates on a channel")
+			case *ssa.Go:
+				add("starts a goroutine")
+			case *ssa.UnOp:
+				if instr.Op == token.ARROW {
+					add("communicates on a channel")
+				} else if globalOf(instr.X) != nil {
+					add("reads a package-level variable")
+				}
+			case ssa.CallInstruction:
+				common := instr.Common()
+				if b, ok := common.Value.(*ssa.Builtin); ok {
+					switch model := builtinModels[b.Name()]; {
+					case model.Effect == "io":
+						add("performs I/O")
+					case model.Effect == "close" || model.Effect == "recover":
+						add("calls " + b.Name())
+					case model.Writes:
+						if !isLocalMemory(fn, common.Args[0]) {
+							add("writes to non-local memory")
+						}
+					}
+					continue
+				}
+				if common.StaticCallee() == nil {
+					add("makes a dynamic call")
+				}
+			}
+		}
+	}
+	return reasons
+}
+
+// isLocalMemory は addr が fn の中で確保したメモリ (Alloc や make) を指しているかどうかを返す
+func isLocalMemory(fn *ssa.Function, addr ssa.Value) bool {
+	for {
+		switch x := addr.(type) {
+		case *ssa.Alloc:
+			return x.Parent() == fn
+		case *ssa.MakeMap, *ssa.MakeSlice, *ssa.MakeChan:
+			return x.(ssa.Instruction).Parent() == fn
+		case *ssa.FieldAddr:
+			addr = x.X
+		case *ssa.IndexAddr:
+			addr = x.X
+		case *ssa.Slice:
+			addr = x.X
+		default:
+			return false
+		}
+	}
+}
+
+// writePurity は判定結果をテキストで出力する
+func writePurity(w io.Writer, result []Purity) error {
+	for _, p := range result {
+		if p.Pure {
+			fmt.Fprintf(w, "%s\tpure\n", p.Func)
+			continue
+		}
+		fmt.Fprintf(w, "%s\timpure\n", p.Func)
+		for _, reason := range p.Reasons {
+			fmt.Fprintf(w, "  %s\n", reason)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPurity(t *testing.T) {
+	src := `package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Calculator struct {
+	base  int
+	calls int
+}
+
+func NewCalculator() *Calculator {
+	return &Calculator{}
+}
+
+func (c *Calculator) add(a, b int) int {
+	return a + b + c.base
+}
+
+func (c *Calculator) count() {
+	c.calls++
+}
+
+var total int
+
+func record(v int) {
+	total += v
+}
+
+func scaled(v int) int {
+	return v * total
+}
+
+func squares(n int) []int {
+	s := make([]int, n)
+	for i := range s {
+		s[i] = i * i
+	}
+	return s
+}
+
+func shout(s string) string {
+	return strings.ToUpper(s) + "!"
+}
+
+func greet(name string) {
+	fmt.Println("hello", name)
+}
+
+func fib(n int) int {
+	if n < 2 {
+		return n
+	}
+	return fib(n-1) + fib(n-2)
+}
+
+func main() {
+	c := NewCalculator()
+	c.count()
+	record(c.add(1, 2))
+	greet(shout("x"))
+	_ = squares(fib(scaled(3)))
+}
+`
+	prog := loadTestProgram(t, map[string]string{"main.go": src})
+	var buf bytes.Buffer
+	if err := writePurity(&buf, purity(prog)); err != nil {
+		t.Fatal(err)
+	}
+	assertLines(t, strings.Split(strings.TrimSpace(buf.String()), "\n"), []string{
+		"example.com/m.NewCalculator\tpure",
+		"(*example.com/m.Calculator).add\tpure",
+		"(*example.com/m.Calculator).count\timpure",
+		"  writes to non-local memory",
+		"example.com/m.record\timpure",
+		"  reads a package-level variable",
+		"  writes to non-local memory",
+		"example.com/m.scaled\timpure",
+		"  reads a package-level variable",
+		"example.com/m.squares\tpure",
+		"example.com/m.shout\tpure",
+		"example.com/m.greet\timpure",
+		"  calls fmt.Println",
+		"example.com/m.fib\tpure",
+		"example.com/m.main\timpure",
+		"  calls (*Calculator).count",
+		"  calls record",
+		"  calls greet",
+		"  calls scaled",
+	})
+}
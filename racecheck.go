@@ -0,0 +1,279 @@
+package main
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// raceContext は同時に動きうるコードのまとまり。エントリポイントから go 文を通らずに到達するコードか、
+// 1 つの go 文で起動する goroutine から到達するコード
+type raceContext struct {
+	spawn *ssa.Go // nil ならエントリポイント
+	loop  bool    // go 文がループの中にあり、同じ goroutine が同時にいくつも動きうる
+}
+
+func (c *raceContext) String() string {
+	if c.spawn == nil {
+		return "the main goroutine"
+	}
+	line := c.spawn.Parent().Prog.Fset.Position(c.spawn.Pos()).Line
+	if c.loop {
+		return fmt.Sprintf("the goroutines started in a loop at line %d", line)
+	}
+	return fmt.Sprintf("the goroutine started at line %d", line)
+}
+
+// sharedAccess は共有される変数の 1 つの読み書き
+type sharedAccess struct {
+	instr  ssa.Instruction
+	write  bool
+	ctx    *raceContext
+	synced bool // 起点からこの関数までの呼び出しの経路に、ロック、アトミック操作、チャネルの操作などがある
+}
+
+// checkRaces は、パッケージ変数と無名関数が捕捉した変数のうち、ある goroutine で書き込まれ、
+// 別の goroutine で読み書きされるものを指摘する。読み書きのどちらの側でも、起点 (エントリポイントか
+// go 文で起動する関数) からその関数までの呼び出しの経路に sync や sync/atomic の呼び出し、
+// チャネルの操作がなければ同期していないと見なす。go 文より前にある、go 文と同じ関数の中の
+// 読み書きは起動した goroutine より先に起きる。パッケージの初期化の中の読み書きは扱わない
+func checkRaces(prog *Program) []Diagnostic {
+	accesses := make(map[*ssa.Function][]sharedVarAccess)
+	for _, fn := range prog.targetFunctions() {
+		if fn.Synthetic != "package initializer" && !strings.HasPrefix(fn.Name(), "init#") && !(fn.Name() == "init" && fn.Parent() == nil) {
+			accesses[fn] = sharedVarAccesses(fn)
+		}
+	}
+	byVar := make(map[ssa.Value][]sharedAccess)
+	var vars []ssa.Value
+	collect := func(ctx *raceContext, roots []*ssa.Function) {
+		for fn, synced := range syncedReach(prog, roots) {
+			for _, a := range accesses[fn] {
+				if byVar[a.v] == nil {
+					vars = append(vars, a.v)
+				}
+				byVar[a.v] = append(byVar[a.v], sharedAccess{instr: a.instr, write: a.write, ctx: ctx, synced: synced})
+			}
+		}
+	}
+	collect(&raceContext{}, entryFunctions(prog, allEntryKinds))
+	for _, fn := range prog.targetFunctions() {
+		loops := loopBlocks(fn)
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				if g, ok := instr.(*ssa.Go); ok {
+					if callee := g.Call.StaticCallee(); callee != nil && callee.Blocks != nil {
+						collect(&raceContext{spawn: g, loop: loops[b]}, []*ssa.Function{callee})
+					}
+				}
+			}
+		}
+	}
+	var diags []Diagnostic
+	for _, v := range vars {
+		if w, other, ok := racingPair(byVar[v]); ok {
+			kind := "read"
+			if other.write {
+				kind = "written"
+			}
+			pos, otherPos := prog.Fset.Position(w.instr.Pos()), prog.Fset.Position(other.instr.Pos())
+			at := fmt.Sprintf("line %d", otherPos.Line)
+			if otherPos.Filename != pos.Filename {
+				at = fmt.Sprintf("%s:%d", relPath(otherPos.Filename, outputRoot), otherPos.Line)
+			}
+			by := other.ctx.String()
+			if other.ctx == w.ctx {
+				by = "another of " + by
+			}
+			diags = append(diags, newDiagnostic(prog.Fset, w.instr.Pos(), "race",
+				"%s is written here by %s and %s at %s by %s without synchronization", sharedVarName(v), w.ctx, kind, at, by))
+		}
+	}
+	sortDiagnostics(diags)
+	return diags
+}
+
+// racingPair は同期していない書き込みと、それと別の goroutine で起きうる読み書きの組を 1 つ返す。
+// 書き込みの位置の早いものを選ぶ
+func racingPair(accesses []sharedAccess) (w, other sharedAccess, ok bool) {
+	for _, a := range accesses {
+		if !a.write {
+			continue
+		}
+		for _, b := range accesses {
+			if a.synced && b.synced || a.ctx == b.ctx && (!a.ctx.loop || a.ctx.spawn == nil) ||
+				happensBeforeSpawn(a, b) || happensBeforeSpawn(b, a) {
+				continue
+			}
+			if !ok || a.instr.Pos() < w.instr.Pos() || a.instr.Pos() == w.instr.Pos() && b.instr.Pos() < other.instr.Pos() {
+				w, other, ok = a, b, true
+			}
+		}
+	}
+	return w, other, ok
+}
+
+// happensBeforeSpawn は a が b の goroutine を起動する関数の中の、go 文より前の読み書きかどうかを返す
+func happensBeforeSpawn(a, b sharedAccess) bool {
+	g := b.ctx.spawn
+	if g == nil || a.instr.Parent() != g.Parent() {
+		return false
+	}
+	if a.instr.Block() != g.Block() {
+		return a.instr.Block().Dominates(g.Block())
+	}
+	for _, instr := range g.Block().Instrs {
+		switch instr {
+		case a.instr:
+			return true
+		case g:
+			return false
+		}
+	}
+	return false
+}
+
+// syncedReach は roots から go 文を通らずに到達する関数と、起点からの経路のどれかに同期の操作があるかどうかを返す。
+// 静的な呼び出しとインターフェースのメソッドの呼び出しだけをたどる
+func syncedReach(prog *Program, roots []*ssa.Function) map[*ssa.Function]bool {
+	cg := prog.CallGraph()
+	synced := make(map[*ssa.Function]bool)
+	var queue []*ssa.Function
+	for _, fn := range roots {
+		if _, ok := synced[fn]; fn != nil && !ok {
+			synced[fn] = hasSyncOp(fn)
+			queue = append(queue, fn)
+		}
+	}
+	for len(queue) > 0 {
+		fn := queue[0]
+		queue = queue[1:]
+		node := cg.Nodes[fn]
+		if node == nil {
+			continue
+		}
+		for _, e := range node.Out {
+			if _, isGo := e.Site.(*ssa.Go); isGo || e.Site == nil || !e.Site.Common().IsInvoke() && e.Site.Common().StaticCallee() == nil {
+				continue
+			}
+			s := synced[fn] || hasSyncOp(e.Callee.Func)
+			if old, ok := synced[e.Callee.Func]; !ok || s && !old {
+				synced[e.Callee.Func] = s
+				queue = append(queue, e.Callee.Func)
+			}
+		}
+	}
+	return synced
+}
+
+// hasSyncOp は fn がチャネルの操作か sync、sync/atomic の関数やメソッドの呼び出しを含むかどうかを返す
+func hasSyncOp(fn *ssa.Function) bool {
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			switch instr := instr.(type) {
+			case *ssa.Send, *ssa.Select:
+				return true
+			case *ssa.UnOp:
+				if instr.Op == token.ARROW {
+					return true
+				}
+			case ssa.CallInstruction:
+				common := instr.Common()
+				var obj types.Object
+				if common.IsInvoke() {
+					obj = common.Method
+				} else if callee := common.StaticCallee(); callee != nil {
+					obj = callee.Object()
+				} else if b, ok := common.Value.(*ssa.Builtin); ok && b.Name() == "close" {
+					return true
+				}
+				if obj != nil && obj.Pkg() != nil && (obj.Pkg().Path() == "sync" || obj.Pkg().Path() == "sync/atomic") {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// sharedVarAccess は関数の中の、共有されうる変数 v (*ssa.Global か、無名関数が捕捉する *ssa.Alloc) の読み書き
+type sharedVarAccess struct {
+	v     ssa.Value
+	instr ssa.Instruction
+	write bool
+}
+
+// sharedVarAccesses は fn の中でのパッケージ変数と捕捉される変数 (そのフィールドや配列の要素を含む) の読み書きを返す
+func sharedVarAccesses(fn *ssa.Function) []sharedVarAccess {
+	var result []sharedVarAccess
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			var addr ssa.Value
+			write := false
+			switch instr := instr.(type) {
+			case *ssa.Store:
+				addr, write = instr.Addr, true
+			case *ssa.UnOp:
+				if instr.Op == token.MUL {
+					addr = instr.X
+				}
+			}
+			if addr == nil || !instr.Pos().IsValid() {
+				continue
+			}
+			for _, v := range sharedVars(addr) {
+				result = append(result, sharedVarAccess{v: v, instr: instr, write: write})
+			}
+		}
+	}
+	return result
+}
+
+// sharedVars はアドレス addr の元になる共有されうる変数を返す
+func sharedVars(addr ssa.Value) []ssa.Value {
+	for {
+		switch a := addr.(type) {
+		case *ssa.FieldAddr:
+			addr = a.X
+			continue
+		case *ssa.IndexAddr:
+			if _, ok := a.X.Type().Underlying().(*types.Pointer); !ok {
+				return nil
+			}
+			addr = a.X
+			continue
+		case *ssa.Global:
+			return []ssa.Value{a}
+		case *ssa.Alloc:
+			if a.Heap && capturedByClosure(a) {
+				return []ssa.Value{a}
+			}
+		case *ssa.FreeVar:
+			var vars []ssa.Value
+			for _, b := range closureBindings(a) {
+				vars = append(vars, sharedVars(b)...)
+			}
+			return vars
+		}
+		return nil
+	}
+}
+
+func capturedByClosure(a *ssa.Alloc) bool {
+	for _, ref := range *a.Referrers() {
+		if _, ok := ref.(*ssa.MakeClosure); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func sharedVarName(v ssa.Value) string {
+	if a, ok := v.(*ssa.Alloc); ok {
+		return "captured variable " + a.Comment
+	}
+	return "package variable " + v.Name()
+}
@@ -0,0 +1,70 @@
+package main
+
+import "testing"
+
+func TestCheckRaces(t *testing.T) {
+	src := `package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+var (
+	hits    int
+	total   int
+	mu      sync.Mutex
+	started bool
+)
+
+func record() {
+	hits++
+}
+
+func locked() {
+	mu.Lock()
+	total++
+	mu.Unlock()
+}
+
+func main() {
+	started = true
+	count := 0
+	go func() {
+		count++
+	}()
+	fmt.Println(count)
+
+	go record()
+	fmt.Println(hits)
+
+	go locked()
+	mu.Lock()
+	fmt.Println(total)
+	mu.Unlock()
+
+	done := make(chan bool)
+	n := 0
+	go func() {
+		n = 1
+		done <- true
+	}()
+	<-done
+	fmt.Println(n)
+
+	sum := 0
+	for i := 0; i < 3; i++ {
+		go func() {
+			fmt.Println(started)
+			sum += i
+		}()
+	}
+}
+`
+	prog := loadTestProgram(t, map[string]string{"main.go": src})
+	assertLines(t, diagnosticMessages(checkRaces(prog)), []string{
+		"main.go:16: package variable hits is written here by the goroutine started at line 33 and read at line 34 by the main goroutine without synchronization",
+		"main.go:29: captured variable count is written here by the goroutine started at line 28 and read at line 31 by the main goroutine without synchronization",
+		"main.go:54: captured variable sum is written here by the goroutines started in a loop at line 52 and read at line 54 by another of the goroutines started in a loop at line 52 without synchronization",
+	})
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// ReflectUse は reflect パッケージまたは unsafe な変換の使用箇所
+type ReflectUse struct {
+	Func   string         `json:"func"`
+	Kind   string         `json:"kind"` // typeof, valueof, method-by-name, field-by-name, method, call, unsafe, other
+	Callee string         `json:"callee,omitempty"`
+	Name   string         `json:"name,omitempty"` // MethodByName などに渡された名前 (定数のとき)
+	Pos    token.Position `json:"pos"`
+}
+
+// reflectKinds は reflect の関数、メソッドの名前と分類
+var reflectKinds = map[string]string{
+	"TypeOf":          "typeof",
+	"ValueOf":         "valueof",
+	"MethodByName":    "method-by-name",
+	"FieldByName":     "field-by-name",
+	"FieldByNameFunc": "field-by-name",
+	"Method":          "method",
+	"Call":            "call",
+	"CallSlice":       "call",
+	"UnsafeAddr":      "unsafe",
+	"UnsafePointer":   "unsafe",
+	"Pointer":         "unsafe",
+	"NewAt":           "unsafe",
+}
+
+// isDynamicReflect は静的なコールグラフに現れない呼び出しにつながる分類かどうかを返す
+func isDynamicReflect(kind string) bool {
+	return kind == "method-by-name" || kind == "method" || kind == "call"
+}
+
+func runReflection(args []string) error {
+	fs := flag.NewFlagSet("reflection", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output as JSON")
+	fs.Parse(args)
+	prog, err := loadProgram(".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	uses := reflectUses(prog)
+	relativizePositions(uses)
+	if *asJSON {
+		return writeJSON(os.Stdout, uses)
+	}
+	return writeReflectUses(os.Stdout, uses, reflectionTainted(uses))
+}
+
+// reflectUses は解析対象の関数から reflect の呼び出しと unsafe.Pointer への変換を探して分類する
+func reflectUses(prog *Program) []ReflectUse {
+	var uses []ReflectUse
+	for _, fn := range prog.targetFunctions() {
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				use := ReflectUse{Func: fn.RelString(nil), Pos: prog.Fset.Position(instr.Pos())}
+				switch instr := instr.(type) {
+				case ssa.CallInstruction:
+					callee := reflectCallee(instr.Common())
+					if callee == nil {
+						continue
+					}
+					use.Callee = callee.FullName()
+					use.Kind = reflectKinds[callee.Name()]
+					if use.Kind == "" {
+						use.Kind = "other"
+					}
+					if strings.HasSuffix(callee.Name(), "ByName") {
+						use.Name = constString(callArgs(instr.Common())[0])
+					}
+				case *ssa.Convert:
+					if !isUnsafePointer(instr.Type()) && !isUnsafePointer(instr.X.Type()) {
+						continue
+					}
+					use.Kind = "unsafe"
+					use.Callee = types.TypeString(instr.X.Type(), nil) + " -> " + types.TypeString(instr.Type(), nil)
+				default:
+					continue
+				}
+				uses = append(uses, use)
+			}
+		}
+	}
+	return uses
+}
+
+// reflectCallee は reflect パッケージの関数、または reflect.Value, reflect.Type のメソッドの呼び出しであればその関数を返す
+func reflectCallee(call *ssa.CallCommon) *types.Func {
+	var fn *types.Func
+	if call.IsInvoke() {
+		fn = call.Method
+	} else if callee := call.StaticCallee(); callee != nil {
+		fn, _ = callee.Object().(*types.Func)
+	}
+	if fn == nil || fn.Pkg() == nil || fn.Pkg().Path() != "reflect" {
+		return nil
+	}
+	return fn
+}
+
+func isUnsafePointer(t types.Type) bool {
+	basic, ok := t.Underlying().(*types.Basic)
+	return ok && basic.Kind() == types.UnsafePointer
+}
+
+// reflectionTainted は reflect による動的な呼び出し (MethodByName, Method, Call) を含み、
+// コールグラフの出力辺が不完全になっている関数を返す
+func reflectionTainted(uses []ReflectUse) map[string]bool {
+	tainted := make(map[string]bool)
+	for _, u := range uses {
+		if isDynamicReflect(u.Kind) {
+			tainted[u.Func] = true
+		}
+	}
+	return tainted
+}
+
+func writeReflectUses(w io.Writer, uses []ReflectUse, tainted map[string]bool) error {
+	for _, u := range uses {
+		detail := u.Callee
+		if u.Name != "" {
+			detail += fmt.Sprintf(" %q", u.Name)
+		}
+		if isDynamicReflect(u.Kind) {
+			detail += " (defeats static call graph)"
+		}
+		fmt.Fprintf(w, "%s: %s: %s %s\n", u.Pos, u.Func, u.Kind, detail)
+	}
+	for _, fn := range sortedKeys(tainted) {
+		fmt.Fprintf(w, "reflection-tainted: %s\n", fn)
+	}
+	return nil
+}
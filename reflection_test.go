@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var testdata_reflection = `package main
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+type T struct{ Name string }
+
+func (T) Hello() {}
+
+func invoke(v any, name string) {
+	m := reflect.ValueOf(v).MethodByName(name)
+	m.Call(nil)
+}
+
+func describe(v any) string {
+	t := reflect.TypeOf(v)
+	f, _ := t.FieldByName("Name")
+	return t.String() + f.Name
+}
+
+func bytesOf(s string) []byte {
+	return unsafe.Slice((*byte)(unsafe.Pointer(unsafe.StringData(s))), len(s))
+}
+
+func main() {
+	invoke(T{}, "Hello")
+	describe(T{})
+	bytesOf("x")
+}
+`
+
+func TestReflectUses(t *testing.T) {
+	prog := loadTestProgram(t, map[string]string{"main.go": testdata_reflection})
+	uses := reflectUses(prog)
+	var buf bytes.Buffer
+	if err := writeReflectUses(&buf, uses, reflectionTainted(uses)); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if i := strings.Index(line, "main.go"); i >= 0 {
+			line = line[i:]
+		}
+		got = append(got, line)
+	}
+	assertLines(t, got, []string{
+		"main.go:13:22: example.com/m.invoke: valueof reflect.ValueOf",
+		"main.go:13:38: example.com/m.invoke: method-by-name (reflect.Value).MethodByName (defeats static call graph)",
+		"main.go:14:8: example.com/m.invoke: call (reflect.Value).Call (defeats static call graph)",
+		"main.go:18:21: example.com/m.describe: typeof reflect.TypeOf",
+		`main.go:19:23: example.com/m.describe: field-by-name (reflect.Type).FieldByName "Name"`,
+		"main.go:20:17: example.com/m.describe: other (reflect.Type).String",
+		"main.go:24:44: example.com/m.bytesOf: unsafe *byte -> unsafe.Pointer",
+		"main.go:24:29: example.com/m.bytesOf: unsafe unsafe.Pointer -> *byte",
+		"reflection-tainted: example.com/m.invoke",
+	})
+}
+
+func TestCallGraphReflectionTainted(t *testing.T) {
+	prog := loadTestProgram(t, map[string]string{"main.go": testdata_reflection})
+	var got []string
+	for _, e := range callEdges(prog, callGraphOptions{Std: stdExclude, Annotate: true}) {
+		if e.Caller == "example.com/m.main" {
+			got = append(got, fmt.Sprintf("%s reflection=%v", e.Callee, e.CalleeReflection))
+		}
+	}
+	assertLines(t, got, []string{
+		"example.com/m.bytesOf reflection=false",
+		"example.com/m.describe reflection=false",
+		"example.com/m.invoke reflection=true",
+	})
+}
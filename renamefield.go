@@ -0,0 +1,247 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+func runRenameField(args []string) error {
+	fs := flag.NewFlagSet("renamefield", flag.ExitOnError)
+	field := fs.String("field", "", "field to rename as `T.f` (path.T.f to choose the package)")
+	to := fs.String("to", "", "new `name` of the field")
+	keyed := fs.Bool("keyed", false, "convert every positional literal of the struct to keyed form")
+	write := fs.Bool("w", false, "write the changed files instead of printing them")
+	fs.Parse(args)
+	if *field == "" || *to == "" {
+		return errors.New("-field and -to are required")
+	}
+	// テストのファイルも書き換えるために読み込む
+	prog, err := loadProgramWith(loadOptions{Tests: true}, ".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	files, err := renameField(prog, *field, *to, *keyed)
+	if err != nil {
+		return err
+	}
+	return writeGenerated(files, *write)
+}
+
+// renameField は spec (T.f か path.T.f) の構造体のフィールドの名前を to に変え、変更したファイルの内容を返す。
+// フィールドの宣言、セレクター、キー付きの複合リテラルのキーを書き換える。位置で並べた複合リテラルは
+// 名前を書かないのでそのままでよいが、リネームするフィールドと同じ型のフィールドが他にあって値がどちらの
+// ものか読み取れないもの (keyed ならすべて) はキー付きの形にする
+func renameField(prog *Program, spec, to string, keyed bool) (map[string][]byte, error) {
+	if !token.IsIdentifier(to) {
+		return nil, fmt.Errorf("%q is not a valid identifier", to)
+	}
+	named, field, err := findField(prog, spec)
+	if err != nil {
+		return nil, err
+	}
+	if field.Embedded() {
+		return nil, fmt.Errorf("%s is an embedded field; rename its type instead", spec)
+	}
+	if obj, _, _ := types.LookupFieldOrMethod(named, true, field.Pkg(), to); obj != nil {
+		return nil, fmt.Errorf("%s already has a field or method %s", named.Obj().Name(), to)
+	}
+	target := prog.Fset.Position(field.Pos())
+	isTarget := func(obj types.Object) bool {
+		v, ok := obj.(*types.Var)
+		return ok && v.IsField() && prog.Fset.Position(v.Pos()) == target
+	}
+	// 埋め込んだ構造体に to という名前のフィールドかメソッドがあると、outer.f を outer.to に書き換えたときに
+	// そちらを指すようになる (同じ深さなら曖昧になる) ので、書き換えるセレクターごとに確かめる
+	for _, pkg := range prog.Packages {
+		for se, sel := range pkg.TypesInfo.Selections {
+			if !isTarget(sel.Obj()) {
+				continue
+			}
+			obj, index, _ := types.LookupFieldOrMethod(sel.Recv(), true, field.Pkg(), to)
+			if index != nil && len(index) <= len(sel.Index()) {
+				pos := prog.Fset.Position(se.Sel.Pos())
+				pos.Filename = relPath(pos.Filename, outputRoot)
+				what := "an ambiguous selector"
+				if obj != nil {
+					what = types.ObjectString(obj, types.RelativeTo(pkg.Types))
+				}
+				return nil, fmt.Errorf("%s: %s.%s would refer to %s", pos, types.ExprString(se.X), to, what)
+			}
+		}
+	}
+	// 生成されたファイルを書き換えずに残すとビルドできなくなる
+	for _, pkg := range prog.Packages {
+		for _, file := range pkg.Syntax {
+			name := prog.Fset.Position(file.Pos()).Filename
+			if !prog.skipGenerated(name) {
+				continue
+			}
+			for id, obj := range pkg.TypesInfo.Uses {
+				if isTarget(obj) && prog.Fset.Position(id.Pos()).Filename == name {
+					return nil, fmt.Errorf("%s is used in the generated file %s; change the generator input or pass -generated", spec, relPath(name, outputRoot))
+				}
+			}
+		}
+	}
+	return rewriteFiles(prog, func(pkg *packages.Package, file *ast.File) bool {
+		info := pkg.TypesInfo
+		changed := false
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.Ident:
+				if isTarget(info.Defs[n]) || isTarget(info.Uses[n]) {
+					n.Name = to
+					changed = true
+				}
+			case *ast.CompositeLit:
+				st := literalStruct(info, n)
+				if st == nil || len(n.Elts) == 0 || isKeyedLiteral(n) {
+					return true
+				}
+				for i := 0; i < st.NumFields(); i++ {
+					if isTarget(st.Field(i)) && (keyed || sameTypeField(st, i)) {
+						names := structFieldNames(st)
+						names[i] = to
+						// _ のフィールドがあれば位置で並べたままにする。位置で並べたリテラルは名前を変えても壊れない
+						changed = keyLiteral(n, names) || changed
+					}
+				}
+			}
+			return true
+		})
+		return changed
+	})
+}
+
+// rewriteFiles は解析対象のパッケージのファイルごとに edit を呼び、edit が true を返した (構文木を書き換えた)
+// ファイルを整形した内容を返す。テストを読み込むと同じファイルが複数のパッケージに現れるので、最初のものだけを渡す。
+// 生成されたファイルは -generated を指定しなければ渡さない
+func rewriteFiles(prog *Program, edit func(pkg *packages.Package, file *ast.File) bool) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	seen := make(map[string]bool)
+	for _, pkg := range prog.Packages {
+		for _, file := range pkg.Syntax {
+			name := prog.Fset.Position(file.Pos()).Filename
+			if seen[name] || prog.skipGenerated(name) {
+				continue
+			}
+			seen[name] = true
+			if !edit(pkg, file) {
+				continue
+			}
+			name, src, err := formatFile(prog.Fset, file)
+			if err != nil {
+				return nil, err
+			}
+			files[name] = src
+		}
+	}
+	return files, nil
+}
+
+// findField は spec (T.f か path.T.f) の名前付きの型と、そのフィールドを解析対象のパッケージから探す
+func findField(prog *Program, spec string) (*types.Named, *types.Var, error) {
+	dot := strings.LastIndex(spec, ".")
+	if dot < 0 {
+		return nil, nil, fmt.Errorf("%q is not of the form T.f", spec)
+	}
+	typeName, fieldName := spec[:dot], spec[dot+1:]
+	pkgPath := ""
+	if dot := strings.LastIndex(typeName, "."); dot >= 0 {
+		pkgPath, typeName = typeName[:dot], typeName[dot+1:]
+	}
+	var named *types.Named
+	var field *types.Var
+	for _, pkg := range prog.Packages {
+		if pkgPath != "" && pkg.PkgPath != pkgPath {
+			continue
+		}
+		obj, ok := pkg.Types.Scope().Lookup(typeName).(*types.TypeName)
+		if !ok {
+			continue
+		}
+		n, ok := obj.Type().(*types.Named)
+		if !ok {
+			continue
+		}
+		st, ok := n.Underlying().(*types.Struct)
+		if !ok {
+			continue
+		}
+		for i := 0; i < st.NumFields(); i++ {
+			f := st.Field(i)
+			if f.Name() != fieldName {
+				continue
+			}
+			if field != nil && prog.Fset.Position(field.Pos()) != prog.Fset.Position(f.Pos()) {
+				return nil, nil, fmt.Errorf("%s is ambiguous; qualify it with the package path", spec)
+			}
+			named, field = n, f
+		}
+	}
+	if field == nil {
+		return nil, nil, notFound("struct field %s not found", spec)
+	}
+	return named, field, nil
+}
+
+// literalStruct は複合リテラル lit の型 (型を省略したものも含む) が構造体ならその構造体を返す
+func literalStruct(info *types.Info, lit *ast.CompositeLit) *types.Struct {
+	t := info.TypeOf(lit)
+	if t == nil {
+		return nil
+	}
+	if p, ok := t.Underlying().(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	st, _ := t.Underlying().(*types.Struct)
+	return st
+}
+
+func isKeyedLiteral(lit *ast.CompositeLit) bool {
+	for _, elt := range lit.Elts {
+		if _, ok := elt.(*ast.KeyValueExpr); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// sameTypeField は st の i 番目のフィールドと同じ型のフィールドが他にあるかどうかを返す
+func sameTypeField(st *types.Struct, i int) bool {
+	for j := 0; j < st.NumFields(); j++ {
+		if j != i && types.Identical(st.Field(j).Type(), st.Field(i).Type()) {
+			return true
+		}
+	}
+	return false
+}
+
+func structFieldNames(st *types.Struct) []string {
+	names := make([]string, st.NumFields())
+	for i := range names {
+		names[i] = st.Field(i).Name()
+	}
+	return names
+}
+
+// keyLiteral は位置で並べた構造体の複合リテラル lit の要素を、names の名前をキーにした形にする。
+// _ のフィールドはキーにできないので、names に _ があれば書き換えずに false を返す
+func keyLiteral(lit *ast.CompositeLit, names []string) bool {
+	for _, name := range names {
+		if name == "_" {
+			return false
+		}
+	}
+	for i, elt := range lit.Elts {
+		lit.Elts[i] = &ast.KeyValueExpr{Key: &ast.Ident{Name: names[i], NamePos: elt.Pos()}, Colon: elt.Pos(), Value: elt}
+	}
+	return true
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const renameFieldSrc = `package main
+
+import "fmt"
+
+type MyStruct struct {
+	field1 int
+	field2 string
+	field3 int
+}
+
+type Pair struct {
+	field1 int
+	name   string
+}
+
+func (m MyStruct) String() string {
+	return fmt.Sprint(m.field1, m.field2)
+}
+
+func main() {
+	a := MyStruct{field1: 1, field2: "a"}
+	b := MyStruct{2, "b", 3}
+	p := Pair{field1: 3, name: "p"}
+	ps := []*Pair{{4, "q"}}
+	fmt.Println(a, b, p, ps, a.field1)
+}
+`
+
+func TestRenameField(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"main.go": renameFieldSrc,
+		"main_test.go": `package main
+
+import "testing"
+
+func TestString(t *testing.T) {
+	if got := (MyStruct{field1: 1}).String(); got != "1 " {
+		t.Error(got)
+	}
+}
+`,
+	})
+	prog, err := loadProgramWith(loadOptions{Tests: true}, dir, "./...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := renameField(prog, "MyStruct.field1", "count", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("changed %d files, want 2", len(files))
+	}
+	got := string(files[filepath.Join(dir, "main.go")])
+	want := `package main
+
+import "fmt"
+
+type MyStruct struct {
+	count  int
+	field2 string
+	field3 int
+}
+
+type Pair struct {
+	field1 int
+	name   string
+}
+
+func (m MyStruct) String() string {
+	return fmt.Sprint(m.count, m.field2)
+}
+
+func main() {
+	a := MyStruct{count: 1, field2: "a"}
+	b := MyStruct{count: 2, field2: "b", field3: 3}
+	p := Pair{field1: 3, name: "p"}
+	ps := []*Pair{{4, "q"}}
+	fmt.Println(a, b, p, ps, a.count)
+}
+`
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	if got := string(files[filepath.Join(dir, "main_test.go")]); !strings.Contains(got, "(MyStruct{count: 1}).String()") {
+		t.Errorf("main_test.go not renamed:\n%s", got)
+	}
+
+	// 構文木は書き換えられているので読み込み直す
+	if prog, err = loadProgramWith(loadOptions{Tests: true}, dir, "./..."); err != nil {
+		t.Fatal(err)
+	}
+	files, err = renameField(prog, "example.com/m.Pair.field1", "id", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = string(files[filepath.Join(dir, "main.go")])
+	for _, want := range []string{"\tid   int\n", "Pair{id: 3, name: \"p\"}", "[]*Pair{{id: 4, name: \"q\"}}", "MyStruct{2, \"b\", 3}"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+
+	for _, tt := range []struct{ spec, to, want string }{
+		{"MyStruct.field1", "field2", "MyStruct already has a field or method field2"},
+		{"MyStruct.field1", "String", "MyStruct already has a field or method String"},
+		{"MyStruct.missing", "x", "struct field MyStruct.missing not found"},
+		{"field1", "x", `"field1" is not of the form T.f`},
+	} {
+		if _, err := renameField(prog, tt.spec, tt.to, false); err == nil || err.Error() != tt.want {
+			t.Errorf("renameField(%s, %s) error = %v, want %s", tt.spec, tt.to, err, tt.want)
+		}
+	}
+}
+
+func TestRenameFieldEmbedded(t *testing.T) {
+	prog := loadTestProgram(t, map[string]string{"main.go": `package main
+
+import "fmt"
+
+type Inner struct {
+	count int
+}
+
+type Outer struct {
+	Inner
+	total int
+}
+
+func (Outer) Size() int { return 0 }
+
+type Deep struct {
+	Outer
+}
+
+func main() {
+	o := Outer{}
+	d := Deep{}
+	fmt.Println(o.count, d.Inner.count)
+}
+`})
+	for _, tt := range []struct{ to, want string }{
+		{"total", "main.go:23:16: o.total would refer to field total int"},
+		{"Size", "main.go:23:16: o.Size would refer to func (Outer).Size() int"},
+	} {
+		_, err := renameField(prog, "Inner.count", tt.to, false)
+		if err == nil || !strings.HasSuffix(err.Error(), tt.want) {
+			t.Errorf("renameField(Inner.count, %s) error = %v, want %s", tt.to, err, tt.want)
+		}
+	}
+	// d.Inner.count の受け手は Inner なので、Outer の total には関係しない
+	files, err := renameField(prog, "Inner.count", "n", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, src := range files {
+		if !strings.Contains(string(src), "fmt.Println(o.n, d.Inner.n)") {
+			t.Errorf("not renamed:\n%s", src)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Report は report コマンドでまとめる解析結果
+type Report struct {
+	Module     string           `json:"module"`
+	Root       string           `json:"-"` // 位置を相対パスで表すときの基準のディレクトリ
+	Metrics    []PackageMetrics `json:"metrics"`
+	DeadCode   []Diagnostic     `json:"deadCode"`
+	Interfaces InterfaceMatrix  `json:"interfaces"`
+	CallGraph  CallGraphSummary `json:"callGraph"`
+	Packages   *PkgGraph        `json:"packages"`
+	Classes    *ClassDiagram    `json:"classes"`
+
+	readFile func(string) ([]byte, error) // 指摘の位置のソースコードを読む
+}
+
+// InterfaceMatrix はインターフェースと、それを実装する型の対応表
+type InterfaceMatrix struct {
+	Interfaces []string       `json:"interfaces"`
+	Rows       []InterfaceRow `json:"rows"`
+}
+
+// InterfaceRow は型 1 つが Interfaces のそれぞれを実装しているかどうか
+type InterfaceRow struct {
+	Type       string `json:"type"`
+	Implements []bool `json:"implements"`
+}
+
+// CallGraphSummary は解析対象の関数の間のコールグラフの規模と、呼び出しの多い関数
+type CallGraphSummary struct {
+	Functions int         `json:"functions"`
+	Edges     int         `json:"edges"`
+	FanIn     []FuncCount `json:"fanIn"`  // 呼び出し元の多い関数
+	FanOut    []FuncCount `json:"fanOut"` // 呼び出し先の多い関数
+}
+
+// FuncCount は関数と、その呼び出し元または呼び出し先の数
+type FuncCount struct {
+	Func  string `json:"func"`
+	Count int    `json:"count"`
+}
+
+// reportTopN はコールグラフの要約に載せる関数の数
+const reportTopN = 10
+
+func runReport(args []string) error {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output as JSON")
+	out := fs.String("o", "", "write the report to `file` instead of standard output")
+	htmlDir := fs.String("html", "", "write the report as a static HTML site into `dir`")
+	fs.Parse(args)
+	prog, err := loadProgram(".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	r := buildReport(prog)
+	if *htmlDir != "" {
+		return writeReportHTML(*htmlDir, r)
+	}
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	if *asJSON {
+		return writeJSON(w, r)
+	}
+	return writeReportMarkdown(w, r)
+}
+
+// buildReport はメトリクス、デッドコード、インターフェースの実装表、コールグラフの要約、
+// パッケージの依存グラフと型の関係をまとめる
+func buildReport(prog *Program) *Report {
+	r := &Report{
+		Metrics:   packageMetrics(prog),
+		DeadCode:  deadCode(prog, allEntryKinds),
+		CallGraph: callGraphSummary(prog),
+		Packages:  packageGraph(prog.Packages, 0),
+		Classes:   classDiagram(prog.Packages),
+		readFile:  prog.ReadFile,
+	}
+	r.Interfaces = interfaceMatrix(r.Classes)
+	for _, pkg := range prog.Packages {
+		if pkg.Module != nil {
+			r.Module, r.Root = pkg.Module.Path, pkg.Module.Dir
+			break
+		}
+	}
+	return r
+}
+
+// interfaceMatrix はクラス図の実装関係から、インターフェースを列、実装する型を行にした表を作る
+func interfaceMatrix(d *ClassDiagram) InterfaceMatrix {
+	var m InterfaceMatrix
+	impls := make(map[string]map[string]bool)
+	var types []string
+	for _, r := range d.Relations {
+		if r.Kind != relImplements {
+			continue
+		}
+		if impls[r.From] == nil {
+			impls[r.From] = make(map[string]bool)
+			types = append(types, r.From)
+		}
+		impls[r.From][r.To] = true
+	}
+	for _, c := range d.Classes {
+		if c.Kind == "interface" {
+			m.Interfaces = append(m.Interfaces, c.Name)
+		}
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		row := InterfaceRow{Type: t}
+		for _, iface := range m.Interfaces {
+			row.Implements = append(row.Implements, impls[t][iface])
+		}
+		m.Rows = append(m.Rows, row)
+	}
+	return m
+}
+
+// callGraphSummary は標準ライブラリを除き、protoc で生成された関数をメッセージごとにまとめたコールグラフの規模と、呼び出し元・呼び出し先の多い関数を返す
+func callGraphSummary(prog *Program) CallGraphSummary {
+	edges := callEdges(prog, callGraphOptions{Std: stdExclude, Proto: stdCollapse})
+	fanIn := make(map[string]int)
+	fanOut := make(map[string]int)
+	funcs := make(map[string]bool)
+	for _, e := range edges {
+		fanOut[e.Caller]++
+		fanIn[e.Callee]++
+		funcs[e.Caller] = true
+		funcs[e.Callee] = true
+	}
+	return CallGraphSummary{
+		Functions: len(funcs),
+		Edges:     len(edges),
+		FanIn:     topFuncCounts(fanIn, reportTopN),
+		FanOut:    topFuncCounts(fanOut, reportTopN),
+	}
+}
+
+// topFuncCounts は数の多い順 (同じなら名前の順) に n 個までの関数を返す
+func topFuncCounts(counts map[string]int, n int) []FuncCount {
+	var result []FuncCount
+	for fn, c := range counts {
+		result = append(result, FuncCount{Func: fn, Count: c})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Func < result[j].Func
+	})
+	if len(result) > n {
+		result = result[:n]
+	}
+	return result
+}
+
+// relPath は root の下のファイルであれば root からの相対パスを返す
+func relPath(filename, root string) string {
+	if root != "" {
+		if rel, err := filepath.Rel(root, filename); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filename
+}
+
+// relPosition は pos を root からの相対パスと行番号で表す
+func relPosition(pos token.Position, root string) string {
+	return fmt.Sprintf("%s:%d", relPath(pos.Filename, root), pos.Line)
+}
+
+// writeReportMarkdown はレポートを PR にそのまま貼れる Markdown で出力する
+func writeReportMarkdown(w io.Writer, r *Report) error {
+	fmt.Fprintf(w, "# Analysis report: %s\n", r.Module)
+
+	fmt.Fprint(w, "\n## Metrics\n\n")
+	fmt.Fprintln(w, "| Package | Files | Lines | Funcs | Types | Exported | Max complexity | Avg complexity |")
+	fmt.Fprintln(w, "| --- | ---: | ---: | ---: | ---: | ---: | ---: | ---: |")
+	for _, m := range r.Metrics {
+		fmt.Fprintf(w, "| `%s` | %d | %d | %d | %d | %d | %d | %.2f |\n",
+			m.Package, m.Files, m.Lines, m.Funcs, m.Types, m.Exported, m.MaxComplexity, m.AvgComplexity)
+	}
+
+	fmt.Fprint(w, "\n## Dead code\n\n")
+	if len(r.DeadCode) == 0 {
+		fmt.Fprintln(w, "No functions are unreachable from the entry points.")
+	} else {
+		fmt.Fprintln(w, "| Position | Finding |")
+		fmt.Fprintln(w, "| --- | --- |")
+		for _, d := range r.DeadCode {
+			fmt.Fprintf(w, "| %s | %s |\n", relPosition(d.Pos, r.Root), d.Message)
+		}
+	}
+
+	fmt.Fprint(w, "\n## Interfaces\n\n")
+	if len(r.Interfaces.Rows) == 0 {
+		fmt.Fprintln(w, "No type implements an interface declared in the module.")
+	} else {
+		fmt.Fprintf(w, "| Type | %s |\n", strings.Join(quoteCode(r.Interfaces.Interfaces), " | "))
+		fmt.Fprintf(w, "| --- |%s\n", strings.Repeat(" :---: |", len(r.Interfaces.Interfaces)))
+		for _, row := range r.Interfaces.Rows {
+			cells := make([]string, len(row.Implements))
+			for i, ok := range row.Implements {
+				if ok {
+					cells[i] = "✓"
+				}
+			}
+			fmt.Fprintf(w, "| `%s` | %s |\n", row.Type, strings.Join(cells, " | "))
+		}
+	}
+
+	fmt.Fprint(w, "\n## Call graph\n\n")
+	fmt.Fprintf(w, "%d functions, %d call edges (standard library excluded).\n\n", r.CallGraph.Functions, r.CallGraph.Edges)
+	fmt.Fprintln(w, "| Most called | Callers | Most calling | Callees |")
+	fmt.Fprintln(w, "| --- | ---: | --- | ---: |")
+	for i := 0; i < max(len(r.CallGraph.FanIn), len(r.CallGraph.FanOut)); i++ {
+		var cells [4]string
+		if i < len(r.CallGraph.FanIn) {
+			cells[0], cells[1] = "`"+r.CallGraph.FanIn[i].Func+"`", fmt.Sprint(r.CallGraph.FanIn[i].Count)
+		}
+		if i < len(r.CallGraph.FanOut) {
+			cells[2], cells[3] = "`"+r.CallGraph.FanOut[i].Func+"`", fmt.Sprint(r.CallGraph.FanOut[i].Count)
+		}
+		fmt.Fprintf(w, "| %s |\n", strings.Join(cells[:], " | "))
+	}
+
+	fmt.Fprint(w, "\n## Package dependencies\n\n")
+	fmt.Fprintln(w, "```mermaid")
+	writePkgGraphMermaid(w, r.Packages)
+	fmt.Fprintln(w, "```")
+
+	fmt.Fprint(w, "\n## Types\n\n")
+	fmt.Fprintln(w, "```mermaid")
+	writeMermaidClasses(w, r.Classes)
+	fmt.Fprintln(w, "```")
+	return nil
+}
+
+// quoteCode は各要素を Markdown のコードにする
+func quoteCode(elems []string) []string {
+	quoted := make([]string, len(elems))
+	for i, e := range elems {
+		quoted[i] = "`" + e + "`"
+	}
+	return quoted
+}
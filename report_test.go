@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReportMarkdown(t *testing.T) {
+	prog := loadTestProgram(t, map[string]string{
+		"main.go": `package main
+
+type Shape interface{ Area() float64 }
+
+type Square struct{ s float64 }
+
+func (q Square) Area() float64 { return q.s * q.s }
+
+func unused() {}
+
+func total(shapes []Shape) float64 {
+	sum := 0.0
+	for _, s := range shapes {
+		sum += s.Area()
+	}
+	return sum
+}
+
+func main() { println(total([]Shape{Square{2}})) }
+`,
+	})
+	var buf bytes.Buffer
+	if err := writeReportMarkdown(&buf, buildReport(prog)); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"# Analysis report: example.com/m\n",
+		"| `example.com/m` | 1 | 19 | 4 | 2 | 3 | 2 | 1.25 |\n",
+		"| main.go:9 | unused is unreachable from the entry points |\n",
+		"| Type | `main.Shape` |\n| --- | :---: |\n| `main.Square` | ✓ |\n",
+		"| `(example.com/m.Square).Area` | 1 | `example.com/m.main` | 1 |\n",
+		"```mermaid\nflowchart TD\n    P0[\".\"]\n```\n",
+		"```mermaid\nclassDiagram\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report does not contain %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestHighlightGo(t *testing.T) {
+	src := "package p\n\n/* a\n   b */\nfunc f() string { return \"<x>\" + 1 }\n"
+	var got []string
+	for _, line := range highlightGo([]byte(src)) {
+		got = append(got, string(line))
+	}
+	assertLines(t, got, []string{
+		`<span class="kw">package</span> p`,
+		``,
+		`<span class="com">/* a</span>`,
+		`<span class="com">   b */</span>`,
+		`<span class="kw">func</span> f() string { <span class="kw">return</span> <span class="str">&#34;&lt;x&gt;&#34;</span> + <span class="num">1</span> }`,
+	})
+}
+
+func TestReportHTML(t *testing.T) {
+	prog := loadTestProgram(t, map[string]string{
+		"main.go": "package main\n\nfunc unused() {}\n\nfunc main() {}\n",
+	})
+	dir := t.TempDir()
+	if err := writeReportHTML(dir, buildReport(prog)); err != nil {
+		t.Fatal(err)
+	}
+	index, err := os.ReadFile(filepath.Join(dir, "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `<a href="snippets/1.html">main.go:3</a>`; !strings.Contains(string(index), want) {
+		t.Errorf("index.html does not contain %q:\n%s", want, index)
+	}
+	snippet, err := os.ReadFile(filepath.Join(dir, "snippets", "1.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"<h1>main.go:3</h1>",
+		`<span id="L1" class="line"><span class="lineno">1</span><span class="kw">package</span> main</span>`,
+		`<span id="L3" class="line mark"><span class="lineno">3</span><span class="kw">func</span> unused() {}</span>`,
+	} {
+		if !strings.Contains(string(snippet), want) {
+			t.Errorf("snippet does not contain %q:\n%s", want, snippet)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "style.css")); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// snippetContext はスニペットに含める、指摘の行の前後の行数
+const snippetContext = 5
+
+// reportSnippet は指摘の位置のソースコードの抜粋
+type reportSnippet struct {
+	File      string // ルートからの相対パス
+	Start     int    // 最初の行番号
+	Mark      int    // 指摘の行番号
+	Lines     []template.HTML
+	Message   string
+	IndexPath string
+}
+
+// writeReportHTML はレポートを dir に静的サイトとして書き出す。index.html から各指摘を、
+// ソースコードを色付けした snippets/N.html (指摘の行の前後 snippetContext 行) にリンクする
+func writeReportHTML(dir string, r *Report) error {
+	if err := os.MkdirAll(filepath.Join(dir, "snippets"), 0o755); err != nil {
+		return err
+	}
+	sources := make(map[string][]template.HTML)
+	var links []string
+	for i, d := range r.DeadCode {
+		lines, ok := sources[d.Pos.Filename]
+		if !ok {
+			src, err := r.readFile(d.Pos.Filename)
+			if err != nil {
+				return err
+			}
+			lines = highlightGo(src)
+			sources[d.Pos.Filename] = lines
+		}
+		start := max(1, d.Pos.Line-snippetContext)
+		end := min(len(lines), d.Pos.Line+snippetContext)
+		s := reportSnippet{
+			File:      relPath(d.Pos.Filename, r.Root),
+			Start:     start,
+			Mark:      d.Pos.Line,
+			Lines:     lines[start-1 : end],
+			Message:   d.Message,
+			IndexPath: "../index.html",
+		}
+		name := fmt.Sprintf("snippets/%d.html", i+1)
+		if err := writeTemplateFile(filepath.Join(dir, filepath.FromSlash(name)), snippetTemplate, s); err != nil {
+			return err
+		}
+		links = append(links, name)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "style.css"), []byte(reportCSS), 0o644); err != nil {
+		return err
+	}
+	var pkgs, classes bytes.Buffer
+	writePkgGraphMermaid(&pkgs, r.Packages)
+	writeMermaidClasses(&classes, r.Classes)
+	return writeTemplateFile(filepath.Join(dir, "index.html"), indexTemplate, struct {
+		*Report
+		Links    []string
+		Packages string
+		Classes  string
+	}{r, links, pkgs.String(), classes.String()})
+}
+
+func writeTemplateFile(path string, tmpl *template.Template, data interface{}) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := tmpl.Execute(f, data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// highlightGo は Go のソースコードを行ごとに、キーワード、文字列、数値、コメントを span で囲んだ HTML にする
+func highlightGo(src []byte) []template.HTML {
+	var lines []template.HTML
+	var line strings.Builder
+	// text を class の span で囲んで追加する。複数行にわたるトークンは行ごとに span を閉じる
+	emit := func(class, text string) {
+		for i, part := range strings.Split(text, "\n") {
+			if i > 0 {
+				lines = append(lines, template.HTML(line.String()))
+				line.Reset()
+			}
+			if part == "" {
+				continue
+			}
+			if class == "" {
+				line.WriteString(template.HTMLEscapeString(part))
+			} else {
+				fmt.Fprintf(&line, `<span class="%s">%s</span>`, class, template.HTMLEscapeString(part))
+			}
+		}
+	}
+
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var s scanner.Scanner
+	s.Init(file, src, nil, scanner.ScanComments)
+	offset := 0
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		start := file.Offset(pos)
+		if tok == token.SEMICOLON && lit == "\n" || start < offset {
+			continue // 自動で挿入されたセミコロン
+		}
+		end := start + len(tok.String())
+		if lit != "" {
+			end = start + len(lit)
+		}
+		class := ""
+		switch {
+		case tok.IsKeyword():
+			class = "kw"
+		case tok == token.STRING || tok == token.CHAR:
+			class = "str"
+		case tok == token.INT || tok == token.FLOAT || tok == token.IMAG:
+			class = "num"
+		case tok == token.COMMENT:
+			class = "com"
+		}
+		emit("", string(src[offset:start]))
+		emit(class, string(src[start:end]))
+		offset = end
+	}
+	emit("", string(src[offset:]))
+	if line.Len() > 0 {
+		lines = append(lines, template.HTML(line.String()))
+	}
+	return lines
+}
+
+var templateFuncs = template.FuncMap{
+	"add": func(a, b int) int { return a + b },
+	"pos": relPosition,
+}
+
+var indexTemplate = template.Must(template.New("index").Funcs(templateFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Analysis report: {{.Module}}</title>
+<link rel="stylesheet" href="style.css">
+<script type="module">
+import mermaid from "https://cdn.jsdelivr.net/npm/mermaid@10/dist/mermaid.esm.min.mjs";
+mermaid.initialize({startOnLoad: true});
+</script>
+</head>
+<body>
+<h1>Analysis report: {{.Module}}</h1>
+
+<h2>Metrics</h2>
+<table>
+<tr><th>Package</th><th>Files</th><th>Lines</th><th>Funcs</th><th>Types</th><th>Exported</th><th>Max complexity</th><th>Avg complexity</th></tr>
+{{range .Metrics}}<tr><td><code>{{.Package}}</code></td><td>{{.Files}}</td><td>{{.Lines}}</td><td>{{.Funcs}}</td><td>{{.Types}}</td><td>{{.Exported}}</td><td>{{.MaxComplexity}}</td><td>{{printf "%.2f" .AvgComplexity}}</td></tr>
+{{end}}</table>
+
+<h2>Dead code</h2>
+{{if .DeadCode}}<table>
+<tr><th>Position</th><th>Finding</th></tr>
+{{range $i, $d := .DeadCode}}<tr><td><a href="{{index $.Links $i}}">{{pos $d.Pos $.Root}}</a></td><td>{{$d.Message}}</td></tr>
+{{end}}</table>
+{{else}}<p>No functions are unreachable from the entry points.</p>
+{{end}}
+<h2>Interfaces</h2>
+{{if .Interfaces.Rows}}<table>
+<tr><th>Type</th>{{range .Interfaces.Interfaces}}<th><code>{{.}}</code></th>{{end}}</tr>
+{{range .Interfaces.Rows}}<tr><td><code>{{.Type}}</code></td>{{range .Implements}}<td>{{if .}}✓{{end}}</td>{{end}}</tr>
+{{end}}</table>
+{{else}}<p>No type implements an interface declared in the module.</p>
+{{end}}
+<h2>Call graph</h2>
+<p>{{.CallGraph.Functions}} functions, {{.CallGraph.Edges}} call edges (standard library excluded).</p>
+<table>
+<tr><th>Most called</th><th>Callers</th></tr>
+{{range .CallGraph.FanIn}}<tr><td><code>{{.Func}}</code></td><td>{{.Count}}</td></tr>
+{{end}}</table>
+<table>
+<tr><th>Most calling</th><th>Callees</th></tr>
+{{range .CallGraph.FanOut}}<tr><td><code>{{.Func}}</code></td><td>{{.Count}}</td></tr>
+{{end}}</table>
+
+<h2>Package dependencies</h2>
+<pre class="mermaid">
+{{.Packages}}</pre>
+
+<h2>Types</h2>
+<pre class="mermaid">
+{{.Classes}}</pre>
+</body>
+</html>
+`))
+
+var snippetTemplate = template.Must(template.New("snippet").Funcs(templateFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.File}}:{{.Mark}}</title>
+<link rel="stylesheet" href="../style.css">
+</head>
+<body>
+<p><a href="{{.IndexPath}}">Back to the report</a></p>
+<h1>{{.File}}:{{.Mark}}</h1>
+<p>{{.Message}}</p>
+<pre class="source">
+{{range $i, $line := .Lines}}{{$n := add $.Start $i}}<span id="L{{$n}}" class="line{{if eq $n $.Mark}} mark{{end}}"><span class="lineno">{{$n}}</span>{{$line}}</span>{{end}}</pre>
+</body>
+</html>
+`))
+
+const reportCSS = `body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 0.2em 0.6em; }
+pre.source { background: #f8f8f8; padding: 0.5em; }
+.line { display: block; }
+.mark { background: #fff3b0; }
+.lineno { display: inline-block; width: 4em; color: #999; user-select: none; }
+.kw { color: #0033b3; font-weight: bold; }
+.str { color: #067d17; }
+.num { color: #1750eb; }
+.com { color: #8c8c8c; font-style: italic; }
+`
@@ -0,0 +1,536 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// Route はルーティングの登録 1 件
+type Route struct {
+	Method     string         `json:"method"` // ANY はメソッドを限定しないもの
+	Path       string         `json:"path"`
+	Handler    string         `json:"handler"`
+	Middleware []string       `json:"middleware,omitempty"` // ハンドラを包むミドルウェア (外側から順、解決できなければ ?)
+	Package    string         `json:"package"`              // 登録しているパッケージ
+	Pos        token.Position `json:"pos"`
+
+	Func            *ssa.Function   `json:"-"` // Handler の関数 (解決できなければ nil)
+	MiddlewareFuncs []*ssa.Function `json:"-"` // Middleware の関数 (解決できなければ nil)
+}
+
+// routeRegistrar はルートを登録する関数の引数の位置 (レシーバを除く)。
+// Method が空なら MethodArg 番目の引数 (Pattern なら "GET /users" のようにパスの先頭) がメソッド、
+// HandlerArg が -1 なら可変長引数の最後の要素がハンドラで、前の要素はミドルウェア。
+// Middleware が true ならハンドラの後の可変長引数がミドルウェア (echo)
+type routeRegistrar struct {
+	Method     string
+	MethodArg  int
+	Pattern    bool
+	PathArg    int
+	HandlerArg int
+	Middleware bool
+}
+
+// routeGroup はパスのプレフィックスを持つルーターを作る関数。
+// Func が true なら ルーターを受け取る関数を FuncArg 番目の引数にとる (chi の Route, Group)
+type routeGroup struct {
+	PathArg    int // -1 ならプレフィックスを追加しない
+	Func       bool
+	FuncArg    int
+	Middleware bool // 最後の可変長引数が、作ったルーターのルートに追加するミドルウェア (gin と echo の Group, chi の With)
+}
+
+var (
+	routeRegistrars = make(map[string]routeRegistrar)
+	routeGroups     = make(map[string]routeGroup)
+	routeUses       = make(map[string]bool) // 可変長引数のミドルウェアをルーターに追加するメソッド (Use)
+)
+
+func init() {
+	for _, name := range []string{"net/http.Handle", "net/http.HandleFunc", "(*net/http.ServeMux).Handle", "(*net/http.ServeMux).HandleFunc"} {
+		routeRegistrars[name] = routeRegistrar{Pattern: true, PathArg: 0, HandlerArg: 1}
+	}
+
+	methods := []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS", "CONNECT", "TRACE"}
+	for _, recv := range []string{"(github.com/go-chi/chi/v5.Router)", "(*github.com/go-chi/chi/v5.Mux)"} {
+		for _, m := range methods {
+			routeRegistrars[recv+"."+m[:1]+strings.ToLower(m[1:])] = routeRegistrar{Method: m, PathArg: 0, HandlerArg: 1}
+		}
+		routeRegistrars[recv+".Handle"] = routeRegistrar{Method: "ANY", PathArg: 0, HandlerArg: 1}
+		routeRegistrars[recv+".HandleFunc"] = routeRegistrar{Method: "ANY", PathArg: 0, HandlerArg: 1}
+		routeRegistrars[recv+".Method"] = routeRegistrar{MethodArg: 0, PathArg: 1, HandlerArg: 2}
+		routeRegistrars[recv+".MethodFunc"] = routeRegistrar{MethodArg: 0, PathArg: 1, HandlerArg: 2}
+		routeGroups[recv+".Route"] = routeGroup{PathArg: 0, Func: true, FuncArg: 1}
+		routeGroups[recv+".Group"] = routeGroup{PathArg: -1, Func: true, FuncArg: 0}
+		routeGroups[recv+".With"] = routeGroup{PathArg: -1, Middleware: true}
+		routeUses[recv+".Use"] = true
+	}
+
+	for _, recv := range []string{"(*github.com/gin-gonic/gin.RouterGroup)", "(github.com/gin-gonic/gin.IRoutes)", "(github.com/gin-gonic/gin.IRouter)"} {
+		for _, m := range methods[:7] {
+			routeRegistrars[recv+"."+m] = routeRegistrar{Method: m, PathArg: 0, HandlerArg: -1}
+		}
+		routeRegistrars[recv+".Any"] = routeRegistrar{Method: "ANY", PathArg: 0, HandlerArg: -1}
+		routeRegistrars[recv+".Handle"] = routeRegistrar{MethodArg: 0, PathArg: 1, HandlerArg: -1}
+		routeGroups[recv+".Group"] = routeGroup{PathArg: 0, Middleware: true}
+		routeUses[recv+".Use"] = true
+	}
+	routeUses["(*github.com/gin-gonic/gin.Engine).Use"] = true
+
+	for _, recv := range []string{"(*github.com/labstack/echo/v4.Echo)", "(*github.com/labstack/echo/v4.Group)"} {
+		for _, m := range methods {
+			routeRegistrars[recv+"."+m] = routeRegistrar{Method: m, PathArg: 0, HandlerArg: 1, Middleware: true}
+		}
+		routeRegistrars[recv+".Any"] = routeRegistrar{Method: "ANY", PathArg: 0, HandlerArg: 1, Middleware: true}
+		routeRegistrars[recv+".Add"] = routeRegistrar{MethodArg: 0, PathArg: 1, HandlerArg: 2, Middleware: true}
+		routeGroups[recv+".Group"] = routeGroup{PathArg: 0, Middleware: true}
+		routeUses[recv+".Use"] = true
+	}
+}
+
+func runRoutes(args []string) error {
+	fs := flag.NewFlagSet("routes", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output as JSON")
+	asMarkdown := fs.Bool("md", false, "output as a Markdown table")
+	fs.Parse(args)
+	prog, err := loadProgram(".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	routes := httpRoutes(prog)
+	switch {
+	case *asJSON:
+		return writeJSON(os.Stdout, routes)
+	case *asMarkdown:
+		return writeRoutesMarkdown(os.Stdout, routes)
+	}
+	return writeRoutes(os.Stdout, routes)
+}
+
+// httpRoutes は net/http, chi, gin, echo のルート登録を探し、ハンドラとそれを包むミドルウェアを解決したルート表を返す。
+// ミドルウェアは、ルーターとその親のルーターの Use (登録の前後は区別しない)、Group や With の引数、
+// ルートの登録の引数、m1(m2(h)) のようにハンドラを包む関数の呼び出しの順に外側から並べる
+func httpRoutes(prog *Program) []Route {
+	var routes []Route
+	for _, fn := range prog.targetFunctions() {
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				call, ok := instr.(*ssa.Call)
+				if !ok {
+					continue
+				}
+				reg, ok := routeRegistrars[calleeName(&call.Call)]
+				if !ok {
+					continue
+				}
+				args := callArgs(&call.Call)
+				method, path := reg.Method, constString(args[reg.PathArg])
+				if reg.Pattern {
+					if m, p, ok := strings.Cut(path, " "); ok {
+						method, path = m, strings.TrimSpace(p)
+					}
+				} else if method == "" {
+					method = constString(args[reg.MethodArg])
+				}
+				path = routePrefix(prog, receiver(&call.Call), 0) + path
+				if method == "" {
+					method = "ANY"
+				}
+				middleware := routeMiddleware(receiver(&call.Call), 0)
+				var handler ssa.Value
+				if reg.HandlerArg >= 0 {
+					handler = args[reg.HandlerArg]
+					if reg.Middleware && len(args) > reg.HandlerArg+1 {
+						middleware = append(middleware, varargValues(args[len(args)-1])...)
+					}
+				} else if elems := varargValues(args[len(args)-1]); len(elems) > 0 {
+					handler = elems[len(elems)-1]
+					middleware = append(middleware, elems[:len(elems)-1]...)
+				}
+				handler, wrappers := unwrapMiddleware(handler)
+				middleware = append(middleware, wrappers...)
+				route := Route{Method: method, Path: path, Package: fn.Pkg.Pkg.Path(), Pos: prog.Fset.Position(call.Pos())}
+				for _, m := range middleware {
+					mf := middlewareFunc(prog, m)
+					route.MiddlewareFuncs = append(route.MiddlewareFuncs, mf)
+					if mf == nil {
+						route.Middleware = append(route.Middleware, "?")
+					} else {
+						route.Middleware = append(route.Middleware, mf.RelString(nil))
+					}
+				}
+				handlers := resolveHandlers(prog, handler, make(map[ssa.Value]bool))
+				if len(handlers) == 0 {
+					route.Handler = "?"
+					routes = append(routes, route)
+				}
+				for _, h := range handlers {
+					route.Handler = h.RelString(nil)
+					route.Func = h
+					routes = append(routes, route)
+				}
+			}
+		}
+	}
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// calleeName は呼び出し先の関数またはインターフェースのメソッドの完全な名前を返す
+func calleeName(call *ssa.CallCommon) string {
+	if call.IsInvoke() {
+		return call.Method.FullName()
+	}
+	if fn := call.StaticCallee(); fn != nil {
+		if obj, ok := fn.Object().(*types.Func); ok {
+			return obj.FullName()
+		}
+	}
+	return ""
+}
+
+// callArgs はレシーバを除いた呼び出しの引数を返す
+func callArgs(call *ssa.CallCommon) []ssa.Value {
+	if fn := call.StaticCallee(); fn != nil && fn.Signature.Recv() != nil {
+		return call.Args[1:]
+	}
+	return call.Args
+}
+
+// receiver はメソッド呼び出しのレシーバを返す
+func receiver(call *ssa.CallCommon) ssa.Value {
+	if call.IsInvoke() {
+		return call.Value
+	}
+	if fn := call.StaticCallee(); fn != nil && fn.Signature.Recv() != nil {
+		return call.Args[0]
+	}
+	return nil
+}
+
+// constString は v が文字列定数であればその値を返す
+func constString(v ssa.Value) string {
+	if c, ok := v.(*ssa.Const); ok && c.Value != nil && c.Value.Kind() == constant.String {
+		return constant.StringVal(c.Value)
+	}
+	return ""
+}
+
+// varargValues は可変長引数として作られたスライス v の要素を返す
+func varargValues(v ssa.Value) []ssa.Value {
+	slice, ok := v.(*ssa.Slice)
+	if !ok {
+		return nil
+	}
+	alloc, ok := slice.X.(*ssa.Alloc)
+	if !ok {
+		return nil
+	}
+	var elems []ssa.Value
+	for _, ref := range *alloc.Referrers() {
+		addr, ok := ref.(*ssa.IndexAddr)
+		if !ok {
+			continue
+		}
+		for _, r := range *addr.Referrers() {
+			if store, ok := r.(*ssa.Store); ok && store.Addr == addr {
+				elems = append(elems, store.Val)
+			}
+		}
+	}
+	return elems
+}
+
+// routePrefix はルーター v に gin や echo の Group、chi の Route でつけられたパスのプレフィックスを返す
+func routePrefix(prog *Program, v ssa.Value, depth int) string {
+	if v == nil || depth > 10 {
+		return ""
+	}
+	switch v := v.(type) {
+	case *ssa.MakeInterface:
+		return routePrefix(prog, v.X, depth+1)
+	case *ssa.ChangeType:
+		return routePrefix(prog, v.X, depth+1)
+	case *ssa.Call:
+		if g, ok := routeGroups[calleeName(&v.Call)]; ok && !g.Func {
+			prefix := routePrefix(prog, receiver(&v.Call), depth+1)
+			if g.PathArg >= 0 {
+				prefix += constString(callArgs(&v.Call)[g.PathArg])
+			}
+			return prefix
+		}
+	case *ssa.Parameter:
+		// chi の r.Route("/users", func(r chi.Router) { ... }) の r
+		if call, g := enclosingRouteGroup(v); call != nil {
+			prefix := routePrefix(prog, receiver(&call.Call), depth+1)
+			if g.PathArg >= 0 {
+				prefix += constString(callArgs(&call.Call)[g.PathArg])
+			}
+			return prefix
+		}
+	}
+	return ""
+}
+
+// enclosingRouteGroup は p が chi の Route や Group に渡された関数の、ルーターを受け取る最初の引数なら、その呼び出しを返す
+func enclosingRouteGroup(p *ssa.Parameter) (*ssa.Call, routeGroup) {
+	fn := p.Parent()
+	if fn.Parent() == nil || len(fn.Params) == 0 || fn.Params[0] != p {
+		return nil, routeGroup{}
+	}
+	for _, b := range fn.Parent().Blocks {
+		for _, instr := range b.Instrs {
+			call, ok := instr.(*ssa.Call)
+			if !ok {
+				continue
+			}
+			if g, ok := routeGroups[calleeName(&call.Call)]; ok && g.Func && isFunc(callArgs(&call.Call)[g.FuncArg], fn) {
+				return call, g
+			}
+		}
+	}
+	return nil, routeGroup{}
+}
+
+// routeMiddleware はルーター v のルートに適用されるミドルウェアの値を外側から順に返す。
+// 親のルーター (Group, With, chi の Route の呼び出し元) のものが先で、v 自身の Use で追加したものが後
+func routeMiddleware(v ssa.Value, depth int) []ssa.Value {
+	if v == nil || depth > 10 {
+		return nil
+	}
+	var outer []ssa.Value
+	switch v := v.(type) {
+	case *ssa.MakeInterface:
+		outer = routeMiddleware(v.X, depth+1)
+	case *ssa.ChangeType:
+		outer = routeMiddleware(v.X, depth+1)
+	case *ssa.FieldAddr:
+		// gin の Engine に埋め込まれた RouterGroup のメソッドは Engine の Use のミドルウェアも適用される
+		outer = routeMiddleware(v.X, depth+1)
+	case *ssa.Call:
+		if g, ok := routeGroups[calleeName(&v.Call)]; ok && !g.Func {
+			outer = routeMiddleware(receiver(&v.Call), depth+1)
+			if args := callArgs(&v.Call); g.Middleware && len(args) > 0 {
+				outer = append(outer, varargValues(args[len(args)-1])...)
+			}
+		}
+	case *ssa.Parameter:
+		if call, _ := enclosingRouteGroup(v); call != nil {
+			outer = routeMiddleware(receiver(&call.Call), depth+1)
+		}
+	}
+	refs := v.Referrers()
+	if refs == nil {
+		return outer
+	}
+	for _, ref := range *refs {
+		call, ok := ref.(*ssa.Call)
+		if !ok || !routeUses[calleeName(&call.Call)] || receiver(&call.Call) != v {
+			continue
+		}
+		if args := callArgs(&call.Call); len(args) > 0 {
+			outer = append(outer, varargValues(args[len(args)-1])...)
+		}
+	}
+	return outer
+}
+
+// unwrapMiddleware は m1(m2(h)) のようにハンドラを包む関数の呼び出しをほどいて、中のハンドラと包んでいる呼び出し (外側から順) を返す。
+// 呼び出し先が静的に決まり、ハンドラの型の引数をとってハンドラの型を返す関数をミドルウェアとみなす
+func unwrapMiddleware(v ssa.Value) (ssa.Value, []ssa.Value) {
+	var wrappers []ssa.Value
+	for len(wrappers) < 10 {
+		inner := v
+	unwrap:
+		for {
+			switch x := inner.(type) {
+			case *ssa.MakeInterface:
+				inner = x.X
+			case *ssa.ChangeType:
+				inner = x.X
+			case *ssa.Convert:
+				inner = x.X
+			default:
+				break unwrap
+			}
+		}
+		call, ok := inner.(*ssa.Call)
+		if !ok || call.Call.StaticCallee() == nil || !isHandlerType(call.Type()) {
+			break
+		}
+		var next ssa.Value
+		for _, arg := range callArgs(&call.Call) {
+			if isHandlerType(arg.Type()) {
+				next = arg
+				break
+			}
+		}
+		if next == nil {
+			break
+		}
+		wrappers = append(wrappers, call)
+		v = next
+	}
+	return v, wrappers
+}
+
+// isHandlerType は t が関数か、ServeHTTP だけを持つインターフェース (http.Handler) かどうかを返す
+func isHandlerType(t types.Type) bool {
+	switch u := t.Underlying().(type) {
+	case *types.Signature:
+		return true
+	case *types.Interface:
+		return u.NumMethods() == 1 && u.Method(0).Name() == "ServeHTTP"
+	}
+	return false
+}
+
+// middlewareFunc はミドルウェアの値の関数を返す。middleware.Timeout(d) のようにミドルウェアを作る関数の呼び出しや、
+// ハンドラを包む関数の呼び出しなら呼び出し先の関数
+func middlewareFunc(prog *Program, v ssa.Value) *ssa.Function {
+	switch v := v.(type) {
+	case *ssa.Function:
+		if obj, ok := v.Object().(*types.Func); ok && v.Synthetic != "" {
+			if fn := prog.SSA().FuncValue(obj); fn != nil {
+				return fn
+			}
+		}
+		return v
+	case *ssa.MakeClosure:
+		return middlewareFunc(prog, v.Fn)
+	case *ssa.MakeInterface:
+		return middlewareFunc(prog, v.X)
+	case *ssa.ChangeType:
+		return middlewareFunc(prog, v.X)
+	case *ssa.Convert:
+		return middlewareFunc(prog, v.X)
+	case *ssa.Call:
+		return v.Call.StaticCallee()
+	}
+	return nil
+}
+
+// isFunc は v が関数 fn そのもの、または fn のクロージャかどうかを返す
+func isFunc(v ssa.Value, fn *ssa.Function) bool {
+	if c, ok := v.(*ssa.MakeClosure); ok {
+		v = c.Fn
+	}
+	return v == fn
+}
+
+// resolveHandlers はハンドラとして渡された値から実際に呼び出される関数を返す。
+// 関数を返す関数の呼び出しは、コールグラフで呼び出し先の return をたどる
+func resolveHandlers(prog *Program, v ssa.Value, seen map[ssa.Value]bool) []*ssa.Function {
+	if v == nil || seen[v] {
+		return nil
+	}
+	seen[v] = true
+	switch v := v.(type) {
+	case *ssa.Function:
+		// メソッド値 h.list のラッパーはメソッドそのものにする
+		if obj, ok := v.Object().(*types.Func); ok && v.Synthetic != "" {
+			if fn := prog.SSA().FuncValue(obj); fn != nil {
+				return []*ssa.Function{fn}
+			}
+		}
+		return []*ssa.Function{v}
+	case *ssa.MakeClosure:
+		return resolveHandlers(prog, v.Fn, seen)
+	case *ssa.ChangeType:
+		return resolveHandlers(prog, v.X, seen)
+	case *ssa.Convert:
+		return resolveHandlers(prog, v.X, seen)
+	case *ssa.MakeInterface:
+		if _, ok := v.X.Type().Underlying().(*types.Signature); ok {
+			return resolveHandlers(prog, v.X, seen)
+		}
+		// http.Handler を実装した値なら ServeHTTP
+		if sel := prog.SSA().MethodSets.MethodSet(v.X.Type()).Lookup(nil, "ServeHTTP"); sel != nil {
+			return []*ssa.Function{prog.SSA().MethodValue(sel)}
+		}
+	case *ssa.Phi:
+		var fns []*ssa.Function
+		for _, e := range v.Edges {
+			fns = append(fns, resolveHandlers(prog, e, seen)...)
+		}
+		return fns
+	case *ssa.Call:
+		var fns []*ssa.Function
+		if node := prog.CallGraph().Nodes[v.Parent()]; node != nil {
+			for _, e := range node.Out {
+				if e.Site != v {
+					continue
+				}
+				callee := e.Callee.Func
+				for _, b := range callee.Blocks {
+					ret, ok := b.Instrs[len(b.Instrs)-1].(*ssa.Return)
+					if !ok || len(ret.Results) != 1 {
+						continue
+					}
+					result := ret.Results[0]
+					// 引数をそのまま返す関数なら呼び出し側の引数
+					for i, p := range callee.Params {
+						if p == result && !v.Call.IsInvoke() && i < len(v.Call.Args) {
+							result = v.Call.Args[i]
+						}
+					}
+					fns = append(fns, resolveHandlers(prog, result, seen)...)
+				}
+			}
+		}
+		return fns
+	}
+	return nil
+}
+
+func writeRoutes(w io.Writer, routes []Route) error {
+	for _, r := range routes {
+		if len(r.Middleware) > 0 {
+			fmt.Fprintf(w, "%-7s %-24s %s (via %s)\n", r.Method, r.Path, r.Handler, strings.Join(r.Middleware, ", "))
+			continue
+		}
+		fmt.Fprintf(w, "%-7s %-24s %s\n", r.Method, r.Path, r.Handler)
+	}
+	return nil
+}
+
+func writeRoutesMarkdown(w io.Writer, routes []Route) error {
+	fmt.Fprintln(w, "| Method | Path | Handler | Middleware | Package |")
+	fmt.Fprintln(w, "| --- | --- | --- | --- | --- |")
+	for _, r := range routes {
+		var middleware []string
+		for _, m := range r.Middleware {
+			middleware = append(middleware, "`"+m+"`")
+		}
+		fmt.Fprintf(w, "| %s | `%s` | `%s` | %s | %s |\n", r.Method, r.Path, r.Handler, strings.Join(middleware, ", "), r.Package)
+	}
+	return nil
+}
+
+// findRoute は "GET /users" か "/users" (メソッドを問わない) の形の spec に一致するルートを返す
+func findRoute(routes []Route, spec string) (Route, error) {
+	method, path, ok := strings.Cut(strings.TrimSpace(spec), " ")
+	if !ok {
+		method, path = "", method
+	}
+	for _, r := range routes {
+		if r.Path == strings.TrimSpace(path) && (method == "" || strings.EqualFold(r.Method, method) || r.Method == "ANY") {
+			return r, nil
+		}
+	}
+	return Route{}, notFound("route %s not found", spec)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var testdata_routes = map[string]string{
+	"go.mod": `module example.com/m
+
+go 1.22
+
+require (
+	github.com/gin-gonic/gin v0.0.0
+	github.com/go-chi/chi/v5 v5.0.0
+)
+
+replace github.com/gin-gonic/gin => ./third_party/gin
+
+replace github.com/go-chi/chi/v5 => ./third_party/chi
+`,
+	"third_party/gin/go.mod": "module github.com/gin-gonic/gin\n\ngo 1.22\n",
+	"third_party/gin/gin.go": `package gin
+
+type Context struct{}
+
+type HandlerFunc func(*Context)
+
+type IRoutes interface {
+	GET(path string, handlers ...HandlerFunc) IRoutes
+}
+
+type RouterGroup struct{ prefix string }
+
+func (g *RouterGroup) Group(path string, handlers ...HandlerFunc) *RouterGroup {
+	return &RouterGroup{prefix: g.prefix + path}
+}
+
+func (g *RouterGroup) GET(path string, handlers ...HandlerFunc) IRoutes  { return g }
+func (g *RouterGroup) POST(path string, handlers ...HandlerFunc) IRoutes { return g }
+
+type Engine struct{ RouterGroup }
+
+func (e *Engine) Use(middleware ...HandlerFunc) IRoutes { return e }
+
+func New() *Engine { return &Engine{} }
+`,
+	"third_party/chi/go.mod": "module github.com/go-chi/chi/v5\n\ngo 1.22\n",
+	"third_party/chi/chi.go": `package chi
+
+import "net/http"
+
+type Router interface {
+	Get(pattern string, h http.HandlerFunc)
+	Route(pattern string, fn func(r Router)) Router
+	Use(middlewares ...func(http.Handler) http.Handler)
+}
+
+type Mux struct{}
+
+func NewRouter() *Mux { return &Mux{} }
+
+func (m *Mux) Get(pattern string, h http.HandlerFunc)             {}
+func (m *Mux) Route(pattern string, fn func(r Router)) Router     { return m }
+func (m *Mux) Use(middlewares ...func(http.Handler) http.Handler) {}
+`,
+	"main.go": `package main
+
+import (
+	"net/http"
+
+	"example.com/m/api"
+)
+
+type users struct{}
+
+func (users) list(w http.ResponseWriter, r *http.Request) {}
+
+type health struct{}
+
+func (health) ServeHTTP(w http.ResponseWriter, r *http.Request) {}
+
+func index(w http.ResponseWriter, r *http.Request) {}
+
+func static(dir string) http.Handler {
+	return http.FileServer(http.Dir(dir))
+}
+
+func withLog(h http.HandlerFunc) http.HandlerFunc {
+	return h
+}
+
+func main() {
+	var u users
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", index)
+	mux.HandleFunc("GET /users", u.list)
+	mux.Handle("/health", health{})
+	mux.Handle("/static/", static("public"))
+	http.HandleFunc("/logged", withLog(index))
+	api.Routes()
+}
+`,
+	"api/api.go": `package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-chi/chi/v5"
+)
+
+func recovery(c *gin.Context)                        {}
+func auth(c *gin.Context)                            {}
+func getItem(c *gin.Context)                         {}
+func getUser(w http.ResponseWriter, r *http.Request) {}
+
+func logger(next http.Handler) http.Handler      { return next }
+func requireUser(next http.Handler) http.Handler { return next }
+
+func Routes() {
+	e := gin.New()
+	e.Use(recovery)
+	v1 := e.Group("/v1")
+	v1.GET("/items/:id", auth, getItem)
+
+	r := chi.NewRouter()
+	r.Use(logger)
+	r.Route("/users", func(r chi.Router) {
+		r.Use(requireUser)
+		r.Get("/{id}", getUser)
+	})
+}
+`,
+}
+
+func TestHTTPRoutes(t *testing.T) {
+	prog := loadTestProgram(t, testdata_routes)
+	routes := httpRoutes(prog)
+	var buf bytes.Buffer
+	if err := writeRoutes(&buf, routes); err != nil {
+		t.Fatal(err)
+	}
+	assertLines(t, strings.Split(strings.TrimSpace(buf.String()), "\n"), []string{
+		"ANY     /                        example.com/m.index",
+		"ANY     /health                  (example.com/m.health).ServeHTTP",
+		"ANY     /logged                  example.com/m.index (via example.com/m.withLog)",
+		"ANY     /static/                 (*net/http.fileHandler).ServeHTTP",
+		"GET     /users                   (example.com/m.users).list",
+		"GET     /users/{id}              example.com/m/api.getUser (via example.com/m/api.logger, example.com/m/api.requireUser)",
+		"GET     /v1/items/:id            example.com/m/api.getItem (via example.com/m/api.recovery, example.com/m/api.auth)",
+	})
+
+	buf.Reset()
+	if err := writeRoutesMarkdown(&buf, routes[:1]); err != nil {
+		t.Fatal(err)
+	}
+	assertLines(t, strings.Split(strings.TrimSpace(buf.String()), "\n"), []string{
+		"| Method | Path | Handler | Middleware | Package |",
+		"| --- | --- | --- | --- | --- |",
+		"| ANY | `/` | `example.com/m.index` |  | example.com/m |",
+	})
+
+	r, err := findRoute(routes, "GET /users/{id}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := writeMermaidSequence(&buf, "GET /users/{id}", routeMessages(prog, "GET /users/{id}", r, 3, false)); err != nil {
+		t.Fatal(err)
+	}
+	assertLines(t, strings.Split(strings.TrimSpace(buf.String()), "\n"), []string{
+		"sequenceDiagram",
+		"    participant P0 as GET /users/{id}",
+		"    participant P1 as api",
+		"    P0->>P1: logger()",
+		"    P1->>P1: requireUser()",
+		"    P1->>P1: getUser()",
+	})
+}
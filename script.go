@@ -0,0 +1,249 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
+)
+
+// script コマンドは Starlark で書いた独自の検査を実行する。スクリプトには次の関数が定義されている。
+//
+//	symbols()          パッケージレベルの宣言とメソッド (name, kind, package, exported, pos)
+//	callsites()        呼び出し (caller, callee, kind, recv, args, pos)。args は引数の型
+//	callees(fn)        fn が直接呼ぶ関数の名前 (CHA のコールグラフ)
+//	callers(fn)        fn を直接呼ぶ解析対象の関数の名前
+//	reaches(from, to)  from から to へ呼び出しをたどれるか
+//	report(pos, message, severity="", category="")
+//	                   指摘を加える。pos は "file:line:col" か pos 属性を持つ値
+//
+// 関数の名前は callgraph や callsites と同じ example.com/m.Func, (*example.com/m.T).Method の形
+func runScript(args []string) error {
+	fs := flag.NewFlagSet("script", flag.ExitOnError)
+	file := fs.String("f", "", "Starlark `file` defining the checks")
+	return runDiagnostics(fs, args, func(prog *Program) ([]Diagnostic, error) {
+		if *file == "" {
+			return nil, errors.New("-f is required")
+		}
+		src, err := os.ReadFile(*file)
+		if err != nil {
+			return nil, err
+		}
+		return runStarlarkChecks(prog, *file, src)
+	})
+}
+
+// runStarlarkChecks は src のスクリプトを prog に対して実行し、report された指摘を返す。
+// 指摘のカテゴリーの既定はスクリプトのファイル名 (拡張子を除く)
+func runStarlarkChecks(prog *Program, filename string, src []byte) ([]Diagnostic, error) {
+	env := &scriptEnv{prog: prog, category: strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))}
+	thread := &starlark.Thread{
+		Name:  filename,
+		Print: func(_ *starlark.Thread, msg string) { fmt.Fprintln(os.Stderr, msg) },
+	}
+	// 検査はファイルの最上位に for や if で書けるようにする
+	opts := &syntax.FileOptions{TopLevelControl: true, GlobalReassign: true, While: true, Set: true}
+	if _, err := starlark.ExecFileOptions(opts, thread, filename, src, env.predeclared()); err != nil {
+		var evalErr *starlark.EvalError
+		if errors.As(err, &evalErr) {
+			return nil, errors.New(evalErr.Backtrace())
+		}
+		return nil, err
+	}
+	sortDiagnostics(env.diags)
+	return env.diags, nil
+}
+
+// scriptEnv はスクリプトから呼ばれる関数の状態。解析結果は最初に使われたときに求める
+type scriptEnv struct {
+	prog     *Program
+	category string
+	diags    []Diagnostic
+
+	symbols, callsites *starlark.List
+	callees, callers   map[string][]string
+}
+
+func (e *scriptEnv) predeclared() starlark.StringDict {
+	return starlark.StringDict{
+		"symbols":   starlark.NewBuiltin("symbols", e.symbolsFn),
+		"callsites": starlark.NewBuiltin("callsites", e.callsitesFn),
+		"callees":   starlark.NewBuiltin("callees", e.calleesFn),
+		"callers":   starlark.NewBuiltin("callers", e.callersFn),
+		"reaches":   starlark.NewBuiltin("reaches", e.reachesFn),
+		"report":    starlark.NewBuiltin("report", e.reportFn),
+	}
+}
+
+func (e *scriptEnv) symbolsFn(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 0); err != nil {
+		return nil, err
+	}
+	if e.symbols == nil {
+		var elems []starlark.Value
+		for _, s := range exportAnalysis(e.prog, nil).Symbols {
+			elems = append(elems, starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+				"name":     starlark.String(s.Name),
+				"kind":     starlark.String(s.Kind),
+				"package":  starlark.String(s.Package),
+				"exported": starlark.Bool(s.Exported),
+				"pos":      starlark.String(fmt.Sprintf("%s:%d:%d", s.Pos.Filename, s.Pos.Line, s.Pos.Column)),
+			}))
+		}
+		e.symbols = starlark.NewList(elems)
+		e.symbols.Freeze()
+	}
+	return e.symbols, nil
+}
+
+func (e *scriptEnv) callsitesFn(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 0); err != nil {
+		return nil, err
+	}
+	if e.callsites == nil {
+		sites := e.prog.CallSites()
+		relativizePositions(sites)
+		var elems []starlark.Value
+		for _, s := range sites {
+			var argTypes []starlark.Value
+			for _, t := range s.ArgTypes {
+				argTypes = append(argTypes, starlark.String(t))
+			}
+			elems = append(elems, starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+				"caller": starlark.String(s.CallerName),
+				"callee": starlark.String(s.CalleeName),
+				"kind":   starlark.String(s.Kind),
+				"recv":   starlark.String(s.RecvType),
+				"args":   starlark.Tuple(argTypes),
+				"pos":    starlark.String(s.Pos.String()),
+			}))
+		}
+		e.callsites = starlark.NewList(elems)
+		e.callsites.Freeze()
+	}
+	return e.callsites, nil
+}
+
+// edges は解析対象の関数から出るコールグラフの辺を名前で引ける形にする
+func (e *scriptEnv) edges() {
+	if e.callees != nil {
+		return
+	}
+	e.callees = make(map[string][]string)
+	e.callers = make(map[string][]string)
+	for fn, node := range e.prog.CallGraph().Nodes {
+		if fn == nil || fn.Pkg == nil || !e.prog.isTarget(fn.Pkg.Pkg) {
+			continue
+		}
+		caller := fn.String()
+		seen := make(map[string]bool)
+		for _, edge := range node.Out {
+			callee := edge.Callee.Func.String()
+			if !seen[callee] {
+				seen[callee] = true
+				e.callees[caller] = append(e.callees[caller], callee)
+				e.callers[callee] = append(e.callers[callee], caller)
+			}
+		}
+	}
+	for _, m := range []map[string][]string{e.callees, e.callers} {
+		for _, names := range m {
+			sort.Strings(names)
+		}
+	}
+}
+
+func stringList(names []string) *starlark.List {
+	elems := make([]starlark.Value, len(names))
+	for i, name := range names {
+		elems[i] = starlark.String(name)
+	}
+	return starlark.NewList(elems)
+}
+
+func (e *scriptEnv) calleesFn(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var fn string
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &fn); err != nil {
+		return nil, err
+	}
+	e.edges()
+	return stringList(e.callees[fn]), nil
+}
+
+func (e *scriptEnv) callersFn(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var fn string
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &fn); err != nil {
+		return nil, err
+	}
+	e.edges()
+	return stringList(e.callers[fn]), nil
+}
+
+func (e *scriptEnv) reachesFn(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var from, to string
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 2, &from, &to); err != nil {
+		return nil, err
+	}
+	e.edges()
+	seen := map[string]bool{from: true}
+	queue := []string{from}
+	for len(queue) > 0 {
+		fn := queue[0]
+		queue = queue[1:]
+		for _, callee := range e.callees[fn] {
+			if callee == to {
+				return starlark.True, nil
+			}
+			if !seen[callee] {
+				seen[callee] = true
+				queue = append(queue, callee)
+			}
+		}
+	}
+	return starlark.False, nil
+}
+
+func (e *scriptEnv) reportFn(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var at starlark.Value
+	var message, severity, category string
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "pos", &at, "message", &message, "severity?", &severity, "category?", &category); err != nil {
+		return nil, err
+	}
+	// 文字列以外は symbols() や callsites() の要素のように pos 属性を持つ値
+	if _, ok := at.(starlark.String); !ok {
+		var attr starlark.Value
+		if v, ok := at.(starlark.HasAttrs); ok {
+			attr, _ = v.Attr("pos")
+		}
+		if attr == nil {
+			return nil, fmt.Errorf("%s: %s has no pos attribute", b.Name(), at.Type())
+		}
+		at = attr
+	}
+	s, ok := starlark.AsString(at)
+	if !ok {
+		return nil, fmt.Errorf("%s: pos must be a string, got %s", b.Name(), at.Type())
+	}
+	pos := parsePosition(s)
+	if !pos.IsValid() {
+		return nil, fmt.Errorf("%s: invalid position %q", b.Name(), s)
+	}
+	if severity != "" {
+		if _, ok := severityRank[severity]; !ok {
+			return nil, fmt.Errorf("%s: unknown severity %q", b.Name(), severity)
+		}
+	}
+	if category == "" {
+		category = e.category
+	}
+	pos.Filename = absolutePath(pos.Filename)
+	e.diags = append(e.diags, Diagnostic{Pos: pos, Category: category, Severity: severity, Message: message})
+	return starlark.None, nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStarlarkChecks(t *testing.T) {
+	prog := loadTestProgram(t, map[string]string{
+		"audit/audit.go": "package audit\n\nfunc Log(event string) {}\n",
+		"main.go": `package main
+
+import (
+	"fmt"
+
+	"example.com/m/audit"
+)
+
+func record(name string) { audit.Log(name) }
+
+func CreateHandler() { record("create") }
+
+func DeleteHandler() { fmt.Println("delete") }
+
+func main() {
+	CreateHandler()
+	DeleteHandler()
+}
+`})
+	script := `
+for s in symbols():
+    if s.kind == "func" and s.name.endswith("Handler") and not reaches(s.name, "example.com/m/audit.Log"):
+        report(s, s.name + " must call audit.Log", severity = "error")
+
+for c in callsites():
+    if c.callee == "fmt.Println":
+        report(c.pos, "use the logger instead of fmt.Println in " + c.caller, category = "logging")
+
+if callers("example.com/m.record") != ["example.com/m.CreateHandler"]:
+    fail("callers: %s" % callers("example.com/m.record"))
+`
+	diags, err := runStarlarkChecks(prog, "audit.star", []byte(script))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, d := range diags {
+		got = append(got, d.Category+" "+severityOf(d)+" "+diagnosticMessages([]Diagnostic{d})[0])
+	}
+	assertLines(t, got, []string{
+		"audit error main.go:13: example.com/m.DeleteHandler must call audit.Log",
+		"logging warning main.go:13: use the logger instead of fmt.Println in example.com/m.DeleteHandler",
+	})
+
+	_, err = runStarlarkChecks(prog, "bad.star", []byte(`report("main.go:1", "x", severity = "fatal")`))
+	if err == nil || !strings.Contains(err.Error(), `unknown severity "fatal"`) {
+		t.Errorf("got %v, want an unknown severity error", err)
+	}
+}
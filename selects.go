@@ -0,0 +1,184 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// SelectStmt は 1 つの select 文
+type SelectStmt struct {
+	Func        string         `json:"func"`
+	Pos         token.Position `json:"pos"`
+	Cases       int            `json:"cases"` // default を除くケースの数
+	Default     bool           `json:"default"`
+	Timeouts    []string       `json:"timeouts,omitempty"` // タイマー (time.Time のチャネル) や ctx.Done() から受信するケースのチャネル
+	RequestPath bool           `json:"request_path"`       // HTTP や gRPC のハンドラから到達できる
+}
+
+func runSelects(args []string) error {
+	fs := flag.NewFlagSet("selects", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output as JSON")
+	requestOnly := fs.Bool("request", false, "only list select statements reachable from HTTP and gRPC handlers")
+	fs.Parse(args)
+	prog, err := loadProgram(".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	var result []SelectStmt
+	for _, s := range selectStatements(prog) {
+		if s.RequestPath || !*requestOnly {
+			result = append(result, s)
+		}
+	}
+	relativizePositions(result)
+	if *asJSON {
+		return writeJSON(os.Stdout, result)
+	}
+	return writeSelects(os.Stdout, result)
+}
+
+// selectStatements は解析対象のパッケージの select 文を位置の順に返す
+func selectStatements(prog *Program) []SelectStmt {
+	var result []SelectStmt
+	walkTimerCode(prog, func(pkg *packages.Package, fn string, request bool, n ast.Node, inLoop bool) {
+		sel, ok := n.(*ast.SelectStmt)
+		if !ok {
+			return
+		}
+		s := SelectStmt{Func: fn, Pos: prog.Fset.Position(sel.Pos()), RequestPath: request}
+		for _, stmt := range sel.Body.List {
+			clause := stmt.(*ast.CommClause)
+			if clause.Comm == nil {
+				s.Default = true
+				continue
+			}
+			s.Cases++
+			if ch := recvChan(clause.Comm); ch != nil && isTimeoutChan(pkg.TypesInfo, ch) {
+				s.Timeouts = append(s.Timeouts, types.ExprString(ch))
+			}
+		}
+		result = append(result, s)
+	})
+	return result
+}
+
+// checkSelects は HTTP や gRPC のハンドラから到達できる、default もタイムアウトのケースもない select 文と、
+// ループの中の time.After (反復ごとにタイマーを作り、発火するまで解放されない) を指摘する
+func checkSelects(prog *Program) []Diagnostic {
+	var diags []Diagnostic
+	for _, s := range selectStatements(prog) {
+		if s.RequestPath && !s.Default && len(s.Timeouts) == 0 {
+			diags = append(diags, Diagnostic{Pos: s.Pos, Category: "select",
+				Message: "select in a request handling path has no default or timeout case and may block the request indefinitely"})
+		}
+	}
+	walkTimerCode(prog, func(pkg *packages.Package, fn string, request bool, n ast.Node, inLoop bool) {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || !inLoop {
+			return
+		}
+		if path, name := pkgFuncName(pkg.TypesInfo, call); path == "time" && name == "After" {
+			diags = append(diags, newDiagnostic(prog.Fset, call.Pos(), "select",
+				"time.After in a loop creates a timer on every iteration; reuse a time.NewTimer with Reset"))
+		}
+	})
+	sortDiagnostics(diags)
+	return diags
+}
+
+// walkTimerCode は解析対象のパッケージの関数の本体のノードを、関数の名前、その関数が HTTP や gRPC の
+// ハンドラから到達できるかどうか、ノードがループ (無名関数を挟まない) の中にあるかどうかとともに visit に渡す
+func walkTimerCode(prog *Program, visit func(pkg *packages.Package, fn string, request bool, n ast.Node, inLoop bool)) {
+	requestPath := reachableFrom(prog, entryFunctions(prog, []string{entryHTTP, entryGRPC}))
+	for _, pkg := range prog.Packages {
+		for _, file := range pkg.Syntax {
+			for _, decl := range file.Decls {
+				fd, ok := decl.(*ast.FuncDecl)
+				if !ok || fd.Body == nil {
+					continue
+				}
+				obj, _ := pkg.TypesInfo.Defs[fd.Name].(*types.Func)
+				if obj == nil {
+					continue
+				}
+				name, request := obj.FullName(), false
+				if fn := prog.SSA().FuncValue(obj); fn != nil {
+					name, request = fn.String(), requestPath[fn]
+				}
+				var walk func(n ast.Node, inLoop bool)
+				walk = func(n ast.Node, inLoop bool) {
+					ast.Inspect(n, func(n ast.Node) bool {
+						switch n := n.(type) {
+						case *ast.ForStmt:
+							walk(n.Body, true)
+							return false
+						case *ast.RangeStmt:
+							walk(n.X, inLoop)
+							walk(n.Body, true)
+							return false
+						case *ast.FuncLit:
+							walk(n.Body, false)
+							return false
+						case nil:
+							return false
+						}
+						visit(pkg, name, request, n, inLoop)
+						return true
+					})
+				}
+				walk(fd.Body, false)
+			}
+		}
+	}
+}
+
+// recvChan は select のケースの通信が受信なら、受信するチャネルの式を返す
+func recvChan(comm ast.Stmt) ast.Expr {
+	var x ast.Expr
+	switch comm := comm.(type) {
+	case *ast.ExprStmt:
+		x = comm.X
+	case *ast.AssignStmt:
+		x = comm.Rhs[0]
+	}
+	if u, ok := ast.Unparen(x).(*ast.UnaryExpr); ok && u.Op == token.ARROW {
+		return u.X
+	}
+	return nil
+}
+
+// isTimeoutChan は ch が時刻を受け取るチャネル (time.After、time.Timer.C など) か context.Context の Done() かどうかを返す
+func isTimeoutChan(info *types.Info, ch ast.Expr) bool {
+	if call, ok := ast.Unparen(ch).(*ast.CallExpr); ok {
+		if fn, ok := calleeObject(info, call).(*types.Func); ok && fn.FullName() == "(context.Context).Done" {
+			return true
+		}
+	}
+	c, ok := info.TypeOf(ch).Underlying().(*types.Chan)
+	return ok && types.TypeString(c.Elem(), nil) == "time.Time"
+}
+
+func writeSelects(w io.Writer, result []SelectStmt) error {
+	for _, s := range result {
+		var flags []string
+		if s.Default {
+			flags = append(flags, "default")
+		}
+		if len(s.Timeouts) > 0 {
+			flags = append(flags, "timeout="+strings.Join(s.Timeouts, ","))
+		}
+		if s.RequestPath {
+			flags = append(flags, "request")
+		}
+		fmt.Fprintf(w, "%s:%d: %s cases=%d %s\n", s.Pos.Filename, s.Pos.Line, s.Func, s.Cases, strings.Join(flags, " "))
+	}
+	return nil
+}
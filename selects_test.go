@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+const selectsSrc = `package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+var results = make(chan string)
+
+func wait(ctx context.Context) string {
+	select {
+	case r := <-results:
+		return r
+	case <-ctx.Done():
+		return ""
+	}
+}
+
+func block() string {
+	select {
+	case r := <-results:
+		return r
+	case results <- "x":
+		return ""
+	}
+}
+
+func handle(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprint(w, wait(r.Context()), block())
+}
+
+func poll() {
+	timeout := time.NewTimer(time.Minute)
+	for {
+		select {
+		case r := <-results:
+			fmt.Println(r)
+		case <-time.After(time.Second):
+			return
+		case <-timeout.C:
+			return
+		}
+	}
+}
+
+func background() {
+	select {
+	case r := <-results:
+		fmt.Println(r)
+	default:
+	}
+}
+
+func main() {
+	http.HandleFunc("/", handle)
+	go poll()
+	background()
+	http.ListenAndServe(":8080", nil)
+}
+`
+
+func TestSelects(t *testing.T) {
+	prog := loadTestProgram(t, map[string]string{"main.go": selectsSrc})
+	var got []string
+	for _, s := range selectStatements(prog) {
+		got = append(got, fmt.Sprintf("%d: %s cases=%d default=%v timeouts=%v request=%v", s.Pos.Line, s.Func, s.Cases, s.Default, s.Timeouts, s.RequestPath))
+	}
+	assertLines(t, got, []string{
+		"13: example.com/m.wait cases=2 default=false timeouts=[ctx.Done()] request=true",
+		"22: example.com/m.block cases=2 default=false timeouts=[] request=true",
+		"37: example.com/m.poll cases=3 default=false timeouts=[time.After(time.Second) timeout.C] request=false",
+		"49: example.com/m.background cases=1 default=true timeouts=[] request=false",
+	})
+	assertLines(t, diagnosticMessages(checkSelects(prog)), []string{
+		"main.go:22: select in a request handling path has no default or timeout case and may block the request indefinitely",
+		"main.go:40: time.After in a loop creates a timer on every iteration; reuse a time.NewTimer with Reset",
+	})
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// SeqMessage はシーケンス図の 1 つのメッセージ (呼び出し)
+type SeqMessage struct {
+	From  string         `json:"from"` // 呼び出し元の参加者 (レシーバの型またはパッケージ)
+	To    string         `json:"to"`
+	Call  string         `json:"call"`  // 呼び出す関数・メソッドの名前
+	Depth int            `json:"depth"` // 起点の関数からの深さ (1 が起点の関数の中の呼び出し)
+	Pos   token.Position `json:"pos"`
+}
+
+// funcDecl は関数の宣言とそれを含むパッケージ
+type funcDecl struct {
+	Pkg  *packages.Package
+	Decl *ast.FuncDecl
+}
+
+func runSequence(args []string) error {
+	fs := flag.NewFlagSet("sequence", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output messages as JSON")
+	format := fs.String("format", "mermaid", "diagram format: mermaid or plantuml")
+	root := fs.String("root", "", "`function` to start from (e.g. pkg.Func or (*pkg.T).Method)")
+	route := fs.String("route", "", "start from the HTTP `route` (e.g. \"GET /users\") and follow its middleware chain into the handler")
+	depth := fs.Int("depth", 3, "maximum call depth to follow")
+	external := fs.Bool("external", false, "include calls to functions outside the analyzed packages")
+	fs.Parse(args)
+	if *root == "" && *route == "" {
+		return errors.New("-root or -route is required")
+	}
+	if *root != "" && *route != "" {
+		return errors.New("-root and -route cannot be used together")
+	}
+	if *format != "mermaid" && *format != "plantuml" {
+		return fmt.Errorf("invalid -format value %q (want mermaid or plantuml)", *format)
+	}
+	prog, err := loadProgram(".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	if *route != "" {
+		r, err := findRoute(httpRoutes(prog), *route)
+		if err != nil {
+			return err
+		}
+		first := r.Method + " " + r.Path
+		msgs := routeMessages(prog, first, r, *depth, *external)
+		if *asJSON {
+			return writeJSON(os.Stdout, msgs)
+		}
+		if *format == "plantuml" {
+			return writePlantUMLSequence(os.Stdout, first, msgs)
+		}
+		return writeMermaidSequence(os.Stdout, first, msgs)
+	}
+	var fn *types.Func
+	for _, f := range findFunctions(prog, *root) {
+		if obj, ok := f.Object().(*types.Func); ok {
+			fn = obj
+			break
+		}
+	}
+	if fn == nil {
+		return notFound("function %s not found", *root)
+	}
+	msgs := sequenceMessages(prog, fn, *depth, *external)
+	if *asJSON {
+		return writeJSON(os.Stdout, msgs)
+	}
+	if *format == "plantuml" {
+		return writePlantUMLSequence(os.Stdout, participant(fn), msgs)
+	}
+	return writeMermaidSequence(os.Stdout, participant(fn), msgs)
+}
+
+// funcDecls は解析対象のパッケージで宣言されている関数の宣言を返す
+func funcDecls(pkgs []*packages.Package) map[*types.Func]funcDecl {
+	decls := make(map[*types.Func]funcDecl)
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Syntax {
+			for _, decl := range file.Decls {
+				if fd, ok := decl.(*ast.FuncDecl); ok && fd.Body != nil {
+					if fn, ok := pkg.TypesInfo.Defs[fd.Name].(*types.Func); ok {
+						decls[fn] = funcDecl{Pkg: pkg, Decl: fd}
+					}
+				}
+			}
+		}
+	}
+	return decls
+}
+
+// sequenceMessages は root の本体の呼び出しを評価順にたどり、宣言のある関数には depth まで入っていく。
+// external が false なら解析対象のパッケージ以外の関数の呼び出しは含めない
+func sequenceMessages(prog *Program, root *types.Func, depth int, external bool) []SeqMessage {
+	decls := funcDecls(prog.Packages)
+	var msgs []SeqMessage
+	active := make(map[*types.Func]bool) // 再帰呼び出しで無限にたどらないようにする
+	var walk func(fn *types.Func, level int)
+	walk = func(fn *types.Func, level int) {
+		d, ok := decls[fn]
+		if !ok || level > depth || active[fn] {
+			return
+		}
+		active[fn] = true
+		defer delete(active, fn)
+		from := participant(fn)
+		var visit func(n ast.Node) bool
+		visit = func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			// 引数とレシーバの式の中の呼び出しが先に評価される
+			ast.Inspect(call.Fun, visit)
+			for _, arg := range call.Args {
+				ast.Inspect(arg, visit)
+			}
+			callee, ok := typeutil.Callee(d.Pkg.TypesInfo, call).(*types.Func)
+			if !ok || !external && !prog.isTarget(callee.Pkg()) {
+				return false
+			}
+			msgs = append(msgs, SeqMessage{
+				From:  from,
+				To:    participant(callee),
+				Call:  callee.Name(),
+				Depth: level,
+				Pos:   prog.Fset.Position(call.Pos()),
+			})
+			walk(callee.Origin(), level+1)
+			return false
+		}
+		ast.Inspect(d.Decl.Body, visit)
+	}
+	walk(root, 1)
+	return msgs
+}
+
+// routeMessages はルート first から r のミドルウェアを外側から順に通ってハンドラを呼び出すメッセージ (Depth は 0) と、
+// ハンドラの中の呼び出しを sequenceMessages と同じように depth までたどったメッセージを返す
+func routeMessages(prog *Program, first string, r Route, depth int, external bool) []SeqMessage {
+	var msgs []SeqMessage
+	from := first
+	for i, name := range r.Middleware {
+		to, call := "?", name
+		if fn := r.MiddlewareFuncs[i]; fn != nil {
+			to, call = ssaParticipant(fn), fn.Name()
+		}
+		msgs = append(msgs, SeqMessage{From: from, To: to, Call: call, Pos: r.Pos})
+		from = to
+	}
+	if r.Func == nil {
+		return append(msgs, SeqMessage{From: from, To: "?", Call: r.Handler, Pos: r.Pos})
+	}
+	msgs = append(msgs, SeqMessage{From: from, To: ssaParticipant(r.Func), Call: r.Func.Name(), Pos: r.Pos})
+	if obj, ok := r.Func.Object().(*types.Func); ok {
+		msgs = append(msgs, sequenceMessages(prog, obj, depth, external)...)
+	}
+	return msgs
+}
+
+// ssaParticipant は participant と同じだが、無名関数なら外側の関数の参加者にする
+func ssaParticipant(fn *ssa.Function) string {
+	for fn.Parent() != nil {
+		fn = fn.Parent()
+	}
+	if obj, ok := fn.Object().(*types.Func); ok {
+		return participant(obj)
+	}
+	if fn.Pkg != nil {
+		return fn.Pkg.Pkg.Name()
+	}
+	return "?"
+}
+
+// participant はシーケンス図での fn の参加者の名前を返す。メソッドならレシーバの型、関数ならパッケージ
+func participant(fn *types.Func) string {
+	if recv := fn.Type().(*types.Signature).Recv(); recv != nil {
+		t := recv.Type()
+		if ptr, ok := t.(*types.Pointer); ok {
+			t = ptr.Elem()
+		}
+		if named, ok := t.(*types.Named); ok {
+			return types.TypeString(named.Origin(), (*types.Package).Name)
+		}
+		return types.TypeString(t, (*types.Package).Name)
+	}
+	if fn.Pkg() == nil {
+		return "builtin"
+	}
+	return fn.Pkg().Name()
+}
+
+// sequenceParticipants は first を先頭に、メッセージに現れる順で参加者を返す
+func sequenceParticipants(first string, msgs []SeqMessage) []string {
+	names := []string{first}
+	seen := map[string]bool{first: true}
+	for _, m := range msgs {
+		for _, name := range []string{m.From, m.To} {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+func writeMermaidSequence(w io.Writer, first string, msgs []SeqMessage) error {
+	ids := make(map[string]string)
+	fmt.Fprintln(w, "sequenceDiagram")
+	for i, name := range sequenceParticipants(first, msgs) {
+		ids[name] = fmt.Sprintf("P%d", i)
+		fmt.Fprintf(w, "    participant %s as %s\n", ids[name], name)
+	}
+	for _, m := range msgs {
+		fmt.Fprintf(w, "    %s->>%s: %s()\n", ids[m.From], ids[m.To], m.Call)
+	}
+	return nil
+}
+
+func writePlantUMLSequence(w io.Writer, first string, msgs []SeqMessage) error {
+	ids := make(map[string]string)
+	fmt.Fprintln(w, "@startuml")
+	for i, name := range sequenceParticipants(first, msgs) {
+		ids[name] = fmt.Sprintf("P%d", i)
+		fmt.Fprintf(w, "participant %q as %s\n", name, ids[name])
+	}
+	for _, m := range msgs {
+		fmt.Fprintf(w, "%s -> %s : %s()\n", ids[m.From], ids[m.To], m.Call)
+	}
+	fmt.Fprintln(w, "@enduml")
+	return nil
+}
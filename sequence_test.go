@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"go/types"
+	"strings"
+	"testing"
+)
+
+var testdata_sequence = map[string]string{
+	"main.go": `package main
+
+import (
+	"fmt"
+
+	"example.com/m/store"
+)
+
+type Handler struct{ s *store.Store }
+
+func (h *Handler) Serve(id int) string {
+	v := h.s.Get(h.key(id))
+	return fmt.Sprint(v)
+}
+
+func (h *Handler) key(id int) string { return fmt.Sprint(id) }
+
+func main() {
+	h := &Handler{s: store.Open()}
+	println(h.Serve(1))
+}
+`,
+	"store/store.go": `package store
+
+type Store struct{ m map[string]string }
+
+func Open() *Store { return &Store{m: map[string]string{}} }
+
+func (s *Store) Get(k string) string { return s.lookup(k) }
+
+func (s *Store) lookup(k string) string { return s.m[k] }
+`,
+}
+
+func TestSequence(t *testing.T) {
+	prog := loadTestProgram(t, testdata_sequence)
+	root := findFunctions(prog, "m.main")[0].Object().(*types.Func)
+
+	var buf bytes.Buffer
+	if err := writeMermaidSequence(&buf, participant(root), sequenceMessages(prog, root, 3, false)); err != nil {
+		t.Fatal(err)
+	}
+	assertLines(t, strings.Split(strings.TrimSpace(buf.String()), "\n"), []string{
+		"sequenceDiagram",
+		"    participant P0 as main",
+		"    participant P1 as store",
+		"    participant P2 as main.Handler",
+		"    participant P3 as store.Store",
+		"    P0->>P1: Open()",
+		"    P0->>P2: Serve()",
+		"    P2->>P2: key()",
+		"    P2->>P3: Get()",
+		"    P3->>P3: lookup()",
+	})
+
+	buf.Reset()
+	if err := writePlantUMLSequence(&buf, participant(root), sequenceMessages(prog, root, 2, true)); err != nil {
+		t.Fatal(err)
+	}
+	assertLines(t, strings.Split(strings.TrimSpace(buf.String()), "\n"), []string{
+		"@startuml",
+		`participant "main" as P0`,
+		`participant "store" as P1`,
+		`participant "main.Handler" as P2`,
+		`participant "store.Store" as P3`,
+		`participant "fmt" as P4`,
+		"P0 -> P1 : Open()",
+		"P0 -> P2 : Serve()",
+		"P2 -> P2 : key()",
+		"P2 -> P3 : Get()",
+		"P2 -> P4 : Sprint()",
+		"@enduml",
+	})
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Analysis は 1 つの Program に対する問い合わせのセッション。メソッドは複数の goroutine から同時に呼んでよい。
+// 指摘の解析の結果は名前ごとにキャッシュし、同じ解析が同時に求められたときは 1 度だけ実行する。
+// 返すスライスは呼び出しごとのコピーなので、呼び出し側で変更してよい
+type Analysis struct {
+	Program *Program
+
+	mu      sync.Mutex
+	results map[string]*analysisResult
+}
+
+// analysisResult は 1 つの解析の結果。once で 1 度だけ計算する
+type analysisResult struct {
+	once  sync.Once
+	diags []Diagnostic
+}
+
+// NewAnalysis は prog に対するセッションを作る
+func NewAnalysis(prog *Program) *Analysis {
+	return &Analysis{Program: prog, results: make(map[string]*analysisResult)}
+}
+
+// Checks はセッションで実行できる解析の名前を返す
+func (a *Analysis) Checks() []string {
+	var names []string
+	for name := range exportChecks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Diagnostics は名前が name の解析の指摘を返す
+func (a *Analysis) Diagnostics(name string) ([]Diagnostic, error) {
+	check, ok := exportChecks[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown check %q", name)
+	}
+	a.mu.Lock()
+	r, ok := a.results[name]
+	if !ok {
+		r = &analysisResult{}
+		a.results[name] = r
+	}
+	a.mu.Unlock()
+	r.once.Do(func() {
+		r.diags = check(a.Program)
+		sortDiagnostics(r.diags)
+	})
+	return append([]Diagnostic(nil), r.diags...), nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// go test -race で実行すると、セッションを複数の goroutine から使ったときのデータ競合を検出する
+func TestAnalysisConcurrent(t *testing.T) {
+	files := map[string]string{"main.go": `package main
+
+import (
+	"context"
+	"strings"
+)
+
+// Deprecated: use trim.
+func Old(s string) string { return strings.TrimSpace(s) }
+
+func Exported() {}
+
+func unused() {}
+
+func run(s string, ctx context.Context) string { return Old(s) }
+
+func main() { run(" x ", context.Background()) }
+`}
+	want := make(map[string][]Diagnostic)
+	seq := NewAnalysis(loadTestProgram(t, files))
+	for _, name := range seq.Checks() {
+		diags, err := seq.Diagnostics(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want[name] = withBaseNames(diags)
+	}
+
+	a := NewAnalysis(loadTestProgram(t, files))
+	var wg sync.WaitGroup
+	errs := make(chan string, 100)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			checks := a.Checks()
+			for j := range checks {
+				name := checks[(i+j)%len(checks)]
+				got, err := a.Diagnostics(name)
+				if err != nil {
+					errs <- err.Error()
+					continue
+				}
+				if !reflect.DeepEqual(withBaseNames(got), want[name]) {
+					errs <- name + ": results differ from a sequential run"
+				}
+				if len(got) > 0 {
+					got[0] = Diagnostic{} // 返されたスライスを変更してもキャッシュは変わらない
+				}
+			}
+			fns := findFunctions(a.Program, "example.com/m.run")
+			if len(fns) == 0 || len(a.Program.DefUse(fns[0], ssaValueNamed(fns[0], "s"), 2)) == 0 {
+				errs <- "no uses of s in run"
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if _, err := a.Diagnostics("nosuchcheck"); err == nil {
+		t.Error("unknown check: want an error")
+	}
+}
+
+// withBaseNames はファイル名をディレクトリを除いた名前にした指摘を返す (別々に読み込んだ Program の結果を比べるため)
+func withBaseNames(diags []Diagnostic) []Diagnostic {
+	var result []Diagnostic
+	for _, d := range diags {
+		d.Pos.Filename = filepath.Base(d.Pos.Filename)
+		result = append(result, d)
+	}
+	return result
+}
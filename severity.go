@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+)
+
+// 指摘の重大度
+const (
+	severityInfo    = "info"    // 改善の提案 (ドキュメント、未使用のコードなど)
+	severityWarning = "warning" // 不具合につながりうるが、意図したものかもしれない
+	severityError   = "error"   // ほぼ確実に不具合か、ビルドできない
+)
+
+var severityRank = map[string]int{severityInfo: 1, severityWarning: 2, severityError: 3}
+
+// categorySeverity は指摘のカテゴリーごとの既定の重大度。Diagnostic.Severity が空ならこれを使う
+var categorySeverity = map[string]string{
+	"archrules":    severityError,
+	"complexity":   severityWarning,
+	"concat":       severityInfo,
+	"context":      severityWarning,
+	"deadbranch":   severityWarning,
+	"deadcode":     severityInfo,
+	"deprecated":   severityWarning,
+	"doc":          severityInfo,
+	"goleak":       severityWarning,
+	"imports":      severityInfo,
+	"load":         severityError,
+	"makecap":      severityInfo,
+	"migrate":      severityInfo,
+	"nilness":      severityError,
+	"params":       severityInfo,
+	"parse":        severityError,
+	"printf":       severityError,
+	"providers":    severityWarning,
+	"race":         severityWarning,
+	"select":       severityWarning,
+	"subtest":      severityWarning,
+	"testhelper":   severityInfo,
+	"testparallel": severityInfo,
+	"typeassert":   severityWarning,
+	"typecheck":    severityError,
+	"wiring":       severityWarning,
+}
+
+// failOn は main の -fail-on。空でなければ、この重大度以上の指摘があるときに指摘のコマンドを失敗させる
+var failOn string
+
+// parseSeverity は s が重大度の名前か none であることを確かめる。none は空を返す
+func parseSeverity(s string) (string, error) {
+	if s == "none" || s == "" {
+		return "", nil
+	}
+	if _, ok := severityRank[s]; !ok {
+		return "", fmt.Errorf("unknown severity %q (want info, warning, error or none)", s)
+	}
+	return s, nil
+}
+
+// severityOf は d の重大度を返す
+func severityOf(d Diagnostic) string {
+	if d.Severity != "" {
+		return d.Severity
+	}
+	if s, ok := categorySeverity[d.Category]; ok {
+		return s
+	}
+	return severityWarning
+}
+
+// setSeverities は重大度が空の指摘にカテゴリーの既定の重大度を入れる
+func setSeverities(diags []Diagnostic) {
+	for i := range diags {
+		diags[i].Severity = severityOf(diags[i])
+	}
+}
+
+// gateDiagnostics は -fail-on の重大度以上の指摘があればエラーを返す
+func gateDiagnostics(diags []Diagnostic) error {
+	if failOn == "" {
+		return nil
+	}
+	n := 0
+	for _, d := range diags {
+		if severityRank[severityOf(d)] >= severityRank[failOn] {
+			n++
+		}
+	}
+	if n > 0 {
+		return fmt.Errorf("%s at or above -fail-on=%s", plural(n, "finding"), failOn)
+	}
+	return nil
+}
+
+// complexityDiagnostics は循環的複雑度が limit を超える関数を指摘する。上限は利用者が指定したものなので重大度は error にする
+func complexityDiagnostics(prog *Program, limit int) []Diagnostic {
+	var diags []Diagnostic
+	for _, pkg := range prog.Packages {
+		for _, file := range pkg.Syntax {
+			for _, decl := range file.Decls {
+				fd, ok := decl.(*ast.FuncDecl)
+				if !ok || !prog.inFocus(prog.Fset.Position(fd.Pos())) {
+					continue
+				}
+				if c := cyclomaticComplexity(fd); c > limit {
+					d := newDiagnostic(prog.Fset, fd.Name.Pos(), "complexity",
+						"%s has cyclomatic complexity %d (max %d)", funcDeclName(fd), c, limit)
+					d.Severity = severityError
+					diags = append(diags, d)
+				}
+			}
+		}
+	}
+	sortDiagnostics(diags)
+	return diags
+}
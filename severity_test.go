@@ -0,0 +1,67 @@
+package main
+
+import "testing"
+
+func TestGateDiagnostics(t *testing.T) {
+	diags := []Diagnostic{
+		{Category: "doc", Message: "missing doc"},
+		{Category: "typeassert", Message: "unchecked"},
+		{Category: "typeassert", Severity: severityError, Message: "impossible"},
+	}
+	defer func(old string) { failOn = old }(failOn)
+	for _, tt := range []struct {
+		failOn string
+		want   string
+	}{
+		{"none", ""},
+		{"info", "3 findings at or above -fail-on=info"},
+		{"warning", "2 findings at or above -fail-on=warning"},
+		{"error", "1 finding at or above -fail-on=error"},
+	} {
+		var err error
+		if failOn, err = parseSeverity(tt.failOn); err != nil {
+			t.Fatal(err)
+		}
+		got := ""
+		if err := gateDiagnostics(diags); err != nil {
+			got = err.Error()
+		}
+		if got != tt.want {
+			t.Errorf("-fail-on=%s: got %q, want %q", tt.failOn, got, tt.want)
+		}
+	}
+	if _, err := parseSeverity("fatal"); err == nil {
+		t.Error("parseSeverity(fatal): want an error")
+	}
+}
+
+func TestComplexityDiagnostics(t *testing.T) {
+	prog := loadTestProgram(t, map[string]string{"main.go": `package main
+
+func simple() {}
+
+func branchy(a, b bool, n int) int {
+	if a && b {
+		return 1
+	}
+	for i := 0; i < n; i++ {
+		switch {
+		case i > 2:
+			return i
+		case i > 1 || a:
+			return 0
+		}
+	}
+	return -1
+}
+
+func main() { branchy(true, false, 3) }
+`})
+	diags := complexityDiagnostics(prog, 4)
+	assertLines(t, diagnosticMessages(diags), []string{
+		"main.go:5: branchy has cyclomatic complexity 7 (max 4)",
+	})
+	if got := severityOf(diags[0]); got != severityError {
+		t.Errorf("severity: got %s, want error", got)
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// ゼロ値の変数の宣言の書き方
+const (
+	declVar   = "var"   // var x T
+	declShort = "short" // x := T{}
+)
+
+func runSimplify(args []string) error {
+	fs := flag.NewFlagSet("simplify", flag.ExitOnError)
+	decl := fs.String("decl", "", "also rewrite zero value declarations of struct variables to `style` var (var x T) or short (x := T{})")
+	write := fs.Bool("w", false, "write the changed files instead of printing them")
+	fs.Parse(args)
+	if *decl != "" && *decl != declVar && *decl != declShort {
+		return fmt.Errorf("-decl must be %s or %s", declVar, declShort)
+	}
+	prog, err := loadProgramWith(loadOptions{Tests: true}, ".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	files, err := simplifyZeroValues(prog, *decl)
+	if err != nil {
+		return err
+	}
+	return writeGenerated(files, *write)
+}
+
+// simplifyZeroValues はゼロ値に関する冗長な書き方を整理し、変更したファイルの内容を返す
+//   - キー付きの構造体のリテラルから、ゼロ値のリテラル (0、""、false、nil、空の構造体や配列のリテラル) を値にした要素を除く
+//   - x == nil || len(x) == 0 を len(x) == 0 に、x != nil && len(x) > 0 (!= 0) を len(x) > 0 (!= 0) にする
+//   - decl が空でなければ、構造体のゼロ値の変数の宣言 (var x T、var x = T{}、x := T{}) を decl の書き方にそろえる
+func simplifyZeroValues(prog *Program, decl string) (map[string][]byte, error) {
+	return rewriteFiles(prog, func(pkg *packages.Package, file *ast.File) bool {
+		info := pkg.TypesInfo
+		changed := false
+		astutil.Apply(file, nil, func(c *astutil.Cursor) bool {
+			switch n := c.Node().(type) {
+			case *ast.CompositeLit:
+				if literalStruct(info, n) != nil && isKeyedLiteral(n) {
+					elts := n.Elts[:0:0]
+					for _, elt := range n.Elts {
+						if !isZeroField(info, elt.(*ast.KeyValueExpr)) {
+							elts = append(elts, elt)
+						}
+					}
+					if len(elts) < len(n.Elts) {
+						n.Elts = elts
+						changed = true
+					}
+				}
+			case *ast.BinaryExpr:
+				if e := collapseNilLen(info, n); e != nil {
+					c.Replace(e)
+					changed = true
+				}
+			case *ast.AssignStmt:
+				// x := T{} は文の並びの中にあるものだけを var にする (if などの初期化文には書けない)
+				if decl != declVar || c.Index() < 0 || n.Tok != token.DEFINE || len(n.Lhs) != 1 || len(n.Rhs) != 1 {
+					return true
+				}
+				if lit := zeroStructLit(info, n.Rhs[0]); lit != nil {
+					c.Replace(&ast.DeclStmt{Decl: &ast.GenDecl{TokPos: n.Pos(), Tok: token.VAR, Specs: []ast.Spec{
+						&ast.ValueSpec{Names: []*ast.Ident{n.Lhs[0].(*ast.Ident)}, Type: lit.Type},
+					}}})
+					changed = true
+				}
+			case *ast.GenDecl:
+				// var x = T{} を var x T にする (パッケージのレベルの宣言も含む)
+				if decl != declVar {
+					return true
+				}
+				if spec, lit := zeroVarSpec(info, n); spec != nil && len(spec.Values) == 1 {
+					spec.Type, spec.Values = lit.Type, nil
+					changed = true
+				}
+			case *ast.DeclStmt:
+				// := は関数の中の宣言にしか使えないので、関数の本体の var の宣言だけを書き換える
+				if decl != declShort {
+					return true
+				}
+				if spec, lit := zeroVarSpec(info, n.Decl.(*ast.GenDecl)); spec != nil {
+					c.Replace(&ast.AssignStmt{Lhs: []ast.Expr{spec.Names[0]}, TokPos: n.Pos(), Tok: token.DEFINE, Rhs: []ast.Expr{lit}})
+					changed = true
+				}
+			}
+			return true
+		})
+		return changed
+	})
+}
+
+// zeroVarSpec は gen が 1 つの変数を構造体のゼロ値で宣言するもの (var x T か var x = T{}) なら、その宣言と
+// ゼロ値のリテラル (var x T では T{} を作る) を返す。まとめた宣言やドキュメントのある宣言は対象にしない
+func zeroVarSpec(info *types.Info, gen *ast.GenDecl) (*ast.ValueSpec, *ast.CompositeLit) {
+	if gen.Tok != token.VAR || gen.Lparen.IsValid() || gen.Doc != nil {
+		return nil, nil
+	}
+	spec := gen.Specs[0].(*ast.ValueSpec)
+	if len(spec.Names) != 1 {
+		return nil, nil
+	}
+	switch {
+	case len(spec.Values) == 1 && spec.Type == nil:
+		if lit := zeroStructLit(info, spec.Values[0]); lit != nil {
+			return spec, lit
+		}
+	case len(spec.Values) == 0 && isStructType(info.TypeOf(spec.Type)):
+		return spec, &ast.CompositeLit{Type: spec.Type}
+	}
+	return nil, nil
+}
+
+// isZeroField はキー付きの構造体のリテラルの要素の値が、フィールドの型のゼロ値を表すリテラルかどうかを返す。
+// インターフェースのフィールドでは nil だけをゼロ値とする (0 などは値の入ったインターフェースになる)
+func isZeroField(info *types.Info, kv *ast.KeyValueExpr) bool {
+	key, ok := kv.Key.(*ast.Ident)
+	if !ok {
+		return false
+	}
+	field, ok := info.Uses[key].(*types.Var)
+	if !ok {
+		return false
+	}
+	value := ast.Unparen(kv.Value)
+	if id, ok := value.(*ast.Ident); ok {
+		if _, isNil := info.Uses[id].(*types.Nil); isNil {
+			return true
+		}
+	}
+	if types.IsInterface(field.Type()) {
+		return false
+	}
+	switch v := value.(type) {
+	case *ast.BasicLit:
+		tv := info.Types[v]
+		if tv.Value == nil || v.Kind == token.CHAR {
+			return false
+		}
+		if tv.Value.Kind() == constant.String {
+			return constant.StringVal(tv.Value) == ""
+		}
+		return constant.Sign(tv.Value) == 0
+	case *ast.Ident:
+		return info.Uses[v] == types.Universe.Lookup("false")
+	case *ast.CompositeLit:
+		return zeroStructLit(info, v) != nil
+	}
+	return false
+}
+
+// zeroStructLit は e が要素のない構造体か配列の複合リテラル (T{}) ならそれを返す
+func zeroStructLit(info *types.Info, e ast.Expr) *ast.CompositeLit {
+	lit, ok := ast.Unparen(e).(*ast.CompositeLit)
+	if !ok || len(lit.Elts) > 0 || lit.Type == nil {
+		return nil
+	}
+	if !isStructType(info.TypeOf(lit)) {
+		if _, isArray := info.TypeOf(lit).Underlying().(*types.Array); !isArray {
+			return nil
+		}
+		// [...]T{} の長さは要素から決まるので var x [...]T にはできない
+		if at, ok := lit.Type.(*ast.ArrayType); ok {
+			if _, ok := at.Len.(*ast.Ellipsis); ok {
+				return nil
+			}
+		}
+	}
+	return lit
+}
+
+func isStructType(t types.Type) bool {
+	if t == nil {
+		return false
+	}
+	_, ok := t.Underlying().(*types.Struct)
+	return ok
+}
+
+// isVarOrField は e が識別子か、識別子のフィールドの選択 (x.f.g) で、評価に副作用がないかどうかを返す
+func isVarOrField(e ast.Expr) bool {
+	switch e := ast.Unparen(e).(type) {
+	case *ast.Ident:
+		return true
+	case *ast.SelectorExpr:
+		return isVarOrField(e.X)
+	}
+	return false
+}
+
+// collapseNilLen は e が x == nil || len(x) == 0 か x != nil && len(x) > 0 (!= 0) の形なら len の比較を返す。
+// nil のスライス、マップ、チャネルの len は 0 なので nil との比較はいらない
+func collapseNilLen(info *types.Info, e *ast.BinaryExpr) ast.Expr {
+	nilOp, lenOps := token.EQL, []token.Token{token.EQL}
+	switch e.Op {
+	case token.LOR:
+	case token.LAND:
+		nilOp, lenOps = token.NEQ, []token.Token{token.GTR, token.NEQ}
+	default:
+		return nil
+	}
+	cmp, ok1 := ast.Unparen(e.X).(*ast.BinaryExpr)
+	check, ok2 := ast.Unparen(e.Y).(*ast.BinaryExpr)
+	if !ok1 || !ok2 || cmp.Op != nilOp {
+		return nil
+	}
+	nilID, ok := ast.Unparen(cmp.Y).(*ast.Ident)
+	if !ok {
+		return nil
+	}
+	if _, isNil := info.Uses[nilID].(*types.Nil); !isNil {
+		return nil
+	}
+	switch info.TypeOf(cmp.X).Underlying().(type) {
+	case *types.Slice, *types.Map, *types.Chan:
+	default:
+		return nil
+	}
+	// f() == nil || len(f()) == 0 をまとめると f の呼び出しが 1 回減るので、変数とフィールドだけを扱う
+	if !isVarOrField(cmp.X) {
+		return nil
+	}
+	call, ok := ast.Unparen(check.X).(*ast.CallExpr)
+	if !ok || len(call.Args) != 1 || !isBuiltin(info, call.Fun, "len") || types.ExprString(call.Args[0]) != types.ExprString(cmp.X) {
+		return nil
+	}
+	if tv := info.Types[check.Y]; tv.Value == nil || constant.Sign(tv.Value) != 0 {
+		return nil
+	}
+	for _, op := range lenOps {
+		if check.Op == op {
+			return e.Y
+		}
+	}
+	return nil
+}
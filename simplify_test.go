@@ -0,0 +1,218 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSimplifyZeroValues(t *testing.T) {
+	src := `package main
+
+import "fmt"
+
+type Point struct {
+	X, Y int
+}
+
+type Config struct {
+	Name    string
+	Retries int
+	Verbose bool
+	Origin  Point
+	Tags    []string
+	Extra   any
+	Limit   float64
+}
+
+var global = Config{}
+
+func main() {
+	c := Config{Name: "", Retries: 0, Verbose: false, Origin: Point{}, Tags: nil, Extra: 0, Limit: 1.5}
+	p := Point{}
+	var q = Point{X: 0, Y: 2}
+	var r Point
+	if s := (Point{}); s == p {
+		fmt.Println(s)
+	}
+	tags := c.Tags
+	if tags == nil || len(tags) == 0 {
+		fmt.Println("empty")
+	}
+	if c.Tags != nil && len(c.Tags) > 0 {
+		fmt.Println(c.Tags)
+	}
+	var ptr *[2]int
+	if ptr == nil || len(ptr) == 0 {
+		fmt.Println(ptr)
+	}
+	arr := [...]int{}
+	if list() == nil || len(list()) == 0 {
+		fmt.Println(arr)
+	}
+	fmt.Println(global, c, p, q, r, []int{0}, map[string]int{"a": 0})
+}
+
+func list() []int { return nil }
+`
+	for _, tt := range []struct {
+		decl, want string
+	}{
+		{"", `package main
+
+import "fmt"
+
+type Point struct {
+	X, Y int
+}
+
+type Config struct {
+	Name    string
+	Retries int
+	Verbose bool
+	Origin  Point
+	Tags    []string
+	Extra   any
+	Limit   float64
+}
+
+var global = Config{}
+
+func main() {
+	c := Config{Extra: 0, Limit: 1.5}
+	p := Point{}
+	var q = Point{Y: 2}
+	var r Point
+	if s := (Point{}); s == p {
+		fmt.Println(s)
+	}
+	tags := c.Tags
+	if len(tags) == 0 {
+		fmt.Println("empty")
+	}
+	if len(c.Tags) > 0 {
+		fmt.Println(c.Tags)
+	}
+	var ptr *[2]int
+	if ptr == nil || len(ptr) == 0 {
+		fmt.Println(ptr)
+	}
+	arr := [...]int{}
+	if list() == nil || len(list()) == 0 {
+		fmt.Println(arr)
+	}
+	fmt.Println(global, c, p, q, r, []int{0}, map[string]int{"a": 0})
+}
+
+func list() []int { return nil }
+`},
+		{declVar, `package main
+
+import "fmt"
+
+type Point struct {
+	X, Y int
+}
+
+type Config struct {
+	Name    string
+	Retries int
+	Verbose bool
+	Origin  Point
+	Tags    []string
+	Extra   any
+	Limit   float64
+}
+
+var global Config
+
+func main() {
+	c := Config{Extra: 0, Limit: 1.5}
+	var p Point
+	var q = Point{Y: 2}
+	var r Point
+	if s := (Point{}); s == p {
+		fmt.Println(s)
+	}
+	tags := c.Tags
+	if len(tags) == 0 {
+		fmt.Println("empty")
+	}
+	if len(c.Tags) > 0 {
+		fmt.Println(c.Tags)
+	}
+	var ptr *[2]int
+	if ptr == nil || len(ptr) == 0 {
+		fmt.Println(ptr)
+	}
+	arr := [...]int{}
+	if list() == nil || len(list()) == 0 {
+		fmt.Println(arr)
+	}
+	fmt.Println(global, c, p, q, r, []int{0}, map[string]int{"a": 0})
+}
+
+func list() []int { return nil }
+`},
+		{declShort, `package main
+
+import "fmt"
+
+type Point struct {
+	X, Y int
+}
+
+type Config struct {
+	Name    string
+	Retries int
+	Verbose bool
+	Origin  Point
+	Tags    []string
+	Extra   any
+	Limit   float64
+}
+
+var global = Config{}
+
+func main() {
+	c := Config{Extra: 0, Limit: 1.5}
+	p := Point{}
+	var q = Point{Y: 2}
+	r := Point{}
+	if s := (Point{}); s == p {
+		fmt.Println(s)
+	}
+	tags := c.Tags
+	if len(tags) == 0 {
+		fmt.Println("empty")
+	}
+	if len(c.Tags) > 0 {
+		fmt.Println(c.Tags)
+	}
+	var ptr *[2]int
+	if ptr == nil || len(ptr) == 0 {
+		fmt.Println(ptr)
+	}
+	arr := [...]int{}
+	if list() == nil || len(list()) == 0 {
+		fmt.Println(arr)
+	}
+	fmt.Println(global, c, p, q, r, []int{0}, map[string]int{"a": 0})
+}
+
+func list() []int { return nil }
+`},
+	} {
+		dir := writeModule(t, map[string]string{"main.go": src})
+		prog, err := loadProgramWith(loadOptions{Tests: true}, dir, "./...")
+		if err != nil {
+			t.Fatal(err)
+		}
+		files, err := simplifyZeroValues(prog, tt.decl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(files[filepath.Join(dir, "main.go")]); got != tt.want {
+			t.Errorf("decl=%q: got:\n%s\nwant:\n%s", tt.decl, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,535 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/cfg"
+	"golang.org/x/tools/go/packages"
+)
+
+// ProgramSlice は変数のある位置での後方スライス (その値に影響する文) と前方スライス (その値が影響する文) の行
+type ProgramSlice struct {
+	Func     string         `json:"func"`
+	Var      string         `json:"var"`
+	Pos      token.Position `json:"pos"`
+	Backward []int          `json:"backward"`
+	Forward  []int          `json:"forward"`
+
+	lines []string // start 行からの関数のソース
+	start int
+}
+
+func runSlice(args []string) error {
+	fs := flag.NewFlagSet("slice", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output as JSON")
+	at := fs.String("pos", "", "variable `position` as file:line:column")
+	fs.Parse(args)
+	if *at == "" {
+		return errors.New("-pos is required")
+	}
+	prog, err := loadProgram(".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	pkg, file, pos, err := findSourcePos(prog, *at)
+	if err != nil {
+		return err
+	}
+	s, err := programSlice(prog, pkg, file, pos)
+	if err != nil {
+		return err
+	}
+	relativizePositions(s)
+	if *asJSON {
+		return writeJSON(os.Stdout, s)
+	}
+	return writeProgramSlice(os.Stdout, s)
+}
+
+// findSourcePos は file:line:column の位置を含む解析対象のパッケージとファイルを探す
+func findSourcePos(prog *Program, at string) (*packages.Package, *ast.File, token.Pos, error) {
+	parts := strings.Split(at, ":")
+	if len(parts) < 3 {
+		return nil, nil, token.NoPos, fmt.Errorf("invalid position %q (want file:line:column)", at)
+	}
+	n := len(parts)
+	line, err1 := strconv.Atoi(parts[n-2])
+	col, err2 := strconv.Atoi(parts[n-1])
+	if err1 != nil || err2 != nil {
+		return nil, nil, token.NoPos, fmt.Errorf("invalid position %q (want file:line:column)", at)
+	}
+	filename, err := filepath.Abs(strings.Join(parts[:n-2], ":"))
+	if err != nil {
+		return nil, nil, token.NoPos, err
+	}
+	for _, pkg := range prog.Packages {
+		for _, file := range pkg.Syntax {
+			tf := prog.Fset.File(file.Pos())
+			if tf.Name() != filename {
+				continue
+			}
+			if line < 1 || line > tf.LineCount() {
+				return nil, nil, token.NoPos, fmt.Errorf("%s: line %d out of range", at, line)
+			}
+			return pkg, file, tf.LineStart(line) + token.Pos(col-1), nil
+		}
+	}
+	return nil, nil, token.NoPos, notFound("%s: file not found in the analyzed packages", at)
+}
+
+// sliceNode は制御フローグラフの 1 つのノード (文か条件の式) と、そこで定義・使用されるローカル変数
+type sliceNode struct {
+	node ast.Node
+	defs []*types.Var
+	uses []*types.Var
+	kill bool // defs を上書きする (フィールドや要素への代入でない)
+}
+
+// programSlice は pos の変数について関数内の後方スライスと前方スライスを求める。
+// 到達定義によるデータ依存と、if, for, range, switch の本体にあることによる制御依存をたどる
+func programSlice(prog *Program, pkg *packages.Package, file *ast.File, pos token.Pos) (*ProgramSlice, error) {
+	path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+	var ident *ast.Ident
+	var body *ast.BlockStmt
+	var funcNode ast.Node
+	for _, n := range path {
+		switch n := n.(type) {
+		case *ast.Ident:
+			if ident == nil {
+				ident = n
+			}
+		case *ast.FuncLit:
+			if body == nil {
+				body, funcNode = n.Body, n
+			}
+		case *ast.FuncDecl:
+			if body == nil {
+				body, funcNode = n.Body, n
+			}
+		}
+	}
+	if ident == nil || body == nil {
+		return nil, notFoundAt(prog.Fset.Position(pos), "no variable in a function body at this position")
+	}
+	info := pkg.TypesInfo
+	v, ok := info.ObjectOf(ident).(*types.Var)
+	if !ok || v.IsField() {
+		return nil, fmt.Errorf("%s: %s is not a local variable", prog.Fset.Position(pos), ident.Name)
+	}
+
+	g := cfg.New(body, func(*ast.CallExpr) bool { return true })
+	var nodes []*sliceNode
+	index := make(map[ast.Node]int)
+	blockNodes := make(map[*cfg.Block][]int)
+	for _, b := range g.Blocks {
+		for _, n := range b.Nodes {
+			index[n] = len(nodes)
+			blockNodes[b] = append(blockNodes[b], len(nodes))
+			nodes = append(nodes, defsAndUses(info, n, body))
+		}
+	}
+	// 到達定義。定義は定義したノードの番号で、-1 は引数など関数に入る前の定義
+	type def struct {
+		node int
+		v    *types.Var
+	}
+	preds := make(map[*cfg.Block][]*cfg.Block)
+	for _, b := range g.Blocks {
+		for _, s := range b.Succs {
+			preds[s] = append(preds[s], b)
+		}
+	}
+	transfer := func(in map[def]bool, i int, visit func(in map[def]bool, i int)) map[def]bool {
+		if visit != nil {
+			visit(in, i)
+		}
+		n := nodes[i]
+		if len(n.defs) == 0 {
+			return in
+		}
+		out := make(map[def]bool, len(in))
+		for d := range in {
+			if !n.kill || !containsVar(n.defs, d.v) {
+				out[d] = true
+			}
+		}
+		for _, v := range n.defs {
+			out[def{i, v}] = true
+		}
+		return out
+	}
+	entry := make(map[def]bool)
+	for _, n := range nodes {
+		for _, u := range n.uses {
+			if !u.Pos().IsValid() || u.Pos() < body.Pos() || u.Pos() >= body.End() {
+				entry[def{-1, u}] = true
+			}
+		}
+	}
+	in := make(map[*cfg.Block]map[def]bool)
+	out := make(map[*cfg.Block]map[def]bool)
+	for changed := true; changed; {
+		changed = false
+		for bi, b := range g.Blocks {
+			cur := make(map[def]bool)
+			if bi == 0 {
+				for d := range entry {
+					cur[d] = true
+				}
+			}
+			for _, p := range preds[b] {
+				for d := range out[p] {
+					cur[d] = true
+				}
+			}
+			in[b] = cur
+			for _, i := range blockNodes[b] {
+				cur = transfer(cur, i, nil)
+			}
+			if len(cur) != len(out[b]) {
+				changed = true
+			}
+			out[b] = cur
+		}
+	}
+	// データ依存: ノードが使う変数の定義のうち、そのノードに到達するもの
+	deps := make([]map[int]bool, len(nodes))
+	reaching := make([]map[def]bool, len(nodes))
+	for _, b := range g.Blocks {
+		cur := in[b]
+		for _, i := range blockNodes[b] {
+			cur = transfer(cur, i, func(in map[def]bool, i int) {
+				reaching[i] = in
+				deps[i] = make(map[int]bool)
+				for d := range in {
+					if d.node >= 0 && containsVar(nodes[i].uses, d.v) {
+						deps[i][d.node] = true
+					}
+				}
+			})
+		}
+	}
+	control := controlDependences(body, index)
+
+	start, ok := index[enclosingNode(path, index)]
+	if !ok {
+		return nil, notFoundAt(prog.Fset.Position(pos), "position is not inside a statement")
+	}
+	backward := map[int]bool{start: true}
+	var queue []int
+	if containsVar(nodes[start].uses, v) && !containsVar(nodes[start].defs, v) {
+		// 変数の使用なら、その変数の定義と制御依存だけから始める
+		for d := range reaching[start] {
+			if d.v == v && d.node >= 0 && !backward[d.node] {
+				backward[d.node] = true
+				queue = append(queue, d.node)
+			}
+		}
+		for _, c := range control[start] {
+			if !backward[c] {
+				backward[c] = true
+				queue = append(queue, c)
+			}
+		}
+	} else {
+		queue = append(queue, start)
+	}
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		for d := range deps[i] {
+			if !backward[d] {
+				backward[d] = true
+				queue = append(queue, d)
+			}
+		}
+		for _, c := range control[i] {
+			if !backward[c] {
+				backward[c] = true
+				queue = append(queue, c)
+			}
+		}
+	}
+	forward := map[int]bool{start: true}
+	queue = []int{start}
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		for j := range nodes {
+			if forward[j] {
+				continue
+			}
+			if deps[j][i] || containsInt(control[j], i) {
+				forward[j] = true
+				queue = append(queue, j)
+			}
+		}
+	}
+
+	s := &ProgramSlice{Var: v.Name(), Pos: prog.Fset.Position(ident.Pos())}
+	if fd, ok := funcNode.(*ast.FuncDecl); ok {
+		s.Func = fd.Name.Name
+	} else {
+		s.Func = "func literal"
+	}
+	s.Backward = nodeLines(prog.Fset, nodes, backward)
+	s.Forward = nodeLines(prog.Fset, nodes, forward)
+	if src, err := prog.ReadFile(s.Pos.Filename); err == nil {
+		first, last := prog.Fset.Position(funcNode.Pos()).Line, prog.Fset.Position(funcNode.End()).Line
+		lines := strings.Split(string(src), "\n")
+		if last <= len(lines) {
+			s.lines, s.start = lines[first-1:last], first
+		}
+	}
+	return s, nil
+}
+
+// defsAndUses は cfg のノード n で定義・使用されるローカル変数 (body の関数の外で宣言されたものも含む) を返す。
+// 関数リテラルの中の代入は定義とみなさない
+func defsAndUses(info *types.Info, n ast.Node, body *ast.BlockStmt) *sliceNode {
+	s := &sliceNode{node: n, kill: true}
+	local := func(id *ast.Ident) *types.Var {
+		v, ok := info.ObjectOf(id).(*types.Var)
+		if !ok || v.IsField() || v.Parent() == nil || v.Parent() == v.Pkg().Scope() {
+			return nil
+		}
+		return v
+	}
+	targets := make(map[*ast.Ident]bool) // = と := の左辺の変数 (使用ではない)
+	addDef := func(lhs ast.Expr, pure bool) {
+		if id, ok := ast.Unparen(lhs).(*ast.Ident); ok {
+			if v := local(id); v != nil {
+				s.defs = append(s.defs, v)
+				if pure {
+					targets[id] = true
+				}
+			}
+			return
+		}
+		// x.f = ... や x[i] = ... は x を部分的に上書きする
+		for e := lhs; e != nil; {
+			switch x := ast.Unparen(e).(type) {
+			case *ast.SelectorExpr:
+				e = x.X
+			case *ast.IndexExpr:
+				e = x.X
+			case *ast.StarExpr:
+				e = x.X
+			case *ast.Ident:
+				if v := local(x); v != nil {
+					s.defs = append(s.defs, v)
+					s.kill = false
+				}
+				e = nil
+			default:
+				e = nil
+			}
+		}
+	}
+	switch n := n.(type) {
+	case *ast.AssignStmt:
+		for _, lhs := range n.Lhs {
+			addDef(lhs, n.Tok == token.ASSIGN || n.Tok == token.DEFINE)
+		}
+	case *ast.IncDecStmt:
+		addDef(n.X, false)
+	case *ast.ValueSpec:
+		for _, name := range n.Names {
+			addDef(name, true)
+		}
+	case *ast.Ident:
+		// range のキーと値
+		if _, ok := info.Defs[n]; ok {
+			addDef(n, true)
+		} else if parentRange(body, n) {
+			addDef(n, true)
+		}
+	}
+	ast.Inspect(n, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && !targets[id] {
+			if v := local(id); v != nil && info.Uses[id] == v && !containsVar(s.uses, v) {
+				s.uses = append(s.uses, v)
+			}
+		}
+		return true
+	})
+	return s
+}
+
+// parentRange は id が body の中の range 文のキーか値かどうかを返す
+func parentRange(body *ast.BlockStmt, id *ast.Ident) bool {
+	found := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		if r, ok := n.(*ast.RangeStmt); ok && (r.Key == id || r.Value == id) {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// controlDependences はノードごとに、それを本体に含む if, for, range, switch の条件のノードを返す
+func controlDependences(body *ast.BlockStmt, index map[ast.Node]int) map[int][]int {
+	control := make(map[int][]int)
+	with := func(ctrl []int, conds ...ast.Node) []int {
+		result := append([]int(nil), ctrl...)
+		for _, c := range conds {
+			if i, ok := index[c]; ok {
+				result = append(result, i)
+			}
+		}
+		return result
+	}
+	var visit func(n ast.Node, ctrl []int)
+	visit = func(n ast.Node, ctrl []int) {
+		if n == nil {
+			return
+		}
+		ast.Inspect(n, func(m ast.Node) bool {
+			if _, ok := m.(*ast.FuncLit); ok {
+				return false
+			}
+			if i, ok := index[m]; ok && len(ctrl) > 0 {
+				control[i] = ctrl
+			}
+			switch m := m.(type) {
+			case *ast.IfStmt:
+				visit(m.Init, ctrl)
+				visit(m.Cond, ctrl)
+				visit(m.Body, with(ctrl, m.Cond))
+				visit(m.Else, with(ctrl, m.Cond))
+				return false
+			case *ast.ForStmt:
+				visit(m.Init, ctrl)
+				visit(m.Cond, ctrl)
+				inner := with(ctrl, m.Cond)
+				visit(m.Post, inner)
+				visit(m.Body, inner)
+				return false
+			case *ast.RangeStmt:
+				visit(m.X, ctrl)
+				visit(m.Key, ctrl)
+				visit(m.Value, ctrl)
+				visit(m.Body, with(ctrl, m.X))
+				return false
+			case *ast.SwitchStmt:
+				visit(m.Init, ctrl)
+				visit(m.Tag, ctrl)
+				for _, clause := range m.Body.List {
+					cc := clause.(*ast.CaseClause)
+					conds := []ast.Node{m.Tag}
+					for _, e := range cc.List {
+						visit(e, with(ctrl, m.Tag))
+						conds = append(conds, e)
+					}
+					for _, stmt := range cc.Body {
+						visit(stmt, with(ctrl, conds...))
+					}
+				}
+				return false
+			case *ast.TypeSwitchStmt:
+				visit(m.Init, ctrl)
+				visit(m.Assign, ctrl)
+				for _, clause := range m.Body.List {
+					for _, stmt := range clause.(*ast.CaseClause).Body {
+						visit(stmt, with(ctrl, m.Assign))
+					}
+				}
+				return false
+			}
+			return true
+		})
+	}
+	visit(body, nil)
+	return control
+}
+
+// enclosingNode は path の中で最も内側の cfg のノードを返す
+func enclosingNode(path []ast.Node, index map[ast.Node]int) ast.Node {
+	for _, n := range path {
+		if _, ok := index[n]; ok {
+			return n
+		}
+	}
+	return nil
+}
+
+// nodeLines は選ばれたノードのある行を昇順で返す
+func nodeLines(fset *token.FileSet, nodes []*sliceNode, selected map[int]bool) []int {
+	seen := make(map[int]bool)
+	var lines []int
+	for i := range selected {
+		n := nodes[i].node
+		for l := fset.Position(n.Pos()).Line; l <= fset.Position(n.End()).Line; l++ {
+			if !seen[l] {
+				seen[l] = true
+				lines = append(lines, l)
+			}
+		}
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+func containsVar(vars []*types.Var, v *types.Var) bool {
+	for _, x := range vars {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(xs []int, x int) bool {
+	for _, y := range xs {
+		if y == x {
+			return true
+		}
+	}
+	return false
+}
+
+// writeProgramSlice は関数のソースを、行頭に印を付けて出力する。
+// @ は指定した位置、< は後方スライス、> は前方スライス、* は両方に含まれる行
+func writeProgramSlice(w io.Writer, s *ProgramSlice) error {
+	fmt.Fprintf(w, "%s: slice of %s in %s\n", s.Pos, s.Var, s.Func)
+	backward := make(map[int]bool)
+	for _, l := range s.Backward {
+		backward[l] = true
+	}
+	forward := make(map[int]bool)
+	for _, l := range s.Forward {
+		forward[l] = true
+	}
+	var buf bytes.Buffer
+	for i, text := range s.lines {
+		line := s.start + i
+		mark := " "
+		switch {
+		case line == s.Pos.Line:
+			mark = "@"
+		case backward[line] && forward[line]:
+			mark = "*"
+		case backward[line]:
+			mark = "<"
+		case forward[line]:
+			mark = ">"
+		}
+		fmt.Fprintf(&buf, "%s %4d  %s\n", mark, line, text)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
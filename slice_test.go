@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProgramSlice(t *testing.T) {
+	src := `package main
+
+import "fmt"
+
+func compute(a, b int, verbose bool) int {
+	sum := a + b
+	diff := a - b
+	if verbose {
+		fmt.Println(diff)
+	}
+	total := sum * 2
+	for i := 0; i < b; i++ {
+		total += i
+	}
+	if total > 10 {
+		fmt.Println("large")
+	}
+	return total
+}
+
+func main() { compute(1, 2, true) }
+`
+	dir := writeModule(t, map[string]string{"main.go": src})
+	prog, err := loadProgram(dir, "./...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, file, pos, err := findSourcePos(prog, filepath.Join(dir, "main.go")+":11:2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := programSlice(prog, pkg, file, pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := writeProgramSlice(&buf, s); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	if !strings.HasSuffix(lines[0], "main.go:11:2: slice of total in compute") {
+		t.Errorf("header = %q", lines[0])
+	}
+	assertLines(t, lines[1:], []string{
+		"     5  func compute(a, b int, verbose bool) int {",
+		"<    6  \tsum := a + b",
+		"     7  \tdiff := a - b",
+		"     8  \tif verbose {",
+		"     9  \t\tfmt.Println(diff)",
+		"    10  \t}",
+		"@   11  \ttotal := sum * 2",
+		"    12  \tfor i := 0; i < b; i++ {",
+		">   13  \t\ttotal += i",
+		"    14  \t}",
+		">   15  \tif total > 10 {",
+		">   16  \t\tfmt.Println(\"large\")",
+		"    17  \t}",
+		">   18  \treturn total",
+		"    19  }",
+	})
+
+	// 使用の位置からは、その値に影響するループも含めてたどる
+	_, _, pos, err = findSourcePos(prog, filepath.Join(dir, "main.go")+":18:9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, err = programSlice(prog, pkg, file, pos); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fmt.Sprint(s.Backward), "[6 11 12 13 18]"; got != want {
+		t.Errorf("backward = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/constant"
+	"go/token"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// SQLQuery は SQL を実行する呼び出しと、そこに渡されるクエリ文字列
+type SQLQuery struct {
+	Func    string         `json:"func"`    // 呼び出している関数
+	Callee  string         `json:"callee"`  // (*database/sql.DB).Query など
+	Query   string         `json:"query"`   // 定数でない部分は {式} にする
+	Dynamic bool           `json:"dynamic"` // 定数だけでは決まらない部分を含む
+	Pos     token.Position `json:"pos"`
+}
+
+// sqlQueryFuncs はクエリを受け取る関数と、クエリの引数の位置 (レシーバを除く)
+var sqlQueryFuncs = make(map[string]int)
+
+func init() {
+	for _, recv := range []string{"(*database/sql.DB)", "(*database/sql.Tx)", "(*database/sql.Conn)"} {
+		for _, name := range []string{"Query", "QueryRow", "Exec", "Prepare"} {
+			if recv != "(*database/sql.Conn)" {
+				sqlQueryFuncs[recv+"."+name] = 0
+			}
+			sqlQueryFuncs[recv+"."+name+"Context"] = 1
+		}
+	}
+	for _, recv := range []string{"(*github.com/jmoiron/sqlx.DB)", "(*github.com/jmoiron/sqlx.Tx)"} {
+		for _, name := range []string{"Queryx", "QueryRowx", "MustExec", "NamedExec", "NamedQuery", "Preparex", "PrepareNamed"} {
+			sqlQueryFuncs[recv+"."+name] = 0
+			sqlQueryFuncs[recv+"."+name+"Context"] = 1
+		}
+		for _, name := range []string{"Select", "Get"} {
+			sqlQueryFuncs[recv+"."+name] = 1
+			sqlQueryFuncs[recv+"."+name+"Context"] = 2
+		}
+	}
+	sqlQueryFuncs["(*gorm.io/gorm.DB).Raw"] = 0
+	sqlQueryFuncs["(*gorm.io/gorm.DB).Exec"] = 0
+}
+
+func runSQLQueries(args []string) error {
+	fs := flag.NewFlagSet("sqlqueries", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output as JSON")
+	dynamicOnly := fs.Bool("dynamic", false, "only list queries built from non-constant parts")
+	fs.Parse(args)
+	prog, err := loadProgram(".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	queries := sqlQueries(prog)
+	if *dynamicOnly {
+		var dynamic []SQLQuery
+		for _, q := range queries {
+			if q.Dynamic {
+				dynamic = append(dynamic, q)
+			}
+		}
+		queries = dynamic
+	}
+	relativizePositions(queries)
+	if *asJSON {
+		return writeJSON(os.Stdout, queries)
+	}
+	return writeSQLQueries(os.Stdout, queries)
+}
+
+// sqlQueries は database/sql, sqlx, gorm にクエリを渡している呼び出しを探し、
+// 定数と文字列の連結、fmt.Sprintf をたどってクエリ文字列を復元する
+func sqlQueries(prog *Program) []SQLQuery {
+	var queries []SQLQuery
+	for _, fn := range prog.targetFunctions() {
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				call, ok := instr.(ssa.CallInstruction)
+				if !ok {
+					continue
+				}
+				name := calleeName(call.Common())
+				idx, ok := sqlQueryFuncs[name]
+				if !ok {
+					continue
+				}
+				args := callArgs(call.Common())
+				if idx >= len(args) {
+					continue
+				}
+				for _, q := range queryStrings(args[idx], 0) {
+					queries = append(queries, SQLQuery{
+						Func:    fn.RelString(nil),
+						Callee:  name,
+						Query:   q.text,
+						Dynamic: q.dynamic,
+						Pos:     prog.Fset.Position(call.Pos()),
+					})
+				}
+			}
+		}
+	}
+	return queries
+}
+
+type queryString struct {
+	text    string
+	dynamic bool
+}
+
+// queryStrings は文字列の値 v が取りうる文字列を返す。定数でない部分は {式} で表す
+func queryStrings(v ssa.Value, depth int) []queryString {
+	if depth > 10 {
+		return []queryString{{"{…}", true}}
+	}
+	switch v := v.(type) {
+	case *ssa.Const:
+		if v.Value != nil && v.Value.Kind() == constant.String {
+			return []queryString{{constant.StringVal(v.Value), false}}
+		}
+	case *ssa.BinOp:
+		if v.Op == token.ADD {
+			var result []queryString
+			for _, x := range queryStrings(v.X, depth+1) {
+				for _, y := range queryStrings(v.Y, depth+1) {
+					result = append(result, queryString{x.text + y.text, x.dynamic || y.dynamic})
+				}
+			}
+			return result
+		}
+	case *ssa.Phi:
+		var result []queryString
+		seen := make(map[queryString]bool)
+		for _, e := range v.Edges {
+			for _, q := range queryStrings(e, depth+1) {
+				if !seen[q] {
+					seen[q] = true
+					result = append(result, q)
+				}
+			}
+		}
+		return result
+	case *ssa.Call:
+		// fmt.Sprintf は書式をそのまま残す
+		if calleeName(&v.Call) == "fmt.Sprintf" {
+			var result []queryString
+			for _, f := range queryStrings(v.Call.Args[0], depth+1) {
+				result = append(result, queryString{f.text, true})
+			}
+			return result
+		}
+	}
+	return []queryString{{"{" + exprName(v) + "}", true}}
+}
+
+// exprName は値をクエリの中で表示するための短い名前を返す
+func exprName(v ssa.Value) string {
+	switch v := v.(type) {
+	case *ssa.Parameter, *ssa.Global:
+		return v.Name()
+	case *ssa.UnOp:
+		if g, ok := v.X.(*ssa.Global); ok && v.Op == token.MUL {
+			return g.Name()
+		}
+	case *ssa.Call:
+		if fn := v.Call.StaticCallee(); fn != nil {
+			return fn.Name() + "()"
+		}
+	}
+	return "…"
+}
+
+func writeSQLQueries(w io.Writer, queries []SQLQuery) error {
+	for _, q := range queries {
+		dynamic := ""
+		if q.Dynamic {
+			dynamic = " (dynamic)"
+		}
+		fmt.Fprintf(w, "%s: %s: %s%s\n", q.Pos, q.Func, strings.Join(strings.Fields(q.Query), " "), dynamic)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var testdata_sqlqueries = `package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+const usersTable = "users"
+
+func findUser(db *sql.DB, id int) {
+	db.QueryRow("SELECT name FROM "+usersTable+" WHERE id = ?", id)
+}
+
+func listUsers(ctx context.Context, db *sql.DB, desc bool) {
+	q := "SELECT id, name FROM users"
+	if desc {
+		q += " ORDER BY id DESC"
+	}
+	db.QueryContext(ctx, q)
+}
+
+func deleteFrom(tx *sql.Tx, table string) {
+	tx.Exec("DELETE FROM " + table)
+	tx.Exec(fmt.Sprintf("TRUNCATE %s", table))
+}
+
+func main() {}
+`
+
+func TestSQLQueries(t *testing.T) {
+	prog := loadTestProgram(t, map[string]string{"main.go": testdata_sqlqueries})
+	var buf bytes.Buffer
+	if err := writeSQLQueries(&buf, sqlQueries(prog)); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		got = append(got, line[strings.Index(line, "main.go"):])
+	}
+	assertLines(t, got, []string{
+		"main.go:12:13: example.com/m.findUser: SELECT name FROM users WHERE id = ?",
+		"main.go:20:17: example.com/m.listUsers: SELECT id, name FROM users",
+		"main.go:20:17: example.com/m.listUsers: SELECT id, name FROM users ORDER BY id DESC",
+		"main.go:24:9: example.com/m.deleteFrom: DELETE FROM {table} (dynamic)",
+		"main.go:25:9: example.com/m.deleteFrom: TRUNCATE %s (dynamic)",
+	})
+}
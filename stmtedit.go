@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// StmtEditor は文の置き換え、前後への挿入、削除を登録しておき、Apply でまとめて適用する。
+// 文はブロック、case 節、select の節、無名関数の本体など、どの深さにあってもよい
+type StmtEditor struct {
+	edits map[ast.Stmt]*stmtEdit
+	order []ast.Stmt // 登録した順 (エラーの報告用)
+}
+
+// stmtEdit は 1 つの文に対する編集
+type stmtEdit struct {
+	before, after []ast.Stmt
+	replace       []ast.Stmt
+	replaced      bool // replace が空なら削除
+	applied       bool
+}
+
+// NewStmtEditor は空の StmtEditor を返す
+func NewStmtEditor() *StmtEditor {
+	return &StmtEditor{edits: make(map[ast.Stmt]*stmtEdit)}
+}
+
+func (e *StmtEditor) edit(stmt ast.Stmt) *stmtEdit {
+	ed, ok := e.edits[stmt]
+	if !ok {
+		ed = &stmtEdit{}
+		e.edits[stmt] = ed
+		e.order = append(e.order, stmt)
+	}
+	return ed
+}
+
+// Replace は old を new の文の並びに置き換える
+func (e *StmtEditor) Replace(old ast.Stmt, new ...ast.Stmt) {
+	ed := e.edit(old)
+	ed.replace, ed.replaced = new, true
+}
+
+// Delete は stmt を削除する
+func (e *StmtEditor) Delete(stmt ast.Stmt) {
+	e.Replace(stmt)
+}
+
+// InsertBefore は stmt の前に new を挿入する
+func (e *StmtEditor) InsertBefore(stmt ast.Stmt, new ...ast.Stmt) {
+	ed := e.edit(stmt)
+	ed.before = append(ed.before, new...)
+}
+
+// InsertAfter は stmt の後に new を挿入する
+func (e *StmtEditor) InsertAfter(stmt ast.Stmt, new ...ast.Stmt) {
+	ed := e.edit(stmt)
+	ed.after = append(ed.after, new...)
+}
+
+// Apply は root の中の文に登録した編集を適用する。置き換えた文や削除した文の中はたどらない。
+// 文の並びの外にある文 (if の初期化文など) には 1 つの文への置き換えしかできない。
+// 見つからなかった文や適用できなかった編集があればエラーを返す
+func (e *StmtEditor) Apply(root ast.Node) error {
+	var problems []string
+	astutil.Apply(root, func(c *astutil.Cursor) bool {
+		stmt, ok := c.Node().(ast.Stmt)
+		if !ok {
+			return true
+		}
+		ed := e.edits[stmt]
+		if ed == nil || ed.applied {
+			return true
+		}
+		ed.applied = true
+		if c.Index() < 0 {
+			if len(ed.before) > 0 || len(ed.after) > 0 || (ed.replaced && len(ed.replace) != 1) {
+				problems = append(problems, fmt.Sprintf("%T is not in a statement list", stmt))
+				return true
+			}
+			if ed.replaced {
+				c.Replace(ed.replace[0])
+				return false
+			}
+			return true
+		}
+		// InsertAfter は直後に挿入するので逆順に呼ぶ
+		for i := len(ed.after) - 1; i >= 0; i-- {
+			c.InsertAfter(ed.after[i])
+		}
+		if ed.replaced {
+			for i := len(ed.replace) - 1; i >= 1; i-- {
+				c.InsertAfter(ed.replace[i])
+			}
+		}
+		for _, s := range ed.before {
+			c.InsertBefore(s)
+		}
+		if !ed.replaced {
+			return true
+		}
+		if len(ed.replace) == 0 {
+			c.Delete()
+		} else {
+			c.Replace(ed.replace[0])
+		}
+		return false
+	}, nil)
+	for _, stmt := range e.order {
+		if !e.edits[stmt].applied {
+			problems = append(problems, fmt.Sprintf("%T not found", stmt))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("statement edits: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestStmtEditor(t *testing.T) {
+	src := `package main
+
+func main() {
+	for i := 0; i < 3; i++ {
+		switch i {
+		case 1:
+			if i > 0 {
+				println("one")
+			}
+		}
+	}
+	go func() {
+		println("a")
+		println("b")
+	}()
+}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "main.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := make(map[string]ast.Stmt)
+	var post ast.Stmt
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.ExprStmt:
+			lit := n.X.(*ast.CallExpr).Args[0].(*ast.BasicLit)
+			calls[strings.Trim(lit.Value, `"`)] = n
+		case *ast.ForStmt:
+			post = n.Post
+		}
+		return true
+	})
+
+	e := NewStmtEditor()
+	e.Replace(calls["one"], MustParseStmt("println(%q)", "1"), MustParseStmt("i++"))
+	e.InsertBefore(calls["a"], MustParseStmt("defer done()"))
+	e.InsertAfter(calls["a"], MustParseStmt("x := 1"), MustParseStmt("_ = x"))
+	e.Delete(calls["b"])
+	e.Replace(post, MustParseStmt("i += 2"))
+	if err := e.Apply(file); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, file); err != nil {
+		t.Fatal(err)
+	}
+	want := `package main
+
+func main() {
+	for i := 0; i < 3; i += 2 {
+		switch i {
+		case 1:
+			if i > 0 {
+				println("1")
+				i++
+			}
+		}
+	}
+	go func() {
+		defer done()
+		println("a")
+		x := 1
+		_ = x
+	}()
+}
+`
+	// 削除や挿入で文の位置がずれると空行が入ることがあるので、空行を除いて比べる
+	got := strings.ReplaceAll(buf.String(), "\n\n", "\n")
+	if want := strings.ReplaceAll(want, "\n\n", "\n"); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	e = NewStmtEditor()
+	post = file.Decls[0].(*ast.FuncDecl).Body.List[0].(*ast.ForStmt).Post
+	e.InsertBefore(post, MustParseStmt("i++"))
+	e.Delete(calls["b"]) // すでに削除した文
+	err = e.Apply(file)
+	if err == nil {
+		t.Fatal("want an error")
+	}
+	for _, want := range []string{"*ast.AssignStmt is not in a statement list", "*ast.ExprStmt not found"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not contain %q", err, want)
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"flag"
+	"go/ast"
+	"go/types"
+	"go/version"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+func runTestCheck(args []string) error {
+	fs := flag.NewFlagSet("testcheck", flag.ExitOnError)
+	minTests := fs.Int("min-tests", 2, "report packages with at least `n` tests that never call t.Parallel")
+	// テストを解析するので -test を指定しなくてもテストを読み込む
+	return runDiagnosticsWith(fs, args, loadOptions{Tests: true}, func(prog *Program) ([]Diagnostic, error) {
+		diags := checkTests(prog, *minTests)
+		sortDiagnostics(diags)
+		return diags, nil
+	})
+}
+
+// checkTests はテストの書き方の問題を探す。
+// t.Helper を呼ばずに失敗を報告するヘルパー、外側のテストの t を使うサブテスト、
+// Go 1.22 より前のループ変数を捕捉する並列のサブテスト、minTests 個以上のテストがあるのに t.Parallel を
+// 一度も呼ばないパッケージを指摘する
+func checkTests(prog *Program, minTests int) []Diagnostic {
+	var diags []Diagnostic
+	type pkgTests struct {
+		first    *ast.FuncDecl
+		count    int
+		parallel bool
+	}
+	byPath := make(map[string]*pkgTests)
+	var paths []string
+	for _, pkg := range prog.Packages {
+		diags = append(diags, checkHelpers(prog, pkg)...)
+		path := strings.TrimSuffix(pkg.PkgPath, "_test")
+		for _, file := range pkg.Syntax {
+			if !strings.HasSuffix(prog.Fset.Position(file.Pos()).Filename, "_test.go") {
+				continue
+			}
+			diags = append(diags, checkSubtests(prog, pkg, file)...)
+			if byPath[path] == nil {
+				byPath[path] = &pkgTests{}
+				paths = append(paths, path)
+			}
+			pt := byPath[path]
+			for _, decl := range file.Decls {
+				if fn, ok := decl.(*ast.FuncDecl); ok && isTestFunc(pkg.TypesInfo, fn) && strings.HasPrefix(fn.Name.Name, "Test") {
+					pt.count++
+					if pt.first == nil {
+						pt.first = fn
+					}
+				}
+			}
+			ast.Inspect(file, func(n ast.Node) bool {
+				if call, ok := n.(*ast.CallExpr); ok && testingMethod(pkg.TypesInfo, call) == "Parallel" {
+					pt.parallel = true
+				}
+				return !pt.parallel
+			})
+		}
+	}
+	for _, path := range paths {
+		if pt := byPath[path]; pt.count >= minTests && !pt.parallel {
+			diags = append(diags, newDiagnostic(prog.Fset, pt.first.Pos(), "testparallel",
+				"package %s has %d tests but none of them calls t.Parallel", path, pt.count))
+		}
+	}
+	return diags
+}
+
+// checkHelpers は *testing.T などを受け取って失敗やログを報告する (報告する関数に渡す) のに、
+// Helper を呼ばない関数を指摘する。Helper を呼ばないと、報告の行がヘルパーの中の行になる
+func checkHelpers(prog *Program, pkg *packages.Package) []Diagnostic {
+	info := pkg.TypesInfo
+	type helper struct {
+		decl   *ast.FuncDecl
+		param  types.Object
+		helper bool // Helper を呼んでいる
+	}
+	helpers := make(map[types.Object]*helper)
+	var order []*helper
+	for _, file := range pkg.Syntax {
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Body == nil || isTestFunc(info, fn) || fn.Name.Name == "TestMain" {
+				continue
+			}
+			for _, field := range fn.Type.Params.List {
+				if !isTestingType(info.TypeOf(field.Type)) || len(field.Names) == 0 {
+					continue
+				}
+				h := &helper{decl: fn, param: info.Defs[field.Names[0]]}
+				ast.Inspect(fn.Body, func(n ast.Node) bool {
+					if call, ok := n.(*ast.CallExpr); ok && testingMethod(info, call) == "Helper" && isMethodOn(info, call, h.param) {
+						h.helper = true
+					}
+					return !h.helper
+				})
+				helpers[info.Defs[fn.Name]] = h
+				order = append(order, h)
+				break
+			}
+		}
+	}
+	// 報告するヘルパーを呼ぶヘルパーも報告するので、増えなくなるまで繰り返す
+	reports := make(map[*helper]bool)
+	for changed := true; changed; {
+		changed = false
+		for _, h := range order {
+			if reports[h] {
+				continue
+			}
+			ast.Inspect(h.decl.Body, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok {
+					return !reports[h]
+				}
+				if isReportMethod(testingMethod(info, call)) && isMethodOn(info, call, h.param) {
+					reports[h] = true
+				}
+				if callee := helpers[calleeObject(info, call)]; callee != nil && reports[callee] {
+					for _, arg := range call.Args {
+						if isIdentOf(info, arg, h.param) {
+							reports[h] = true
+						}
+					}
+				}
+				return !reports[h]
+			})
+			changed = changed || reports[h]
+		}
+	}
+	var diags []Diagnostic
+	for _, h := range order {
+		if reports[h] && !h.helper && prog.inFocus(prog.Fset.Position(h.decl.Pos())) {
+			diags = append(diags, newDiagnostic(prog.Fset, h.decl.Name.Pos(), "testhelper",
+				"%s reports failures through %s but does not call %s.Helper()", h.decl.Name.Name, h.param.Name(), h.param.Name()))
+		}
+	}
+	return diags
+}
+
+// checkSubtests は t.Run に渡した関数リテラルが、自分の引数ではなく外側のテストの t を使っているものと、
+// Go 1.22 より前のファイルで t.Parallel を呼ぶのにループ変数を捕捉しているものを指摘する
+func checkSubtests(prog *Program, pkg *packages.Package, file *ast.File) []Diagnostic {
+	info := pkg.TypesInfo
+	goVersion := fileGoVersion(pkg, file)
+	sharedLoopVars := goVersion != "" && version.Compare(goVersion, "go1.22") < 0
+	var diags []Diagnostic
+	var stack []ast.Node
+	ast.Inspect(file, func(n ast.Node) bool {
+		if n == nil {
+			stack = stack[:len(stack)-1]
+			return true
+		}
+		stack = append(stack, n)
+		call, ok := n.(*ast.CallExpr)
+		if !ok || testingMethod(info, call) != "Run" || len(call.Args) != 2 {
+			return true
+		}
+		lit, ok := call.Args[1].(*ast.FuncLit)
+		if !ok || len(lit.Type.Params.List) != 1 || len(lit.Type.Params.List[0].Names) != 1 {
+			return true
+		}
+		var outer types.Object
+		if id, ok := call.Fun.(*ast.SelectorExpr).X.(*ast.Ident); ok {
+			outer = info.ObjectOf(id)
+		}
+		inner := info.Defs[lit.Type.Params.List[0].Names[0]]
+		var misuse *ast.Ident
+		parallel := false
+		ast.Inspect(lit.Body, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.Ident:
+				if misuse == nil && outer != nil && info.Uses[n] == outer {
+					misuse = n
+				}
+			case *ast.CallExpr:
+				if testingMethod(info, n) == "Parallel" && isMethodOn(info, n, inner) {
+					parallel = true
+				}
+			}
+			return true
+		})
+		if misuse != nil {
+			diags = append(diags, newDiagnostic(prog.Fset, misuse.Pos(), "subtest",
+				"subtest uses the enclosing test's %s instead of its own %s", outer.Name(), inner.Name()))
+		}
+		if parallel && sharedLoopVars {
+			for _, v := range capturedLoopVars(info, stack, lit) {
+				diags = append(diags, newDiagnostic(prog.Fset, lit.Pos(), "subtest",
+					"parallel subtest captures loop variable %s, which is shared between iterations before Go 1.22; copy it with %s := %s before t.Run",
+					v.Name(), v.Name(), v.Name()))
+			}
+		}
+		return true
+	})
+	return diags
+}
+
+// capturedLoopVars は stack の中の (同じ関数の) ループで宣言された変数のうち、lit の中で使われているものを返す
+func capturedLoopVars(info *types.Info, stack []ast.Node, lit *ast.FuncLit) []types.Object {
+	var vars []types.Object
+	for i := len(stack) - 2; i >= 0; i-- {
+		var idents []ast.Expr
+		switch loop := stack[i].(type) {
+		case *ast.FuncLit, *ast.FuncDecl:
+			i = 0
+		case *ast.RangeStmt:
+			idents = []ast.Expr{loop.Key, loop.Value}
+		case *ast.ForStmt:
+			if init, ok := loop.Init.(*ast.AssignStmt); ok {
+				idents = init.Lhs
+			}
+		}
+		for _, e := range idents {
+			id, ok := e.(*ast.Ident)
+			if !ok || info.Defs[id] == nil {
+				continue
+			}
+			v := info.Defs[id]
+			used := false
+			ast.Inspect(lit.Body, func(n ast.Node) bool {
+				if id, ok := n.(*ast.Ident); ok && info.Uses[id] == v {
+					used = true
+				}
+				return !used
+			})
+			if used {
+				vars = append(vars, v)
+			}
+		}
+	}
+	return vars
+}
+
+// isTestingType は t が *testing.T、*testing.B、*testing.F か testing.TB かどうかを返す
+func isTestingType(t types.Type) bool {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	n, ok := types.Unalias(t).(*types.Named)
+	if !ok || n.Obj().Pkg() == nil || n.Obj().Pkg().Path() != "testing" {
+		return false
+	}
+	switch n.Obj().Name() {
+	case "T", "B", "F", "TB":
+		return true
+	}
+	return false
+}
+
+// isTestFunc は fn が go test から呼ばれるテスト、ベンチマーク、ファズテスト関数かどうかを返す
+func isTestFunc(info *types.Info, fn *ast.FuncDecl) bool {
+	if fn.Recv != nil || !isTestFuncName(fn.Name.Name) || strings.HasPrefix(fn.Name.Name, "Example") {
+		return false
+	}
+	params := fn.Type.Params.List
+	return len(params) == 1 && len(params[0].Names) <= 1 && isTestingType(info.TypeOf(params[0].Type))
+}
+
+// testingMethod は call が testing の型のメソッドの呼び出しならメソッドの名前を返す
+func testingMethod(info *types.Info, call *ast.CallExpr) string {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || !isTestingType(info.TypeOf(sel.X)) {
+		return ""
+	}
+	return sel.Sel.Name
+}
+
+// isMethodOn は call のレシーバが obj かどうかを返す
+func isMethodOn(info *types.Info, call *ast.CallExpr, obj types.Object) bool {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	return ok && isIdentOf(info, sel.X, obj)
+}
+
+// isReportMethod は name が失敗やログを報告して、報告した行を出力する testing のメソッドかどうかを返す
+func isReportMethod(name string) bool {
+	switch name {
+	case "Error", "Errorf", "Fatal", "Fatalf", "Log", "Logf", "Skip", "Skipf":
+		return true
+	}
+	return false
+}
+
+// calleeObject は call が関数の呼び出しなら呼び出す関数のオブジェクトを返す
+func calleeObject(info *types.Info, call *ast.CallExpr) types.Object {
+	switch fun := ast.Unparen(call.Fun).(type) {
+	case *ast.Ident:
+		return info.Uses[fun]
+	case *ast.SelectorExpr:
+		return info.Uses[fun.Sel]
+	}
+	return nil
+}
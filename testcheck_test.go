@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestCheckTests(t *testing.T) {
+	files := map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.21\n",
+		"m.go":   "package m\n\nfunc Add(a, b int) int { return a + b }\n",
+		"m_test.go": `package m
+
+import "testing"
+
+func assertEqual(t *testing.T, got, want int) {
+	if got != want {
+		t.Errorf("got %d, want %d", got, want)
+	}
+}
+
+func assertAdd(t *testing.T, a, b, want int) {
+	assertEqual(t, Add(a, b), want)
+}
+
+func checked(tb testing.TB, got int) {
+	tb.Helper()
+	if got < 0 {
+		tb.Fatal("negative")
+	}
+}
+
+func TestAdd(t *testing.T) {
+	assertAdd(t, 1, 2, 3)
+	checked(t, Add(1, 2))
+}
+
+func TestTable(t *testing.T) {
+	tests := []struct{ a, b, want int }{{1, 2, 3}}
+	for _, tc := range tests {
+		t.Run("sequential", func(t *testing.T) {
+			assertEqual(t, Add(tc.a, tc.b), tc.want)
+		})
+		t.Run("parallel", func(t *testing.T) {
+			t.Parallel()
+			assertEqual(t, Add(tc.a, tc.b), tc.want)
+		})
+		tc := tc
+		t.Run("copied", func(t *testing.T) {
+			t.Parallel()
+			assertEqual(t, Add(tc.a, tc.b), tc.want)
+		})
+	}
+}
+
+func TestOuter(t *testing.T) {
+	t.Run("sub", func(st *testing.T) {
+		if Add(1, 1) != 2 {
+			t.Fatal("wrong")
+		}
+	})
+}
+`,
+		"serial/serial_test.go": `package serial
+
+import "testing"
+
+func TestA(t *testing.T) {}
+
+func TestB(t *testing.T) {}
+`,
+	}
+	prog, err := loadProgramWith(loadOptions{Tests: true}, writeModule(t, files), "./...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	diags := checkTests(prog, 2)
+	sortDiagnostics(diags)
+	assertLines(t, diagnosticMessages(diags), []string{
+		"m_test.go:5: assertEqual reports failures through t but does not call t.Helper()",
+		"m_test.go:11: assertAdd reports failures through t but does not call t.Helper()",
+		"m_test.go:33: parallel subtest captures loop variable tc, which is shared between iterations before Go 1.22; copy it with tc := tc before t.Run",
+		"m_test.go:48: subtest uses the enclosing test's t instead of its own st",
+		"serial_test.go:5: package example.com/m/serial has 2 tests but none of them calls t.Parallel",
+	})
+}
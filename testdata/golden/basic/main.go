@@ -0,0 +1,17 @@
+package main
+
+import (
+	"log"
+
+	"example.com/golden/store"
+)
+
+func main() {
+	s := store.New()
+	// TODO: read the keys from the command line
+	for _, k := range []string{"a", "b"} {
+		s.Put(k, len(k))
+	}
+	report := func(n int) { log.Printf("stored %d keys", n) }
+	report(s.Len())
+}
@@ -0,0 +1,28 @@
+package store
+
+import "sync"
+
+type Store struct {
+	mu sync.Mutex
+	m  map[string]int
+}
+
+// New は空の Store を返す
+func New() *Store {
+	return &Store{m: make(map[string]int)}
+}
+
+// Put は k に v を保存する
+func (s *Store) Put(k string, v int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.m[k] = v
+}
+
+func (s *Store) Len() int {
+	return len(s.m)
+}
+
+func (s *Store) reset(all bool) {
+	clear(s.m)
+}
@@ -0,0 +1,18 @@
+package assertcheck
+
+import "io"
+
+type file struct{}
+
+func (*file) Read(p []byte) (int, error) { return 0, nil }
+
+type sizer interface{ Read() int }
+
+func audit(r io.Reader) {
+	f := r.(*file) // want `type assertion io.Reader to \*assertcheck.file panics on failure; use the comma-ok form`
+	if g, ok := r.(*file); ok {
+		_ = g
+	}
+	_ = r.(sizer) // want `impossible type assertion: io.Reader to assertcheck.sizer: method Read has conflicting signatures`
+	_ = f
+}
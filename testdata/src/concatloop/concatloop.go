@@ -0,0 +1,21 @@
+package concatloop
+
+func join(names []string) string {
+	s := ""
+	for _, name := range names {
+		s += name + "," // want `s is built by string concatenation in a loop; use a strings.Builder`
+	}
+	return s
+}
+
+// ループの途中で s を読んでいるので書き直さない
+func firstLong(names []string) string {
+	s := ""
+	for _, name := range names {
+		s += name // want `s is built by string concatenation in a loop; use a strings.Builder`
+		if len(s) > 10 {
+			return s
+		}
+	}
+	return s
+}
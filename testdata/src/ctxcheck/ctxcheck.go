@@ -0,0 +1,17 @@
+package ctxcheck
+
+import "context"
+
+type Server struct {
+	ctx context.Context // want `context.Context stored in struct field ctx`
+}
+
+func handle(ctx context.Context, id int) {
+	lookup(id)
+}
+
+func lookup(id int) {
+	_ = context.Background() // want `context.Background\(\) called in lookup, which is reached from a function that already receives a ctx \(handle -> lookup\)`
+}
+
+func process(id int, ctx context.Context) {} // want `context.Context should be the first parameter, found at position 2`
@@ -0,0 +1,10 @@
+package deadbranch
+
+const debug = false
+
+func run() int {
+	if debug { // want `condition debug is always false`
+		return 1 // want `unreachable code`
+	}
+	return 0
+}
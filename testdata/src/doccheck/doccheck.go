@@ -0,0 +1,18 @@
+package doccheck
+
+// API のクライアント // want `doc comment for Client should start with "Client "`
+type Client struct{}
+
+// Do sends a request.
+func (c *Client) Do() {}
+
+func (c *Client) Close() {} // want `exported method Close should have a doc comment`
+
+type internal struct{}
+
+func (internal) Exported() {}
+
+// OldDo sends a request. // want `deprecation notice for OldDo should be a paragraph starting with "Deprecated: "`
+//
+// deprecated: use Do instead.
+func OldDo() {}
@@ -0,0 +1,11 @@
+package importcheck
+
+import (
+	"importcheck/names"
+	"fmt"       // want `standard library import "fmt" comes after third-party imports; group imports as standard library, third-party, then local`
+	. "strings" // want `dot import of "strings"; refer to its names through the package name`
+)
+
+func greet() string {
+	return fmt.Sprintf("hello, %s", ToUpper(names.Default))
+}
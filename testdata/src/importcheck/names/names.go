@@ -0,0 +1,3 @@
+package names
+
+const Default = "gopher"
@@ -0,0 +1,22 @@
+package makecap
+
+func index(names []string) map[string]int {
+	m := make(map[string]int) // want `make\(map\[string\]int\) has no size hint but the loop below adds up to len\(names\) entries to m`
+	for i, name := range names {
+		m[name] = i
+	}
+	return m
+}
+
+// ループの中で作る map は大きさが分からないので書き直さない
+func count(rows [][]string) int {
+	n := 0
+	for _, row := range rows {
+		seen := make(map[string]bool) // want `make\(map\[string\]bool\) allocates a new map on every iteration; consider reusing it with clear`
+		for _, cell := range row[1:] {
+			seen[cell] = true
+		}
+		n += len(seen)
+	}
+	return n
+}
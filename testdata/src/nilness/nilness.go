@@ -0,0 +1,18 @@
+package nilness
+
+type config struct{ name string }
+
+func branch(ok bool) string {
+	var c *config
+	if ok {
+		c = &config{}
+	}
+	return c.name // want `possible nil dereference: value is nil when ok is false`
+}
+
+func checked(c *config) string {
+	if c == nil {
+		return ""
+	}
+	return c.name
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// TestSelection はパッケージごとに実行するテスト。Tests が空ならパッケージのすべてのテストを実行する
+type TestSelection struct {
+	Package string   `json:"package"`
+	Tests   []string `json:"tests,omitempty"`
+	Run     string   `json:"run,omitempty"` // go test -run に渡すパターン
+}
+
+func runTestSelect(args []string) error {
+	fs := flag.NewFlagSet("testselect", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output as JSON")
+	fs.Parse(args)
+	prog, err := loadProgramWith(loadOptions{Tests: true}, ".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	changes, err := loadChanges(".", defaultRef, defaultChanges)
+	if err != nil {
+		return err
+	}
+	changed := changedFunctions(prog, changes)
+	selections := selectTests(prog, changed, impactOf(prog, changed))
+	if *asJSON {
+		return writeJSON(os.Stdout, selections)
+	}
+	return writeTestSelections(os.Stdout, selections)
+}
+
+// selectTests は変更された関数と影響を受けうる関数のうち、_test.go で宣言された
+// テスト、ファズテスト、Example 関数をパッケージごとに集める。TestMain が含まれるパッケージはすべてのテストを実行する。
+// ベンチマークは -run では実行されないので含めない
+func selectTests(prog *Program, changed []*ssa.Function, impact *Impact) []TestSelection {
+	fns := append([]*ssa.Function(nil), changed...)
+	for _, f := range impact.Impacted {
+		fns = append(fns, f.fn)
+	}
+	tests := make(map[string]map[string]bool)
+	all := make(map[string]bool)
+	for _, fn := range fns {
+		if fn.Pkg == nil || fn.Signature.Recv() != nil || !strings.HasSuffix(prog.Fset.Position(fn.Pos()).Filename, "_test.go") {
+			continue
+		}
+		pkg := fn.Pkg.Pkg.Path()
+		if strings.HasSuffix(fn.Pkg.Pkg.Name(), "_test") {
+			pkg = strings.TrimSuffix(pkg, "_test")
+		}
+		if tests[pkg] == nil {
+			tests[pkg] = make(map[string]bool)
+		}
+		switch name := fn.Name(); {
+		case name == "TestMain":
+			all[pkg] = true
+		case isTestFuncName(name) && !strings.HasPrefix(name, "Benchmark"):
+			tests[pkg][name] = true
+		}
+	}
+	var result []TestSelection
+	for pkg, names := range tests {
+		s := TestSelection{Package: pkg}
+		if !all[pkg] {
+			if len(names) == 0 {
+				continue
+			}
+			for name := range names {
+				s.Tests = append(s.Tests, name)
+			}
+			sort.Strings(s.Tests)
+			s.Run = runPattern(s.Tests)
+		}
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Package < result[j].Package })
+	return result
+}
+
+// runPattern は names のトップレベルのテストだけに一致する -run のパターンを返す
+func runPattern(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = regexp.QuoteMeta(name)
+	}
+	return "^(" + strings.Join(quoted, "|") + ")$"
+}
+
+// writeTestSelections はパッケージごとに go test のコマンドラインを出力する
+func writeTestSelections(w io.Writer, selections []TestSelection) error {
+	for _, s := range selections {
+		if s.Run == "" {
+			fmt.Fprintf(w, "go test %s\n", s.Package)
+			continue
+		}
+		fmt.Fprintf(w, "go test -run '%s' %s\n", s.Run, s.Package)
+	}
+	return nil
+}
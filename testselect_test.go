@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+func TestSelectTests(t *testing.T) {
+	files := map[string]string{
+		"svc/svc_ext_test.go": `package svc_test
+
+import (
+	"testing"
+
+	"example.com/m/svc"
+)
+
+func TestLookupExt(t *testing.T) { svc.Handle("y") }
+
+func BenchmarkHandle(b *testing.B) { svc.Handle("z") }
+`,
+	}
+	for name, src := range testdata_impact {
+		files[name] = src
+	}
+	prog, err := loadProgramWith(loadOptions{Tests: true}, writeModule(t, files), "./...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var changed []*ssa.Function
+	for _, fn := range prog.targetFunctions() {
+		if fn.RelString(nil) == "example.com/m/svc.Lookup" {
+			changed = append(changed, fn)
+		}
+	}
+	var buf bytes.Buffer
+	if err := writeTestSelections(&buf, selectTests(prog, changed, impactOf(prog, changed))); err != nil {
+		t.Fatal(err)
+	}
+	assertLines(t, strings.Split(strings.TrimSpace(buf.String()), "\n"), []string{
+		"go test -run '^(TestHandle|TestLookupExt)$' example.com/m/svc",
+	})
+}
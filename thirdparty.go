@@ -0,0 +1,154 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/types"
+	"io"
+	"os"
+	"sort"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// ExternalAPI は解析対象のモジュールが参照している依存モジュールの宣言
+type ExternalAPI struct {
+	Module  string   `json:"module"`
+	Version string   `json:"version,omitempty"`
+	Kind    string   `json:"kind"` // func, method, type, var, const, field
+	Symbol  string   `json:"symbol"`
+	Uses    int      `json:"uses"`
+	Users   []string `json:"users"` // 参照しているパッケージ
+}
+
+func runThirdParty(args []string) error {
+	fs := flag.NewFlagSet("thirdparty", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output as JSON")
+	module := fs.String("module", "", "only list APIs of the dependency with this module `path`")
+	fs.Parse(args)
+	prog, err := loadProgram(".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	apis := externalAPIs(prog.Packages)
+	if *module != "" {
+		var filtered []ExternalAPI
+		for _, api := range apis {
+			if api.Module == *module {
+				filtered = append(filtered, api)
+			}
+		}
+		apis = filtered
+	}
+	if *asJSON {
+		return writeJSON(os.Stdout, apis)
+	}
+	return writeExternalAPIs(os.Stdout, apis)
+}
+
+// packageModules は読み込んだ全パッケージ (依存を含む) のパスとモジュールの対応を返す。標準ライブラリは nil
+func packageModules(pkgs []*packages.Package) map[string]*packages.Module {
+	modules := make(map[string]*packages.Module)
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		modules[pkg.PkgPath] = pkg.Module
+	})
+	return modules
+}
+
+// externalAPIs は標準ライブラリでもメインモジュールでもないパッケージの宣言への参照を、モジュールごとに集める
+func externalAPIs(pkgs []*packages.Package) []ExternalAPI {
+	modules := packageModules(pkgs)
+	byObj := make(map[types.Object]*ExternalAPI)
+	users := make(map[types.Object]map[string]bool)
+	for _, pkg := range pkgs {
+		for _, obj := range pkg.TypesInfo.Uses {
+			if obj.Pkg() == nil {
+				continue
+			}
+			mod := modules[obj.Pkg().Path()]
+			if mod == nil || mod.Main {
+				continue
+			}
+			kind, symbol := apiSymbol(originObject(obj))
+			if kind == "" {
+				continue
+			}
+			obj = originObject(obj)
+			api, ok := byObj[obj]
+			if !ok {
+				api = &ExternalAPI{Module: mod.Path, Version: mod.Version, Kind: kind, Symbol: symbol}
+				byObj[obj] = api
+				users[obj] = make(map[string]bool)
+			}
+			api.Uses++
+			users[obj][pkg.PkgPath] = true
+		}
+	}
+	var apis []ExternalAPI
+	for obj, api := range byObj {
+		api.Users = sortedKeys(users[obj])
+		apis = append(apis, *api)
+	}
+	sort.Slice(apis, func(i, j int) bool {
+		if apis[i].Module != apis[j].Module {
+			return apis[i].Module < apis[j].Module
+		}
+		return apis[i].Symbol < apis[j].Symbol
+	})
+	return apis
+}
+
+// apiSymbol は参照されているものの種類と名前を返す。パッケージ名やローカル変数は対象にしない
+func apiSymbol(obj types.Object) (kind, symbol string) {
+	switch obj := obj.(type) {
+	case *types.Func:
+		if obj.Type().(*types.Signature).Recv() != nil {
+			return "method", obj.FullName()
+		}
+		return "func", obj.FullName()
+	case *types.TypeName:
+		return "type", obj.Pkg().Path() + "." + obj.Name()
+	case *types.Const:
+		return "const", obj.Pkg().Path() + "." + obj.Name()
+	case *types.Var:
+		if obj.IsField() {
+			return "field", obj.Pkg().Path() + "." + fieldOwner(obj) + obj.Name()
+		}
+		if obj.Parent() == obj.Pkg().Scope() {
+			return "var", obj.Pkg().Path() + "." + obj.Name()
+		}
+	}
+	return "", ""
+}
+
+// fieldOwner はフィールドを宣言している、パッケージレベルの構造体の型名に "." をつけたものを返す
+func fieldOwner(field *types.Var) string {
+	scope := field.Pkg().Scope()
+	for _, name := range scope.Names() {
+		tn, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok {
+			continue
+		}
+		if st, ok := tn.Type().Underlying().(*types.Struct); ok {
+			for i := 0; i < st.NumFields(); i++ {
+				if st.Field(i) == field {
+					return name + "."
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// writeExternalAPIs は依存モジュールごとに参照している宣言を一覧にする
+func writeExternalAPIs(w io.Writer, apis []ExternalAPI) error {
+	module := ""
+	for _, api := range apis {
+		if api.Module != module {
+			module = api.Module
+			fmt.Fprintf(w, "%s %s\n", api.Module, api.Version)
+		}
+		fmt.Fprintf(w, "\t%-6s %s (%d uses)\n", api.Kind, api.Symbol, api.Uses)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var testdata_thirdparty = map[string]string{
+	"go.mod": `module example.com/m
+
+go 1.22
+
+require example.com/dep v1.2.0
+
+replace example.com/dep => ./third_party/dep
+`,
+	"third_party/dep/go.mod": "module example.com/dep\n\ngo 1.22\n",
+	"third_party/dep/dep.go": `package dep
+
+const Version = "1"
+
+type Client struct{ Addr string }
+
+func New() *Client         { return &Client{} }
+func (c *Client) Do() error { return nil }
+`,
+	"util/util.go": "package util\n\nfunc Helper() {}\n",
+	"main.go": `package main
+
+import (
+	"fmt"
+
+	"example.com/dep"
+	"example.com/m/util"
+)
+
+func main() {
+	var c *dep.Client = dep.New()
+	c.Do()
+	c = dep.New()
+	fmt.Println(c.Addr, dep.Version)
+	util.Helper()
+}
+`,
+}
+
+func TestExternalAPIs(t *testing.T) {
+	prog := loadTestProgram(t, testdata_thirdparty)
+	var buf bytes.Buffer
+	if err := writeExternalAPIs(&buf, externalAPIs(prog.Packages)); err != nil {
+		t.Fatal(err)
+	}
+	assertLines(t, strings.Split(strings.TrimSpace(buf.String()), "\n"), []string{
+		"example.com/dep v1.2.0",
+		"\tmethod (*example.com/dep.Client).Do (1 uses)",
+		"\ttype   example.com/dep.Client (1 uses)",
+		"\tfield  example.com/dep.Client.Addr (1 uses)",
+		"\tfunc   example.com/dep.New (2 uses)",
+		"\tconst  example.com/dep.Version (1 uses)",
+	})
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/packages"
+)
+
+// Todo はコメント中の TODO, FIXME, HACK, XXX
+type Todo struct {
+	Marker string         `json:"marker"`
+	Owner  string         `json:"owner,omitempty"` // TODO(owner): の owner
+	Text   string         `json:"text"`
+	Decl   string         `json:"decl,omitempty"` // コメントを含む (またはコメントが doc になっている) 宣言
+	Pos    token.Position `json:"pos"`
+}
+
+var todoMarker = regexp.MustCompile(`\b(TODO|FIXME|HACK|XXX)\b(?:\(([^)]*)\))?:?\s*(.*)`)
+
+func runTodos(args []string) error {
+	fs := flag.NewFlagSet("todos", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output as JSON")
+	asMarkdown := fs.Bool("md", false, "output as a Markdown table")
+	fs.Parse(args)
+	prog, err := loadProgramWith(loadOptions{Tests: true}, ".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	todos := extractTodos(prog.Packages)
+	relativizePositions(todos)
+	switch {
+	case *asJSON:
+		return writeJSON(os.Stdout, todos)
+	case *asMarkdown:
+		return writeTodosMarkdown(os.Stdout, todos)
+	}
+	return writeTodos(os.Stdout, todos)
+}
+
+// extractTodos はコメントから TODO などの印を取り出し、それを囲む宣言と対応付ける
+func extractTodos(pkgs []*packages.Package) []Todo {
+	var todos []Todo
+	seen := make(map[string]bool) // テストのパッケージと元のパッケージで同じファイルを読むことがある
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Syntax {
+			filename := pkg.Fset.Position(file.Pos()).Filename
+			if seen[filename] {
+				continue
+			}
+			seen[filename] = true
+			in := inspector.New([]*ast.File{file})
+			docs := docOwners(in)
+			for _, group := range file.Comments {
+				decl := docs[group]
+				if decl == nil {
+					decl = enclosingDecl(enclosingStack(in, group.Pos()))
+				}
+				for _, c := range group.List {
+					for i, line := range strings.Split(c.Text, "\n") {
+						m := todoMarker.FindStringSubmatch(line)
+						if m == nil {
+							continue
+						}
+						pos := pkg.Fset.Position(c.Pos())
+						pos.Line += i
+						todos = append(todos, Todo{
+							Marker: m[1],
+							Owner:  m[2],
+							Text:   strings.TrimSpace(strings.TrimSuffix(m[3], "*/")),
+							Decl:   declName(pkg.TypesInfo, decl),
+							Pos:    pos,
+						})
+					}
+				}
+			}
+		}
+	}
+	return todos
+}
+
+// docOwners は doc コメントと行末のコメントから、それを持つ宣言と祖先のノード (最後が宣言) への対応を作る
+func docOwners(in *inspector.Inspector) map[*ast.CommentGroup][]ast.Node {
+	owners := make(map[*ast.CommentGroup][]ast.Node)
+	nodeTypes := []ast.Node{(*ast.FuncDecl)(nil), (*ast.GenDecl)(nil), (*ast.TypeSpec)(nil), (*ast.ValueSpec)(nil), (*ast.Field)(nil)}
+	in.WithStack(nodeTypes, func(n ast.Node, push bool, stack []ast.Node) bool {
+		if !push {
+			return true
+		}
+		stack = append([]ast.Node(nil), stack...)
+		var doc *ast.CommentGroup
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			doc = n.Doc
+		case *ast.GenDecl:
+			if len(n.Specs) == 1 && n.Lparen == token.NoPos {
+				owners[n.Doc] = append(stack, n.Specs[0]) // type T struct{} の doc は GenDecl につく
+				return true
+			}
+			doc = n.Doc
+		case *ast.TypeSpec:
+			doc = n.Doc
+			owners[n.Comment] = stack
+		case *ast.ValueSpec:
+			doc = n.Doc
+			owners[n.Comment] = stack
+		case *ast.Field:
+			doc = n.Doc
+			owners[n.Comment] = stack
+		}
+		if doc != nil {
+			owners[doc] = stack
+		}
+		return true
+	})
+	delete(owners, nil)
+	return owners
+}
+
+// enclosingStack は pos を含む最も内側のノードとその祖先 (最後が最も内側のノード) を返す
+func enclosingStack(in *inspector.Inspector, pos token.Pos) []ast.Node {
+	var inner []ast.Node
+	in.WithStack(nil, func(n ast.Node, push bool, stack []ast.Node) bool {
+		if !push || pos < n.Pos() || n.End() <= pos {
+			return false
+		}
+		inner = append(inner[:0], stack...)
+		return true
+	})
+	return inner
+}
+
+// enclosingDecl は stack を内側からたどって関数、型、変数、構造体のフィールドの宣言を探し、
+// その宣言までの stack を返す。見つからなければ nil
+func enclosingDecl(stack []ast.Node) []ast.Node {
+	for i := len(stack) - 1; i >= 0; i-- {
+		switch stack[i].(type) {
+		case *ast.FuncDecl, *ast.TypeSpec, *ast.ValueSpec:
+			return stack[:i+1]
+		case *ast.Field:
+			if i >= 2 {
+				if _, ok := stack[i-2].(*ast.StructType); ok {
+					return stack[:i+1]
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// declName は stack の最後の宣言を (T).Method, T, T.Field, x のような名前にする
+func declName(info *types.Info, stack []ast.Node) string {
+	if len(stack) == 0 {
+		return ""
+	}
+	switch d := stack[len(stack)-1].(type) {
+	case *ast.FuncDecl:
+		if fn, ok := info.Defs[d.Name].(*types.Func); ok {
+			if recv := fn.Type().(*types.Signature).Recv(); recv != nil {
+				return fmt.Sprintf("(%s).%s", types.TypeString(recv.Type(), types.RelativeTo(fn.Pkg())), fn.Name())
+			}
+		}
+		return d.Name.Name
+	case *ast.TypeSpec:
+		return d.Name.Name
+	case *ast.ValueSpec:
+		var names []string
+		for _, id := range d.Names {
+			names = append(names, id.Name)
+		}
+		return strings.Join(names, ", ")
+	case *ast.Field:
+		var names []string
+		for _, id := range d.Names {
+			names = append(names, id.Name)
+		}
+		if len(names) == 0 {
+			names = append(names, types.ExprString(d.Type))
+		}
+		if ts := enclosingDecl(stack[:len(stack)-1]); ts != nil {
+			return declName(info, ts) + "." + strings.Join(names, ", ")
+		}
+		return strings.Join(names, ", ")
+	}
+	return ""
+}
+
+func writeTodos(w io.Writer, todos []Todo) error {
+	for _, t := range todos {
+		marker := t.Marker
+		if t.Owner != "" {
+			marker += "(" + t.Owner + ")"
+		}
+		decl := ""
+		if t.Decl != "" {
+			decl = " [" + t.Decl + "]"
+		}
+		fmt.Fprintf(w, "%s: %s %s%s\n", t.Pos, marker, t.Text, decl)
+	}
+	return nil
+}
+
+func writeTodosMarkdown(w io.Writer, todos []Todo) error {
+	fmt.Fprintln(w, "| Marker | Owner | Text | Declaration | Position |")
+	fmt.Fprintln(w, "| --- | --- | --- | --- | --- |")
+	for _, t := range todos {
+		fmt.Fprintf(w, "| %s | %s | %s | `%s` | %s |\n", t.Marker, t.Owner, strings.ReplaceAll(t.Text, "|", `\|`), t.Decl, t.Pos)
+	}
+	return nil
+}
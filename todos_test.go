@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var testdata_todos = `package main
+
+// TODO(alice): split into smaller types
+type Server struct {
+	// FIXME: guard with a mutex
+	conns int
+	addr  string // HACK hardcoded default
+}
+
+// Serve は接続を受け付ける
+func (s *Server) Serve() {
+	/* XXX(bob): retry on error
+	   TODO: add metrics */
+	s.conns++
+}
+
+var handler = func() {
+	// TODO: implement
+}
+
+// TODOS is not a marker
+func main() {}
+`
+
+func TestExtractTodos(t *testing.T) {
+	prog := loadTestProgram(t, map[string]string{"main.go": testdata_todos})
+	todos := extractTodos(prog.Packages)
+	var buf bytes.Buffer
+	if err := writeTodos(&buf, todos); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		got = append(got, line[strings.Index(line, "main.go"):])
+	}
+	assertLines(t, got, []string{
+		"main.go:3:1: TODO(alice) split into smaller types [Server]",
+		"main.go:5:2: FIXME guard with a mutex [Server.conns]",
+		"main.go:7:15: HACK hardcoded default [Server.addr]",
+		"main.go:12:2: XXX(bob) retry on error [(*Server).Serve]",
+		"main.go:13:2: TODO add metrics [(*Server).Serve]",
+		"main.go:18:2: TODO implement [handler]",
+	})
+
+	buf.Reset()
+	if err := writeTodosMarkdown(&buf, todos[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "| TODO | alice | split into smaller types | `Server` |") {
+		t.Errorf("unexpected markdown:\n%s", buf.String())
+	}
+}
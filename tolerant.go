@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// loadErrorCategory は読み込みのエラーを指摘にしたときのカテゴリー
+func loadErrorCategory(e *PosError) string {
+	switch {
+	case errors.Is(e, ErrParse):
+		return "parse"
+	case errors.Is(e, ErrTypeCheck):
+		return "typecheck"
+	}
+	return "load"
+}
+
+// withLoadErrors は Tolerant で読み込んだ prog のエラーのあるパッケージの指摘を近似とし、
+// パッケージのエラーを指摘として加える。エラーがなければ diags をそのまま返す
+func withLoadErrors(prog *Program, diags []Diagnostic) []Diagnostic {
+	if len(prog.Errors) == 0 {
+		return diags
+	}
+	for i := range diags {
+		if prog.approximate[diags[i].Pos.Filename] {
+			diags[i].Approximate = true
+		}
+	}
+	for _, e := range prog.Errors {
+		diags = append(diags, Diagnostic{Pos: e.Pos, Category: loadErrorCategory(e), Message: e.Msg})
+	}
+	// SSA を使う解析 (nilness など) はエラーのあるパッケージの関数の本体を調べないので、指摘が欠けることを知らせる
+	for _, pkg := range prog.ssaSkipped() {
+		diags = append(diags, Diagnostic{Pos: packageError(pkg.Errors[0]).Pos, Category: "load", Severity: severityInfo,
+			Message: fmt.Sprintf("SSA-based checks skipped the function bodies of %s because it has errors", pkg.PkgPath)})
+	}
+	sortDiagnostics(diags)
+	return diags
+}
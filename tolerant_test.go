@@ -0,0 +1,82 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestTolerantLoad(t *testing.T) {
+	files := map[string]string{
+		"main.go": `package main
+
+import "example.com/m/lib"
+
+func main() {
+	lib.Run(1)
+	show(2)
+}
+
+func show(v int) {}
+
+func deref() int {
+	var p *int
+	return *p
+}
+`,
+		"lib/lib.go": `package lib
+
+import "context"
+
+// Run は壊れた関数を呼ぶ
+func Run(n int) {
+	var s string = n
+	undefined(s)
+	helper(nil, context.Background())
+}
+
+func helper(x *int, ctx context.Context) {
+	if false {
+		println(*x)
+	}
+}
+
+func Broken(
+`,
+	}
+	dir := writeModule(t, files)
+	if _, err := loadProgram(dir, "./..."); err == nil {
+		t.Fatal("loading without Tolerant: want an error")
+	}
+	prog, err := loadProgramWith(loadOptions{Tolerant: true}, dir, "./...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prog.Errors) == 0 {
+		t.Fatal("no errors collected")
+	}
+
+	var diags []Diagnostic
+	for _, check := range []func(*Program) []Diagnostic{checkContext, checkDeprecated, checkDocs, checkParams, checkDeadBranches, checkNilness, checkTypeAssertions} {
+		diags = append(diags, check(prog)...)
+	}
+	var got []string
+	for _, d := range withLoadErrors(prog, diags) {
+		d.Pos.Filename = filepath.Base(d.Pos.Filename)
+		got = append(got, d.String())
+	}
+	// エラーのない main パッケージの指摘は近似にせず、エラーのある lib に依存していても SSA の解析を省かない
+	assertLines(t, got, []string{
+		"lib.go:7:17: [typecheck] cannot use n (variable of type int) as string value in variable declaration",
+		"lib.go:8:2: [typecheck] undefined: undefined",
+		"lib.go:12:21: [context] context.Context should be the first parameter, found at position 2 (approximate)",
+		"lib.go:12:21: [params] parameter ctx of helper is never used (approximate)",
+		"lib.go:13:5: [deadbranch] condition false is always false (approximate)",
+		"lib.go:14:3: [deadbranch] unreachable code (approximate)",
+		"lib.go:18:6: [doc] exported function Broken should have a doc comment (approximate)",
+		"lib.go:18:14: [load] SSA-based checks skipped the function bodies of example.com/m/lib because it has errors",
+		"lib.go:18:14: [parse] expected ')', found 'EOF'",
+		"lib.go:18:14: [parse] expected ';', found 'EOF'",
+		"main.go:10:11: [params] parameter v of show is never used",
+		"main.go:14:9: [nilness] possible nil dereference: value is always nil",
+	})
+}
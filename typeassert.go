@@ -0,0 +1,198 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// 型アサーションの書き方
+const (
+	assertSingle  = "assert"   // x.(T)
+	assertCommaOK = "comma-ok" // v, ok := x.(T)
+	assertSwitch  = "switch"   // switch x.(type) の case
+)
+
+// 型アサーションの判定
+const (
+	assertOK         = "ok"
+	assertImpossible = "impossible" // 決して成功しない
+	assertUnchecked  = "unchecked"  // 失敗しうるのに ok を受け取らず、失敗すると panic する
+)
+
+// TypeAssertion は型アサーションまたは型 switch の 1 つの case
+type TypeAssertion struct {
+	Func    string         `json:"func"`    // アサーションを含む関数。パッケージレベルの宣言なら空
+	Operand string         `json:"operand"` // アサーションされるインターフェースの型
+	Type    string         `json:"type"`    // アサーションする型
+	Form    string         `json:"form"`
+	Status  string         `json:"status"`
+	Reason  string         `json:"reason,omitempty"`
+	Pos     token.Position `json:"pos"`
+}
+
+func runTypeAsserts(args []string) error {
+	fs := flag.NewFlagSet("typeasserts", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output as JSON")
+	problems := fs.Bool("problems", false, "only list impossible and unchecked assertions")
+	fs.Parse(args)
+	prog, err := loadProgram(".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	asserts := typeAssertions(prog)
+	if *problems {
+		var result []TypeAssertion
+		for _, a := range asserts {
+			if a.Status != assertOK {
+				result = append(result, a)
+			}
+		}
+		asserts = result
+	}
+	relativizePositions(asserts)
+	if *asJSON {
+		return writeJSON(os.Stdout, asserts)
+	}
+	return writeTypeAssertions(os.Stdout, asserts)
+}
+
+// typeAssertions は解析対象のパッケージのすべての型アサーションと型 switch の case を、
+// メソッドセットから成功しうるかどうかを判定して返す
+func typeAssertions(prog *Program) []TypeAssertion {
+	var result []TypeAssertion
+	for _, pkg := range prog.Packages {
+		for _, file := range pkg.Syntax {
+			for _, decl := range file.Decls {
+				name := ""
+				if fd, ok := decl.(*ast.FuncDecl); ok {
+					if fn, ok := pkg.TypesInfo.Defs[fd.Name].(*types.Func); ok {
+						name = fn.FullName()
+					}
+				}
+				result = append(result, declTypeAssertions(prog.Fset, pkg, decl, name)...)
+			}
+		}
+	}
+	return result
+}
+
+func declTypeAssertions(fset *token.FileSet, pkg *packages.Package, decl ast.Decl, name string) []TypeAssertion {
+	var result []TypeAssertion
+	commaOK := make(map[*ast.TypeAssertExpr]bool)
+	add := func(x ast.Expr, typ ast.Expr, form string, pos token.Pos) {
+		V, _ := pkg.TypesInfo.TypeOf(x).Underlying().(*types.Interface)
+		T := pkg.TypesInfo.TypeOf(typ)
+		if V == nil || T == nil {
+			return
+		}
+		a := TypeAssertion{
+			Func:    name,
+			Operand: types.TypeString(pkg.TypesInfo.TypeOf(x), nil),
+			Type:    types.TypeString(T, nil),
+			Form:    form,
+			Status:  assertOK,
+			Pos:     fset.Position(pos),
+		}
+		if reason := impossibleAssertion(V, T); reason != "" {
+			a.Status, a.Reason = assertImpossible, reason
+		} else if form == assertSingle && !(types.IsInterface(T) && types.Implements(V, T.Underlying().(*types.Interface))) {
+			a.Status = assertUnchecked
+		}
+		result = append(result, a)
+	}
+	ast.Inspect(decl, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			if len(n.Lhs) == 2 && len(n.Rhs) == 1 {
+				if ta, ok := ast.Unparen(n.Rhs[0]).(*ast.TypeAssertExpr); ok {
+					commaOK[ta] = true
+				}
+			}
+		case *ast.ValueSpec:
+			if len(n.Names) == 2 && len(n.Values) == 1 {
+				if ta, ok := ast.Unparen(n.Values[0]).(*ast.TypeAssertExpr); ok {
+					commaOK[ta] = true
+				}
+			}
+		case *ast.TypeSwitchStmt:
+			var x ast.Expr
+			switch s := n.Assign.(type) {
+			case *ast.AssignStmt:
+				x = s.Rhs[0].(*ast.TypeAssertExpr).X
+			case *ast.ExprStmt:
+				x = s.X.(*ast.TypeAssertExpr).X
+			}
+			for _, stmt := range n.Body.List {
+				for _, typ := range stmt.(*ast.CaseClause).List {
+					if tv := pkg.TypesInfo.Types[typ]; !tv.IsNil() {
+						add(x, typ, assertSwitch, typ.Pos())
+					}
+				}
+			}
+		case *ast.TypeAssertExpr:
+			if n.Type == nil {
+				return true // switch x.(type) は TypeSwitchStmt で扱う
+			}
+			form := assertSingle
+			if commaOK[n] {
+				form = assertCommaOK
+			}
+			add(n.X, n.Type, form, n.Pos())
+		}
+		return true
+	})
+	return result
+}
+
+// impossibleAssertion はインターフェース型 V の値をインターフェース型 T にアサーションしても決して成功しない場合にその理由を返す。
+// 具象型へのアサーションで成功しないものは型検査でエラーになるので、ここではインターフェース同士だけを調べる。
+// 同じ名前でシグネチャの異なるメソッドがあると、両方を実装する型は存在しない
+func impossibleAssertion(V *types.Interface, T types.Type) string {
+	iface, ok := T.Underlying().(*types.Interface)
+	if !ok {
+		return ""
+	}
+	for i := 0; i < V.NumMethods(); i++ {
+		m := V.Method(i)
+		obj, _, _ := types.LookupFieldOrMethod(iface, false, m.Pkg(), m.Name())
+		if f, ok := obj.(*types.Func); ok && !types.Identical(f.Type(), m.Type()) {
+			return fmt.Sprintf("method %s has conflicting signatures", m.Name())
+		}
+	}
+	return ""
+}
+
+// checkTypeAssertions は決して成功しないアサーションと、ok を受け取らない失敗しうるアサーションを指摘する
+func checkTypeAssertions(prog *Program) []Diagnostic {
+	var diags []Diagnostic
+	for _, a := range typeAssertions(prog) {
+		switch a.Status {
+		case assertImpossible:
+			diags = append(diags, Diagnostic{Pos: a.Pos, Category: "typeassert", Severity: severityError,
+				Message: fmt.Sprintf("impossible type assertion: %s to %s: %s", a.Operand, a.Type, a.Reason)})
+		case assertUnchecked:
+			diags = append(diags, Diagnostic{Pos: a.Pos, Category: "typeassert",
+				Message: fmt.Sprintf("type assertion %s to %s panics on failure; use the comma-ok form", a.Operand, a.Type)})
+		}
+	}
+	sortDiagnostics(diags)
+	return diags
+}
+
+func writeTypeAssertions(w io.Writer, asserts []TypeAssertion) error {
+	for _, a := range asserts {
+		fmt.Fprintf(w, "%s: %s: %s.(%s) [%s] %s", a.Pos, a.Func, a.Operand, a.Type, a.Form, a.Status)
+		if a.Reason != "" {
+			fmt.Fprintf(w, ": %s", a.Reason)
+		}
+		fmt.Fprintln(w)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTypeAssertions(t *testing.T) {
+	src := `package main
+
+import (
+	"fmt"
+	"io"
+)
+
+type file struct{}
+
+func (*file) Read(p []byte) (int, error) { return 0, nil }
+
+type named struct{}
+
+func (named) String() string { return "" }
+
+type sizer interface{ Read() int }
+
+func audit(r io.Reader, v any) {
+	f := r.(*file)
+	var s fmt.Stringer
+	n, ok := s.(named)
+	_ = r.(sizer)
+	_ = r.(interface{ io.Reader })
+	switch v.(type) {
+	case string, nil:
+	case fmt.Stringer:
+	}
+	fmt.Println(f, ok, n)
+}
+
+func main() { audit(nil, nil) }
+`
+	prog := loadTestProgram(t, map[string]string{"main.go": src})
+	var buf bytes.Buffer
+	if err := writeTypeAssertions(&buf, typeAssertions(prog)); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		got = append(got, line[strings.Index(line, "main.go:"):])
+	}
+	assertLines(t, got, []string{
+		"main.go:19:7: example.com/m.audit: io.Reader.(*example.com/m.file) [assert] unchecked",
+		"main.go:21:11: example.com/m.audit: fmt.Stringer.(example.com/m.named) [comma-ok] ok",
+		"main.go:22:6: example.com/m.audit: io.Reader.(example.com/m.sizer) [assert] impossible: method Read has conflicting signatures",
+		"main.go:23:6: example.com/m.audit: io.Reader.(interface{io.Reader}) [assert] ok",
+		"main.go:25:7: example.com/m.audit: any.(string) [switch] ok",
+		"main.go:26:7: example.com/m.audit: any.(fmt.Stringer) [switch] ok",
+	})
+	assertLines(t, diagnosticMessages(checkTypeAssertions(prog)), []string{
+		"main.go:19: type assertion io.Reader to *example.com/m.file panics on failure; use the comma-ok form",
+		"main.go:22: impossible type assertion: io.Reader to example.com/m.sizer: method Read has conflicting signatures",
+	})
+}
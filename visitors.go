@@ -0,0 +1,65 @@
+package main
+
+import (
+	"go/ast"
+	"reflect"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// VisitContext は VisitorRegistry に登録した関数に渡す走査の状態
+type VisitContext struct {
+	Pkg   *packages.Package
+	File  *ast.File
+	Stack []ast.Node // ファイルからノードまでの祖先 (最後がノード自身)。呼び出しの後は変わるので保持しない
+}
+
+// VisitorRegistry は解析ごとに関心のあるノードの型と関数を登録しておき、パッケージのファイルを 1 回だけ走査して、
+// ノードの型に関心のある関数を呼ぶ。解析ごとにファイルを ast.Inspect で走査し直すより速い
+type VisitorRegistry struct {
+	byType map[reflect.Type][]func(c *VisitContext, n ast.Node)
+	all    []func(c *VisitContext, n ast.Node) // 全てのノードに関心のある関数
+}
+
+// NewVisitorRegistry は空の VisitorRegistry を返す
+func NewVisitorRegistry() *VisitorRegistry {
+	return &VisitorRegistry{byType: make(map[reflect.Type][]func(c *VisitContext, n ast.Node))}
+}
+
+// Register は nodeTypes と同じ型のノードで fn を呼ぶように登録する。nodeTypes には (*ast.CallExpr)(nil) のような
+// 型だけの値を渡す。空なら全てのノードで呼ぶ
+func (r *VisitorRegistry) Register(nodeTypes []ast.Node, fn func(c *VisitContext, n ast.Node)) {
+	if len(nodeTypes) == 0 {
+		r.all = append(r.all, fn)
+		return
+	}
+	for _, n := range nodeTypes {
+		t := reflect.TypeOf(n)
+		r.byType[t] = append(r.byType[t], fn)
+	}
+}
+
+// Walk は pkgs のファイルを 1 回ずつ走査し、登録した関数をノードの出現順に呼ぶ。同じノードでは型を指定して
+// 登録した関数を登録順に呼び、その後に全てのノードに関心のある関数を呼ぶ
+func (r *VisitorRegistry) Walk(pkgs []*packages.Package) {
+	for _, pkg := range pkgs {
+		c := &VisitContext{Pkg: pkg}
+		for _, file := range pkg.Syntax {
+			c.File, c.Stack = file, c.Stack[:0]
+			ast.Inspect(file, func(n ast.Node) bool {
+				if n == nil {
+					c.Stack = c.Stack[:len(c.Stack)-1]
+					return true
+				}
+				c.Stack = append(c.Stack, n)
+				for _, fn := range r.byType[reflect.TypeOf(n)] {
+					fn(c, n)
+				}
+				for _, fn := range r.all {
+					fn(c, n)
+				}
+				return true
+			})
+		}
+	}
+}
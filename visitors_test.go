@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVisitorRegistry(t *testing.T) {
+	prog := loadTestProgram(t, map[string]string{"main.go": `package main
+
+import "fmt"
+
+type T struct{ n int }
+
+func (t T) show() { fmt.Println(t.n) }
+
+func main() {
+	f := func() { T{}.show() }
+	f()
+}
+`})
+	var got []string
+	r := NewVisitorRegistry()
+	r.Register([]ast.Node{(*ast.CallExpr)(nil)}, func(c *VisitContext, n ast.Node) {
+		var path []string
+		for _, p := range c.Stack[1 : len(c.Stack)-1] {
+			switch p := p.(type) {
+			case *ast.FuncDecl:
+				path = append(path, p.Name.Name)
+			case *ast.FuncLit:
+				path = append(path, "func")
+			}
+		}
+		got = append(got, fmt.Sprintf("call %s in %s", types.ExprString(n.(*ast.CallExpr).Fun), strings.Join(path, "/")))
+	})
+	r.Register([]ast.Node{(*ast.FuncDecl)(nil), (*ast.FuncLit)(nil)}, func(c *VisitContext, n ast.Node) {
+		if filepath.Base(c.Pkg.Fset.Position(c.File.Pos()).Filename) != "main.go" {
+			t.Errorf("unexpected file")
+		}
+		got = append(got, fmt.Sprintf("func at depth %d", len(c.Stack)-1))
+	})
+	var idents int
+	r.Register(nil, func(c *VisitContext, n ast.Node) {
+		if _, ok := n.(*ast.Ident); ok {
+			idents++
+		}
+	})
+	r.Walk(prog.Packages)
+	assertLines(t, got, []string{
+		"func at depth 1",
+		"call fmt.Println in show",
+		"func at depth 1",
+		"func at depth 4",
+		"call T{}.show in main/func",
+		"call f in main",
+	})
+	if idents == 0 {
+		t.Error("the visitor for all nodes was not called")
+	}
+}
+
+// loadBenchProgram は関数をたくさん含むモジュールを読み込む
+func loadBenchProgram(b *testing.B) *Program {
+	b.Helper()
+	dir := b.TempDir()
+	os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/m\n\ngo 1.22\n"), 0o644)
+	for i := 0; i < 20; i++ {
+		var src strings.Builder
+		src.WriteString("package main\n\nimport (\n\t\"context\"\n\t\"fmt\"\n)\n")
+		for j := 0; j < 50; j++ {
+			fmt.Fprintf(&src, "\nfunc f%d_%d(ctx context.Context, n int) int {\n\tfor i := 0; i < n; i++ {\n\t\tif i%%2 == 0 {\n\t\t\tfmt.Printf(\"%%d\\n\", i)\n\t\t}\n\t}\n\treturn n * 2\n}\n", i, j)
+		}
+		if i == 0 {
+			src.WriteString("\nfunc main() {}\n")
+		}
+		os.WriteFile(filepath.Join(dir, fmt.Sprintf("f%d.go", i)), []byte(src.String()), 0o644)
+	}
+	prog, err := loadProgram(dir, "./...")
+	if err != nil {
+		b.Fatal(err)
+	}
+	return prog
+}
+
+// benchVisitors は ast.Inspect で走査し直すか VisitorRegistry にまとめるかを比べるための 4 つの解析
+var benchVisitors = []struct {
+	types []ast.Node
+	match func(n ast.Node) bool
+}{
+	{[]ast.Node{(*ast.CallExpr)(nil)}, func(n ast.Node) bool { _, ok := n.(*ast.CallExpr); return ok }},
+	{[]ast.Node{(*ast.IfStmt)(nil)}, func(n ast.Node) bool { _, ok := n.(*ast.IfStmt); return ok }},
+	{[]ast.Node{(*ast.FuncType)(nil)}, func(n ast.Node) bool { _, ok := n.(*ast.FuncType); return ok }},
+	{[]ast.Node{(*ast.ReturnStmt)(nil)}, func(n ast.Node) bool { _, ok := n.(*ast.ReturnStmt); return ok }},
+}
+
+func BenchmarkInspectPerAnalysis(b *testing.B) {
+	prog := loadBenchProgram(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		count := 0
+		for _, v := range benchVisitors {
+			for _, pkg := range prog.Packages {
+				for _, file := range pkg.Syntax {
+					ast.Inspect(file, func(n ast.Node) bool {
+						if v.match(n) {
+							count++
+						}
+						return true
+					})
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkVisitorRegistry(b *testing.B) {
+	prog := loadBenchProgram(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		count := 0
+		r := NewVisitorRegistry()
+		for _, v := range benchVisitors {
+			r.Register(v.types, func(c *VisitContext, n ast.Node) { count++ })
+		}
+		r.Walk(prog.Packages)
+	}
+}
@@ -0,0 +1,403 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Provider は依存性注入のコンストラクタ (New で始まり、型の値を返す関数)
+type Provider struct {
+	Func    string         `json:"func"`
+	Name    string         `json:"name"` // パッケージ名で修飾した関数名
+	Type    string         `json:"type"` // 作る型
+	Pos     token.Position `json:"pos"`
+	Error   bool           `json:"error,omitempty"`   // error も返す
+	Cleanup bool           `json:"cleanup,omitempty"` // 後始末の関数も返す
+	InCycle bool           `json:"inCycle,omitempty"`
+
+	obj  *types.Func
+	decl *ast.FuncDecl
+	info *types.Info
+	typ  types.Type
+}
+
+// WiringEdge はコンストラクタ From が、コンストラクタ To の作る型 Type を使うこと
+type WiringEdge struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Type  string `json:"type"`
+	Kind  string `json:"kind"` // param (引数で受け取る) または call (中でコンストラクタを呼ぶ)
+	Cycle bool   `json:"cycle,omitempty"`
+}
+
+// MissingDependency はどのコンストラクタも作らない、コンストラクタの引数の型
+type MissingDependency struct {
+	Func string         `json:"func"`
+	Type string         `json:"type"`
+	Pos  token.Position `json:"pos"`
+}
+
+// WiringGraph はコンストラクタの間の依存関係
+type WiringGraph struct {
+	Providers []Provider          `json:"providers"`
+	Edges     []WiringEdge        `json:"edges"`
+	Missing   []MissingDependency `json:"missing,omitempty"`
+	Cycles    [][]string          `json:"cycles,omitempty"`
+	// Ambiguous はインターフェースの引数に複数のコンストラクタの型が当てはまるもの (引数の位置と候補)
+	Ambiguous []AmbiguousDependency `json:"ambiguous,omitempty"`
+}
+
+// AmbiguousDependency はインターフェースの引数を満たすコンストラクタが複数あるもの
+type AmbiguousDependency struct {
+	Func       string         `json:"func"`
+	Type       string         `json:"type"`
+	Pos        token.Position `json:"pos"`
+	Candidates []string       `json:"candidates"`
+}
+
+func runWiring(args []string) error {
+	fs := flag.NewFlagSet("wiring", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output as JSON")
+	format := fs.String("format", "mermaid", "diagram format: mermaid or dot")
+	fs.Parse(args)
+	if *format != "mermaid" && *format != "dot" {
+		return fmt.Errorf("invalid -format value %q (want mermaid or dot)", *format)
+	}
+	prog, err := loadProgram(".", fs.Args()...)
+	if err != nil {
+		return err
+	}
+	g := wiringGraph(prog)
+	if *asJSON {
+		relativizePositions(g)
+		return writeJSON(os.Stdout, g)
+	}
+	if *format == "dot" {
+		return writeWiringDOT(os.Stdout, g)
+	}
+	return writeWiringMermaid(os.Stdout, g)
+}
+
+// checkWiring はコンストラクタの依存の循環、どのコンストラクタも作らない引数の型、
+// インターフェースの引数を満たすコンストラクタが複数あるものを指摘する
+func checkWiring(prog *Program) []Diagnostic {
+	g := wiringGraph(prog)
+	byFunc := make(map[string]Provider)
+	for _, p := range g.Providers {
+		byFunc[p.Func] = p
+	}
+	var diags []Diagnostic
+	for _, cycle := range g.Cycles {
+		var names []string
+		for _, f := range append(append([]string(nil), cycle...), cycle[0]) {
+			names = append(names, byFunc[f].Name)
+		}
+		diags = append(diags, Diagnostic{Pos: byFunc[cycle[0]].Pos, Category: "wiring", Severity: severityError,
+			Message: fmt.Sprintf("constructors depend on each other: %s", strings.Join(names, " -> "))})
+	}
+	for _, m := range g.Missing {
+		diags = append(diags, Diagnostic{Pos: m.Pos, Category: "wiring",
+			Message: fmt.Sprintf("no constructor provides %s needed by %s", m.Type, m.Func)})
+	}
+	for _, a := range g.Ambiguous {
+		diags = append(diags, Diagnostic{Pos: a.Pos, Category: "wiring",
+			Message: fmt.Sprintf("%s needed by %s is provided by several constructors: %s", a.Type, a.Func, strings.Join(a.Candidates, ", "))})
+	}
+	sortDiagnostics(diags)
+	return diags
+}
+
+// wiringGraph は解析対象のパッケージのコンストラクタと、コンストラクタが引数で受け取る型、中で呼び出すコンストラクタによる
+// 依存関係のグラフを作る。引数の型はその型を作るコンストラクタ、なければ (インターフェースなら) その型を実装する型を作る
+// コンストラクタに対応させる。基本型、context.Context、関数の型の引数と可変長引数は外から与える値とみなして依存に含めない
+func wiringGraph(prog *Program) *WiringGraph {
+	g := &WiringGraph{}
+	providers := constructors(prog)
+	byObj := make(map[*types.Func]*Provider)
+	for _, p := range providers {
+		byObj[p.obj] = p
+	}
+	type edgeKey struct{ from, to, kind string }
+	seen := make(map[edgeKey]bool)
+	addEdge := func(from, to *Provider, kind string) {
+		if k := (edgeKey{from.Func, to.Func, kind}); !seen[k] {
+			seen[k] = true
+			g.Edges = append(g.Edges, WiringEdge{From: from.Func, To: to.Func, Type: to.Type, Kind: kind})
+		}
+	}
+	for _, p := range providers {
+		sig := p.obj.Type().(*types.Signature)
+		for i := 0; i < sig.Params().Len(); i++ {
+			param := sig.Params().At(i)
+			if sig.Variadic() && i == sig.Params().Len()-1 || !isDependencyType(param.Type()) {
+				continue
+			}
+			found := providersOf(providers, param.Type())
+			typeName := wiringTypeString(param.Type())
+			switch {
+			case len(found) == 0:
+				g.Missing = append(g.Missing, MissingDependency{Func: p.Name, Type: typeName, Pos: prog.Fset.Position(param.Pos())})
+			case len(found) > 1:
+				a := AmbiguousDependency{Func: p.Name, Type: typeName, Pos: prog.Fset.Position(param.Pos())}
+				for _, q := range found {
+					a.Candidates = append(a.Candidates, q.Name)
+				}
+				g.Ambiguous = append(g.Ambiguous, a)
+			}
+			for _, q := range found {
+				addEdge(p, q, "param")
+			}
+		}
+		ast.Inspect(p.decl.Body, func(n ast.Node) bool {
+			if call, ok := n.(*ast.CallExpr); ok {
+				if fn, ok := calleeObject(p.info, call).(*types.Func); ok && byObj[fn] != nil {
+					addEdge(p, byObj[fn], "call")
+				}
+			}
+			return true
+		})
+	}
+	sort.Slice(g.Edges, func(i, j int) bool {
+		a, b := g.Edges[i], g.Edges[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		if a.To != b.To {
+			return a.To < b.To
+		}
+		return a.Kind > b.Kind
+	})
+
+	var nodes []string
+	for _, p := range providers {
+		nodes = append(nodes, p.Func)
+	}
+	succs := make(map[string][]string)
+	self := make(map[string]bool)
+	for _, e := range g.Edges {
+		succs[e.From] = append(succs[e.From], e.To)
+		self[e.From] = self[e.From] || e.From == e.To
+	}
+	inCycle := make(map[string]int)
+	for _, scc := range stronglyConnected(nodes, succs) {
+		if len(scc) == 1 && !self[scc[0]] {
+			continue
+		}
+		sort.Strings(scc)
+		g.Cycles = append(g.Cycles, scc)
+		for _, f := range scc {
+			inCycle[f] = len(g.Cycles)
+		}
+	}
+	sort.Slice(g.Cycles, func(i, j int) bool { return g.Cycles[i][0] < g.Cycles[j][0] })
+	for i, e := range g.Edges {
+		g.Edges[i].Cycle = inCycle[e.From] != 0 && inCycle[e.From] == inCycle[e.To]
+	}
+	for _, p := range providers {
+		p.InCycle = inCycle[p.Func] != 0
+		g.Providers = append(g.Providers, *p)
+	}
+	return g
+}
+
+// constructors は解析対象のパッケージ (_test.go を除く) の、New で始まる名前で、名前付きの型 (かそのポインタ) を返す関数を、
+// 宣言の順に返す。結果は型だけか、型と error、または型と後始末の関数と error (google/wire のプロバイダの形) のもの
+func constructors(prog *Program) []*Provider {
+	var result []*Provider
+	for _, pkg := range prog.Packages {
+		for _, file := range pkg.Syntax {
+			if strings.HasSuffix(prog.Fset.Position(file.Pos()).Filename, "_test.go") {
+				continue
+			}
+			for _, decl := range file.Decls {
+				fd, ok := decl.(*ast.FuncDecl)
+				if !ok || fd.Recv != nil || fd.Body == nil || !strings.HasPrefix(fd.Name.Name, "New") {
+					continue
+				}
+				if rest := strings.TrimPrefix(fd.Name.Name, "New"); rest != "" && !isUpper(rest) {
+					continue
+				}
+				obj, _ := pkg.TypesInfo.Defs[fd.Name].(*types.Func)
+				if obj == nil {
+					continue
+				}
+				res := obj.Type().(*types.Signature).Results()
+				p := &Provider{
+					Func: obj.FullName(), Name: pkg.Name + "." + obj.Name(), Pos: prog.Fset.Position(fd.Name.Pos()),
+					obj: obj, decl: fd, info: pkg.TypesInfo,
+				}
+				switch {
+				case res.Len() == 1:
+				case res.Len() == 2 && isErrorType(res.At(1).Type()):
+					p.Error = true
+				case res.Len() == 3 && isCleanupFunc(res.At(1).Type()) && isErrorType(res.At(2).Type()):
+					p.Error, p.Cleanup = true, true
+				default:
+					continue
+				}
+				p.typ = res.At(0).Type()
+				if !isProvidedType(p.typ) {
+					continue
+				}
+				p.Type = wiringTypeString(p.typ)
+				result = append(result, p)
+			}
+		}
+	}
+	return result
+}
+
+// isUpper は s が大文字で始まるかどうかを返す
+func isUpper(s string) bool {
+	return s != "" && strings.ToUpper(s[:1]) == s[:1] && strings.ToLower(s[:1]) != s[:1]
+}
+
+// isCleanupFunc は t が func() かどうかを返す
+func isCleanupFunc(t types.Type) bool {
+	sig, ok := t.Underlying().(*types.Signature)
+	return ok && sig.Params().Len() == 0 && sig.Results().Len() == 0
+}
+
+// isProvidedType は t がコンストラクタの作る型 (名前付きの型かそのポインタで、基本型やエラーでないもの) かどうかを返す
+func isProvidedType(t types.Type) bool {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	_, named := types.Unalias(t).(*types.Named)
+	return named && isDependencyType(t)
+}
+
+// isDependencyType は引数の型 t がコンストラクタから注入される依存かどうかを返す
+func isDependencyType(t types.Type) bool {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	if _, ok := types.Unalias(t).(*types.Named); !ok {
+		return false
+	}
+	switch t.Underlying().(type) {
+	case *types.Basic, *types.Signature:
+		return false
+	}
+	switch types.TypeString(t, nil) {
+	case "context.Context", "error":
+		return false
+	}
+	return true
+}
+
+// providersOf は t を作るコンストラクタを返す。なければ t がインターフェースのとき、t を実装する型を作るコンストラクタを返す
+func providersOf(providers []*Provider, t types.Type) []*Provider {
+	var exact, impls []*Provider
+	iface, isIface := t.Underlying().(*types.Interface)
+	for _, p := range providers {
+		switch {
+		case types.Identical(p.typ, t):
+			exact = append(exact, p)
+		case isIface && types.Implements(p.typ, iface):
+			impls = append(impls, p)
+		}
+	}
+	if len(exact) > 0 {
+		return exact
+	}
+	return impls
+}
+
+// wiringTypeString は t をパッケージ名で修飾した文字列にする
+func wiringTypeString(t types.Type) string {
+	return types.TypeString(t, func(pkg *types.Package) string { return pkg.Name() })
+}
+
+// writeWiringMermaid は依存関係を Mermaid の flowchart で出力する。引数による依存は実線、
+// 中での呼び出しは点線、作るコンストラクタのない型は赤い枠のノード、循環に含まれる辺は赤くする
+func writeWiringMermaid(w io.Writer, g *WiringGraph) error {
+	fmt.Fprintln(w, "flowchart LR")
+	ids := make(map[string]string)
+	for i, p := range g.Providers {
+		ids[p.Func] = fmt.Sprintf("P%d", i)
+		fmt.Fprintf(w, "    %s[\"%s<br/>%s\"]\n", ids[p.Func], p.Name, p.Type)
+	}
+	missing := make(map[string]string)
+	for _, m := range g.Missing {
+		if _, ok := missing[m.Type]; !ok {
+			missing[m.Type] = fmt.Sprintf("M%d", len(missing))
+			fmt.Fprintf(w, "    %s[\"%s\"]\n", missing[m.Type], m.Type)
+			fmt.Fprintf(w, "    style %s stroke:red\n", missing[m.Type])
+		}
+	}
+	funcIDs := make(map[string]string)
+	for _, p := range g.Providers {
+		funcIDs[p.Name] = ids[p.Func]
+	}
+	n := 0
+	var cycleLinks []int
+	for _, e := range g.Edges {
+		arrow := "-->"
+		if e.Kind == "call" {
+			arrow = "-.->"
+		}
+		fmt.Fprintf(w, "    %s %s %s\n", ids[e.From], arrow, ids[e.To])
+		if e.Cycle {
+			cycleLinks = append(cycleLinks, n)
+		}
+		n++
+	}
+	for _, m := range g.Missing {
+		fmt.Fprintf(w, "    %s --> %s\n", funcIDs[m.Func], missing[m.Type])
+	}
+	for _, i := range cycleLinks {
+		fmt.Fprintf(w, "    linkStyle %d stroke:red\n", i)
+	}
+	return nil
+}
+
+// writeWiringDOT は依存関係を DOT で出力する。表し方は writeWiringMermaid と同じ
+func writeWiringDOT(w io.Writer, g *WiringGraph) error {
+	fmt.Fprintln(w, "digraph wiring {")
+	fmt.Fprintln(w, "  rankdir=LR;")
+	fmt.Fprintln(w, "  node [shape=box];")
+	names := make(map[string]string)
+	for _, p := range g.Providers {
+		names[p.Func] = p.Name
+		label := p.Name + "\\n" + p.Type
+		if p.InCycle {
+			fmt.Fprintf(w, "  %q [label=\"%s\", color=red];\n", p.Name, label)
+		} else {
+			fmt.Fprintf(w, "  %q [label=\"%s\"];\n", p.Name, label)
+		}
+	}
+	seen := make(map[string]bool)
+	for _, m := range g.Missing {
+		if !seen[m.Type] {
+			seen[m.Type] = true
+			fmt.Fprintf(w, "  %q [color=red, style=dashed];\n", m.Type)
+		}
+	}
+	for _, e := range g.Edges {
+		var attrs []string
+		if e.Kind == "call" {
+			attrs = append(attrs, "style=dashed")
+		}
+		if e.Cycle {
+			attrs = append(attrs, "color=red")
+		}
+		if len(attrs) > 0 {
+			fmt.Fprintf(w, "  %q -> %q [%s];\n", names[e.From], names[e.To], strings.Join(attrs, ", "))
+		} else {
+			fmt.Fprintf(w, "  %q -> %q;\n", names[e.From], names[e.To])
+		}
+	}
+	for _, m := range g.Missing {
+		fmt.Fprintf(w, "  %q -> %q [color=red];\n", m.Func, m.Type)
+	}
+	fmt.Fprintln(w, "}")
+	return nil
+}
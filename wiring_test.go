@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const testdata_wiring = `package main
+
+import (
+	"context"
+	"database/sql"
+)
+
+type Calculator struct{}
+
+func NewCalculator() *Calculator {
+	return &Calculator{}
+}
+
+type A struct {
+	base       int
+	calculator *Calculator
+}
+
+func NewA(base int) *A {
+	return &A{calculator: NewCalculator(), base: base}
+}
+
+type Store interface{ Get(string) string }
+
+type memStore struct{}
+
+func (memStore) Get(string) string { return "" }
+
+type dbStore struct{ db *sql.DB }
+
+func (dbStore) Get(string) string { return "" }
+
+func NewMemStore() *memStore { return &memStore{} }
+
+func NewDBStore(db *sql.DB) (*dbStore, func(), error) { return &dbStore{db}, func() {}, nil }
+
+type Service struct {
+	a     *A
+	store Store
+}
+
+func NewService(ctx context.Context, a *A, store Store) (*Service, error) {
+	return &Service{a, store}, nil
+}
+
+type Ping struct{ pong *Pong }
+
+type Pong struct{ ping *Ping }
+
+func NewPing(p *Pong) *Ping { return &Ping{p} }
+
+func NewPong(p *Ping) *Pong { return &Pong{p} }
+
+func Newton() *Calculator { return nil }
+
+func main() {
+	NewService(context.Background(), NewA(1), NewMemStore())
+}
+`
+
+func TestWiringGraph(t *testing.T) {
+	prog := loadTestProgram(t, map[string]string{"main.go": testdata_wiring})
+	g := wiringGraph(prog)
+	var edges []string
+	for _, e := range g.Edges {
+		edges = append(edges, e.From+" -> "+e.To+" "+e.Kind+" "+e.Type)
+	}
+	assertLines(t, edges, []string{
+		"example.com/m.NewA -> example.com/m.NewCalculator call *main.Calculator",
+		"example.com/m.NewPing -> example.com/m.NewPong param *main.Pong",
+		"example.com/m.NewPong -> example.com/m.NewPing param *main.Ping",
+		"example.com/m.NewService -> example.com/m.NewA param *main.A",
+		"example.com/m.NewService -> example.com/m.NewDBStore param *main.dbStore",
+		"example.com/m.NewService -> example.com/m.NewMemStore param *main.memStore",
+	})
+	assertLines(t, diagnosticMessages(checkWiring(prog)), []string{
+		"main.go:35: no constructor provides *sql.DB needed by main.NewDBStore",
+		"main.go:42: main.Store needed by main.NewService is provided by several constructors: main.NewMemStore, main.NewDBStore",
+		"main.go:50: constructors depend on each other: main.NewPing -> main.NewPong -> main.NewPing",
+	})
+
+	var buf bytes.Buffer
+	if err := writeWiringMermaid(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	assertLines(t, strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n"), []string{
+		"flowchart LR",
+		`    P0["main.NewCalculator<br/>*main.Calculator"]`,
+		`    P1["main.NewA<br/>*main.A"]`,
+		`    P2["main.NewMemStore<br/>*main.memStore"]`,
+		`    P3["main.NewDBStore<br/>*main.dbStore"]`,
+		`    P4["main.NewService<br/>*main.Service"]`,
+		`    P5["main.NewPing<br/>*main.Ping"]`,
+		`    P6["main.NewPong<br/>*main.Pong"]`,
+		`    M0["*sql.DB"]`,
+		"    style M0 stroke:red",
+		"    P1 -.-> P0",
+		"    P5 --> P6",
+		"    P6 --> P5",
+		"    P4 --> P1",
+		"    P4 --> P3",
+		"    P4 --> P2",
+		"    P3 --> M0",
+		"    linkStyle 1 stroke:red",
+		"    linkStyle 2 stroke:red",
+	})
+}
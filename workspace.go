@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// workspace は go.work で一緒に開発される複数のモジュール
+type workspace struct {
+	File    string   // go.work のパス
+	Modules []string // use されたモジュールのディレクトリ (絶対パス)
+	Env     []string // go コマンドに渡す環境変数
+}
+
+// findWorkspace は dir で go コマンドが使う go.work を読む。ワークスペースでなければ nil を返す。
+// モジュールの一覧は go list -m で求めるので、go.work の replace もそのまま go コマンドが解決する
+func findWorkspace(dir string) (*workspace, error) {
+	out, err := goCommand(dir, nil, "env", "GOWORK")
+	if err != nil {
+		return nil, err
+	}
+	file := strings.TrimSpace(string(out))
+	if file == "" || file == "off" {
+		return nil, nil
+	}
+	ws := &workspace{File: file, Env: workspaceEnv(os.Environ())}
+	if out, err = goCommand(dir, ws.Env, "list", "-m", "-f", "{{.Dir}}"); err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if line != "" {
+			ws.Modules = append(ws.Modules, line)
+		}
+	}
+	return ws, nil
+}
+
+// workspaceEnv は GOFLAGS から -mod を除いた環境変数を返す。ワークスペースでは -mod=mod を指定できない
+func workspaceEnv(environ []string) []string {
+	env := make([]string, 0, len(environ))
+	for _, kv := range environ {
+		if flags, ok := strings.CutPrefix(kv, "GOFLAGS="); ok {
+			var kept []string
+			for _, f := range strings.Fields(flags) {
+				if f != "-mod" && !strings.HasPrefix(f, "-mod=") && !strings.HasPrefix(f, "--mod=") {
+					kept = append(kept, f)
+				}
+			}
+			kv = "GOFLAGS=" + strings.Join(kept, " ")
+		}
+		env = append(env, kv)
+	}
+	return env
+}
+
+// patterns は dir からの相対パスのパターンのうち、どのモジュールにも含まれないディレクトリの ./... を、
+// その下にあるワークスペースのモジュールごとのパターンに展開する。go.work だけがあるディレクトリで ./... を
+// 指定したときに、ワークスペースの全モジュールを対象にするため
+func (ws *workspace) patterns(dir string, patterns []string) ([]string, error) {
+	var result []string
+	for _, pattern := range patterns {
+		rel, ok := strings.CutSuffix(pattern, "/...")
+		if !ok || !(rel == "." || strings.HasPrefix(rel, "./") || strings.HasPrefix(rel, "../")) {
+			result = append(result, pattern)
+			continue
+		}
+		root, err := filepath.Abs(filepath.Join(dir, rel))
+		if err != nil {
+			return nil, err
+		}
+		if ws.moduleOf(root) != "" {
+			result = append(result, pattern)
+			continue
+		}
+		var expanded []string
+		for _, mod := range ws.Modules {
+			if r, err := filepath.Rel(root, mod); err == nil && r != ".." && !strings.HasPrefix(r, "../") {
+				p, _ := filepath.Rel(dir, mod)
+				expanded = append(expanded, "./"+filepath.ToSlash(p)+"/...")
+			}
+		}
+		if len(expanded) == 0 {
+			return nil, notFound("%s: no modules of %s in this directory", pattern, ws.File)
+		}
+		result = append(result, expanded...)
+	}
+	return result, nil
+}
+
+// moduleOf は path を含むワークスペースのモジュールのディレクトリを返す。含まれなければ空
+func (ws *workspace) moduleOf(path string) string {
+	for _, mod := range ws.Modules {
+		if path == mod || strings.HasPrefix(path, mod+string(filepath.Separator)) {
+			return mod
+		}
+	}
+	return ""
+}
+
+// goCommand は dir で go コマンドを実行して標準出力を返す。env が nil なら今の環境変数を使う
+func goCommand(dir string, env []string, args ...string) ([]byte, error) {
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	cmd.Env = env
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
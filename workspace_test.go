@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestLoadWorkspace(t *testing.T) {
+	files := map[string]string{
+		"go.work":    "go 1.22\n\nuse (\n\t./app\n\t./lib\n)\n\nreplace example.com/util => ./util\n",
+		"app/go.mod": "module example.com/app\n\ngo 1.22\n\nrequire example.com/util v0.0.0\n",
+		"app/main.go": `package main
+
+import (
+	"example.com/lib"
+	"example.com/util"
+)
+
+func main() { lib.Run(util.Name()) }
+`,
+		"lib/go.mod":   "module example.com/lib\n\ngo 1.22\n",
+		"lib/lib.go":   "package lib\n\nfunc Run(name string) { println(name) }\n",
+		"util/go.mod":  "module example.com/util\n\ngo 1.22\n",
+		"util/util.go": "package util\n\nfunc Name() string { return \"x\" }\n",
+	}
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 環境の GOFLAGS に -mod=mod があってもワークスペースを読み込める
+	t.Setenv("GOFLAGS", "-mod=mod")
+
+	prog, err := loadProgram(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, pkg := range prog.Packages {
+		paths = append(paths, pkg.PkgPath)
+	}
+	sort.Strings(paths)
+	assertLines(t, paths, []string{"example.com/app", "example.com/lib"})
+
+	var edges []string
+	for _, e := range callEdges(prog, callGraphOptions{Std: stdKeep}) {
+		edges = append(edges, e.Caller+" --> "+e.Callee)
+	}
+	// go.work の replace で解決したモジュールへの呼び出しも含まれる
+	for _, want := range []string{"example.com/app.main --> example.com/lib.Run", "example.com/app.main --> example.com/util.Name"} {
+		if !strings.Contains(strings.Join(edges, "\n"), want) {
+			t.Errorf("missing edge %s in\n%s", want, strings.Join(edges, "\n"))
+		}
+	}
+
+	// モジュールのディレクトリから読み込めば、そのモジュールのパッケージだけになる
+	prog, err = loadProgram(filepath.Join(dir, "lib"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prog.Packages) != 1 || prog.Packages[0].PkgPath != "example.com/lib" {
+		t.Errorf("got %d packages, want example.com/lib only", len(prog.Packages))
+	}
+
+	if got := workspaceEnv([]string{"HOME=/h", "GOFLAGS=-mod=mod -trimpath"}); strings.Join(got, " ") != "HOME=/h GOFLAGS=-trimpath" {
+		t.Errorf("workspaceEnv: got %v", got)
+	}
+}